@@ -0,0 +1,89 @@
+package commands
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/slyt3/Logryph/internal/secretref"
+	"github.com/slyt3/Logryph/internal/sockaddr"
+)
+
+const (
+	defaultAdminAddr = "localhost:9998"
+	maxAdminRespSize = 1 << 20
+	adminTimeout     = 10 * time.Second
+)
+
+// httpEndpoint returns the base URL and a client for addr: a URL, a host:port, or a
+// unix:/path socket.
+func httpEndpoint(addr string, timeout time.Duration) (string, *http.Client) {
+	if path, ok := sockaddr.UnixPath(addr); ok {
+		base, _ := http.DefaultTransport.(*http.Transport)
+		return "http://localhost", &http.Client{Timeout: timeout, Transport: sockaddr.Transport(base, path)}
+	}
+	if !strings.Contains(addr, "://") {
+		addr = "http://" + addr
+	}
+	return addr, &http.Client{Timeout: timeout}
+}
+
+// adminAddr is the admin API address from LOGRYPH_ADMIN_ADDR, matching the proxy's
+// --admin-listen, or localhost:9998.
+func adminAddr() string {
+	if addr := os.Getenv("LOGRYPH_ADMIN_ADDR"); addr != "" {
+		return addr
+	}
+	return defaultAdminAddr
+}
+
+// adminRequest calls the local admin API, attaching X-Admin-Token from LOGRYPH_ADMIN_TOKEN.
+// A non-nil body is sent as JSON. Returns the status code and response body
+// (capped at maxAdminRespSize).
+func adminRequest(method, path string, header map[string]string, body []byte) (int, []byte, error) {
+	return adminRequestLimit(method, path, header, body, adminTimeout, maxAdminRespSize)
+}
+
+// adminRequestLimit is adminRequest with an explicit timeout and response size cap,
+// for long-running or large responses such as CPU profiles.
+func adminRequestLimit(method, path string, header map[string]string, body []byte, timeout time.Duration, limit int64) (int, []byte, error) {
+	var reqBody io.Reader
+	if body != nil {
+		reqBody = bytes.NewReader(body)
+	}
+	baseURL, client := httpEndpoint(adminAddr(), timeout)
+	req, err := http.NewRequest(method, baseURL+path, reqBody)
+	if err != nil {
+		return 0, nil, fmt.Errorf("building request: %w", err)
+	}
+	token, err := secretref.Getenv("LOGRYPH_ADMIN_TOKEN")
+	if err != nil {
+		return 0, nil, err
+	}
+	if token != "" {
+		req.Header.Set("X-Admin-Token", token)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for k, v := range header {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, nil, fmt.Errorf("contacting Logryph API: %w", err)
+	}
+	respBody, readErr := io.ReadAll(io.LimitReader(resp.Body, limit))
+	if closeErr := resp.Body.Close(); closeErr != nil && readErr == nil {
+		readErr = closeErr
+	}
+	if readErr != nil {
+		return resp.StatusCode, nil, fmt.Errorf("reading response body: %w", readErr)
+	}
+	return resp.StatusCode, respBody, nil
+}
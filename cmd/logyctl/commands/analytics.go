@@ -5,21 +5,39 @@ import (
 	"flag"
 	"fmt"
 	"log"
+	"math"
 	"os"
-
-	"net/http"
 	"time"
 
+	"github.com/slyt3/Logryph/internal/api"
 	"github.com/slyt3/Logryph/internal/assert"
+	"github.com/slyt3/Logryph/internal/capacity"
+	"github.com/slyt3/Logryph/internal/ledger"
 	"github.com/slyt3/Logryph/internal/ledger/store"
+	"github.com/slyt3/Logryph/internal/models"
+	"github.com/slyt3/Logryph/internal/observer"
 	"github.com/slyt3/Logryph/internal/pool"
+	"github.com/slyt3/Logryph/internal/vql"
 )
 
-func EventsCommand() {
+func EventsCommand(args []string) {
 	// Parse flags
 	eventsFlags := flag.NewFlagSet("events", flag.ExitOnError)
 	limit := eventsFlags.Int("limit", 10, "Number of events to show")
-	_ = eventsFlags.Parse(os.Args[2:])
+	var labelArgs labelFlag
+	eventsFlags.Var(&labelArgs, "label", "Only show events with this label, key=value (repeatable)")
+	where := eventsFlags.String("where", "", "Only show events matching this query, e.g. 'risk in (\"high\") and params.amount > 1000'")
+	_ = eventsFlags.Parse(args)
+	labels, err := models.ParseLabels(labelArgs)
+	if err != nil {
+		log.Fatalf("Invalid label filter: %v", err)
+	}
+	var query *vql.Expr
+	if *where != "" {
+		if query, err = vql.CompileQuery(*where); err != nil {
+			log.Fatalf("Invalid query: %v", err)
+		}
+	}
 
 	// Open database
 	db, err := store.NewDB("logryph.db")
@@ -47,7 +65,22 @@ func EventsCommand() {
 	}
 
 	// Get recent events
-	events, err := db.GetRecentEvents(runID, *limit)
+	var events []models.Event
+	switch {
+	case query != nil:
+		events, err = db.GetAllEvents(runID)
+		if err == nil && len(labels) > 0 {
+			events = filterLabels(events, labels)
+		}
+		if err == nil {
+			events = query.Filter(events, *limit)
+			reverseEvents(events) // newest first, as GetRecentEvents returns them
+		}
+	case len(labels) > 0:
+		events, err = db.GetEventsByLabels(runID, labels, *limit)
+	default:
+		events, err = db.GetRecentEvents(runID, *limit)
+	}
 	if err := assert.Check(err == nil, "failed to get events: %v", err); err != nil {
 		log.Fatalf("Failed to get events: %v", err)
 	}
@@ -65,13 +98,55 @@ func EventsCommand() {
 		}
 		e := events[idx]
 		fmt.Printf("[%d] %s | %s | %s\n", e.SeqIndex, e.ID[:8], e.EventType, e.Method)
+		if len(e.Labels) > 0 {
+			fmt.Printf("    labels: %s\n", models.FormatLabels(e.Labels))
+		}
 		if e.WasBlocked {
 			fmt.Print("    BLOCKED\n")
 		}
 	}
 }
 
-func StatsCommand() {
+// filterLabels keeps the events that carry every label in want.
+func filterLabels(events []models.Event, want map[string]string) []models.Event {
+	var kept []models.Event
+	for i := 0; i < len(events); i++ {
+		match := true
+		for k, v := range want {
+			if events[i].Labels[k] != v {
+				match = false
+			}
+		}
+		if match {
+			kept = append(kept, events[i])
+		}
+	}
+	return kept
+}
+
+func reverseEvents(events []models.Event) {
+	for i, j := 0, len(events)-1; i < j; i, j = i+1, j-1 {
+		events[i], events[j] = events[j], events[i]
+	}
+}
+
+func StatsCommand(args []string) {
+	statsFlags := flag.NewFlagSet("stats", flag.ExitOnError)
+	var labelArgs labelFlag
+	statsFlags.Var(&labelArgs, "label", "Only count events with this label, key=value (repeatable)")
+	topMethods := statsFlags.Int("methods", 10, "Busiest methods to list for the run")
+	days := statsFlags.Int("days", 7, "Days of daily totals to list")
+	showCapacity := statsFlags.Bool("capacity", false, "Show the database size, growth rate and projected size instead")
+	window := statsFlags.Int("window", capacity.DefaultWindowDays, "Recent days the growth rate is averaged over (with --capacity)")
+	horizon := statsFlags.Int("horizon", 0, "Days to project ahead (with --capacity); default retention_days from --config, else 90")
+	minHeadroom := statsFlags.Float64("min-headroom", capacity.DefaultMinHeadroom, "Warn when less than this percent of the disk stays free (with --capacity)")
+	configPath := statsFlags.String("config", "logryph-policy.yaml", "Policy file with retention_days (with --capacity)")
+	_ = statsFlags.Parse(args)
+	labels, err := models.ParseLabels(labelArgs)
+	if err != nil {
+		log.Fatalf("Invalid label filter: %v", err)
+	}
+
 	db, err := store.NewDB("logryph.db")
 	if err != nil {
 		log.Fatalf("Failed to open database: %v", err)
@@ -82,6 +157,26 @@ func StatsCommand() {
 		}
 	}()
 
+	if *showCapacity {
+		opts := capacity.Options{WindowDays: *window, HorizonDays: *horizon, MinHeadroom: *minHeadroom}
+		if opts.HorizonDays == 0 {
+			opts.HorizonDays = policyRetentionDays(*configPath)
+		}
+		printCapacity(db, opts)
+		return
+	}
+
+	if len(labels) > 0 {
+		stats, err := db.GetLabelStats(labels)
+		if err != nil {
+			log.Fatalf("Failed to get label stats: %v", err)
+		}
+		fmt.Printf("Label Statistics (%s, all runs)\n", models.FormatLabels(labels))
+		fmt.Println("=======================")
+		printRunStats(stats)
+		return
+	}
+
 	runID, _ := db.GetRunID()
 	if runID == "" {
 		fmt.Println("No runs found")
@@ -97,6 +192,38 @@ func StatsCommand() {
 
 	fmt.Printf("Run Statistics (%s)\n", runID[:8])
 	fmt.Println("=======================")
+	printRunStats(stats)
+	printMethodStats(db, runID, *topMethods)
+
+	if gStats != nil {
+		fmt.Println("\nGlobal Context")
+		fmt.Println("--------------")
+		fmt.Printf("Total Runs:      %d\n", gStats.TotalRuns)
+		fmt.Printf("Total Events:    %d\n", gStats.TotalEvents)
+		fmt.Printf("Critical Alerts: %d\n", gStats.CriticalCount)
+	}
+	printDailyStats(db, *days)
+
+	// Fetch Memory Pool Metrics from API
+	baseURL, client := httpEndpoint(adminAddr(), 2*time.Second)
+	resp, err := client.Get(baseURL + "/api/metrics")
+	if err == nil {
+		defer func() {
+			if err := resp.Body.Close(); err != nil {
+				log.Printf("Failed to close metrics response: %v", err)
+			}
+		}()
+		var m pool.Metrics
+		if err := json.NewDecoder(resp.Body).Decode(&m); err == nil {
+			fmt.Println("\nMemory Infrastructure (Zero-Allocation Pools)")
+			fmt.Println("--------------------------------------------")
+			printPoolMetric("Event Pool", m.EventHits, m.EventMisses)
+			printPoolMetric("Buffer Pool", m.BufferHits, m.BufferMisses)
+		}
+	}
+}
+
+func printRunStats(stats *ledger.RunStats) {
 	fmt.Printf("Total Events:    %d\n", stats.TotalEvents)
 	fmt.Printf("Tool Calls:      %d\n", stats.CallCount)
 	fmt.Printf("Blocked Calls:   %d\n", stats.BlockedCount)
@@ -124,34 +251,103 @@ func StatsCommand() {
 			delete(stats.RiskBreakdown, key)
 		}
 	}
+}
 
-	if gStats != nil {
-		fmt.Println("\nGlobal Context")
-		fmt.Println("--------------")
-		fmt.Printf("Total Runs:      %d\n", gStats.TotalRuns)
-		fmt.Printf("Total Events:    %d\n", gStats.TotalEvents)
-		fmt.Printf("Critical Alerts: %d\n", gStats.CriticalCount)
+func printMethodStats(db *store.DB, runID string, limit int) {
+	if limit <= 0 {
+		return
+	}
+	methods, err := db.GetMethodStats(runID, limit)
+	if err != nil {
+		log.Printf("Failed to get method stats: %v", err)
+		return
+	}
+	fmt.Println("\nBusiest Methods:")
+	if len(methods) == 0 {
+		fmt.Println("  None")
 	}
+	for _, m := range methods {
+		fmt.Printf("  %-32s %6d events  %6d calls  %6d blocked\n", m.Method, m.Events, m.Calls, m.Blocked)
+	}
+}
 
-	// Fetch Memory Pool Metrics from API
-	client := http.Client{Timeout: 2 * time.Second}
-	resp, err := client.Get("http://localhost:9998/api/metrics")
-	if err == nil {
-		defer func() {
-			if err := resp.Body.Close(); err != nil {
-				log.Printf("Failed to close metrics response: %v", err)
-			}
-		}()
-		var m pool.Metrics
-		if err := json.NewDecoder(resp.Body).Decode(&m); err == nil {
-			fmt.Println("\nMemory Infrastructure (Zero-Allocation Pools)")
-			fmt.Println("--------------------------------------------")
-			printPoolMetric("Event Pool", m.EventHits, m.EventMisses)
-			printPoolMetric("Buffer Pool", m.BufferHits, m.BufferMisses)
-		}
+func printDailyStats(db *store.DB, days int) {
+	if days <= 0 {
+		return
+	}
+	daily, err := db.GetDailyStats(days)
+	if err != nil {
+		log.Printf("Failed to get daily stats: %v", err)
+		return
+	}
+	if len(daily) == 0 {
+		return
+	}
+	fmt.Println("\nDaily Totals (all runs)")
+	fmt.Println("-----------------------")
+	for _, d := range daily {
+		fmt.Printf("  %s  %6d events  %6d calls  %6d blocked  %6d critical\n", d.Day, d.Events, d.Calls, d.Blocked, d.Critical)
 	}
 }
 
+// printCapacity prints the ledger's size and growth forecast, and exits non-zero when
+// the disk is running out of headroom.
+func printCapacity(db *store.DB, opts capacity.Options) {
+	if err := opts.Validate(); err != nil {
+		log.Fatalf("Invalid capacity options: %v", err)
+	}
+	f, err := capacity.Measure("logryph.db", db, opts, time.Now())
+	if err != nil {
+		log.Fatalf("Failed to measure the ledger: %v", err)
+	}
+	fmt.Println("Ledger Capacity")
+	fmt.Println("===============")
+	fmt.Printf("Database Size:   %s (%d events, %.0f bytes/event)\n", formatSize(float64(f.DBBytes)), f.Events, f.BytesPerEvent)
+	if f.Events == 0 {
+		fmt.Println("Growth:          no events recorded yet")
+	} else {
+		fmt.Printf("Growth:          %.0f events/day, %s/day (last %d days)\n", f.EventsPerDay, formatSize(f.BytesPerDay), f.WindowDays)
+	}
+	fmt.Printf("Projected Size:  %s in %d days\n", formatSize(float64(f.ProjectedBytes)), f.HorizonDays)
+	if !f.Disk.Known {
+		fmt.Println("Disk:            unknown on this platform")
+		return
+	}
+	fmt.Printf("Disk Free:       %s of %s (%.1f%%)\n", formatSize(float64(f.Disk.Free)), formatSize(float64(f.Disk.Total)), f.HeadroomPercent())
+	if !math.IsInf(f.DaysUntilFull, 1) {
+		fmt.Printf("Disk Full In:    %.0f days at the current rate\n", f.DaysUntilFull)
+	}
+	for i := 0; i < len(f.Warnings); i++ {
+		fmt.Printf("[WARN] %s\n", f.Warnings[i])
+	}
+	if len(f.Warnings) > 0 {
+		os.Exit(1)
+	}
+}
+
+// policyRetentionDays reads retention_days from the policy file, or returns the default
+// horizon when the file cannot be loaded or sets none.
+func policyRetentionDays(configPath string) int {
+	obsEngine, err := observer.NewObserverEngine(configPath)
+	if err != nil || obsEngine.GetConfig().Defaults.RetentionDays <= 0 {
+		return capacity.DefaultHorizonDays
+	}
+	return obsEngine.GetConfig().Defaults.RetentionDays
+}
+
+// formatSize writes a byte count with a binary unit.
+func formatSize(n float64) string {
+	units := []string{"B", "KiB", "MiB", "GiB", "TiB", "PiB"}
+	i := 0
+	for ; n >= 1024 && i < len(units)-1; i++ {
+		n /= 1024
+	}
+	if i == 0 {
+		return fmt.Sprintf("%.0f %s", n, units[i])
+	}
+	return fmt.Sprintf("%.1f %s", n, units[i])
+}
+
 func printPoolMetric(name string, hits, misses uint64) {
 	total := hits + misses
 	rate := 0.0
@@ -161,7 +357,22 @@ func printPoolMetric(name string, hits, misses uint64) {
 	fmt.Printf("%-12s | Hits: %-5d | Misses: %-5d | Efficiency: %.1f%%\n", name, hits, misses, rate)
 }
 
-func RiskCommand() {
+// RiskCommand lists risk events across runs, newest first, a page at a time:
+//
+//	logyctl risk [--level high] [--since 24h] [--limit 100] [--cursor id]
+func RiskCommand(args []string) {
+	fs := flag.NewFlagSet("risk", flag.ExitOnError)
+	level := fs.String("level", "high", "Lowest risk level listed: low, medium, high or critical")
+	since := fs.String("since", "", "Only events newer than this duration (24h) or RFC 3339 time")
+	limit := fs.String("limit", "100", "Events per page (max 1000)")
+	cursor := fs.String("cursor", "", "Continue from the cursor printed after the previous page")
+	_ = fs.Parse(args)
+	q, err := api.ParseRiskQuery(*level, *since, *limit, time.Now())
+	if err != nil {
+		log.Fatalf("Invalid risk query: %v", err)
+	}
+	q.After = *cursor
+
 	db, err := store.NewDB("logryph.db")
 	if err != nil {
 		log.Fatalf("Failed to open database: %v", err)
@@ -172,30 +383,55 @@ func RiskCommand() {
 		}
 	}()
 
-	risky, err := db.GetRiskEvents()
+	risky, next, err := db.GetRiskEventsPage(q)
 	if err := assert.Check(err == nil, "failed to get risky events: %v", err); err != nil {
 		log.Fatalf("Failed to get risky events: %v", err)
 	}
 
 	if len(risky) == 0 {
-		fmt.Println("[OK] No high-risk events detected")
+		if *cursor == "" {
+			fmt.Printf("[OK] No events at %s risk or above\n", *level)
+		}
 		return
 	}
-	const maxRiskEvents = 10000
-	if err := assert.Check(len(risky) <= maxRiskEvents, "risky events exceed max: %d", len(risky)); err != nil {
-		log.Fatalf("Risky events exceed max: %v", err)
-	}
 
-	fmt.Printf("High-Risk Events Found: %d\n", len(risky))
+	fmt.Printf("Risk Events (%s and above): %d\n", *level, len(risky))
 	fmt.Println("==========================")
-	for i := 0; i < maxRiskEvents; i++ {
-		if i >= len(risky) {
-			break
-		}
+	for i := 0; i < len(risky); i++ {
 		e := risky[i]
-		fmt.Printf("[%s] %-8s | %-10s | %s\n", e.RiskLevel, e.ID[:8], e.EventType, e.Method)
+		fmt.Printf("[%s] %-8s | %s | %-10s | %s\n", e.RiskLevel, e.ID[:min(8, len(e.ID))], e.Timestamp.Format("2006-01-02 15:04:05"), e.EventType, e.Method)
 		if e.PolicyID != "" {
 			fmt.Printf("    Policy: %s\n", e.PolicyID)
 		}
 	}
+	if next != "" {
+		fmt.Printf("More events: add --cursor %s\n", next)
+	}
+}
+
+// LabelsCommand lists label values and how many events carry each: logyctl labels
+func LabelsCommand() {
+	db, err := store.NewDB("logryph.db")
+	if err != nil {
+		log.Fatalf("Failed to open database: %v", err)
+	}
+	defer func() {
+		if err := db.Close(); err != nil {
+			log.Printf("Failed to close database: %v", err)
+		}
+	}()
+
+	counts, err := db.GetLabelCounts()
+	if err != nil {
+		log.Fatalf("Failed to get labels: %v", err)
+	}
+	if len(counts) == 0 {
+		fmt.Println("No labeled events")
+		return
+	}
+	const maxLabelRows = 10000
+	fmt.Printf("%-40s %s\n", "LABEL", "EVENTS")
+	for i := 0; i < len(counts) && i < maxLabelRows; i++ {
+		fmt.Printf("%-40s %d\n", counts[i].Key+"="+counts[i].Value, counts[i].Events)
+	}
 }
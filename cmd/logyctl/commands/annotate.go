@@ -0,0 +1,110 @@
+package commands
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/slyt3/Logryph/internal/api"
+	"github.com/slyt3/Logryph/internal/ledger/store"
+	"github.com/slyt3/Logryph/internal/models"
+)
+
+// AnnotateCommand records or lists investigator notes on an event:
+//
+//	logyctl annotate <event-id> -m "confirmed false positive" [--label k=v] [--as name]
+//	logyctl annotate <event-id>
+//
+// Notes are submitted through the admin API so the running proxy signs and chains them.
+func AnnotateCommand(args []string) {
+	if len(args) < 1 || strings.HasPrefix(args[0], "-") {
+		fmt.Println("Usage: logyctl annotate <event-id> [-m note] [--label key=value] [--as name]")
+		os.Exit(1)
+	}
+	eventID := args[0]
+
+	fs := flag.NewFlagSet("annotate", flag.ExitOnError)
+	note := fs.String("m", "", "Annotation text; without it, existing annotations are listed")
+	author := fs.String("as", "", "Author recorded in the ledger (default: admin-api)")
+	var labelArgs labelFlag
+	fs.Var(&labelArgs, "label", "Label to set on the event, key=value (repeatable)")
+	if err := fs.Parse(args[1:]); err != nil {
+		log.Fatalf("Failed to parse flags: %v", err)
+	}
+	labels, err := models.ParseLabels(labelArgs)
+	if err != nil {
+		log.Fatalf("Invalid label: %v", err)
+	}
+
+	if *note == "" && len(labels) == 0 {
+		listAnnotations(eventID)
+		return
+	}
+
+	ack, err := submitAnnotation(api.AnnotationRequest{EventID: eventID, Note: *note, Author: *author, Labels: labels})
+	if err != nil {
+		log.Fatalf("Failed to annotate event: %v", err)
+	}
+	fmt.Printf("Annotation %s recorded on event %s\n", ack.ID, ack.EventID)
+}
+
+// submitAnnotation posts an annotation to the running proxy, which signs and chains it.
+func submitAnnotation(req api.AnnotationRequest) (*api.AnnotationResponse, error) {
+	payload, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("encoding annotation: %w", err)
+	}
+	status, body, err := adminRequest(http.MethodPost, "/api/annotations", nil, payload)
+	if err != nil {
+		return nil, err
+	}
+	if status != http.StatusAccepted {
+		return nil, fmt.Errorf("HTTP %d: %s", status, strings.TrimSpace(string(body)))
+	}
+	var ack api.AnnotationResponse
+	if err := json.Unmarshal(body, &ack); err != nil {
+		return nil, fmt.Errorf("parsing response: %w", err)
+	}
+	return &ack, nil
+}
+
+func listAnnotations(eventID string) {
+	db, err := store.NewDB("logryph.db")
+	if err != nil {
+		log.Fatalf("Failed to open database: %v", err)
+	}
+	defer func() {
+		if err := db.Close(); err != nil {
+			log.Printf("Failed to close database: %v", err)
+		}
+	}()
+
+	annotations, err := db.GetAnnotations(eventID)
+	if err != nil {
+		log.Fatalf("Failed to get annotations: %v", err)
+	}
+	if len(annotations) == 0 {
+		fmt.Printf("No annotations on event %s\n", eventID)
+		return
+	}
+
+	const maxAnnotationLines = 10000
+	for i := 0; i < len(annotations) && i < maxAnnotationLines; i++ {
+		a := annotations[i]
+		fmt.Printf("[%s] %s (%s): %s\n", a.CreatedAt.Format("2006-01-02 15:04:05"), a.Author, a.ID, a.Note)
+	}
+}
+
+// labelFlag collects repeated --label key=value flags.
+type labelFlag []string
+
+func (l *labelFlag) String() string { return strings.Join(*l, ",") }
+
+func (l *labelFlag) Set(v string) error {
+	*l = append(*l, v)
+	return nil
+}
@@ -0,0 +1,265 @@
+package commands
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/slyt3/Logryph/internal/api"
+	"github.com/slyt3/Logryph/internal/approval"
+	"github.com/slyt3/Logryph/internal/crypto"
+	"github.com/slyt3/Logryph/internal/secretref"
+)
+
+// PendingCommand lists calls currently stalled for approval in enforce mode, oldest
+// first, and warns about those about to time out. With --by-task they are grouped by
+// task, so a task's stalls can be decided together with approve --task.
+func PendingCommand(args []string) {
+	fs := flag.NewFlagSet("pending", flag.ExitOnError)
+	byTask := fs.Bool("by-task", false, "Group stalled calls by task")
+	_ = fs.Parse(args)
+
+	path := "/api/pending"
+	if *byTask {
+		path += "?group=task"
+	}
+	status, body, err := adminRequest(http.MethodGet, path, nil, nil)
+	if err != nil {
+		log.Fatalf("Failed to list pending approvals: %v", err)
+	}
+	if status != http.StatusOK {
+		fmt.Printf("Error (%d): %s\n", status, string(body))
+		os.Exit(1)
+	}
+
+	if *byTask {
+		var tasks []api.PendingTask
+		if err := json.Unmarshal(body, &tasks); err != nil {
+			log.Fatalf("Failed to parse response: %v", err)
+		}
+		printPendingByTask(tasks)
+		return
+	}
+	var pending []api.PendingApproval
+	if err := json.Unmarshal(body, &pending); err != nil {
+		log.Fatalf("Failed to parse response: %v", err)
+	}
+	if len(pending) == 0 {
+		fmt.Println("No calls awaiting approval")
+		return
+	}
+
+	fmt.Printf("  %-10s %-30s %-12s %-10s %-8s %s\n", "EVENT", "METHOD", "TASK", "RISK", "AGE", "EXPIRES IN")
+	near := printPendingRows(pending, true)
+	if near > 0 {
+		fmt.Printf("[WARN] %d stalled call(s) marked ! will be refused soon unless decided\n", near)
+	}
+}
+
+const maxPendingRows = 1024
+
+// printPendingRows prints one row per stall and returns how many are near expiry.
+func printPendingRows(pending []api.PendingApproval, withTask bool) int {
+	near := 0
+	for i := 0; i < maxPendingRows; i++ {
+		if i >= len(pending) {
+			break
+		}
+		p := pending[i]
+		mark := " "
+		if p.NearExpiry {
+			mark = "!"
+			near++
+		}
+		age := (time.Duration(p.AgeSeconds) * time.Second).Round(time.Second)
+		left := (time.Duration(p.ExpiresInSeconds) * time.Second).Round(time.Second)
+		if withTask {
+			fmt.Printf("%s %-10s %-30s %-12s %-10s %-8s %s\n", mark, p.EventID, p.Method, p.TaskID, p.RiskLevel, age, left)
+		} else {
+			fmt.Printf("%s   %-10s %-30s %-10s %-8s %s\n", mark, p.EventID, p.Method, p.RiskLevel, age, left)
+		}
+	}
+	return near
+}
+
+// printPendingByTask prints each task's stalls under a task header, tasks with the
+// longest-waiting stall first.
+func printPendingByTask(tasks []api.PendingTask) {
+	if len(tasks) == 0 {
+		fmt.Println("No calls awaiting approval")
+		return
+	}
+	near := 0
+	fmt.Printf("    %-10s %-30s %-10s %-8s %s\n", "EVENT", "METHOD", "RISK", "AGE", "EXPIRES IN")
+	for i := 0; i < len(tasks) && i < maxPendingRows; i++ {
+		task := tasks[i].TaskID
+		if task == "" {
+			task = "(no task)"
+		}
+		fmt.Printf("Task %s: %d stalled call(s)\n", task, len(tasks[i].Stalls))
+		near += printPendingRows(tasks[i].Stalls, false)
+	}
+	if near > 0 {
+		fmt.Printf("[WARN] %d stalled call(s) marked ! will be refused soon unless decided\n", near)
+	}
+	fmt.Println("Decide a task's stalls together with: logyctl approve --task <task-id>")
+}
+
+// ApproveCommand releases a stalled call: logyctl approve <event-id> [--as name]
+// With --offline it writes a signed token to carry to an air-gapped proxy instead.
+func ApproveCommand(args []string) {
+	decideCommand("approve", approval.DecisionApproved, args)
+}
+
+// RejectCommand refuses a stalled call: logyctl reject <event-id> [--as name]
+func RejectCommand(args []string) {
+	decideCommand("reject", approval.DecisionRejected, args)
+}
+
+func decideCommand(action string, decision approval.Decision, args []string) {
+	fs := flag.NewFlagSet(action, flag.ExitOnError)
+	approver := fs.String("as", "", "Approver name recorded in the ledger (default: admin-api)")
+	offline := fs.Bool("offline", false, "Write a signed token for the proxy's offline_approvals dir instead of calling the API")
+	keyPath := fs.String("key", "approver.key", "Approver signing key for --offline (created if missing)")
+	outDir := fs.String("out", ".", "Directory the --offline token is written to")
+	ttl := fs.Duration("valid", time.Hour, "How long the --offline token may be applied")
+	all := fs.Bool("all", false, "Decide every pending call matching --policy, --task and --method")
+	policyID := fs.String("policy", "", "With --all, only calls stalled by this rule")
+	taskID := fs.String("task", "", "Decide every call of this task stalled now, together (with --all, a filter)")
+	method := fs.String("method", "", "With --all, only calls of this method")
+	// Flags may come before or after the event IDs.
+	eventIDs := parseInterspersed(fs, args)
+	wholeTask := *taskID != "" && !*all && len(eventIDs) == 0 && !*offline
+	if !wholeTask && ((len(eventIDs) == 0) == !*all || (*offline && len(eventIDs) != 1)) {
+		fmt.Printf("Usage: logyctl %s <event-id>... [--as name] [--offline [--key file] [--out dir] [--valid 1h]]\n", action)
+		fmt.Printf("       logyctl %s --task id [--as name]\n", action)
+		fmt.Printf("       logyctl %s --all [--policy id] [--task id] [--method m] [--as name]\n", action)
+		os.Exit(1)
+	}
+	if *offline {
+		writeOfflineToken(eventIDs[0], decision, *approver, *keyPath, *outDir, *ttl)
+		return
+	}
+
+	header := map[string]string{}
+	if *approver != "" {
+		header["X-Logryph-Approver"] = *approver
+	}
+	if wholeTask {
+		decideTask(action, header, *taskID)
+		return
+	}
+	if *all || len(eventIDs) > 1 {
+		decideBatch(action, header, api.BatchDecisionRequest{EventIDs: eventIDs, All: *all, PolicyID: *policyID, TaskID: *taskID, Method: *method})
+		return
+	}
+	path := fmt.Sprintf("/api/%s?event_id=%s", action, url.QueryEscape(eventIDs[0]))
+	status, body, err := adminRequest(http.MethodPost, path, header, nil)
+	if err != nil {
+		log.Fatalf("Failed to %s event: %v", action, err)
+	}
+	if status != http.StatusOK {
+		fmt.Printf("Error (%d): %s", status, string(body))
+		os.Exit(1)
+	}
+	fmt.Print(string(body))
+}
+
+// decideTask decides every call of a task stalled now in one request, which the proxy
+// records as a single grouped decision. Like a batch, it is sent with the bulk approval
+// token when LOGRYPH_BULK_APPROVAL_TOKEN is set.
+func decideTask(action string, header map[string]string, taskID string) {
+	setBulkToken(header)
+	path := fmt.Sprintf("/api/%s/task?task_id=%s", action, url.QueryEscape(taskID))
+	status, body, err := adminRequest(http.MethodPost, path, header, nil)
+	if err != nil {
+		log.Fatalf("Failed to %s task: %v", action, err)
+	}
+	if status != http.StatusOK {
+		fmt.Printf("Error (%d): %s", status, string(body))
+		os.Exit(1)
+	}
+	var resp api.TaskDecisionResponse
+	if err := json.Unmarshal(body, &resp); err != nil {
+		log.Fatalf("Failed to parse response: %v", err)
+	}
+	for i := 0; i < len(resp.EventIDs); i++ {
+		fmt.Printf("  %-10s %s\n", resp.EventIDs[i], resp.Decision)
+	}
+	fmt.Printf("%d call(s) of task %s %s by %s (group %s)\n", len(resp.EventIDs), resp.TaskID, resp.Decision, resp.Approver, resp.GroupID)
+}
+
+// decideBatch sends one batch decision and prints the result for each call. With
+// LOGRYPH_BULK_APPROVAL_TOKEN set, it is sent in place of the admin token.
+func decideBatch(action string, header map[string]string, req api.BatchDecisionRequest) {
+	setBulkToken(header)
+	payload, err := json.Marshal(req)
+	if err != nil {
+		log.Fatalf("Failed to encode batch: %v", err)
+	}
+	status, body, err := adminRequest(http.MethodPost, "/api/"+action+"/batch", header, payload)
+	if err != nil {
+		log.Fatalf("Failed to %s events: %v", action, err)
+	}
+	if status != http.StatusOK {
+		fmt.Printf("Error (%d): %s", status, string(body))
+		os.Exit(1)
+	}
+	var resp api.BatchDecisionResponse
+	if err := json.Unmarshal(body, &resp); err != nil {
+		log.Fatalf("Failed to parse response: %v", err)
+	}
+	for i := 0; i < len(resp.Results); i++ {
+		r := resp.Results[i]
+		if r.Error != "" {
+			fmt.Printf("  %-10s %s: %s\n", r.EventID, r.Status, r.Error)
+		} else {
+			fmt.Printf("  %-10s %s\n", r.EventID, r.Status)
+		}
+	}
+	fmt.Printf("%d of %d calls %s by %s\n", resp.Decided, len(resp.Results), resp.Decision, resp.Approver)
+	if resp.Decided < len(resp.Results) {
+		os.Exit(1)
+	}
+}
+
+// setBulkToken sends LOGRYPH_BULK_APPROVAL_TOKEN, when set, in place of the admin token.
+func setBulkToken(header map[string]string) {
+	token, err := secretref.Getenv(api.BulkApprovalTokenEnv)
+	if err != nil {
+		log.Fatalf("Failed to read the bulk approval token: %v", err)
+	}
+	if token != "" {
+		header["X-Admin-Token"] = token
+	}
+}
+
+// writeOfflineToken signs a decision with the approver's own key, which must be listed
+// under offline_approvals.approvers in the proxy's policy file.
+func writeOfflineToken(eventID string, decision approval.Decision, approver, keyPath, outDir string, ttl time.Duration) {
+	signer, err := crypto.NewSigner(keyPath)
+	if err != nil {
+		log.Fatalf("Failed to load approver key: %v", err)
+	}
+	if approver == "" {
+		approver = os.Getenv("USER")
+	}
+	if approver == "" {
+		approver = "offline"
+	}
+	token, err := approval.NewToken(eventID, decision, approver, ttl, signer)
+	if err != nil {
+		log.Fatalf("Failed to create token: %v", err)
+	}
+	path, err := approval.WriteToken(outDir, token)
+	if err != nil {
+		log.Fatalf("Failed to write token: %v", err)
+	}
+	fmt.Printf("Wrote %s (%s, valid until %s)\n", path, decision, token.ExpiresAt.Format(time.RFC3339))
+	fmt.Printf("Signed with approver key %s\n", token.SignerPubKey)
+}
@@ -0,0 +1,43 @@
+package commands
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/slyt3/Logryph/internal/attachments"
+)
+
+// AttachmentCommand writes a stored payload blob after checking it against its hash:
+// logyctl attachment <sha256> [--out file] [--dir attachments]
+func AttachmentCommand(args []string) {
+	if len(args) < 1 {
+		fmt.Println("Usage: logyctl attachment <sha256> [--out file] [--dir attachments]")
+		os.Exit(1)
+	}
+	sum := args[0]
+	fs := flag.NewFlagSet("attachment", flag.ExitOnError)
+	out := fs.String("out", "", "Write the blob to this file instead of stdout")
+	dir := fs.String("dir", "attachments", "Attachment store directory")
+	_ = fs.Parse(args[1:])
+
+	s, err := attachments.NewStore(*dir)
+	if err != nil {
+		log.Fatalf("Failed to open attachment store: %v", err)
+	}
+	data, err := s.Open(sum)
+	if err != nil {
+		log.Fatalf("Failed to read attachment: %v", err)
+	}
+	if *out == "" {
+		if _, err := os.Stdout.Write(data); err != nil {
+			log.Fatalf("Failed to write attachment: %v", err)
+		}
+		return
+	}
+	if err := os.WriteFile(*out, data, 0o600); err != nil {
+		log.Fatalf("Failed to write attachment: %v", err)
+	}
+	fmt.Printf("[OK] %d bytes, sha256 %s verified, written to %s\n", len(data), sum, *out)
+}
@@ -0,0 +1,106 @@
+package commands
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/slyt3/Logryph/internal/attest"
+	"github.com/slyt3/Logryph/internal/ledger/store"
+)
+
+// AttestCommand checks signed statements issued by the ledger:
+// logyctl attest verify <export> <attestation> [--pubkey hex]
+// logyctl attest receipt <receipt.json> [--pubkey hex] [--ledger logryph.db]
+func AttestCommand(args []string) {
+	if len(args) >= 2 && args[0] == "receipt" {
+		verifyReceipt(args[1:])
+		return
+	}
+	if len(args) < 3 || args[0] != "verify" {
+		fmt.Println("Usage: logyctl attest verify <export> <attestation> [--pubkey hex]")
+		fmt.Println("       logyctl attest receipt <receipt.json> [--pubkey hex] [--ledger logryph.db]")
+		os.Exit(1)
+	}
+	exportPath, attestPath := args[1], args[2]
+	fs := flag.NewFlagSet("attest verify", flag.ExitOnError)
+	pubKey := fs.String("pubkey", "", "Require this signer public key (hex)")
+	_ = fs.Parse(args[3:])
+
+	a, err := attest.Read(attestPath)
+	if err != nil {
+		log.Fatalf("Failed to load attestation: %v", err)
+	}
+	if err := attest.Verify(exportPath, a, *pubKey); err != nil {
+		fmt.Print("[FAILED] Export does not match its attestation\n")
+		fmt.Printf("  Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("[OK] %s matches its attestation (sha256 %s)\n", exportPath, a.ExportSHA256)
+	fmt.Printf("  Run:        %s\n", a.RunID)
+	fmt.Printf("  Chain head: %s (seq %d)\n", a.ChainHead, a.ChainSeq)
+	fmt.Printf("  Exported:   %s by Logryph %s\n", a.Timestamp.Format("2006-01-02 15:04:05 MST"), a.LogryphVersion)
+	fmt.Printf("  Signer:     %s\n", a.SignerPubKey)
+	if a.TSA != nil {
+		fmt.Printf("  TSA stamp:  %s from %s (serial %s)\n", a.TSA.GenTime.Format("2006-01-02 15:04:05 MST"), a.TSA.URL, a.TSA.Serial)
+	}
+	if *pubKey == "" {
+		fmt.Println("[WARN] Signer key not pinned; compare it with the ledger's published key or pass --pubkey")
+	}
+}
+
+// verifyReceipt checks a receipt from GET /api/receipt/{event-id} and, with --ledger,
+// that the ledger still holds the event it names.
+func verifyReceipt(args []string) {
+	fs := flag.NewFlagSet("attest receipt", flag.ExitOnError)
+	pubKey := fs.String("pubkey", "", "Require this signer public key (hex)")
+	ledgerPath := fs.String("ledger", "", "Also check the event against this ledger")
+	_ = fs.Parse(args[1:])
+
+	data, err := os.ReadFile(args[0])
+	if err != nil {
+		log.Fatalf("Failed to read receipt: %v", err)
+	}
+	var r attest.Receipt
+	if err := json.Unmarshal(data, &r); err != nil {
+		log.Fatalf("Failed to parse receipt: %v", err)
+	}
+	if err := attest.VerifyReceipt(&r, *pubKey); err != nil {
+		fmt.Print("[FAILED] Receipt signature is not valid\n")
+		fmt.Printf("  Error: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("[OK] Receipt for event %s is signed by the ledger key\n", r.EventID)
+	fmt.Printf("  Run:        %s\n", r.RunID)
+	fmt.Printf("  Event:      %s (seq %d)\n", r.EventHash, r.SeqIndex)
+	fmt.Printf("  Chain head: %s (seq %d)\n", r.ChainHead, r.ChainSeq)
+	fmt.Printf("  Issued:     %s\n", r.IssuedAt.Format("2006-01-02 15:04:05 MST"))
+	fmt.Printf("  Signer:     %s\n", r.SignerPubKey)
+	if *ledgerPath != "" {
+		checkReceiptInLedger(&r, *ledgerPath)
+	}
+	if *pubKey == "" {
+		fmt.Println("[WARN] Signer key not pinned; compare it with the ledger's published key or pass --pubkey")
+	}
+}
+
+func checkReceiptInLedger(r *attest.Receipt, path string) {
+	db, err := store.NewDB(path)
+	if err != nil {
+		log.Fatalf("Failed to open ledger: %v", err)
+	}
+	defer func() { _ = db.Close() }()
+	event, err := db.GetEventByID(r.EventID)
+	if err != nil {
+		fmt.Printf("[FAILED] Event %s not found in %s: %v\n", r.EventID, path, err)
+		os.Exit(1)
+	}
+	if event.RunID != r.RunID || event.SeqIndex != r.SeqIndex || event.CurrentHash != r.EventHash {
+		fmt.Printf("[FAILED] Ledger holds event %s as %s (run %s, seq %d), not as receipted\n", event.ID, event.CurrentHash, event.RunID, event.SeqIndex)
+		os.Exit(1)
+	}
+	fmt.Printf("[OK] %s holds the event as receipted\n", path)
+}
@@ -0,0 +1,58 @@
+package commands
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/slyt3/Logryph/internal/backup"
+	"github.com/slyt3/Logryph/internal/ledger/store"
+)
+
+// BackupCommand writes a consistent copy of the ledger and a manifest into a new
+// directory: logyctl backup --out dir [--keep N]
+func BackupCommand(args []string) {
+	fs := flag.NewFlagSet("backup", flag.ExitOnError)
+	outDir := fs.String("out", "", "Directory the backup is written to (required)")
+	keep := fs.Int("keep", 0, "Keep only the newest N backups in --out (0 keeps all)")
+	_ = fs.Parse(args)
+	if *outDir == "" || *keep < 0 {
+		fmt.Println("Usage: logyctl backup --out <dir> [--keep N]")
+		os.Exit(1)
+	}
+	if _, err := os.Stat("logryph.db"); err != nil {
+		log.Fatalf("No ledger to back up: %v", err)
+	}
+
+	db, err := store.NewDB("logryph.db")
+	if err != nil {
+		log.Fatalf("Failed to open database: %v", err)
+	}
+	defer func() {
+		if err := db.Close(); err != nil {
+			log.Printf("Failed to close database: %v", err)
+		}
+	}()
+
+	path, manifest, err := backup.Create(db, *outDir, ".")
+	if err != nil {
+		log.Fatalf("Backup failed: %v", err)
+	}
+	fmt.Printf("[OK] Ledger backed up to %s (%d bytes, run %s, seq %d)\n", path, manifest.Size, manifest.RunID, manifest.LastSeq)
+	if manifest.KeyBackup != nil {
+		fmt.Printf("     Signing key backup referenced: %s\n", manifest.KeyBackup.File)
+	} else {
+		fmt.Println("[WARN] No signing key backup found; run 'logyctl backup-key' and store it offline")
+	}
+	if *keep == 0 {
+		return
+	}
+	removed, err := backup.Prune(*outDir, *keep)
+	if err != nil {
+		log.Fatalf("Pruning old backups failed: %v", err)
+	}
+	for i := 0; i < len(removed); i++ {
+		fmt.Printf("     Removed old backup %s\n", removed[i])
+	}
+}
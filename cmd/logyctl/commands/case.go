@@ -0,0 +1,325 @@
+package commands
+
+import (
+	"archive/zip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/slyt3/Logryph/internal/assert"
+	"github.com/slyt3/Logryph/internal/ledger/store"
+	"github.com/slyt3/Logryph/internal/models"
+)
+
+const (
+	maxCaseEvents = 100000
+	maxCaseArgs   = 16
+)
+
+// CaseManifest describes a case evidence package. Every exported event keeps its own
+// hash and signature; Runs carries the public keys needed to check them offline, and
+// Files the SHA-256 of every other file in the package.
+type CaseManifest struct {
+	Version    string            `json:"version"`
+	Case       models.Case       `json:"case"`
+	ExportTime time.Time         `json:"export_time"`
+	Items      []models.CaseItem `json:"items"`
+	Runs       []CaseRun         `json:"runs"`
+	EventCount int               `json:"event_count"`
+	Files      map[string]string `json:"files"` // file name -> sha256
+}
+
+// CaseRun identifies a run that contributed events to a case.
+type CaseRun struct {
+	RunID       string `json:"run_id"`
+	Agent       string `json:"agent"`
+	GenesisHash string `json:"genesis_hash"`
+	PubKey      string `json:"ledger_pub_key"`
+}
+
+// CaseCommand manages investigations:
+//
+//	logyctl case create <name>
+//	logyctl case add <case> <event-id|task-id> [--kind event|task]
+//	logyctl case list
+//	logyctl case show <case>
+//	logyctl case export <case> <file.zip>
+func CaseCommand(args []string) {
+	if len(args) < 1 {
+		printCaseUsage()
+		os.Exit(1)
+	}
+	fs := flag.NewFlagSet("case", flag.ExitOnError)
+	kind := fs.String("kind", "", "Item kind for add: event or task (default: detect)")
+	sub := args[0]
+	args = parseInterspersed(fs, args[1:])
+
+	db, err := store.NewDB("logryph.db")
+	if err != nil {
+		log.Fatalf("Failed to open database: %v", err)
+	}
+	defer func() {
+		if err := db.Close(); err != nil {
+			log.Printf("Failed to close database: %v", err)
+		}
+	}()
+
+	switch {
+	case sub == "create" && len(args) == 1:
+		c := models.Case{ID: uuid.New().String()[:8], Name: args[0], CreatedAt: time.Now()}
+		if err := db.CreateCase(c); err != nil {
+			log.Fatalf("Failed to create case: %v", err)
+		}
+		fmt.Printf("[OK] Case %q created (%s)\n", c.Name, c.ID)
+	case sub == "add" && len(args) == 2:
+		addCaseItem(db, args[0], args[1], *kind)
+	case sub == "list" && len(args) == 0:
+		listCases(db)
+	case sub == "show" && len(args) == 1:
+		showCase(db, args[0])
+	case sub == "export" && len(args) == 2:
+		c := mustGetCase(db, args[0])
+		if err := ExportCaseBundle(db, c, args[1]); err != nil {
+			log.Fatalf("Case export failed: %v", err)
+		}
+		fmt.Printf("[OK] Case package created: %s\n", args[1])
+	default:
+		printCaseUsage()
+		os.Exit(1)
+	}
+}
+
+func printCaseUsage() {
+	fmt.Println("Usage:")
+	fmt.Println("  logyctl case create <name>")
+	fmt.Println("  logyctl case add <case> <event-id|task-id> [--kind event|task]")
+	fmt.Println("  logyctl case list")
+	fmt.Println("  logyctl case show <case>")
+	fmt.Println("  logyctl case export <case> <file.zip>")
+}
+
+// parseInterspersed parses flags that may appear before, between or after positional
+// arguments and returns the positionals.
+func parseInterspersed(fs *flag.FlagSet, args []string) []string {
+	var positional []string
+	for i := 0; i < maxCaseArgs; i++ {
+		if err := fs.Parse(args); err != nil {
+			log.Fatalf("Failed to parse flags: %v", err)
+		}
+		args = fs.Args()
+		if len(args) == 0 {
+			break
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+	return positional
+}
+
+func mustGetCase(db *store.DB, name string) *models.Case {
+	c, err := db.GetCase(name)
+	if errors.Is(err, store.ErrCaseNotFound) {
+		fmt.Printf("No case named %q\n", name)
+		os.Exit(1)
+	}
+	if err != nil {
+		log.Fatalf("Failed to get case: %v", err)
+	}
+	return c
+}
+
+// addCaseItem attaches ref to the case. Without an explicit kind, ref is treated as an
+// event ID if such an event exists, otherwise as a task ID with recorded events.
+func addCaseItem(db *store.DB, caseName, ref, kind string) {
+	c := mustGetCase(db, caseName)
+	if kind == "" {
+		kind = detectCaseItemKind(db, ref)
+	}
+	if kind != models.CaseItemEvent && kind != models.CaseItemTask {
+		fmt.Printf("No event or task %q found (use --kind event|task)\n", ref)
+		os.Exit(1)
+	}
+	item := models.CaseItem{CaseID: c.ID, Kind: kind, Ref: ref, AddedAt: time.Now()}
+	if err := db.AddCaseItem(item); err != nil {
+		log.Fatalf("Failed to add %s to case: %v", kind, err)
+	}
+	fmt.Printf("[OK] Added %s %s to case %q\n", kind, ref, c.Name)
+}
+
+func detectCaseItemKind(db *store.DB, ref string) string {
+	if _, err := db.GetEventByID(ref); err == nil {
+		return models.CaseItemEvent
+	}
+	if events, err := db.GetEventsByTaskID(ref); err == nil && len(events) > 0 {
+		return models.CaseItemTask
+	}
+	return ""
+}
+
+func listCases(db *store.DB) {
+	cases, err := db.ListCases()
+	if err != nil {
+		log.Fatalf("Failed to list cases: %v", err)
+	}
+	if len(cases) == 0 {
+		fmt.Println("No cases. Create one with: logyctl case create <name>")
+		return
+	}
+	fmt.Printf("%-10s %-30s %-6s %s\n", "ID", "NAME", "ITEMS", "CREATED")
+	for i := 0; i < len(cases) && i < maxCaseEvents; i++ {
+		items, err := db.GetCaseItems(cases[i].ID)
+		if err != nil {
+			log.Fatalf("Failed to get case items: %v", err)
+		}
+		fmt.Printf("%-10s %-30s %-6d %s\n", cases[i].ID, cases[i].Name, len(items), cases[i].CreatedAt.Format("2006-01-02 15:04"))
+	}
+}
+
+func showCase(db *store.DB, name string) {
+	c := mustGetCase(db, name)
+	items, err := db.GetCaseItems(c.ID)
+	if err != nil {
+		log.Fatalf("Failed to get case items: %v", err)
+	}
+	fmt.Printf("Case %q (%s), created %s\n", c.Name, c.ID, c.CreatedAt.Format(time.RFC3339))
+	if hold := caseHold(db, c.ID); hold != nil {
+		fmt.Printf("  On legal hold since %s by %s: %s\n", hold.SetAt.Format(time.RFC3339), hold.Principal, hold.Reason)
+	}
+	if len(items) == 0 {
+		fmt.Println("  (no items)")
+	}
+	for i := 0; i < len(items) && i < maxCaseEvents; i++ {
+		fmt.Printf("  %-6s %-12s added %s\n", items[i].Kind, items[i].Ref, items[i].AddedAt.Format("2006-01-02 15:04"))
+	}
+}
+
+// collectCaseEvents gathers the events behind a case's items, deduplicated and ordered by
+// run and sequence. Event items bring their linked child events (detector findings,
+// approvals, annotations); task items bring every event of the task.
+func collectCaseEvents(db *store.DB, items []models.CaseItem) ([]models.Event, error) {
+	seen := make(map[string]bool)
+	var out []models.Event
+	add := func(events []models.Event) {
+		for i := 0; i < len(events) && len(out) < maxCaseEvents; i++ {
+			if !seen[events[i].ID] {
+				seen[events[i].ID] = true
+				out = append(out, events[i])
+			}
+		}
+	}
+	for i := 0; i < len(items) && i < maxCaseEvents; i++ {
+		switch items[i].Kind {
+		case models.CaseItemTask:
+			events, err := db.GetEventsByTaskID(items[i].Ref)
+			if err != nil {
+				return nil, fmt.Errorf("getting task %s: %w", items[i].Ref, err)
+			}
+			add(events)
+		case models.CaseItemEvent:
+			event, err := db.GetEventByID(items[i].Ref)
+			if err != nil {
+				return nil, fmt.Errorf("getting event %s: %w", items[i].Ref, err)
+			}
+			children, err := db.GetEventsByParentID(event.ID)
+			if err != nil {
+				return nil, fmt.Errorf("getting events linked to %s: %w", event.ID, err)
+			}
+			add([]models.Event{*event})
+			add(children)
+		}
+	}
+	sort.SliceStable(out, func(a, b int) bool {
+		if out[a].RunID != out[b].RunID {
+			return out[a].RunID < out[b].RunID
+		}
+		return out[a].SeqIndex < out[b].SeqIndex
+	})
+	return out, nil
+}
+
+// ExportCaseBundle writes a case evidence package: events.json with the case's events and
+// a manifest.json describing the case, the runs involved and the file digests.
+func ExportCaseBundle(db *store.DB, c *models.Case, zipPath string) (err error) {
+	if err := assert.NotNil(c, "case"); err != nil {
+		return err
+	}
+	items, err := db.GetCaseItems(c.ID)
+	if err != nil {
+		return fmt.Errorf("getting case items: %w", err)
+	}
+	events, err := collectCaseEvents(db, items)
+	if err != nil {
+		return err
+	}
+
+	manifest := CaseManifest{
+		Version:    "1.0 (Logryph case)",
+		Case:       *c,
+		ExportTime: time.Now(),
+		Items:      items,
+		EventCount: len(events),
+		Files:      make(map[string]string),
+	}
+	seenRuns := make(map[string]bool)
+	for i := 0; i < len(events); i++ {
+		runID := events[i].RunID
+		if runID == "" || seenRuns[runID] {
+			continue
+		}
+		seenRuns[runID] = true
+		agent, genesisHash, pubKey, err := db.GetRunInfo(runID)
+		if err != nil {
+			return fmt.Errorf("getting run %s: %w", runID, err)
+		}
+		manifest.Runs = append(manifest.Runs, CaseRun{RunID: runID, Agent: agent, GenesisHash: genesisHash, PubKey: pubKey})
+	}
+
+	f, err := os.Create(zipPath)
+	if err != nil {
+		return fmt.Errorf("creating zip file: %w", err)
+	}
+	defer func() {
+		if closeErr := f.Close(); closeErr != nil && err == nil {
+			err = fmt.Errorf("closing zip file: %w", closeErr)
+		}
+	}()
+	w := zip.NewWriter(f)
+	defer func() {
+		if closeErr := w.Close(); closeErr != nil && err == nil {
+			err = fmt.Errorf("closing zip writer: %w", closeErr)
+		}
+	}()
+
+	digest, err := writeZipJSON(w, "events.json", events)
+	if err != nil {
+		return err
+	}
+	manifest.Files["events.json"] = digest
+	_, err = writeZipJSON(w, "manifest.json", manifest)
+	return err
+}
+
+// writeZipJSON writes v as indented JSON into a new zip entry and returns its SHA-256.
+func writeZipJSON(w *zip.Writer, name string, v interface{}) (string, error) {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("encoding %s: %w", name, err)
+	}
+	entry, err := w.Create(name)
+	if err != nil {
+		return "", fmt.Errorf("creating %s: %w", name, err)
+	}
+	if _, err := entry.Write(data); err != nil {
+		return "", fmt.Errorf("writing %s: %w", name, err)
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
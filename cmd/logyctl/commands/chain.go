@@ -0,0 +1,120 @@
+package commands
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+
+	"github.com/slyt3/Logryph/internal/crypto"
+	"github.com/slyt3/Logryph/internal/ledger"
+	"github.com/slyt3/Logryph/internal/ledger/audit"
+	"github.com/slyt3/Logryph/internal/ledger/store"
+)
+
+// ChainCommand inspects and repairs sequence gaps in the ledger:
+//
+//	logyctl chain gaps
+//	logyctl chain repair --reason "..." [--as name] [--force]
+func ChainCommand(args []string) {
+	if len(args) < 1 {
+		printChainUsage()
+		os.Exit(1)
+	}
+	switch args[0] {
+	case "gaps":
+		chainGaps()
+	case "repair":
+		chainRepair(args[1:])
+	default:
+		printChainUsage()
+		os.Exit(1)
+	}
+}
+
+func printChainUsage() {
+	fmt.Println("Usage:")
+	fmt.Println("  logyctl chain gaps                          List missing sequence ranges")
+	fmt.Println("  logyctl chain repair --reason R [--as name] Acknowledge them so writes can continue")
+}
+
+func openChain() (*store.DB, string) {
+	db, err := store.NewDB("logryph.db")
+	if err != nil {
+		log.Fatalf("Failed to open database: %v", err)
+	}
+	runID, err := db.GetRunID()
+	if err != nil {
+		log.Fatalf("Failed to get run ID: %v", err)
+	}
+	if runID == "" {
+		fmt.Println("No runs found in database")
+		os.Exit(1)
+	}
+	return db, runID
+}
+
+func chainGaps() {
+	db, runID := openChain()
+	defer func() {
+		if err := db.Close(); err != nil {
+			log.Printf("Failed to close database: %v", err)
+		}
+	}()
+	events, err := db.GetAllEvents(runID)
+	if err != nil {
+		log.Fatalf("Failed to read events: %v", err)
+	}
+	acked, open := audit.FindGaps(events)
+	if len(acked)+len(open) == 0 {
+		fmt.Printf("[OK] No sequence gaps in run %s\n", runID[:8])
+		return
+	}
+	for i := 0; i < len(acked); i++ {
+		fmt.Printf("  %-20s %d missing, acknowledged\n", acked[i], acked[i].Count())
+	}
+	for i := 0; i < len(open); i++ {
+		fmt.Printf("  %-20s %d missing, NOT acknowledged\n", open[i], open[i].Count())
+	}
+	if len(open) > 0 {
+		fmt.Println("Writes fail until the gaps are acknowledged with 'logyctl chain repair'")
+		os.Exit(1)
+	}
+}
+
+func chainRepair(args []string) {
+	fs := flag.NewFlagSet("chain repair", flag.ExitOnError)
+	reason := fs.String("reason", "", "Why the events are missing, recorded in the ledger (required)")
+	operator := fs.String("as", os.Getenv("USER"), "Operator name recorded in the ledger")
+	force := fs.Bool("force", false, "Repair even though the proxy's admin API answers")
+	_ = fs.Parse(args)
+	if *reason == "" || *operator == "" {
+		fmt.Println("Usage: logyctl chain repair --reason \"...\" [--as name]")
+		os.Exit(1)
+	}
+	if status, _, err := adminRequest(http.MethodGet, "/healthz", nil, nil); err == nil && status == http.StatusOK && !*force {
+		log.Fatalf("The proxy at %s is running; stop it before repairing the chain (or pass --force)", adminAddr())
+	}
+
+	db, runID := openChain()
+	defer func() {
+		if err := db.Close(); err != nil {
+			log.Printf("Failed to close database: %v", err)
+		}
+	}()
+	signer, err := crypto.NewSigner(".logryph_key")
+	if err != nil {
+		log.Fatalf("Failed to load signer: %v", err)
+	}
+	gaps, err := ledger.AcknowledgeGaps(db, signer, runID, *operator, *reason)
+	for i := 0; i < len(gaps); i++ {
+		fmt.Printf("[OK] Acknowledged %s (%d missing) as %s\n", gaps[i], gaps[i].Count(), *operator)
+	}
+	if err != nil {
+		log.Fatalf("Chain repair failed: %v", err)
+	}
+	if len(gaps) == 0 {
+		fmt.Println("[OK] No unacknowledged gaps; nothing to repair")
+	}
+}
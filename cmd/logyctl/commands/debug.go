@@ -0,0 +1,113 @@
+package commands
+
+import (
+	"archive/zip"
+	"crypto/sha256"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"time"
+)
+
+const (
+	maxDebugFileSize  = 64 << 20
+	maxProfileSeconds = 300
+)
+
+// debugItem is one file in a diagnostics bundle and the admin path it is fetched from.
+type debugItem struct {
+	file string
+	path string
+}
+
+// DebugManifest lists what a diagnostics bundle contains and what could not be collected.
+type DebugManifest struct {
+	CapturedAt time.Time         `json:"captured_at"`
+	CPUSeconds int               `json:"cpu_seconds"`
+	Files      map[string]string `json:"files"`            // file name -> sha256
+	Errors     map[string]string `json:"errors,omitempty"` // file name -> reason
+}
+
+// DebugCommand collects diagnostics from a running proxy:
+// logyctl debug capture [--out file.zip] [--seconds 10]
+func DebugCommand(args []string) {
+	if len(args) < 1 || args[0] != "capture" {
+		fmt.Println("Usage: logyctl debug capture [--out file.zip] [--seconds 10]")
+		os.Exit(1)
+	}
+	fs := flag.NewFlagSet("debug capture", flag.ExitOnError)
+	now := time.Now()
+	out := fs.String("out", "logryph-debug-"+now.Format("20060102-150405")+".zip", "Output ZIP file")
+	seconds := fs.Int("seconds", 10, "CPU profile duration in seconds")
+	_ = fs.Parse(args[1:])
+	if *seconds < 1 || *seconds > maxProfileSeconds {
+		log.Fatalf("--seconds must be between 1 and %d", maxProfileSeconds)
+	}
+
+	items := []debugItem{
+		{"runtime.json", "/debug/snapshot"},
+		{"goroutines.txt", "/debug/pprof/goroutine?debug=2"},
+		{"heap.pprof", "/debug/pprof/heap"},
+		{"allocs.pprof", "/debug/pprof/allocs"},
+		{"cpu.pprof", fmt.Sprintf("/debug/pprof/profile?seconds=%d", *seconds)},
+		{"metrics.txt", "/metrics"},
+		{"vars.json", "/debug/vars"},
+		{"config.yaml", "/debug/config"},
+		{"logs.jsonl", "/debug/logs"},
+	}
+	fmt.Printf("Capturing diagnostics (CPU profile takes %ds)...\n", *seconds)
+	manifest := DebugManifest{CapturedAt: now, CPUSeconds: *seconds, Files: map[string]string{}, Errors: map[string]string{}}
+	if err := writeDebugBundle(*out, items, time.Duration(*seconds)*time.Second, &manifest); err != nil {
+		log.Fatalf("Diagnostics capture failed: %v", err)
+	}
+	for name, reason := range manifest.Errors {
+		fmt.Printf("[WARN] %s not collected: %s\n", name, reason)
+	}
+	fmt.Printf("[OK] Diagnostics bundle written: %s (%d files)\n", *out, len(manifest.Files))
+}
+
+func writeDebugBundle(zipPath string, items []debugItem, cpu time.Duration, manifest *DebugManifest) (err error) {
+	f, err := os.Create(zipPath)
+	if err != nil {
+		return fmt.Errorf("creating zip file: %w", err)
+	}
+	defer func() {
+		if closeErr := f.Close(); closeErr != nil && err == nil {
+			err = fmt.Errorf("closing zip file: %w", closeErr)
+		}
+	}()
+	w := zip.NewWriter(f)
+	defer func() {
+		if closeErr := w.Close(); closeErr != nil && err == nil {
+			err = fmt.Errorf("closing zip writer: %w", closeErr)
+		}
+	}()
+
+	for i := 0; i < len(items); i++ {
+		status, body, err := adminRequestLimit(http.MethodGet, items[i].path, nil, nil, cpu+adminTimeout, maxDebugFileSize)
+		if err != nil && i == 0 {
+			return err // proxy unreachable; nothing else will succeed either
+		}
+		if err == nil && status != http.StatusOK {
+			err = fmt.Errorf("HTTP %d: %s", status, body)
+		}
+		if err != nil {
+			manifest.Errors[items[i].file] = err.Error()
+			continue
+		}
+		entry, err := w.Create(items[i].file)
+		if err != nil {
+			return fmt.Errorf("creating %s: %w", items[i].file, err)
+		}
+		if _, err := entry.Write(body); err != nil {
+			return fmt.Errorf("writing %s: %w", items[i].file, err)
+		}
+		sum := sha256.Sum256(body)
+		manifest.Files[items[i].file] = hex.EncodeToString(sum[:])
+	}
+	_, err = writeZipJSON(w, "manifest.json", manifest)
+	return err
+}
@@ -0,0 +1,55 @@
+package commands
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/slyt3/Logryph/internal/digest"
+	"github.com/slyt3/Logryph/internal/ledger/store"
+)
+
+// DigestCommand prints the digest the proxy would send for the last 24 hours.
+func DigestCommand(args []string) {
+	fs := flag.NewFlagSet("digest", flag.ExitOnError)
+	asJSON := fs.Bool("json", false, "Print the digest as JSON, as webhook channels receive it")
+	_ = fs.Parse(args)
+
+	db, err := store.NewDB("logryph.db")
+	if err != nil {
+		log.Fatalf("Failed to open database: %v", err)
+	}
+	defer func() {
+		if err := db.Close(); err != nil {
+			log.Printf("Failed to close database: %v", err)
+		}
+	}()
+
+	runID, err := db.GetRunID()
+	if err != nil {
+		log.Fatalf("Failed to get run ID: %v", err)
+	}
+	if runID == "" {
+		fmt.Println("No runs found in database")
+		return
+	}
+	_, _, pubKey, err := db.GetRunInfo(runID)
+	if err != nil {
+		log.Fatalf("Failed to get run info: %v", err)
+	}
+	d, err := digest.Build(db, runID, pubKey, time.Now())
+	if err != nil {
+		log.Fatalf("Digest failed: %v", err)
+	}
+	if !*asJSON {
+		fmt.Println(d.Text())
+		return
+	}
+	out, err := json.MarshalIndent(d, "", "  ")
+	if err != nil {
+		log.Fatalf("Failed to encode digest: %v", err)
+	}
+	fmt.Println(string(out))
+}
@@ -0,0 +1,88 @@
+package commands
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"sort"
+
+	"github.com/slyt3/Logryph/internal/analyzer"
+	"github.com/slyt3/Logryph/internal/assert"
+	"github.com/slyt3/Logryph/internal/ledger/store"
+)
+
+// ExfilCommand prints the per-task exfiltration report: logyctl exfil [--task id]
+func ExfilCommand(args []string) {
+	fs := flag.NewFlagSet("exfil", flag.ExitOnError)
+	taskFilter := fs.String("task", "", "Only report on this task ID")
+	_ = fs.Parse(args)
+
+	db, err := store.NewDB("logryph.db")
+	if err != nil {
+		log.Fatalf("Failed to open database: %v", err)
+	}
+	defer func() {
+		if err := db.Close(); err != nil {
+			log.Printf("Failed to close database: %v", err)
+		}
+	}()
+
+	events, err := db.GetEventsByType(analyzer.EventExfiltrationSuspected)
+	if err := assert.Check(err == nil, "failed to get exfiltration events: %v", err); err != nil {
+		log.Fatalf("Failed to get exfiltration events: %v", err)
+	}
+
+	reports := analyzer.SummarizeExfil(events)
+	const maxReports = 10000
+	printed := 0
+	for i := 0; i < maxReports; i++ {
+		if i >= len(reports) {
+			break
+		}
+		r := reports[i]
+		if *taskFilter != "" && r.TaskID != *taskFilter {
+			continue
+		}
+		if printed == 0 {
+			fmt.Println("Exfiltration Report")
+			fmt.Println("===================")
+		}
+		printExfilReport(r)
+		printed++
+	}
+	if printed == 0 {
+		fmt.Println("[OK] No suspected exfiltration recorded")
+	}
+}
+
+func printExfilReport(r analyzer.TaskExfilReport) {
+	task := r.TaskID
+	if task == "" {
+		task = "(no task)"
+	}
+	fmt.Printf("\nTask %s: %d flagged call(s), %d suspect bytes\n", task, r.Events, r.SuspectBytes)
+	fmt.Printf("  Window:  %s -> %s\n", r.First.Format("2006-01-02 15:04:05"), r.Last.Format("2006-01-02 15:04:05"))
+	fmt.Printf("  Methods: %s\n", formatCounts(r.Methods))
+	fmt.Printf("  Signals: %s\n", formatCounts(r.Kinds))
+}
+
+// formatCounts renders a count map as "a=3, b=1" sorted by key.
+func formatCounts(counts map[string]int) string {
+	const maxCountKeys = 256
+	keys := make([]string, 0, len(counts))
+	for k := range counts {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	out := ""
+	for i := 0; i < maxCountKeys; i++ {
+		if i >= len(keys) {
+			break
+		}
+		if i > 0 {
+			out += ", "
+		}
+		out += fmt.Sprintf("%s=%d", keys[i], counts[keys[i]])
+	}
+	return out
+}
@@ -0,0 +1,354 @@
+package commands
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/slyt3/Logryph/internal/ledger/audit"
+	"github.com/slyt3/Logryph/internal/ledger/store"
+	"github.com/slyt3/Logryph/internal/models"
+	"github.com/slyt3/Logryph/internal/observer"
+	"github.com/slyt3/Logryph/internal/vql"
+)
+
+const maxExplainLinks = 1000
+
+// ExplainCommand prints everything the ledger knows about one event:
+//
+//	logyctl explain <event-id> [--config logryph-policy.yaml]
+//
+// The matched rule is looked up in the policy file as it is now, which may differ from
+// the policy in force when the event was recorded.
+func ExplainCommand(args []string) {
+	if len(args) < 1 || strings.HasPrefix(args[0], "-") {
+		fmt.Println("Usage: logyctl explain <event-id> [--config logryph-policy.yaml]")
+		os.Exit(1)
+	}
+	eventID := args[0]
+	fs := flag.NewFlagSet("explain", flag.ExitOnError)
+	configPath := fs.String("config", "logryph-policy.yaml", "Policy file used to explain the matched rule")
+	if err := fs.Parse(args[1:]); err != nil {
+		log.Fatalf("Failed to parse flags: %v", err)
+	}
+
+	db, err := store.NewDB("logryph.db")
+	if err != nil {
+		log.Fatalf("Failed to open database: %v", err)
+	}
+	defer func() {
+		if err := db.Close(); err != nil {
+			log.Printf("Failed to close database: %v", err)
+		}
+	}()
+
+	event, err := db.GetEventByID(eventID)
+	if err != nil {
+		log.Fatalf("Failed to find event: %v", err)
+	}
+	if err := explainEvent(os.Stdout, db, event, *configPath); err != nil {
+		log.Fatalf("Failed to explain event: %v", err)
+	}
+}
+
+// explainEvent writes the explanation of e to w. Sections whose lookups fail say so
+// rather than aborting, so a damaged ledger can still be explained.
+func explainEvent(w io.Writer, db *store.DB, e *models.Event, configPath string) error {
+	explainHeader(w, e)
+	if err := explainPayload(w, e); err != nil {
+		return err
+	}
+	explainPolicy(w, db, e, configPath)
+	explainLinks(w, db, e)
+	explainChain(w, db, e)
+	explainSignature(w, db, e)
+	explainApprovals(w, db, e)
+	explainAnnotations(w, db, e)
+	return nil
+}
+
+func explainHeader(w io.Writer, e *models.Event) {
+	fmt.Fprintf(w, "Event %s\n", e.ID)
+	fmt.Fprintln(w, strings.Repeat("=", 60))
+	fields := [][2]string{
+		{"Type", e.EventType},
+		{"Actor", e.Actor},
+		{"Method", e.Method},
+		{"Time", e.Timestamp.Format(time.RFC3339Nano)},
+		{"Run", e.RunID},
+		{"Seq", fmt.Sprint(e.SeqIndex)},
+		{"Task", e.TaskID},
+		{"Task state", e.TaskState},
+		{"Parent", e.ParentID},
+		{"Policy", e.PolicyID},
+		{"Risk", e.RiskLevel},
+		{"Environment", e.Environment},
+		{"Tags", strings.Join(e.Tags, ", ")},
+		{"Labels", formatLabels(e.Labels)},
+		{"Correlation", e.CorrelationID},
+		{"Trace", e.TraceID},
+		{"Blocked", fmt.Sprint(e.WasBlocked)},
+	}
+	for _, f := range fields {
+		if f[1] != "" {
+			fmt.Fprintf(w, "%-12s %s\n", f[0]+":", f[1])
+		}
+	}
+}
+
+func formatLabels(labels map[string]string) string {
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	parts := make([]string, 0, len(keys))
+	for _, k := range keys {
+		parts = append(parts, k+"="+labels[k])
+	}
+	return strings.Join(parts, ", ")
+}
+
+func explainPayload(w io.Writer, e *models.Event) error {
+	sections := []struct {
+		name  string
+		value interface{}
+		set   bool
+	}{
+		{"Params", e.Params, len(e.Params) > 0},
+		{"Response", e.Response, len(e.Response) > 0},
+		{"Response value", e.ResponseValue, e.ResponseValue != nil},
+		{"Headers", e.Headers, len(e.Headers) > 0},
+		{"Query", e.QueryParams, len(e.QueryParams) > 0},
+	}
+	for _, s := range sections {
+		if !s.set {
+			continue
+		}
+		out, err := json.MarshalIndent(s.value, "  ", "  ")
+		if err != nil {
+			return fmt.Errorf("encoding %s: %w", strings.ToLower(s.name), err)
+		}
+		fmt.Fprintf(w, "\n%s:\n  %s\n", s.name, out)
+	}
+	return nil
+}
+
+// explainPolicy shows the rule named by the event and why it matched: the method
+// patterns that cover the method and the conditions, re-evaluated on the stored params.
+func explainPolicy(w io.Writer, db *store.DB, e *models.Event, configPath string) {
+	fmt.Fprintln(w, "\nPolicy:")
+	if e.PolicyID == "" {
+		fmt.Fprintln(w, "  no rule matched")
+		return
+	}
+	engine, err := observer.NewObserverEngine(configPath)
+	if err != nil {
+		fmt.Fprintf(w, "  rule %s (policy file unavailable: %v)\n", e.PolicyID, err)
+		return
+	}
+	var rule *observer.Rule
+	rules := engine.GetPoliciesFor(e.Environment)
+	for j := 0; j < len(rules); j++ {
+		if rules[j].ID == e.PolicyID {
+			rule = &rules[j]
+			break
+		}
+	}
+	if rule == nil {
+		fmt.Fprintf(w, "  rule %s is not in %s (version %s); the policy changed since\n", e.PolicyID, configPath, engine.GetVersion())
+		return
+	}
+	action := rule.Action
+	if action == "" {
+		action = observer.RuleActionTag
+	}
+	fmt.Fprintf(w, "  rule %s from %s (version %s): risk %s, action %s\n", rule.ID, configPath, engine.GetVersion(), rule.RiskLevel, action)
+	method := e.CanonicalMethod()
+	if method != e.Method {
+		method = fmt.Sprintf("%s (alias of %s)", e.Method, method)
+	}
+	var matched []string
+	for _, p := range rule.MatchMethods {
+		if e.Method != "" && observer.MatchPattern(p, e.CanonicalMethod()) {
+			matched = append(matched, p)
+		}
+	}
+	if len(matched) > 0 {
+		fmt.Fprintf(w, "  method %s matches %s\n", method, strings.Join(matched, ", "))
+	} else {
+		fmt.Fprintf(w, "  method %s matches none of %s now\n", method, strings.Join(rule.MatchMethods, ", "))
+	}
+	if len(rule.MatchSQL) > 0 {
+		fmt.Fprintf(w, "  match_sql: %s\n", strings.Join(rule.MatchSQL, ", "))
+	}
+	for _, c := range rule.MatchConditions {
+		fmt.Fprintf(w, "  condition: %s %s %s\n", c["key"], c["operator"], c["value"])
+	}
+	if rule.When != "" {
+		fmt.Fprintf(w, "  when: %s\n", rule.When)
+	}
+	if len(rule.MatchConditions) > 0 || rule.When != "" {
+		var prior []vql.Env
+		if rule.UsesHistory() {
+			prior = explainTaskHistory(w, db, e, engine.GetTaskHistory())
+		}
+		fmt.Fprintf(w, "  conditions hold on the stored params: %v (redacted params may differ from the call)\n",
+			rule.MatchesTaskCall(e.CanonicalMethod(), e.Params, e.Environment, prior))
+	}
+}
+
+// explainTaskHistory rebuilds, from the ledger, the earlier calls of e's task that a
+// history() rule saw: the task's tool calls before e within the policy's task_history
+// window, most recent first. The proxy kept them in memory, so calls made before a
+// restart are included here although the proxy had forgotten them.
+func explainTaskHistory(w io.Writer, db *store.DB, e *models.Event, h observer.TaskHistory) []vql.Env {
+	if e.TaskID == "" {
+		fmt.Fprintln(w, "  history: the call has no task, so history() counts nothing")
+		return nil
+	}
+	events, err := db.GetEventsByTaskID(e.TaskID)
+	if err != nil {
+		fmt.Fprintf(w, "  history: lookup failed: %v\n", err)
+		return nil
+	}
+	sort.SliceStable(events, func(a, b int) bool { return events[a].Timestamp.After(events[b].Timestamp) })
+	var prior []vql.Env
+	for j := 0; j < len(events) && len(prior) < h.Size; j++ {
+		c := &events[j]
+		if c.EventType != "tool_call" || c.ID == e.ID || !c.Timestamp.Before(e.Timestamp) {
+			continue
+		}
+		if e.Timestamp.Sub(c.Timestamp) > h.MaxAge {
+			break
+		}
+		prior = append(prior, vql.MapEnv{"method": c.CanonicalMethod(), "params": c.Params, "environment": c.Environment})
+	}
+	fmt.Fprintf(w, "  history: %d earlier call(s) of task %s within %s, from the ledger\n", len(prior), e.TaskID, h.MaxAge)
+	return prior
+}
+
+func explainLinks(w io.Writer, db *store.DB, e *models.Event) {
+	fmt.Fprintln(w, "\nLinks:")
+	if e.ParentID != "" {
+		if parent, err := db.GetEventByID(e.ParentID); err == nil {
+			fmt.Fprintf(w, "  parent   %s\n", eventSummary(parent))
+		} else {
+			fmt.Fprintf(w, "  parent   %s (not in this ledger)\n", e.ParentID)
+		}
+	}
+	children, err := db.GetEventsByParentID(e.ID)
+	if err != nil {
+		fmt.Fprintf(w, "  children: lookup failed: %v\n", err)
+		return
+	}
+	for j := 0; j < len(children) && j < maxExplainLinks; j++ {
+		fmt.Fprintf(w, "  child    %s\n", eventSummary(&children[j]))
+	}
+	if e.ParentID == "" && len(children) == 0 {
+		fmt.Fprintln(w, "  none")
+	}
+}
+
+// explainChain shows the events either side of e in its run and checks that their
+// hashes link up.
+func explainChain(w io.Writer, db *store.DB, e *models.Event) {
+	fmt.Fprintln(w, "\nChain:")
+	from := e.SeqIndex
+	if from > 0 {
+		from--
+	}
+	neighbors, err := db.GetEventsFrom(e.RunID, from, 3)
+	if err != nil {
+		fmt.Fprintf(w, "  lookup failed: %v\n", err)
+		return
+	}
+	var prev, next *models.Event
+	for j := range neighbors {
+		switch neighbors[j].SeqIndex {
+		case e.SeqIndex - 1:
+			if e.SeqIndex > 0 {
+				prev = &neighbors[j]
+			}
+		case e.SeqIndex + 1:
+			next = &neighbors[j]
+		}
+	}
+	switch {
+	case prev != nil:
+		fmt.Fprintf(w, "  prev  #%d %s  %s\n", prev.SeqIndex, prev.ID, linkStatus(prev.CurrentHash == e.PrevHash))
+	case e.SeqIndex == 0:
+		fmt.Fprintln(w, "  prev  none (genesis)")
+	default:
+		fmt.Fprintf(w, "  prev  #%d missing (gap in the chain)\n", e.SeqIndex-1)
+	}
+	fmt.Fprintf(w, "  this  #%d %s  hash %s\n", e.SeqIndex, e.ID, e.CurrentHash)
+	if next != nil {
+		fmt.Fprintf(w, "  next  #%d %s  %s\n", next.SeqIndex, next.ID, linkStatus(next.PrevHash == e.CurrentHash))
+	} else {
+		fmt.Fprintln(w, "  next  none (chain head, or a gap)")
+	}
+}
+
+func linkStatus(ok bool) string {
+	if ok {
+		return "hash link OK"
+	}
+	return "hash link BROKEN"
+}
+
+func explainSignature(w io.Writer, db *store.DB, e *models.Event) {
+	fmt.Fprint(w, "\nSignature: ")
+	_, _, pubKey, err := db.GetRunInfo(e.RunID)
+	if err != nil {
+		fmt.Fprintf(w, "not checked, run key unavailable: %v\n", err)
+		return
+	}
+	if err := audit.VerifyEventWithKey(e, pubKey); err != nil {
+		fmt.Fprintf(w, "INVALID: %v\n", err)
+		return
+	}
+	fmt.Fprintln(w, "valid (hash and signature match the run key)")
+}
+
+func explainApprovals(w io.Writer, db *store.DB, e *models.Event) {
+	children, err := db.GetEventsByParentID(e.ID)
+	if err != nil {
+		return
+	}
+	printed := false
+	for j := 0; j < len(children) && j < maxExplainLinks; j++ {
+		c := children[j]
+		if c.EventType != "stall_resolved" {
+			continue
+		}
+		if !printed {
+			fmt.Fprintln(w, "\nApprovals:")
+			printed = true
+		}
+		fmt.Fprintf(w, "  %s by %v after %vms (policy %s) [%s]\n", c.Params["decision"], c.Params["approver"], c.Params["waited_ms"], c.PolicyID, c.ID)
+	}
+}
+
+func explainAnnotations(w io.Writer, db *store.DB, e *models.Event) {
+	annotations, err := db.GetAnnotations(e.ID)
+	if err != nil || len(annotations) == 0 {
+		return
+	}
+	fmt.Fprintln(w, "\nAnnotations:")
+	for j := 0; j < len(annotations) && j < maxExplainLinks; j++ {
+		a := annotations[j]
+		fmt.Fprintf(w, "  [%s] %s: %s\n", a.CreatedAt.Format("2006-01-02 15:04:05"), a.Author, a.Note)
+	}
+}
+
+// eventSummary is the one-line form of an event used in link lists.
+func eventSummary(e *models.Event) string {
+	return fmt.Sprintf("%s %-22s %s %s", e.ID, e.EventType, e.Method, e.Timestamp.Format("15:04:05.000"))
+}
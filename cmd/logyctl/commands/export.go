@@ -3,47 +3,267 @@ package commands
 import (
 	"archive/zip"
 	"encoding/json"
+	"flag"
 	"fmt"
 	"io"
 	"log"
 	"os"
+	"path/filepath"
+	"strings"
 	"time"
 
+	"github.com/slyt3/Logryph/internal/attest"
+	"github.com/slyt3/Logryph/internal/crypto"
+	"github.com/slyt3/Logryph/internal/export"
 	"github.com/slyt3/Logryph/internal/ledger"
 	"github.com/slyt3/Logryph/internal/ledger/store"
 )
 
 type EvidenceManifest struct {
-	Version       string                 `json:"version"`
-	RunID         string                 `json:"run_id"`
-	ExportTime    time.Time              `json:"export_time"`
-	RunStats      *ledger.RunStats       `json:"run_stats"`
-	GenesisAnchor map[string]interface{} `json:"genesis_anchor"`
-	LastHash      string                 `json:"last_hash"`
+	Version        string                 `json:"version"`
+	RunID          string                 `json:"run_id"`
+	ExportTime     time.Time              `json:"export_time"`
+	RunStats       *ledger.RunStats       `json:"run_stats"`
+	GenesisAnchor  map[string]interface{} `json:"genesis_anchor"`
+	LastHash       string                 `json:"last_hash"` // chain head captured by the snapshot
+	LastSeq        uint64                 `json:"last_seq"`
+	VerifiedEvents int                    `json:"verified_events"`         // events verified in the snapshot
+	EventsSHA256   string                 `json:"events_sha256,omitempty"` // of events.jsonl, or task.json for a task export
+	TaskID         string                 `json:"task_id,omitempty"`       // set for task exports
+	Agent          *ledger.RunIdentity    `json:"agent,omitempty"`         // from the run's signed genesis
 }
 
-func ExportCommand() {
-	if len(os.Args) < 3 {
-		fmt.Println("Usage: logyctl export <output-file.zip> [run-id]")
+func ExportCommand(args []string) {
+	if len(args) < 1 {
+		fmt.Println("Usage: logyctl export <output-file> [run-id] [--task id] [--format zip|jsonl|json|aggregate] [--k n] [--epsilon e] [--attestation file] [--tsa url] [--no-attest]")
 		os.Exit(1)
 	}
-	outputFile := os.Args[2]
+	outputFile := args[0]
 
 	// Default to current run if not specified
 	targetRunID := ""
-	if len(os.Args) > 3 {
-		targetRunID = os.Args[3]
+	args = args[1:]
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		targetRunID, args = args[0], args[1:]
 	}
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	format := fs.String("format", "zip", "zip (evidence bag), jsonl (the run's events, one per line), json (task bundle) or aggregate (statistics only)")
+	k := fs.Int("k", 5, "For --format aggregate: publish only counts that at least k tasks contributed to")
+	epsilon := fs.Float64("epsilon", 0, "For --format aggregate: add Laplace noise of scale 1/epsilon to every count (0 for none)")
+	taskID := fs.String("task", "", "Export only this task's events, with the chain context to verify them")
+	attestPath := fs.String("attestation", "", "Attestation file (default <output-file>.attestation.json)")
+	tsaURL := fs.String("tsa", "", "RFC 3161 time-stamp authority URL for the attestation")
+	noAttest := fs.Bool("no-attest", false, "Skip the signed attestation")
+	_ = fs.Parse(args)
 
-	if err := ExportEvidenceBag(outputFile, targetRunID); err != nil {
+	var manifest *EvidenceManifest
+	var err error
+	switch {
+	case *taskID != "":
+		manifest, err = ExportTask(outputFile, targetRunID, *taskID, *format)
+	case *format == "zip":
+		manifest, err = ExportEvidenceBag(outputFile, targetRunID, printExportProgress)
+	case *format == "jsonl":
+		manifest, err = ExportJSONL(outputFile, targetRunID, printExportProgress)
+	case *format == "aggregate":
+		manifest, err = ExportAggregate(outputFile, targetRunID, export.AggregateOptions{K: *k, Epsilon: *epsilon})
+	default:
+		log.Fatalf("Invalid format %q: must be zip, jsonl or aggregate", *format)
+	}
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
 		log.Fatalf("Export failed: %v", err)
 	}
-	fmt.Printf("[OK] Evidence bag created: %s\n", outputFile)
+	if *taskID != "" {
+		fmt.Printf("[OK] Task %s exported (%d events, chain head seq %d): %s\n", *taskID, manifest.VerifiedEvents, manifest.LastSeq, outputFile)
+	} else if *format == "jsonl" {
+		fmt.Printf("[OK] %d events exported: %s\n", manifest.VerifiedEvents, outputFile)
+	} else if *format == "aggregate" {
+		fmt.Printf("[OK] Statistics of %d events exported (k=%d): %s\n", manifest.VerifiedEvents, *k, outputFile)
+	} else {
+		fmt.Printf("[OK] Evidence bag created: %s\n", outputFile)
+	}
+	if *noAttest {
+		return
+	}
+	if *attestPath == "" {
+		*attestPath = attest.Path(outputFile)
+	}
+	if err := writeExportAttestation(outputFile, *attestPath, manifest, *tsaURL); err != nil {
+		log.Fatalf("Attestation failed: %v", err)
+	}
+	fmt.Printf("[OK] Attestation written: %s\n", *attestPath)
+}
+
+// printExportProgress keeps a running count on stderr while events stream out.
+func printExportProgress(p export.Progress) {
+	fmt.Fprintf(os.Stderr, "\r  %d events exported (through seq %d)", p.Events, p.LastSeq)
+}
+
+// writeExportAttestation signs the finished export with the ledger key. It refuses to
+// create a key: an attestation from a fresh key would prove nothing about the ledger.
+func writeExportAttestation(exportPath, attestPath string, manifest *EvidenceManifest, tsaURL string) error {
+	signer, err := crypto.LoadSigner(".logryph_key")
+	if err != nil {
+		return err
+	}
+	a, err := attest.New(exportPath, manifest.RunID, manifest.LastHash, manifest.LastSeq, signer, attest.Options{TSAURL: tsaURL})
+	if err != nil {
+		return err
+	}
+	return attest.Write(attestPath, a)
+}
+
+// ExportEvidenceBag writes the run's events, manifest and database into a ZIP and
+// returns the manifest. The database is a consistent snapshot, taken while the proxy may
+// still be writing. Events stream into events.jsonl a page at a time and are verified on
+// the way; if verification fails the partial ZIP is removed. The manifest records the
+// chain head the snapshot ends at. The ZIP is closed by the time it returns.
+func ExportEvidenceBag(zipPath, targetRunID string, progress func(export.Progress)) (*EvidenceManifest, error) {
+	return exportSnapshot(zipPath, targetRunID, func(out *os.File, snap *store.DB, run exportRun) (*EvidenceManifest, error) {
+		return writeEvidenceZip(out, snap, run, progress)
+	})
+}
+
+// ExportJSONL writes the run's events to path as JSON Lines, verified as they stream out
+// of a snapshot of the ledger, and returns a manifest describing them.
+func ExportJSONL(path, targetRunID string, progress func(export.Progress)) (*EvidenceManifest, error) {
+	return exportSnapshot(path, targetRunID, func(out *os.File, snap *store.DB, run exportRun) (*EvidenceManifest, error) {
+		res, err := export.WriteJSONL(out, snap, run.id, run.pubKey, progress)
+		if err != nil {
+			return nil, err
+		}
+		return exportManifest(snap, run.id, res)
+	})
+}
+
+// ExportAggregate writes statistics about the run to path as JSON: method counts,
+// latency histograms and risk levels, with no payloads, computed from a snapshot of the
+// ledger whose chain is verified on the way. Counts from fewer than opts.K tasks are
+// suppressed.
+func ExportAggregate(path, targetRunID string, opts export.AggregateOptions) (*EvidenceManifest, error) {
+	return exportSnapshot(path, targetRunID, func(out *os.File, snap *store.DB, run exportRun) (*EvidenceManifest, error) {
+		agg, err := export.BuildAggregate(snap, run.id, run.pubKey, opts)
+		if err != nil {
+			return nil, err
+		}
+		encoder := json.NewEncoder(out)
+		encoder.SetIndent("", "  ")
+		return &EvidenceManifest{
+			Version:        "1.0 (Logryph aggregate)",
+			RunID:          run.id,
+			ExportTime:     time.Now(),
+			LastHash:       agg.Head.Hash,
+			LastSeq:        agg.Head.Seq,
+			VerifiedEvents: agg.Verified,
+		}, encoder.Encode(agg)
+	})
+}
+
+// ExportTask writes one task's events and the chain context that proves them, taken from
+// a snapshot of the ledger whose whole chain is verified first. format zip writes a task
+// evidence bag with task.json and manifest.json; json writes the bundle on its own.
+func ExportTask(path, targetRunID, taskID, format string) (*EvidenceManifest, error) {
+	if format != "zip" && format != "json" {
+		return nil, fmt.Errorf("invalid format %q for a task export: must be zip or json", format)
+	}
+	return exportSnapshot(path, targetRunID, func(out *os.File, snap *store.DB, run exportRun) (_ *EvidenceManifest, err error) {
+		b, err := export.BuildTask(snap, run.id, taskID, run.pubKey)
+		if err != nil {
+			return nil, err
+		}
+		agent, err := snap.GetRunIdentity(run.id)
+		if err != nil {
+			return nil, err
+		}
+		manifest := &EvidenceManifest{
+			Version:        "1.0 (Logryph task)",
+			RunID:          run.id,
+			TaskID:         taskID,
+			ExportTime:     time.Now(),
+			LastHash:       b.Head.Hash,
+			LastSeq:        b.Head.Seq,
+			VerifiedEvents: len(b.Events),
+			Agent:          agent,
+		}
+		if format == "json" {
+			encoder := json.NewEncoder(out)
+			encoder.SetIndent("", "  ")
+			return manifest, encoder.Encode(b)
+		}
+		w := zip.NewWriter(out)
+		defer func() {
+			if closeErr := w.Close(); closeErr != nil && err == nil {
+				err = fmt.Errorf("closing zip writer: %w", closeErr)
+			}
+		}()
+		if manifest.EventsSHA256, err = writeZipJSON(w, export.TaskBundleFile, b); err != nil {
+			return nil, err
+		}
+		_, err = writeZipJSON(w, "manifest.json", manifest)
+		return manifest, err
+	})
+}
+
+// exportRun is the run being exported, the key its chain is verified against and the
+// snapshot it is read from.
+type exportRun struct {
+	id, pubKey string
+	snapPath   string // snapshot database file
+}
+
+// exportSnapshot snapshots the live ledger and has write fill outPath from the snapshot.
+// outPath is removed if write fails.
+func exportSnapshot(outPath, targetRunID string, write func(*os.File, *store.DB, exportRun) (*EvidenceManifest, error)) (_ *EvidenceManifest, err error) {
+	dir, err := os.MkdirTemp("", "logryph-export-*")
+	if err != nil {
+		return nil, fmt.Errorf("creating snapshot directory: %w", err)
+	}
+	defer func() {
+		if err := os.RemoveAll(dir); err != nil {
+			log.Printf("Failed to remove snapshot %s: %v", dir, err)
+		}
+	}()
+	snapPath := filepath.Join(dir, "logryph.db")
+	if err := snapshotLedger("logryph.db", snapPath); err != nil {
+		return nil, err
+	}
+	snap, err := store.NewDB(snapPath)
+	if err != nil {
+		return nil, fmt.Errorf("opening snapshot: %w", err)
+	}
+	defer func() {
+		if closeErr := snap.Close(); closeErr != nil && err == nil {
+			err = fmt.Errorf("closing snapshot: %w", closeErr)
+		}
+	}()
+	run, err := snapshotRun(snap, targetRunID)
+	if err != nil {
+		return nil, err
+	}
+	run.snapPath = snapPath
+
+	out, err := os.Create(outPath)
+	if err != nil {
+		return nil, fmt.Errorf("creating %s: %w", outPath, err)
+	}
+	manifest, err := write(out, snap, run)
+	if closeErr := out.Close(); closeErr != nil && err == nil {
+		err = fmt.Errorf("closing %s: %w", outPath, closeErr)
+	}
+	if err != nil {
+		_ = os.Remove(outPath)
+		return nil, err
+	}
+	return manifest, nil
 }
 
-func ExportEvidenceBag(zipPath, targetRunID string) error {
-	// 1. Open DB
-	db, err := store.NewDB("logryph.db")
+// snapshotLedger copies the live ledger to dest without stopping the proxy.
+func snapshotLedger(livePath, dest string) error {
+	if _, err := os.Stat(livePath); err != nil {
+		return fmt.Errorf("opening %s: %w", livePath, err)
+	}
+	db, err := store.NewDB(livePath)
 	if err != nil {
 		return fmt.Errorf("opening db: %w", err)
 	}
@@ -52,87 +272,95 @@ func ExportEvidenceBag(zipPath, targetRunID string) error {
 			log.Printf("Failed to close database: %v", err)
 		}
 	}()
+	return db.Snapshot(dest)
+}
 
-	// 2. Identify Run ID
-	runID := targetRunID
+// snapshotRun resolves the run to export, the current one if runID is empty.
+func snapshotRun(snap *store.DB, runID string) (exportRun, error) {
+	var err error
 	if runID == "" {
-		runID, err = db.GetRunID()
-		if err != nil {
-			return fmt.Errorf("getting run id: %w", err)
+		if runID, err = snap.GetRunID(); err != nil {
+			return exportRun{}, fmt.Errorf("getting run id: %w", err)
 		}
 	}
 	if runID == "" {
-		return fmt.Errorf("no runs found")
+		return exportRun{}, fmt.Errorf("no runs found")
 	}
-
-	// 3. Gather Data
-	stats, err := db.GetRunStats(runID)
+	_, _, pubKey, err := snap.GetRunInfo(runID)
 	if err != nil {
-		return fmt.Errorf("getting stats: %w", err)
+		return exportRun{}, fmt.Errorf("getting run info: %w", err)
 	}
+	return exportRun{id: runID, pubKey: pubKey}, nil
+}
 
-	_, lastHash, err := db.GetLastEvent(runID)
+// exportManifest describes a run whose events were exported and verified in res.
+func exportManifest(snap *store.DB, runID string, res *export.Result) (*EvidenceManifest, error) {
+	stats, err := snap.GetRunStats(runID)
 	if err != nil {
-		return fmt.Errorf("getting last hash: %w", err)
-	}
-
-	manifest := EvidenceManifest{
-		Version:    "1.0 (Logryph 2026.1)",
-		RunID:      runID,
-		ExportTime: time.Now(),
-		RunStats:   stats,
-		LastHash:   lastHash,
+		return nil, fmt.Errorf("getting stats: %w", err)
 	}
-
-	// 4. Create Zip
-	f, err := os.Create(zipPath)
+	agent, err := snap.GetRunIdentity(runID)
 	if err != nil {
-		return fmt.Errorf("creating zip file: %w", err)
+		return nil, err
 	}
-	defer func() {
-		if err := f.Close(); err != nil {
-			fmt.Fprintf(os.Stderr, "Failed to close zip file: %v\n", err)
-		}
-	}()
+	return &EvidenceManifest{
+		Version:        "1.0 (Logryph 2026.1)",
+		RunID:          runID,
+		ExportTime:     time.Now(),
+		RunStats:       stats,
+		LastHash:       res.LastHash,
+		LastSeq:        res.LastSeq,
+		VerifiedEvents: res.Events,
+		EventsSHA256:   res.SHA256,
+		Agent:          agent,
+	}, nil
+}
 
-	w := zip.NewWriter(f)
+// writeEvidenceZip streams the run's events into events.jsonl, then adds the manifest and
+// the snapshot database.
+func writeEvidenceZip(out *os.File, snap *store.DB, run exportRun, progress func(export.Progress)) (_ *EvidenceManifest, err error) {
+	w := zip.NewWriter(out)
 	defer func() {
-		if err := w.Close(); err != nil {
-			fmt.Fprintf(os.Stderr, "Failed to close zip writer: %v\n", err)
+		if closeErr := w.Close(); closeErr != nil && err == nil {
+			err = fmt.Errorf("closing zip writer: %w", closeErr)
 		}
 	}()
 
-	// 5. Add Manifest
+	eventsFile, err := w.Create("events.jsonl")
+	if err != nil {
+		return nil, err
+	}
+	res, err := export.WriteJSONL(eventsFile, snap, run.id, run.pubKey, progress)
+	if err != nil {
+		return nil, err
+	}
+	manifest, err := exportManifest(snap, run.id, res)
+	if err != nil {
+		return nil, err
+	}
 	manFile, err := w.Create("manifest.json")
 	if err != nil {
-		return err
+		return nil, err
 	}
 	encoder := json.NewEncoder(manFile)
 	encoder.SetIndent("", "  ")
 	if err := encoder.Encode(manifest); err != nil {
-		return err
+		return nil, err
 	}
 
-	// 6. Add DB (Raw)
-	// We allow reading the DB even if locked by WAL, usually.
-	dbFile, err := os.Open("logryph.db")
+	dbFile, err := os.Open(run.snapPath)
 	if err != nil {
-		// Try to read generic way if locked
-		return fmt.Errorf("opening logryph.db: %w", err)
+		return nil, fmt.Errorf("opening snapshot: %w", err)
 	}
 	defer func() {
 		if err := dbFile.Close(); err != nil {
-			fmt.Fprintf(os.Stderr, "Failed to close DB file: %v\n", err)
+			fmt.Fprintf(os.Stderr, "Failed to close snapshot file: %v\n", err)
 		}
 	}()
-
 	destFile, err := w.Create("logryph.db")
 	if err != nil {
-		return err
+		return nil, err
 	}
-	if _, err := io.Copy(destFile, dbFile); err != nil {
-		return err
-	}
-
-	return nil
+	_, err = io.Copy(destFile, dbFile)
+	return manifest, err
 }
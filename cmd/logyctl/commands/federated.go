@@ -0,0 +1,92 @@
+package commands
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/slyt3/Logryph/internal/federation"
+	"github.com/slyt3/Logryph/internal/models"
+)
+
+const federatedUsage = "Usage: logyctl trace --federated <trace-id> <ledger.db|export.zip> <ledger.db|export.zip>..."
+
+var traceIDPattern = regexp.MustCompile(`^[0-9a-f]{32}$`)
+
+// federatedEvent is an event tagged with the ledger it was read from.
+type federatedEvent struct {
+	ledger string
+	event  models.Event
+}
+
+// FederatedTraceCommand merges one W3C trace from several ledgers, such as those of an
+// agent's proxy and of a sub-agent's proxy, into a single timeline:
+// logyctl trace --federated <trace-id> <ledger.db|export.zip>...
+func FederatedTraceCommand(args []string) {
+	if len(args) < 3 {
+		fmt.Println(federatedUsage)
+		os.Exit(1)
+	}
+	traceID := strings.ToLower(args[0])
+	if !traceIDPattern.MatchString(traceID) {
+		log.Fatalf("Invalid trace ID %q: expected 32 hex digits", args[0])
+	}
+
+	fmt.Printf("Federated Trace: %s\n", traceID)
+	var merged []federatedEvent
+	for _, path := range args[1:] {
+		events, err := loadTraceEvents(path, traceID)
+		if err != nil {
+			log.Fatalf("Failed to read %s: %v", path, err)
+		}
+		run := ""
+		if len(events) > 0 {
+			run = fmt.Sprintf(" (run %.8s)", events[0].RunID)
+		}
+		fmt.Printf("  %-24s %d events%s\n", path, len(events), run)
+		for i := 0; i < len(events); i++ {
+			merged = append(merged, federatedEvent{ledger: path, event: events[i]})
+		}
+	}
+	if len(merged) == 0 {
+		fmt.Printf("No events found for trace %s\n", traceID)
+		return
+	}
+	// Ledgers are merged by timestamp; each keeps its own order for equal times.
+	sort.SliceStable(merged, func(i, j int) bool {
+		return merged[i].event.Timestamp.Before(merged[j].event.Timestamp)
+	})
+
+	start := merged[0].event.Timestamp
+	fmt.Println(strings.Repeat("=", 60))
+	for i := 0; i < len(merged); i++ {
+		e := merged[i].event
+		task := ""
+		if e.TaskID != "" {
+			task = " task " + e.TaskID
+		}
+		fmt.Printf("+%-10v %-16s %-4s %-15s [%.6s]%s\n",
+			e.Timestamp.Sub(start).Truncate(time.Millisecond), merged[i].ledger, eventMarker(e), e.Method, e.ID, task)
+	}
+	duration := merged[len(merged)-1].event.Timestamp.Sub(start)
+	fmt.Println(strings.Repeat("-", 60))
+	fmt.Printf("Summary: %d events from %d ledgers | Total Duration: %v\n", len(merged), len(args)-1, duration.Truncate(time.Millisecond))
+}
+
+// loadTraceEvents reads a trace's events from a ledger database or an evidence bag.
+func loadTraceEvents(path, traceID string) ([]models.Event, error) {
+	db, closeFn, err := federation.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := closeFn(); err != nil {
+			log.Printf("Failed to close %s: %v", path, err)
+		}
+	}()
+	return db.GetEventsByTraceID(traceID)
+}
@@ -0,0 +1,76 @@
+package commands
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/slyt3/Logryph/internal/deploy"
+	"github.com/slyt3/Logryph/internal/observer"
+)
+
+const generateUsage = "Usage: logyctl generate k8s --image IMAGE --target URL [--config file] [--dir DIR] [--name N] [--namespace NS]"
+
+// GenerateCommand writes deployment assets derived from the policy and the proxy's settings:
+// logyctl generate k8s --image IMAGE --target URL [flags]
+func GenerateCommand(args []string) {
+	if len(args) < 1 || args[0] != "k8s" {
+		fmt.Println(generateUsage)
+		os.Exit(1)
+	}
+	fs := flag.NewFlagSet("generate k8s", flag.ExitOnError)
+	configPath := fs.String("config", "logryph-policy.yaml", "Policy file to embed in the ConfigMap")
+	dir := fs.String("dir", ".", "Output directory")
+	image := fs.String("image", "", "Logryph container image, e.g. registry.example.com/logryph:v1.4.0")
+	target := fs.String("target", "", "Tool server URL the proxy forwards to")
+	name := fs.String("name", "logryph", "Resource name prefix")
+	namespace := fs.String("namespace", "", "Namespace to set on every object (none when empty)")
+	port := fs.Int("port", deploy.DefaultPort, "Proxy port")
+	backpressure := fs.String("backpressure", "drop", "Backpressure strategy: drop or block")
+	storage := fs.String("storage", "1Gi", "Size of the ledger volume")
+	agentImage := fs.String("agent-image", "", "Agent image for the sidecar example")
+	_ = fs.Parse(args[1:])
+
+	policy, err := observer.LoadConfig(*configPath)
+	if err != nil {
+		log.Fatalf("Failed to load policy %s: %v", *configPath, err)
+	}
+	spec := deploy.Spec{
+		Name: *name, Namespace: *namespace, Image: *image, Target: *target,
+		Port: *port, Backpressure: *backpressure, StorageSize: *storage, Policy: policy,
+	}
+	manifests, err := deploy.Kubernetes(spec)
+	if err != nil {
+		log.Fatalf("Failed to build manifests: %v\n%s", err, generateUsage)
+	}
+	values, err := deploy.HelmValues(spec)
+	if err != nil {
+		log.Fatalf("Failed to build Helm values: %v", err)
+	}
+	sidecar, err := deploy.Sidecar(spec, *agentImage)
+	if err != nil {
+		log.Fatalf("Failed to build sidecar example: %v", err)
+	}
+	if err := os.MkdirAll(*dir, 0o755); err != nil {
+		log.Fatalf("Failed to create %s: %v", *dir, err)
+	}
+	files := []struct {
+		name string
+		data []byte
+		mode os.FileMode
+	}{
+		{"logryph-k8s.yaml", manifests, 0o600}, // holds the admin token
+		{"logryph-values.yaml", values, 0o644},
+		{"logryph-sidecar.yaml", sidecar, 0o644},
+	}
+	for i := 0; i < len(files); i++ {
+		path := filepath.Join(*dir, files[i].name)
+		if err := os.WriteFile(path, files[i].data, files[i].mode); err != nil {
+			log.Fatalf("Failed to write %s: %v", path, err)
+		}
+		fmt.Printf("[OK] Wrote %s\n", path)
+	}
+	fmt.Println("Apply with: kubectl apply -f logryph-k8s.yaml (the Secret holds a newly generated admin token).")
+}
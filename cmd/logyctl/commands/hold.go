@@ -0,0 +1,145 @@
+package commands
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/slyt3/Logryph/internal/api"
+	"github.com/slyt3/Logryph/internal/ledger/store"
+	"github.com/slyt3/Logryph/internal/models"
+)
+
+// HoldCommand manages legal holds, the signed record of which tasks and cases must be
+// preserved:
+//
+//	logyctl hold set <task|case> <ref> --reason "..." [--as name]
+//	logyctl hold release <task|case> <ref> --reason "..." [--as name]
+//	logyctl hold list
+//	logyctl hold check <event-id>
+//
+// Holds are set and released through the admin API so the running proxy signs and
+// chains the hold events.
+func HoldCommand(args []string) {
+	if len(args) < 1 {
+		printHoldUsage()
+		os.Exit(1)
+	}
+	fs := flag.NewFlagSet("hold", flag.ExitOnError)
+	reason := fs.String("reason", "", "Why the hold is set or released, recorded in the ledger (required)")
+	principal := fs.String("as", os.Getenv("USER"), "Principal recorded as requesting the change")
+	sub, args := args[0], parseInterspersed(fs, args[1:])
+
+	switch {
+	case (sub == "set" || sub == "release") && len(args) == 2 && *reason != "":
+		submitHold(api.HoldRequest{Kind: args[0], Ref: args[1], Reason: *reason, Principal: *principal, Release: sub == "release"})
+	case sub == "list" && len(args) == 0:
+		listHolds()
+	case sub == "check" && len(args) == 1:
+		checkHold(args[0])
+	default:
+		printHoldUsage()
+		os.Exit(1)
+	}
+}
+
+func printHoldUsage() {
+	fmt.Println("Usage:")
+	fmt.Println("  logyctl hold set <task|case> <ref> --reason R [--as name]")
+	fmt.Println("  logyctl hold release <task|case> <ref> --reason R [--as name]")
+	fmt.Println("  logyctl hold list")
+	fmt.Println("  logyctl hold check <event-id>")
+}
+
+func submitHold(req api.HoldRequest) {
+	payload, err := json.Marshal(req)
+	if err != nil {
+		log.Fatalf("Failed to encode hold: %v", err)
+	}
+	status, body, err := adminRequest(http.MethodPost, "/api/holds", nil, payload)
+	if err != nil {
+		log.Fatalf("Failed to submit hold: %v", err)
+	}
+	if status != http.StatusAccepted {
+		fmt.Printf("Error (%d): %s", status, string(body))
+		os.Exit(1)
+	}
+	var ack api.HoldResponse
+	if err := json.Unmarshal(body, &ack); err != nil {
+		log.Fatalf("Failed to parse response: %v", err)
+	}
+	verb := "set on"
+	if ack.Release {
+		verb = "released from"
+	}
+	fmt.Printf("[OK] Legal hold %s %s %s (event %s)\n", verb, ack.Kind, ack.Ref, ack.ID)
+}
+
+func openHoldDB() *store.DB {
+	db, err := store.NewDB("logryph.db")
+	if err != nil {
+		log.Fatalf("Failed to open database: %v", err)
+	}
+	return db
+}
+
+func listHolds() {
+	db := openHoldDB()
+	defer func() {
+		if err := db.Close(); err != nil {
+			log.Printf("Failed to close database: %v", err)
+		}
+	}()
+	holds, err := db.GetLegalHolds()
+	if err != nil {
+		log.Fatalf("Failed to read holds: %v", err)
+	}
+	if len(holds) == 0 {
+		fmt.Println("No legal holds in force")
+		return
+	}
+	for i := 0; i < len(holds); i++ {
+		h := holds[i]
+		fmt.Printf("  %-4s %-12s since %s by %s: %s\n", h.Kind, h.Ref, h.SetAt.Format(time.RFC3339), h.Principal, h.Reason)
+	}
+}
+
+func checkHold(eventID string) {
+	db := openHoldDB()
+	defer func() {
+		if err := db.Close(); err != nil {
+			log.Printf("Failed to close database: %v", err)
+		}
+	}()
+	event, err := db.GetEventByID(eventID)
+	if err != nil {
+		log.Fatalf("Failed to get event %s: %v", eventID, err)
+	}
+	scope, err := db.GetLegalHoldScope()
+	if err != nil {
+		log.Fatalf("Failed to read holds: %v", err)
+	}
+	if scope.Covers(event) {
+		fmt.Printf("Event %s is under legal hold\n", eventID)
+		return
+	}
+	fmt.Printf("Event %s is not under legal hold\n", eventID)
+}
+
+// caseHold returns the hold on a case, if any.
+func caseHold(db *store.DB, caseID string) *models.LegalHold {
+	holds, err := db.GetLegalHolds()
+	if err != nil {
+		log.Fatalf("Failed to read holds: %v", err)
+	}
+	for i := 0; i < len(holds); i++ {
+		if holds[i].Kind == models.HoldKindCase && holds[i].Ref == caseID {
+			return &holds[i]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+package commands
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/slyt3/Logryph/internal/metrics"
+)
+
+// ObservabilityCommand generates monitoring assets from the metrics registry:
+// logyctl observability export --grafana [--dir DIR]
+func ObservabilityCommand(args []string) {
+	if len(args) < 1 || args[0] != "export" {
+		fmt.Println("Usage: logyctl observability export --grafana [--dir DIR]")
+		os.Exit(1)
+	}
+	fs := flag.NewFlagSet("observability export", flag.ExitOnError)
+	grafana := fs.Bool("grafana", false, "Write a Grafana dashboard and Prometheus alert rules")
+	dir := fs.String("dir", ".", "Output directory")
+	_ = fs.Parse(args[1:])
+	if !*grafana {
+		fmt.Println("Nothing to export. Usage: logyctl observability export --grafana [--dir DIR]")
+		os.Exit(1)
+	}
+	if err := os.MkdirAll(*dir, 0o755); err != nil {
+		log.Fatalf("Failed to create %s: %v", *dir, err)
+	}
+
+	dashboard, err := metrics.GrafanaDashboard()
+	if err != nil {
+		log.Fatalf("Failed to build dashboard: %v", err)
+	}
+	rules, err := metrics.PrometheusAlertRules()
+	if err != nil {
+		log.Fatalf("Failed to build alert rules: %v", err)
+	}
+	files := []struct {
+		name string
+		data []byte
+	}{
+		{"logryph-grafana-dashboard.json", dashboard},
+		{"logryph-alerts.yml", rules},
+	}
+	for i := 0; i < len(files); i++ {
+		path := filepath.Join(*dir, files[i].name)
+		if err := os.WriteFile(path, files[i].data, 0o644); err != nil {
+			log.Fatalf("Failed to write %s: %v", path, err)
+		}
+		fmt.Printf("[OK] Wrote %s\n", path)
+	}
+	fmt.Printf("Import the dashboard in Grafana and add the rules file to Prometheus' rule_files (%d metrics, %d alerts).\n",
+		len(metrics.All), len(metrics.Alerts))
+}
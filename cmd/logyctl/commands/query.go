@@ -0,0 +1,140 @@
+package commands
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/slyt3/Logryph/internal/ledger/store"
+	"github.com/slyt3/Logryph/internal/models"
+	"github.com/slyt3/Logryph/internal/vql"
+)
+
+// QueryCommand manages saved event queries, which logyctl and scheduled reports run by
+// name:
+//
+//	logyctl query save <name> <expr>
+//	logyctl query list
+//	logyctl query run <name> [--limit N]
+//	logyctl query delete <name>
+func QueryCommand(args []string) {
+	if len(args) < 1 {
+		printQueryUsage()
+		os.Exit(1)
+	}
+	fs := flag.NewFlagSet("query", flag.ExitOnError)
+	limit := fs.Int("limit", 10, "Number of matching events to show for run (0 for all)")
+	sub := args[0]
+	args = parseInterspersed(fs, args[1:])
+
+	db, err := store.NewDB("logryph.db")
+	if err != nil {
+		log.Fatalf("Failed to open database: %v", err)
+	}
+	defer func() {
+		if err := db.Close(); err != nil {
+			log.Printf("Failed to close database: %v", err)
+		}
+	}()
+
+	switch {
+	case sub == "save" && len(args) == 2:
+		if _, err := vql.CompileQuery(args[1]); err != nil {
+			log.Fatalf("Invalid query: %v", err)
+		}
+		if err := db.SaveQuery(models.SavedQuery{Name: args[0], Expr: args[1], UpdatedAt: time.Now()}); err != nil {
+			log.Fatalf("Failed to save query: %v", err)
+		}
+		fmt.Printf("[OK] Query %q saved\n", args[0])
+	case sub == "list" && len(args) == 0:
+		listQueries(db)
+	case sub == "run" && len(args) == 1:
+		runQuery(db, args[0], *limit)
+	case sub == "delete" && len(args) == 1:
+		if err := db.DeleteSavedQuery(args[0]); err != nil {
+			log.Fatalf("Failed to delete query: %v", err)
+		}
+		fmt.Printf("[OK] Query %q deleted\n", args[0])
+	default:
+		printQueryUsage()
+		os.Exit(1)
+	}
+}
+
+func printQueryUsage() {
+	fmt.Println("Usage:")
+	fmt.Println("  logyctl query save <name> <expr>")
+	fmt.Println("  logyctl query list")
+	fmt.Println("  logyctl query run <name> [--limit N]")
+	fmt.Println("  logyctl query delete <name>")
+}
+
+func listQueries(db *store.DB) {
+	queries, err := db.ListSavedQueries()
+	if err != nil {
+		log.Fatalf("Failed to list queries: %v", err)
+	}
+	if len(queries) == 0 {
+		fmt.Println("No saved queries")
+		return
+	}
+	width := 0
+	for i := 0; i < len(queries); i++ {
+		if n := len(queries[i].Name); n > width {
+			width = n
+		}
+	}
+	for i := 0; i < len(queries); i++ {
+		q := queries[i]
+		fmt.Printf("%-*s  %s  %s\n", width, q.Name, q.UpdatedAt.Format("2006-01-02 15:04"), strings.TrimSpace(q.Expr))
+	}
+}
+
+// runQuery prints the newest matches of a saved query in the current run, newest first
+// like logyctl events.
+func runQuery(db *store.DB, name string, limit int) {
+	q, err := db.GetSavedQuery(name)
+	if errors.Is(err, store.ErrQueryNotFound) {
+		fmt.Printf("No saved query named %q\n", name)
+		os.Exit(1)
+	}
+	if err != nil {
+		log.Fatalf("Failed to get query: %v", err)
+	}
+	expr, err := vql.CompileQuery(q.Expr)
+	if err != nil {
+		log.Fatalf("Saved query %q no longer compiles: %v", name, err)
+	}
+	runID, err := db.GetRunID()
+	if err != nil {
+		log.Fatalf("Failed to get run ID: %v", err)
+	}
+	if runID == "" {
+		fmt.Println("No runs found in database")
+		return
+	}
+	events, err := db.GetAllEvents(runID)
+	if err != nil {
+		log.Fatalf("Failed to get events: %v", err)
+	}
+	events = expr.Filter(events, 0)
+	total := len(events)
+	if limit > 0 && total > limit {
+		events = events[total-limit:]
+	}
+	reverseEvents(events)
+
+	fmt.Printf("%s: %d matching events (showing %d)\n", name, total, len(events))
+	fmt.Println("===========================")
+	for i := 0; i < len(events); i++ {
+		e := events[i]
+		fmt.Printf("[%d] %s | %s | %s\n", e.SeqIndex, e.ID[:8], e.EventType, e.Method)
+		if e.WasBlocked {
+			fmt.Print("    BLOCKED\n")
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package commands
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/slyt3/Logryph/internal/transparent"
+)
+
+const redirectUsage = "Usage: logyctl redirect print|install|remove --ports 8080[,3000] [--proxy-port 9999] [--uid N | --exclude-uid N] [--mode redirect|tproxy] [--nft]"
+
+// RedirectCommand manages the firewall rules that steer agent traffic through the
+// proxy running with --transparent:
+// logyctl redirect print|install|remove --ports LIST [flags]
+func RedirectCommand(args []string) {
+	if len(args) < 1 {
+		fmt.Println(redirectUsage)
+		os.Exit(1)
+	}
+	action := args[0]
+	fs := flag.NewFlagSet("redirect", flag.ExitOnError)
+	ports := fs.String("ports", "", "Comma-separated tool server ports to divert")
+	proxyPort := fs.Int("proxy-port", 9999, "Port the proxy listens on")
+	uid := fs.Int("uid", -1, "redirect: only divert connections made by this user (the agent)")
+	excludeUID := fs.Int("exclude-uid", -1, "redirect: divert every user's connections except this one (the proxy)")
+	mode := fs.String("mode", transparent.ModeRedirect, "redirect (local agents) or tproxy (routed traffic)")
+	nft := fs.Bool("nft", false, "Use nftables instead of iptables")
+	_ = fs.Parse(args[1:])
+
+	portList, err := parsePorts(*ports)
+	if err != nil {
+		log.Fatalf("Invalid --ports: %v", err)
+	}
+	rules := transparent.Rules{Mode: *mode, ProxyPort: *proxyPort, Ports: portList, UID: *uid, ExcludeUID: *excludeUID, Nftables: *nft}
+	install, err := rules.Install()
+	if err != nil {
+		log.Fatalf("Invalid rules: %v", err)
+	}
+
+	switch action {
+	case "print":
+		fmt.Println("# install")
+		printCommands(install)
+		fmt.Println("# remove")
+		printCommands(rules.Remove())
+	case "install":
+		requireLinux()
+		if err := transparent.Run(install); err != nil {
+			_ = transparent.RunAll(rules.Remove())
+			log.Fatalf("Installing rules failed (rolled back): %v", err)
+		}
+		fmt.Printf("[OK] Ports %s now go to the proxy on :%d. Start it with --transparent %s.\n", *ports, *proxyPort, *mode)
+	case "remove":
+		requireLinux()
+		if err := transparent.RunAll(rules.Remove()); err != nil {
+			log.Fatalf("Some rules could not be removed: %v", err)
+		}
+		fmt.Println("[OK] Redirect rules removed")
+	default:
+		fmt.Println(redirectUsage)
+		os.Exit(1)
+	}
+}
+
+func parsePorts(list string) ([]int, error) {
+	var ports []int
+	for _, field := range strings.Split(list, ",") {
+		if field = strings.TrimSpace(field); field == "" {
+			continue
+		}
+		p, err := strconv.Atoi(field)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not a port", field)
+		}
+		ports = append(ports, p)
+	}
+	return ports, nil
+}
+
+func printCommands(cmds []transparent.Command) {
+	for i := 0; i < len(cmds); i++ {
+		fmt.Println(cmds[i].String())
+	}
+}
+
+func requireLinux() {
+	if runtime.GOOS != "linux" {
+		log.Fatalf("Redirect rules can only be installed on Linux; use print to see them")
+	}
+}
@@ -13,16 +13,16 @@ import (
 	"github.com/slyt3/Logryph/internal/ledger/store"
 )
 
-func ReplayCommand() {
-	if len(os.Args) < 3 {
-		fmt.Println("Usage: logyctl replay <event-id> [--target http://localhost:8080]")
+func ReplayCommand(args []string) {
+	if len(args) < 1 {
+		fmt.Println("Usage: logyctl replay <event-id> [--target http://localhost:8080|unix:/path]")
 		os.Exit(1)
 	}
-	eventID := os.Args[2]
+	eventID := args[0]
 	targetURL := "http://localhost:8080" // Default for many MCP setups
 
-	if len(os.Args) >= 5 && os.Args[3] == "--target" {
-		targetURL = os.Args[4]
+	if len(args) >= 3 && args[1] == "--target" {
+		targetURL = args[2]
 	}
 
 	db, err := store.NewDB("logryph.db")
@@ -50,7 +50,8 @@ func ReplayCommand() {
 
 	// 1. Prepare Request
 	reqBody, _ := json.Marshal(event.Params)
-	req, err := http.NewRequest("POST", targetURL, bytes.NewBuffer(reqBody))
+	baseURL, client := httpEndpoint(targetURL, 0)
+	req, err := http.NewRequest("POST", baseURL, bytes.NewBuffer(reqBody))
 	if err != nil {
 		log.Fatalf("Failed to create request: %v", err)
 	}
@@ -58,7 +59,6 @@ func ReplayCommand() {
 	req.Header.Set("X-Logryph-Replay", event.ID)
 
 	// 2. Execute
-	client := &http.Client{}
 	start := time.Now()
 	resp, err := client.Do(req)
 	if err != nil {
@@ -72,7 +72,7 @@ func ReplayCommand() {
 	duration := time.Since(start)
 
 	body, _ := io.ReadAll(resp.Body)
-	var newResp map[string]interface{}
+	var newResp interface{}
 	if err := json.Unmarshal(body, &newResp); err != nil {
 		log.Fatalf("Failed to decode replay response: %v", err)
 	}
@@ -83,7 +83,11 @@ func ReplayCommand() {
 	fmt.Println()
 
 	fmt.Println("Original Response:")
-	origPretty, _ := json.MarshalIndent(event.Response, "", "  ")
+	var orig interface{} = event.Response
+	if event.ResponseValue != nil {
+		orig = event.ResponseValue
+	}
+	origPretty, _ := json.MarshalIndent(orig, "", "  ")
 	fmt.Println(string(origPretty))
 	fmt.Println()
 
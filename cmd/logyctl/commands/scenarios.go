@@ -0,0 +1,136 @@
+package commands
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/slyt3/Logryph/internal/models"
+	"github.com/slyt3/Logryph/internal/simulate"
+)
+
+// ScenariosCommand runs scripted rogue-agent scenarios through the proxy and checks the
+// ledger recorded what the policy should have caught:
+// logyctl scenarios list
+// logyctl scenarios run [name...] [--target URL] [--mock-upstream addr] [--settle D] [--json]
+func ScenariosCommand(args []string) {
+	if len(args) < 1 {
+		fmt.Println("Usage: logyctl scenarios list | run [name...] [--target URL] [--mock-upstream addr] [--settle 10s] [--json]")
+		os.Exit(1)
+	}
+	switch args[0] {
+	case "list":
+		for _, sc := range simulate.Scenarios() {
+			fmt.Printf("%-22s %d steps  %s\n", sc.Name, len(sc.Steps), sc.Description)
+		}
+	case "run":
+		runScenarios(args[1:])
+	default:
+		log.Fatalf("unknown scenarios subcommand %q (want list or run)", args[0])
+	}
+}
+
+func runScenarios(args []string) {
+	fs := flag.NewFlagSet("scenarios run", flag.ExitOnError)
+	target := fs.String("target", "localhost:9999", "Proxy address: a URL, host:port or unix:/path")
+	timeout := fs.Duration("timeout", 30*time.Second, "Per-call timeout; calls stalled for approval wait this long")
+	settle := fs.Duration("settle", 10*time.Second, "How long to wait for the ledger to record every call")
+	mockAddr := fs.String("mock-upstream", "", "Also serve a stand-in tool server here (point serve --target at it)")
+	asJSON := fs.Bool("json", false, "Print the results as JSON")
+	names := parseInterspersed(fs, args)
+
+	scenarios, err := simulate.SelectScenarios(names)
+	if err != nil {
+		log.Fatalf("scenarios: %v", err)
+	}
+	if *mockAddr != "" {
+		stop := serveMockUpstream(*mockAddr)
+		defer stop()
+	}
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+	baseURL, client := httpEndpoint(*target, *timeout)
+	opts := simulate.ScenarioOptions{Target: baseURL, Client: client, Fetch: fetchTaskEvents, Settle: *settle}
+
+	var results []*simulate.ScenarioResult
+	failed := 0
+	for _, sc := range scenarios {
+		fmt.Fprintf(os.Stderr, "Running %s against %s\n", sc.Name, baseURL)
+		res, err := simulate.RunScenario(ctx, sc, opts)
+		if err != nil {
+			log.Fatalf("scenario %s: %v", sc.Name, err)
+		}
+		if !res.Pass {
+			failed++
+		}
+		results = append(results, res)
+	}
+	if *asJSON {
+		out, err := json.MarshalIndent(results, "", "  ")
+		if err != nil {
+			log.Fatalf("Failed to encode results: %v", err)
+		}
+		fmt.Println(string(out))
+	} else {
+		for _, res := range results {
+			printScenarioResult(res)
+		}
+		fmt.Printf("%d of %d scenarios passed\n", len(results)-failed, len(results))
+	}
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
+
+// fetchTaskEvents reads a task's events from the current run through the admin API.
+func fetchTaskEvents(taskID string) ([]models.Event, error) {
+	q := url.Values{"q": {"task_id = " + strconv.Quote(taskID)}, "limit": {"1000"}}
+	status, body, err := adminRequest(http.MethodGet, "/api/events?"+q.Encode(), nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	if status != http.StatusOK {
+		return nil, fmt.Errorf("admin API returned %d: %s", status, strings.TrimSpace(string(body)))
+	}
+	var events []models.Event
+	if err := json.Unmarshal(body, &events); err != nil {
+		return nil, fmt.Errorf("decoding events: %w", err)
+	}
+	return events, nil
+}
+
+func printScenarioResult(res *simulate.ScenarioResult) {
+	verdict := "PASS"
+	if !res.Pass {
+		verdict = "FAIL"
+	}
+	fmt.Printf("[%s] %s (task %s)\n", verdict, res.Name, res.TaskID)
+	for _, step := range res.Steps {
+		mark := "ok  "
+		if !step.Pass {
+			mark = "FAIL"
+		}
+		fmt.Printf("  %s %-26s %-28s recorded %d/%d  risk %-8s tags %s\n", mark, step.Name, step.Method,
+			step.Recorded, step.Sent, orDash(step.Risk), orDash(strings.Join(step.Tags, ",")))
+		for _, f := range step.Failures {
+			fmt.Printf("       expected: %s\n", f)
+		}
+	}
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
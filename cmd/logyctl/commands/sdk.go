@@ -0,0 +1,106 @@
+package commands
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/slyt3/Logryph/internal/interceptor"
+)
+
+const sdkUsage = "Usage: logyctl sdk snippet [--lang python|typescript|go|curl] [--proxy URL]"
+
+// sdkSnippets are helpers agents paste in to tag their calls with the task hierarchy
+// headers when they cannot add task_id to JSON-RPC params. {{TASK}}, {{PARENT}} and
+// {{PROXY}} are filled in by SDKCommand.
+var sdkSnippets = map[string]string{
+	"python": `import requests
+
+LOGRYPH_PROXY = "{{PROXY}}"
+
+
+def logryph_call(method, params, task_id, parent_event=None, rpc_id=1):
+    """Send a JSON-RPC call through Logryph, tagged with its task."""
+    headers = {"{{TASK}}": task_id}
+    if parent_event:
+        headers["{{PARENT}}"] = parent_event
+    body = {"jsonrpc": "2.0", "id": rpc_id, "method": method, "params": params}
+    resp = requests.post(LOGRYPH_PROXY, json=body, headers=headers)
+    resp.raise_for_status()
+    return resp.json()
+`,
+	"typescript": `const LOGRYPH_PROXY = "{{PROXY}}";
+
+// Send a JSON-RPC call through Logryph, tagged with its task.
+export async function logryphCall(method: string, params: object, taskId: string, parentEvent?: string, id = 1) {
+  const headers: Record<string, string> = { "Content-Type": "application/json", "{{TASK}}": taskId };
+  if (parentEvent) headers["{{PARENT}}"] = parentEvent;
+  const resp = await fetch(LOGRYPH_PROXY, {
+    method: "POST",
+    headers,
+    body: JSON.stringify({ jsonrpc: "2.0", id, method, params }),
+  });
+  if (!resp.ok) throw new Error(` + "`logryph: HTTP ${resp.status}`" + `);
+  return resp.json();
+}
+`,
+	"go": `// Point the agent's HTTP client at {{PROXY}} and wrap its transport:
+//
+//	client := &http.Client{Transport: &logryphTask{Base: http.DefaultTransport, TaskID: "task-1"}}
+type logryphTask struct {
+	Base        http.RoundTripper
+	TaskID      string
+	ParentEvent string // optional
+}
+
+func (t *logryphTask) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.Header.Set("{{TASK}}", t.TaskID)
+	if t.ParentEvent != "" {
+		req.Header.Set("{{PARENT}}", t.ParentEvent)
+	}
+	return t.Base.RoundTrip(req)
+}
+`,
+	"curl": `curl -sS {{PROXY}} \
+  -H 'Content-Type: application/json' \
+  -H '{{TASK}}: task-1' \
+  -H '{{PARENT}}: <event-id>' \
+  -d '{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{}}'
+`,
+}
+
+// SDKCommand prints client helpers for the task hierarchy headers:
+// logyctl sdk snippet [--lang LANG] [--proxy URL]
+func SDKCommand(args []string) {
+	if len(args) < 1 || args[0] != "snippet" {
+		fmt.Println(sdkUsage)
+		os.Exit(1)
+	}
+	fs := flag.NewFlagSet("sdk snippet", flag.ExitOnError)
+	lang := fs.String("lang", "python", "Snippet language: "+strings.Join(sdkLanguages(), ", "))
+	proxy := fs.String("proxy", "http://localhost:9999", "Proxy URL the agent sends calls to")
+	_ = fs.Parse(args[1:])
+
+	snippet, ok := sdkSnippets[*lang]
+	if !ok {
+		fmt.Printf("Unknown language %q\n%s\n", *lang, sdkUsage)
+		os.Exit(1)
+	}
+	fmt.Print(strings.NewReplacer(
+		"{{TASK}}", interceptor.TaskIDHeader,
+		"{{PARENT}}", interceptor.ParentEventHeader,
+		"{{PROXY}}", *proxy,
+	).Replace(snippet))
+}
+
+func sdkLanguages() []string {
+	langs := make([]string, 0, len(sdkSnippets))
+	for lang := range sdkSnippets {
+		langs = append(langs, lang)
+	}
+	sort.Strings(langs)
+	return langs
+}
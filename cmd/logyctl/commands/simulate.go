@@ -0,0 +1,106 @@
+package commands
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/slyt3/Logryph/internal/simulate"
+)
+
+// SimulateCommand sends synthetic agent traffic through the proxy:
+// logyctl simulate [--profile mixed-risk] [--seed N] [--calls N | --duration D] [--rate R]
+// [--concurrency N] [--target URL] [--mock-upstream addr] [--json]
+func SimulateCommand(args []string) {
+	fs := flag.NewFlagSet("simulate", flag.ExitOnError)
+	profile := fs.String("profile", simulate.ProfileMixedRisk, "Traffic profile: "+strings.Join(simulate.Profiles(), ", "))
+	seed := fs.Int64("seed", 1, "Seed; the same seed, profile and rate replay the same calls")
+	calls := fs.Int("calls", 0, "Calls to send (default 200 when --duration is not set)")
+	duration := fs.Duration("duration", 0, "Run for this long, for soak tests")
+	rate := fs.Float64("rate", 20, "Average calls per second")
+	concurrency := fs.Int("concurrency", 16, "Calls in flight at once")
+	target := fs.String("target", "localhost:9999", "Proxy address: a URL, host:port or unix:/path")
+	timeout := fs.Duration("timeout", 30*time.Second, "Per-call timeout; stalled calls wait this long")
+	mockAddr := fs.String("mock-upstream", "", "Also serve a stand-in tool server here (point serve --target at it)")
+	asJSON := fs.Bool("json", false, "Print the report as JSON")
+	_ = fs.Parse(args)
+	if *calls == 0 && *duration == 0 {
+		*calls = 200
+	}
+
+	g, err := simulate.NewGenerator(*profile, *seed, *rate)
+	if err != nil {
+		log.Fatalf("simulate: %v", err)
+	}
+	if *mockAddr != "" {
+		stop := serveMockUpstream(*mockAddr)
+		defer stop()
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+	baseURL, client := httpEndpoint(*target, *timeout)
+	fmt.Fprintf(os.Stderr, "Simulating %s traffic against %s (seed %d, %.4g calls/s)\n", *profile, baseURL, *seed, *rate)
+	rep, err := simulate.Run(ctx, g, simulate.Options{
+		Target: baseURL, Client: client, Calls: *calls, Duration: *duration, Concurrency: *concurrency,
+	})
+	if err != nil {
+		log.Fatalf("simulate: %v", err)
+	}
+	if *asJSON {
+		out, err := json.MarshalIndent(rep, "", "  ")
+		if err != nil {
+			log.Fatalf("Failed to encode report: %v", err)
+		}
+		fmt.Println(string(out))
+		return
+	}
+	printSimulateReport(rep)
+}
+
+// serveMockUpstream serves simulate.Upstream on addr and returns a function that stops it.
+func serveMockUpstream(addr string) func() {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		log.Fatalf("mock upstream: %v", err)
+	}
+	srv := &http.Server{Handler: simulate.Upstream(), ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		if err := srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Printf("mock upstream: %v", err)
+		}
+	}()
+	fmt.Fprintf(os.Stderr, "Mock upstream listening on %s\n", ln.Addr())
+	return func() { _ = srv.Close() }
+}
+
+func printSimulateReport(rep *simulate.Report) {
+	fmt.Printf("Profile:    %s (seed %d)\n", rep.Profile, rep.Seed)
+	fmt.Printf("Sent:       %d in %.1fs (%.1f calls/s)\n", rep.Sent, rep.ElapsedS, rep.RatePerS)
+	fmt.Printf("OK:         %d\n", rep.OK)
+	fmt.Printf("RPC errors: %d\n", rep.RPCErrors)
+	statuses := make([]string, 0, len(rep.HTTPErrors))
+	for status := range rep.HTTPErrors {
+		statuses = append(statuses, status)
+	}
+	sort.Strings(statuses)
+	for _, status := range statuses {
+		fmt.Printf("HTTP %s:   %d\n", status, rep.HTTPErrors[status])
+	}
+	fmt.Printf("No reply:   %d\n", rep.Transport)
+	if rep.Late > 0 {
+		fmt.Printf("Late:       %d (raise --concurrency to hold the rate)\n", rep.Late)
+	}
+	fmt.Printf("Latency:    p50 %.1fms  p95 %.1fms  p99 %.1fms  max %.1fms\n", rep.P50MS, rep.P95MS, rep.P99MS, rep.MaxMS)
+}
@@ -1,18 +1,33 @@
 package commands
 
 import (
+	"encoding/json"
+	"flag"
 	"fmt"
-	"io"
 	"log"
 	"net/http"
 	"os"
 	"path/filepath"
 	"time"
 
+	"github.com/slyt3/Logryph/internal/api"
 	"github.com/slyt3/Logryph/internal/ledger/store"
 )
 
-func StatusCommand() {
+// StatusCommand shows the current run and, with --live, the running proxy's state from the
+// admin API.
+func StatusCommand(args []string) {
+	fs := flag.NewFlagSet("status", flag.ExitOnError)
+	live := fs.Bool("live", false, "Also show worker health, queue, drops and policy from the running proxy")
+	_ = fs.Parse(args)
+
+	printRunStatus()
+	if *live {
+		printLiveStatus()
+	}
+}
+
+func printRunStatus() {
 	// Open database
 	db, err := store.NewDB("logryph.db")
 	if err != nil {
@@ -47,22 +62,66 @@ func StatusCommand() {
 	fmt.Printf("Agent:        %s\n", agentName)
 	fmt.Printf("Genesis Hash: %s\n", genesisHash[:16]+"...")
 	fmt.Printf("Public Key:   %s\n", pubKey[:32]+"...")
+	id, err := db.GetRunIdentity(runID)
+	if err != nil {
+		log.Printf("Failed to read genesis metadata: %v", err)
+		return
+	}
+	if id.Operator != "" {
+		fmt.Printf("Operator:     %s\n", id.Operator)
+	}
+	if id.Environment != "" {
+		fmt.Printf("Environment:  %s\n", id.Environment)
+	}
+	if len(id.Labels) > 0 {
+		fmt.Printf("Labels:       %s\n", formatLabels(id.Labels))
+	}
 }
 
-func RekeyCommand() {
-	resp, err := http.Post("http://localhost:9998/api/rekey", "application/json", nil)
+func printLiveStatus() {
+	status, body, err := adminRequest(http.MethodGet, "/api/status", nil, nil)
 	if err != nil {
-		log.Fatalf("Failed to contact Logryph API: %v", err)
+		log.Fatalf("Failed to reach the proxy at %s: %v", adminAddr(), err)
 	}
-	defer func() {
-		if err := resp.Body.Close(); err != nil {
-			log.Printf("Failed to close rekey response: %v", err)
-		}
-	}()
+	if status != http.StatusOK {
+		log.Fatalf("Status request failed (%d): %s", status, string(body))
+	}
+	var st api.LiveStatus
+	if err := json.Unmarshal(body, &st); err != nil {
+		log.Fatalf("Invalid status response: %v", err)
+	}
+
+	health := "healthy"
+	if !st.Healthy {
+		health = "UNHEALTHY: " + st.UnhealthyReason
+	}
+	if st.LedgerGuarantee != "" {
+		health += ", ledger guarantee " + st.LedgerGuarantee
+	}
+	anchor := "none since startup"
+	if st.LastAnchor != nil {
+		anchor = fmt.Sprintf("%s (%s ago)", st.LastAnchor.Format(time.RFC3339), time.Since(*st.LastAnchor).Round(time.Second))
+	}
+	fmt.Println()
+	fmt.Println("Live Proxy Status")
+	fmt.Println("=================")
+	fmt.Printf("Worker:       %s (up %s)\n", health, st.Uptime)
+	fmt.Printf("Queue:        %d / %d (backpressure %s)\n", st.QueueDepth, st.QueueCapacity, st.Backpressure)
+	fmt.Printf("Events:       %d processed, %d dropped since start\n", st.Processed, st.Dropped)
+	fmt.Printf("Last Seq:     %d\n", st.LastSeq)
+	fmt.Printf("Last Anchor:  %s\n", anchor)
+	fmt.Printf("Policy:       version %q, %s mode, environment %q\n", st.PolicyVersion, st.EnforcementMode, st.Environment)
+	fmt.Printf("Approvals:    %d pending\n", st.PendingApprovals)
+}
 
-	body, err := io.ReadAll(resp.Body)
+func RekeyCommand() {
+	status, body, err := adminRequest(http.MethodPost, "/api/rekey", nil, nil)
 	if err != nil {
-		fmt.Printf("Error: Failed to read response body: %v\n", err)
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	if status != http.StatusOK {
+		fmt.Printf("Error (%d): %s", status, string(body))
 		os.Exit(1)
 	}
 	fmt.Println(string(body))
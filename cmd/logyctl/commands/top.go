@@ -0,0 +1,237 @@
+package commands
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/slyt3/Logryph/internal/approval"
+	"github.com/slyt3/Logryph/internal/ledger/store"
+	"github.com/slyt3/Logryph/internal/models"
+)
+
+const (
+	maxTopTicks       = 1 << 30
+	maxTopEvents      = 5000
+	maxTopMethods     = 10
+	maxTopRiskEvents  = 8
+	maxTopPending     = 8
+	maxMetricLines    = 4096
+	ansiClearHome     = "\033[H\033[2J"
+	ansiHideCursor    = "\033[?25l"
+	ansiShowCursor    = "\033[?25h"
+	topTimestampStyle = "15:04:05"
+)
+
+// topSnapshot is one refresh worth of data. Ledger data comes from logryph.db; live proxy
+// state (queue, drops, tasks, approvals) from the admin API, which may be unreachable.
+type topSnapshot struct {
+	at        time.Time
+	runID     string
+	events    []models.Event // newest first
+	metrics   map[string]float64
+	pending   []approval.Request
+	apiErr    error
+	ledgerErr error
+}
+
+// TopCommand shows a live view of agent activity, refreshed in place:
+// logyctl top [--interval 2s] [--window 1m] [--once]
+func TopCommand(args []string) {
+	fs := flag.NewFlagSet("top", flag.ExitOnError)
+	interval := fs.Duration("interval", 2*time.Second, "Refresh interval")
+	window := fs.Duration("window", time.Minute, "Window for call rates")
+	once := fs.Bool("once", false, "Print a single frame and exit")
+	_ = fs.Parse(args)
+	if *interval < 200*time.Millisecond || *window <= 0 {
+		log.Fatalf("interval must be at least 200ms and window positive")
+	}
+
+	db, err := store.NewDB("logryph.db")
+	if err != nil {
+		log.Fatalf("Failed to open database: %v", err)
+	}
+	defer func() {
+		if err := db.Close(); err != nil {
+			log.Printf("Failed to close database: %v", err)
+		}
+	}()
+
+	if *once {
+		fmt.Print(renderTop(collectTop(db), nil, *window))
+		return
+	}
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(stop)
+	fmt.Print(ansiHideCursor)
+	defer fmt.Print(ansiShowCursor)
+
+	ticker := time.NewTicker(*interval)
+	defer ticker.Stop()
+	var prev *topSnapshot
+	for i := 0; i < maxTopTicks; i++ {
+		snap := collectTop(db)
+		fmt.Print(ansiClearHome + renderTop(snap, prev, *window))
+		prev = &snap
+		select {
+		case <-stop:
+			fmt.Println()
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func collectTop(db *store.DB) topSnapshot {
+	snap := topSnapshot{at: time.Now()}
+	snap.runID, snap.ledgerErr = db.GetRunID()
+	if snap.ledgerErr == nil && snap.runID != "" {
+		snap.events, snap.ledgerErr = db.GetRecentEvents(snap.runID, maxTopEvents)
+	}
+
+	status, body, err := adminRequest(http.MethodGet, "/metrics", nil, nil)
+	if err == nil && status != http.StatusOK {
+		err = fmt.Errorf("metrics: HTTP %d", status)
+	}
+	if err != nil {
+		snap.apiErr = err
+		return snap
+	}
+	snap.metrics = parsePromText(body)
+
+	status, body, err = adminRequest(http.MethodGet, "/api/approvals", nil, nil)
+	if err == nil && status == http.StatusOK {
+		err = json.Unmarshal(body, &snap.pending)
+	}
+	if err != nil {
+		snap.apiErr = err
+	}
+	return snap
+}
+
+// parsePromText reads unlabeled samples from Prometheus text exposition format.
+func parsePromText(body []byte) map[string]float64 {
+	out := make(map[string]float64)
+	sc := bufio.NewScanner(bytes.NewReader(body))
+	for i := 0; i < maxMetricLines && sc.Scan(); i++ {
+		line := sc.Text()
+		if line == "" || line[0] == '#' || strings.Contains(line, "{") {
+			continue
+		}
+		name, value, ok := strings.Cut(line, " ")
+		if !ok {
+			continue
+		}
+		if v, err := strconv.ParseFloat(strings.TrimSpace(value), 64); err == nil {
+			out[name] = v
+		}
+	}
+	return out
+}
+
+func renderTop(snap topSnapshot, prev *topSnapshot, window time.Duration) string {
+	var b strings.Builder
+	run := snap.runID
+	if len(run) > 8 {
+		run = run[:8]
+	}
+	fmt.Fprintf(&b, "Logryph top  %s  run %s  (Ctrl-C to quit)\n\n", snap.at.Format(topTimestampStyle), run)
+	renderTopProxy(&b, snap, prev)
+	if snap.ledgerErr != nil {
+		fmt.Fprintf(&b, "\nLedger unavailable: %v\n", snap.ledgerErr)
+		return b.String()
+	}
+	renderTopRates(&b, snap, window)
+	renderTopRisk(&b, snap)
+	renderTopPending(&b, snap)
+	return b.String()
+}
+
+func renderTopProxy(b *strings.Builder, snap topSnapshot, prev *topSnapshot) {
+	if snap.metrics == nil {
+		fmt.Fprintf(b, "Proxy: unreachable (%v)\n", snap.apiErr)
+		return
+	}
+	m := snap.metrics
+	dropped := m["logryph_ledger_events_dropped_total"]
+	newDrops := 0.0
+	if prev != nil && prev.metrics != nil {
+		newDrops = dropped - prev.metrics["logryph_ledger_events_dropped_total"]
+	}
+	fmt.Fprintf(b, "Queue %.0f/%.0f   Active tasks %.0f   Processed %.0f   Dropped %.0f (+%.0f)   Pending approvals %d\n",
+		m["logryph_ledger_queue_depth"], m["logryph_ledger_queue_capacity"], m["logryph_engine_active_tasks_total"],
+		m["logryph_ledger_events_processed_total"], dropped, newDrops, len(snap.pending))
+}
+
+func renderTopRates(b *strings.Builder, snap topSnapshot, window time.Duration) {
+	counts := make(map[string]int)
+	cutoff := snap.at.Add(-window)
+	for i := 0; i < len(snap.events); i++ {
+		e := snap.events[i]
+		if e.Timestamp.Before(cutoff) {
+			break // newest first
+		}
+		if e.EventType == "tool_call" {
+			counts[e.Method]++
+		}
+	}
+	methods := make([]string, 0, len(counts))
+	for m := range counts {
+		methods = append(methods, m)
+	}
+	sort.Slice(methods, func(i, j int) bool {
+		if counts[methods[i]] != counts[methods[j]] {
+			return counts[methods[i]] > counts[methods[j]]
+		}
+		return methods[i] < methods[j]
+	})
+
+	fmt.Fprintf(b, "\nCalls per minute (last %s)\n", window)
+	if len(methods) == 0 {
+		b.WriteString("  (no calls)\n")
+	}
+	perMinute := float64(time.Minute) / float64(window)
+	for i := 0; i < len(methods) && i < maxTopMethods; i++ {
+		fmt.Fprintf(b, "  %-40s %8.1f\n", methods[i], float64(counts[methods[i]])*perMinute)
+	}
+}
+
+func renderTopRisk(b *strings.Builder, snap topSnapshot) {
+	b.WriteString("\nRecent high-risk events\n")
+	shown := 0
+	for i := 0; i < len(snap.events) && shown < maxTopRiskEvents; i++ {
+		e := snap.events[i]
+		if e.RiskLevel != "high" && e.RiskLevel != "critical" {
+			continue
+		}
+		fmt.Fprintf(b, "  %s  %-8s %-8s %-24s %s\n", e.Timestamp.Format(topTimestampStyle), e.RiskLevel, e.ID, e.EventType, e.Method)
+		shown++
+	}
+	if shown == 0 {
+		b.WriteString("  (none)\n")
+	}
+}
+
+func renderTopPending(b *strings.Builder, snap topSnapshot) {
+	if len(snap.pending) == 0 {
+		return
+	}
+	b.WriteString("\nAwaiting approval\n")
+	for i := 0; i < len(snap.pending) && i < maxTopPending; i++ {
+		p := snap.pending[i]
+		fmt.Fprintf(b, "  %-8s %-30s %-20s expires in %s\n", p.EventID, p.Method, p.PolicyID, time.Until(p.Deadline).Round(time.Second))
+	}
+}
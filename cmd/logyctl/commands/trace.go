@@ -12,7 +12,11 @@ import (
 	"github.com/slyt3/Logryph/internal/models"
 )
 
-func TraceCommand() {
+func TraceCommand(args []string) {
+	if len(args) >= 1 && args[0] == "--federated" {
+		FederatedTraceCommand(args[1:])
+		return
+	}
 	db, err := store.NewDB("logryph.db")
 	if err != nil {
 		log.Fatalf("Failed to open database: %v", err)
@@ -23,7 +27,7 @@ func TraceCommand() {
 		}
 	}()
 
-	if len(os.Args) < 3 {
+	if len(args) < 1 {
 		tasks, err := db.GetUniqueTasks()
 		if err != nil {
 			log.Fatalf("Failed to get tasks: %v", err)
@@ -51,10 +55,24 @@ func TraceCommand() {
 		fmt.Println("\nUsage: logyctl trace <task-id>")
 		return
 	}
-	taskID := os.Args[2]
+	if args[0] == "--interactive" {
+		if len(args) < 2 {
+			fmt.Println("Usage: logyctl trace --interactive <task-id> [--config logryph-policy.yaml]")
+			os.Exit(1)
+		}
+		configPath := "logryph-policy.yaml"
+		if len(args) >= 4 && args[2] == "--config" {
+			configPath = args[3]
+		}
+		if err := interactiveTrace(db, args[1], configPath); err != nil {
+			log.Fatalf("Interactive trace failed: %v", err)
+		}
+		return
+	}
+	taskID := args[0]
 	htmlOutput := ""
-	if len(os.Args) >= 5 && os.Args[3] == "--html" {
-		htmlOutput = os.Args[4]
+	if len(args) >= 3 && args[1] == "--html" {
+		htmlOutput = args[2]
 	}
 
 	events, err := db.GetEventsByTaskID(taskID)
@@ -158,17 +176,7 @@ func printTraceTree(roots []models.Event, childrenMap map[string][]models.Event,
 			marker = "`-- "
 		}
 
-		// Status icon
-		statusSym := "[ ]" // Default: Call
-		if e.EventType == "tool_response" {
-			statusSym = "[x]" // Response
-		}
-		if e.WasBlocked {
-			statusSym = "[X]" // Blocked
-		}
-		if e.RiskLevel == "critical" {
-			statusSym = "[!!]" // Critical
-		}
+		statusSym := eventMarker(e)
 
 		// Calculate delta from start
 		delta := e.Timestamp.Sub(startTime)
@@ -201,6 +209,37 @@ func printTraceTree(roots []models.Event, childrenMap map[string][]models.Event,
 		}
 	}
 }
+
+// eventMarker is the status icon shown for an event in trace timelines.
+func eventMarker(e models.Event) string {
+	statusSym := "[ ]" // Default: Call
+	if e.EventType == "tool_response" {
+		statusSym = "[x]" // Response
+	}
+	if e.EventType == "call_aborted" {
+		statusSym = "[-]" // Abandoned by the agent
+	}
+	if e.EventType == "call_retry" {
+		statusSym = "[~]" // Upstream retry
+	}
+	if e.EventType == "call_timeout" {
+		statusSym = "[T]" // Upstream timed out
+	}
+	if e.EventType == "upstream_error" {
+		statusSym = "[E]" // Upstream unreachable
+	}
+	if e.EventType == "call_throttled" {
+		statusSym = "[Q]" // Queued or refused by the concurrency cap
+	}
+	if e.WasBlocked {
+		statusSym = "[X]" // Blocked
+	}
+	if e.RiskLevel == "critical" {
+		statusSym = "[!!]" // Critical
+	}
+	return statusSym
+}
+
 func generateHTMLReport(taskID string, events []models.Event, outputPath string) error {
 	f, err := os.Create(outputPath)
 	if err != nil {
@@ -285,6 +324,10 @@ func generateHTMLReport(taskID string, events []models.Event, outputPath string)
 			if _, err := fmt.Fprintf(f, `        <div class="payload"><strong>Response:</strong> %v</div>`, formatPayload(e.Response)); err != nil {
 				return err
 			}
+		} else if e.ResponseValue != nil {
+			if _, err := fmt.Fprintf(f, `        <div class="payload"><strong>Response:</strong> %+v</div>`, e.ResponseValue); err != nil {
+				return err
+			}
 		}
 
 		if _, err := fmt.Fprintf(f, `    </div>`); err != nil {
@@ -0,0 +1,625 @@
+package commands
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/slyt3/Logryph/internal/api"
+	"github.com/slyt3/Logryph/internal/ledger/store"
+	"github.com/slyt3/Logryph/internal/models"
+)
+
+const (
+	maxTraceTUIKeys   = 1 << 30
+	maxDiffPaths      = 2000
+	maxDiffDepth      = 16
+	maxDiffNodes      = 1 << 16 // maps, lists and leaves visited per payload
+	maxDiffValueWidth = 80
+	maxNoteLength     = 4096
+	tuiDefaultRows    = 24
+	tuiDefaultCols    = 80
+	maxTraceTUIEvents = 100000
+)
+
+// Keys the interactive trace understands, decoded from raw terminal input.
+const (
+	keyNone = iota
+	keyUp
+	keyDown
+	keyLeft
+	keyRight
+	keyPageUp
+	keyPageDown
+	keyEnter
+	keyEscape
+	keyBackspace
+	keyRune
+	keyQuit
+)
+
+type tuiKey struct {
+	kind int
+	r    byte
+}
+
+// Detail pane modes.
+const (
+	paneSummary = iota
+	panePayload
+	paneDiff
+	paneExplain
+)
+
+// traceRow is one visible line of the tree.
+type traceRow struct {
+	id    string
+	depth int
+}
+
+// traceView is the state of an interactive trace: the task's events as a tree, which
+// nodes are expanded, the selected row and what the detail pane shows.
+type traceView struct {
+	taskID     string
+	events     map[string]*models.Event
+	children   map[string][]string
+	roots      []string
+	expanded   map[string]bool
+	cursor     int
+	pane       int
+	paneScroll int
+	paneText   []string
+	status     string
+	start      time.Time
+	explain    func(e *models.Event) string
+}
+
+// newTraceView builds the tree for a task's events. Events whose parent is not part of
+// the task are shown as roots, so nothing is hidden.
+func newTraceView(taskID string, events []models.Event) *traceView {
+	v := &traceView{
+		taskID:   taskID,
+		events:   make(map[string]*models.Event, len(events)),
+		children: make(map[string][]string),
+		expanded: make(map[string]bool),
+	}
+	for j := 0; j < len(events) && j < maxTraceTUIEvents; j++ {
+		v.events[events[j].ID] = &events[j]
+	}
+	for j := 0; j < len(events) && j < maxTraceTUIEvents; j++ {
+		e := &events[j]
+		if _, ok := v.events[e.ParentID]; e.ParentID != "" && ok {
+			v.children[e.ParentID] = append(v.children[e.ParentID], e.ID)
+		} else {
+			v.roots = append(v.roots, e.ID)
+		}
+	}
+	if len(events) > 0 {
+		v.start = events[0].Timestamp
+	}
+	return v
+}
+
+// rows flattens the expanded part of the tree in display order.
+func (v *traceView) rows() []traceRow {
+	out := make([]traceRow, 0, len(v.roots))
+	type frame struct {
+		id    string
+		depth int
+	}
+	stack := make([]frame, 0, len(v.roots))
+	for j := len(v.roots) - 1; j >= 0; j-- {
+		stack = append(stack, frame{v.roots[j], 0})
+	}
+	for n := 0; n < maxTraceTUIEvents && len(stack) > 0; n++ {
+		f := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		out = append(out, traceRow{id: f.id, depth: f.depth})
+		if !v.expanded[f.id] {
+			continue
+		}
+		kids := v.children[f.id]
+		for j := len(kids) - 1; j >= 0; j-- {
+			stack = append(stack, frame{kids[j], f.depth + 1})
+		}
+	}
+	return out
+}
+
+// selected returns the event under the cursor, or nil for an empty trace.
+func (v *traceView) selected() *models.Event {
+	rows := v.rows()
+	if len(rows) == 0 {
+		return nil
+	}
+	if v.cursor >= len(rows) {
+		v.cursor = len(rows) - 1
+	}
+	return v.events[rows[v.cursor].id]
+}
+
+// moveTo puts the cursor on id, expanding its ancestors so it is visible.
+func (v *traceView) moveTo(id string) bool {
+	for p, n := v.events[id], 0; p != nil && p.ParentID != "" && n < maxTraceTUIEvents; n++ {
+		if _, ok := v.events[p.ParentID]; !ok {
+			break
+		}
+		v.expanded[p.ParentID] = true
+		p = v.events[p.ParentID]
+	}
+	rows := v.rows()
+	for j := range rows {
+		if rows[j].id == id {
+			v.cursor = j
+			return true
+		}
+	}
+	return false
+}
+
+// setPane switches the detail pane and renders its text for the selected event.
+func (v *traceView) setPane(pane int) {
+	v.pane, v.paneScroll = pane, 0
+	e := v.selected()
+	if e == nil {
+		v.paneText = nil
+		return
+	}
+	var text string
+	switch pane {
+	case panePayload:
+		text = eventPayload(e)
+	case paneDiff:
+		text = v.callResponseDiff(e)
+	case paneExplain:
+		if v.explain != nil {
+			text = v.explain(e)
+		}
+	default:
+		text = v.summary(e)
+	}
+	v.paneText = strings.Split(strings.TrimRight(text, "\n"), "\n")
+}
+
+func (v *traceView) summary(e *models.Event) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s  %s  %s\n", e.ID, e.EventType, e.Method)
+	fmt.Fprintf(&b, "seq %d  +%v  actor %s  risk %s  policy %s\n", e.SeqIndex, e.Timestamp.Sub(v.start).Truncate(time.Millisecond), orDash(e.Actor), orDash(e.RiskLevel), orDash(e.PolicyID))
+	if len(e.Tags) > 0 {
+		fmt.Fprintf(&b, "tags %s\n", strings.Join(e.Tags, ", "))
+	}
+	if e.ParentID != "" {
+		fmt.Fprintf(&b, "parent %s\n", e.ParentID)
+	}
+	if n := len(v.children[e.ID]); n > 0 {
+		fmt.Fprintf(&b, "%d children\n", n)
+	}
+	return b.String()
+}
+
+// eventPayload is the event's params and result as indented JSON.
+func eventPayload(e *models.Event) string {
+	var b strings.Builder
+	write := func(name string, value interface{}) {
+		out, err := json.MarshalIndent(value, "", "  ")
+		if err != nil {
+			fmt.Fprintf(&b, "%s: %v\n", name, err)
+			return
+		}
+		fmt.Fprintf(&b, "%s:\n%s\n", name, out)
+	}
+	if len(e.Params) > 0 {
+		write("params", e.Params)
+	}
+	if len(e.Response) > 0 {
+		write("response", e.Response)
+	}
+	if e.ResponseValue != nil {
+		write("response_value", e.ResponseValue)
+	}
+	if b.Len() == 0 {
+		return "(no payload)\n"
+	}
+	return b.String()
+}
+
+// callResponseDiff compares a tool_call's params with its tool_response's result, path by
+// path: "-" only in the call, "+" only in the response, "~" in both with other values.
+func (v *traceView) callResponseDiff(e *models.Event) string {
+	call, resp := e, (*models.Event)(nil)
+	if e.EventType == "tool_response" {
+		call, resp = v.events[e.ParentID], e
+	} else {
+		for _, id := range v.children[e.ID] {
+			if c := v.events[id]; c.EventType == "tool_response" {
+				resp = c
+				break
+			}
+		}
+	}
+	if call == nil || resp == nil {
+		return "No call/response pair for this event.\n"
+	}
+	before := map[string]string{}
+	flattenJSON(call.Params, before)
+	after := map[string]string{}
+	if resp.ResponseValue != nil {
+		flattenJSON(resp.ResponseValue, after)
+	} else {
+		flattenJSON(resp.Response, after)
+	}
+	paths := make([]string, 0, len(before)+len(after))
+	for p := range before {
+		paths = append(paths, p)
+	}
+	for p := range after {
+		if _, ok := before[p]; !ok {
+			paths = append(paths, p)
+		}
+	}
+	sort.Strings(paths)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "call %s -> response %s\n", call.ID, resp.ID)
+	same := 0
+	for _, p := range paths {
+		a, inCall := before[p]
+		r, inResp := after[p]
+		switch {
+		case inCall && !inResp:
+			fmt.Fprintf(&b, "- %s: %s\n", p, a)
+		case !inCall && inResp:
+			fmt.Fprintf(&b, "+ %s: %s\n", p, r)
+		case a != r:
+			fmt.Fprintf(&b, "~ %s: %s -> %s\n", p, a, r)
+		default:
+			same++
+		}
+	}
+	if same > 0 {
+		fmt.Fprintf(&b, "(%d paths identical)\n", same)
+	}
+	return b.String()
+}
+
+// flattenJSON records each leaf of value under its dotted path, values shortened for
+// display. Depth and the number of paths are bounded.
+func flattenJSON(value interface{}, out map[string]string) {
+	type flatFrame struct {
+		path  string
+		value interface{}
+		depth int
+	}
+	stack := []flatFrame{{value: value}}
+	for n := 0; n < maxDiffNodes && len(stack) > 0 && len(out) < maxDiffPaths; n++ {
+		f := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if f.depth < maxDiffDepth {
+			switch t := f.value.(type) {
+			case map[string]interface{}:
+				for k, child := range t {
+					stack = append(stack, flatFrame{joinPath(f.path, k), child, f.depth + 1})
+				}
+				continue
+			case []interface{}:
+				for j, child := range t {
+					stack = append(stack, flatFrame{fmt.Sprintf("%s[%d]", f.path, j), child, f.depth + 1})
+				}
+				continue
+			}
+		}
+		raw, err := json.Marshal(f.value)
+		s := string(raw)
+		if err != nil {
+			s = fmt.Sprint(f.value)
+		}
+		if len(s) > maxDiffValueWidth {
+			s = s[:maxDiffValueWidth] + "..."
+		}
+		path := f.path
+		if path == "" {
+			path = "."
+		}
+		out[path] = s
+	}
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// render draws the tree above the detail pane in a rows x cols screen.
+func (v *traceView) render(rows, cols int) string {
+	var b strings.Builder
+	b.WriteString(ansiClearHome)
+	header := fmt.Sprintf("Trace %s - %d events   arrows: move/expand  enter: payload  d: diff  e: explain  a: annotate  p/c: parent/child  q: quit", v.taskID, len(v.events))
+	b.WriteString(clip(header, cols) + "\r\n")
+
+	paneRows := rows / 2
+	treeRows := rows - paneRows - 3
+	if treeRows < 3 {
+		treeRows = 3
+	}
+	visible := v.rows()
+	top := 0
+	if v.cursor >= treeRows {
+		top = v.cursor - treeRows + 1
+	}
+	for j := top; j < top+treeRows; j++ {
+		if j >= len(visible) {
+			b.WriteString("\r\n")
+			continue
+		}
+		r := visible[j]
+		e := v.events[r.id]
+		fold := "  "
+		if len(v.children[r.id]) > 0 {
+			fold = "+ "
+			if v.expanded[r.id] {
+				fold = "- "
+			}
+		}
+		line := fmt.Sprintf("%s%s%s %-15s [%s] (+%v)", strings.Repeat("  ", r.depth), fold, eventMarker(*e), e.Method, e.ID, e.Timestamp.Sub(v.start).Truncate(time.Millisecond))
+		line = clip(line, cols-2)
+		if j == v.cursor {
+			line = "\033[7m> " + line + "\033[0m"
+		} else {
+			line = "  " + line
+		}
+		b.WriteString(line + "\r\n")
+	}
+
+	b.WriteString(clip(strings.Repeat("-", cols), cols) + "\r\n")
+	for j := v.paneScroll; j < v.paneScroll+paneRows; j++ {
+		if j < len(v.paneText) {
+			b.WriteString(clip(v.paneText[j], cols))
+		}
+		b.WriteString("\r\n")
+	}
+	b.WriteString(clip(v.status, cols))
+	return b.String()
+}
+
+func clip(s string, width int) string {
+	if width <= 0 || len(s) <= width {
+		return s
+	}
+	return s[:width]
+}
+
+// handle applies one key; it returns false when the view should close. Keys that need
+// a prompt (annotate) are handled by the caller.
+func (v *traceView) handle(k tuiKey, paneRows int) bool {
+	v.status = ""
+	visible := v.rows()
+	cur := v.selected()
+	switch {
+	case k.kind == keyQuit || (k.kind == keyRune && k.r == 'q'):
+		return false
+	case k.kind == keyUp || (k.kind == keyRune && k.r == 'k'):
+		if v.cursor > 0 {
+			v.cursor--
+		}
+	case k.kind == keyDown || (k.kind == keyRune && k.r == 'j'):
+		if v.cursor < len(visible)-1 {
+			v.cursor++
+		}
+	case k.kind == keyRight || (k.kind == keyRune && k.r == 'l'):
+		if cur != nil && len(v.children[cur.ID]) > 0 {
+			if v.expanded[cur.ID] {
+				v.moveTo(v.children[cur.ID][0])
+			} else {
+				v.expanded[cur.ID] = true
+			}
+		}
+	case k.kind == keyLeft || (k.kind == keyRune && k.r == 'h'):
+		if cur != nil && v.expanded[cur.ID] {
+			v.expanded[cur.ID] = false
+		} else if cur != nil {
+			v.jumpParent(cur)
+		}
+	case k.kind == keyRune && k.r == 'p':
+		if cur != nil {
+			v.jumpParent(cur)
+		}
+	case k.kind == keyRune && k.r == 'c':
+		if cur != nil && len(v.children[cur.ID]) > 0 {
+			v.moveTo(v.children[cur.ID][0])
+		} else {
+			v.status = "no children"
+		}
+	case k.kind == keyPageDown:
+		if v.paneScroll+paneRows < len(v.paneText) {
+			v.paneScroll += paneRows
+		}
+		return true
+	case k.kind == keyPageUp:
+		v.paneScroll -= paneRows
+		if v.paneScroll < 0 {
+			v.paneScroll = 0
+		}
+		return true
+	case k.kind == keyEnter:
+		if v.pane == panePayload {
+			v.setPane(paneSummary)
+		} else {
+			v.setPane(panePayload)
+		}
+		return true
+	case k.kind == keyRune && k.r == 'd':
+		v.setPane(paneDiff)
+		return true
+	case k.kind == keyRune && k.r == 'e':
+		v.setPane(paneExplain)
+		return true
+	case k.kind == keyEscape:
+		v.setPane(paneSummary)
+		return true
+	}
+	// The selection may have changed: refresh the pane in its current mode.
+	v.setPane(v.pane)
+	return true
+}
+
+func (v *traceView) jumpParent(e *models.Event) {
+	if _, ok := v.events[e.ParentID]; e.ParentID == "" || !ok {
+		v.status = "no parent in this task"
+		return
+	}
+	v.moveTo(e.ParentID)
+}
+
+// readKey decodes one key press from raw terminal input, including arrow and page
+// escape sequences.
+func readKey(r *bufio.Reader) (tuiKey, error) {
+	c, err := r.ReadByte()
+	if err != nil {
+		return tuiKey{}, err
+	}
+	switch c {
+	case 3, 4: // Ctrl-C, Ctrl-D
+		return tuiKey{kind: keyQuit}, nil
+	case '\r', '\n':
+		return tuiKey{kind: keyEnter}, nil
+	case 127, 8:
+		return tuiKey{kind: keyBackspace}, nil
+	case 27:
+		if r.Buffered() == 0 {
+			return tuiKey{kind: keyEscape}, nil
+		}
+		next, _ := r.ReadByte()
+		if next != '[' && next != 'O' {
+			return tuiKey{kind: keyEscape}, nil
+		}
+		code, _ := r.ReadByte()
+		switch code {
+		case 'A':
+			return tuiKey{kind: keyUp}, nil
+		case 'B':
+			return tuiKey{kind: keyDown}, nil
+		case 'C':
+			return tuiKey{kind: keyRight}, nil
+		case 'D':
+			return tuiKey{kind: keyLeft}, nil
+		case '5', '6':
+			_, _ = r.ReadByte() // trailing '~'
+			if code == '5' {
+				return tuiKey{kind: keyPageUp}, nil
+			}
+			return tuiKey{kind: keyPageDown}, nil
+		}
+		return tuiKey{kind: keyNone}, nil
+	}
+	return tuiKey{kind: keyRune, r: c}, nil
+}
+
+// readPrompt reads a line of text in raw mode, echoing it on the status line. Escape
+// cancels and returns "".
+func readPrompt(r *bufio.Reader, out io.Writer, prompt string) string {
+	var line []byte
+	for n := 0; n < maxNoteLength; n++ {
+		fmt.Fprintf(out, "\r\033[K%s%s", prompt, line)
+		k, err := readKey(r)
+		if err != nil {
+			return ""
+		}
+		switch k.kind {
+		case keyEnter:
+			return string(line)
+		case keyEscape, keyQuit:
+			return ""
+		case keyBackspace:
+			if len(line) > 0 {
+				line = line[:len(line)-1]
+			}
+		case keyRune:
+			if k.r >= 32 {
+				line = append(line, k.r)
+			}
+		}
+	}
+	return string(line)
+}
+
+// runTraceTUI drives the view from key presses on in until the user quits.
+func runTraceTUI(v *traceView, in io.Reader, out io.Writer, rows, cols int) {
+	r := bufio.NewReader(in)
+	paneRows := rows / 2
+	v.setPane(paneSummary)
+	for n := 0; n < maxTraceTUIKeys; n++ {
+		fmt.Fprint(out, v.render(rows, cols))
+		k, err := readKey(r)
+		if err != nil {
+			return
+		}
+		if k.kind == keyRune && k.r == 'a' {
+			v.annotate(r, out)
+			continue
+		}
+		if !v.handle(k, paneRows) {
+			return
+		}
+	}
+}
+
+// annotate prompts for a note on the selected event and submits it to the running proxy.
+func (v *traceView) annotate(r *bufio.Reader, out io.Writer) {
+	e := v.selected()
+	if e == nil {
+		return
+	}
+	note := readPrompt(r, out, fmt.Sprintf("Note on %s (enter to save, esc to cancel): ", e.ID))
+	if strings.TrimSpace(note) == "" {
+		v.status = "annotation canceled"
+		return
+	}
+	ack, err := submitAnnotation(api.AnnotationRequest{EventID: e.ID, Note: note})
+	if err != nil {
+		v.status = "annotation failed: " + err.Error()
+		return
+	}
+	v.status = fmt.Sprintf("annotation %s recorded on %s", ack.ID, ack.EventID)
+}
+
+// interactiveTrace opens the trace TUI for a task on the controlling terminal.
+func interactiveTrace(db *store.DB, taskID, configPath string) error {
+	events, err := db.GetEventsByTaskID(taskID)
+	if err != nil {
+		return fmt.Errorf("getting events: %w", err)
+	}
+	if len(events) == 0 {
+		return fmt.Errorf("no events found for task %s", taskID)
+	}
+	v := newTraceView(taskID, events)
+	v.explain = func(e *models.Event) string {
+		var buf bytes.Buffer
+		if err := explainEvent(&buf, db, e, configPath); err != nil {
+			return err.Error()
+		}
+		return buf.String()
+	}
+
+	fd := int(os.Stdin.Fd())
+	restore, err := makeRaw(fd)
+	if err != nil {
+		return fmt.Errorf("interactive mode needs a terminal: %w", err)
+	}
+	defer restore()
+	rows, cols, err := terminalSize(int(os.Stdout.Fd()))
+	if err != nil || rows <= 0 || cols <= 0 {
+		rows, cols = tuiDefaultRows, tuiDefaultCols
+	}
+	fmt.Print(ansiHideCursor)
+	defer fmt.Print(ansiShowCursor + ansiClearHome)
+	runTraceTUI(v, os.Stdin, os.Stdout, rows, cols)
+	return nil
+}
@@ -0,0 +1,10 @@
+//go:build darwin || freebsd || netbsd || openbsd
+
+package commands
+
+import "golang.org/x/sys/unix"
+
+const (
+	ioctlReadTermios  = unix.TIOCGETA
+	ioctlWriteTermios = unix.TIOCSETA
+)
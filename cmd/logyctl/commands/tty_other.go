@@ -0,0 +1,15 @@
+//go:build !(linux || darwin || freebsd || netbsd || openbsd)
+
+package commands
+
+import "errors"
+
+var errNoRawTerminal = errors.New("interactive mode needs a Linux, macOS or BSD terminal")
+
+func makeRaw(int) (func(), error) {
+	return nil, errNoRawTerminal
+}
+
+func terminalSize(int) (int, int, error) {
+	return 0, 0, errNoRawTerminal
+}
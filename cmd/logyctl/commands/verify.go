@@ -8,15 +8,50 @@ import (
 
 	"github.com/slyt3/Logryph/internal/assert"
 	"github.com/slyt3/Logryph/internal/crypto"
+	"github.com/slyt3/Logryph/internal/export"
+	"github.com/slyt3/Logryph/internal/federation"
 	"github.com/slyt3/Logryph/internal/ledger/audit"
 	"github.com/slyt3/Logryph/internal/ledger/store"
+	"github.com/slyt3/Logryph/internal/models"
+	"github.com/slyt3/Logryph/internal/notary"
+	"github.com/slyt3/Logryph/internal/observer"
+	"github.com/slyt3/Logryph/internal/worm"
 )
 
-func VerifyCommand() {
+func VerifyCommand(args []string) {
 	// Parse flags
 	verifyFlags := flag.NewFlagSet("verify", flag.ExitOnError)
 	skipLive := verifyFlags.Bool("skip-live", false, "Skip live verification of Bitcoin anchors")
-	_ = verifyFlags.Parse(os.Args[2:])
+	checkWORM := verifyFlags.Bool("worm", false, "Cross-check the ledger against segments committed to WORM storage")
+	checkNotaries := verifyFlags.Bool("notaries", false, "Check notary countersignatures against the keys in the policy file")
+	configPath := verifyFlags.String("config", "logryph-policy.yaml", "Policy file with the worm target and notaries")
+	federationPath := verifyFlags.String("federation", "", "Verify the ledgers listed in this federation manifest together")
+	taskExport := verifyFlags.String("task-export", "", "Verify a task export (zip or json) on its own, without the ledger")
+	pubKey := verifyFlags.String("pubkey", "", "Ledger public key (hex) the task export or archive must be signed by")
+	archive := verifyFlags.String("archive", "", "Verify WORM segments straight from s3://bucket/prefix or dir:/path, without the ledger")
+	region := verifyFlags.String("region", awsRegion(), "S3 region of the archive")
+	endpoint := verifyFlags.String("endpoint", "", "S3-compatible endpoint of the archive; AWS when empty")
+	selfTest := verifyFlags.Bool("self-test", false, "Check the verifier against the signed test-vector corpus")
+	vectors := verifyFlags.String("vectors", "", "Test-vector corpus for --self-test; the built-in one when empty")
+	_ = verifyFlags.Parse(args)
+
+	if *selfTest {
+		verifySelfTest(*vectors)
+		return
+	}
+
+	if *federationPath != "" {
+		verifyFederation(*federationPath)
+		return
+	}
+	if *taskExport != "" {
+		verifyTaskExport(*taskExport, *pubKey)
+		return
+	}
+	if *archive != "" {
+		verifyArchive(*archive, worm.Config{Region: *region, Endpoint: *endpoint}, *pubKey)
+		return
+	}
 
 	// Open database
 	db, err := store.NewDB("logryph.db")
@@ -46,25 +81,29 @@ func VerifyCommand() {
 		return
 	}
 
-	fmt.Printf("Verifying chain for run: %s\n", runID[:8])
-
-	// Verify chain
-	result, err := audit.VerifyChain(db, runID, signer)
+	// Verify the run and, if it was started by rotation, the runs before it
+	result, err := audit.VerifyLinkedRuns(db, runID, signer)
 	if err != nil {
 		log.Fatalf("Verification error: %v", err)
 	}
-
-	if result.Valid {
-		fmt.Printf("[OK] Chain is valid (%d events verified)\n", result.TotalEvents)
-	} else {
-		fmt.Print("[FAILED] Chain verification failed\n")
-		fmt.Printf("  Error: %s\n", result.ErrorMessage)
-		if result.FailedAtSeq > 0 {
-			fmt.Printf("  Failed at sequence: %d\n", result.FailedAtSeq)
+	for i := 0; i < len(result.Runs); i++ {
+		printRunVerification(result.Runs[i], i > 0)
+	}
+	if !result.Valid {
+		if last := result.Runs[len(result.Runs)-1]; last.Result.Valid {
+			fmt.Print("[FAILED] Run link verification failed\n")
+			fmt.Printf("  Error: %s\n", result.ErrorMessage)
 		}
 		os.Exit(1)
 	}
 
+	if *checkWORM {
+		verifyWORM(db, runID, *configPath)
+	}
+	if *checkNotaries {
+		verifyCountersignatures(db, *configPath)
+	}
+
 	if *skipLive {
 		return
 	}
@@ -81,3 +120,235 @@ func VerifyCommand() {
 		os.Exit(1)
 	}
 }
+
+// verifyTaskExport checks a task export's events and chain context on their own.
+func verifyTaskExport(path, pubKey string) {
+	b, err := export.ReadTaskBundle(path)
+	if err != nil {
+		log.Fatalf("Failed to read task export: %v", err)
+	}
+	fmt.Printf("Verifying task %s from run %s\n", b.TaskID, b.RunID)
+	if err := export.VerifyTask(b, pubKey); err != nil {
+		fmt.Print("[FAILED] Task export verification failed\n")
+		fmt.Printf("  Error: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("[OK] %d task events verified, chained through %d other events (run head at export: seq %d)\n", len(b.Events), len(b.Links), b.Head.Seq)
+	if pubKey == "" {
+		fmt.Printf("  Signed by %s; compare it with the ledger key or pass --pubkey\n", b.PubKey)
+	}
+}
+
+// verifyArchive checks every run committed under an archive URI from its segments alone.
+func verifyArchive(uri string, c worm.Config, pubKey string) {
+	archive, prefix, err := worm.OpenArchive(uri, c)
+	if err != nil {
+		log.Fatalf("Failed to open archive: %v", err)
+	}
+	fmt.Printf("Verifying archive %s...\n", uri)
+	report, err := worm.VerifyArchive(archive, prefix, pubKey)
+	if err != nil {
+		log.Fatalf("Archive verification error: %v", err)
+	}
+	if len(report.Runs) == 0 && len(report.Problems) == 0 {
+		fmt.Println("[WARN] No segments found under this prefix")
+		return
+	}
+	for _, run := range report.Runs {
+		if len(run.Problems) > 0 {
+			fmt.Printf("[FAILED] Run %s (%d segments verified):\n", run.RunID, run.Segments)
+			for i := 0; i < len(run.Problems); i++ {
+				fmt.Printf("  - %s\n", run.Problems[i])
+			}
+			continue
+		}
+		fmt.Printf("[OK] Run %s: %d segments, %d events verified (seq %d-%d, head %.16s...)\n",
+			run.RunID, run.Segments, run.Events, run.FirstSeq, run.Head.Seq, run.Head.Hash)
+		for i := 0; i < len(run.AcknowledgedGaps); i++ {
+			gap := run.AcknowledgedGaps[i]
+			fmt.Printf("[WARN] %s missing (%d events), acknowledged by a gap_acknowledged event\n", gap, gap.Count())
+		}
+		if run.Duplicates > 0 {
+			fmt.Printf("  %d segments were uploaded more than once with the same events\n", run.Duplicates)
+		}
+		if !run.Pinned {
+			fmt.Printf("[WARN] Key not pinned; verified with the key the segments carry (%.16s...); pass --pubkey\n", run.PubKey)
+		}
+	}
+	for i := 0; i < len(report.Problems); i++ {
+		fmt.Printf("[FAILED] %s\n", report.Problems[i])
+	}
+	if !report.Valid() {
+		os.Exit(1)
+	}
+}
+
+// awsRegion is the region the AWS tools would use.
+func awsRegion() string {
+	if region := os.Getenv("AWS_REGION"); region != "" {
+		return region
+	}
+	return os.Getenv("AWS_DEFAULT_REGION")
+}
+
+// printRunVerification prints the chain result for one run; previous marks runs reached
+// through a rotation link.
+func printRunVerification(run audit.RunVerification, previous bool) {
+	if previous {
+		fmt.Printf("Verifying previous run: %s (linked by rotation)\n", run.RunID[:8])
+	} else {
+		fmt.Printf("Verifying chain for run: %s\n", run.RunID[:8])
+	}
+	result := run.Result
+	if !result.Valid {
+		fmt.Print("[FAILED] Chain verification failed\n")
+		fmt.Printf("  Error: %s\n", result.ErrorMessage)
+		if result.FailedAtSeq > 0 {
+			fmt.Printf("  Failed at sequence: %d\n", result.FailedAtSeq)
+		}
+		return
+	}
+	fmt.Printf("[OK] Chain is valid (%d events verified)\n", result.TotalEvents)
+	for i := 0; i < len(result.AcknowledgedGaps); i++ {
+		gap := result.AcknowledgedGaps[i]
+		fmt.Printf("[WARN] %s missing (%d events), acknowledged by a gap_acknowledged event\n", gap, gap.Count())
+	}
+}
+
+// verifyWORM compares the run with the immutable segment copies recorded in its chain.
+func verifyWORM(db *store.DB, runID, configPath string) {
+	obsEngine, err := observer.NewObserverEngine(configPath)
+	if err != nil {
+		log.Fatalf("Failed to load policy: %v", err)
+	}
+	target, err := worm.NewTarget(obsEngine.GetConfig().WORM)
+	if err != nil {
+		log.Fatalf("Failed to open WORM target: %v", err)
+	}
+	fmt.Printf("Cross-checking ledger against %s...\n", target.Name())
+	report, err := worm.VerifySegments(db, target, runID)
+	if err != nil {
+		log.Fatalf("WORM verification error: %v", err)
+	}
+	if report.Segments == 0 && len(report.Problems) == 0 {
+		fmt.Println("[WARN] No WORM segments recorded for this run")
+		return
+	}
+	if len(report.Problems) == 0 {
+		fmt.Printf("[OK] Ledger matches %d immutable segments (%d events)\n", report.Segments, report.Events)
+		return
+	}
+	fmt.Printf("[FAILED] Ledger differs from its immutable copies (%d segments checked)\n", report.Segments)
+	for i := 0; i < len(report.Problems); i++ {
+		fmt.Printf("  - %s\n", report.Problems[i])
+	}
+	os.Exit(1)
+}
+
+// verifyFederation checks every ledger of a multi-proxy deployment and the links between
+// them, and exits non-zero unless the combined verdict is valid.
+func verifyFederation(manifestPath string) {
+	manifest, err := federation.LoadManifest(manifestPath)
+	if err != nil {
+		log.Fatalf("Failed to load federation manifest: %v", err)
+	}
+	fmt.Printf("Verifying federation: %d ledgers, %d links\n", len(manifest.Ledgers), len(manifest.Links))
+	report, err := federation.Verify(manifest)
+	if err != nil {
+		log.Fatalf("Federation verification error: %v", err)
+	}
+
+	for _, l := range report.Ledgers {
+		if !l.Valid {
+			fmt.Printf("[FAILED] %s: %s\n", l.Name, l.Error)
+			continue
+		}
+		fmt.Printf("[OK] %s: chain valid (%d events, run %.8s)\n", l.Name, l.Events, l.RunID)
+		if !l.Pinned {
+			fmt.Printf("[WARN] %s: key not pinned; verified with the key the ledger records (%.16s...)\n", l.Name, l.PubKey)
+		}
+	}
+	for _, l := range report.Links {
+		switch {
+		case len(l.Problems) > 0:
+			fmt.Printf("[FAILED] %s -> %s:\n", l.From, l.To)
+			for i := 0; i < len(l.Problems); i++ {
+				fmt.Printf("  - %s\n", l.Problems[i])
+			}
+		case l.SharedTraces == 0:
+			fmt.Printf("[WARN] %s -> %s: no shared traces to cross-check\n", l.From, l.To)
+		default:
+			fmt.Printf("[OK] %s -> %s: %d shared traces consistent\n", l.From, l.To, l.SharedTraces)
+		}
+	}
+
+	if !report.Valid() {
+		fmt.Println("[FAILED] Federation integrity check failed")
+		os.Exit(1)
+	}
+	fmt.Println("[OK] Federation integrity verified")
+}
+
+// verifyCountersignatures checks every notary countersignature in the ledger: the notary
+// must be configured, the signature must verify with its pinned key, and the ledger must
+// still hold the checkpoint with the hash that was signed.
+func verifyCountersignatures(db *store.DB, configPath string) {
+	obsEngine, err := observer.NewObserverEngine(configPath)
+	if err != nil {
+		log.Fatalf("Failed to load policy: %v", err)
+	}
+	keys := make(map[string]string)
+	endpoints := obsEngine.GetConfig().Notary.Endpoints
+	for i := 0; i < len(endpoints); i++ {
+		keys[endpoints[i].Name] = endpoints[i].PublicKey
+	}
+	events, err := db.GetEventsByType(notary.EventTypeCountersignature)
+	if err != nil {
+		log.Fatalf("Failed to read countersignatures: %v", err)
+	}
+	fmt.Println("Checking notary countersignatures...")
+	counts := make(map[string]int)
+	var problems []string
+	for i := 0; i < len(events); i++ {
+		name, err := checkCountersignature(db, &events[i], keys)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("event %s: %v", events[i].ID, err))
+			continue
+		}
+		counts[name]++
+	}
+	for i := 0; i < len(endpoints); i++ {
+		fmt.Printf("[OK] %s: %d checkpoints countersigned\n", endpoints[i].Name, counts[endpoints[i].Name])
+	}
+	if len(problems) == 0 {
+		return
+	}
+	fmt.Printf("[FAILED] %d countersignatures did not verify\n", len(problems))
+	for i := 0; i < len(problems); i++ {
+		fmt.Printf("  - %s\n", problems[i])
+	}
+	os.Exit(1)
+}
+
+// checkCountersignature returns the name of the notary that signed e.
+func checkCountersignature(db *store.DB, e *models.Event, keys map[string]string) (string, error) {
+	cs, err := notary.ParseCountersignature(e)
+	if err != nil {
+		return "", err
+	}
+	key, ok := keys[cs.Notary]
+	if !ok {
+		return "", fmt.Errorf("notary %s is not in the policy file", cs.Notary)
+	}
+	if err := cs.Check(key); err != nil {
+		return "", err
+	}
+	checkpoint, err := db.GetEventByID(cs.CheckpointID)
+	if err != nil {
+		return "", fmt.Errorf("checkpoint %s: %w", cs.CheckpointID, err)
+	}
+	if checkpoint.CurrentHash != cs.CheckpointHash {
+		return "", fmt.Errorf("checkpoint %s now hashes to %s, not the countersigned %s", cs.CheckpointID, checkpoint.CurrentHash, cs.CheckpointHash)
+	}
+	return cs.Notary, nil
+}
@@ -0,0 +1,58 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/slyt3/Logryph/internal/verifyserver"
+)
+
+// VerifyServerCommand serves the verification endpoints alone. It opens no ledger and
+// reads no signing key, so it can be handed to auditors.
+func VerifyServerCommand(args []string) {
+	fs := flag.NewFlagSet("verify-server", flag.ExitOnError)
+	listen := fs.String("listen", "127.0.0.1:9700", "Address to serve verification on")
+	pubKey := fs.String("pubkey", "", "Ledger public key (hex) every export must be signed by")
+	maxBody := fs.Int64("max-body", 32<<20, "Largest export accepted, in bytes")
+	certFile := fs.String("tls-cert", "", "TLS certificate; serve plain HTTP without one")
+	keyFile := fs.String("tls-key", "", "TLS private key for --tls-cert")
+	_ = fs.Parse(args)
+	if (*certFile == "") != (*keyFile == "") {
+		log.Fatal("--tls-cert and --tls-key must be given together")
+	}
+
+	vs := &verifyserver.Server{PubKey: *pubKey, MaxBody: *maxBody}
+	srv := &http.Server{Addr: *listen, Handler: vs.Handler(), ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		var err error
+		if *certFile != "" {
+			err = srv.ListenAndServeTLS(*certFile, *keyFile)
+		} else {
+			err = srv.ListenAndServe()
+		}
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatalf("Verify server error: %v", err)
+		}
+	}()
+	fmt.Printf("Verify server listening on %s (POST %s, POST %s)\n", *listen, verifyserver.TaskPath, verifyserver.EventsPath)
+	if *pubKey == "" {
+		fmt.Println("  No --pubkey: task exports are checked against their own key, event batches need ?pubkey=")
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	<-ctx.Done()
+	shutdown, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := srv.Shutdown(shutdown); err != nil {
+		log.Printf("Verify server shutdown: %v", err)
+	}
+}
@@ -1,74 +1,80 @@
+// Command logyctl is the single Logryph binary: `logyctl serve` runs the proxy and admin
+// API, and the other subcommands work on the ledger it writes.
 package main
 
 import (
-	"fmt"
 	"os"
 
-	"github.com/slyt3/Logryph/cmd/logyctl/commands"
+	"github.com/spf13/cobra"
 )
 
 func main() {
-	if len(os.Args) < 2 {
-		printUsage()
+	if err := newRootCommand().Execute(); err != nil {
 		os.Exit(1)
 	}
+}
 
-	command := os.Args[1]
+// Command groups, in the order help lists them.
+const (
+	groupProxy     = "proxy"
+	groupLedger    = "ledger"
+	groupInvestig  = "investigations"
+	groupApprovals = "approvals"
+	groupOps       = "operations"
+	groupKeys      = "keys"
+)
 
-	switch command {
-	case "verify":
-		commands.VerifyCommand()
-	case "status":
-		commands.StatusCommand()
-	case "events":
-		commands.EventsCommand()
-	case "stats":
-		commands.StatsCommand()
-	case "risk":
-		commands.RiskCommand()
-	case "export":
-		commands.ExportCommand()
+func newRootCommand() *cobra.Command {
+	root := &cobra.Command{
+		Use:   "logyctl",
+		Short: "Logryph proxy and Associated Evidence Ledger (AEL) tool",
+		Long: `logyctl runs the Logryph proxy (logyctl serve) and works on the ledger it writes:
+verifying the hash chain, querying and exporting events, investigating tasks, deciding
+stalled calls and managing the signing key. Commands that read the ledger open
+logryph.db in the working directory.`,
+		SilenceUsage: true,
+	}
+	root.AddGroup(
+		&cobra.Group{ID: groupProxy, Title: "Proxy:"},
+		&cobra.Group{ID: groupLedger, Title: "Ledger:"},
+		&cobra.Group{ID: groupInvestig, Title: "Investigations:"},
+		&cobra.Group{ID: groupApprovals, Title: "Approvals (enforce mode):"},
+		&cobra.Group{ID: groupOps, Title: "Operations:"},
+		&cobra.Group{ID: groupKeys, Title: "Key management:"},
+	)
+	addProxyCommands(root)
+	addLedgerCommands(root)
+	addInvestigationCommands(root)
+	addApprovalCommands(root)
+	addOperationsCommands(root)
+	addKeyCommands(root)
+	return root
+}
 
-	case "rekey":
-		commands.RekeyCommand()
-	case "backup-key":
-		commands.BackupKeyCommand()
-	case "restore-key":
-		if len(os.Args) < 3 {
-			fmt.Println("Error: restore-key requires backup file path")
-			fmt.Println("Usage: logyctl restore-key <backup-file>")
-			os.Exit(1)
-		}
-		commands.RestoreKeyCommand(os.Args[2])
-	case "list-backups":
-		commands.ListBackupsCommand()
-	case "trace":
-		commands.TraceCommand()
-	case "replay":
-		commands.ReplayCommand()
-	default:
-		fmt.Printf("Unknown command: %s\n", command)
-		printUsage()
-		os.Exit(1)
+// passthrough builds a subcommand that parses its own flags with the standard flag
+// package: cobra hands it every argument after its name, -h included, unparsed.
+func passthrough(group, use, short, long string, run func(args []string)) *cobra.Command {
+	return &cobra.Command{
+		Use:                use,
+		Short:              short,
+		Long:               long,
+		GroupID:            group,
+		DisableFlagParsing: true,
+		Run: func(_ *cobra.Command, args []string) {
+			run(args)
+		},
 	}
 }
 
-func printUsage() {
-	fmt.Println("Logryph CLI - Associated Evidence Ledger (AEL) Tool tool")
-	fmt.Println()
-	fmt.Println("Usage:")
-	fmt.Println("  logyctl verify                    Validate the entire hash chain")
-	fmt.Println("  logyctl status                    Show current run information")
-	fmt.Println("  logyctl events [--limit N]        List recent events (default: 10)")
-	fmt.Println("  logyctl stats                     Show detailed run and global statistics")
-	fmt.Println("  logyctl risk                      List all high-risk events")
-	fmt.Println("  logyctl export <file.zip>         Export the current run as an Evidence Bag (ZIP)")
-	fmt.Println("  logyctl trace <task-id>           Visualize the forensic timeline of a task")
-	fmt.Println("  logyctl replay <id>               Re-execute a tool call to reproduce an incident")
-	fmt.Println()
-	fmt.Println("Key Management:")
-	fmt.Println("  logyctl rekey                     Rotate the Ed25519 signing keys")
-	fmt.Println("  logyctl backup-key                Create timestamped backup of signing key")
-	fmt.Println("  logyctl restore-key <file>        Restore signing key from backup")
-	fmt.Println("  logyctl list-backups              List available key backups")
+// noArgs builds a subcommand that takes no arguments or flags.
+func noArgs(group, use, short string, run func()) *cobra.Command {
+	return &cobra.Command{
+		Use:     use,
+		Short:   short,
+		GroupID: group,
+		Args:    cobra.NoArgs,
+		Run: func(_ *cobra.Command, _ []string) {
+			run()
+		},
+	}
 }
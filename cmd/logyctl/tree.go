@@ -0,0 +1,133 @@
+package main
+
+import (
+	"github.com/slyt3/Logryph/cmd/logyctl/commands"
+	"github.com/slyt3/Logryph/internal/server"
+	"github.com/spf13/cobra"
+)
+
+func addProxyCommands(root *cobra.Command) {
+	root.AddCommand(
+		passthrough(groupProxy, "serve [--target URL] [--config file] [flags]", "Run the proxy and admin API",
+			`Run the proxy in front of a tool server and the admin API beside it. logyctl serve -h
+lists the flags; the README describes them.`, server.Run),
+		passthrough(groupProxy, "status [--live]", "Show current run information",
+			`Show the current run. --live adds worker health, queue, drops and policy from the
+running proxy.`, commands.StatusCommand),
+		passthrough(groupProxy, "top [--interval 2s]", "Live view of call rates, queue, tasks and approvals", "", commands.TopCommand),
+		passthrough(groupProxy, "verify-server [--pubkey hex] [--worm] [--config file]", "Serve read-only verification of posted exports",
+			`Serve read-only verification of posted exports. --worm also cross-checks them against
+the WORM segment copies.`, commands.VerifyServerCommand),
+	)
+}
+
+func addLedgerCommands(root *cobra.Command) {
+	root.AddCommand(
+		passthrough(groupLedger, "verify [flags]", "Validate the hash chain",
+			`Validate the entire hash chain.
+
+  logyctl verify --task-export <f>  Verify a task export on its own [--pubkey hex]
+  logyctl verify --archive <uri>    Verify WORM segments from s3://bucket/prefix or dir:/path
+                                    [--pubkey hex] [--region r] [--endpoint url]
+  logyctl verify --self-test        Check the verifier against the signed test vectors [--vectors file]`,
+			commands.VerifyCommand),
+		passthrough(groupLedger, "testvectors generate [--out file]", "Write the test-vector corpus", "", commands.TestVectorsCommand),
+		passthrough(groupLedger, "chain gaps|repair", "List or acknowledge missing sequence ranges",
+			`  logyctl chain gaps                List missing sequence ranges in the chain
+  logyctl chain repair --reason R   Record a signed acknowledgement of the gaps [--as name]`,
+			commands.ChainCommand),
+		passthrough(groupLedger, "events [--limit N] [--label key=value]", "List recent events (default: 10)", "", commands.EventsCommand),
+		passthrough(groupLedger, "stats [--label k=v] [--capacity]", "Show run and global statistics, or label totals",
+			`Show run and global statistics, or label totals. --capacity shows ledger size, growth
+and the disk headroom forecast [--window 7] [--horizon days] [--min-headroom 20].`,
+			commands.StatsCommand),
+		noArgs(groupLedger, "labels", "List labels and how many events carry each", commands.LabelsCommand),
+		passthrough(groupLedger, "risk [--level L] [--since T] [--limit N] [--cursor id]", "List risk events a page at a time", "", commands.RiskCommand),
+		passthrough(groupLedger, "digest [--json]", "Print the daily digest for the last 24 hours", "", commands.DigestCommand),
+		passthrough(groupLedger, "exfil [--task ID]", "Per-task report of suspected data exfiltration", "", commands.ExfilCommand),
+		passthrough(groupLedger, "export <file.zip> [run-id] [flags]", "Export the current run as an Evidence Bag (ZIP)",
+			`Export the current run as an Evidence Bag (ZIP).
+
+  [--tsa url] [--no-attest]        Also write a signed <file.zip>.attestation.json
+  [--task id] [--format zip|json]  Export one task with the chain context to verify it`,
+			commands.ExportCommand),
+		passthrough(groupLedger, "attest verify|receipt", "Check an export or event receipt against its signature",
+			`  logyctl attest verify <zip> <att>  Check an export against its signed attestation
+  logyctl attest receipt <file>      Check an event receipt [--pubkey hex] [--ledger db]`,
+			commands.AttestCommand),
+		passthrough(groupLedger, "attachment <sha256>", "Write a stored upload after verifying its hash", "", commands.AttachmentCommand),
+		passthrough(groupLedger, "backup --out DIR [--keep N]", "Write a consistent ledger backup with a manifest", "", commands.BackupCommand),
+	)
+}
+
+func addInvestigationCommands(root *cobra.Command) {
+	root.AddCommand(
+		passthrough(groupInvestig, "trace <task-id>", "Visualize the forensic timeline of a task",
+			`Visualize the forensic timeline of a task.
+
+  logyctl trace --federated <trace-id> <ledger|zip>...  Merge one trace from several ledgers
+  logyctl trace --interactive <task-id> [--config f]    Browse a task's events, diffs, explain and annotate`,
+			commands.TraceCommand),
+		passthrough(groupInvestig, "explain <id> [--config f]", "Show an event's payload, rule, links, chain, signature and notes", "", commands.ExplainCommand),
+		passthrough(groupInvestig, "replay <id> [--target url]", "Re-execute a tool call to reproduce an incident", "", commands.ReplayCommand),
+		passthrough(groupInvestig, "annotate <id> [-m note] [--label key=value]", "Add a signed investigator note, or list notes", "", commands.AnnotateCommand),
+		passthrough(groupInvestig, "case create|add|list|show|export", "Group events and tasks into cases",
+			`  logyctl case create <name>        Open a case
+  logyctl case add <case> <id>      Attach an event or task to a case
+  logyctl case list|show <case>     List cases or show a case's items
+  logyctl case export <case> <zip>  Export a case as one evidence package`,
+			commands.CaseCommand),
+		passthrough(groupInvestig, "hold set|release|list|check", "Manage legal holds on tasks and cases",
+			`  logyctl hold set|release <k> <r>  Set or release a legal hold on a task or case --reason R
+  logyctl hold list|check <event>   List legal holds or check whether an event is held`,
+			commands.HoldCommand),
+		passthrough(groupInvestig, "query save|list|delete|run", "Save and run event queries",
+			`  logyctl query save <name> <expr>      Save an event query for logyctl and scheduled reports
+  logyctl query list|delete <name>      List or delete saved queries
+  logyctl query run <name> [--limit N]  Show events matching a saved query`,
+			commands.QueryCommand),
+	)
+}
+
+func addApprovalCommands(root *cobra.Command) {
+	root.AddCommand(
+		passthrough(groupApprovals, "pending [--by-task]", "List calls stalled for approval", "", commands.PendingCommand),
+		passthrough(groupApprovals, "approve <id>... [--as name]", "Release stalled calls", decideHelp, commands.ApproveCommand),
+		passthrough(groupApprovals, "reject <id>... [--as name]", "Refuse stalled calls", decideHelp, commands.RejectCommand),
+	)
+}
+
+const decideHelp = `  logyctl approve|reject <id> [--as name]  Decide one stalled call
+  logyctl approve|reject --task id         Decide every stall of a task together
+  logyctl approve|reject --all             Decide all matching stalls [--policy id] [--task id] [--method m]
+    [--offline] [--key f] [--out d]        Write a signed decision file for offline_approvals`
+
+func addOperationsCommands(root *cobra.Command) {
+	root.AddCommand(
+		passthrough(groupOps, "simulate [--profile P] [--seed N] [--rate R] [--mock-upstream addr]", "Send seeded synthetic agent traffic through the proxy",
+			"Send seeded synthetic agent traffic through the proxy. Profiles: bursty, mixed-risk,\nmulti-task, failure-heavy.", commands.SimulateCommand),
+		passthrough(groupOps, "scenarios list|run [name...]", "Run scripted rogue-agent scenarios and check the ledger caught them", "", commands.ScenariosCommand),
+		passthrough(groupOps, "observability export --grafana", "Write Grafana dashboard and alert rules", "", commands.ObservabilityCommand),
+		passthrough(groupOps, "generate k8s --image I --target URL", "Write Kubernetes manifests, Helm values and a sidecar example", "", commands.GenerateCommand),
+		passthrough(groupOps, "redirect print|install|remove --ports P", "Steer agent traffic to a --transparent proxy (Linux)", "", commands.RedirectCommand),
+		passthrough(groupOps, "sdk snippet [--lang L]", "Print a helper that sets the task hierarchy headers", "", commands.SDKCommand),
+		passthrough(groupOps, "debug capture [--seconds N]", "Collect profiles, metrics, config and logs into a ZIP", "", commands.DebugCommand),
+	)
+}
+
+func addKeyCommands(root *cobra.Command) {
+	root.AddCommand(
+		noArgs(groupKeys, "rekey", "Rotate the Ed25519 signing keys", commands.RekeyCommand),
+		noArgs(groupKeys, "backup-key", "Create a timestamped backup of the signing key", commands.BackupKeyCommand),
+		&cobra.Command{
+			Use:     "restore-key <backup-file>",
+			Short:   "Restore the signing key from a backup",
+			GroupID: groupKeys,
+			Args:    cobra.ExactArgs(1),
+			Run: func(_ *cobra.Command, args []string) {
+				commands.RestoreKeyCommand(args[0])
+			},
+		},
+		noArgs(groupKeys, "list-backups", "List available key backups", commands.ListBackupsCommand),
+	)
+}
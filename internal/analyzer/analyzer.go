@@ -0,0 +1,98 @@
+// Package analyzer holds content heuristics run over tool call params before they are ledgered.
+// Detectors are pure functions of (method, params): they never block traffic themselves,
+// the interceptor decides whether a finding only tags the call or is also enforced.
+package analyzer
+
+import (
+	"sort"
+	"strconv"
+
+	"github.com/slyt3/Logryph/internal/observer"
+)
+
+const (
+	maxMethodPatterns = 128
+	maxWalkDepth      = 16
+	maxWalkStrings    = 4096
+	maxWalkNodes      = 1 << 16 // maps, lists and leaves visited per walk
+	maxFindings       = 64
+)
+
+// Finding is a single detector hit inside a tool call's params.
+type Finding struct {
+	Kind   string `json:"kind"`
+	Path   string `json:"path"` // dotted param path, e.g. body.attachments[0]
+	Detail string `json:"detail,omitempty"`
+	Size   int    `json:"size,omitempty"` // bytes of the offending value
+	Risk   string `json:"risk,omitempty"` // detector-assigned risk level, when the detector grades hits
+}
+
+// matchMethods reports whether method matches one of patterns (observer.MatchPattern
+// syntax), falling back to defaults when the policy configures none.
+func matchMethods(patterns, defaults []string, method string) bool {
+	if len(patterns) == 0 {
+		patterns = defaults
+	}
+	for i := 0; i < maxMethodPatterns; i++ {
+		if i >= len(patterns) {
+			break
+		}
+		if observer.MatchPattern(patterns[i], method) {
+			return true
+		}
+	}
+	return false
+}
+
+// walkStrings calls fn for every string leaf in params, in deterministic key order.
+// Stops after maxWalkStrings leaves or when fn returns false.
+func walkStrings(params map[string]interface{}, fn func(path, value string) bool) {
+	type walkFrame struct {
+		path  string
+		value interface{}
+		depth int
+	}
+	// Children are pushed last to first, so leaves are visited in key order.
+	stack := []walkFrame{{value: params}}
+	seen := 0
+	for n := 0; n < maxWalkNodes && len(stack) > 0 && seen < maxWalkStrings; n++ {
+		f := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if f.depth > maxWalkDepth {
+			return
+		}
+		switch v := f.value.(type) {
+		case string:
+			seen++
+			if !fn(f.path, v) {
+				return
+			}
+		case map[string]interface{}:
+			keys := make([]string, 0, len(v))
+			for k := range v {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+			for i := min(len(keys), maxWalkStrings) - 1; i >= 0; i-- {
+				stack = append(stack, walkFrame{joinPath(f.path, keys[i]), v[keys[i]], f.depth + 1})
+			}
+		case []interface{}:
+			for i := min(len(v), maxWalkStrings) - 1; i >= 0; i-- {
+				stack = append(stack, walkFrame{f.path + "[" + strconv.Itoa(i) + "]", v[i], f.depth + 1})
+			}
+		}
+	}
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// Event types and tags produced from detector findings.
+const (
+	EventExfiltrationSuspected = "exfiltration_suspected"
+	EventDangerousCommand      = "dangerous_command"
+)
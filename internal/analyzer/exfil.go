@@ -0,0 +1,197 @@
+package analyzer
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/slyt3/Logryph/internal/models"
+)
+
+// Exfiltration finding kinds.
+const (
+	KindBase64Blob      = "base64_blob"
+	KindHexBlob         = "hex_blob"
+	KindBulkContent     = "bulk_content"
+	KindSensitiveMarker = "sensitive_marker"
+)
+
+// DefaultExfilMethods are outbound-looking methods checked when the policy names none.
+var DefaultExfilMethods = []string{
+	"http:post", "http:put", "http:patch", "http:request",
+	"email:*", "smtp:*", "slack:post*", "webhook:*", "ftp:*", "upload:*",
+}
+
+const (
+	defaultMinBlobBytes = 256
+	defaultBulkBytes    = 16 * 1024
+	defaultBulkLines    = 200
+	maxReportEvents     = 100000
+)
+
+// ExfilOptions tunes DetectExfiltration. Zero values select the defaults.
+type ExfilOptions struct {
+	Methods      []string // method patterns treated as outbound (observer.MatchPattern syntax)
+	MinBlobBytes int      // shortest unbroken base64/hex token reported as a blob
+	BulkBytes    int      // a single value at least this large is reported as bulk content
+}
+
+type sensitiveMarker struct {
+	name    string
+	pattern *regexp.Regexp
+}
+
+var sensitiveMarkers = []sensitiveMarker{
+	{"private_key", regexp.MustCompile(`-----BEGIN [A-Z ]*PRIVATE KEY-----`)},
+	{"aws_access_key", regexp.MustCompile(`\bAKIA[0-9A-Z]{16}\b`)},
+	{"passwd_file", regexp.MustCompile(`(?m)^root:[^:]*:0:0:`)},
+	{"shadow_file", regexp.MustCompile(`(?m)^root:\$[0-9a-z]+\$`)},
+	{"ssh_public_key", regexp.MustCompile(`\bssh-(rsa|ed25519) AAAA`)},
+	{"dotenv_secret", regexp.MustCompile(`(?mi)^[A-Z0-9_]*(SECRET|TOKEN|PASSWORD|API_KEY)[A-Z0-9_]*=\S+`)},
+}
+
+var (
+	base64Token = regexp.MustCompile(`^[A-Za-z0-9+/_-]+={0,2}$`)
+	hexToken    = regexp.MustCompile(`^[0-9a-fA-F]+$`)
+)
+
+// IsOutbound reports whether method matches one of the outbound patterns.
+func (o ExfilOptions) IsOutbound(method string) bool {
+	return matchMethods(o.Methods, DefaultExfilMethods, method)
+}
+
+// DetectExfiltration flags params of outbound-looking calls that carry encoded blobs,
+// bulk file-like content, or known-sensitive markers. Non-outbound methods return nil.
+func DetectExfiltration(method string, params map[string]interface{}, opts ExfilOptions) []Finding {
+	if method == "" || !opts.IsOutbound(method) {
+		return nil
+	}
+	minBlob := opts.MinBlobBytes
+	if minBlob <= 0 {
+		minBlob = defaultMinBlobBytes
+	}
+	bulk := opts.BulkBytes
+	if bulk <= 0 {
+		bulk = defaultBulkBytes
+	}
+
+	var findings []Finding
+	walkStrings(params, func(path, value string) bool {
+		findings = append(findings, scanExfilValue(path, value, minBlob, bulk)...)
+		return len(findings) < maxFindings
+	})
+	if len(findings) > maxFindings {
+		findings = findings[:maxFindings]
+	}
+	return findings
+}
+
+func scanExfilValue(path, value string, minBlob, bulk int) []Finding {
+	var out []Finding
+	if len(value) >= bulk || strings.Count(value, "\n") >= defaultBulkLines {
+		out = append(out, Finding{Kind: KindBulkContent, Path: path, Size: len(value),
+			Detail: fmt.Sprintf("%d lines", strings.Count(value, "\n")+1)})
+	}
+	for i := 0; i < len(sensitiveMarkers); i++ {
+		if sensitiveMarkers[i].pattern.MatchString(value) {
+			out = append(out, Finding{Kind: KindSensitiveMarker, Path: path, Detail: sensitiveMarkers[i].name, Size: len(value)})
+		}
+	}
+	if len(value) < minBlob {
+		return out
+	}
+	tokens := strings.Fields(value)
+	for i := 0; i < len(tokens) && i < maxWalkStrings; i++ {
+		token := tokens[i]
+		if len(token) < minBlob {
+			continue
+		}
+		if hexToken.MatchString(token) {
+			out = append(out, Finding{Kind: KindHexBlob, Path: path, Size: len(token)})
+			break
+		}
+		if base64Token.MatchString(token) && strings.IndexAny(token, "0123456789") >= 0 {
+			out = append(out, Finding{Kind: KindBase64Blob, Path: path, Size: len(token)})
+			break
+		}
+	}
+	return out
+}
+
+// TaskExfilReport aggregates exfiltration_suspected events for one task.
+type TaskExfilReport struct {
+	TaskID       string
+	Events       int
+	SuspectBytes int
+	Methods      map[string]int
+	Kinds        map[string]int
+	First        time.Time
+	Last         time.Time
+}
+
+// SummarizeExfil groups exfiltration_suspected events by task, most active task first.
+// Events without a task are grouped under an empty TaskID.
+func SummarizeExfil(events []models.Event) []TaskExfilReport {
+	byTask := make(map[string]*TaskExfilReport)
+	for i := 0; i < len(events) && i < maxReportEvents; i++ {
+		e := &events[i]
+		if e.EventType != EventExfiltrationSuspected {
+			continue
+		}
+		r, ok := byTask[e.TaskID]
+		if !ok {
+			r = &TaskExfilReport{TaskID: e.TaskID, Methods: map[string]int{}, Kinds: map[string]int{}, First: e.Timestamp}
+			byTask[e.TaskID] = r
+		}
+		r.Events++
+		if m, ok := e.Params["method"].(string); ok {
+			r.Methods[m]++
+		}
+		addFindingCounts(r, e.Params["findings"])
+		if e.Timestamp.Before(r.First) {
+			r.First = e.Timestamp
+		}
+		if e.Timestamp.After(r.Last) {
+			r.Last = e.Timestamp
+		}
+	}
+
+	reports := make([]TaskExfilReport, 0, len(byTask))
+	for _, r := range byTask {
+		reports = append(reports, *r)
+	}
+	sort.Slice(reports, func(a, b int) bool {
+		if reports[a].Events != reports[b].Events {
+			return reports[a].Events > reports[b].Events
+		}
+		return reports[a].TaskID < reports[b].TaskID
+	})
+	return reports
+}
+
+// addFindingCounts accepts findings as stored in the ledger: []Finding before persistence,
+// []interface{} of JSON objects after a database round trip.
+func addFindingCounts(r *TaskExfilReport, raw interface{}) {
+	switch findings := raw.(type) {
+	case []Finding:
+		for i := 0; i < len(findings) && i < maxFindings; i++ {
+			r.Kinds[findings[i].Kind]++
+			r.SuspectBytes += findings[i].Size
+		}
+	case []interface{}:
+		for i := 0; i < len(findings) && i < maxFindings; i++ {
+			f, ok := findings[i].(map[string]interface{})
+			if !ok {
+				continue
+			}
+			if kind, ok := f["kind"].(string); ok {
+				r.Kinds[kind]++
+			}
+			if size, ok := f["size"].(float64); ok {
+				r.SuspectBytes += int(size)
+			}
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package analyzer
+
+// Risk levels in ascending order, matching the values used by policy rules.
+const (
+	RiskLow      = "low"
+	RiskMedium   = "medium"
+	RiskHigh     = "high"
+	RiskCritical = "critical"
+)
+
+var riskRank = map[string]int{RiskLow: 1, RiskMedium: 2, RiskHigh: 3, RiskCritical: 4}
+
+// RiskAtLeast reports whether risk is at or above min. Unknown levels rank lowest.
+func RiskAtLeast(risk, min string) bool {
+	return riskRank[risk] >= riskRank[min] && riskRank[risk] > 0
+}
+
+// MaxRisk returns the higher of two risk levels.
+func MaxRisk(a, b string) string {
+	if riskRank[b] > riskRank[a] {
+		return b
+	}
+	return a
+}
+
+// HighestRisk returns the highest Risk among findings, or "" if none carry one.
+func HighestRisk(findings []Finding) string {
+	risk := ""
+	for i := 0; i < len(findings) && i < maxFindings; i++ {
+		risk = MaxRisk(risk, findings[i].Risk)
+	}
+	return risk
+}
+
+// RiskLevelsAtLeast returns the levels at or above min, lowest first, or nil if min is
+// not a risk level.
+func RiskLevelsAtLeast(min string) []string {
+	if riskRank[min] == 0 {
+		return nil
+	}
+	var levels []string
+	for _, level := range []string{RiskLow, RiskMedium, RiskHigh, RiskCritical} {
+		if RiskAtLeast(level, min) {
+			levels = append(levels, level)
+		}
+	}
+	return levels
+}
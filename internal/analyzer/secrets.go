@@ -0,0 +1,199 @@
+package analyzer
+
+import (
+	"math"
+	"regexp"
+	"strings"
+)
+
+// Event types and tags produced by the secrets detector.
+const (
+	EventSecretLeak      = "secret_leak"
+	EventSecretReference = "secret_reference"
+)
+
+// Secret finding kinds.
+const (
+	KindSecretRef      = "secret_ref"
+	KindKnownSecret    = "known_secret_format"
+	KindNamedSecret    = "named_secret_param"
+	KindHighEntropyKey = "high_entropy_secret"
+)
+
+// DefaultSecretMethods scans every method; secrets can leak through any tool.
+var DefaultSecretMethods = []string{"*"}
+
+// DefaultSecretRefSchemes are the vault reference schemes recognized when the policy names none,
+// e.g. vault:kv/payments/stripe or op://team/db/password.
+var DefaultSecretRefSchemes = []string{"vault", "aws-sm", "gcp-sm", "azure-kv", "op", "doppler"}
+
+const (
+	defaultSecretMinLength  = 20
+	defaultSecretMinEntropy = 4.0
+	maxSecretTokenLen       = 512 // longer tokens are blobs, reported by the exfiltration heuristics
+	maxSecretRefLen         = 256
+	minNamedSecretLen       = 8
+	maxRefSchemes           = 32
+)
+
+var knownSecretFormats = []sensitiveMarker{
+	{"aws_access_key", regexp.MustCompile(`\b(AKIA|ASIA)[0-9A-Z]{16}\b`)},
+	{"github_token", regexp.MustCompile(`\b(gh[pousr]_[A-Za-z0-9]{36,}|github_pat_[A-Za-z0-9_]{40,})\b`)},
+	{"openai_key", regexp.MustCompile(`\bsk-(proj-)?[A-Za-z0-9_-]{32,}`)},
+	{"slack_token", regexp.MustCompile(`\bxox[abposr]-[A-Za-z0-9-]{10,}`)},
+	{"google_api_key", regexp.MustCompile(`\bAIza[0-9A-Za-z_-]{35}\b`)},
+	{"stripe_live_key", regexp.MustCompile(`\b[rs]k_live_[0-9A-Za-z]{16,}\b`)},
+	{"jwt", regexp.MustCompile(`\beyJ[A-Za-z0-9_-]{8,}\.eyJ[A-Za-z0-9_-]{8,}\.[A-Za-z0-9_-]{8,}`)},
+	{"private_key", regexp.MustCompile(`-----BEGIN [A-Z ]*PRIVATE KEY-----`)},
+}
+
+var (
+	secretParamName = regexp.MustCompile(`(?i)(^|[_-])(password|passwd|pwd|secret|client_?secret|api_?key|access_?key|secret_?key|private_?key|auth_?token|access_?token|credentials?)$`)
+	secretToken     = regexp.MustCompile(`[A-Za-z0-9+/=_.-]+`)
+	uuidToken       = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+)
+
+// SecretOptions tunes DetectSecrets. Zero values select the defaults.
+type SecretOptions struct {
+	Methods    []string // method patterns to scan (default: all)
+	RefSchemes []string // vault reference schemes, e.g. "vault" for vault:kv/path
+	MinLength  int      // shortest token considered for the entropy check
+	MinEntropy float64  // Shannon entropy (bits per byte) at which a token counts as a secret
+}
+
+// SecretScan separates vault references, which are safe to pass, from literal secrets.
+type SecretScan struct {
+	Refs  []Finding // Detail holds the reference itself; it is a pointer, not a secret
+	Leaks []Finding // Detail names the format; the secret value is never recorded
+}
+
+// DetectSecrets finds vault references and literal secrets in params. A reference means the
+// agent never held the value and resolution is left to the upstream tool; a literal secret
+// (known token format, a secret-named param, or a high-entropy token) is reported as a leak.
+func DetectSecrets(method string, params map[string]interface{}, opts SecretOptions) SecretScan {
+	var scan SecretScan
+	if method == "" || !matchMethods(opts.Methods, DefaultSecretMethods, method) {
+		return scan
+	}
+	refPattern := secretRefPattern(opts.RefSchemes)
+	minLen := opts.MinLength
+	if minLen <= 0 {
+		minLen = defaultSecretMinLength
+	}
+	minEntropy := opts.MinEntropy
+	if minEntropy <= 0 {
+		minEntropy = defaultSecretMinEntropy
+	}
+
+	walkStrings(params, func(path, value string) bool {
+		refs := refPattern.FindAllString(value, maxFindings)
+		for i := 0; i < len(refs) && len(scan.Refs) < maxFindings; i++ {
+			scan.Refs = append(scan.Refs, Finding{Kind: KindSecretRef, Path: path, Detail: truncate(refs[i], maxSecretRefLen)})
+		}
+		literal := refPattern.ReplaceAllString(value, " ")
+		if leak, ok := classifyLiteralSecret(path, literal, minLen, minEntropy); ok && len(scan.Leaks) < maxFindings {
+			scan.Leaks = append(scan.Leaks, leak)
+		}
+		return len(scan.Refs) < maxFindings && len(scan.Leaks) < maxFindings
+	})
+	return scan
+}
+
+// classifyLiteralSecret reports at most one leak per param value, strongest signal first.
+func classifyLiteralSecret(path, value string, minLen int, minEntropy float64) (Finding, bool) {
+	for i := 0; i < len(knownSecretFormats); i++ {
+		if knownSecretFormats[i].pattern.MatchString(value) {
+			return Finding{Kind: KindKnownSecret, Path: path, Detail: knownSecretFormats[i].name, Size: len(value), Risk: RiskHigh}, true
+		}
+	}
+	trimmed := strings.TrimSpace(value)
+	if secretParamName.MatchString(lastPathKey(path)) && len(trimmed) >= minNamedSecretLen && !strings.ContainsAny(trimmed, " \n\t") {
+		return Finding{Kind: KindNamedSecret, Path: path, Detail: lastPathKey(path), Size: len(value), Risk: RiskHigh}, true
+	}
+	tokens := secretToken.FindAllString(value, maxWalkStrings)
+	for i := 0; i < len(tokens); i++ {
+		tok := tokens[i]
+		if len(tok) < minLen || len(tok) > maxSecretTokenLen || !looksLikeSecretToken(tok) {
+			continue
+		}
+		if shannonEntropy(tok) >= minEntropy {
+			return Finding{Kind: KindHighEntropyKey, Path: path, Detail: "entropy", Size: len(tok), Risk: RiskMedium}, true
+		}
+	}
+	return Finding{}, false
+}
+
+// looksLikeSecretToken skips identifiers that are random but not secret (hashes, UUIDs)
+// and words or paths that lack mixed character classes.
+func looksLikeSecretToken(tok string) bool {
+	if hexToken.MatchString(tok) || uuidToken.MatchString(tok) {
+		return false
+	}
+	var upper, lower, digit bool
+	for i := 0; i < len(tok); i++ {
+		c := tok[i]
+		upper = upper || c >= 'A' && c <= 'Z'
+		lower = lower || c >= 'a' && c <= 'z'
+		digit = digit || c >= '0' && c <= '9'
+	}
+	return upper && lower && digit
+}
+
+// shannonEntropy returns the entropy of s in bits per byte.
+func shannonEntropy(s string) float64 {
+	if s == "" {
+		return 0
+	}
+	var counts [256]int
+	for i := 0; i < len(s); i++ {
+		counts[s[i]]++
+	}
+	entropy := 0.0
+	n := float64(len(s))
+	for i := 0; i < len(counts); i++ {
+		if counts[i] == 0 {
+			continue
+		}
+		p := float64(counts[i]) / n
+		entropy -= p * math.Log2(p)
+	}
+	return entropy
+}
+
+var defaultSecretRefPattern = compileSecretRefPattern(DefaultSecretRefSchemes)
+
+// secretRefPattern matches <scheme>:<path> and <scheme>://<path> references.
+func secretRefPattern(schemes []string) *regexp.Regexp {
+	if len(schemes) == 0 {
+		return defaultSecretRefPattern
+	}
+	return compileSecretRefPattern(schemes)
+}
+
+func compileSecretRefPattern(schemes []string) *regexp.Regexp {
+	quoted := make([]string, 0, len(schemes))
+	for i := 0; i < len(schemes) && i < maxRefSchemes; i++ {
+		if schemes[i] != "" {
+			quoted = append(quoted, regexp.QuoteMeta(strings.ToLower(schemes[i])))
+		}
+	}
+	return regexp.MustCompile(`(?i)\b(` + strings.Join(quoted, "|") + `):(//)?[A-Za-z0-9_.#@/-]*[A-Za-z0-9_]`)
+}
+
+// lastPathKey returns the final map key of a walkStrings path, without array indexes.
+func lastPathKey(path string) string {
+	if i := strings.IndexByte(path, '['); i >= 0 {
+		path = path[:i]
+	}
+	if i := strings.LastIndexByte(path, '.'); i >= 0 {
+		path = path[i+1:]
+	}
+	return path
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n]
+}
@@ -0,0 +1,319 @@
+package analyzer
+
+import (
+	"path"
+	"regexp"
+	"strings"
+)
+
+// Shell finding kinds.
+const (
+	KindDestructiveDelete   = "destructive_delete"
+	KindRecursiveDelete     = "recursive_delete"
+	KindRemoteCodeExecution = "remote_code_execution"
+	KindPipeToInterpreter   = "pipe_to_interpreter"
+	KindPrivilegeEscalation = "privilege_escalation"
+	KindDiskWrite           = "raw_disk_write"
+	KindReverseShell        = "reverse_shell"
+	KindForkBomb            = "fork_bomb"
+	KindPermissionsOpen     = "world_writable_root"
+	KindSystemShutdown      = "system_shutdown"
+	KindAuditEvasion        = "audit_evasion"
+)
+
+// DefaultShellMethods are the command-execution methods analyzed when the policy names none.
+var DefaultShellMethods = []string{
+	"shell:*", "exec:*", "bash:*", "terminal:*", "process:*", "cmd:*", "os:run", "os:exec",
+}
+
+const (
+	maxShellDepth    = 4 // nested sh -c / $(...) levels
+	maxShellWords    = 1024
+	maxShellCommands = 256
+	maxShellScripts  = 256 // nested scripts analyzed per command line
+	maxCommandLen    = 64 * 1024
+)
+
+var (
+	downloaders  = map[string]bool{"curl": true, "wget": true, "fetch": true, "aria2c": true}
+	interpreters = map[string]bool{
+		"sh": true, "bash": true, "zsh": true, "dash": true, "ksh": true, "fish": true,
+		"python": true, "python2": true, "python3": true, "perl": true, "ruby": true, "node": true, "php": true,
+	}
+	shells     = map[string]bool{"sh": true, "bash": true, "zsh": true, "dash": true, "ksh": true}
+	elevators  = map[string]bool{"sudo": true, "su": true, "doas": true, "pkexec": true}
+	wrappers   = map[string]bool{"env": true, "nohup": true, "time": true, "nice": true, "exec": true, "command": true, "xargs": true, "timeout": true}
+	shutdowns  = map[string]bool{"shutdown": true, "reboot": true, "halt": true, "poweroff": true}
+	rootTarget = map[string]bool{"/": true, "/*": true, "~": true, "~/": true, "~/*": true, "$HOME": true, "${HOME}": true, "/.*": true}
+
+	blockDeviceRedirect = regexp.MustCompile(`>\s*/dev/(sd|hd|vd|xvd|nvme|mmcblk|disk)`)
+	forkBomb            = regexp.MustCompile(`:\s*\(\s*\)\s*\{\s*:\s*\|\s*:\s*&\s*\}\s*;\s*:`)
+)
+
+// ShellOptions tunes DetectShellRisk. Zero values select the defaults.
+type ShellOptions struct {
+	Methods []string // method patterns treated as command execution
+}
+
+// DetectShellRisk parses command strings in the params of command-execution methods and
+// reports dangerous patterns, each graded with a risk level. Non-shell methods return nil.
+func DetectShellRisk(method string, params map[string]interface{}, opts ShellOptions) []Finding {
+	if method == "" || !matchMethods(opts.Methods, DefaultShellMethods, method) {
+		return nil
+	}
+	var findings []Finding
+	cmds := shellCommandParams(params)
+	for i := 0; i < len(cmds) && i < maxFindings; i++ {
+		findings = append(findings, AnalyzeCommand(cmds[i].path, cmds[i].command)...)
+	}
+	if len(findings) > maxFindings {
+		findings = findings[:maxFindings]
+	}
+	return findings
+}
+
+// AnalyzeCommand reports dangerous patterns in one shell command line.
+func AnalyzeCommand(paramPath, command string) []Finding {
+	if len(command) > maxCommandLen {
+		command = command[:maxCommandLen]
+	}
+	a := &shellAnalysis{path: paramPath, scripts: []shellScript{{command: command}}}
+	// analyze queues the scripts nested in each one, so the queue grows as it is read.
+	for i := 0; i < len(a.scripts) && i < maxShellScripts; i++ {
+		a.analyze(a.scripts[i].command, a.scripts[i].depth)
+	}
+	return a.findings
+}
+
+type shellAnalysis struct {
+	path     string
+	findings []Finding
+	scripts  []shellScript // the command line, then the scripts found inside it
+}
+
+// shellScript is a script to analyze: the command line itself, a $(...) body or an
+// sh -c argument, depth levels down.
+type shellScript struct {
+	command string
+	depth   int
+}
+
+func (a *shellAnalysis) add(kind, risk, detail string) {
+	for i := 0; i < len(a.findings); i++ {
+		if a.findings[i].Kind == kind && a.findings[i].Detail == detail {
+			return
+		}
+	}
+	if len(a.findings) < maxFindings {
+		a.findings = append(a.findings, Finding{Kind: kind, Path: a.path, Detail: detail, Risk: risk})
+	}
+}
+
+func (a *shellAnalysis) analyze(command string, depth int) {
+	if depth > maxShellDepth {
+		return
+	}
+	if forkBomb.MatchString(command) {
+		a.add(KindForkBomb, RiskCritical, ":(){ :|:& };:")
+	}
+	if blockDeviceRedirect.MatchString(command) {
+		a.add(KindDiskWrite, RiskCritical, "redirect to block device")
+	}
+	if strings.Contains(command, "/dev/tcp/") || strings.Contains(command, "/dev/udp/") {
+		a.add(KindReverseShell, RiskCritical, "/dev/tcp socket")
+	}
+
+	lex := lexShell(command)
+	for i := 0; i < len(lex.substitutions) && i < maxShellCommands; i++ {
+		a.scripts = append(a.scripts, shellScript{lex.substitutions[i], depth + 1})
+	}
+	for i := 0; i < len(lex.pipelines) && i < maxShellCommands; i++ {
+		a.analyzePipeline(lex.pipelines[i], depth)
+	}
+}
+
+func (a *shellAnalysis) analyzePipeline(pipeline [][]string, depth int) {
+	downloaded := false
+	for i := 0; i < len(pipeline) && i < maxShellCommands; i++ {
+		argv := a.unwrap(pipeline[i])
+		if len(argv) == 0 {
+			continue
+		}
+		name := path.Base(argv[0])
+		if i > 0 && interpreters[name] && readsStdin(argv) {
+			if downloaded {
+				a.add(KindRemoteCodeExecution, RiskCritical, "download piped to "+name)
+			} else {
+				a.add(KindPipeToInterpreter, RiskHigh, "output piped to "+name)
+			}
+		}
+		if downloaders[name] {
+			downloaded = true
+		}
+		a.analyzeCommand(name, argv, depth)
+	}
+}
+
+// unwrap strips env assignments and transparent wrappers (env, nohup, ...), recording
+// privilege escalation along the way, and returns the effective argv.
+func (a *shellAnalysis) unwrap(argv []string) []string {
+	for i := 0; i < maxShellWords && len(argv) > 0; i++ {
+		name := path.Base(argv[0])
+		switch {
+		case strings.Contains(argv[0], "=") && !strings.HasPrefix(argv[0], "="):
+			argv = argv[1:]
+		case elevators[name]:
+			a.add(KindPrivilegeEscalation, RiskHigh, name)
+			argv = skipFlags(argv[1:])
+		case wrappers[name]:
+			argv = skipFlags(argv[1:])
+		default:
+			return argv
+		}
+	}
+	return argv
+}
+
+func (a *shellAnalysis) analyzeCommand(name string, argv []string, depth int) {
+	switch {
+	case name == "rm":
+		a.analyzeRm(argv)
+	case name == "dd":
+		for i := 1; i < len(argv) && i < maxShellWords; i++ {
+			if strings.HasPrefix(argv[i], "of=/dev/") && !strings.HasPrefix(argv[i], "of=/dev/null") {
+				a.add(KindDiskWrite, RiskCritical, "dd "+argv[i])
+			}
+		}
+	case strings.HasPrefix(name, "mkfs") || name == "wipefs" || name == "shred":
+		a.add(KindDiskWrite, RiskCritical, name)
+	case name == "chmod" || name == "chown":
+		if hasArg(argv, "/") && (hasFlagChar(argv, 'R') || hasArg(argv, "--recursive")) {
+			a.add(KindPermissionsOpen, RiskHigh, name+" -R /")
+		}
+	case name == "nc" || name == "ncat" || name == "netcat":
+		if hasArg(argv, "-e") || hasArg(argv, "-c") {
+			a.add(KindReverseShell, RiskCritical, name+" -e")
+		}
+	case shutdowns[name] || (name == "init" && (hasArg(argv, "0") || hasArg(argv, "6"))):
+		a.add(KindSystemShutdown, RiskHigh, name)
+	case name == "history" && hasArg(argv, "-c"), name == "unset" && hasArg(argv, "HISTFILE"):
+		a.add(KindAuditEvasion, RiskMedium, strings.Join(argv, " "))
+	case shells[name]:
+		// sh -c '<script>': analyze the script itself.
+		for i := 1; i+1 < len(argv) && i < maxShellWords; i++ {
+			if argv[i] == "-c" {
+				a.scripts = append(a.scripts, shellScript{argv[i+1], depth + 1})
+				break
+			}
+		}
+	}
+}
+
+func (a *shellAnalysis) analyzeRm(argv []string) {
+	recursive := hasFlagChar(argv, 'r') || hasFlagChar(argv, 'R') || hasArg(argv, "--recursive")
+	if hasArg(argv, "--no-preserve-root") {
+		a.add(KindDestructiveDelete, RiskCritical, "rm --no-preserve-root")
+		return
+	}
+	if !recursive {
+		return
+	}
+	for i := 1; i < len(argv) && i < maxShellWords; i++ {
+		if rootTarget[argv[i]] {
+			a.add(KindDestructiveDelete, RiskCritical, "rm -r "+argv[i])
+			return
+		}
+	}
+	a.add(KindRecursiveDelete, RiskMedium, "rm -r")
+}
+
+// readsStdin reports whether an interpreter invocation executes code from stdin
+// (no script argument, or an explicit "-" / "-s").
+func readsStdin(argv []string) bool {
+	for i := 1; i < len(argv) && i < maxShellWords; i++ {
+		arg := argv[i]
+		if arg == "-" || arg == "-s" {
+			return true
+		}
+		if arg == "-c" || arg == "-e" {
+			return false
+		}
+		if !strings.HasPrefix(arg, "-") {
+			return false
+		}
+	}
+	return true
+}
+
+func skipFlags(argv []string) []string {
+	for i := 0; i < len(argv) && i < maxShellWords; i++ {
+		if !strings.HasPrefix(argv[i], "-") {
+			return argv[i:]
+		}
+	}
+	return nil
+}
+
+func hasArg(argv []string, want string) bool {
+	for i := 1; i < len(argv) && i < maxShellWords; i++ {
+		if argv[i] == want {
+			return true
+		}
+	}
+	return false
+}
+
+// hasFlagChar reports whether a short-flag cluster (e.g. -rf) contains c.
+func hasFlagChar(argv []string, c byte) bool {
+	for i := 1; i < len(argv) && i < maxShellWords; i++ {
+		arg := argv[i]
+		if len(arg) > 1 && arg[0] == '-' && arg[1] != '-' && strings.IndexByte(arg[1:], c) >= 0 {
+			return true
+		}
+	}
+	return false
+}
+
+type shellCommand struct {
+	path    string
+	command string
+}
+
+// shellCommandParams pulls command lines out of shell tool params. "command"/"cmd" are
+// joined with "args"/"argv" when present; "script" is analyzed on its own.
+func shellCommandParams(params map[string]interface{}) []shellCommand {
+	var out []shellCommand
+	for _, key := range []string{"command", "cmd", "command_line", "commandline"} {
+		cmd, ok := params[key].(string)
+		if !ok {
+			continue
+		}
+		out = append(out, shellCommand{path: key, command: cmd + joinArgs(params)})
+		break
+	}
+	if script, ok := params["script"].(string); ok {
+		out = append(out, shellCommand{path: "script", command: script})
+	}
+	if len(out) == 0 {
+		if args := joinArgs(params); args != "" {
+			out = append(out, shellCommand{path: "args", command: strings.TrimSpace(args)})
+		}
+	}
+	return out
+}
+
+func joinArgs(params map[string]interface{}) string {
+	raw, ok := params["args"].([]interface{})
+	if !ok {
+		raw, _ = params["argv"].([]interface{})
+	}
+	var b strings.Builder
+	for i := 0; i < len(raw) && i < maxShellWords; i++ {
+		if s, ok := raw[i].(string); ok {
+			b.WriteString(" '")
+			b.WriteString(strings.ReplaceAll(s, "'", `'\''`))
+			b.WriteString("'")
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,155 @@
+package analyzer
+
+import "strings"
+
+// shellLex is the result of splitting a command line into pipelines of simple commands.
+// It is a best-effort POSIX-ish lexer for risk analysis, not an interpreter: quotes and
+// escapes are honoured, command substitutions are returned for separate analysis.
+type shellLex struct {
+	pipelines     [][][]string // list -> pipeline -> argv
+	substitutions []string     // bodies of $(...) and `...`
+}
+
+type shellLexer struct {
+	out      shellLex
+	pipeline [][]string
+	argv     []string
+	word     strings.Builder
+	inWord   bool
+}
+
+func (l *shellLexer) endWord() {
+	if l.inWord && len(l.argv) < maxShellWords {
+		l.argv = append(l.argv, l.word.String())
+	}
+	l.word.Reset()
+	l.inWord = false
+}
+
+func (l *shellLexer) endCommand() {
+	l.endWord()
+	if len(l.argv) > 0 {
+		l.pipeline = append(l.pipeline, l.argv)
+	}
+	l.argv = nil
+}
+
+func (l *shellLexer) endPipeline() {
+	l.endCommand()
+	if len(l.pipeline) > 0 && len(l.out.pipelines) < maxShellCommands {
+		l.out.pipelines = append(l.out.pipelines, l.pipeline)
+	}
+	l.pipeline = nil
+}
+
+func (l *shellLexer) addSubstitution(body string) {
+	if len(l.out.substitutions) < maxShellCommands {
+		l.out.substitutions = append(l.out.substitutions, body)
+	}
+}
+
+func lexShell(s string) shellLex {
+	l := &shellLexer{}
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '\\' && i+1 < len(s):
+			i++
+			l.word.WriteByte(s[i])
+			l.inWord = true
+		case c == '\'':
+			end := strings.IndexByte(s[i+1:], '\'')
+			if end < 0 {
+				end = len(s) - i - 1
+			}
+			l.word.WriteString(s[i+1 : i+1+end])
+			l.inWord = true
+			i += end + 1
+		case c == '"':
+			i = l.lexDoubleQuoted(s, i+1)
+		case c == '`':
+			end := strings.IndexByte(s[i+1:], '`')
+			if end < 0 {
+				end = len(s) - i - 1
+			}
+			l.addSubstitution(s[i+1 : i+1+end])
+			l.inWord = true
+			i += end + 1
+		case c == '$' && i+1 < len(s) && s[i+1] == '(':
+			body, next := matchParen(s, i+2)
+			l.addSubstitution(body)
+			l.inWord = true
+			i = next
+		case c == '|' || c == ';' || c == '&' || c == '\n':
+			i = l.lexOperator(s, i)
+		case c == ' ' || c == '\t':
+			l.endWord()
+		case c == '>' || c == '<':
+			l.endWord()
+		default:
+			l.word.WriteByte(c)
+			l.inWord = true
+		}
+	}
+	l.endPipeline()
+	return l.out
+}
+
+// lexDoubleQuoted consumes a "..." word starting after the opening quote and returns the
+// index of the closing quote. $(...) inside double quotes is still a substitution.
+func (l *shellLexer) lexDoubleQuoted(s string, i int) int {
+	l.inWord = true
+	for ; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '"':
+			return i
+		case c == '\\' && i+1 < len(s):
+			i++
+			l.word.WriteByte(s[i])
+		case c == '$' && i+1 < len(s) && s[i+1] == '(':
+			body, next := matchParen(s, i+2)
+			l.addSubstitution(body)
+			i = next
+		default:
+			l.word.WriteByte(c)
+		}
+	}
+	return i
+}
+
+// lexOperator handles |, ||, &, &&, ; and newline. Only a single | continues a pipeline.
+func (l *shellLexer) lexOperator(s string, i int) int {
+	c := s[i]
+	double := i+1 < len(s) && s[i+1] == c
+	switch {
+	case c == '|' && !double:
+		l.endCommand()
+	case c == '&' && i > 0 && s[i-1] == '>':
+		// ">&" redirection, not a separator
+	default:
+		l.endPipeline()
+	}
+	if double {
+		return i + 1
+	}
+	return i
+}
+
+// matchParen returns the body of a $( ... ) starting at i (just inside the paren)
+// and the index of the closing paren.
+func matchParen(s string, i int) (string, int) {
+	depth := 1
+	for j := i; j < len(s); j++ {
+		switch s[j] {
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return s[i:j], j
+			}
+		}
+	}
+	return s[i:], len(s)
+}
@@ -0,0 +1,309 @@
+package analyzer
+
+import (
+	"strings"
+)
+
+// SQL statement classes, from least to most dangerous.
+const (
+	SQLClassRead        = "read"        // SELECT, SHOW, EXPLAIN, ...
+	SQLClassWrite       = "write"       // INSERT, UPDATE/DELETE with WHERE, MERGE, ...
+	SQLClassDDL         = "ddl"         // CREATE, ALTER, RENAME, ...
+	SQLClassAdmin       = "admin"       // GRANT, REVOKE, ...
+	SQLClassDestructive = "destructive" // DROP, TRUNCATE, UPDATE/DELETE without WHERE
+	SQLClassUnknown     = "unknown"
+)
+
+// DefaultSQLMethods are the database methods classified when the policy names none.
+var DefaultSQLMethods = []string{"db:*", "sql:*", "database:*", "postgres:*", "mysql:*", "sqlite:*"}
+
+const (
+	maxSQLStatements = 64
+	maxSQLTables     = 16
+	maxSQLLen        = 256 * 1024
+	maxSQLTokens     = 4096
+)
+
+var sqlVerbClass = map[string]string{
+	"SELECT": SQLClassRead, "SHOW": SQLClassRead, "EXPLAIN": SQLClassRead, "DESCRIBE": SQLClassRead,
+	"DESC": SQLClassRead, "VALUES": SQLClassRead, "PRAGMA": SQLClassRead,
+	"INSERT": SQLClassWrite, "UPDATE": SQLClassWrite, "DELETE": SQLClassWrite, "MERGE": SQLClassWrite,
+	"UPSERT": SQLClassWrite, "REPLACE": SQLClassWrite, "COPY": SQLClassWrite,
+	"CREATE": SQLClassDDL, "ALTER": SQLClassDDL, "RENAME": SQLClassDDL, "COMMENT": SQLClassDDL,
+	"GRANT": SQLClassAdmin, "REVOKE": SQLClassAdmin,
+	"DROP": SQLClassDestructive, "TRUNCATE": SQLClassDestructive,
+}
+
+// tableKeywords are followed by a table name (after optional modifiers).
+var tableKeywords = map[string]bool{"FROM": true, "JOIN": true, "INTO": true, "UPDATE": true, "TABLE": true, "TRUNCATE": true}
+
+// tableModifiers may sit between a table keyword and the name.
+var tableModifiers = map[string]bool{"TABLE": true, "IF": true, "EXISTS": true, "NOT": true, "ONLY": true, "LATERAL": true, "IGNORE": true, "LOW_PRIORITY": true}
+
+// SQLStatement is the classification of one statement.
+type SQLStatement struct {
+	Verb      string   `json:"verb"`
+	Class     string   `json:"class"`
+	Tables    []string `json:"tables,omitempty"`
+	Unbounded bool     `json:"unbounded,omitempty"` // UPDATE/DELETE without WHERE
+}
+
+// ClassifySQL splits a SQL string into statements and classifies each one.
+// It is a lexical classifier for policy decisions, not a parser: string literals and
+// comments are skipped, CTEs resolve to their main verb, and table names are taken from
+// FROM/JOIN/INTO/UPDATE/TABLE clauses.
+func ClassifySQL(sql string) []SQLStatement {
+	if len(sql) > maxSQLLen {
+		sql = sql[:maxSQLLen]
+	}
+	var out []SQLStatement
+	stmts := splitSQL(sql)
+	for i := 0; i < len(stmts) && i < maxSQLStatements; i++ {
+		if len(stmts[i]) == 0 {
+			continue
+		}
+		out = append(out, classifyTokens(stmts[i]))
+	}
+	return out
+}
+
+func classifyTokens(tokens []string) SQLStatement {
+	verb := strings.ToUpper(tokens[0])
+	// WITH ... AS (...) <verb>: the main verb follows the last CTE body at depth 0.
+	if verb == "WITH" {
+		verb = mainVerbAfterCTE(tokens)
+	}
+	class, ok := sqlVerbClass[verb]
+	if !ok {
+		class = SQLClassUnknown
+	}
+	st := SQLStatement{Verb: verb, Class: class, Tables: extractTables(tokens)}
+	if verb == "UPDATE" || verb == "DELETE" {
+		if !containsWord(tokens, "WHERE") {
+			st.Unbounded = true
+			st.Class = SQLClassDestructive
+		}
+	}
+	return st
+}
+
+func mainVerbAfterCTE(tokens []string) string {
+	depth := 0
+	for i := 1; i < len(tokens) && i < maxSQLTokens; i++ {
+		switch tokens[i] {
+		case "(":
+			depth++
+		case ")":
+			depth--
+		default:
+			up := strings.ToUpper(tokens[i])
+			if _, known := sqlVerbClass[up]; depth == 0 && known {
+				return up
+			}
+		}
+	}
+	return "WITH"
+}
+
+func extractTables(tokens []string) []string {
+	var tables []string
+	seen := map[string]bool{}
+	for i := 0; i < len(tokens) && i < maxSQLTokens; i++ {
+		if !tableKeywords[strings.ToUpper(tokens[i])] {
+			continue
+		}
+		j := i + 1
+		for j < len(tokens) && tableModifiers[strings.ToUpper(tokens[j])] {
+			j++
+		}
+		if j >= len(tokens) || tokens[j] == "(" || tokens[j] == "," {
+			continue
+		}
+		name := normalizeIdentifier(tokens[j])
+		if name == "" || seen[name] || len(tables) >= maxSQLTables {
+			continue
+		}
+		if _, isVerb := sqlVerbClass[strings.ToUpper(name)]; isVerb {
+			continue
+		}
+		seen[name] = true
+		tables = append(tables, name)
+	}
+	return tables
+}
+
+// normalizeIdentifier strips identifier quoting ("x", `x`, [x]) from each dotted part.
+func normalizeIdentifier(tok string) string {
+	parts := strings.Split(tok, ".")
+	for i := 0; i < len(parts); i++ {
+		parts[i] = strings.Trim(parts[i], "\"`[]")
+	}
+	name := strings.ToLower(strings.Join(parts, "."))
+	if strings.ContainsAny(name, "'?$:@") {
+		return ""
+	}
+	return name
+}
+
+func containsWord(tokens []string, word string) bool {
+	for i := 0; i < len(tokens) && i < maxSQLTokens; i++ {
+		if strings.EqualFold(tokens[i], word) {
+			return true
+		}
+	}
+	return false
+}
+
+// splitSQL tokenizes SQL into statements of word/punctuation tokens.
+// String literals become a single "'" token; comments are dropped.
+func splitSQL(sql string) [][]string {
+	var stmts [][]string
+	var cur []string
+	flush := func() {
+		if len(cur) > 0 && len(stmts) < maxSQLStatements {
+			stmts = append(stmts, cur)
+		}
+		cur = nil
+	}
+	for i := 0; i < len(sql); i++ {
+		c := sql[i]
+		switch {
+		case c == '-' && i+1 < len(sql) && sql[i+1] == '-':
+			i = skipUntil(sql, i, "\n")
+		case c == '/' && i+1 < len(sql) && sql[i+1] == '*':
+			i = skipUntil(sql, i+2, "*/")
+		case c == '\'':
+			i = skipQuoted(sql, i, '\'')
+			cur = appendToken(cur, "'")
+		case c == ';':
+			flush()
+		case c == '(' || c == ')' || c == ',':
+			cur = appendToken(cur, string(c))
+		case isSQLWordChar(c) && c != ']':
+			start := i
+			i = scanSQLWord(sql, i)
+			cur = appendToken(cur, sql[start:i+1])
+		}
+	}
+	flush()
+	return stmts
+}
+
+func appendToken(tokens []string, tok string) []string {
+	if len(tokens) >= maxSQLTokens {
+		return tokens
+	}
+	return append(tokens, tok)
+}
+
+func isSQLWordChar(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' ||
+		c == '_' || c == '.' || c == '"' || c == '`' || c == '[' || c == ']' || c == '$' || c == '@' || c == '?' || c == ':'
+}
+
+// scanSQLWord returns the index of the last byte of the word starting at i.
+// Quoted identifiers may contain spaces.
+func scanSQLWord(sql string, i int) int {
+	for ; i < len(sql); i++ {
+		c := sql[i]
+		if c == '"' || c == '`' || c == '[' {
+			closer := c
+			if c == '[' {
+				closer = ']'
+			}
+			i = skipQuoted(sql, i, closer)
+			continue
+		}
+		if !isSQLWordChar(c) || c == ']' {
+			return i - 1
+		}
+	}
+	return len(sql) - 1
+}
+
+// skipQuoted returns the index of the closing quote for the literal opened at i.
+// A doubled quote (”) is an escaped quote, not a terminator.
+func skipQuoted(sql string, i int, quote byte) int {
+	for j := i + 1; j < len(sql); j++ {
+		if sql[j] != quote {
+			continue
+		}
+		if j+1 < len(sql) && sql[j+1] == quote && quote == '\'' {
+			j++
+			continue
+		}
+		return j
+	}
+	return len(sql) - 1
+}
+
+func skipUntil(sql string, i int, end string) int {
+	idx := strings.Index(sql[i:], end)
+	if idx < 0 {
+		return len(sql) - 1
+	}
+	return i + idx + len(end) - 1
+}
+
+// SQLOptions tunes DetectSQL. Zero values select the defaults.
+type SQLOptions struct {
+	Methods []string // database method patterns
+}
+
+// DetectSQL classifies SQL found in the query/sql/statement params of database methods.
+// Returns nil for other methods or when no SQL is present.
+func DetectSQL(method string, params map[string]interface{}, opts SQLOptions) []SQLStatement {
+	if method == "" || !matchMethods(opts.Methods, DefaultSQLMethods, method) {
+		return nil
+	}
+	var out []SQLStatement
+	for _, key := range []string{"query", "sql", "statement", "statements", "queries"} {
+		switch v := params[key].(type) {
+		case string:
+			out = append(out, ClassifySQL(v)...)
+		case []interface{}:
+			for i := 0; i < len(v) && i < maxSQLStatements; i++ {
+				if s, ok := v[i].(string); ok {
+					out = append(out, ClassifySQL(s)...)
+				}
+			}
+		}
+	}
+	if len(out) > maxSQLStatements {
+		out = out[:maxSQLStatements]
+	}
+	return out
+}
+
+// SQLMatchKeys returns the lowercase classes and verbs present in stmts, used by
+// policy rules' match_sql.
+func SQLMatchKeys(stmts []SQLStatement) map[string]bool {
+	keys := make(map[string]bool)
+	for i := 0; i < len(stmts) && i < maxSQLStatements; i++ {
+		keys[stmts[i].Class] = true
+		keys[strings.ToLower(stmts[i].Verb)] = true
+	}
+	return keys
+}
+
+// SQLTags returns event tags for stmts: one sql_<class> tag per class and one
+// sql_table:<name> tag per table.
+func SQLTags(stmts []SQLStatement) []string {
+	var tags []string
+	seen := map[string]bool{}
+	add := func(tag string) {
+		if !seen[tag] && len(tags) < maxSQLTables*2 {
+			seen[tag] = true
+			tags = append(tags, tag)
+		}
+	}
+	for i := 0; i < len(stmts) && i < maxSQLStatements; i++ {
+		add("sql_" + stmts[i].Class)
+	}
+	for i := 0; i < len(stmts) && i < maxSQLStatements; i++ {
+		for j := 0; j < len(stmts[i].Tables) && j < maxSQLTables; j++ {
+			add("sql_table:" + stmts[i].Tables[j])
+		}
+	}
+	return tags
+}
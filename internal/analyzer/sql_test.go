@@ -0,0 +1,68 @@
+package analyzer
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestClassifySQL(t *testing.T) {
+	tests := []struct {
+		sql    string
+		verb   string
+		class  string
+		tables []string
+	}{
+		{"SELECT id FROM users u JOIN orders o ON o.uid = u.id", "SELECT", SQLClassRead, []string{"users", "orders"}},
+		{"select * from public.\"Accounts\" where id = $1", "SELECT", SQLClassRead, []string{"public.accounts"}},
+		{"INSERT INTO audit_log (msg) VALUES ('DROP TABLE users;')", "INSERT", SQLClassWrite, []string{"audit_log"}},
+		{"UPDATE accounts SET balance = 0 WHERE id = 7", "UPDATE", SQLClassWrite, []string{"accounts"}},
+		{"DELETE FROM sessions", "DELETE", SQLClassDestructive, []string{"sessions"}},
+		{"DROP TABLE IF EXISTS customers", "DROP", SQLClassDestructive, []string{"customers"}},
+		{"TRUNCATE TABLE `events`", "TRUNCATE", SQLClassDestructive, []string{"events"}},
+		{"ALTER TABLE users ADD COLUMN age int", "ALTER", SQLClassDDL, []string{"users"}},
+		{"GRANT ALL ON payroll TO intern", "GRANT", SQLClassAdmin, nil},
+		{"WITH recent AS (SELECT * FROM logins) DELETE FROM logins WHERE id IN (SELECT id FROM recent)", "DELETE", SQLClassWrite, []string{"logins", "recent"}},
+		{"-- cleanup\n/* nightly */ SELECT 1", "SELECT", SQLClassRead, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.sql, func(t *testing.T) {
+			stmts := ClassifySQL(tt.sql)
+			if len(stmts) != 1 {
+				t.Fatalf("expected 1 statement, got %+v", stmts)
+			}
+			st := stmts[0]
+			if st.Verb != tt.verb || st.Class != tt.class {
+				t.Errorf("got %s/%s, want %s/%s", st.Verb, st.Class, tt.verb, tt.class)
+			}
+			if !reflect.DeepEqual(st.Tables, tt.tables) {
+				t.Errorf("tables = %v, want %v", st.Tables, tt.tables)
+			}
+		})
+	}
+}
+
+func TestClassifySQL_MultipleStatements(t *testing.T) {
+	stmts := ClassifySQL("SELECT 1; DROP TABLE users; ")
+	if len(stmts) != 2 || stmts[1].Verb != "DROP" {
+		t.Fatalf("expected SELECT then DROP, got %+v", stmts)
+	}
+	keys := SQLMatchKeys(stmts)
+	if !keys["drop"] || !keys[SQLClassDestructive] || !keys[SQLClassRead] {
+		t.Errorf("unexpected match keys: %v", keys)
+	}
+	tags := SQLTags(stmts)
+	want := []string{"sql_read", "sql_destructive", "sql_table:users"}
+	if !reflect.DeepEqual(tags, want) {
+		t.Errorf("tags = %v, want %v", tags, want)
+	}
+}
+
+func TestDetectSQL(t *testing.T) {
+	params := map[string]interface{}{"statements": []interface{}{"DELETE FROM a WHERE x = 1", "DROP TABLE b"}}
+	if got := DetectSQL("db:execute", params, SQLOptions{}); len(got) != 2 {
+		t.Fatalf("expected 2 statements, got %+v", got)
+	}
+	if DetectSQL("http:post", map[string]interface{}{"query": "DROP TABLE b"}, SQLOptions{}) != nil {
+		t.Error("non-database methods should not be classified")
+	}
+}
@@ -0,0 +1,142 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/slyt3/Logryph/internal/ledger"
+	"github.com/slyt3/Logryph/internal/logging"
+	"github.com/slyt3/Logryph/internal/models"
+	"github.com/slyt3/Logryph/internal/pool"
+)
+
+const maxAnnotationBody = 16 * 1024
+
+// AnnotationRequest is the body of POST /api/annotations.
+type AnnotationRequest struct {
+	EventID string            `json:"event_id"`
+	Note    string            `json:"note"`
+	Author  string            `json:"author,omitempty"`
+	Labels  map[string]string `json:"labels,omitempty"` // applied to the annotated event
+}
+
+// AnnotationResponse acknowledges a queued annotation.
+type AnnotationResponse struct {
+	ID      string `json:"id"`
+	EventID string `json:"event_id"`
+}
+
+// HandleAnnotations records investigator notes and labels on ledgered events.
+// GET ?event_id=X lists the annotations on an event; POST with an AnnotationRequest body
+// ledgers a signed annotation event linked to the target and returns 202 with its ID.
+// Labels in the request are carried by the annotation event and apply to the target.
+// Requires X-Admin-Token header if LOGRYPH_ADMIN_TOKEN is set.
+func (h *Handlers) HandleAnnotations(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !authorizeAdmin(w, r) {
+		return
+	}
+	if h.Core == nil || h.Core.Worker == nil {
+		http.Error(w, "ledger unavailable", http.StatusServiceUnavailable)
+		return
+	}
+	if r.Method == http.MethodGet {
+		h.listAnnotations(w, r.URL.Query().Get("event_id"))
+		return
+	}
+
+	var req AnnotationRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxAnnotationBody)).Decode(&req); err != nil {
+		http.Error(w, "invalid annotation body", http.StatusBadRequest)
+		return
+	}
+	req.Note = strings.TrimSpace(req.Note)
+	if req.EventID == "" || len(req.EventID) > maxEventIDLen {
+		http.Error(w, "event_id is required", http.StatusBadRequest)
+		return
+	}
+	if (req.Note == "" && len(req.Labels) == 0) || len(req.Note) > models.MaxAnnotationNoteLen || len(req.Author) > models.MaxAnnotationAuthorLen {
+		http.Error(w, fmt.Sprintf("note or labels required (note max %d bytes), author max %d bytes", models.MaxAnnotationNoteLen, models.MaxAnnotationAuthorLen), http.StatusBadRequest)
+		return
+	}
+	if err := models.ValidateLabels(req.Labels); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.Author == "" {
+		req.Author = defaultApprover
+	}
+
+	target, err := h.Core.Worker.GetDB().GetEventByID(req.EventID)
+	if errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, "event not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	id := h.submitAnnotation(target, req)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	if err := json.NewEncoder(w).Encode(AnnotationResponse{ID: id, EventID: target.ID}); err != nil {
+		logging.Error("annotation_encode_failed", logging.Fields{Component: "api", Error: err.Error()})
+	}
+}
+
+// submitAnnotation ledgers the note as a user event that is hashed, signed and chained
+// like the calls it annotates. Returns the annotation event ID.
+func (h *Handlers) submitAnnotation(target *models.Event, req AnnotationRequest) string {
+	event := pool.GetEvent()
+	event.ID = uuid.New().String()[:8]
+	event.Timestamp = time.Now()
+	event.Actor = "user"
+	event.EventType = models.EventTypeAnnotation
+	event.Method = "logryph:annotate"
+	event.TaskID = target.TaskID
+	event.ParentID = target.ID
+	event.Environment = target.Environment
+	event.Params["event_id"] = target.ID
+	event.Params["author"] = req.Author
+	event.Params["note"] = req.Note
+	event.Labels = req.Labels
+	id := event.ID
+
+	h.Core.Worker.Submit(event)
+	logging.Info("annotation_submitted", logging.Fields{Component: "api", EventID: target.ID, TaskID: target.TaskID})
+	return id
+}
+
+func (h *Handlers) listAnnotations(w http.ResponseWriter, eventID string) {
+	if eventID == "" || len(eventID) > maxEventIDLen {
+		http.Error(w, "event_id is required", http.StatusBadRequest)
+		return
+	}
+	reader, ok := h.Core.Worker.GetDB().(ledger.AnnotationReader)
+	if !ok {
+		http.Error(w, "annotations not supported by this ledger", http.StatusNotImplemented)
+		return
+	}
+	annotations, err := reader.GetAnnotations(eventID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if annotations == nil {
+		annotations = []models.Annotation{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(annotations); err != nil {
+		logging.Error("annotations_encode_failed", logging.Fields{Component: "api", Error: err.Error()})
+	}
+}
@@ -0,0 +1,63 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/slyt3/Logryph/internal/models"
+)
+
+func TestHandleAnnotations_RecordsChainedEvent(t *testing.T) {
+	engine, worker, cleanup := setupTestEngine(t)
+	defer cleanup()
+
+	emitTestEvent(worker)
+	waitForProcessed(t, worker, 1, 2*time.Second)
+	h := NewHandlers(engine)
+
+	body := `{"event_id":"evt-test","note":"confirmed false positive","author":"alice"}`
+	rec := httptest.NewRecorder()
+	h.HandleAnnotations(rec, httptest.NewRequest(http.MethodPost, "/api/annotations", strings.NewReader(body)))
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("unexpected status: %d %s", rec.Code, rec.Body.String())
+	}
+	var ack AnnotationResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &ack); err != nil || ack.ID == "" {
+		t.Fatalf("invalid response: %v %s", err, rec.Body.String())
+	}
+	waitForProcessed(t, worker, 2, 2*time.Second)
+
+	event, err := worker.GetDB().GetEventByID(ack.ID)
+	if err != nil {
+		t.Fatalf("annotation event not ledgered: %v", err)
+	}
+	if event.EventType != models.EventTypeAnnotation || event.ParentID != "evt-test" || event.Signature == "" {
+		t.Errorf("unexpected annotation event: %+v", event)
+	}
+
+	rec = httptest.NewRecorder()
+	h.HandleAnnotations(rec, httptest.NewRequest(http.MethodGet, "/api/annotations?event_id=evt-test", nil))
+	var annotations []models.Annotation
+	if err := json.Unmarshal(rec.Body.Bytes(), &annotations); err != nil {
+		t.Fatalf("invalid list response: %v", err)
+	}
+	if len(annotations) != 1 || annotations[0].Note != "confirmed false positive" || annotations[0].Author != "alice" {
+		t.Errorf("unexpected annotations: %+v", annotations)
+	}
+}
+
+func TestHandleAnnotations_UnknownEvent(t *testing.T) {
+	engine, _, cleanup := setupTestEngine(t)
+	defer cleanup()
+
+	rec := httptest.NewRecorder()
+	body := `{"event_id":"missing","note":"x"}`
+	NewHandlers(engine).HandleAnnotations(rec, httptest.NewRequest(http.MethodPost, "/api/annotations", strings.NewReader(body)))
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("expected 404, got %d", rec.Code)
+	}
+}
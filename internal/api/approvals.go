@@ -0,0 +1,409 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/slyt3/Logryph/internal/approval"
+	"github.com/slyt3/Logryph/internal/logging"
+	"github.com/slyt3/Logryph/internal/pool"
+)
+
+// ApproverHeader optionally names the operator deciding on a stall.
+const ApproverHeader = "X-Logryph-Approver"
+
+const (
+	defaultApprover = "admin-api"
+	maxEventIDLen   = 64
+	maxTicketIDLen  = 256
+	maxTaskIDLen    = 256
+)
+
+// PendingApproval is a stalled call with how long it has waited and how long is left.
+type PendingApproval struct {
+	approval.Request
+	AgeSeconds       float64 `json:"age_s"`
+	ExpiresInSeconds float64 `json:"expires_in_s"`
+	NearExpiry       bool    `json:"near_expiry,omitempty"` // under a quarter of the stall window left
+}
+
+// PendingTask is the stalls of one task, oldest first. Calls made without a task ID are
+// grouped under an empty TaskID.
+type PendingTask struct {
+	TaskID           string            `json:"task_id"`
+	OldestAgeSeconds float64           `json:"oldest_age_s"`
+	NearExpiry       bool              `json:"near_expiry,omitempty"` // any of its stalls is near expiry
+	Stalls           []PendingApproval `json:"stalls"`
+}
+
+// HandlePendingApprovals lists calls currently stalled in enforce mode, oldest first,
+// flagging those close to timing out. With group=task they are grouped by task, tasks
+// ordered by their oldest stall. Served as /api/pending and /api/approvals.
+// Requires X-Admin-Token header if LOGRYPH_ADMIN_TOKEN is set.
+func (h *Handlers) HandlePendingApprovals(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !authorizeAdmin(w, r) {
+		return
+	}
+	group := r.URL.Query().Get("group")
+	if group != "" && group != "task" {
+		http.Error(w, "group must be task", http.StatusBadRequest)
+		return
+	}
+	pending := []PendingApproval{}
+	if h.Core != nil && h.Core.Approvals != nil {
+		now := time.Now()
+		stalls := h.Core.Approvals.Pending()
+		for i := 0; i < len(stalls); i++ {
+			pending = append(pending, PendingApproval{
+				Request:          stalls[i],
+				AgeSeconds:       now.Sub(stalls[i].CreatedAt).Seconds(),
+				ExpiresInSeconds: stalls[i].Deadline.Sub(now).Seconds(),
+				NearExpiry:       stalls[i].NearExpiry(now),
+			})
+		}
+	}
+	var body interface{} = pending
+	if group == "task" {
+		body = groupByTask(pending)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		logging.Error("approvals_encode_failed", logging.Fields{Component: "api", Error: err.Error()})
+	}
+}
+
+// groupByTask groups stalls, given oldest first, by task. Tasks keep the order of their
+// oldest stall.
+func groupByTask(pending []PendingApproval) []PendingTask {
+	tasks := []PendingTask{}
+	index := make(map[string]int)
+	for i := 0; i < len(pending); i++ {
+		p := pending[i]
+		n, ok := index[p.TaskID]
+		if !ok {
+			n = len(tasks)
+			index[p.TaskID] = n
+			tasks = append(tasks, PendingTask{TaskID: p.TaskID, OldestAgeSeconds: p.AgeSeconds})
+		}
+		tasks[n].Stalls = append(tasks[n].Stalls, p)
+		tasks[n].NearExpiry = tasks[n].NearExpiry || p.NearExpiry
+	}
+	return tasks
+}
+
+// HandleApprove releases a stalled call to the upstream server.
+// Requires POST, an event_id query parameter, and X-Admin-Token if configured.
+// Returns 404 if the event is not awaiting approval.
+func (h *Handlers) HandleApprove(w http.ResponseWriter, r *http.Request) {
+	h.handleDecision(w, r, approval.DecisionApproved)
+}
+
+// HandleReject answers a stalled call with a JSON-RPC error instead of forwarding it.
+// Same contract as HandleApprove.
+func (h *Handlers) HandleReject(w http.ResponseWriter, r *http.Request) {
+	h.handleDecision(w, r, approval.DecisionRejected)
+}
+
+func (h *Handlers) handleDecision(w http.ResponseWriter, r *http.Request, decision approval.Decision) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !authorizeAdmin(w, r) {
+		return
+	}
+	eventID := r.URL.Query().Get("event_id")
+	if eventID == "" || len(eventID) > maxEventIDLen {
+		http.Error(w, "event_id is required", http.StatusBadRequest)
+		return
+	}
+	if h.Core == nil || h.Core.Approvals == nil {
+		http.Error(w, "approvals unavailable", http.StatusServiceUnavailable)
+		return
+	}
+	approver := r.Header.Get(ApproverHeader)
+	if approver == "" {
+		approver = defaultApprover
+	}
+
+	if err := h.Core.Approvals.Resolve(eventID, decision, approver); err != nil {
+		if errors.Is(err, approval.ErrNotPending) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	logging.Info("approval_"+string(decision), logging.Fields{Component: "api", EventID: eventID})
+
+	if _, err := fmt.Fprintf(w, "Event %s %s by %s\n", eventID, decision, approver); err != nil {
+		logging.Error("approval_response_write_failed", logging.Fields{Component: "api", Error: err.Error()})
+	}
+}
+
+// TaskDecisionResponse reports a decision taken on every stall of a task at once.
+// GroupID is recorded on the stall_group_resolved event and on each stall's
+// stall_resolved event.
+type TaskDecisionResponse struct {
+	GroupID  string   `json:"group_id"`
+	TaskID   string   `json:"task_id"`
+	Decision string   `json:"decision"`
+	Approver string   `json:"approver"`
+	EventIDs []string `json:"event_ids"`
+}
+
+// HandleApproveTask releases every call of a task that is stalled now, together. Calls
+// of the task that stall afterwards wait for their own decision. Requires POST, a task_id
+// query parameter, and the bulk approval token if configured, else the admin token.
+// Returns 404 if the task has no stalled calls.
+func (h *Handlers) HandleApproveTask(w http.ResponseWriter, r *http.Request) {
+	h.handleTaskDecision(w, r, approval.DecisionApproved)
+}
+
+// HandleRejectTask refuses every call of a task that is stalled now, together. Same
+// contract as HandleApproveTask.
+func (h *Handlers) HandleRejectTask(w http.ResponseWriter, r *http.Request) {
+	h.handleTaskDecision(w, r, approval.DecisionRejected)
+}
+
+func (h *Handlers) handleTaskDecision(w http.ResponseWriter, r *http.Request, decision approval.Decision) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !authorizeBulk(w, r) {
+		return
+	}
+	taskID := r.URL.Query().Get("task_id")
+	if taskID == "" || len(taskID) > maxTaskIDLen {
+		http.Error(w, "task_id is required", http.StatusBadRequest)
+		return
+	}
+	if h.Core == nil || h.Core.Approvals == nil {
+		http.Error(w, "approvals unavailable", http.StatusServiceUnavailable)
+		return
+	}
+	approver := r.Header.Get(ApproverHeader)
+	if approver == "" {
+		approver = defaultApprover
+	}
+
+	groupID := uuid.New().String()[:8]
+	decided, err := h.Core.Approvals.ResolveTask(taskID, groupID, decision, approver)
+	if err != nil {
+		if errors.Is(err, approval.ErrNotPending) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	resp := TaskDecisionResponse{GroupID: groupID, TaskID: taskID, Decision: string(decision), Approver: approver, EventIDs: make([]string, 0, len(decided))}
+	for i := 0; i < len(decided); i++ {
+		resp.EventIDs = append(resp.EventIDs, decided[i].EventID)
+	}
+	h.submitGroupDecision(resp)
+	logging.Info("approval_task_"+string(decision), logging.Fields{Component: "api", TaskID: taskID})
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		logging.Error("approval_task_encode_failed", logging.Fields{Component: "api", Error: err.Error()})
+	}
+}
+
+// submitGroupDecision ledgers a task decision once, listing the stalls it decided. Each
+// stall also records its own stall_resolved event carrying the group ID.
+func (h *Handlers) submitGroupDecision(resp TaskDecisionResponse) {
+	if h.Core.Worker == nil {
+		return
+	}
+	eventIDs := make([]interface{}, 0, len(resp.EventIDs))
+	for i := 0; i < len(resp.EventIDs); i++ {
+		eventIDs = append(eventIDs, resp.EventIDs[i])
+	}
+	event := pool.GetEvent()
+	event.ID = uuid.New().String()[:8]
+	event.Timestamp = time.Now()
+	event.Actor = "user"
+	event.EventType = "stall_group_resolved"
+	event.Method = "logryph:approval"
+	event.TaskID = resp.TaskID
+	event.Params["group_id"] = resp.GroupID
+	event.Params["decision"] = resp.Decision
+	event.Params["approver"] = resp.Approver
+	event.Params["event_ids"] = eventIDs
+	event.WasBlocked = resp.Decision != string(approval.DecisionApproved)
+
+	h.Core.Worker.Submit(event)
+}
+
+// HandleApprovalTicket links a stalled call to its ticket in an external approval system,
+// which the approval poller then asks about. Requires POST, event_id and ticket_id query
+// parameters, and X-Admin-Token if configured. Returns 404 if the event is not awaiting
+// approval.
+func (h *Handlers) HandleApprovalTicket(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !authorizeAdmin(w, r) {
+		return
+	}
+	eventID, ticketID := r.URL.Query().Get("event_id"), r.URL.Query().Get("ticket_id")
+	if eventID == "" || len(eventID) > maxEventIDLen || ticketID == "" || len(ticketID) > maxTicketIDLen {
+		http.Error(w, "event_id and ticket_id are required", http.StatusBadRequest)
+		return
+	}
+	if h.Core == nil || h.Core.Approvals == nil {
+		http.Error(w, "approvals unavailable", http.StatusServiceUnavailable)
+		return
+	}
+	if err := h.Core.Approvals.SetTicket(eventID, ticketID); err != nil {
+		if errors.Is(err, approval.ErrNotPending) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	logging.Info("approval_ticket_linked", logging.Fields{Component: "api", EventID: eventID})
+	if _, err := fmt.Fprintf(w, "Event %s linked to ticket %s\n", eventID, ticketID); err != nil {
+		logging.Error("approval_response_write_failed", logging.Fields{Component: "api", Error: err.Error()})
+	}
+}
+
+// BulkApprovalTokenEnv names the token that gates batch decisions. When it is set, only
+// requests presenting it as X-Admin-Token may decide in bulk; the admin token alone
+// still decides one call at a time.
+const BulkApprovalTokenEnv = "LOGRYPH_BULK_APPROVAL_TOKEN"
+
+const (
+	maxBatchBody     = 64 * 1024
+	maxBatchDecision = 1000
+)
+
+// BatchDecisionRequest selects the stalls a batch decides: the listed EventIDs, or with
+// All every pending stall matching the non-empty filters.
+type BatchDecisionRequest struct {
+	EventIDs []string `json:"event_ids,omitempty"`
+	All      bool     `json:"all,omitempty"`
+	PolicyID string   `json:"policy_id,omitempty"`
+	TaskID   string   `json:"task_id,omitempty"`
+	Method   string   `json:"method,omitempty"`
+}
+
+// BatchItemResult is the outcome for one stall of a batch: the decision on success,
+// not_pending if it was already resolved or expired, else error.
+type BatchItemResult struct {
+	EventID string `json:"event_id"`
+	Status  string `json:"status"`
+	Error   string `json:"error,omitempty"`
+}
+
+// BatchDecisionResponse reports a batch item by item.
+type BatchDecisionResponse struct {
+	Decision string            `json:"decision"`
+	Approver string            `json:"approver"`
+	Decided  int               `json:"decided"`
+	Results  []BatchItemResult `json:"results"`
+}
+
+// HandleApproveBatch releases several stalled calls at once. POST a BatchDecisionRequest;
+// the response has a result per stall, so a stall resolved meanwhile does not fail the
+// rest. Requires the bulk approval token if configured, else the admin token.
+func (h *Handlers) HandleApproveBatch(w http.ResponseWriter, r *http.Request) {
+	h.handleBatchDecision(w, r, approval.DecisionApproved)
+}
+
+// HandleRejectBatch refuses several stalled calls at once. Same contract as
+// HandleApproveBatch.
+func (h *Handlers) HandleRejectBatch(w http.ResponseWriter, r *http.Request) {
+	h.handleBatchDecision(w, r, approval.DecisionRejected)
+}
+
+func (h *Handlers) handleBatchDecision(w http.ResponseWriter, r *http.Request, decision approval.Decision) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !authorizeBulk(w, r) {
+		return
+	}
+	if h.Core == nil || h.Core.Approvals == nil {
+		http.Error(w, "approvals unavailable", http.StatusServiceUnavailable)
+		return
+	}
+	var req BatchDecisionRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBatchBody)).Decode(&req); err != nil {
+		http.Error(w, "invalid batch body", http.StatusBadRequest)
+		return
+	}
+	if req.All == (len(req.EventIDs) > 0) || len(req.EventIDs) > maxBatchDecision {
+		http.Error(w, fmt.Sprintf("give either event_ids (up to %d) or all with optional filters", maxBatchDecision), http.StatusBadRequest)
+		return
+	}
+	approver := r.Header.Get(ApproverHeader)
+	if approver == "" {
+		approver = defaultApprover
+	}
+
+	ids := req.EventIDs
+	if req.All {
+		ids = matchPending(h.Core.Approvals.Pending(), req)
+	}
+	resp := BatchDecisionResponse{Decision: string(decision), Approver: approver, Results: make([]BatchItemResult, 0, len(ids))}
+	for i := 0; i < len(ids); i++ {
+		res := BatchItemResult{EventID: ids[i], Status: string(decision)}
+		if err := h.Core.Approvals.Resolve(ids[i], decision, approver); errors.Is(err, approval.ErrNotPending) {
+			res.Status = "not_pending"
+		} else if err != nil {
+			res.Status, res.Error = "error", err.Error()
+		} else {
+			resp.Decided++
+			logging.Info("approval_"+string(decision), logging.Fields{Component: "api", EventID: ids[i]})
+		}
+		resp.Results = append(resp.Results, res)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		logging.Error("approval_batch_encode_failed", logging.Fields{Component: "api", Error: err.Error()})
+	}
+}
+
+// matchPending returns the pending stalls matching every non-empty filter of req.
+func matchPending(pending []approval.Request, req BatchDecisionRequest) []string {
+	var ids []string
+	for i := 0; i < len(pending) && len(ids) < maxBatchDecision; i++ {
+		p := pending[i]
+		if (req.PolicyID != "" && p.PolicyID != req.PolicyID) || (req.TaskID != "" && p.TaskID != req.TaskID) || (req.Method != "" && p.Method != req.Method) {
+			continue
+		}
+		ids = append(ids, p.EventID)
+	}
+	return ids
+}
+
+// authorizeBulk gates batch decisions on the bulk approval token when one is set.
+func authorizeBulk(w http.ResponseWriter, r *http.Request) bool {
+	token, ok := tokenFromEnv(w, BulkApprovalTokenEnv)
+	if !ok {
+		return false
+	}
+	if token == "" || socketAuthorized(r) {
+		return authorizeAdmin(w, r)
+	}
+	if !tokenMatches(r.Header.Get("X-Admin-Token"), token) {
+		http.Error(w, "Unauthorized: batch decisions need the bulk approval token", http.StatusUnauthorized)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,175 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/slyt3/Logryph/internal/approval"
+	"github.com/slyt3/Logryph/internal/core"
+	"github.com/slyt3/Logryph/internal/ledger/store"
+)
+
+func TestHandleApproveBatch_FiltersAndReportsPerItem(t *testing.T) {
+	registry := approval.NewRegistry(0)
+	deadline := time.Now().Add(time.Minute)
+	for _, req := range []approval.Request{
+		{EventID: "e1", Method: "aws:deploy", PolicyID: "critical-infra", Deadline: deadline},
+		{EventID: "e2", Method: "aws:deploy", PolicyID: "critical-infra", Deadline: deadline},
+		{EventID: "e3", Method: "db:drop", PolicyID: "data", Deadline: deadline},
+	} {
+		if err := registry.Register(req); err != nil {
+			t.Fatal(err)
+		}
+	}
+	h := NewHandlers(&core.Engine{Approvals: registry})
+	batch := func(body string, header map[string]string) (int, BatchDecisionResponse) {
+		req := httptest.NewRequest(http.MethodPost, "/api/approve/batch", strings.NewReader(body))
+		for k, v := range header {
+			req.Header.Set(k, v)
+		}
+		rec := httptest.NewRecorder()
+		h.HandleApproveBatch(rec, req)
+		var resp BatchDecisionResponse
+		if rec.Code == http.StatusOK {
+			if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("decoding %q: %v", rec.Body.String(), err)
+			}
+		}
+		return rec.Code, resp
+	}
+
+	code, resp := batch(`{"all":true,"policy_id":"critical-infra"}`, map[string]string{ApproverHeader: "alice"})
+	if code != http.StatusOK || resp.Decided != 2 || resp.Approver != "alice" || len(resp.Results) != 2 {
+		t.Fatalf("policy batch: %d %+v", code, resp)
+	}
+	if pending := registry.Pending(); len(pending) != 1 || pending[0].EventID != "e3" {
+		t.Errorf("only the data stall should remain, got %+v", pending)
+	}
+
+	_, resp = batch(`{"event_ids":["e1","e3"]}`, nil)
+	if resp.Decided != 1 || resp.Results[0].Status != "not_pending" || resp.Results[1].Status != string(approval.DecisionApproved) {
+		t.Errorf("listed batch: %+v", resp)
+	}
+	if code, _ := batch(`{}`, nil); code != http.StatusBadRequest {
+		t.Errorf("a batch selecting nothing: %d, want 400", code)
+	}
+
+	t.Setenv(BulkApprovalTokenEnv, "bulk-secret")
+	if code, _ := batch(`{"all":true}`, nil); code != http.StatusUnauthorized {
+		t.Errorf("without the bulk token: %d, want 401", code)
+	}
+	if code, _ := batch(`{"all":true}`, map[string]string{"X-Admin-Token": "bulk-secret"}); code != http.StatusOK {
+		t.Errorf("with the bulk token: %d, want 200", code)
+	}
+}
+
+func TestHandlePendingApprovals_OldestFirstWithExpiryWarning(t *testing.T) {
+	registry := approval.NewRegistry(0)
+	now := time.Now()
+	for _, req := range []approval.Request{
+		{EventID: "fresh", Method: "aws:deploy", CreatedAt: now.Add(-time.Second), Deadline: now.Add(5 * time.Minute)},
+		{EventID: "old", Method: "db:drop", TaskID: "t1", CreatedAt: now.Add(-290 * time.Second), Deadline: now.Add(10 * time.Second)},
+	} {
+		if err := registry.Register(req); err != nil {
+			t.Fatal(err)
+		}
+	}
+	rec := httptest.NewRecorder()
+	NewHandlers(&core.Engine{Approvals: registry}).HandlePendingApprovals(rec, httptest.NewRequest(http.MethodGet, "/api/pending", nil))
+	var pending []PendingApproval
+	if err := json.Unmarshal(rec.Body.Bytes(), &pending); err != nil || len(pending) != 2 {
+		t.Fatalf("pending: %v %s", err, rec.Body.String())
+	}
+	if pending[0].EventID != "old" || !pending[0].NearExpiry || pending[0].AgeSeconds < 289 || pending[0].TaskID != "t1" {
+		t.Errorf("oldest stall should come first, flagged near expiry: %+v", pending[0])
+	}
+	if pending[1].NearExpiry || pending[1].ExpiresInSeconds < 290 {
+		t.Errorf("fresh stall: %+v", pending[1])
+	}
+}
+
+func TestHandleApprovalTicket_LinksPendingStalls(t *testing.T) {
+	registry := approval.NewRegistry(0)
+	if err := registry.Register(approval.Request{EventID: "e1", Deadline: time.Now().Add(time.Minute)}); err != nil {
+		t.Fatal(err)
+	}
+	h := NewHandlers(&core.Engine{Approvals: registry})
+	link := func(query string) int {
+		rec := httptest.NewRecorder()
+		h.HandleApprovalTicket(rec, httptest.NewRequest(http.MethodPost, "/api/approvals/ticket?"+query, nil))
+		return rec.Code
+	}
+	if code := link("event_id=e1&ticket_id=CHG-7"); code != http.StatusOK {
+		t.Fatalf("link: %d", code)
+	}
+	if pending := registry.Pending(); pending[0].TicketID != "CHG-7" {
+		t.Errorf("ticket not recorded: %+v", pending[0])
+	}
+	if code := link("event_id=gone&ticket_id=CHG-8"); code != http.StatusNotFound {
+		t.Errorf("unknown event: %d, want 404", code)
+	}
+	if code := link("event_id=e1"); code != http.StatusBadRequest {
+		t.Errorf("missing ticket: %d, want 400", code)
+	}
+}
+
+func TestHandleApproveTask_ReleasesTheTaskTogether(t *testing.T) {
+	engine, worker, cleanup := setupTestEngine(t)
+	defer cleanup()
+	engine.Approvals = approval.NewRegistry(0)
+	now := time.Now()
+	for _, req := range []approval.Request{
+		{EventID: "e1", TaskID: "t1", Method: "fs:write", CreatedAt: now.Add(-290 * time.Second), Deadline: now.Add(10 * time.Second)},
+		{EventID: "e2", TaskID: "t2", Method: "db:drop", CreatedAt: now.Add(-2 * time.Second), Deadline: now.Add(time.Minute)},
+		{EventID: "e3", TaskID: "t1", Method: "fs:delete", CreatedAt: now.Add(-time.Second), Deadline: now.Add(time.Minute)},
+	} {
+		if err := engine.Approvals.Register(req); err != nil {
+			t.Fatal(err)
+		}
+	}
+	h := NewHandlers(engine)
+
+	rec := httptest.NewRecorder()
+	h.HandlePendingApprovals(rec, httptest.NewRequest(http.MethodGet, "/api/pending?group=task", nil))
+	var tasks []PendingTask
+	if err := json.Unmarshal(rec.Body.Bytes(), &tasks); err != nil || len(tasks) != 2 {
+		t.Fatalf("grouped pending: %v %s", err, rec.Body.String())
+	}
+	if tasks[0].TaskID != "t1" || len(tasks[0].Stalls) != 2 || tasks[0].Stalls[1].EventID != "e3" || !tasks[0].NearExpiry || tasks[0].OldestAgeSeconds < 289 {
+		t.Errorf("t1 should come first with both its stalls: %+v", tasks[0])
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/api/approve/task?task_id=t1", nil)
+	req.Header.Set(ApproverHeader, "alice")
+	rec = httptest.NewRecorder()
+	h.HandleApproveTask(rec, req)
+	var resp TaskDecisionResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil || rec.Code != http.StatusOK {
+		t.Fatalf("approve task: %d %s", rec.Code, rec.Body.String())
+	}
+	if len(resp.EventIDs) != 2 || resp.EventIDs[0] != "e1" || resp.EventIDs[1] != "e3" || resp.Approver != "alice" || resp.GroupID == "" {
+		t.Errorf("task decision: %+v", resp)
+	}
+	if pending := engine.Approvals.Pending(); len(pending) != 1 || pending[0].EventID != "e2" {
+		t.Errorf("only t2's stall should remain, got %+v", pending)
+	}
+
+	waitForProcessed(t, worker, 1, 2*time.Second)
+	events, err := worker.GetDB().(*store.DB).GetEventsByType("stall_group_resolved")
+	if err != nil || len(events) != 1 {
+		t.Fatalf("group decision not ledgered: %v %+v", err, events)
+	}
+	if e := events[0]; e.TaskID != "t1" || e.Params["group_id"] != resp.GroupID || e.Params["decision"] != "approved" {
+		t.Errorf("group decision event: %+v", e)
+	}
+
+	rec = httptest.NewRecorder()
+	h.HandleRejectTask(rec, httptest.NewRequest(http.MethodPost, "/api/reject/task?task_id=t1", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("a task with no stalls left: %d, want 404", rec.Code)
+	}
+}
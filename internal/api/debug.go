@@ -0,0 +1,201 @@
+package api
+
+import (
+	"encoding/json"
+	"expvar"
+	"fmt"
+	"net/http"
+	"net/http/pprof"
+	"os"
+	"path/filepath"
+	"runtime"
+	runtimepprof "runtime/pprof"
+	"strings"
+	"time"
+
+	"github.com/slyt3/Logryph/internal/assert"
+	"github.com/slyt3/Logryph/internal/logging"
+	"github.com/slyt3/Logryph/internal/secretref"
+	"gopkg.in/yaml.v3"
+)
+
+// DiagnosticsDirEnv overrides where POST /debug/snapshot writes its profiles.
+const DiagnosticsDirEnv = "LOGRYPH_DIAGNOSTICS_DIR"
+
+const defaultDiagnosticsDir = "diagnostics"
+
+var startTime = time.Now()
+
+// RuntimeSnapshot summarizes process health for support escalations.
+type RuntimeSnapshot struct {
+	Time         time.Time `json:"time"`
+	Uptime       string    `json:"uptime"`
+	GoVersion    string    `json:"go_version"`
+	NumCPU       int       `json:"num_cpu"`
+	Goroutines   int       `json:"goroutines"`
+	HeapAlloc    uint64    `json:"heap_alloc_bytes"`
+	HeapInuse    uint64    `json:"heap_inuse_bytes"`
+	Sys          uint64    `json:"sys_bytes"`
+	NumGC        uint32    `json:"num_gc"`
+	PauseTotalNs uint64    `json:"gc_pause_total_ns"`
+	Files        []string  `json:"files,omitempty"` // profiles written by POST
+}
+
+// DebugHandler serves runtime diagnostics under /debug/, all behind admin auth:
+// net/http/pprof under /debug/pprof/, expvar at /debug/vars, a runtime snapshot at
+// /debug/snapshot (POST also writes heap and goroutine profiles to disk), the loaded
+// policy at /debug/config and recent log lines at /debug/logs.
+func (h *Handlers) DebugHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	mux.Handle("/debug/vars", expvar.Handler())
+	mux.HandleFunc("/debug/snapshot", h.HandleDebugSnapshot)
+	mux.HandleFunc("/debug/config", h.HandleDebugConfig)
+	mux.HandleFunc("/debug/logs", HandleDebugLogs)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !authorizeAdmin(w, r) {
+			return
+		}
+		mux.ServeHTTP(w, r)
+	})
+}
+
+// HandleDebugSnapshot returns a RuntimeSnapshot. POST additionally forces a GC and writes
+// heap and goroutine profiles under LOGRYPH_DIAGNOSTICS_DIR (default ./diagnostics),
+// for hosts where profiles cannot be pulled over the network.
+func (h *Handlers) HandleDebugSnapshot(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var files []string
+	if r.Method == http.MethodPost {
+		runtime.GC()
+		written, err := writeSnapshotProfiles(time.Now())
+		if err != nil {
+			logging.Error("debug_snapshot_failed", logging.Fields{Component: "api", Error: err.Error()})
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		files = written
+		logging.Info("debug_snapshot_written", logging.Fields{Component: "api"})
+	}
+	snap := takeRuntimeSnapshot()
+	snap.Files = files
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(snap); err != nil {
+		logging.Error("debug_snapshot_encode_failed", logging.Fields{Component: "api", Error: err.Error()})
+	}
+}
+
+func takeRuntimeSnapshot() RuntimeSnapshot {
+	var ms runtime.MemStats
+	runtime.ReadMemStats(&ms)
+	now := time.Now()
+	return RuntimeSnapshot{
+		Time:         now,
+		Uptime:       now.Sub(startTime).Round(time.Second).String(),
+		GoVersion:    runtime.Version(),
+		NumCPU:       runtime.NumCPU(),
+		Goroutines:   runtime.NumGoroutine(),
+		HeapAlloc:    ms.HeapAlloc,
+		HeapInuse:    ms.HeapInuse,
+		Sys:          ms.Sys,
+		NumGC:        ms.NumGC,
+		PauseTotalNs: ms.PauseTotalNs,
+	}
+}
+
+func writeSnapshotProfiles(at time.Time) ([]string, error) {
+	base := os.Getenv(DiagnosticsDirEnv)
+	if base == "" {
+		base = defaultDiagnosticsDir
+	}
+	dir := filepath.Join(base, "snapshot-"+at.UTC().Format("20060102T150405Z"))
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("creating %s: %w", dir, err)
+	}
+	profiles := []struct {
+		name  string
+		debug int
+		file  string
+	}{
+		{"heap", 0, "heap.pprof"},
+		{"goroutine", 2, "goroutines.txt"},
+	}
+	var files []string
+	for i := 0; i < len(profiles); i++ {
+		path := filepath.Join(dir, profiles[i].file)
+		if err := writeProfile(profiles[i].name, profiles[i].debug, path); err != nil {
+			return files, err
+		}
+		files = append(files, path)
+	}
+	return files, nil
+}
+
+func writeProfile(name string, debug int, path string) (err error) {
+	p := runtimepprof.Lookup(name)
+	if err := assert.NotNil(p, "profile "+name); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+	if err != nil {
+		return fmt.Errorf("creating %s: %w", path, err)
+	}
+	defer func() {
+		if closeErr := f.Close(); closeErr != nil && err == nil {
+			err = fmt.Errorf("closing %s: %w", path, closeErr)
+		}
+	}()
+	if err := p.WriteTo(f, debug); err != nil {
+		return fmt.Errorf("writing %s profile: %w", name, err)
+	}
+	return nil
+}
+
+// HandleDebugConfig returns the policy configuration currently loaded, as YAML. Values
+// that came from secret references are masked.
+func (h *Handlers) HandleDebugConfig(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if h.Core == nil || h.Core.Observer == nil {
+		http.Error(w, "no policy loaded", http.StatusServiceUnavailable)
+		return
+	}
+	config := h.Core.Observer.GetConfig()
+	var doc yaml.Node
+	if err := doc.Encode(config); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	secretref.Mask(&doc, config.Secrets())
+	data, err := yaml.Marshal(&doc)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/yaml")
+	if _, err := w.Write(data); err != nil {
+		logging.Error("debug_config_write_failed", logging.Fields{Component: "api", Error: err.Error()})
+	}
+}
+
+// HandleDebugLogs returns the most recent log lines as JSON Lines.
+func HandleDebugLogs(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	lines := logging.Recent()
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	if _, err := w.Write([]byte(strings.Join(lines, "\n") + "\n")); err != nil {
+		logging.Error("debug_logs_write_failed", logging.Fields{Component: "api", Error: err.Error()})
+	}
+}
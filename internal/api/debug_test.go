@@ -0,0 +1,58 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestDebugHandlerRequiresAdminToken(t *testing.T) {
+	engine, _, cleanup := setupTestEngine(t)
+	defer cleanup()
+	t.Setenv("LOGRYPH_ADMIN_TOKEN", "secret")
+	handler := NewHandlers(engine).DebugHandler()
+
+	for _, path := range []string{"/debug/pprof/", "/debug/vars", "/debug/snapshot", "/debug/logs"} {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		if rec.Code != http.StatusUnauthorized {
+			t.Errorf("%s without token: expected 401, got %d", path, rec.Code)
+		}
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/debug/pprof/goroutine?debug=1", nil)
+	req.Header.Set("X-Admin-Token", "secret")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "goroutine") {
+		t.Fatalf("goroutine profile with token: %d %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestDebugSnapshotWritesProfiles(t *testing.T) {
+	engine, _, cleanup := setupTestEngine(t)
+	defer cleanup()
+	t.Setenv(DiagnosticsDirEnv, t.TempDir())
+	handler := NewHandlers(engine).DebugHandler()
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/debug/snapshot", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("snapshot: %d %s", rec.Code, rec.Body.String())
+	}
+	var snap RuntimeSnapshot
+	if err := json.Unmarshal(rec.Body.Bytes(), &snap); err != nil {
+		t.Fatalf("decode snapshot: %v", err)
+	}
+	if snap.Goroutines <= 0 || len(snap.Files) != 2 {
+		t.Fatalf("unexpected snapshot: %+v", snap)
+	}
+	for _, f := range snap.Files {
+		if info, err := os.Stat(f); err != nil || info.Size() == 0 {
+			t.Fatalf("profile %s not written: %v", f, err)
+		}
+	}
+}
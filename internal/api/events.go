@@ -0,0 +1,73 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/slyt3/Logryph/internal/logging"
+	"github.com/slyt3/Logryph/internal/models"
+	"github.com/slyt3/Logryph/internal/vql"
+)
+
+const (
+	defaultEventsLimit = 100
+	maxEventsLimit     = 1000
+)
+
+// HandleEvents lists the current run's events, newest last. GET ?q=<query> keeps only
+// events matching a query-language expression; ?limit=N (default 100, max 1000) keeps the
+// newest N matches. Requires X-Admin-Token header if LOGRYPH_ADMIN_TOKEN is set.
+func (h *Handlers) HandleEvents(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !authorizeAdmin(w, r) {
+		return
+	}
+	if h.Core == nil || h.Core.Worker == nil {
+		http.Error(w, "ledger unavailable", http.StatusServiceUnavailable)
+		return
+	}
+	limit := defaultEventsLimit
+	if raw := r.URL.Query().Get("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 || n > maxEventsLimit {
+			http.Error(w, fmt.Sprintf("limit must be between 1 and %d", maxEventsLimit), http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+	query, err := vql.CompileQuery("true")
+	if q := r.URL.Query().Get("q"); q != "" {
+		query, err = vql.CompileQuery(q)
+	}
+	if err != nil {
+		http.Error(w, "invalid query: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	db := h.Core.Worker.GetDB()
+	runID, err := db.GetRunID()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	events := []models.Event{}
+	if runID != "" {
+		all, err := db.GetAllEvents(runID)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if matched := query.Filter(all, limit); matched != nil {
+			events = matched
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(events); err != nil {
+		logging.Error("events_encode_failed", logging.Fields{Component: "api", Error: err.Error()})
+	}
+}
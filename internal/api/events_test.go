@@ -0,0 +1,37 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/slyt3/Logryph/internal/models"
+)
+
+func TestHandleEvents_Query(t *testing.T) {
+	engine, worker, cleanup := setupTestEngine(t)
+	defer cleanup()
+
+	emitTestEvent(worker)
+	waitForProcessed(t, worker, 1, 2*time.Second)
+	h := NewHandlers(engine)
+
+	rec := httptest.NewRecorder()
+	h.HandleEvents(rec, httptest.NewRequest(http.MethodGet, `/api/events?q=method+%3D~+%22os.*%22+and+type+%3D+%22tool_call%22`, nil))
+	var events []models.Event
+	if err := json.Unmarshal(rec.Body.Bytes(), &events); err != nil {
+		t.Fatalf("invalid response: %v %s", err, rec.Body.String())
+	}
+	if len(events) != 1 || events[0].ID != "evt-test" {
+		t.Errorf("unexpected events: %+v", events)
+	}
+
+	rec = httptest.NewRecorder()
+	h.HandleEvents(rec, httptest.NewRequest(http.MethodGet, `/api/events?q=mehtod+%3D+%22x%22`, nil))
+	if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), "unknown field") {
+		t.Errorf("a misspelt field should be rejected: %d %s", rec.Code, rec.Body.String())
+	}
+}
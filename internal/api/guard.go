@@ -0,0 +1,315 @@
+package api
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/slyt3/Logryph/internal/logging"
+	"github.com/slyt3/Logryph/internal/pool"
+)
+
+// EventTypeAdminLockout records a client locked out of the admin API after repeated
+// authentication failures. Its params carry ip, failures, path and locked_until.
+const EventTypeAdminLockout = "admin_lockout"
+
+const (
+	maxGuardClients = 10000 // tracked IPs and tokens each; stale ones are pruned first
+	guardEvictBatch = maxGuardClients / 10
+)
+
+// GuardConfig limits admin API traffic. A zero rate or MaxFailures turns that check off.
+type GuardConfig struct {
+	IPRate      float64       // requests per second from one client IP
+	TokenRate   float64       // requests per second presenting one X-Admin-Token
+	Burst       int           // requests allowed at once before the rates apply
+	MaxFailures int           // 401s from one IP within Lockout before it is locked out
+	Lockout     time.Duration // how long a lockout lasts
+}
+
+// DefaultGuardConfig allows a dashboard and a CLI comfortably and locks an IP out for
+// 15 minutes after 10 failed logins.
+func DefaultGuardConfig() GuardConfig {
+	return GuardConfig{IPRate: 10, TokenRate: 20, Burst: 40, MaxFailures: 10, Lockout: 15 * time.Minute}
+}
+
+// AdminGuard rate limits the admin API per client IP and per token, and locks out IPs that
+// keep failing authentication, ledgering an admin_lockout event for each lockout. Health
+// probes are exempt so a locked-out node IP cannot fail its own readiness checks.
+type AdminGuard struct {
+	next http.Handler
+	cfg  GuardConfig
+	h    *Handlers
+	now  func() time.Time
+
+	mu     sync.Mutex
+	ips    map[string]*guardClient
+	tokens map[string]*bucket
+}
+
+type guardClient struct {
+	bucket
+	failures     int
+	firstFailure time.Time
+	lockedUntil  time.Time
+}
+
+// bucket is a token bucket holding up to Burst requests, refilled at the rate.
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// Guard wraps the admin API handler in an AdminGuard.
+func (h *Handlers) Guard(next http.Handler, cfg GuardConfig) *AdminGuard {
+	if cfg.Burst <= 0 {
+		cfg.Burst = 1
+	}
+	return &AdminGuard{
+		next:   next,
+		cfg:    cfg,
+		h:      h,
+		now:    time.Now,
+		ips:    make(map[string]*guardClient),
+		tokens: make(map[string]*bucket),
+	}
+}
+
+func (g *AdminGuard) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/healthz" || r.URL.Path == "/readyz" {
+		g.next.ServeHTTP(w, r)
+		return
+	}
+	ip := clientIP(r)
+	if wait, ok := g.admit(ip, r.Header.Get("X-Admin-Token")); !ok {
+		w.Header().Set("Retry-After", strconv.Itoa(int(wait.Seconds())+1))
+		http.Error(w, "Too many requests", http.StatusTooManyRequests)
+		return
+	}
+	rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+	g.next.ServeHTTP(rec, r)
+	if rec.status == http.StatusUnauthorized {
+		g.authFailed(ip, r.URL.Path)
+	}
+}
+
+// admit takes a request from the IP's and the token's buckets. When refused, it returns
+// how long until the client may retry.
+func (g *AdminGuard) admit(ip, token string) (time.Duration, bool) {
+	now := g.now()
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	c := g.ips[ip]
+	if c == nil {
+		if !g.prune(now) {
+			return g.firstUnlock(now), false
+		}
+		c = &guardClient{bucket: bucket{tokens: float64(g.cfg.Burst), last: now}}
+		g.ips[ip] = c
+	}
+	if now.Before(c.lockedUntil) {
+		return c.lockedUntil.Sub(now), false
+	}
+	if wait, ok := c.take(now, g.cfg.IPRate, g.cfg.Burst); !ok {
+		return wait, false
+	}
+	if token == "" || g.cfg.TokenRate <= 0 {
+		return 0, true
+	}
+	key := tokenKey(token)
+	b := g.tokens[key]
+	if b == nil {
+		g.prune(now)
+		b = &bucket{tokens: float64(g.cfg.Burst), last: now}
+		g.tokens[key] = b
+	}
+	if wait, ok := b.take(now, g.cfg.TokenRate, g.cfg.Burst); !ok {
+		return wait, false
+	}
+	return 0, true
+}
+
+// authFailed counts a 401 against ip and locks it out once it reaches MaxFailures.
+func (g *AdminGuard) authFailed(ip, path string) {
+	if g.cfg.MaxFailures <= 0 {
+		return
+	}
+	now := g.now()
+	g.mu.Lock()
+	c := g.ips[ip]
+	if c == nil {
+		g.mu.Unlock()
+		return
+	}
+	if c.failures == 0 || now.Sub(c.firstFailure) > g.cfg.Lockout {
+		c.failures, c.firstFailure = 0, now
+	}
+	c.failures++
+	failures := c.failures
+	locked := failures >= g.cfg.MaxFailures
+	if locked {
+		c.lockedUntil = now.Add(g.cfg.Lockout)
+		c.failures = 0
+	}
+	until := c.lockedUntil
+	g.mu.Unlock()
+
+	if locked {
+		g.recordLockout(ip, path, failures, until)
+	}
+}
+
+// recordLockout ledgers the lockout as a signed system event.
+func (g *AdminGuard) recordLockout(ip, path string, failures int, until time.Time) {
+	logging.Warn("admin_lockout", logging.Fields{Component: "api", Error: fmt.Sprintf("%s locked out until %s after %d failed logins", ip, until.Format(time.RFC3339), failures)})
+	if g.h == nil || g.h.Core == nil || g.h.Core.Worker == nil {
+		return
+	}
+	event := pool.GetEvent()
+	event.ID = uuid.New().String()[:8]
+	event.Timestamp = g.now()
+	event.Actor = "system"
+	event.EventType = EventTypeAdminLockout
+	event.Method = "logryph:admin_auth"
+	event.RiskLevel = "high"
+	event.Params["ip"] = ip
+	event.Params["path"] = path
+	event.Params["failures"] = failures
+	event.Params["locked_until"] = until.Format(time.RFC3339)
+	g.h.Core.Worker.Submit(event)
+}
+
+// prune drops clients with full buckets and no lockout or recent failures once the maps
+// are full. If that frees too little, the least recently seen clients that are not locked
+// out are evicted. A locked-out client is never dropped, so cycling through addresses
+// cannot clear a lockout. It reports whether there is room for another client IP.
+func (g *AdminGuard) prune(now time.Time) bool {
+	if len(g.ips) < maxGuardClients && len(g.tokens) < maxGuardClients {
+		return true
+	}
+	for ip, c := range g.ips {
+		if now.After(c.lockedUntil) && (c.failures == 0 || now.Sub(c.firstFailure) > g.cfg.Lockout) && c.full(now, g.cfg.IPRate, g.cfg.Burst) {
+			delete(g.ips, ip)
+		}
+	}
+	for key, b := range g.tokens {
+		if b.full(now, g.cfg.TokenRate, g.cfg.Burst) {
+			delete(g.tokens, key)
+		}
+	}
+	if len(g.ips) >= maxGuardClients {
+		idle := make([]string, 0, len(g.ips))
+		for ip, c := range g.ips {
+			if !now.Before(c.lockedUntil) {
+				idle = append(idle, ip)
+			}
+		}
+		sort.Slice(idle, func(i, j int) bool { return g.ips[idle[i]].last.Before(g.ips[idle[j]].last) })
+		for i := 0; i < len(idle) && len(g.ips) > maxGuardClients-guardEvictBatch; i++ {
+			delete(g.ips, idle[i])
+		}
+	}
+	if len(g.tokens) >= maxGuardClients {
+		keys := make([]string, 0, len(g.tokens))
+		for key := range g.tokens {
+			keys = append(keys, key)
+		}
+		sort.Slice(keys, func(i, j int) bool { return g.tokens[keys[i]].last.Before(g.tokens[keys[j]].last) })
+		for i := 0; i < len(keys) && len(g.tokens) > maxGuardClients-guardEvictBatch; i++ {
+			delete(g.tokens, keys[i])
+		}
+	}
+	return len(g.ips) < maxGuardClients
+}
+
+// firstUnlock is how long until the earliest lockout ends, for refusing new client IPs
+// while every tracked one is locked out.
+func (g *AdminGuard) firstUnlock(now time.Time) time.Duration {
+	wait := g.cfg.Lockout
+	for _, c := range g.ips {
+		if d := c.lockedUntil.Sub(now); d > 0 && d < wait {
+			wait = d
+		}
+	}
+	return wait
+}
+
+// take refills the bucket for the time since its last request and takes one request.
+// A rate of zero never limits.
+func (b *bucket) take(now time.Time, rate float64, burst int) (time.Duration, bool) {
+	if rate <= 0 {
+		return 0, true
+	}
+	b.refill(now, rate, burst)
+	if b.tokens < 1 {
+		return time.Duration((1 - b.tokens) / rate * float64(time.Second)), false
+	}
+	b.tokens--
+	return 0, true
+}
+
+// full reports whether the bucket has refilled since its last request. It leaves last
+// alone, so pruning can still tell which clients were seen least recently.
+func (b *bucket) full(now time.Time, rate float64, burst int) bool {
+	if rate <= 0 {
+		return true
+	}
+	return b.tokens+now.Sub(b.last).Seconds()*rate >= float64(burst)
+}
+
+func (b *bucket) refill(now time.Time, rate float64, burst int) {
+	b.tokens += now.Sub(b.last).Seconds() * rate
+	if b.tokens > float64(burst) {
+		b.tokens = float64(burst)
+	}
+	b.last = now
+}
+
+// tokenKey identifies a token without keeping it in memory.
+func tokenKey(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:8])
+}
+
+// tokenMatches compares a presented token with the configured one in constant time.
+func tokenMatches(got, want string) bool {
+	return subtle.ConstantTimeCompare([]byte(got), []byte(want)) == 1
+}
+
+// clientIP is the host part of the peer address; Unix socket peers all count as "local".
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		if r.RemoteAddr == "" || r.RemoteAddr == "@" {
+			return "local"
+		}
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// statusRecorder remembers the status code a handler wrote.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (s *statusRecorder) WriteHeader(code int) {
+	s.status = code
+	s.ResponseWriter.WriteHeader(code)
+}
+
+// Flush lets streaming handlers behind the guard flush.
+func (s *statusRecorder) Flush() {
+	if f, ok := s.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
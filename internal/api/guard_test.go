@@ -0,0 +1,114 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/slyt3/Logryph/internal/ledger/store"
+)
+
+func TestAdminGuard_RateLimitsAndLocksOut(t *testing.T) {
+	engine, worker, cleanup := setupTestEngine(t)
+	defer cleanup()
+	t.Setenv("LOGRYPH_ADMIN_TOKEN", "s3cret")
+	h := NewHandlers(engine)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/holds", h.HandleHolds)
+	mux.HandleFunc("/healthz", h.HandleHealth)
+	g := h.Guard(mux, GuardConfig{IPRate: 1, TokenRate: 1, Burst: 2, MaxFailures: 3, Lockout: time.Minute})
+	now := time.Now()
+	g.now = func() time.Time { return now }
+	get := func(path, ip, token string) int {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.RemoteAddr = ip + ":40000"
+		if token != "" {
+			req.Header.Set("X-Admin-Token", token)
+		}
+		rec := httptest.NewRecorder()
+		g.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	// Per IP: a burst of two, then one a second.
+	if a, b, c := get("/api/holds", "10.0.0.1", "s3cret"), get("/api/holds", "10.0.0.1", "s3cret"), get("/api/holds", "10.0.0.1", "s3cret"); a != http.StatusOK || b != http.StatusOK || c != http.StatusTooManyRequests {
+		t.Fatalf("burst from one IP: %d %d %d, want 200 200 429", a, b, c)
+	}
+	// Per token: the same token from another IP is still over its rate.
+	if code := get("/api/holds", "10.0.0.2", "s3cret"); code != http.StatusTooManyRequests {
+		t.Errorf("token over its rate from a new IP: %d, want 429", code)
+	}
+	now = now.Add(time.Second)
+	if code := get("/api/holds", "10.0.0.1", "s3cret"); code != http.StatusOK {
+		t.Errorf("after a second: %d, want 200", code)
+	}
+
+	// Three bad tokens lock the IP out, even with the right token, until the lockout ends.
+	for i := 0; i < 3; i++ {
+		now = now.Add(time.Second)
+		if code := get("/api/holds", "10.0.0.3", "guess"); code != http.StatusUnauthorized {
+			t.Fatalf("bad token %d: %d, want 401", i, code)
+		}
+	}
+	now = now.Add(time.Second)
+	if code := get("/api/holds", "10.0.0.3", "s3cret"); code != http.StatusTooManyRequests {
+		t.Errorf("locked-out IP: %d, want 429", code)
+	}
+	if code := get("/healthz", "10.0.0.3", ""); code != http.StatusOK {
+		t.Errorf("health probes are exempt: %d", code)
+	}
+	now = now.Add(time.Minute)
+	if code := get("/api/holds", "10.0.0.3", "s3cret"); code != http.StatusOK {
+		t.Errorf("after the lockout: %d, want 200", code)
+	}
+
+	waitForProcessed(t, worker, 1, 2*time.Second)
+	db := worker.GetDB().(*store.DB)
+	events, err := db.GetEventsByType(EventTypeAdminLockout)
+	if err != nil || len(events) != 1 || events[0].Params["ip"] != "10.0.0.3" || events[0].Signature == "" {
+		t.Fatalf("lockout not ledgered: %v %+v", err, events)
+	}
+}
+
+// Filling the table with new addresses must not clear a lockout: only idle clients that
+// are not locked out are evicted.
+func TestAdminGuard_PruneKeepsLockouts(t *testing.T) {
+	g := (&Handlers{}).Guard(http.NotFoundHandler(), GuardConfig{IPRate: 1, Burst: 1, MaxFailures: 1, Lockout: time.Hour})
+	now := time.Now()
+	g.now = func() time.Time { return now }
+	if _, ok := g.admit("10.9.9.9", ""); !ok {
+		t.Fatal("first request refused")
+	}
+	g.authFailed("10.9.9.9", "/api/holds")
+	for i := 0; i < 2*maxGuardClients; i++ {
+		now = now.Add(time.Millisecond)
+		if _, ok := g.admit(fmt.Sprintf("10.%d.%d.%d", i>>16, (i>>8)&0xff, i&0xff), ""); !ok {
+			t.Fatalf("new client %d refused", i)
+		}
+	}
+	if len(g.ips) > maxGuardClients {
+		t.Errorf("tracking %d clients, max %d", len(g.ips), maxGuardClients)
+	}
+	if _, ok := g.admit("10.9.9.9", ""); ok {
+		t.Error("locked-out client was admitted after the table filled")
+	}
+
+	// With every tracked client locked out, new clients wait for the first lockout to end.
+	for i := 0; len(g.ips) < maxGuardClients; i++ {
+		g.ips[fmt.Sprintf("172.16.%d.%d", i>>8, i&0xff)] = &guardClient{bucket: bucket{last: now}}
+	}
+	for _, c := range g.ips {
+		c.lockedUntil = now.Add(time.Hour)
+	}
+	if wait, ok := g.admit("192.0.2.1", ""); ok || wait <= 0 {
+		t.Errorf("new client admitted into a table of lockouts: %s, %v", wait, ok)
+	}
+}
+
+func TestTokenMatches(t *testing.T) {
+	if !tokenMatches("abc", "abc") || tokenMatches("abc", "abd") || tokenMatches("ab", "abc") || tokenMatches("", "abc") {
+		t.Error("tokenMatches should accept only the exact token")
+	}
+}
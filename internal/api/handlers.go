@@ -3,15 +3,22 @@ package api
 import (
 	"encoding/json"
 	"fmt"
+	"math"
 	"net/http"
-	"os"
+	"strings"
 	"time"
 
 	"github.com/slyt3/Logryph/internal/assert"
+	"github.com/slyt3/Logryph/internal/capacity"
 	"github.com/slyt3/Logryph/internal/core"
+	"github.com/slyt3/Logryph/internal/interceptor"
 	"github.com/slyt3/Logryph/internal/ledger"
 	"github.com/slyt3/Logryph/internal/logging"
+	"github.com/slyt3/Logryph/internal/metrics"
+	"github.com/slyt3/Logryph/internal/observer"
 	"github.com/slyt3/Logryph/internal/pool"
+	"github.com/slyt3/Logryph/internal/secretref"
+	"github.com/slyt3/Logryph/internal/slo"
 )
 
 // LatencySnapshot is aliased from ledger package for clarity
@@ -37,12 +44,8 @@ func (h *Handlers) HandleRekey(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
-	adminToken := os.Getenv("LOGRYPH_ADMIN_TOKEN")
-	if adminToken != "" {
-		if r.Header.Get("X-Admin-Token") != adminToken {
-			http.Error(w, "Unauthorized", http.StatusUnauthorized)
-			return
-		}
+	if !authorizeAdmin(w, r) {
+		return
 	}
 	oldPubKey, newPubKey, err := h.Core.Worker.GetSigner().RotateKey(".logryph_key")
 	if err != nil {
@@ -55,6 +58,37 @@ func (h *Handlers) HandleRekey(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// authorizeAdmin checks the X-Admin-Token header against LOGRYPH_ADMIN_TOKEN.
+// Writes 401 and returns false on mismatch; always passes when no token is configured
+// or the request came over a trusted admin socket (see SocketAuthContext).
+func authorizeAdmin(w http.ResponseWriter, r *http.Request) bool {
+	adminToken, ok := tokenFromEnv(w, "LOGRYPH_ADMIN_TOKEN")
+	if !ok {
+		return false
+	}
+	if adminToken == "" || socketAuthorized(r) {
+		return true
+	}
+	if !tokenMatches(r.Header.Get("X-Admin-Token"), adminToken) {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return false
+	}
+	return true
+}
+
+// tokenFromEnv reads a token variable, which may be a secret reference such as
+// ${file:/run/secrets/admin-token}, re-read on every request so rotated secrets apply.
+// A reference that cannot be resolved fails closed with 503.
+func tokenFromEnv(w http.ResponseWriter, name string) (string, bool) {
+	token, err := secretref.Getenv(name)
+	if err != nil {
+		logging.Error("admin_token_unavailable", logging.Fields{Component: "api", Error: err.Error()})
+		http.Error(w, "Admin token unavailable", http.StatusServiceUnavailable)
+		return "", false
+	}
+	return token, true
+}
+
 // HandleStats returns pool metrics (event/buffer hits and misses) as JSON.
 // Always returns 200 OK with pool statistics.
 func (h *Handlers) HandleStats(w http.ResponseWriter, r *http.Request) {
@@ -95,7 +129,17 @@ func (h *Handlers) HandleReady(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if !h.Core.Worker.IsHealthy() {
-		http.Error(w, "worker unhealthy", http.StatusServiceUnavailable)
+		// A degraded proxy keeps serving agents, so it stays in rotation unless the ledger
+		// was made read-only, which refuses calls whatever the guarantee.
+		guarantee := h.ledgerGuarantee()
+		if guarantee != observer.GuaranteeDegraded || h.Core.Worker.ReadOnly() {
+			http.Error(w, "worker unhealthy ("+guarantee+")", http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		if _, err := w.Write([]byte("degraded: " + h.Core.Worker.UnhealthyReason())); err != nil {
+			logging.Error("ready_response_write_failed", logging.Fields{Component: "api", Error: err.Error()})
+		}
 		return
 	}
 
@@ -115,6 +159,14 @@ func (h *Handlers) HandleReady(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// ledgerGuarantee returns the policy's ledger guarantee, fail_open without a policy.
+func (h *Handlers) ledgerGuarantee() string {
+	if h.Core.Observer == nil {
+		return observer.GuaranteeFailOpen
+	}
+	return h.Core.Observer.GetLedgerGuarantee()
+}
+
 // HandlePrometheus exports metrics in Prometheus text format.
 // Includes counters (events processed/dropped), gauges (queue depth, active tasks),
 // and histograms (processing latency buckets).
@@ -139,10 +191,19 @@ type prometheusMetrics struct {
 	EventsDropped    uint64
 	EventsBlocked    uint64
 	BackpressureMode string
+	LedgerGuarantee  string
+	LedgerRecording  int // 1 while the ledger can record, else 0
 	ActiveTasks      int
 	QueueDepth       int
 	QueueCapacity    int
+	UpstreamTimeouts uint64
+	UpstreamErrors   uint64
+	LedgerRejections uint64
+	UnrecordedCalls  uint64
+	Concurrency      interceptor.ConcurrencyStats
 	LatencyMetrics   LatencySnapshot
+	ToolLatency      []slo.MethodLatency
+	Capacity         *capacity.Forecast // nil when the ledger is not on disk
 }
 
 // collectMetrics gathers all metrics from the system
@@ -168,6 +229,11 @@ func (h *Handlers) collectMetrics() *prometheusMetrics {
 		modeLabel = "block"
 	}
 
+	recording := 0
+	if h.Core.Worker.IsHealthy() {
+		recording = 1
+	}
+
 	if err := assert.Check(queueCap >= 0, "queue capacity must be non-negative"); err != nil {
 		logging.Warn("queue_capacity_invalid", logging.Fields{Component: "api", Error: err.Error()})
 	}
@@ -192,11 +258,33 @@ func (h *Handlers) collectMetrics() *prometheusMetrics {
 		EventsDropped:    drop,
 		EventsBlocked:    blocked,
 		BackpressureMode: modeLabel,
+		LedgerGuarantee:  h.ledgerGuarantee(),
+		LedgerRecording:  recording,
 		ActiveTasks:      tasks,
 		QueueDepth:       queueDepth,
 		QueueCapacity:    queueCap,
+		UpstreamTimeouts: interceptor.UpstreamTimeouts(),
+		UpstreamErrors:   interceptor.UpstreamErrors(),
+		LedgerRejections: interceptor.LedgerRejections(),
+		UnrecordedCalls:  interceptor.UnrecordedCalls(),
+		Concurrency:      interceptor.Concurrency(),
 		LatencyMetrics:   latency,
+		ToolLatency:      h.Core.SLO.Snapshot(time.Now()),
+		Capacity:         h.capacityForecast(),
+	}
+}
+
+// capacityForecast measures the ledger for the capacity gauges, or returns nil.
+func (h *Handlers) capacityForecast() *capacity.Forecast {
+	if h.Core.Capacity == nil {
+		return nil
+	}
+	f, err := h.Core.Capacity.Forecast()
+	if err != nil {
+		logging.Warn("capacity_forecast_failed", logging.Fields{Component: "api", Error: err.Error()})
+		return nil
 	}
+	return f
 }
 
 // formatPrometheusText writes metrics in Prometheus text format
@@ -208,105 +296,144 @@ func (h *Handlers) formatPrometheusText(w http.ResponseWriter, m *prometheusMetr
 		return
 	}
 
-	writef := func(format string, args ...interface{}) bool {
-		if _, err := fmt.Fprintf(w, format, args...); err != nil {
+	samples := []struct {
+		desc   metrics.Desc
+		labels string
+		value  string
+	}{
+		{metrics.PoolEventHits, "", fmt.Sprint(m.PoolEventHits)},
+		{metrics.PoolEventMisses, "", fmt.Sprint(m.PoolEventMisses)},
+		{metrics.EventsProcessed, "", fmt.Sprint(m.EventsProcessed)},
+		{metrics.EventsDropped, "", fmt.Sprint(m.EventsDropped)},
+		{metrics.EventsBlocked, "", fmt.Sprint(m.EventsBlocked)},
+		{metrics.BackpressureMode, fmt.Sprintf("{mode=\"%s\"}", m.BackpressureMode), "1"},
+		{metrics.LedgerGuarantee, fmt.Sprintf("{mode=\"%s\"}", m.LedgerGuarantee), "1"},
+		{metrics.LedgerRecording, "", fmt.Sprint(m.LedgerRecording)},
+		{metrics.ActiveTasks, "", fmt.Sprint(m.ActiveTasks)},
+		{metrics.QueueDepth, "", fmt.Sprint(m.QueueDepth)},
+		{metrics.QueueCapacity, "", fmt.Sprint(m.QueueCapacity)},
+		{metrics.UpstreamTimeouts, "", fmt.Sprint(m.UpstreamTimeouts)},
+		{metrics.UpstreamErrors, "", fmt.Sprint(m.UpstreamErrors)},
+		{metrics.LedgerRejections, "", fmt.Sprint(m.LedgerRejections)},
+		{metrics.UnrecordedCalls, "", fmt.Sprint(m.UnrecordedCalls)},
+		{metrics.ProxyInFlight, "", fmt.Sprint(m.Concurrency.InFlight)},
+		{metrics.ProxyQueueLength, "", fmt.Sprint(m.Concurrency.Queued)},
+		{metrics.ProxyQueued, "", fmt.Sprint(m.Concurrency.Waited)},
+		{metrics.ProxyQueueWait, "", fmt.Sprintf("%.6f", m.Concurrency.QueueWait.Seconds())},
+		{metrics.ProxyThrottled, "", fmt.Sprint(m.Concurrency.Throttled)},
+	}
+	for i := 0; i < len(samples); i++ {
+		d := samples[i].desc
+		if !writeMetricHeader(w, d) {
+			return
+		}
+		if _, err := fmt.Fprintf(w, "%s%s %s\n", d.Name, samples[i].labels, samples[i].value); err != nil {
 			logging.Error("prometheus_write_failed", logging.Fields{Component: "api", Error: err.Error()})
-			return false
+			return
 		}
-		return true
 	}
 
-	if !writef("# HELP logryph_pool_event_hits_total Total hits on the event pool\n") {
+	if !formatToolLatency(w, m.ToolLatency) {
 		return
 	}
-	if !writef("# TYPE logryph_pool_event_hits_total counter\n") {
-		return
-	}
-	if !writef("logryph_pool_event_hits_total %d\n", m.PoolEventHits) {
-		return
-	}
-
-	if !writef("# HELP logryph_pool_event_misses_total Total misses (allocations) in the event pool\n") {
-		return
-	}
-	if !writef("# TYPE logryph_pool_event_misses_total counter\n") {
-		return
-	}
-	if !writef("logryph_pool_event_misses_total %d\n", m.PoolEventMisses) {
-		return
-	}
-
-	if !writef("# HELP logryph_ledger_events_processed_total Total events successfully written to the ledger\n") {
-		return
-	}
-	if !writef("# TYPE logryph_ledger_events_processed_total counter\n") {
-		return
-	}
-	if !writef("logryph_ledger_events_processed_total %d\n", m.EventsProcessed) {
+	if !formatCapacity(w, m.Capacity) {
 		return
 	}
+	h.formatLatencyHistogram(w, &m.LatencyMetrics)
+}
 
-	if !writef("# HELP logryph_ledger_events_dropped_total Total events dropped due to backpressure\n") {
-		return
-	}
-	if !writef("# TYPE logryph_ledger_events_dropped_total counter\n") {
-		return
-	}
-	if !writef("logryph_ledger_events_dropped_total %d\n", m.EventsDropped) {
-		return
-	}
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
 
-	if !writef("# HELP logryph_ledger_events_blocked_total Total submit attempts blocked by backpressure\n") {
-		return
-	}
-	if !writef("# TYPE logryph_ledger_events_blocked_total counter\n") {
-		return
+// formatToolLatency writes per-method latency quantiles and objective violations.
+// Methods without calls in the window keep their violation counter but no quantiles.
+func formatToolLatency(w http.ResponseWriter, methods []slo.MethodLatency) bool {
+	write := func(format string, args ...interface{}) bool {
+		if _, err := fmt.Fprintf(w, format, args...); err != nil {
+			logging.Error("prometheus_write_failed", logging.Fields{Component: "api", Error: err.Error()})
+			return false
+		}
+		return true
 	}
-	if !writef("logryph_ledger_events_blocked_total %d\n", m.EventsBlocked) {
-		return
+	if !writeMetricHeader(w, metrics.ToolLatency) {
+		return false
 	}
-
-	if !writef("# HELP logryph_ledger_backpressure_mode Current backpressure mode (drop|block)\n") {
-		return
+	for i := 0; i < len(methods); i++ {
+		m := methods[i]
+		if m.Count == 0 {
+			continue
+		}
+		method := labelEscaper.Replace(m.Method)
+		for _, q := range []struct {
+			label string
+			value time.Duration
+		}{{"0.5", m.P50}, {"0.9", m.P90}, {"0.99", m.P99}} {
+			if !write("%s{method=\"%s\",quantile=\"%s\"} %.6f\n", metrics.ToolLatency.Name, method, q.label, q.value.Seconds()) {
+				return false
+			}
+		}
 	}
-	if !writef("# TYPE logryph_ledger_backpressure_mode gauge\n") {
-		return
+	if !writeMetricHeader(w, metrics.SLOViolations) {
+		return false
 	}
-	if !writef("logryph_ledger_backpressure_mode{mode=\"%s\"} 1\n", m.BackpressureMode) {
-		return
+	for i := 0; i < len(methods); i++ {
+		if !write("%s{method=\"%s\"} %d\n", metrics.SLOViolations.Name, labelEscaper.Replace(methods[i].Method), methods[i].Violations) {
+			return false
+		}
 	}
+	return true
+}
 
-	if !writef("# HELP logryph_engine_active_tasks_total Number of currently active causal tasks\n") {
-		return
-	}
-	if !writef("# TYPE logryph_engine_active_tasks_total gauge\n") {
-		return
-	}
-	if !writef("logryph_engine_active_tasks_total %d\n", m.ActiveTasks) {
-		return
+// formatCapacity writes the ledger capacity gauges. Without a forecast only their headers
+// are written; the disk gauges are left out when the disk could not be read.
+func formatCapacity(w http.ResponseWriter, f *capacity.Forecast) bool {
+	var c capacity.Forecast
+	if f != nil {
+		c = *f
+	}
+	samples := []struct {
+		desc  metrics.Desc
+		value string
+		disk  bool
+	}{
+		{metrics.LedgerDBBytes, fmt.Sprint(c.DBBytes), false},
+		{metrics.LedgerGrowthEvents, fmt.Sprintf("%.3f", c.EventsPerDay), false},
+		{metrics.LedgerGrowthBytes, fmt.Sprintf("%.0f", c.BytesPerDay), false},
+		{metrics.LedgerProjectedBytes, fmt.Sprint(c.ProjectedBytes), false},
+		{metrics.LedgerDiskFree, fmt.Sprint(c.Disk.Free), true},
+		{metrics.LedgerDiskTotal, fmt.Sprint(c.Disk.Total), true},
+		{metrics.LedgerDaysUntilFull, formatFloat(c.DaysUntilFull), true},
+	}
+	for i := 0; i < len(samples); i++ {
+		d := samples[i].desc
+		if !writeMetricHeader(w, d) {
+			return false
+		}
+		if f == nil || (samples[i].disk && !c.Disk.Known) {
+			continue
+		}
+		if _, err := fmt.Fprintf(w, "%s %s\n", d.Name, samples[i].value); err != nil {
+			logging.Error("prometheus_write_failed", logging.Fields{Component: "api", Error: err.Error()})
+			return false
+		}
 	}
+	return true
+}
 
-	if !writef("# HELP logryph_ledger_queue_depth Current queue depth\n") {
-		return
-	}
-	if !writef("# TYPE logryph_ledger_queue_depth gauge\n") {
-		return
-	}
-	if !writef("logryph_ledger_queue_depth %d\n", m.QueueDepth) {
-		return
+// formatFloat writes v as Prometheus expects, with +Inf for infinity.
+func formatFloat(v float64) string {
+	if math.IsInf(v, 1) {
+		return "+Inf"
 	}
+	return fmt.Sprintf("%.1f", v)
+}
 
-	if !writef("# HELP logryph_ledger_queue_capacity Queue capacity\n") {
-		return
-	}
-	if !writef("# TYPE logryph_ledger_queue_capacity gauge\n") {
-		return
+// writeMetricHeader writes the HELP and TYPE lines for d.
+func writeMetricHeader(w http.ResponseWriter, d metrics.Desc) bool {
+	if _, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", d.Name, d.Help, d.Name, d.Type); err != nil {
+		logging.Error("prometheus_write_failed", logging.Fields{Component: "api", Error: err.Error()})
+		return false
 	}
-	if !writef("logryph_ledger_queue_capacity %d\n", m.QueueCapacity) {
-		return
-	}
-
-	h.formatLatencyHistogram(w, &m.LatencyMetrics)
+	return true
 }
 
 // formatLatencyHistogram writes the latency histogram in Prometheus format
@@ -326,10 +453,8 @@ func (h *Handlers) formatLatencyHistogram(w http.ResponseWriter, latency *Latenc
 		return true
 	}
 
-	if !writef("# HELP logryph_ledger_event_latency_seconds Event processing latency\n") {
-		return
-	}
-	if !writef("# TYPE logryph_ledger_event_latency_seconds histogram\n") {
+	name := metrics.EventLatency.Name
+	if !writeMetricHeader(w, metrics.EventLatency) {
 		return
 	}
 
@@ -345,14 +470,14 @@ func (h *Handlers) formatLatencyHistogram(w http.ResponseWriter, latency *Latenc
 		} else {
 			label = fmt.Sprintf("%.6f", float64(upper)/float64(time.Second))
 		}
-		if !writef("logryph_ledger_event_latency_seconds_bucket{le=\"%s\"} %d\n", label, latency.Counts[i]) {
+		if !writef("%s_bucket{le=\"%s\"} %d\n", name, label, latency.Counts[i]) {
 			return
 		}
 	}
-	if !writef("logryph_ledger_event_latency_seconds_sum %.6f\n", float64(latency.SumNs)/float64(time.Second)) {
+	if !writef("%s_sum %.6f\n", name, float64(latency.SumNs)/float64(time.Second)) {
 		return
 	}
-	if !writef("logryph_ledger_event_latency_seconds_count %d\n", latency.Count) {
+	if !writef("%s_count %d\n", name, latency.Count) {
 		return
 	}
 }
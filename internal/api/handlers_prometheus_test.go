@@ -3,6 +3,7 @@ package api
 import (
 	"net/http"
 	"net/http/httptest"
+	"os"
 	"path/filepath"
 	"strings"
 	"sync"
@@ -10,10 +11,13 @@ import (
 	"time"
 
 	"github.com/slyt3/Logryph/internal/assert"
+	"github.com/slyt3/Logryph/internal/capacity"
 	"github.com/slyt3/Logryph/internal/core"
 	"github.com/slyt3/Logryph/internal/ledger"
 	"github.com/slyt3/Logryph/internal/ledger/store"
+	"github.com/slyt3/Logryph/internal/metrics"
 	"github.com/slyt3/Logryph/internal/pool"
+	"github.com/slyt3/Logryph/internal/slo"
 )
 
 const maxWaitTicks = 50
@@ -59,6 +63,77 @@ func TestHandlePrometheusLatencyCountAndSum(t *testing.T) {
 	}
 }
 
+func TestHandlePrometheusExportsRegistry(t *testing.T) {
+	engine, worker, cleanup := setupTestEngine(t)
+	defer cleanup()
+
+	emitTestEvent(worker)
+	waitForProcessed(t, worker, 1, 2*time.Second)
+
+	body := fetchPrometheusBody(t, engine)
+	for i := 0; i < len(metrics.All); i++ {
+		d := metrics.All[i]
+		if !strings.Contains(body, "# TYPE "+d.Name+" "+d.Type+"\n") {
+			t.Errorf("registered metric %s (%s) not exported", d.Name, d.Type)
+		}
+	}
+}
+
+func TestHandlePrometheusToolLatency(t *testing.T) {
+	engine, worker, cleanup := setupTestEngine(t)
+	defer cleanup()
+
+	tracker, err := slo.NewTracker(nil, worker.Submit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	engine.SLO = tracker
+	tracker.Observe(slo.Sample{Method: `odd"tool`, Latency: 250 * time.Millisecond, At: time.Now()})
+
+	body := fetchPrometheusBody(t, engine)
+	for _, want := range []string{
+		`logryph_tool_latency_seconds{method="odd\"tool",quantile="0.99"} 0.250000`,
+		`logryph_slo_violations_total{method="odd\"tool"} 0`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("missing %s", want)
+		}
+	}
+}
+
+func TestHandlePrometheusCapacity(t *testing.T) {
+	engine, worker, cleanup := setupTestEngine(t)
+	defer cleanup()
+
+	emitTestEvent(worker)
+	waitForProcessed(t, worker, 1, 2*time.Second)
+
+	src, ok := worker.GetDB().(capacity.Source)
+	if !ok {
+		t.Fatal("store does not provide daily stats")
+	}
+	dbFile := filepath.Join(t.TempDir(), "sized.db")
+	if err := os.WriteFile(dbFile, make([]byte, 2048), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	monitor, err := capacity.NewMonitor(dbFile, src, capacity.Options{HorizonDays: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	engine.Capacity = monitor
+
+	body := fetchPrometheusBody(t, engine)
+	for _, want := range []string{
+		"logryph_ledger_db_bytes 2048\n",
+		"logryph_ledger_growth_events_per_day ",
+		"logryph_ledger_projected_bytes ",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("missing %q", want)
+		}
+	}
+}
+
 func setupTestEngine(t *testing.T) (*core.Engine, *ledger.Worker, func()) {
 	tempDir := t.TempDir()
 	if err := assert.Check(tempDir != "", "temp dir must not be empty"); err != nil {
@@ -0,0 +1,16 @@
+package api
+
+import (
+	"net/http"
+)
+
+// HandleHead serves the published chain head at headpub.WellKnownPath. It needs no admin
+// token, so external monitors can poll it; it is 404 unless head_publication.well_known
+// is set.
+func (h *Handlers) HandleHead(w http.ResponseWriter, r *http.Request) {
+	if h == nil || h.Core == nil || h.Core.Heads == nil || !h.Core.Heads.WellKnown() {
+		http.NotFound(w, r)
+		return
+	}
+	h.Core.Heads.ServeHTTP(w, r)
+}
@@ -0,0 +1,52 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/slyt3/Logryph/internal/canary"
+	"github.com/slyt3/Logryph/internal/headpub"
+	"github.com/slyt3/Logryph/internal/ledger/store"
+)
+
+func TestHandleHeadServesWithoutToken(t *testing.T) {
+	t.Setenv("LOGRYPH_ADMIN_TOKEN", "s3cret")
+	engine, worker, cleanup := setupTestEngine(t)
+	defer cleanup()
+	h := NewHandlers(engine)
+
+	get := func() *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		h.HandleHead(rec, httptest.NewRequest(http.MethodGet, headpub.WellKnownPath, nil))
+		return rec
+	}
+	if rec := get(); rec.Code != http.StatusNotFound {
+		t.Fatalf("without a publisher: status %d, want 404", rec.Code)
+	}
+
+	emitTestEvent(worker)
+	waitForProcessed(t, worker, 1, 2*time.Second)
+	db, ok := worker.GetDB().(*store.DB)
+	if !ok {
+		t.Fatal("test ledger is not a SQLite store")
+	}
+	var err error
+	engine.Heads, err = headpub.NewPublisher(db, worker.GetSigner(), headpub.Config{WellKnown: true})
+	if err != nil {
+		t.Fatalf("NewPublisher: %v", err)
+	}
+	if err := engine.Heads.Refresh(); err != nil {
+		t.Fatalf("Refresh: %v", err)
+	}
+	rec := get()
+	var head canary.Entry
+	if err := json.Unmarshal(rec.Body.Bytes(), &head); err != nil || rec.Code != http.StatusOK {
+		t.Fatalf("status %d %q (%v)", rec.Code, rec.Body.String(), err)
+	}
+	if head.Seq != 1 || !headpub.Verify(&head) {
+		t.Errorf("served head %+v does not verify", head)
+	}
+}
@@ -0,0 +1,177 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/slyt3/Logryph/internal/ledger"
+	"github.com/slyt3/Logryph/internal/ledger/store"
+	"github.com/slyt3/Logryph/internal/logging"
+	"github.com/slyt3/Logryph/internal/models"
+	"github.com/slyt3/Logryph/internal/pool"
+)
+
+const (
+	maxHoldBody   = 8 * 1024
+	maxHoldRefLen = 128
+)
+
+// HoldRequest is the body of POST /api/holds.
+type HoldRequest struct {
+	Kind      string `json:"kind"` // task | case
+	Ref       string `json:"ref"`  // task ID, or case name or ID
+	Reason    string `json:"reason"`
+	Principal string `json:"principal,omitempty"`
+	Release   bool   `json:"release,omitempty"`
+}
+
+// HoldResponse acknowledges a queued hold event.
+type HoldResponse struct {
+	ID      string `json:"id"`
+	Kind    string `json:"kind"`
+	Ref     string `json:"ref"`
+	Release bool   `json:"release,omitempty"`
+}
+
+// HandleHolds sets and releases legal holds, which record that a task's or a case's
+// events must be preserved. GET lists the holds in force; POST with a
+// HoldRequest body ledgers a signed legal_hold_set or legal_hold_released event naming
+// the principal and returns 202 with its ID. Setting a held ref or releasing an unheld
+// one is a 409.
+// Requires X-Admin-Token header if LOGRYPH_ADMIN_TOKEN is set.
+func (h *Handlers) HandleHolds(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !authorizeAdmin(w, r) {
+		return
+	}
+	if h.Core == nil || h.Core.Worker == nil {
+		http.Error(w, "ledger unavailable", http.StatusServiceUnavailable)
+		return
+	}
+	reader, ok := h.Core.Worker.GetDB().(ledger.LegalHoldReader)
+	if !ok {
+		http.Error(w, "legal holds not supported by this ledger", http.StatusNotImplemented)
+		return
+	}
+	holds, err := reader.GetLegalHolds()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if r.Method == http.MethodGet {
+		if holds == nil {
+			holds = []models.LegalHold{}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(holds); err != nil {
+			logging.Error("holds_encode_failed", logging.Fields{Component: "api", Error: err.Error()})
+		}
+		return
+	}
+
+	var req HoldRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxHoldBody)).Decode(&req); err != nil {
+		http.Error(w, "invalid hold body", http.StatusBadRequest)
+		return
+	}
+	req.Reason = strings.TrimSpace(req.Reason)
+	if req.Kind != models.HoldKindTask && req.Kind != models.HoldKindCase {
+		http.Error(w, fmt.Sprintf("kind must be %s or %s", models.HoldKindTask, models.HoldKindCase), http.StatusBadRequest)
+		return
+	}
+	if req.Ref == "" || len(req.Ref) > maxHoldRefLen {
+		http.Error(w, "ref is required", http.StatusBadRequest)
+		return
+	}
+	if req.Reason == "" || len(req.Reason) > models.MaxHoldReasonLen || len(req.Principal) > models.MaxHoldPrincipalLen {
+		http.Error(w, fmt.Sprintf("reason required (max %d bytes), principal max %d bytes", models.MaxHoldReasonLen, models.MaxHoldPrincipalLen), http.StatusBadRequest)
+		return
+	}
+	if req.Principal == "" {
+		req.Principal = defaultApprover
+	}
+	if status, err := h.resolveHoldRef(reader, &req); err != nil {
+		http.Error(w, err.Error(), status)
+		return
+	}
+	held := false
+	for i := 0; i < len(holds); i++ {
+		if holds[i].Kind == req.Kind && holds[i].Ref == req.Ref {
+			held = true
+			break
+		}
+	}
+	if held != req.Release {
+		msg := fmt.Sprintf("%s %s is already on hold", req.Kind, req.Ref)
+		if req.Release {
+			msg = fmt.Sprintf("%s %s is not on hold", req.Kind, req.Ref)
+		}
+		http.Error(w, msg, http.StatusConflict)
+		return
+	}
+	id := h.submitHold(req)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	if err := json.NewEncoder(w).Encode(HoldResponse{ID: id, Kind: req.Kind, Ref: req.Ref, Release: req.Release}); err != nil {
+		logging.Error("hold_encode_failed", logging.Fields{Component: "api", Error: err.Error()})
+	}
+}
+
+// resolveHoldRef checks the held task or case exists, replacing a case name with its ID
+// so the hold survives a rename.
+func (h *Handlers) resolveHoldRef(reader ledger.LegalHoldReader, req *HoldRequest) (int, error) {
+	if req.Kind == models.HoldKindCase {
+		c, err := reader.GetCase(req.Ref)
+		if errors.Is(err, store.ErrCaseNotFound) {
+			return http.StatusNotFound, err
+		}
+		if err != nil {
+			return http.StatusInternalServerError, err
+		}
+		req.Ref = c.ID
+		return 0, nil
+	}
+	events, err := h.Core.Worker.GetDB().GetEventsByTaskID(req.Ref)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	if len(events) == 0 && !req.Release {
+		return http.StatusNotFound, fmt.Errorf("task %s has no events", req.Ref)
+	}
+	return 0, nil
+}
+
+// submitHold ledgers the hold as a user event, signed and chained like any other. A task
+// hold carries the task ID, so it shows in the task's trace. Returns the event ID.
+func (h *Handlers) submitHold(req HoldRequest) string {
+	event := pool.GetEvent()
+	event.ID = uuid.New().String()[:8]
+	event.Timestamp = time.Now()
+	event.Actor = "user"
+	event.EventType = models.EventTypeLegalHoldSet
+	if req.Release {
+		event.EventType = models.EventTypeLegalHoldReleased
+	}
+	event.Method = "logryph:legal_hold"
+	if req.Kind == models.HoldKindTask {
+		event.TaskID = req.Ref
+	}
+	event.Params["kind"] = req.Kind
+	event.Params["ref"] = req.Ref
+	event.Params["reason"] = req.Reason
+	event.Params["principal"] = req.Principal
+	id, msg := event.ID, event.EventType
+
+	h.Core.Worker.Submit(event)
+	logging.Info(msg, logging.Fields{Component: "api", EventID: id, TaskID: req.Ref})
+	return id
+}
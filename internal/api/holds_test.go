@@ -0,0 +1,70 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/slyt3/Logryph/internal/ledger/store"
+	"github.com/slyt3/Logryph/internal/models"
+)
+
+func TestHandleHolds_SetListRelease(t *testing.T) {
+	engine, worker, cleanup := setupTestEngine(t)
+	defer cleanup()
+	db := worker.GetDB().(*store.DB)
+	if err := db.CreateCase(models.Case{ID: "case-1", Name: "incident", CreatedAt: time.Now()}); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AddCaseItem(models.CaseItem{CaseID: "case-1", Kind: models.CaseItemEvent, Ref: "evt-test", AddedAt: time.Now()}); err != nil {
+		t.Fatal(err)
+	}
+	h := NewHandlers(engine)
+	post := func(body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		h.HandleHolds(rec, httptest.NewRequest(http.MethodPost, "/api/holds", strings.NewReader(body)))
+		return rec
+	}
+
+	rec := post(`{"kind":"case","ref":"incident","reason":"litigation","principal":"legal@example.com"}`)
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("set: %d %s", rec.Code, rec.Body.String())
+	}
+	var ack HoldResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &ack); err != nil || ack.Ref != "case-1" {
+		t.Fatalf("a case hold should name the case ID: %v %s", err, rec.Body.String())
+	}
+	waitForProcessed(t, worker, 1, 2*time.Second)
+
+	event, err := db.GetEventByID(ack.ID)
+	if err != nil || event.EventType != models.EventTypeLegalHoldSet || event.Params["principal"] != "legal@example.com" || event.Signature == "" {
+		t.Fatalf("hold event not ledgered as expected: %v %+v", err, event)
+	}
+	if rec := post(`{"kind":"case","ref":"case-1","reason":"again"}`); rec.Code != http.StatusConflict {
+		t.Errorf("holding a held case: %d, want 409", rec.Code)
+	}
+	if rec := post(`{"kind":"task","ref":"no-such-task","reason":"x"}`); rec.Code != http.StatusNotFound {
+		t.Errorf("holding an unknown task: %d, want 404", rec.Code)
+	}
+	scope, err := db.GetLegalHoldScope()
+	if err != nil || !scope.Covers(&models.Event{ID: "evt-test"}) || scope.Covers(&models.Event{ID: "other"}) {
+		t.Fatalf("scope %+v, err %v", scope, err)
+	}
+
+	if rec := post(`{"kind":"case","ref":"incident","reason":"settled","release":true}`); rec.Code != http.StatusAccepted {
+		t.Fatalf("release: %d %s", rec.Code, rec.Body.String())
+	}
+	waitForProcessed(t, worker, 2, 2*time.Second)
+	rec = httptest.NewRecorder()
+	h.HandleHolds(rec, httptest.NewRequest(http.MethodGet, "/api/holds", nil))
+	var holds []models.LegalHold
+	if err := json.Unmarshal(rec.Body.Bytes(), &holds); err != nil || len(holds) != 0 {
+		t.Errorf("holds after release: %v %s", err, rec.Body.String())
+	}
+	if rec := post(`{"kind":"case","ref":"incident","reason":"again","release":true}`); rec.Code != http.StatusConflict {
+		t.Errorf("releasing an unheld case: %d, want 409", rec.Code)
+	}
+}
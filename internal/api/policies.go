@@ -0,0 +1,74 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/slyt3/Logryph/internal/logging"
+	"github.com/slyt3/Logryph/internal/observer"
+)
+
+// PolicyRuleStatus is one loaded rule with its live activity.
+type PolicyRuleStatus struct {
+	ID           string   `json:"id"`
+	MatchMethods []string `json:"match_methods"`
+	RiskLevel    string   `json:"risk_level,omitempty"`
+	Action       string   `json:"action"`
+	When         string   `json:"when,omitempty"`
+	observer.RuleStat
+}
+
+// PoliciesResponse lists the rules in force with how often each has matched.
+type PoliciesResponse struct {
+	Version         string             `json:"version"`
+	Environment     string             `json:"environment,omitempty"`
+	EnforcementMode string             `json:"enforcement_mode"`
+	Rules           []PolicyRuleStatus `json:"rules"`
+}
+
+// HandlePolicies returns the rules for the active environment, in evaluation order, with
+// match counts, the last match time and the actions taken since startup. Counts follow a
+// rule across reloads as long as its id is unchanged.
+// Requires GET and X-Admin-Token if configured.
+func (h *Handlers) HandlePolicies(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !authorizeAdmin(w, r) {
+		return
+	}
+	if h.Core == nil || h.Core.Observer == nil {
+		http.Error(w, "no policy loaded", http.StatusServiceUnavailable)
+		return
+	}
+	obs := h.Core.Observer
+	resp := PoliciesResponse{
+		Version:         obs.GetVersion(),
+		Environment:     obs.GetEnvironment(),
+		EnforcementMode: observer.EnforcementObserve,
+		Rules:           []PolicyRuleStatus{},
+	}
+	if obs.IsEnforcing() {
+		resp.EnforcementMode = observer.EnforcementEnforce
+	}
+	rules := obs.GetPoliciesFor(resp.Environment)
+	for i := 0; i < len(rules); i++ {
+		action := rules[i].Action
+		if action == "" {
+			action = observer.RuleActionTag
+		}
+		resp.Rules = append(resp.Rules, PolicyRuleStatus{
+			ID:           rules[i].ID,
+			MatchMethods: rules[i].MatchMethods,
+			RiskLevel:    rules[i].RiskLevel,
+			Action:       action,
+			When:         rules[i].When,
+			RuleStat:     obs.GetRuleStat(rules[i].ID),
+		})
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		logging.Error("policies_encode_failed", logging.Fields{Component: "api", Error: err.Error()})
+	}
+}
@@ -0,0 +1,57 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/slyt3/Logryph/internal/core"
+	"github.com/slyt3/Logryph/internal/observer"
+)
+
+func TestHandlePolicies(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "logryph-policy.yaml")
+	policy := `version: "2.1"
+policies:
+  - id: "payments"
+    match_methods: ["stripe:*"]
+    risk_level: "high"
+    action: "stall"
+  - id: "reads"
+    match_methods: ["fs:read"]
+    risk_level: "low"
+`
+	if err := os.WriteFile(path, []byte(policy), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	obs, err := observer.NewObserverEngine(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	obs.RecordMatch("payments", observer.MatchActionStallObserved)
+	obs.RecordMatch("payments", observer.MatchActionStallObserved)
+	obs.RecordDecision("payments", "approved")
+
+	rec := httptest.NewRecorder()
+	NewHandlers(&core.Engine{Observer: obs}).HandlePolicies(rec, httptest.NewRequest(http.MethodGet, "/api/policies", nil))
+	var resp PoliciesResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("invalid response: %v %s", err, rec.Body.String())
+	}
+	if resp.Version != "2.1" || resp.EnforcementMode != observer.EnforcementObserve || len(resp.Rules) != 2 {
+		t.Fatalf("unexpected response: %+v", resp)
+	}
+	pay, reads := resp.Rules[0], resp.Rules[1]
+	if pay.ID != "payments" || pay.Action != "stall" || pay.Matches != 2 || pay.LastMatch == nil {
+		t.Errorf("payments rule: %+v", pay)
+	}
+	if pay.Actions[observer.MatchActionStallObserved] != 2 || pay.Decisions["approved"] != 1 {
+		t.Errorf("payments actions %v, decisions %v", pay.Actions, pay.Decisions)
+	}
+	if reads.ID != "reads" || reads.Action != "tag" || reads.Matches != 0 || reads.LastMatch != nil {
+		t.Errorf("unmatched rule: %+v", reads)
+	}
+}
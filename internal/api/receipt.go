@@ -0,0 +1,62 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/slyt3/Logryph/internal/attest"
+	"github.com/slyt3/Logryph/internal/logging"
+)
+
+// HandleReceipt issues a signed receipt for a ledgered event:
+// GET /api/receipt/{event-id}. The receipt names the event's hash and seq and the run's
+// chain head at the time of issue (see attest.Receipt). Events still queued for the
+// ledger return 404 until they are written.
+// Requires X-Admin-Token header if LOGRYPH_ADMIN_TOKEN is set.
+func (h *Handlers) HandleReceipt(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !authorizeAdmin(w, r) {
+		return
+	}
+	if h.Core == nil || h.Core.Worker == nil {
+		http.Error(w, "ledger unavailable", http.StatusServiceUnavailable)
+		return
+	}
+	eventID := strings.TrimPrefix(r.URL.Path, "/api/receipt/")
+	if eventID == "" || len(eventID) > maxEventIDLen || strings.Contains(eventID, "/") {
+		http.Error(w, "event id is required: /api/receipt/{event-id}", http.StatusBadRequest)
+		return
+	}
+
+	db := h.Core.Worker.GetDB()
+	event, err := db.GetEventByID(eventID)
+	if errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, "event not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	headSeq, headHash, err := db.GetLastEvent(event.RunID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	receipt, err := attest.NewReceipt(event, headSeq, headHash, h.Core.Worker.GetSigner())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	logging.Info("receipt_issued", logging.Fields{Component: "api", RunID: event.RunID, EventID: event.ID})
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(receipt); err != nil {
+		logging.Error("receipt_encode_failed", logging.Fields{Component: "api", Error: err.Error()})
+	}
+}
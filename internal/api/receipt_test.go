@@ -0,0 +1,43 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/slyt3/Logryph/internal/attest"
+)
+
+func TestHandleReceipt(t *testing.T) {
+	engine, worker, cleanup := setupTestEngine(t)
+	defer cleanup()
+
+	emitTestEvent(worker)
+	waitForProcessed(t, worker, 1, 2*time.Second)
+	h := NewHandlers(engine)
+
+	rec := httptest.NewRecorder()
+	h.HandleReceipt(rec, httptest.NewRequest(http.MethodGet, "/api/receipt/evt-test", nil))
+	var receipt attest.Receipt
+	if err := json.Unmarshal(rec.Body.Bytes(), &receipt); err != nil {
+		t.Fatalf("invalid response: %v %s", err, rec.Body.String())
+	}
+	if err := attest.VerifyReceipt(&receipt, worker.GetSigner().GetPublicKey()); err != nil {
+		t.Fatalf("VerifyReceipt: %v", err)
+	}
+	event, err := worker.GetDB().GetEventByID("evt-test")
+	if err != nil {
+		t.Fatalf("GetEventByID: %v", err)
+	}
+	if receipt.EventHash != event.CurrentHash || receipt.SeqIndex != event.SeqIndex || receipt.ChainSeq < event.SeqIndex {
+		t.Errorf("receipt %+v does not describe event %+v", receipt, event)
+	}
+
+	rec = httptest.NewRecorder()
+	h.HandleReceipt(rec, httptest.NewRequest(http.MethodGet, "/api/receipt/missing", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("unknown event: status %d, want 404", rec.Code)
+	}
+}
@@ -0,0 +1,91 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/slyt3/Logryph/internal/analyzer"
+	"github.com/slyt3/Logryph/internal/logging"
+	"github.com/slyt3/Logryph/internal/models"
+)
+
+const maxRiskCursorLen = 64
+
+// RiskResponse is one page of GET /api/risk. NextCursor is passed back as ?cursor= for
+// the next page and is empty after the last.
+type RiskResponse struct {
+	Events     []models.Event `json:"events"`
+	NextCursor string         `json:"next_cursor,omitempty"`
+}
+
+// HandleRisk lists risk events across runs, newest first, a page at a time. GET
+// ?level=L keeps events at or above L (default high); ?since= takes a duration such as
+// 24h or an RFC 3339 time; ?limit=N (default 100, max 1000) sizes the page; ?cursor=
+// continues from a previous page.
+// Requires X-Admin-Token header if LOGRYPH_ADMIN_TOKEN is set.
+func (h *Handlers) HandleRisk(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !authorizeAdmin(w, r) {
+		return
+	}
+	if h.Core == nil || h.Core.Worker == nil {
+		http.Error(w, "ledger unavailable", http.StatusServiceUnavailable)
+		return
+	}
+	q, err := ParseRiskQuery(r.URL.Query().Get("level"), r.URL.Query().Get("since"), r.URL.Query().Get("limit"), time.Now())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if q.After = r.URL.Query().Get("cursor"); len(q.After) > maxRiskCursorLen {
+		http.Error(w, "invalid cursor", http.StatusBadRequest)
+		return
+	}
+	events, next, err := h.Core.Worker.GetDB().GetRiskEventsPage(q)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if events == nil {
+		events = []models.Event{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(RiskResponse{Events: events, NextCursor: next}); err != nil {
+		logging.Error("risk_encode_failed", logging.Fields{Component: "api", Error: err.Error()})
+	}
+}
+
+// ParseRiskQuery builds a risk query from its text form, shared by the API and logyctl
+// risk. Empty values take the defaults: level high, no since, limit 100.
+func ParseRiskQuery(level, since, limit string, now time.Time) (models.RiskQuery, error) {
+	q := models.RiskQuery{Limit: defaultEventsLimit}
+	if level == "" {
+		level = analyzer.RiskHigh
+	}
+	if q.Levels = analyzer.RiskLevelsAtLeast(level); q.Levels == nil {
+		return q, fmt.Errorf("unknown risk level %q: must be low, medium, high or critical", level)
+	}
+	if since != "" {
+		if d, err := time.ParseDuration(since); err == nil && d > 0 {
+			q.Since = now.Add(-d)
+		} else if t, err := time.Parse(time.RFC3339, since); err == nil {
+			q.Since = t
+		} else {
+			return q, fmt.Errorf("invalid since %q: use a duration such as 24h or an RFC 3339 time", since)
+		}
+	}
+	if limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 1 || n > maxEventsLimit {
+			return q, fmt.Errorf("limit must be between 1 and %d", maxEventsLimit)
+		}
+		q.Limit = n
+	}
+	return q, nil
+}
@@ -0,0 +1,55 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/slyt3/Logryph/internal/pool"
+)
+
+func TestHandleRisk_PagesBySeverity(t *testing.T) {
+	engine, worker, cleanup := setupTestEngine(t)
+	defer cleanup()
+	for i, level := range []string{"critical", "high", "low", "critical"} {
+		event := pool.GetEvent()
+		event.ID = fmt.Sprintf("evt-risk-%d", i)
+		event.Timestamp = time.Now()
+		event.Actor = "agent"
+		event.EventType = "tool_call"
+		event.Method = "os.exec"
+		event.RiskLevel = level
+		worker.Submit(event)
+	}
+	waitForProcessed(t, worker, 4, 2*time.Second)
+	h := NewHandlers(engine)
+	get := func(query string) (int, RiskResponse) {
+		rec := httptest.NewRecorder()
+		h.HandleRisk(rec, httptest.NewRequest(http.MethodGet, "/api/risk"+query, nil))
+		var resp RiskResponse
+		if rec.Code == http.StatusOK {
+			if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("decoding %q: %v", rec.Body.String(), err)
+			}
+		}
+		return rec.Code, resp
+	}
+
+	code, first := get("?level=critical&limit=1&since=1h")
+	if code != http.StatusOK || len(first.Events) != 1 || first.NextCursor == "" {
+		t.Fatalf("first page: %d %+v", code, first)
+	}
+	_, second := get("?level=critical&limit=1&cursor=" + first.NextCursor)
+	if len(second.Events) != 1 || second.NextCursor != "" || second.Events[0].ID == first.Events[0].ID {
+		t.Fatalf("second page: %+v", second)
+	}
+	if _, all := get(""); len(all.Events) != 3 {
+		t.Errorf("default level high: %d events, want 3", len(all.Events))
+	}
+	if code, _ := get("?level=severe"); code != http.StatusBadRequest {
+		t.Errorf("unknown level: %d, want 400", code)
+	}
+}
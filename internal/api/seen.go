@@ -0,0 +1,143 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/slyt3/Logryph/internal/crypto"
+	"github.com/slyt3/Logryph/internal/ledger"
+	"github.com/slyt3/Logryph/internal/logging"
+	"github.com/slyt3/Logryph/internal/models"
+)
+
+const (
+	maxSeenMatches = 100
+	maxHashLen     = 64 // hex-encoded SHA-256
+	maxSeenParams  = 64 * 1024
+)
+
+// SeenMatch is one ledgered event matching a /api/seen lookup.
+type SeenMatch struct {
+	ID          string    `json:"id"`
+	RunID       string    `json:"run_id"`
+	SeqIndex    uint64    `json:"seq_index"`
+	Timestamp   time.Time `json:"timestamp"`
+	EventType   string    `json:"event_type"`
+	Method      string    `json:"method"`
+	CurrentHash string    `json:"current_hash"`
+}
+
+// SeenResponse answers whether a hash or payload has ever been recorded.
+type SeenResponse struct {
+	Seen       bool        `json:"seen"`
+	ParamsHash string      `json:"params_hash,omitempty"`
+	Events     []SeenMatch `json:"events"`
+}
+
+// HandleSeen answers "has this ever been recorded" from the ledger's hash indexes,
+// across every run. GET ?hash=H looks up the event whose hash is H; ?params_hash=P, or
+// ?method=M&params=<JSON> hashed the same way (see crypto.PayloadHash), lists up to 100
+// events that carried that exact call, oldest first.
+// Requires X-Admin-Token header if LOGRYPH_ADMIN_TOKEN is set.
+func (h *Handlers) HandleSeen(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !authorizeAdmin(w, r) {
+		return
+	}
+	if h.Core == nil || h.Core.Worker == nil {
+		http.Error(w, "ledger unavailable", http.StatusServiceUnavailable)
+		return
+	}
+	index, ok := h.Core.Worker.GetDB().(ledger.HashIndex)
+	if !ok {
+		http.Error(w, "hash lookups not supported by this ledger", http.StatusNotImplemented)
+		return
+	}
+	q := r.URL.Query()
+	var (
+		resp SeenResponse
+		err  error
+	)
+	switch {
+	case q.Get("hash") != "":
+		resp, err = seenHash(index, q.Get("hash"))
+	case q.Get("params_hash") != "" || q.Get("method") != "":
+		resp, err = seenPayload(index, q.Get("params_hash"), q.Get("method"), q.Get("params"))
+	default:
+		http.Error(w, "hash, params_hash or method is required", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		status := http.StatusInternalServerError
+		if _, bad := err.(badLookup); bad {
+			status = http.StatusBadRequest
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+	resp.Seen = len(resp.Events) > 0
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		logging.Error("seen_encode_failed", logging.Fields{Component: "api", Error: err.Error()})
+	}
+}
+
+// badLookup is a malformed lookup, reported as 400.
+type badLookup string
+
+func (e badLookup) Error() string { return string(e) }
+
+func seenHash(index ledger.HashIndex, hash string) (SeenResponse, error) {
+	resp := SeenResponse{Events: []SeenMatch{}}
+	if len(hash) > maxHashLen {
+		return resp, badLookup("hash is too long")
+	}
+	event, err := index.FindByHash(hash)
+	if err != nil || event == nil {
+		return resp, err
+	}
+	resp.Events = append(resp.Events, seenMatch(event))
+	return resp, nil
+}
+
+// seenPayload looks up paramsHash, or hashes method and params when paramsHash is empty.
+func seenPayload(index ledger.HashIndex, paramsHash, method, params string) (SeenResponse, error) {
+	resp := SeenResponse{Events: []SeenMatch{}}
+	if paramsHash == "" {
+		if len(params) > maxSeenParams {
+			return resp, badLookup("params is too large")
+		}
+		var decoded interface{}
+		if params != "" {
+			if err := json.Unmarshal([]byte(params), &decoded); err != nil {
+				return resp, badLookup("params must be JSON: " + err.Error())
+			}
+		}
+		hash, err := crypto.PayloadHash(method, decoded)
+		if err != nil {
+			return resp, badLookup("hashing params: " + err.Error())
+		}
+		paramsHash = hash
+	}
+	if len(paramsHash) > maxHashLen {
+		return resp, badLookup("params_hash is too long")
+	}
+	resp.ParamsHash = paramsHash
+	events, err := index.FindByParamsHash(paramsHash, maxSeenMatches)
+	if err != nil {
+		return resp, err
+	}
+	for i := 0; i < len(events); i++ {
+		resp.Events = append(resp.Events, seenMatch(&events[i]))
+	}
+	return resp, nil
+}
+
+func seenMatch(e *models.Event) SeenMatch {
+	return SeenMatch{ID: e.ID, RunID: e.RunID, SeqIndex: e.SeqIndex, Timestamp: e.Timestamp,
+		EventType: e.EventType, Method: e.Method, CurrentHash: e.CurrentHash}
+}
@@ -0,0 +1,46 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestHandleSeen(t *testing.T) {
+	engine, worker, cleanup := setupTestEngine(t)
+	defer cleanup()
+
+	emitTestEvent(worker)
+	waitForProcessed(t, worker, 1, 2*time.Second)
+	h := NewHandlers(engine)
+
+	seen := func(query string) SeenResponse {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		h.HandleSeen(rec, httptest.NewRequest(http.MethodGet, "/api/seen?"+query, nil))
+		var resp SeenResponse
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("%s: invalid response: %v %s", query, err, rec.Body.String())
+		}
+		return resp
+	}
+
+	resp := seen("method=os.read&params=%7B%7D")
+	if !resp.Seen || len(resp.Events) != 1 || resp.Events[0].ID != "evt-test" {
+		t.Fatalf("payload lookup: %+v", resp)
+	}
+	if again := seen("hash=" + resp.Events[0].CurrentHash); !again.Seen || again.Events[0].ID != "evt-test" {
+		t.Errorf("hash lookup: %+v", again)
+	}
+	if other := seen(`method=os.read&params=%7B%22path%22%3A%22x%22%7D`); other.Seen || other.Events == nil {
+		t.Errorf("a different payload should not be seen: %+v", other)
+	}
+
+	rec := httptest.NewRecorder()
+	h.HandleSeen(rec, httptest.NewRequest(http.MethodGet, "/api/seen?method=os.read&params=%7Bnot", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("malformed params: status %d, want 400", rec.Code)
+	}
+}
@@ -0,0 +1,40 @@
+package api
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"os"
+
+	"github.com/slyt3/Logryph/internal/logging"
+	"github.com/slyt3/Logryph/internal/sockaddr"
+)
+
+type socketAuthKey struct{}
+
+// SocketAuthContext is an http.Server ConnContext for an admin API served on a Unix
+// socket whose file permissions are the access control. Requests over such a connection
+// need no X-Admin-Token or bulk approval token, provided the peer runs as the proxy's
+// own user. TCP connections, and peers whose credentials cannot be read, are left to
+// the tokens.
+func SocketAuthContext(ctx context.Context, c net.Conn) context.Context {
+	uc, ok := c.(*net.UnixConn)
+	if !ok {
+		return ctx
+	}
+	uid, err := sockaddr.PeerUID(uc)
+	if err != nil {
+		logging.Warn("admin_socket_peer_unknown", logging.Fields{Component: "api", Error: err.Error()})
+		return ctx
+	}
+	if uid != os.Getuid() {
+		return ctx
+	}
+	return context.WithValue(ctx, socketAuthKey{}, true)
+}
+
+// socketAuthorized reports whether r arrived over a connection SocketAuthContext trusts.
+func socketAuthorized(r *http.Request) bool {
+	trusted, _ := r.Context().Value(socketAuthKey{}).(bool)
+	return trusted
+}
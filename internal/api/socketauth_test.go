@@ -0,0 +1,56 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/slyt3/Logryph/internal/sockaddr"
+)
+
+func TestSocketAuthSkipsTokensOnlyOverTheSocket(t *testing.T) {
+	engine, _, cleanup := setupTestEngine(t)
+	defer cleanup()
+	t.Setenv("LOGRYPH_ADMIN_TOKEN", "s3cret")
+	t.Setenv(BulkApprovalTokenEnv, "bulk")
+	h := NewHandlers(engine)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/holds", h.HandleHolds)
+
+	path := filepath.Join(t.TempDir(), "admin.sock")
+	ln, err := sockaddr.ListenMode("unix:"+path, sockaddr.OwnerSocketMode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != sockaddr.OwnerSocketMode {
+		t.Errorf("admin socket mode = %v, %v; want owner-only", info.Mode().Perm(), err)
+	}
+	srv := &http.Server{Handler: mux, ConnContext: SocketAuthContext}
+	go func() { _ = srv.Serve(ln) }()
+	defer srv.Close()
+
+	client := &http.Client{Transport: sockaddr.Transport(http.DefaultTransport.(*http.Transport), path)}
+	resp, err := client.Get("http://localhost/api/holds")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("over the socket without a token: %d, want 200", resp.StatusCode)
+	}
+
+	tcp := httptest.NewUnstartedServer(mux)
+	tcp.Config.ConnContext = SocketAuthContext
+	tcp.Start()
+	defer tcp.Close()
+	resp, err = http.Get(tcp.URL + "/api/holds")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("over TCP without a token: %d, want 401", resp.StatusCode)
+	}
+}
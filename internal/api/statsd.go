@@ -0,0 +1,233 @@
+package api
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/slyt3/Logryph/internal/assert"
+	"github.com/slyt3/Logryph/internal/logging"
+	"github.com/slyt3/Logryph/internal/metrics"
+)
+
+// StatsD wire flavors.
+const (
+	StatsdPlain = "statsd"
+	StatsdDog   = "dogstatsd"
+)
+
+const (
+	maxStatsdPacket = 1432 // fits one UDP datagram on a standard MTU
+	maxStatsdTags   = 32
+	minStatsdFlush  = 100 * time.Millisecond
+	maxStatsdTicks  = 1 << 30
+)
+
+// StatsdConfig configures the push emitter.
+type StatsdConfig struct {
+	Addr     string        // host:port of the StatsD agent
+	Flavor   string        // StatsdPlain or StatsdDog
+	Interval time.Duration // flush interval
+	Tags     []string      // DogStatsD tags (key:value) added to every metric
+}
+
+// StatsdEmitter pushes the /metrics values to a StatsD or DogStatsD agent. Counters are
+// sent as deltas since the previous flush, gauges as current values, and the latency
+// histogram as one sampled timing per non-empty bucket.
+type StatsdEmitter struct {
+	h      *Handlers
+	cfg    StatsdConfig
+	conn   net.Conn
+	prev   *prometheusMetrics
+	stopCh chan struct{}
+	wg     sync.WaitGroup
+}
+
+// StartStatsd validates cfg, dials the agent and starts flushing every cfg.Interval.
+// Stop flushes one last time and closes the connection.
+func (h *Handlers) StartStatsd(cfg StatsdConfig) (*StatsdEmitter, error) {
+	if err := assert.NotNil(h, "handlers"); err != nil {
+		return nil, err
+	}
+	if cfg.Flavor == "" {
+		cfg.Flavor = StatsdPlain
+	}
+	if cfg.Flavor != StatsdPlain && cfg.Flavor != StatsdDog {
+		return nil, fmt.Errorf("unknown statsd flavor %q (want %s or %s)", cfg.Flavor, StatsdPlain, StatsdDog)
+	}
+	if cfg.Interval < minStatsdFlush {
+		return nil, fmt.Errorf("statsd interval must be at least %s", minStatsdFlush)
+	}
+	if len(cfg.Tags) > maxStatsdTags {
+		return nil, fmt.Errorf("too many statsd tags: %d (max %d)", len(cfg.Tags), maxStatsdTags)
+	}
+	conn, err := net.Dial("udp", cfg.Addr)
+	if err != nil {
+		return nil, fmt.Errorf("dialing statsd %s: %w", cfg.Addr, err)
+	}
+	e := &StatsdEmitter{h: h, cfg: cfg, conn: conn, stopCh: make(chan struct{})}
+	e.wg.Add(1)
+	go e.run()
+	return e, nil
+}
+
+func (e *StatsdEmitter) run() {
+	defer e.wg.Done()
+	ticker := time.NewTicker(e.cfg.Interval)
+	defer ticker.Stop()
+	for i := 0; i < maxStatsdTicks; i++ {
+		select {
+		case <-e.stopCh:
+			e.flush()
+			return
+		case <-ticker.C:
+			e.flush()
+		}
+	}
+}
+
+// Stop sends a final flush and closes the connection.
+func (e *StatsdEmitter) Stop() {
+	if e == nil {
+		return
+	}
+	close(e.stopCh)
+	e.wg.Wait()
+	if err := e.conn.Close(); err != nil {
+		logging.Warn("statsd_close_failed", logging.Fields{Component: "api", Error: err.Error()})
+	}
+}
+
+func (e *StatsdEmitter) flush() {
+	cur := e.h.collectMetrics()
+	lines := formatStatsd(e.cfg, cur, e.prev)
+	e.prev = cur
+	packets := packStatsd(lines)
+	for i := 0; i < len(packets); i++ {
+		if _, err := e.conn.Write(packets[i]); err != nil {
+			// UDP is best effort; an absent agent must never affect the proxy.
+			logging.Warn("statsd_write_failed", logging.Fields{Component: "api", Error: err.Error()})
+			return
+		}
+	}
+}
+
+// formatStatsd renders cur as StatsD lines. prev is the previous flush, or nil for the
+// first one, in which case counters report their totals so far.
+func formatStatsd(cfg StatsdConfig, cur, prev *prometheusMetrics) []string {
+	if prev == nil {
+		prev = &prometheusMetrics{}
+	}
+	var lines []string
+	counter := func(d metrics.Desc, now, before uint64) {
+		if now > before {
+			lines = append(lines, statsdLine(cfg, d.Name, fmt.Sprint(now-before), "c", "", nil))
+		}
+	}
+	gauge := func(d metrics.Desc, v int) {
+		lines = append(lines, statsdLine(cfg, d.Name, fmt.Sprint(v), "g", "", nil))
+	}
+	counter(metrics.PoolEventHits, cur.PoolEventHits, prev.PoolEventHits)
+	counter(metrics.PoolEventMisses, cur.PoolEventMisses, prev.PoolEventMisses)
+	counter(metrics.EventsProcessed, cur.EventsProcessed, prev.EventsProcessed)
+	counter(metrics.EventsDropped, cur.EventsDropped, prev.EventsDropped)
+	counter(metrics.EventsBlocked, cur.EventsBlocked, prev.EventsBlocked)
+	counter(metrics.UpstreamTimeouts, cur.UpstreamTimeouts, prev.UpstreamTimeouts)
+	counter(metrics.UpstreamErrors, cur.UpstreamErrors, prev.UpstreamErrors)
+	counter(metrics.LedgerRejections, cur.LedgerRejections, prev.LedgerRejections)
+	counter(metrics.UnrecordedCalls, cur.UnrecordedCalls, prev.UnrecordedCalls)
+	counter(metrics.ProxyQueued, cur.Concurrency.Waited, prev.Concurrency.Waited)
+	counter(metrics.ProxyThrottled, cur.Concurrency.Throttled, prev.Concurrency.Throttled)
+	if wait := cur.Concurrency.QueueWait - prev.Concurrency.QueueWait; wait > 0 {
+		lines = append(lines, statsdLine(cfg, metrics.ProxyQueueWait.Name, fmt.Sprintf("%.6f", wait.Seconds()), "c", "", nil))
+	}
+	gauge(metrics.ActiveTasks, cur.ActiveTasks)
+	gauge(metrics.QueueDepth, cur.QueueDepth)
+	gauge(metrics.QueueCapacity, cur.QueueCapacity)
+	gauge(metrics.ProxyInFlight, cur.Concurrency.InFlight)
+	gauge(metrics.ProxyQueueLength, cur.Concurrency.Queued)
+	gauge(metrics.LedgerRecording, cur.LedgerRecording)
+	modes := []struct {
+		desc metrics.Desc
+		mode string
+	}{{metrics.BackpressureMode, cur.BackpressureMode}, {metrics.LedgerGuarantee, cur.LedgerGuarantee}}
+	for _, m := range modes {
+		if m.mode == "" {
+			continue
+		}
+		if cfg.Flavor == StatsdDog {
+			lines = append(lines, statsdLine(cfg, m.desc.Name, "1", "g", "", []string{"mode:" + m.mode}))
+		} else {
+			lines = append(lines, statsdLine(cfg, m.desc.Name+"."+m.mode, "1", "g", "", nil))
+		}
+	}
+	return append(lines, latencyLines(cfg, &cur.LatencyMetrics, &prev.LatencyMetrics)...)
+}
+
+// latencyLines sends each bucket's new observations as one timing at the bucket's upper
+// bound, with a sample rate of 1/n so the agent counts it n times.
+func latencyLines(cfg StatsdConfig, cur, prev *LatencySnapshot) []string {
+	var lines []string
+	kind := "ms"
+	if cfg.Flavor == StatsdDog {
+		kind = "h"
+	}
+	name := strings.TrimSuffix(metrics.EventLatency.Name, "_seconds") + "_ms"
+	lastBound := uint64(0)
+	for i := 0; i < len(cur.BoundsNs); i++ {
+		bound := cur.BoundsNs[i]
+		if bound == ^uint64(0) {
+			bound = lastBound
+		} else if bound != 0 {
+			lastBound = bound
+		}
+		if cur.Counts[i] <= prev.Counts[i] || bound == 0 {
+			continue
+		}
+		n := cur.Counts[i] - prev.Counts[i]
+		rate := ""
+		if n > 1 {
+			rate = fmt.Sprintf("%g", 1/float64(n))
+		}
+		ms := fmt.Sprintf("%g", float64(bound)/float64(time.Millisecond))
+		lines = append(lines, statsdLine(cfg, name, ms, kind, rate, nil))
+	}
+	return lines
+}
+
+func statsdLine(cfg StatsdConfig, name, value, kind, rate string, extraTags []string) string {
+	line := name + ":" + value + "|" + kind
+	if rate != "" {
+		line += "|@" + rate
+	}
+	if cfg.Flavor != StatsdDog {
+		return line
+	}
+	tags := append(append([]string{}, cfg.Tags...), extraTags...)
+	if len(tags) > 0 {
+		line += "|#" + strings.Join(tags, ",")
+	}
+	return line
+}
+
+// packStatsd joins lines into newline-separated datagrams of at most maxStatsdPacket bytes.
+func packStatsd(lines []string) [][]byte {
+	var packets [][]byte
+	var cur []byte
+	for i := 0; i < len(lines); i++ {
+		if len(cur) > 0 && len(cur)+1+len(lines[i]) > maxStatsdPacket {
+			packets = append(packets, cur)
+			cur = nil
+		}
+		if len(cur) > 0 {
+			cur = append(cur, '\n')
+		}
+		cur = append(cur, lines[i]...)
+	}
+	if len(cur) > 0 {
+		packets = append(packets, cur)
+	}
+	return packets
+}
@@ -0,0 +1,90 @@
+package api
+
+import (
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStatsdEmitterPushesMetrics(t *testing.T) {
+	engine, worker, cleanup := setupTestEngine(t)
+	defer cleanup()
+
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer conn.Close()
+
+	emitTestEvent(worker)
+	waitForProcessed(t, worker, 1, 2*time.Second)
+
+	h := NewHandlers(engine)
+	emitter, err := h.StartStatsd(StatsdConfig{
+		Addr: conn.LocalAddr().String(), Flavor: StatsdDog, Interval: time.Hour, Tags: []string{"env:test"},
+	})
+	if err != nil {
+		t.Fatalf("StartStatsd: %v", err)
+	}
+	emitter.Stop() // final flush
+
+	if err := conn.SetReadDeadline(time.Now().Add(2 * time.Second)); err != nil {
+		t.Fatalf("deadline: %v", err)
+	}
+	buf := make([]byte, 64*1024)
+	n, _, err := conn.ReadFrom(buf)
+	if err != nil {
+		t.Fatalf("no statsd packet received: %v", err)
+	}
+	packet := string(buf[:n])
+	for _, want := range []string{
+		"logryph_ledger_events_processed_total:1|c|#env:test",
+		"logryph_ledger_queue_capacity:16|g|#env:test",
+		"logryph_ledger_backpressure_mode:1|g|#env:test,mode:drop",
+		"logryph_ledger_event_latency_ms:",
+	} {
+		if !strings.Contains(packet, want) {
+			t.Errorf("packet missing %q:\n%s", want, packet)
+		}
+	}
+}
+
+func TestFormatStatsdSendsCounterDeltas(t *testing.T) {
+	cfg := StatsdConfig{Flavor: StatsdPlain, Tags: []string{"ignored:yes"}}
+	prev := &prometheusMetrics{EventsProcessed: 10, EventsDropped: 2, BackpressureMode: "block"}
+	cur := &prometheusMetrics{EventsProcessed: 15, EventsDropped: 2, QueueDepth: 3, BackpressureMode: "block"}
+	cur.LatencyMetrics.BoundsNs[0] = uint64(time.Millisecond)
+	cur.LatencyMetrics.Counts[0] = 4
+
+	out := strings.Join(formatStatsd(cfg, cur, prev), "\n")
+	for _, want := range []string{
+		"logryph_ledger_events_processed_total:5|c",
+		"logryph_ledger_queue_depth:3|g",
+		"logryph_ledger_backpressure_mode.block:1|g",
+		"logryph_ledger_event_latency_ms:1|ms|@0.25",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("missing %q in:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "dropped") {
+		t.Errorf("unchanged counter should not be sent:\n%s", out)
+	}
+	if strings.Contains(out, "#") {
+		t.Errorf("plain statsd must not carry tags:\n%s", out)
+	}
+}
+
+func TestPackStatsdSplitsDatagrams(t *testing.T) {
+	line := strings.Repeat("x", 600) + ":1|c"
+	packets := packStatsd([]string{line, line, line})
+	if len(packets) != 2 {
+		t.Fatalf("expected 2 packets, got %d", len(packets))
+	}
+	for i := 0; i < len(packets); i++ {
+		if len(packets[i]) > maxStatsdPacket {
+			t.Fatalf("packet %d too large: %d bytes", i, len(packets[i]))
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/slyt3/Logryph/internal/ledger"
+	"github.com/slyt3/Logryph/internal/logging"
+	"github.com/slyt3/Logryph/internal/observer"
+)
+
+// LiveStatus is the proxy state shown by logyctl status --live.
+type LiveStatus struct {
+	Healthy          bool       `json:"healthy"`
+	UnhealthyReason  string     `json:"unhealthy_reason,omitempty"`
+	QueueDepth       int        `json:"queue_depth"`
+	QueueCapacity    int        `json:"queue_capacity"`
+	Processed        uint64     `json:"processed"`
+	Dropped          uint64     `json:"dropped"` // since startup
+	LastSeq          uint64     `json:"last_seq"`
+	LastAnchor       *time.Time `json:"last_anchor,omitempty"` // nil until an anchor is committed
+	Backpressure     string     `json:"backpressure"`
+	LedgerGuarantee  string     `json:"ledger_guarantee,omitempty"`
+	PolicyVersion    string     `json:"policy_version,omitempty"`
+	EnforcementMode  string     `json:"enforcement_mode,omitempty"`
+	Environment      string     `json:"environment,omitempty"`
+	PendingApprovals int        `json:"pending_approvals"`
+	Uptime           string     `json:"uptime"`
+}
+
+// HandleStatus returns a LiveStatus for on-call triage. Requires GET and X-Admin-Token
+// if configured.
+func (h *Handlers) HandleStatus(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !authorizeAdmin(w, r) {
+		return
+	}
+	if h == nil || h.Core == nil || h.Core.Worker == nil {
+		http.Error(w, "service unavailable", http.StatusServiceUnavailable)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(h.liveStatus()); err != nil {
+		logging.Error("status_encode_failed", logging.Fields{Component: "api", Error: err.Error()})
+	}
+}
+
+func (h *Handlers) liveStatus() LiveStatus {
+	worker := h.Core.Worker
+	st := LiveStatus{
+		Healthy:         worker.IsHealthy(),
+		UnhealthyReason: worker.UnhealthyReason(),
+		LastSeq:         worker.LastSeq(),
+		Backpressure:    "drop",
+		LedgerGuarantee: h.ledgerGuarantee(),
+		Uptime:          time.Since(startTime).Round(time.Second).String(),
+	}
+	st.QueueDepth, st.QueueCapacity = worker.QueueDepth()
+	st.Processed, st.Dropped = worker.Stats()
+	if anchor := worker.LastAnchor(); !anchor.IsZero() {
+		st.LastAnchor = &anchor
+	}
+	if worker.BackpressureMode() == ledger.BackpressureBlock {
+		st.Backpressure = "block"
+	}
+	if obs := h.Core.Observer; obs != nil {
+		st.PolicyVersion = obs.GetVersion()
+		st.EnforcementMode = observer.EnforcementObserve
+		if obs.IsEnforcing() {
+			st.EnforcementMode = observer.EnforcementEnforce
+		}
+		st.Environment = obs.GetEnvironment()
+	}
+	if h.Core.Approvals != nil {
+		st.PendingApprovals = h.Core.Approvals.Len()
+	}
+	return st
+}
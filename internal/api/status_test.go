@@ -0,0 +1,67 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/slyt3/Logryph/internal/observer"
+)
+
+func TestHandleStatus(t *testing.T) {
+	engine, worker, cleanup := setupTestEngine(t)
+	defer cleanup()
+
+	emitTestEvent(worker)
+	waitForProcessed(t, worker, 1, 2*time.Second)
+
+	rec := httptest.NewRecorder()
+	NewHandlers(engine).HandleStatus(rec, httptest.NewRequest(http.MethodGet, "/api/status", nil))
+	var st LiveStatus
+	if err := json.Unmarshal(rec.Body.Bytes(), &st); err != nil {
+		t.Fatalf("invalid response: %v %s", err, rec.Body.String())
+	}
+	if !st.Healthy || st.UnhealthyReason != "" || st.Processed != 1 || st.LastSeq != 1 || st.QueueCapacity == 0 {
+		t.Errorf("unexpected status: %+v", st)
+	}
+	if st.Backpressure != "drop" || st.LastAnchor != nil {
+		t.Errorf("unexpected status: %+v", st)
+	}
+}
+
+func TestHandleReadyFailsWhileReadOnly(t *testing.T) {
+	engine, worker, cleanup := setupTestEngine(t)
+	defer cleanup()
+	worker.SetReadOnly("test: integrity check failed")
+
+	ready := func() *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		NewHandlers(engine).HandleReady(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+		return rec
+	}
+	if rec := ready(); rec.Code != http.StatusServiceUnavailable || !strings.Contains(rec.Body.String(), "fail_open") {
+		t.Errorf("fail_open: got %d %q, want 503", rec.Code, rec.Body.String())
+	}
+
+	// A read-only ledger refuses calls, so even a degraded proxy is not ready.
+	policy := filepath.Join(t.TempDir(), "logryph-policy.yaml")
+	if err := os.WriteFile(policy, []byte("version: \"1.0\"\ndefaults:\n  ledger_guarantee: degraded\npolicies: []\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	obs, err := observer.NewObserverEngine(policy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	engine.Observer = obs
+	if rec := ready(); rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("degraded and read-only: got %d %q, want 503", rec.Code, rec.Body.String())
+	}
+	if st := NewHandlers(engine).liveStatus(); st.LedgerGuarantee != observer.GuaranteeDegraded {
+		t.Errorf("status ledger_guarantee = %q", st.LedgerGuarantee)
+	}
+}
@@ -0,0 +1,316 @@
+package approval
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/slyt3/Logryph/internal/assert"
+	"github.com/slyt3/Logryph/internal/crypto"
+	"github.com/slyt3/Logryph/internal/logging"
+)
+
+// TokenVersion identifies the offline approval token layout.
+const TokenVersion = "1"
+
+// Suffixes given to token files once handled, so each is applied at most once.
+const (
+	TokenSuffix   = ".approval.json"
+	appliedSuffix = ".applied"
+	invalidSuffix = ".invalid"
+	expiredSuffix = ".expired"
+)
+
+const (
+	defaultWatchInterval = 2 * time.Second
+	minWatchInterval     = 100 * time.Millisecond
+	maxTrustedApprovers  = 64
+	maxTokenBytes        = 16 * 1024
+	maxTokenFiles        = 1024
+	maxWatchTicks        = 1 << 31
+)
+
+// OfflineConfig is the offline_approvals section of the policy file. An empty Dir
+// disables file-drop approvals.
+type OfflineConfig struct {
+	Dir       string            `yaml:"dir,omitempty"`      // watched for *.approval.json tokens
+	Interval  string            `yaml:"interval,omitempty"` // how often to scan; default 2s
+	Approvers []TrustedApprover `yaml:"approvers,omitempty"`
+}
+
+// TrustedApprover names a key allowed to sign offline decisions.
+type TrustedApprover struct {
+	Name      string `yaml:"name"`
+	PublicKey string `yaml:"public_key"` // hex Ed25519
+}
+
+// Token is a decision on one stalled call made on another machine, for hosts with no
+// route to the admin API. Signature is the approver's Ed25519 signature over Digest of
+// every other field.
+type Token struct {
+	Version      string    `json:"version"`
+	EventID      string    `json:"event_id"`
+	Decision     Decision  `json:"decision"`
+	Approver     string    `json:"approver"`
+	IssuedAt     time.Time `json:"issued_at"`
+	ExpiresAt    time.Time `json:"expires_at"`
+	SignerPubKey string    `json:"signer_pubkey"`
+	Signature    string    `json:"signature"`
+}
+
+// Digest returns the hex SHA-256 of the token with Signature cleared.
+func (t *Token) Digest() (string, error) {
+	unsigned := *t
+	unsigned.Signature = ""
+	data, err := json.Marshal(unsigned)
+	if err != nil {
+		return "", fmt.Errorf("encoding token: %w", err)
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// NewToken signs a decision on eventID that is valid for ttl.
+func NewToken(eventID string, decision Decision, approver string, ttl time.Duration, signer *crypto.Signer) (*Token, error) {
+	if err := assert.NotNil(signer, "signer"); err != nil {
+		return nil, err
+	}
+	if eventID == "" || approver == "" || len(approver) > maxApproverLen {
+		return nil, errors.New("event id and an approver name of at most 128 bytes are required")
+	}
+	if decision != DecisionApproved && decision != DecisionRejected {
+		return nil, fmt.Errorf("invalid decision %q", decision)
+	}
+	if ttl <= 0 {
+		return nil, errors.New("token lifetime must be positive")
+	}
+	now := time.Now().UTC()
+	t := &Token{
+		Version: TokenVersion, EventID: eventID, Decision: decision, Approver: approver,
+		IssuedAt: now, ExpiresAt: now.Add(ttl), SignerPubKey: signer.GetPublicKey(),
+	}
+	digest, err := t.Digest()
+	if err != nil {
+		return nil, err
+	}
+	if t.Signature, err = signer.SignHash(digest); err != nil {
+		return nil, fmt.Errorf("signing token: %w", err)
+	}
+	return t, nil
+}
+
+// WriteToken writes t into dir as <event-id>-<decision>.approval.json and returns the path.
+func WriteToken(dir string, t *Token) (string, error) {
+	if err := assert.NotNil(t, "token"); err != nil {
+		return "", err
+	}
+	if strings.ContainsAny(t.EventID, `/\`) || t.EventID == "." || t.EventID == ".." {
+		return "", fmt.Errorf("invalid event id %q", t.EventID)
+	}
+	data, err := json.MarshalIndent(t, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("encoding token: %w", err)
+	}
+	path := filepath.Join(dir, t.EventID+"-"+string(t.Decision)+TokenSuffix)
+	if err := os.WriteFile(path, append(data, '\n'), 0o600); err != nil {
+		return "", fmt.Errorf("writing token: %w", err)
+	}
+	return path, nil
+}
+
+// ValidateOfflineConfig checks the offline_approvals section. A disabled section is
+// always valid.
+func ValidateOfflineConfig(c OfflineConfig) error {
+	if c.Dir == "" {
+		return nil
+	}
+	if _, err := c.interval(); err != nil {
+		return err
+	}
+	if len(c.Approvers) == 0 || len(c.Approvers) > maxTrustedApprovers {
+		return fmt.Errorf("dir needs 1-%d approvers", maxTrustedApprovers)
+	}
+	seen := make(map[string]bool, len(c.Approvers))
+	for i := 0; i < len(c.Approvers); i++ {
+		a := c.Approvers[i]
+		if a.Name == "" || len(a.Name) > maxApproverLen || seen[a.Name] {
+			return fmt.Errorf("approvers[%d]: name %q is empty, too long or repeated", i, a.Name)
+		}
+		seen[a.Name] = true
+		if key, err := hex.DecodeString(a.PublicKey); err != nil || len(key) != 32 {
+			return fmt.Errorf("approver %s: public_key must be a hex Ed25519 public key", a.Name)
+		}
+	}
+	return nil
+}
+
+func (c OfflineConfig) interval() (time.Duration, error) {
+	if c.Interval == "" {
+		return defaultWatchInterval, nil
+	}
+	d, err := time.ParseDuration(c.Interval)
+	if err != nil || d < minWatchInterval {
+		return 0, fmt.Errorf("invalid interval %q: must be a duration of at least %s", c.Interval, minWatchInterval)
+	}
+	return d, nil
+}
+
+// verify checks t against the trusted keys and returns the name the key is trusted as.
+func (c OfflineConfig) verify(t *Token, now time.Time) (string, error) {
+	if t.Version != TokenVersion {
+		return "", fmt.Errorf("unsupported token version %q", t.Version)
+	}
+	name := ""
+	for i := 0; i < len(c.Approvers); i++ {
+		if strings.EqualFold(c.Approvers[i].PublicKey, t.SignerPubKey) {
+			name = c.Approvers[i].Name
+		}
+	}
+	if name == "" {
+		return "", fmt.Errorf("signer %s is not a trusted approver", t.SignerPubKey)
+	}
+	digest, err := t.Digest()
+	if err != nil {
+		return "", err
+	}
+	if !crypto.VerifyWithPublicKey(t.SignerPubKey, digest, t.Signature) {
+		return "", errors.New("signature does not match the token contents")
+	}
+	if t.Decision != DecisionApproved && t.Decision != DecisionRejected {
+		return "", fmt.Errorf("invalid decision %q", t.Decision)
+	}
+	if now.After(t.ExpiresAt) {
+		return "", errExpired
+	}
+	return name, nil
+}
+
+var errExpired = errors.New("token expired")
+
+// Watcher applies offline approval tokens dropped into a directory. A valid token for a
+// pending stall resolves it, recorded with approver "offline:<trusted name>". A token
+// for an event that is not stalled yet is kept until it expires.
+type Watcher struct {
+	reg      *Registry
+	cfg      OfflineConfig
+	interval time.Duration
+
+	stop     chan struct{}
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+// NewWatcher watches c.Dir, which must exist.
+func NewWatcher(reg *Registry, c OfflineConfig) (*Watcher, error) {
+	if err := assert.NotNil(reg, "registry"); err != nil {
+		return nil, err
+	}
+	if err := ValidateOfflineConfig(c); err != nil {
+		return nil, err
+	}
+	if info, err := os.Stat(c.Dir); err != nil || !info.IsDir() {
+		return nil, fmt.Errorf("approval dir %s is not a directory", c.Dir)
+	}
+	interval, err := c.interval()
+	if err != nil {
+		return nil, err
+	}
+	return &Watcher{reg: reg, cfg: c, interval: interval, stop: make(chan struct{}), done: make(chan struct{})}, nil
+}
+
+// Start scans the directory every interval until Stop.
+func (w *Watcher) Start() {
+	go w.run()
+}
+
+func (w *Watcher) run() {
+	defer close(w.done)
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+	for i := 0; i < maxWatchTicks; i++ {
+		select {
+		case <-w.stop:
+			return
+		case <-ticker.C:
+			if err := w.Scan(); err != nil {
+				logging.Warn("offline_approval_scan_failed", logging.Fields{Component: "approval", Error: err.Error()})
+			}
+		}
+	}
+}
+
+// Stop ends scanning.
+func (w *Watcher) Stop() {
+	w.stopOnce.Do(func() { close(w.stop) })
+	<-w.done
+}
+
+// Scan applies every token currently in the directory.
+func (w *Watcher) Scan() error {
+	paths, err := filepath.Glob(filepath.Join(w.cfg.Dir, "*"+TokenSuffix))
+	if err != nil {
+		return err
+	}
+	for i := 0; i < len(paths) && i < maxTokenFiles; i++ {
+		w.apply(paths[i])
+	}
+	return nil
+}
+
+// apply handles one token file, renaming it once it has been used or cannot be.
+func (w *Watcher) apply(path string) {
+	t, err := readToken(path)
+	if err != nil {
+		w.retire(path, invalidSuffix, "", err)
+		return
+	}
+	name, err := w.cfg.verify(t, time.Now())
+	if errors.Is(err, errExpired) {
+		w.retire(path, expiredSuffix, t.EventID, err)
+		return
+	}
+	if err != nil {
+		w.retire(path, invalidSuffix, t.EventID, err)
+		return
+	}
+	err = w.reg.Resolve(t.EventID, t.Decision, "offline:"+name)
+	if errors.Is(err, ErrNotPending) {
+		return // the call may not have stalled yet; retried until the token expires
+	}
+	if err != nil {
+		w.retire(path, invalidSuffix, t.EventID, err)
+		return
+	}
+	logging.Info("offline_approval_applied", logging.Fields{Component: "approval", EventID: t.EventID})
+	w.retire(path, appliedSuffix, t.EventID, nil)
+}
+
+func (w *Watcher) retire(path, suffix, eventID string, cause error) {
+	if cause != nil {
+		logging.Warn("offline_approval_refused", logging.Fields{Component: "approval", EventID: eventID, Error: cause.Error()})
+	}
+	if err := os.Rename(path, path+suffix); err != nil {
+		logging.Error("offline_approval_rename_failed", logging.Fields{Component: "approval", EventID: eventID, Error: err.Error()})
+	}
+}
+
+func readToken(path string) (*Token, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var t Token
+	if err := json.NewDecoder(io.LimitReader(f, maxTokenBytes)).Decode(&t); err != nil {
+		return nil, fmt.Errorf("parsing token: %w", err)
+	}
+	return &t, nil
+}
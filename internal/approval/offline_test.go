@@ -0,0 +1,94 @@
+package approval
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/slyt3/Logryph/internal/crypto"
+)
+
+func TestWatcher_AppliesSignedTokens(t *testing.T) {
+	dir := t.TempDir()
+	alice, err := crypto.NewSigner(filepath.Join(t.TempDir(), "alice.key"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	mallory, err := crypto.NewSigner(filepath.Join(t.TempDir(), "mallory.key"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := NewRegistry(0)
+	w, err := NewWatcher(r, OfflineConfig{Dir: dir, Approvers: []TrustedApprover{{Name: "alice", PublicKey: alice.GetPublicKey()}}})
+	if err != nil {
+		t.Fatalf("NewWatcher: %v", err)
+	}
+
+	// A token that arrives before the call stalls waits for it.
+	token, err := NewToken("evt-1", DecisionApproved, "alice@laptop", time.Hour, alice)
+	if err != nil {
+		t.Fatalf("NewToken: %v", err)
+	}
+	path, err := WriteToken(dir, token)
+	if err != nil {
+		t.Fatalf("WriteToken: %v", err)
+	}
+	if err := w.Scan(); err != nil {
+		t.Fatalf("Scan: %v", err)
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Fatalf("token for a call that has not stalled should be kept: %v", err)
+	}
+
+	for _, id := range []string{"evt-1", "evt-2"} {
+		if err := r.Register(Request{EventID: id, Deadline: time.Now().Add(time.Minute)}); err != nil {
+			t.Fatalf("Register: %v", err)
+		}
+	}
+	stalled := r.pending["evt-1"]
+	forged, _ := NewToken("evt-2", DecisionApproved, "alice", time.Hour, mallory)
+	forgedPath, _ := WriteToken(dir, forged)
+	if err := w.Scan(); err != nil {
+		t.Fatalf("Scan: %v", err)
+	}
+	select {
+	case out := <-stalled.done:
+		if out.Decision != DecisionApproved || out.Approver != "offline:alice" {
+			t.Fatalf("unexpected outcome: %+v", out)
+		}
+	default:
+		t.Fatal("evt-1 was not resolved")
+	}
+	if _, err := os.Stat(path + appliedSuffix); err != nil {
+		t.Errorf("applied token should be renamed: %v", err)
+	}
+	if _, err := os.Stat(forgedPath + invalidSuffix); err != nil {
+		t.Errorf("token from an untrusted key should be set aside: %v", err)
+	}
+	if r.Len() != 1 {
+		t.Errorf("evt-2 should still be pending, %d pending", r.Len())
+	}
+}
+
+func TestOfflineConfig_RejectsExpiredTokens(t *testing.T) {
+	alice, err := crypto.NewSigner(filepath.Join(t.TempDir(), "alice.key"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := OfflineConfig{Dir: "x", Approvers: []TrustedApprover{{Name: "alice", PublicKey: alice.GetPublicKey()}}}
+	token, err := NewToken("evt-1", DecisionRejected, "alice", time.Minute, alice)
+	if err != nil {
+		t.Fatalf("NewToken: %v", err)
+	}
+	if name, err := cfg.verify(token, time.Now()); err != nil || name != "alice" {
+		t.Fatalf("verify = %q, %v", name, err)
+	}
+	if _, err := cfg.verify(token, time.Now().Add(2*time.Minute)); err != errExpired {
+		t.Errorf("verify after expiry = %v, want errExpired", err)
+	}
+	token.Decision = DecisionApproved
+	if _, err := cfg.verify(token, time.Now()); err == nil {
+		t.Error("an edited token must not verify")
+	}
+}
@@ -0,0 +1,277 @@
+package approval
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/slyt3/Logryph/internal/assert"
+	"github.com/slyt3/Logryph/internal/logging"
+)
+
+// TicketPlaceholder is replaced in PollConfig.URL by the stall's ticket ID.
+const TicketPlaceholder = "{ticket}"
+
+const (
+	defaultPollInterval = 10 * time.Second
+	minPollInterval     = time.Second
+	defaultPollTimeout  = 10 * time.Second
+	defaultPollRetries  = 2
+	maxPollRetries      = 10
+	pollRetryDelay      = 500 * time.Millisecond
+	maxPollTickets      = 256
+	maxPollBody         = 64 * 1024
+	maxPollTicks        = 1 << 31
+)
+
+// PollConfig is the approval_poll section of the policy file, for approval systems that
+// cannot call the admin API and can only be asked. An empty URL disables polling.
+type PollConfig struct {
+	// URL is fetched with GET for each pending stall, with {ticket} replaced by the
+	// stall's ticket ID, or by its event ID when no ticket was linked.
+	URL      string            `yaml:"url,omitempty"`
+	Headers  map[string]string `yaml:"headers,omitempty"`  // e.g. Authorization
+	Interval string            `yaml:"interval,omitempty"` // how often to poll; default 10s
+	Timeout  string            `yaml:"timeout,omitempty"`  // per request; default 10s
+	Retries  int               `yaml:"retries,omitempty"`  // per ticket per poll after a failure; default 2
+}
+
+// PollAnswer is the JSON body the polled endpoint returns for a ticket. Decision is
+// approved or rejected once decided, and empty or pending until then. A 404 means the
+// same as pending.
+type PollAnswer struct {
+	Decision string `json:"decision"`
+	Approver string `json:"approver,omitempty"`
+}
+
+// ValidatePollConfig checks the approval_poll section. A disabled section is always
+// valid.
+func ValidatePollConfig(c PollConfig) error {
+	if c.URL == "" {
+		return nil
+	}
+	u, err := url.Parse(strings.ReplaceAll(c.URL, TicketPlaceholder, "ticket"))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return errors.New("url must be an absolute http(s) URL")
+	}
+	if !strings.Contains(c.URL, TicketPlaceholder) {
+		return fmt.Errorf("url must contain %s", TicketPlaceholder)
+	}
+	if _, err := c.interval(); err != nil {
+		return err
+	}
+	if _, err := c.timeout(); err != nil {
+		return err
+	}
+	if c.Retries < 0 || c.Retries > maxPollRetries {
+		return fmt.Errorf("retries must be between 0 and %d", maxPollRetries)
+	}
+	return nil
+}
+
+func (c PollConfig) interval() (time.Duration, error) {
+	if c.Interval == "" {
+		return defaultPollInterval, nil
+	}
+	d, err := time.ParseDuration(c.Interval)
+	if err != nil || d < minPollInterval {
+		return 0, fmt.Errorf("invalid interval %q: must be a duration of at least %s", c.Interval, minPollInterval)
+	}
+	return d, nil
+}
+
+func (c PollConfig) timeout() (time.Duration, error) {
+	if c.Timeout == "" {
+		return defaultPollTimeout, nil
+	}
+	d, err := time.ParseDuration(c.Timeout)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("invalid timeout %q: must be a positive duration", c.Timeout)
+	}
+	return d, nil
+}
+
+func (c PollConfig) retries() int {
+	if c.Retries == 0 {
+		return defaultPollRetries
+	}
+	return c.Retries
+}
+
+// errRetryable marks a poll failure worth retrying: a transport error, a 5xx or a 429.
+var errRetryable = errors.New("retryable")
+
+// Poller asks an external approval system for decisions on pending stalls and applies
+// them, recorded with approver "poll:<approver>". Failed requests are retried with
+// backoff; a ticket still failing is asked again on the next poll.
+type Poller struct {
+	reg      *Registry
+	cfg      PollConfig
+	client   *http.Client
+	interval time.Duration
+
+	ctx      context.Context
+	cancel   context.CancelFunc
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+// NewPoller polls c.URL for decisions on the stalls in reg.
+func NewPoller(reg *Registry, c PollConfig) (*Poller, error) {
+	if err := assert.NotNil(reg, "registry"); err != nil {
+		return nil, err
+	}
+	if err := ValidatePollConfig(c); err != nil {
+		return nil, err
+	}
+	if c.URL == "" {
+		return nil, errors.New("url is required")
+	}
+	interval, err := c.interval()
+	if err != nil {
+		return nil, err
+	}
+	timeout, err := c.timeout()
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Poller{
+		reg: reg, cfg: c, client: &http.Client{Timeout: timeout}, interval: interval,
+		ctx: ctx, cancel: cancel, done: make(chan struct{}),
+	}, nil
+}
+
+// Start polls every interval until Stop.
+func (p *Poller) Start() {
+	go p.run()
+}
+
+func (p *Poller) run() {
+	defer close(p.done)
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+	for i := 0; i < maxPollTicks; i++ {
+		select {
+		case <-p.ctx.Done():
+			return
+		case <-ticker.C:
+			p.Poll()
+		}
+	}
+}
+
+// Stop ends polling, abandoning any request in flight.
+func (p *Poller) Stop() {
+	p.stopOnce.Do(p.cancel)
+	<-p.done
+}
+
+// Poll asks once about every pending stall and returns how many it resolved.
+func (p *Poller) Poll() int {
+	pending := p.reg.Pending()
+	resolved := 0
+	for i := 0; i < len(pending) && i < maxPollTickets && p.ctx.Err() == nil; i++ {
+		if p.pollOne(pending[i]) {
+			resolved++
+		}
+	}
+	return resolved
+}
+
+// pollOne asks about one stall, retrying failures, and applies a decision if there is
+// one.
+func (p *Poller) pollOne(req Request) bool {
+	ticket := req.TicketID
+	if ticket == "" {
+		ticket = req.EventID
+	}
+	var answer *PollAnswer
+	var err error
+	delay := pollRetryDelay
+	for attempt := 0; attempt <= p.cfg.retries(); attempt++ {
+		if attempt > 0 {
+			select {
+			case <-p.ctx.Done():
+				return false
+			case <-time.After(delay):
+			}
+			delay *= 2
+		}
+		answer, err = p.fetch(ticket)
+		if !errors.Is(err, errRetryable) {
+			break
+		}
+	}
+	if err != nil {
+		logging.Warn("approval_poll_failed", logging.Fields{Component: "approval", EventID: req.EventID, Error: err.Error()})
+		return false
+	}
+	if answer == nil {
+		return false
+	}
+	decision := Decision(answer.Decision)
+	if decision != DecisionApproved && decision != DecisionRejected {
+		if answer.Decision != "" && answer.Decision != "pending" {
+			logging.Warn("approval_poll_refused", logging.Fields{Component: "approval", EventID: req.EventID, Error: fmt.Sprintf("unknown decision %q", answer.Decision)})
+		}
+		return false
+	}
+	approver := "poll"
+	if answer.Approver != "" {
+		approver += ":" + answer.Approver
+	}
+	if len(approver) > maxApproverLen {
+		approver = approver[:maxApproverLen]
+	}
+	if err := p.reg.Resolve(req.EventID, decision, approver); err != nil {
+		if !errors.Is(err, ErrNotPending) {
+			logging.Warn("approval_poll_refused", logging.Fields{Component: "approval", EventID: req.EventID, Error: err.Error()})
+		}
+		return false
+	}
+	logging.Info("approval_poll_applied", logging.Fields{Component: "approval", EventID: req.EventID})
+	return true
+}
+
+// fetch asks the endpoint about one ticket. It returns nil without an error while the
+// ticket is undecided.
+func (p *Poller) fetch(ticket string) (*PollAnswer, error) {
+	target := strings.ReplaceAll(p.cfg.URL, TicketPlaceholder, url.PathEscape(ticket))
+	req, err := http.NewRequestWithContext(p.ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	for k, v := range p.cfg.Headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		if p.ctx.Err() != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("%w: %v", errRetryable, err)
+	}
+	defer resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return nil, nil
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		return nil, fmt.Errorf("%w: ticket %s: status %d", errRetryable, ticket, resp.StatusCode)
+	case resp.StatusCode >= 300:
+		return nil, fmt.Errorf("ticket %s: status %d", ticket, resp.StatusCode)
+	}
+	var answer PollAnswer
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxPollBody)).Decode(&answer); err != nil {
+		return nil, fmt.Errorf("ticket %s: parsing answer: %w", ticket, err)
+	}
+	return &answer, nil
+}
@@ -0,0 +1,79 @@
+package approval
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestPoller_AppliesDecisionsByTicket(t *testing.T) {
+	var failures atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer s3cret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/tickets/CHG-7":
+			// The first attempt fails; the retry gets the decision.
+			if failures.Add(1) == 1 {
+				w.WriteHeader(http.StatusBadGateway)
+				return
+			}
+			_, _ = w.Write([]byte(`{"decision":"approved","approver":"alice"}`))
+		case "/tickets/evt-2":
+			_, _ = w.Write([]byte(`{"decision":"rejected"}`))
+		case "/tickets/evt-3":
+			_, _ = w.Write([]byte(`{"decision":"pending"}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	r := NewRegistry(0)
+	for _, id := range []string{"evt-1", "evt-2", "evt-3", "evt-4"} {
+		if err := r.Register(Request{EventID: id, Deadline: time.Now().Add(time.Minute)}); err != nil {
+			t.Fatalf("Register: %v", err)
+		}
+	}
+	if err := r.SetTicket("evt-1", "CHG-7"); err != nil {
+		t.Fatalf("SetTicket: %v", err)
+	}
+	first, second := r.pending["evt-1"], r.pending["evt-2"]
+
+	p, err := NewPoller(r, PollConfig{URL: srv.URL + "/tickets/{ticket}", Headers: map[string]string{"Authorization": "Bearer s3cret"}, Retries: 1})
+	if err != nil {
+		t.Fatalf("NewPoller: %v", err)
+	}
+	if n := p.Poll(); n != 2 {
+		t.Fatalf("Poll resolved %d stalls, want 2", n)
+	}
+	if out := <-first.done; out.Decision != DecisionApproved || out.Approver != "poll:alice" {
+		t.Errorf("evt-1 outcome = %+v", out)
+	}
+	if out := <-second.done; out.Decision != DecisionRejected || out.Approver != "poll" {
+		t.Errorf("evt-2 outcome = %+v", out)
+	}
+	if r.Len() != 2 {
+		t.Errorf("undecided stalls should stay pending, %d left", r.Len())
+	}
+}
+
+func TestValidatePollConfig(t *testing.T) {
+	for _, c := range []PollConfig{
+		{URL: "https://tickets.example/api/{ticket}", Interval: "500ms"},
+		{URL: "https://tickets.example/api/decisions"},
+		{URL: "ftp://tickets.example/{ticket}"},
+		{URL: "https://tickets.example/{ticket}", Retries: 11},
+	} {
+		if err := ValidatePollConfig(c); err == nil {
+			t.Errorf("config %+v was accepted", c)
+		}
+	}
+	if err := ValidatePollConfig(PollConfig{URL: "https://tickets.example/api/{ticket}", Interval: "30s"}); err != nil {
+		t.Errorf("valid config: %v", err)
+	}
+}
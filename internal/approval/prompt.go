@@ -0,0 +1,171 @@
+package approval
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/slyt3/Logryph/internal/assert"
+)
+
+const (
+	promptApprover   = "console"
+	promptPoll       = 500 * time.Millisecond
+	maxPromptTicks   = 1 << 31
+	maxPromptLineLen = 1024
+)
+
+// IsTerminal reports whether f is attached to a terminal. The console prompt only runs
+// when stdin is one, so a proxy started by systemd or with stdin redirected never reads it.
+func IsTerminal(f *os.File) bool {
+	if f == nil {
+		return false
+	}
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+// Prompt lets an operator at the proxy's terminal decide stalled calls. It announces each
+// new stall and reads "approve <id>", "reject <id>" and "pending" commands from a single
+// reader shared by all stalls; decisions go through the registry like API decisions do.
+type Prompt struct {
+	reg *Registry
+	in  io.Reader
+	out io.Writer
+
+	announced map[string]bool
+	stop      chan struct{}
+	done      chan struct{}
+	stopOnce  sync.Once
+}
+
+// NewPrompt creates a prompt reading commands from in and writing to out.
+func NewPrompt(reg *Registry, in io.Reader, out io.Writer) (*Prompt, error) {
+	if err := assert.NotNil(reg, "registry"); err != nil {
+		return nil, err
+	}
+	if err := assert.NotNil(in, "prompt input"); err != nil {
+		return nil, err
+	}
+	if err := assert.NotNil(out, "prompt output"); err != nil {
+		return nil, err
+	}
+	return &Prompt{
+		reg: reg, in: in, out: out, announced: make(map[string]bool),
+		stop: make(chan struct{}), done: make(chan struct{}),
+	}, nil
+}
+
+// Start announces stalls and handles commands until Stop or the end of input.
+func (p *Prompt) Start() {
+	lines := make(chan string)
+	go p.read(lines)
+	go p.run(lines)
+}
+
+// read forwards input lines. It blocks on the reader, so it outlives Stop when the input
+// never closes; there is only ever one per process.
+func (p *Prompt) read(lines chan<- string) {
+	defer close(lines)
+	scanner := bufio.NewScanner(p.in)
+	scanner.Buffer(make([]byte, 0, maxPromptLineLen), maxPromptLineLen)
+	for scanner.Scan() {
+		select {
+		case lines <- scanner.Text():
+		case <-p.stop:
+			return
+		}
+	}
+}
+
+func (p *Prompt) run(lines <-chan string) {
+	defer close(p.done)
+	ticker := time.NewTicker(promptPoll)
+	defer ticker.Stop()
+	for i := 0; i < maxPromptTicks; i++ {
+		select {
+		case <-p.stop:
+			return
+		case line, ok := <-lines:
+			if !ok {
+				return
+			}
+			p.Handle(line)
+		case <-ticker.C:
+			p.announce()
+		}
+	}
+}
+
+// Stop ends the prompt.
+func (p *Prompt) Stop() {
+	p.stopOnce.Do(func() { close(p.stop) })
+	<-p.done
+}
+
+// announce prints stalls registered since the last check and forgets resolved ones.
+func (p *Prompt) announce() {
+	pending := p.reg.Pending()
+	sort.Slice(pending, func(i, j int) bool { return pending[i].CreatedAt.Before(pending[j].CreatedAt) })
+	current := make(map[string]bool, len(pending))
+	for i := 0; i < len(pending); i++ {
+		req := pending[i]
+		current[req.EventID] = true
+		if p.announced[req.EventID] {
+			continue
+		}
+		fmt.Fprintf(p.out, "[STALL] %s %s risk=%s policy=%s expires in %s; type 'approve %s' or 'reject %s'\n",
+			req.EventID, req.Method, req.RiskLevel, req.PolicyID,
+			time.Until(req.Deadline).Round(time.Second), req.EventID, req.EventID)
+	}
+	p.announced = current
+}
+
+// Handle runs one command line.
+func (p *Prompt) Handle(line string) {
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return
+	}
+	switch cmd := fields[0]; cmd {
+	case "approve", "reject":
+		if len(fields) != 2 {
+			fmt.Fprintf(p.out, "usage: %s <event-id>\n", cmd)
+			return
+		}
+		decision := DecisionApproved
+		if cmd == "reject" {
+			decision = DecisionRejected
+		}
+		if err := p.reg.Resolve(fields[1], decision, promptApprover); err != nil {
+			fmt.Fprintf(p.out, "[ERR] %v\n", err)
+			return
+		}
+		fmt.Fprintf(p.out, "[OK] %s %s\n", fields[1], decision)
+	case "pending":
+		p.list()
+	default:
+		fmt.Fprintln(p.out, "commands: pending, approve <event-id>, reject <event-id>")
+	}
+}
+
+func (p *Prompt) list() {
+	pending := p.reg.Pending()
+	if len(pending) == 0 {
+		fmt.Fprintln(p.out, "no calls awaiting approval")
+		return
+	}
+	sort.Slice(pending, func(i, j int) bool { return pending[i].CreatedAt.Before(pending[j].CreatedAt) })
+	for i := 0; i < len(pending); i++ {
+		fmt.Fprintf(p.out, "%s %s risk=%s expires in %s\n", pending[i].EventID, pending[i].Method,
+			pending[i].RiskLevel, time.Until(pending[i].Deadline).Round(time.Second))
+	}
+}
@@ -0,0 +1,56 @@
+package approval
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPrompt_HandleResolvesStalls(t *testing.T) {
+	r := NewRegistry(0)
+	for _, id := range []string{"evt-1", "evt-2"} {
+		if err := r.Register(Request{EventID: id, Method: "aws:delete", Deadline: time.Now().Add(time.Minute)}); err != nil {
+			t.Fatalf("Register failed: %v", err)
+		}
+	}
+	var out bytes.Buffer
+	p, err := NewPrompt(r, strings.NewReader(""), &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p.announce()
+	p.announce()
+	if n := strings.Count(out.String(), "[STALL]"); n != 2 {
+		t.Errorf("each stall should be announced once, got %d:\n%s", n, out.String())
+	}
+
+	first, second := r.pending["evt-1"].done, r.pending["evt-2"].done
+	p.Handle("approve evt-1")
+	p.Handle("reject evt-2")
+	p.Handle("approve evt-3")
+	if o := <-first; o.Decision != DecisionApproved || o.Approver != "console" {
+		t.Errorf("unexpected outcome: %+v", o)
+	}
+	if o := <-second; o.Decision != DecisionRejected {
+		t.Errorf("unexpected outcome: %+v", o)
+	}
+	if !strings.Contains(out.String(), "[OK] evt-2 rejected") || !strings.Contains(out.String(), "[ERR]") {
+		t.Errorf("unexpected prompt output:\n%s", out.String())
+	}
+}
+
+func TestPrompt_StopsAtEndOfInput(t *testing.T) {
+	var out bytes.Buffer
+	p, err := NewPrompt(NewRegistry(0), strings.NewReader("pending\n"), &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.Start()
+	<-p.done
+	p.Stop()
+	if !strings.Contains(out.String(), "no calls awaiting approval") {
+		t.Errorf("unexpected prompt output:\n%s", out.String())
+	}
+}
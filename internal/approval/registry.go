@@ -0,0 +1,287 @@
+package approval
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/slyt3/Logryph/internal/assert"
+)
+
+// Decision is the outcome of a stalled call.
+type Decision string
+
+const (
+	DecisionApproved Decision = "approved"
+	DecisionRejected Decision = "rejected"
+	DecisionExpired  Decision = "expired"
+)
+
+const (
+	maxPendingDefault = 1024
+	maxApproverLen    = 128
+	maxTicketIDLen    = 256
+)
+
+var (
+	ErrNotPending     = errors.New("no pending approval for event")
+	ErrAlreadyPending = errors.New("event is already pending approval")
+	ErrRegistryFull   = errors.New("pending approval limit reached")
+)
+
+// Request describes a call held by the interceptor until an operator decides on it.
+type Request struct {
+	EventID   string    `json:"event_id"`
+	Method    string    `json:"method"`
+	TaskID    string    `json:"task_id,omitempty"`
+	PolicyID  string    `json:"policy_id,omitempty"`
+	RiskLevel string    `json:"risk_level,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	Deadline  time.Time `json:"deadline"`
+	// TicketID is the stall's ticket in an external approval system, when one was linked.
+	TicketID string `json:"ticket_id,omitempty"`
+}
+
+// Outcome is delivered to the waiting interceptor once a decision is made.
+type Outcome struct {
+	Decision Decision
+	Approver string
+	// Group identifies a decision taken on every stall of a task at once, and GroupSize
+	// is how many stalls it released. Both are zero for a decision on one stall.
+	Group     string
+	GroupSize int
+}
+
+type pendingEntry struct {
+	req  Request
+	done chan Outcome
+}
+
+// Registry tracks stalled calls awaiting approval. Safe for concurrent use.
+// Each pending entry is resolved exactly once: by Resolve, or by expiry in Wait.
+type Registry struct {
+	mu         sync.Mutex
+	pending    map[string]*pendingEntry
+	maxPending int
+}
+
+// NewRegistry creates an empty registry holding at most maxPending stalls.
+// A non-positive maxPending selects the default limit.
+func NewRegistry(maxPending int) *Registry {
+	if maxPending <= 0 {
+		maxPending = maxPendingDefault
+	}
+	return &Registry{
+		pending:    make(map[string]*pendingEntry),
+		maxPending: maxPending,
+	}
+}
+
+// Register records a new stalled call. Returns ErrRegistryFull when the limit is reached
+// so the caller can fail closed instead of queueing unbounded goroutines.
+func (r *Registry) Register(req Request) error {
+	if err := assert.NotNil(r, "registry"); err != nil {
+		return err
+	}
+	if err := assert.Check(req.EventID != "", "event id must not be empty"); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, exists := r.pending[req.EventID]; exists {
+		return ErrAlreadyPending
+	}
+	if len(r.pending) >= r.maxPending {
+		return ErrRegistryFull
+	}
+	r.pending[req.EventID] = &pendingEntry{req: req, done: make(chan Outcome, 1)}
+	return nil
+}
+
+// Wait blocks until the stall identified by eventID is resolved or its deadline passes.
+// The entry is removed from the registry before Wait returns.
+func (r *Registry) Wait(eventID string) (Outcome, error) {
+	return r.WaitContext(context.Background(), eventID)
+}
+
+// WaitContext is Wait bounded by ctx. When ctx is done first (the agent disconnected or
+// the call timed out) the entry is dropped and ctx.Err() is returned.
+func (r *Registry) WaitContext(ctx context.Context, eventID string) (Outcome, error) {
+	if err := assert.NotNil(r, "registry"); err != nil {
+		return Outcome{}, err
+	}
+	if err := assert.NotNil(ctx, "context"); err != nil {
+		return Outcome{}, err
+	}
+	r.mu.Lock()
+	entry, ok := r.pending[eventID]
+	r.mu.Unlock()
+	if !ok {
+		return Outcome{}, ErrNotPending
+	}
+
+	timer := time.NewTimer(time.Until(entry.req.Deadline))
+	defer timer.Stop()
+
+	select {
+	case out := <-entry.done:
+		return out, nil
+	case <-timer.C:
+		if out, ok := r.abandon(eventID, entry); ok {
+			return out, nil
+		}
+		return Outcome{Decision: DecisionExpired, Approver: "system"}, nil
+	case <-ctx.Done():
+		if out, ok := r.abandon(eventID, entry); ok {
+			return out, nil
+		}
+		return Outcome{}, ctx.Err()
+	}
+}
+
+// abandon removes a pending entry whose waiter is giving up. A decision may have raced
+// the waiter; if so it is returned and preferred over the waiter's reason for leaving.
+func (r *Registry) abandon(eventID string, entry *pendingEntry) (Outcome, bool) {
+	r.mu.Lock()
+	delete(r.pending, eventID)
+	r.mu.Unlock()
+	select {
+	case out := <-entry.done:
+		return out, true
+	default:
+		return Outcome{}, false
+	}
+}
+
+// Resolve delivers an operator decision to a waiting stall.
+// Returns ErrNotPending if the event is unknown or was already resolved.
+func (r *Registry) Resolve(eventID string, decision Decision, approver string) error {
+	if err := assert.NotNil(r, "registry"); err != nil {
+		return err
+	}
+	if err := assert.Check(decision == DecisionApproved || decision == DecisionRejected, "invalid decision: %s", decision); err != nil {
+		return err
+	}
+	if err := assert.Check(len(approver) <= maxApproverLen, "approver too long: %d", len(approver)); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	entry, ok := r.pending[eventID]
+	if ok {
+		delete(r.pending, eventID)
+	}
+	r.mu.Unlock()
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrNotPending, eventID)
+	}
+
+	entry.done <- Outcome{Decision: decision, Approver: approver}
+	return nil
+}
+
+// ResolveTask delivers one decision, identified by group, to every stall of a task. The
+// stalls are taken from the registry together, so a call of the task that stalls while
+// the decision is being delivered waits for a decision of its own. Returns the stalls
+// decided, oldest first, or ErrNotPending if the task has none.
+func (r *Registry) ResolveTask(taskID, group string, decision Decision, approver string) ([]Request, error) {
+	if err := assert.NotNil(r, "registry"); err != nil {
+		return nil, err
+	}
+	if err := assert.Check(taskID != "" && group != "", "task id and group must not be empty"); err != nil {
+		return nil, err
+	}
+	if err := assert.Check(decision == DecisionApproved || decision == DecisionRejected, "invalid decision: %s", decision); err != nil {
+		return nil, err
+	}
+	if err := assert.Check(len(approver) <= maxApproverLen, "approver too long: %d", len(approver)); err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	var entries []*pendingEntry
+	for id, entry := range r.pending {
+		if entry.req.TaskID == taskID {
+			entries = append(entries, entry)
+			delete(r.pending, id)
+		}
+	}
+	r.mu.Unlock()
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("%w: task %s", ErrNotPending, taskID)
+	}
+
+	sort.Slice(entries, func(i, j int) bool { return entries[i].req.CreatedAt.Before(entries[j].req.CreatedAt) })
+	decided := make([]Request, 0, len(entries))
+	for _, entry := range entries {
+		entry.done <- Outcome{Decision: decision, Approver: approver, Group: group, GroupSize: len(entries)}
+		decided = append(decided, entry.req)
+	}
+	return decided, nil
+}
+
+// SetTicket links a pending stall to its ticket in an external approval system, so a
+// poller can look the decision up by ticket. Returns ErrNotPending if the event is not
+// awaiting a decision.
+func (r *Registry) SetTicket(eventID, ticketID string) error {
+	if err := assert.NotNil(r, "registry"); err != nil {
+		return err
+	}
+	if err := assert.Check(ticketID != "" && len(ticketID) <= maxTicketIDLen, "ticket id must be 1-%d bytes", maxTicketIDLen); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	entry, ok := r.pending[eventID]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrNotPending, eventID)
+	}
+	entry.req.TicketID = ticketID
+	return nil
+}
+
+// Cancel drops a pending entry without a decision (e.g. the submitting request failed).
+func (r *Registry) Cancel(eventID string) {
+	if err := assert.NotNil(r, "registry"); err != nil {
+		return
+	}
+	r.mu.Lock()
+	delete(r.pending, eventID)
+	r.mu.Unlock()
+}
+
+// Pending returns a snapshot of the calls awaiting a decision, oldest first.
+func (r *Registry) Pending() []Request {
+	if err := assert.NotNil(r, "registry"); err != nil {
+		return nil
+	}
+	r.mu.Lock()
+	out := make([]Request, 0, len(r.pending))
+	for _, entry := range r.pending {
+		out = append(out, entry.req)
+	}
+	r.mu.Unlock()
+	sort.Slice(out, func(i, j int) bool { return out[i].CreatedAt.Before(out[j].CreatedAt) })
+	return out
+}
+
+// NearExpiry reports whether less than a quarter of the stall's window is left at now,
+// so whoever is deciding can be warned before the call is refused by timeout.
+func (req Request) NearExpiry(now time.Time) bool {
+	window := req.Deadline.Sub(req.CreatedAt)
+	return window > 0 && req.Deadline.Sub(now) < window/4
+}
+
+// Len returns the number of calls currently awaiting a decision.
+func (r *Registry) Len() int {
+	if err := assert.NotNil(r, "registry"); err != nil {
+		return 0
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.pending)
+}
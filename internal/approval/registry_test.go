@@ -0,0 +1,123 @@
+package approval
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRegistry_ResolveDeliversDecision(t *testing.T) {
+	r := NewRegistry(0)
+	req := Request{EventID: "evt-1", Method: "aws:delete", Deadline: time.Now().Add(time.Minute)}
+	if err := r.Register(req); err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+	if err := r.Register(req); !errors.Is(err, ErrAlreadyPending) {
+		t.Fatalf("expected ErrAlreadyPending, got %v", err)
+	}
+
+	go func() {
+		if err := r.Resolve("evt-1", DecisionApproved, "alice"); err != nil {
+			t.Errorf("Resolve failed: %v", err)
+		}
+	}()
+
+	out, err := r.Wait("evt-1")
+	if err != nil {
+		t.Fatalf("Wait failed: %v", err)
+	}
+	if out.Decision != DecisionApproved || out.Approver != "alice" {
+		t.Errorf("unexpected outcome: %+v", out)
+	}
+	if r.Len() != 0 {
+		t.Errorf("expected empty registry, got %d", r.Len())
+	}
+	if err := r.Resolve("evt-1", DecisionRejected, "bob"); !errors.Is(err, ErrNotPending) {
+		t.Errorf("expected ErrNotPending on second resolve, got %v", err)
+	}
+}
+
+func TestRegistry_WaitExpires(t *testing.T) {
+	r := NewRegistry(0)
+	if err := r.Register(Request{EventID: "evt-2", Deadline: time.Now().Add(20 * time.Millisecond)}); err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+	out, err := r.Wait("evt-2")
+	if err != nil {
+		t.Fatalf("Wait failed: %v", err)
+	}
+	if out.Decision != DecisionExpired {
+		t.Errorf("expected expired, got %s", out.Decision)
+	}
+	if r.Len() != 0 {
+		t.Errorf("expired entry should be removed, got %d", r.Len())
+	}
+}
+
+func TestRegistry_WaitContextCanceled(t *testing.T) {
+	r := NewRegistry(0)
+	if err := r.Register(Request{EventID: "evt-3", Deadline: time.Now().Add(time.Minute)}); err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := r.WaitContext(ctx, "evt-3"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected context error, got %v", err)
+	}
+	if r.Len() != 0 {
+		t.Errorf("abandoned entry should be removed, got %d", r.Len())
+	}
+	if err := r.Resolve("evt-3", DecisionApproved, "alice"); !errors.Is(err, ErrNotPending) {
+		t.Errorf("expected ErrNotPending after abandon, got %v", err)
+	}
+}
+
+func TestRegistry_Full(t *testing.T) {
+	r := NewRegistry(1)
+	deadline := time.Now().Add(time.Minute)
+	if err := r.Register(Request{EventID: "a", Deadline: deadline}); err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+	if err := r.Register(Request{EventID: "b", Deadline: deadline}); !errors.Is(err, ErrRegistryFull) {
+		t.Fatalf("expected ErrRegistryFull, got %v", err)
+	}
+	if len(r.Pending()) != 1 {
+		t.Errorf("expected 1 pending request")
+	}
+}
+
+func TestRegistry_ResolveTaskDecidesEveryStallOfTheTask(t *testing.T) {
+	r := NewRegistry(0)
+	now := time.Now()
+	for i, id := range []string{"evt-b", "evt-a", "evt-other"} {
+		task := "t1"
+		if id == "evt-other" {
+			task = "t2"
+		}
+		req := Request{EventID: id, TaskID: task, CreatedAt: now.Add(-time.Duration(i) * time.Second), Deadline: now.Add(time.Minute)}
+		if err := r.Register(req); err != nil {
+			t.Fatal(err)
+		}
+	}
+	first, second := r.pending["evt-a"], r.pending["evt-b"]
+
+	decided, err := r.ResolveTask("t1", "grp-1", DecisionRejected, "alice")
+	if err != nil {
+		t.Fatalf("ResolveTask: %v", err)
+	}
+	if len(decided) != 2 || decided[0].EventID != "evt-a" || decided[1].EventID != "evt-b" {
+		t.Fatalf("decided %+v, want evt-a then evt-b", decided)
+	}
+	for _, entry := range []*pendingEntry{first, second} {
+		if out := <-entry.done; out.Decision != DecisionRejected || out.Group != "grp-1" || out.GroupSize != 2 || out.Approver != "alice" {
+			t.Errorf("%s outcome = %+v", entry.req.EventID, out)
+		}
+	}
+	if pending := r.Pending(); len(pending) != 1 || pending[0].EventID != "evt-other" {
+		t.Errorf("another task's stall should stay pending, got %+v", pending)
+	}
+	if _, err := r.ResolveTask("t1", "grp-2", DecisionApproved, "alice"); !errors.Is(err, ErrNotPending) {
+		t.Errorf("a task with no stalls left: %v, want ErrNotPending", err)
+	}
+}
@@ -0,0 +1,111 @@
+// Package attachments keeps payload blobs referenced by ledger events. Blobs are
+// content-addressed by SHA-256, so the hash recorded in a signed event is enough to
+// find the blob and prove it has not changed.
+package attachments
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+
+	"github.com/slyt3/Logryph/internal/assert"
+)
+
+// MaxBlobBytes bounds a single stored blob.
+const MaxBlobBytes = 32 << 20
+
+var (
+	ErrNotFound    = errors.New("attachment not found")
+	ErrInvalidHash = errors.New("attachment hash must be 64 hex characters")
+	validSum       = regexp.MustCompile(`^[0-9a-f]{64}$`)
+)
+
+// Store writes blobs under dir/<first two hex chars>/<sha256>.
+type Store struct {
+	dir string
+}
+
+// NewStore returns a store rooted at dir. The directory is created on first Put.
+func NewStore(dir string) (*Store, error) {
+	if err := assert.Check(dir != "", "attachment dir must not be empty"); err != nil {
+		return nil, err
+	}
+	return &Store{dir: dir}, nil
+}
+
+// Sum returns the hex SHA-256 of data, the key a blob is stored under.
+func Sum(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// Put stores data and returns its SHA-256. Storing the same content twice is a no-op.
+func (s *Store) Put(data []byte) (string, error) {
+	if err := assert.NotNil(s, "attachment store"); err != nil {
+		return "", err
+	}
+	if len(data) > MaxBlobBytes {
+		return "", fmt.Errorf("attachment of %d bytes exceeds %d", len(data), MaxBlobBytes)
+	}
+	sum := Sum(data)
+	path := s.path(sum)
+	if _, err := os.Stat(path); err == nil {
+		return sum, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+		return "", fmt.Errorf("creating attachment dir: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return "", fmt.Errorf("creating attachment: %w", err)
+	}
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+		return "", fmt.Errorf("writing attachment: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		_ = os.Remove(tmp.Name())
+		return "", fmt.Errorf("closing attachment: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		_ = os.Remove(tmp.Name())
+		return "", fmt.Errorf("storing attachment: %w", err)
+	}
+	return sum, nil
+}
+
+// Open returns the blob stored under sum after checking its content still hashes to sum.
+func (s *Store) Open(sum string) ([]byte, error) {
+	if err := assert.NotNil(s, "attachment store"); err != nil {
+		return nil, err
+	}
+	if !validSum.MatchString(sum) {
+		return nil, ErrInvalidHash
+	}
+	f, err := os.Open(s.path(sum))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, sum)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("opening attachment: %w", err)
+	}
+	defer f.Close()
+	data, err := io.ReadAll(io.LimitReader(f, MaxBlobBytes+1))
+	if err != nil {
+		return nil, fmt.Errorf("reading attachment: %w", err)
+	}
+	if got := Sum(data); got != sum {
+		return nil, fmt.Errorf("attachment %s is corrupted: content hashes to %s", sum, got)
+	}
+	return data, nil
+}
+
+func (s *Store) path(sum string) string {
+	return filepath.Join(s.dir, sum[:2], sum)
+}
@@ -0,0 +1,38 @@
+package attachments
+
+import (
+	"errors"
+	"os"
+	"testing"
+)
+
+func TestStorePutOpen(t *testing.T) {
+	s, err := NewStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewStore: %v", err)
+	}
+	sum, err := s.Put([]byte("%PDF-1.7 report"))
+	if err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	if again, err := s.Put([]byte("%PDF-1.7 report")); err != nil || again != sum {
+		t.Fatalf("second Put should be a no-op: %s %v", again, err)
+	}
+	data, err := s.Open(sum)
+	if err != nil || string(data) != "%PDF-1.7 report" {
+		t.Fatalf("Open: %q %v", data, err)
+	}
+
+	if err := os.WriteFile(s.path(sum), []byte("tampered"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Open(sum); err == nil {
+		t.Fatal("expected a tampered blob to be rejected")
+	}
+	if _, err := s.Open(Sum([]byte("missing"))); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+	if _, err := s.Open("../../etc/passwd"); !errors.Is(err, ErrInvalidHash) {
+		t.Fatalf("expected ErrInvalidHash, got %v", err)
+	}
+}
@@ -0,0 +1,187 @@
+// Package attest produces and checks export attestations: a signed statement of an
+// export file's digest and the ledger state it was taken from, so a recipient can prove
+// the file was not modified after export.
+package attest
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/slyt3/Logryph/internal/assert"
+	"github.com/slyt3/Logryph/internal/crypto"
+)
+
+// FormatVersion identifies the attestation layout.
+const FormatVersion = "1"
+
+// LogryphVersion is the release recorded in attestations.
+const LogryphVersion = "2026.1"
+
+// Attestation is written next to an export as <export>.attestation.json. Signature is
+// the ledger key's Ed25519 signature over Digest of every other field.
+type Attestation struct {
+	Version        string    `json:"version"`
+	Export         string    `json:"export"` // base name of the export file
+	ExportSHA256   string    `json:"export_sha256"`
+	ExportSize     int64     `json:"export_size"`
+	RunID          string    `json:"run_id"`
+	ChainHead      string    `json:"chain_head"` // current_hash of the run's last event
+	ChainSeq       uint64    `json:"chain_seq"`
+	SignerPubKey   string    `json:"signer_pubkey"`
+	LogryphVersion string    `json:"logryph_version"`
+	Timestamp      time.Time `json:"timestamp"`
+	TSA            *TSAStamp `json:"tsa,omitempty"`
+	Signature      string    `json:"signature"`
+}
+
+// Digest returns the hex SHA-256 of the attestation with Signature cleared. The TSA stamp
+// is requested over the digest taken before it was attached, and the signature covers it.
+func (a *Attestation) Digest() (string, error) {
+	unsigned := *a
+	unsigned.Signature = ""
+	data, err := json.Marshal(unsigned)
+	if err != nil {
+		return "", fmt.Errorf("encoding attestation: %w", err)
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// Options adds optional parts to a new attestation.
+type Options struct {
+	TSAURL string // RFC 3161 time-stamp authority; empty skips the stamp
+}
+
+// New describes the export at path, stamps it if a TSA is configured and signs it.
+func New(path, runID, chainHead string, chainSeq uint64, signer *crypto.Signer, opts Options) (*Attestation, error) {
+	if err := assert.NotNil(signer, "signer"); err != nil {
+		return nil, err
+	}
+	sum, size, err := FileSHA256(path)
+	if err != nil {
+		return nil, err
+	}
+	a := &Attestation{
+		Version:        FormatVersion,
+		Export:         filepath.Base(path),
+		ExportSHA256:   sum,
+		ExportSize:     size,
+		RunID:          runID,
+		ChainHead:      chainHead,
+		ChainSeq:       chainSeq,
+		SignerPubKey:   signer.GetPublicKey(),
+		LogryphVersion: LogryphVersion,
+		Timestamp:      time.Now().UTC(),
+	}
+	if opts.TSAURL != "" {
+		digest, err := a.Digest()
+		if err != nil {
+			return nil, err
+		}
+		if a.TSA, err = RequestStamp(opts.TSAURL, digest); err != nil {
+			return nil, err
+		}
+	}
+	digest, err := a.Digest()
+	if err != nil {
+		return nil, err
+	}
+	if a.Signature, err = signer.SignHash(digest); err != nil {
+		return nil, fmt.Errorf("signing attestation: %w", err)
+	}
+	return a, nil
+}
+
+// Path returns where the attestation for an export is written by default.
+func Path(exportPath string) string {
+	return exportPath + ".attestation.json"
+}
+
+// Write stores a as indented JSON.
+func Write(path string, a *Attestation) error {
+	data, err := json.MarshalIndent(a, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encoding attestation: %w", err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0o644); err != nil {
+		return fmt.Errorf("writing attestation: %w", err)
+	}
+	return nil
+}
+
+// Read loads an attestation file.
+func Read(path string) (*Attestation, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading attestation: %w", err)
+	}
+	var a Attestation
+	if err := json.Unmarshal(data, &a); err != nil {
+		return nil, fmt.Errorf("parsing attestation: %w", err)
+	}
+	return &a, nil
+}
+
+// Verify checks that the export at path is the one attested and that the signature is
+// valid. With pinnedKey set, the signer must be that key; otherwise the embedded key is
+// trusted and the caller should compare it with a key obtained out of band.
+func Verify(path string, a *Attestation, pinnedKey string) error {
+	if err := assert.NotNil(a, "attestation"); err != nil {
+		return err
+	}
+	if a.Version != FormatVersion {
+		return fmt.Errorf("unsupported attestation version %q", a.Version)
+	}
+	if pinnedKey != "" && !strings.EqualFold(pinnedKey, a.SignerPubKey) {
+		return fmt.Errorf("signed by %s, expected %s", a.SignerPubKey, pinnedKey)
+	}
+	digest, err := a.Digest()
+	if err != nil {
+		return err
+	}
+	if !crypto.VerifyWithPublicKey(a.SignerPubKey, digest, a.Signature) {
+		return errors.New("signature does not match the attestation contents")
+	}
+	sum, size, err := FileSHA256(path)
+	if err != nil {
+		return err
+	}
+	if sum != a.ExportSHA256 || size != a.ExportSize {
+		return fmt.Errorf("export digest %s (%d bytes) does not match attested %s (%d bytes)", sum, size, a.ExportSHA256, a.ExportSize)
+	}
+	if a.TSA != nil {
+		unstamped := *a
+		unstamped.TSA = nil
+		digest, err := unstamped.Digest()
+		if err != nil {
+			return err
+		}
+		if err := a.TSA.Check(digest); err != nil {
+			return fmt.Errorf("tsa stamp: %w", err)
+		}
+	}
+	return nil
+}
+
+// FileSHA256 returns the hex SHA-256 and size of a file.
+func FileSHA256(path string) (string, int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", 0, fmt.Errorf("opening export: %w", err)
+	}
+	defer f.Close()
+	h := sha256.New()
+	n, err := io.Copy(h, f)
+	if err != nil {
+		return "", 0, fmt.Errorf("hashing export: %w", err)
+	}
+	return hex.EncodeToString(h.Sum(nil)), n, nil
+}
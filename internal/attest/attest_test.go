@@ -0,0 +1,193 @@
+package attest
+
+import (
+	"encoding/asn1"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/slyt3/Logryph/internal/crypto"
+	"github.com/slyt3/Logryph/internal/models"
+)
+
+func newExport(t *testing.T) (string, *crypto.Signer) {
+	t.Helper()
+	dir := t.TempDir()
+	path := filepath.Join(dir, "bag.zip")
+	if err := os.WriteFile(path, []byte("evidence bag contents"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	signer, err := crypto.NewSigner(filepath.Join(dir, "test.key"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return path, signer
+}
+
+func TestAttestationRoundTrip(t *testing.T) {
+	path, signer := newExport(t)
+	a, err := New(path, "run-1", "abc123", 42, signer, Options{})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	if err := Write(Path(path), a); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	loaded, err := Read(Path(path))
+	if err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+	if err := Verify(path, loaded, signer.GetPublicKey()); err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+
+	forged := *loaded
+	forged.ChainHead = "def456"
+	if Verify(path, &forged, "") == nil {
+		t.Error("edited attestation must not verify")
+	}
+	other, _ := crypto.NewSigner(filepath.Join(t.TempDir(), "other.key"))
+	if Verify(path, loaded, other.GetPublicKey()) == nil {
+		t.Error("attestation must not verify against a different pinned key")
+	}
+	if err := os.WriteFile(path, []byte("evidence bag contents, edited"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if Verify(path, loaded, "") == nil {
+		t.Error("modified export must not verify")
+	}
+}
+
+func TestAttestationWithTSAStamp(t *testing.T) {
+	genTime := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	tsa := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		var req timeStampReq
+		if _, err := asn1.Unmarshal(body, &req); err != nil {
+			t.Errorf("request: %v", err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/timestamp-reply")
+		_, _ = w.Write(fakeTimeStampResp(t, req, genTime))
+	}))
+	defer tsa.Close()
+
+	path, signer := newExport(t)
+	a, err := New(path, "run-1", "abc123", 42, signer, Options{TSAURL: tsa.URL})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	if a.TSA == nil || !a.TSA.GenTime.Equal(genTime) || a.TSA.Serial != "7" {
+		t.Fatalf("unexpected stamp: %+v", a.TSA)
+	}
+	if err := Verify(path, a, ""); err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+
+	// A stamp lifted from another attestation does not cover this one.
+	other, err := New(path, "run-2", "abc123", 42, signer, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	unstamped := *other
+	if err := a.TSA.Check(mustDigest(t, &unstamped)); err == nil {
+		t.Error("stamp must not cover a different attestation")
+	}
+}
+
+func mustDigest(t *testing.T, a *Attestation) string {
+	t.Helper()
+	d, err := a.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return d
+}
+
+// fakeTimeStampResp builds a granted TimeStampResp whose token carries an unsigned
+// TSTInfo for req. Only the fields Logryph checks are meaningful.
+func fakeTimeStampResp(t *testing.T, req timeStampReq, genTime time.Time) []byte {
+	t.Helper()
+	info, err := asn1.Marshal(struct {
+		Version        int
+		Policy         asn1.ObjectIdentifier
+		MessageImprint messageImprint
+		Serial         *big.Int
+		GenTime        time.Time `asn1:"generalized"`
+		Nonce          *big.Int
+	}{1, asn1.ObjectIdentifier{1, 2, 3}, req.MessageImprint, big.NewInt(7), genTime, req.Nonce})
+	if err != nil {
+		t.Fatal(err)
+	}
+	octets, _ := asn1.Marshal(info)
+	sd, err := asn1.Marshal(struct {
+		Version          int
+		DigestAlgorithms asn1.RawValue
+		EncapContentInfo struct {
+			EContentType asn1.ObjectIdentifier
+			EContent     asn1.RawValue
+		}
+	}{
+		Version:          3,
+		DigestAlgorithms: asn1.RawValue{Tag: asn1.TagSet, IsCompound: true},
+		EncapContentInfo: struct {
+			EContentType asn1.ObjectIdentifier
+			EContent     asn1.RawValue
+		}{oidTSTInfo, explicitTag0(t, octets)},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, err := asn1.Marshal(struct {
+		ContentType asn1.ObjectIdentifier
+		Content     asn1.RawValue
+	}{oidSignedData, explicitTag0(t, sd)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := asn1.Marshal(timeStampResp{Status: pkiStatusInfo{Status: 0}, TimeStampToken: asn1.RawValue{FullBytes: token}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp
+}
+
+// explicitTag0 wraps DER in an explicit [0] tag; RawValue FullBytes bypass struct tags.
+func explicitTag0(t *testing.T, der []byte) asn1.RawValue {
+	t.Helper()
+	wrapped, err := asn1.Marshal(asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: der})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return asn1.RawValue{FullBytes: wrapped}
+}
+
+func TestReceiptRoundTrip(t *testing.T) {
+	_, signer := newExport(t)
+	event := &models.Event{ID: "e1", RunID: "run-1", SeqIndex: 3, CurrentHash: "abc123"}
+	r, err := NewReceipt(event, 7, "head789", signer)
+	if err != nil {
+		t.Fatalf("NewReceipt: %v", err)
+	}
+	if err := VerifyReceipt(r, signer.GetPublicKey()); err != nil {
+		t.Fatalf("VerifyReceipt: %v", err)
+	}
+
+	forged := *r
+	forged.EventHash = "def456"
+	if VerifyReceipt(&forged, "") == nil {
+		t.Error("edited receipt must not verify")
+	}
+	other, _ := crypto.NewSigner(filepath.Join(t.TempDir(), "other.key"))
+	if VerifyReceipt(r, other.GetPublicKey()) == nil {
+		t.Error("receipt must not verify against a different pinned key")
+	}
+	if _, err := NewReceipt(event, 2, "old-head", signer); err == nil {
+		t.Error("a head before the event must be refused")
+	}
+}
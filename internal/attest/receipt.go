@@ -0,0 +1,103 @@
+package attest
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/slyt3/Logryph/internal/assert"
+	"github.com/slyt3/Logryph/internal/crypto"
+	"github.com/slyt3/Logryph/internal/models"
+)
+
+// ReceiptVersion identifies the receipt layout.
+const ReceiptVersion = "1"
+
+// Receipt is a signed statement that an event is in the ledger, for the caller or a
+// downstream system to keep as proof the interaction was recorded. ChainHead is the run's
+// head when the receipt was issued; a verified export of the run that contains the event
+// at SeqIndex with EventHash, and reaches ChainHead, shows the event was not removed.
+// Signature is the ledger key's Ed25519 signature over Digest of every other field.
+type Receipt struct {
+	Version      string    `json:"version"`
+	EventID      string    `json:"event_id"`
+	RunID        string    `json:"run_id"`
+	SeqIndex     uint64    `json:"seq_index"`
+	EventHash    string    `json:"event_hash"`
+	ChainHead    string    `json:"chain_head"`
+	ChainSeq     uint64    `json:"chain_seq"`
+	SignerPubKey string    `json:"signer_pubkey"`
+	IssuedAt     time.Time `json:"issued_at"`
+	Signature    string    `json:"signature"`
+}
+
+// Digest returns the hex SHA-256 of the receipt with Signature cleared.
+func (r *Receipt) Digest() (string, error) {
+	unsigned := *r
+	unsigned.Signature = ""
+	data, err := json.Marshal(unsigned)
+	if err != nil {
+		return "", fmt.Errorf("encoding receipt: %w", err)
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// NewReceipt signs a receipt for a ledgered event, given its run's current head.
+func NewReceipt(event *models.Event, chainSeq uint64, chainHead string, signer *crypto.Signer) (*Receipt, error) {
+	if err := assert.NotNil(event, "event"); err != nil {
+		return nil, err
+	}
+	if err := assert.NotNil(signer, "signer"); err != nil {
+		return nil, err
+	}
+	if chainSeq < event.SeqIndex {
+		return nil, fmt.Errorf("chain head seq %d is before event seq %d", chainSeq, event.SeqIndex)
+	}
+	r := &Receipt{
+		Version:      ReceiptVersion,
+		EventID:      event.ID,
+		RunID:        event.RunID,
+		SeqIndex:     event.SeqIndex,
+		EventHash:    event.CurrentHash,
+		ChainHead:    chainHead,
+		ChainSeq:     chainSeq,
+		SignerPubKey: signer.GetPublicKey(),
+		IssuedAt:     time.Now().UTC(),
+	}
+	digest, err := r.Digest()
+	if err != nil {
+		return nil, err
+	}
+	if r.Signature, err = signer.SignHash(digest); err != nil {
+		return nil, fmt.Errorf("signing receipt: %w", err)
+	}
+	return r, nil
+}
+
+// VerifyReceipt checks a receipt's signature. With pinnedKey set, the signer must be that
+// key; otherwise the embedded key is trusted and the caller should compare it with a key
+// obtained out of band.
+func VerifyReceipt(r *Receipt, pinnedKey string) error {
+	if err := assert.NotNil(r, "receipt"); err != nil {
+		return err
+	}
+	if r.Version != ReceiptVersion {
+		return fmt.Errorf("unsupported receipt version %q", r.Version)
+	}
+	if pinnedKey != "" && !strings.EqualFold(pinnedKey, r.SignerPubKey) {
+		return fmt.Errorf("signed by %s, expected %s", r.SignerPubKey, pinnedKey)
+	}
+	digest, err := r.Digest()
+	if err != nil {
+		return err
+	}
+	if !crypto.VerifyWithPublicKey(r.SignerPubKey, digest, r.Signature) {
+		return errors.New("signature does not match the receipt contents")
+	}
+	return nil
+}
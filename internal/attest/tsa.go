@@ -0,0 +1,230 @@
+package attest
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"time"
+)
+
+const (
+	tsaTimeout       = 30 * time.Second
+	maxTSAResponse   = 1 << 20
+	maxTSTInfoFields = 16
+)
+
+var (
+	oidSHA256     = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 1}
+	oidSignedData = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 2}
+	oidTSTInfo    = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 16, 1, 4}
+)
+
+// TSAStamp is an RFC 3161 time-stamp token over the attestation digest. Check confirms
+// the token covers the digest; the TSA's own CMS signature can be checked with
+// `openssl ts -verify` against the authority's certificate.
+type TSAStamp struct {
+	URL     string    `json:"url"`
+	GenTime time.Time `json:"gen_time"`
+	Serial  string    `json:"serial"`
+	Token   string    `json:"token"` // base64 DER TimeStampToken (CMS SignedData)
+}
+
+type algorithmIdentifier struct {
+	Algorithm  asn1.ObjectIdentifier
+	Parameters asn1.RawValue `asn1:"optional"`
+}
+
+type messageImprint struct {
+	HashAlgorithm algorithmIdentifier
+	HashedMessage []byte
+}
+
+type timeStampReq struct {
+	Version        int
+	MessageImprint messageImprint
+	Nonce          *big.Int `asn1:"optional"`
+	CertReq        bool     `asn1:"optional,default:false"`
+}
+
+type pkiStatusInfo struct {
+	Status int
+}
+
+type timeStampResp struct {
+	Status         pkiStatusInfo
+	TimeStampToken asn1.RawValue `asn1:"optional"`
+}
+
+type contentInfo struct {
+	ContentType asn1.ObjectIdentifier
+	Content     asn1.RawValue `asn1:"explicit,tag:0"`
+}
+
+type signedData struct {
+	Version          int
+	DigestAlgorithms asn1.RawValue
+	EncapContentInfo struct {
+		EContentType asn1.ObjectIdentifier
+		EContent     asn1.RawValue `asn1:"explicit,tag:0"`
+	}
+}
+
+// tstInfo holds the TSTInfo fields Logryph checks.
+type tstInfo struct {
+	imprint messageImprint
+	serial  *big.Int
+	genTime time.Time
+	nonce   *big.Int
+}
+
+// RequestStamp asks the TSA at url to stamp the hex SHA-256 digest.
+func RequestStamp(url, digestHex string) (*TSAStamp, error) {
+	digest, err := hex.DecodeString(digestHex)
+	if err != nil || len(digest) != 32 {
+		return nil, errors.New("tsa: digest must be a hex SHA-256")
+	}
+	nonce, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 64))
+	if err != nil {
+		return nil, fmt.Errorf("tsa: nonce: %w", err)
+	}
+	req, err := asn1.Marshal(timeStampReq{
+		Version:        1,
+		MessageImprint: messageImprint{HashAlgorithm: algorithmIdentifier{Algorithm: oidSHA256, Parameters: asn1.NullRawValue}, HashedMessage: digest},
+		Nonce:          nonce,
+		CertReq:        true,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("tsa: encoding request: %w", err)
+	}
+
+	client := &http.Client{Timeout: tsaTimeout}
+	resp, err := client.Post(url, "application/timestamp-query", bytes.NewReader(req))
+	if err != nil {
+		return nil, fmt.Errorf("tsa: %w", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxTSAResponse))
+	if err != nil {
+		return nil, fmt.Errorf("tsa: reading response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("tsa: status %d", resp.StatusCode)
+	}
+
+	var tsr timeStampResp
+	if _, err := asn1.Unmarshal(body, &tsr); err != nil {
+		return nil, fmt.Errorf("tsa: parsing response: %w", err)
+	}
+	if tsr.Status.Status > 1 || len(tsr.TimeStampToken.FullBytes) == 0 {
+		return nil, fmt.Errorf("tsa: request refused (status %d)", tsr.Status.Status)
+	}
+	info, err := parseToken(tsr.TimeStampToken.FullBytes)
+	if err != nil {
+		return nil, err
+	}
+	if info.nonce == nil || info.nonce.Cmp(nonce) != 0 {
+		return nil, errors.New("tsa: response nonce does not match the request")
+	}
+	stamp := &TSAStamp{
+		URL:     url,
+		GenTime: info.genTime.UTC(),
+		Serial:  info.serial.String(),
+		Token:   base64.StdEncoding.EncodeToString(tsr.TimeStampToken.FullBytes),
+	}
+	return stamp, stamp.Check(digestHex)
+}
+
+// Check confirms the token stamps digestHex and agrees with the recorded time and serial.
+func (s *TSAStamp) Check(digestHex string) error {
+	token, err := base64.StdEncoding.DecodeString(s.Token)
+	if err != nil {
+		return fmt.Errorf("decoding token: %w", err)
+	}
+	info, err := parseToken(token)
+	if err != nil {
+		return err
+	}
+	if !info.imprint.HashAlgorithm.Algorithm.Equal(oidSHA256) {
+		return errors.New("token is not over a SHA-256 digest")
+	}
+	if hex.EncodeToString(info.imprint.HashedMessage) != digestHex {
+		return errors.New("token does not cover this attestation")
+	}
+	if !info.genTime.Equal(s.GenTime) || info.serial.String() != s.Serial {
+		return errors.New("recorded time or serial differs from the token")
+	}
+	return nil
+}
+
+// parseToken extracts the TSTInfo from a DER TimeStampToken.
+func parseToken(der []byte) (*tstInfo, error) {
+	var ci contentInfo
+	if _, err := asn1.Unmarshal(der, &ci); err != nil {
+		return nil, fmt.Errorf("parsing token: %w", err)
+	}
+	if !ci.ContentType.Equal(oidSignedData) {
+		return nil, errors.New("token is not CMS signed data")
+	}
+	// An explicitly tagged RawValue keeps the [0] wrapper; Bytes is the inner element.
+	var sd signedData
+	if _, err := asn1.Unmarshal(ci.Content.Bytes, &sd); err != nil {
+		return nil, fmt.Errorf("parsing signed data: %w", err)
+	}
+	if !sd.EncapContentInfo.EContentType.Equal(oidTSTInfo) {
+		return nil, errors.New("token does not carry a TSTInfo")
+	}
+	var octets []byte
+	if _, err := asn1.Unmarshal(sd.EncapContentInfo.EContent.Bytes, &octets); err != nil {
+		return nil, fmt.Errorf("parsing TSTInfo: %w", err)
+	}
+	return parseTSTInfo(octets)
+}
+
+// parseTSTInfo reads version, policy, messageImprint, serialNumber and genTime, then
+// looks for the optional nonce among the remaining fields.
+func parseTSTInfo(der []byte) (*tstInfo, error) {
+	var seq asn1.RawValue
+	if _, err := asn1.Unmarshal(der, &seq); err != nil {
+		return nil, fmt.Errorf("parsing TSTInfo: %w", err)
+	}
+	var fields []asn1.RawValue
+	rest := seq.Bytes
+	for i := 0; i < maxTSTInfoFields && len(rest) > 0; i++ {
+		var f asn1.RawValue
+		var err error
+		if rest, err = asn1.Unmarshal(rest, &f); err != nil {
+			return nil, fmt.Errorf("parsing TSTInfo: %w", err)
+		}
+		fields = append(fields, f)
+	}
+	if len(fields) < 5 {
+		return nil, errors.New("TSTInfo is truncated")
+	}
+	info := &tstInfo{}
+	if _, err := asn1.Unmarshal(fields[2].FullBytes, &info.imprint); err != nil {
+		return nil, fmt.Errorf("parsing message imprint: %w", err)
+	}
+	if _, err := asn1.Unmarshal(fields[3].FullBytes, &info.serial); err != nil {
+		return nil, fmt.Errorf("parsing serial: %w", err)
+	}
+	if _, err := asn1.UnmarshalWithParams(fields[4].FullBytes, &info.genTime, "generalized"); err != nil {
+		return nil, fmt.Errorf("parsing gen time: %w", err)
+	}
+	for j := 5; j < len(fields); j++ {
+		if fields[j].Class == asn1.ClassUniversal && fields[j].Tag == asn1.TagInteger {
+			info.nonce = new(big.Int)
+			if _, err := asn1.Unmarshal(fields[j].FullBytes, &info.nonce); err != nil {
+				return nil, fmt.Errorf("parsing nonce: %w", err)
+			}
+			break
+		}
+	}
+	return info, nil
+}
@@ -0,0 +1,201 @@
+// Package backup takes point-in-time copies of the ledger database while the proxy keeps
+// recording, and prunes old copies. Each backup is a directory holding the database and a
+// manifest; signing keys are backed up separately (logyctl backup-key) and only referenced.
+package backup
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/slyt3/Logryph/internal/assert"
+)
+
+const (
+	// DirPrefix starts the name of every backup directory; the rest is the UTC time.
+	DirPrefix = "logryph-"
+	// KeyBackupPrefix starts the names of key backups written by logyctl backup-key.
+	KeyBackupPrefix = ".logryph_key.backup."
+	timeLayout      = "20060102T150405Z"
+	dbFile          = "logryph.db"
+	manifestFile    = "manifest.json"
+	manifestVersion = "1"
+	maxEntries      = 100000
+)
+
+// Source is the subset of the ledger a backup reads.
+type Source interface {
+	Snapshot(dest string) error
+	GetRunID() (string, error)
+	GetRunInfo(runID string) (agent, genesisHash, pubKey string, err error)
+	GetLastEvent(runID string) (seqIndex uint64, currentHash string, err error)
+}
+
+// Manifest describes one backup.
+type Manifest struct {
+	Version      string     `json:"version"`
+	CreatedAt    time.Time  `json:"created_at"`
+	Database     string     `json:"database"`
+	SHA256       string     `json:"sha256"`
+	Size         int64      `json:"size"`
+	RunID        string     `json:"run_id,omitempty"`
+	LastSeq      uint64     `json:"last_seq"`
+	LastHash     string     `json:"last_hash,omitempty"`
+	LedgerPubKey string     `json:"ledger_pub_key,omitempty"`
+	KeyBackup    *KeyBackup `json:"key_backup,omitempty"` // nil when no key backup exists
+}
+
+// KeyBackup references the newest key backup; the key itself is not copied.
+type KeyBackup struct {
+	File    string    `json:"file"`
+	SHA256  string    `json:"sha256"`
+	ModTime time.Time `json:"mod_time"`
+}
+
+// Create writes a backup directory under outDir and returns its path and manifest. Key
+// backups are looked up in keyDir.
+func Create(src Source, outDir, keyDir string) (string, *Manifest, error) {
+	if err := assert.NotNil(src, "backup source"); err != nil {
+		return "", nil, err
+	}
+	now := time.Now().UTC()
+	dir := filepath.Join(outDir, DirPrefix+now.Format(timeLayout))
+	if err := os.MkdirAll(outDir, 0o700); err != nil {
+		return "", nil, fmt.Errorf("creating backup directory: %w", err)
+	}
+	if err := os.Mkdir(dir, 0o700); err != nil {
+		return "", nil, fmt.Errorf("creating backup directory: %w", err)
+	}
+	m, err := write(src, dir, keyDir, now)
+	if err != nil {
+		_ = os.RemoveAll(dir)
+		return "", nil, err
+	}
+	return dir, m, nil
+}
+
+func write(src Source, dir, keyDir string, now time.Time) (*Manifest, error) {
+	dbPath := filepath.Join(dir, dbFile)
+	if err := src.Snapshot(dbPath); err != nil {
+		return nil, err
+	}
+	m := &Manifest{Version: manifestVersion, CreatedAt: now, Database: dbFile}
+	var err error
+	if m.SHA256, m.Size, err = hashFile(dbPath); err != nil {
+		return nil, err
+	}
+	// Run details come from the live ledger; the snapshot holds at least this much.
+	if m.RunID, err = src.GetRunID(); err != nil {
+		return nil, fmt.Errorf("getting run id: %w", err)
+	}
+	if m.RunID != "" {
+		if _, _, m.LedgerPubKey, err = src.GetRunInfo(m.RunID); err != nil {
+			return nil, fmt.Errorf("getting run info: %w", err)
+		}
+		if m.LastSeq, m.LastHash, err = src.GetLastEvent(m.RunID); err != nil {
+			return nil, fmt.Errorf("getting chain head: %w", err)
+		}
+	}
+	if m.KeyBackup, err = LatestKeyBackup(keyDir); err != nil {
+		return nil, err
+	}
+
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(filepath.Join(dir, manifestFile), append(data, '\n'), 0o600); err != nil {
+		return nil, fmt.Errorf("writing manifest: %w", err)
+	}
+	return m, nil
+}
+
+// LatestKeyBackup returns the newest key backup in dir, or nil if there is none.
+func LatestKeyBackup(dir string) (*KeyBackup, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("reading %s: %w", dir, err)
+	}
+	var latest string
+	// Names end in a sortable UTC timestamp.
+	for i := 0; i < len(entries) && i < maxEntries; i++ {
+		name := entries[i].Name()
+		if strings.HasPrefix(name, KeyBackupPrefix) && !entries[i].IsDir() && name > latest {
+			latest = name
+		}
+	}
+	if latest == "" {
+		return nil, nil
+	}
+	path := filepath.Join(dir, latest)
+	sum, _, err := hashFile(path)
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	return &KeyBackup{File: latest, SHA256: sum, ModTime: info.ModTime().UTC()}, nil
+}
+
+// Prune removes all but the newest keep backups in outDir and returns the removed paths.
+func Prune(outDir string, keep int) ([]string, error) {
+	if keep < 1 {
+		return nil, fmt.Errorf("keep must be at least 1, got %d", keep)
+	}
+	backups, err := List(outDir)
+	if err != nil {
+		return nil, err
+	}
+	var removed []string
+	for i := 0; i+keep < len(backups); i++ {
+		path := filepath.Join(outDir, backups[i])
+		if err := os.RemoveAll(path); err != nil {
+			return removed, fmt.Errorf("removing %s: %w", path, err)
+		}
+		removed = append(removed, path)
+	}
+	return removed, nil
+}
+
+// List returns the names of the backup directories in outDir, oldest first.
+func List(outDir string) ([]string, error) {
+	entries, err := os.ReadDir(outDir)
+	if err != nil {
+		return nil, fmt.Errorf("reading %s: %w", outDir, err)
+	}
+	var names []string
+	for i := 0; i < len(entries) && i < maxEntries; i++ {
+		name := entries[i].Name()
+		if !entries[i].IsDir() || !strings.HasPrefix(name, DirPrefix) {
+			continue
+		}
+		if _, err := time.Parse(timeLayout, strings.TrimPrefix(name, DirPrefix)); err == nil {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+func hashFile(path string) (string, int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", 0, err
+	}
+	defer f.Close()
+	h := sha256.New()
+	n, err := io.Copy(h, f)
+	if err != nil {
+		return "", 0, fmt.Errorf("hashing %s: %w", path, err)
+	}
+	return hex.EncodeToString(h.Sum(nil)), n, nil
+}
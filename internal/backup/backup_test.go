@@ -0,0 +1,130 @@
+package backup
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/slyt3/Logryph/internal/ledger"
+	"github.com/slyt3/Logryph/internal/ledger/audit"
+	"github.com/slyt3/Logryph/internal/ledger/store"
+	"github.com/slyt3/Logryph/internal/models"
+)
+
+// openLedger returns a ledger holding a genesis block and n tool calls.
+func openLedger(t *testing.T, dir string, n int) *store.DB {
+	t.Helper()
+	path := filepath.Join(dir, "logryph.db")
+	db, err := store.NewDB(path)
+	if err != nil {
+		t.Fatalf("store: %v", err)
+	}
+	worker, err := ledger.NewWorker(16, db, filepath.Join(dir, ".logryph_key"))
+	if err != nil {
+		t.Fatalf("worker: %v", err)
+	}
+	if err := worker.Start(); err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	for i := 0; i < n; i++ {
+		worker.Submit(&models.Event{
+			ID: fmt.Sprintf("call-%d", i), Timestamp: time.Now(), EventType: "tool_call",
+			Method: "tools/call", Params: map[string]interface{}{},
+		})
+	}
+	if err := worker.Shutdown(2 * time.Second); err != nil {
+		t.Fatalf("shutdown: %v", err)
+	}
+	// Shutdown closes the store; reopen it as the CLI would.
+	if db, err = store.NewDB(path); err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	return db
+}
+
+func TestCreate(t *testing.T) {
+	dir := t.TempDir()
+	db := openLedger(t, dir, 3)
+	keyBackup := KeyBackupPrefix + "20260101T000000Z"
+	if err := os.WriteFile(filepath.Join(dir, keyBackup), []byte("key"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	path, m, err := Create(db, filepath.Join(dir, "backups"), dir)
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if m.RunID == "" || m.LastSeq != 3 || m.LastHash == "" || m.LedgerPubKey == "" || m.Size == 0 {
+		t.Errorf("incomplete manifest: %+v", m)
+	}
+	if m.KeyBackup == nil || m.KeyBackup.File != keyBackup {
+		t.Errorf("key backup not referenced: %+v", m.KeyBackup)
+	}
+
+	data, err := os.ReadFile(filepath.Join(path, manifestFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var onDisk Manifest
+	if err := json.Unmarshal(data, &onDisk); err != nil || onDisk.SHA256 != m.SHA256 {
+		t.Errorf("manifest on disk does not match: %v %+v", err, onDisk)
+	}
+	copyDB, err := store.NewDB(filepath.Join(path, dbFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer copyDB.Close()
+	result, err := audit.VerifyChainWithKey(copyDB, m.RunID, m.LedgerPubKey)
+	if err != nil || !result.Valid || result.TotalEvents != 4 {
+		t.Errorf("backup does not verify: %v %+v", err, result)
+	}
+}
+
+func TestPrune(t *testing.T) {
+	dir := t.TempDir()
+	base := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 5; i++ {
+		name := DirPrefix + base.Add(time.Duration(i)*time.Hour).Format(timeLayout)
+		if err := os.Mkdir(filepath.Join(dir, name), 0o700); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Mkdir(filepath.Join(dir, DirPrefix+"notes"), 0o700); err != nil {
+		t.Fatal(err)
+	}
+
+	removed, err := Prune(dir, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	left, err := List(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(removed) != 3 || len(left) != 2 || left[1] != DirPrefix+"20260101T040000Z" {
+		t.Errorf("removed %v, left %v", removed, left)
+	}
+	if _, err := os.Stat(filepath.Join(dir, DirPrefix+"notes")); err != nil {
+		t.Error("directories that are not backups must be left alone")
+	}
+	if _, err := Prune(dir, 0); err == nil {
+		t.Error("keep 0 must be rejected")
+	}
+}
+
+func TestNewSchedulerRejects(t *testing.T) {
+	db := openLedger(t, t.TempDir(), 0)
+	for _, cfg := range []Config{
+		{Interval: time.Hour},
+		{Dir: "backups", Interval: time.Second},
+		{Dir: "backups", Interval: time.Hour, Keep: -1},
+	} {
+		if _, err := NewScheduler(db, cfg); err == nil {
+			t.Errorf("config should be rejected: %+v", cfg)
+		}
+	}
+}
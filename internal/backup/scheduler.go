@@ -0,0 +1,100 @@
+package backup
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/slyt3/Logryph/internal/assert"
+	"github.com/slyt3/Logryph/internal/logging"
+)
+
+const (
+	minInterval      = time.Minute
+	maxSchedulerLoop = 1 << 30
+)
+
+// Config describes periodic backups.
+type Config struct {
+	Dir      string        // directory the backups are written to
+	KeyDir   string        // directory searched for key backups; default "."
+	Interval time.Duration // time between backups; at least one minute
+	Keep     int           // newest backups kept after each run; 0 keeps all
+}
+
+// Scheduler backs up the ledger every Interval and prunes old backups.
+type Scheduler struct {
+	src Source
+	cfg Config
+
+	stop     chan struct{}
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+// NewScheduler validates cfg; call Start to begin backing up.
+func NewScheduler(src Source, cfg Config) (*Scheduler, error) {
+	if err := assert.NotNil(src, "backup source"); err != nil {
+		return nil, err
+	}
+	if cfg.Dir == "" {
+		return nil, errors.New("backup directory must not be empty")
+	}
+	if cfg.Interval < minInterval {
+		return nil, fmt.Errorf("backup interval must be at least %s, got %s", minInterval, cfg.Interval)
+	}
+	if cfg.Keep < 0 {
+		return nil, fmt.Errorf("backup keep must not be negative, got %d", cfg.Keep)
+	}
+	if cfg.KeyDir == "" {
+		cfg.KeyDir = "."
+	}
+	return &Scheduler{src: src, cfg: cfg, stop: make(chan struct{}), done: make(chan struct{})}, nil
+}
+
+// Start backs up in the background until Stop.
+func (s *Scheduler) Start() {
+	go s.run()
+}
+
+func (s *Scheduler) run() {
+	defer close(s.done)
+	ticker := time.NewTicker(s.cfg.Interval)
+	defer ticker.Stop()
+	for i := 0; i < maxSchedulerLoop; i++ {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+		}
+		if _, err := s.RunOnce(); err != nil {
+			logging.Error("backup_failed", logging.Fields{Component: "backup", Error: err.Error()})
+		}
+	}
+}
+
+// RunOnce takes one backup and applies retention, returning the new backup's path.
+func (s *Scheduler) RunOnce() (string, error) {
+	dir, m, err := Create(s.src, s.cfg.Dir, s.cfg.KeyDir)
+	if err != nil {
+		return "", err
+	}
+	logging.Info("backup_created", logging.Fields{Component: "backup", RunID: m.RunID})
+	if s.cfg.Keep > 0 {
+		removed, err := Prune(s.cfg.Dir, s.cfg.Keep)
+		if err != nil {
+			return dir, fmt.Errorf("pruning backups: %w", err)
+		}
+		if len(removed) > 0 {
+			logging.Info("backups_pruned", logging.Fields{Component: "backup", RunID: m.RunID})
+		}
+	}
+	return dir, nil
+}
+
+// Stop ends background backups; a backup in progress finishes first.
+func (s *Scheduler) Stop() {
+	s.stopOnce.Do(func() { close(s.stop) })
+	<-s.done
+}
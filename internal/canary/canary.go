@@ -0,0 +1,167 @@
+// Package canary keeps a tamper canary for the ledger: an append-only file, outside the
+// database, of signed chain heads. Anyone who rolls the database back or rewrites its tail
+// must also edit the canary, so keeping the file on separate storage (or mirroring it to a
+// remote endpoint) turns a silent rollback into a detectable one.
+package canary
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/slyt3/Logryph/internal/assert"
+	"github.com/slyt3/Logryph/internal/crypto"
+	"github.com/slyt3/Logryph/internal/models"
+)
+
+// EventTypeTamperAlarm records a failed startup check in the chain.
+const EventTypeTamperAlarm = "ledger_tamper_alarm"
+
+const maxTailBytes = 64 << 10 // last entry must fit; entries are a few hundred bytes
+
+// ErrTampered is wrapped by Check when the ledger no longer holds the last canary head.
+var ErrTampered = errors.New("ledger tamper alarm")
+
+// Source is the subset of the ledger the canary reads.
+type Source interface {
+	GetRunID() (string, error)
+	GetRunInfo(runID string) (agent, genesisHash, pubKey string, err error)
+	GetLastEvent(runID string) (seqIndex uint64, currentHash string, err error)
+	GetEventsFrom(runID string, fromSeq uint64, limit int) ([]models.Event, error)
+}
+
+// Entry is one line of the canary file.
+type Entry struct {
+	RunID     string    `json:"run_id"`
+	Seq       uint64    `json:"seq"`
+	Hash      string    `json:"hash"`
+	Timestamp time.Time `json:"timestamp"`
+	PubKey    string    `json:"pub_key"`
+	Signature string    `json:"signature"` // over Digest, by the ledger key
+}
+
+// Digest is the hex SHA-256 of the entry's signed fields.
+func (e *Entry) Digest() string {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s|%d|%s|%s", e.RunID, e.Seq, e.Hash, e.Timestamp.UTC().Format(time.RFC3339Nano))))
+	return hex.EncodeToString(sum[:])
+}
+
+// Head reads the current chain head and signs it.
+func Head(src Source, signer *crypto.Signer) (*Entry, error) {
+	if err := assert.NotNil(signer, "signer"); err != nil {
+		return nil, err
+	}
+	runID, err := src.GetRunID()
+	if err != nil {
+		return nil, fmt.Errorf("getting run id: %w", err)
+	}
+	if runID == "" {
+		return nil, nil
+	}
+	e := &Entry{RunID: runID, Timestamp: time.Now().UTC(), PubKey: signer.GetPublicKey()}
+	if e.Seq, e.Hash, err = src.GetLastEvent(runID); err != nil {
+		return nil, fmt.Errorf("getting chain head: %w", err)
+	}
+	if e.Signature, err = signer.SignHash(e.Digest()); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// Append writes e as a new line of the canary file at path, creating it if needed.
+func Append(path string, e *Entry) error {
+	line, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return fmt.Errorf("opening canary: %w", err)
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		_ = f.Close()
+		return fmt.Errorf("writing canary: %w", err)
+	}
+	if err := f.Sync(); err != nil {
+		_ = f.Close()
+		return fmt.Errorf("syncing canary: %w", err)
+	}
+	return f.Close()
+}
+
+// Last returns the last entry of the canary file at path, or nil if the file does not
+// exist or is empty.
+func Last(path string) (*Entry, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("opening canary: %w", err)
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	offset := info.Size() - maxTailBytes
+	if offset < 0 {
+		offset = 0
+	}
+	tail := make([]byte, info.Size()-offset)
+	if _, err := f.ReadAt(tail, offset); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("reading canary: %w", err)
+	}
+	tail = bytes.TrimRight(tail, "\n")
+	if len(tail) == 0 {
+		return nil, nil
+	}
+	if i := bytes.LastIndexByte(tail, '\n'); i >= 0 {
+		tail = tail[i+1:]
+	}
+	var e Entry
+	if err := json.Unmarshal(tail, &e); err != nil {
+		return nil, fmt.Errorf("parsing last canary entry: %w", err)
+	}
+	return &e, nil
+}
+
+// Check compares the ledger with the last canary entry. It returns an error wrapping
+// ErrTampered when the canary's run is gone, the run's chain is shorter than the canary
+// recorded, or the event at the canary's seq no longer has the recorded hash.
+func Check(path string, src Source) (*Entry, error) {
+	last, err := Last(path)
+	if err != nil || last == nil {
+		return nil, err
+	}
+	if !crypto.VerifyWithPublicKey(last.PubKey, last.Digest(), last.Signature) {
+		return last, fmt.Errorf("last canary entry has an invalid signature")
+	}
+	if _, _, _, err := src.GetRunInfo(last.RunID); errors.Is(err, sql.ErrNoRows) {
+		return last, fmt.Errorf("%w: run %s from the canary is missing from the ledger", ErrTampered, last.RunID)
+	} else if err != nil {
+		return last, fmt.Errorf("getting run info: %w", err)
+	}
+	seq, _, err := src.GetLastEvent(last.RunID)
+	if err != nil {
+		return last, fmt.Errorf("getting chain head: %w", err)
+	}
+	if seq < last.Seq {
+		return last, fmt.Errorf("%w: run %s regressed from seq %d to %d", ErrTampered, last.RunID, last.Seq, seq)
+	}
+	events, err := src.GetEventsFrom(last.RunID, last.Seq, 1)
+	if err != nil {
+		return last, fmt.Errorf("reading event %d: %w", last.Seq, err)
+	}
+	if len(events) == 0 || events[0].SeqIndex != last.Seq || events[0].CurrentHash != last.Hash {
+		return last, fmt.Errorf("%w: run %s event %d no longer has hash %s", ErrTampered, last.RunID, last.Seq, last.Hash)
+	}
+	return last, nil
+}
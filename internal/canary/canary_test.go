@@ -0,0 +1,163 @@
+package canary
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/slyt3/Logryph/internal/crypto"
+	"github.com/slyt3/Logryph/internal/ledger"
+	"github.com/slyt3/Logryph/internal/ledger/store"
+	"github.com/slyt3/Logryph/internal/models"
+)
+
+// record appends n tool calls to the ledger at dir/logryph.db and returns it reopened,
+// since worker shutdown closes the store.
+func record(t *testing.T, dir string, n int) *store.DB {
+	t.Helper()
+	path := filepath.Join(dir, "logryph.db")
+	db, err := store.NewDB(path)
+	if err != nil {
+		t.Fatalf("store: %v", err)
+	}
+	worker, err := ledger.NewWorker(16, db, filepath.Join(dir, ".logryph_key"))
+	if err != nil {
+		t.Fatalf("worker: %v", err)
+	}
+	if err := worker.Start(); err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	for i := 0; i < n; i++ {
+		worker.Submit(&models.Event{
+			ID: fmt.Sprintf("call-%d-%d", time.Now().UnixNano(), i), Timestamp: time.Now(),
+			EventType: "tool_call", Method: "tools/call", Params: map[string]interface{}{},
+		})
+	}
+	if err := worker.Shutdown(2 * time.Second); err != nil {
+		t.Fatalf("shutdown: %v", err)
+	}
+	if db, err = store.NewDB(path); err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	return db
+}
+
+func newMonitor(t *testing.T, dir string, db *store.DB) *Monitor {
+	t.Helper()
+	signer, err := crypto.NewSigner(filepath.Join(dir, ".logryph_key"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := NewMonitor(db, signer, Config{Path: filepath.Join(dir, "canary.jsonl")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func TestCheckAcceptsGrowth(t *testing.T) {
+	dir := t.TempDir()
+	db := record(t, dir, 2)
+	if err := newMonitor(t, dir, db).Write(); err != nil {
+		t.Fatal(err)
+	}
+	_ = db.Close()
+	db = record(t, dir, 3)
+
+	last, err := Check(filepath.Join(dir, "canary.jsonl"), db)
+	if err != nil {
+		t.Fatalf("a ledger that grew past the canary must pass: %v", err)
+	}
+	if last == nil || last.Seq != 2 {
+		t.Errorf("unexpected last entry: %+v", last)
+	}
+}
+
+func TestCheckDetectsRollback(t *testing.T) {
+	dir := t.TempDir()
+	db := record(t, dir, 2)
+	oldCopy := filepath.Join(t.TempDir(), "old.db")
+	if err := db.Snapshot(oldCopy); err != nil {
+		t.Fatal(err)
+	}
+	_ = db.Close()
+	db = record(t, dir, 3)
+	m := newMonitor(t, dir, db)
+	if err := m.Write(); err != nil {
+		t.Fatal(err)
+	}
+
+	old, err := store.NewDB(oldCopy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer old.Close()
+	_, err = Check(m.cfg.Path, old)
+	if !errors.Is(err, ErrTampered) || !strings.Contains(err.Error(), "regressed from seq 5 to 2") {
+		t.Errorf("rollback not detected: %v", err)
+	}
+}
+
+func TestCheckDetectsRewriteAndMissingRun(t *testing.T) {
+	dir := t.TempDir()
+	db := record(t, dir, 2)
+	signer, err := crypto.NewSigner(filepath.Join(dir, ".logryph_key"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	head, err := Head(db, signer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		name   string
+		modify func(e *Entry)
+	}{
+		{"rewrite", func(e *Entry) { e.Hash = strings.Repeat("0", 64) }},
+		{"missing", func(e *Entry) { e.RunID = "00000000-0000-0000-0000-000000000000" }},
+	} {
+		e := *head
+		tc.modify(&e)
+		if e.Signature, err = signer.SignHash(e.Digest()); err != nil {
+			t.Fatal(err)
+		}
+		path := filepath.Join(dir, tc.name+".jsonl")
+		if err := Append(path, &e); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := Check(path, db); !errors.Is(err, ErrTampered) {
+			t.Errorf("%s: expected a tamper alarm, got %v", tc.name, err)
+		}
+	}
+}
+
+func TestMonitorSkipsUnchangedHead(t *testing.T) {
+	dir := t.TempDir()
+	db := record(t, dir, 1)
+	m := newMonitor(t, dir, db)
+	for i := 0; i < 3; i++ {
+		if err := m.Write(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	data, err := os.ReadFile(m.cfg.Path)
+	if err != nil || strings.Count(string(data), "\n") != 1 {
+		t.Fatalf("an unchanged head must be written once: %v\n%s", err, data)
+	}
+	forged, err := Last(m.cfg.Path)
+	if err != nil || forged == nil {
+		t.Fatalf("last: %v", err)
+	}
+	forged.Seq++
+	if err := Append(m.cfg.Path, forged); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Check(m.cfg.Path, db); err == nil || errors.Is(err, ErrTampered) {
+		t.Errorf("an entry whose signature does not match must be reported as such: %v", err)
+	}
+}
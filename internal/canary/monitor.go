@@ -0,0 +1,142 @@
+package canary
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/slyt3/Logryph/internal/assert"
+	"github.com/slyt3/Logryph/internal/crypto"
+	"github.com/slyt3/Logryph/internal/logging"
+)
+
+const (
+	defaultInterval = 10 * time.Second
+	postTimeout     = 10 * time.Second
+	maxMonitorLoop  = 1 << 30
+)
+
+// Config describes where the canary is written.
+type Config struct {
+	Path     string        // append-only canary file
+	URL      string        // optional http(s) endpoint each new entry is POSTed to as JSON
+	Interval time.Duration // how often the head is checked; default 10s
+}
+
+// Monitor appends the chain head to the canary whenever it has moved.
+type Monitor struct {
+	src    Source
+	signer *crypto.Signer
+	cfg    Config
+	client *http.Client
+
+	mu   sync.Mutex // serialises Write and guards last
+	last Entry
+
+	stop     chan struct{}
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+// NewMonitor validates cfg; call Start to begin writing.
+func NewMonitor(src Source, signer *crypto.Signer, cfg Config) (*Monitor, error) {
+	if err := assert.NotNil(src, "canary source"); err != nil {
+		return nil, err
+	}
+	if err := assert.NotNil(signer, "signer"); err != nil {
+		return nil, err
+	}
+	if cfg.Path == "" {
+		return nil, errors.New("canary path must not be empty")
+	}
+	if cfg.URL != "" {
+		u, err := url.Parse(cfg.URL)
+		if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+			return nil, errors.New("canary url must be an absolute http(s) URL")
+		}
+	}
+	if cfg.Interval <= 0 {
+		cfg.Interval = defaultInterval
+	}
+	return &Monitor{
+		src: src, signer: signer, cfg: cfg,
+		client: &http.Client{Timeout: postTimeout},
+		stop:   make(chan struct{}), done: make(chan struct{}),
+	}, nil
+}
+
+// Start writes the head every interval until Stop.
+func (m *Monitor) Start() {
+	go m.run()
+}
+
+func (m *Monitor) run() {
+	defer close(m.done)
+	ticker := time.NewTicker(m.cfg.Interval)
+	defer ticker.Stop()
+	for i := 0; i < maxMonitorLoop; i++ {
+		select {
+		case <-m.stop:
+			return
+		case <-ticker.C:
+			if err := m.Write(); err != nil {
+				logging.Error("canary_write_failed", logging.Fields{Component: "canary", Error: err.Error()})
+			}
+		}
+	}
+}
+
+// Stop ends periodic writes and records the final head.
+func (m *Monitor) Stop() error {
+	m.stopOnce.Do(func() { close(m.stop) })
+	<-m.done
+	return m.Write()
+}
+
+// Write appends the current head to the canary file, and sends it to the remote endpoint,
+// unless it matches the last head written. A failed remote send is logged, not returned:
+// the local file is the record the startup check relies on.
+func (m *Monitor) Write() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	head, err := Head(m.src, m.signer)
+	if err != nil || head == nil {
+		return err
+	}
+	if head.RunID == m.last.RunID && head.Seq == m.last.Seq && head.Hash == m.last.Hash {
+		return nil
+	}
+	if err := Append(m.cfg.Path, head); err != nil {
+		return err
+	}
+	m.last = *head
+	if m.cfg.URL != "" {
+		if err := m.post(head); err != nil {
+			logging.Warn("canary_remote_failed", logging.Fields{Component: "canary", RunID: head.RunID, Error: err.Error()})
+		}
+	}
+	return nil
+}
+
+func (m *Monitor) post(e *Entry) error {
+	body, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	resp, err := m.client.Post(m.cfg.URL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<16))
+	_ = resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("canary endpoint returned %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,211 @@
+// Package capacity forecasts how fast the ledger grows and whether its disk will hold it.
+// Growth is measured from the daily event totals the store keeps, and converted to bytes
+// with the database's average size per event. The ledger never prunes itself, so the
+// projection simply extends the current rate over the horizon.
+package capacity
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/slyt3/Logryph/internal/assert"
+	"github.com/slyt3/Logryph/internal/ledger/store"
+)
+
+// Defaults for Options.
+const (
+	DefaultWindowDays  = 7
+	DefaultHorizonDays = 90
+	DefaultMinHeadroom = 20.0
+)
+
+const (
+	maxWindowDays  = 365
+	maxHorizonDays = 100 * 365
+	dayLayout      = "2006-01-02"
+)
+
+// ErrDiskUnsupported is returned by DiskUsage where free space cannot be read.
+var ErrDiskUnsupported = errors.New("disk usage is not supported on this platform")
+
+// Options controls a forecast. Zero values take the defaults.
+type Options struct {
+	WindowDays  int     // recent days the growth rate is averaged over
+	HorizonDays int     // how far ahead to project, normally the policy's retention_days
+	MinHeadroom float64 // percent of the disk that should stay free
+}
+
+func (o Options) withDefaults() Options {
+	if o.WindowDays <= 0 {
+		o.WindowDays = DefaultWindowDays
+	}
+	if o.HorizonDays <= 0 {
+		o.HorizonDays = DefaultHorizonDays
+	}
+	if o.MinHeadroom <= 0 {
+		o.MinHeadroom = DefaultMinHeadroom
+	}
+	return o
+}
+
+// Validate checks the options are in range.
+func (o Options) Validate() error {
+	if o.WindowDays < 0 || o.WindowDays > maxWindowDays {
+		return fmt.Errorf("window must be between 1 and %d days", maxWindowDays)
+	}
+	if o.HorizonDays < 0 || o.HorizonDays > maxHorizonDays {
+		return fmt.Errorf("horizon must be between 1 and %d days", maxHorizonDays)
+	}
+	if o.MinHeadroom < 0 || o.MinHeadroom >= 100 || math.IsNaN(o.MinHeadroom) {
+		return errors.New("minimum headroom must be a percentage below 100")
+	}
+	return nil
+}
+
+// Disk is the space on the filesystem holding the ledger. Known is false where it
+// could not be read.
+type Disk struct {
+	Free  uint64
+	Total uint64
+	Known bool
+}
+
+// Forecast is the ledger's size, growth and projected size, and what they mean for its
+// disk.
+type Forecast struct {
+	DBBytes        int64
+	Events         uint64
+	BytesPerEvent  float64
+	WindowDays     int // days the rate was averaged over; fewer than asked for a young ledger
+	EventsPerDay   float64
+	BytesPerDay    float64
+	HorizonDays    int
+	ProjectedBytes int64 // size after HorizonDays more days at the current rate
+	Disk           Disk
+	DaysUntilFull  float64 // +Inf when the ledger is not growing or the disk is unknown
+	Warnings       []string
+}
+
+// HeadroomPercent is the share of the disk free now.
+func (f *Forecast) HeadroomPercent() float64 {
+	if !f.Disk.Known || f.Disk.Total == 0 {
+		return 100
+	}
+	return float64(f.Disk.Free) / float64(f.Disk.Total) * 100
+}
+
+// Compute forecasts from the database size, the store's daily totals (oldest first, as
+// GetDailyStats returns them) and the disk, as of now.
+func Compute(dbBytes int64, days []store.DayStats, disk Disk, opts Options, now time.Time) *Forecast {
+	opts = opts.withDefaults()
+	f := &Forecast{DBBytes: dbBytes, HorizonDays: opts.HorizonDays, Disk: disk, DaysUntilFull: math.Inf(1)}
+	today := now.UTC().Truncate(24 * time.Hour)
+	var first time.Time
+	for i := 0; i < len(days); i++ {
+		day, err := time.Parse(dayLayout, days[i].Day)
+		if err != nil {
+			continue
+		}
+		f.Events += days[i].Events
+		if first.IsZero() || day.Before(first) {
+			first = day
+		}
+	}
+	if f.Events > 0 {
+		f.BytesPerEvent = float64(dbBytes) / float64(f.Events)
+		// A ledger younger than the window is averaged over the days it has existed.
+		f.WindowDays = min(opts.WindowDays, max(1, int(today.Sub(first)/(24*time.Hour))+1))
+		since := today.AddDate(0, 0, 1-f.WindowDays)
+		var recent uint64
+		for i := 0; i < len(days); i++ {
+			if day, err := time.Parse(dayLayout, days[i].Day); err == nil && !day.Before(since) {
+				recent += days[i].Events
+			}
+		}
+		f.EventsPerDay = float64(recent) / float64(f.WindowDays)
+		f.BytesPerDay = f.EventsPerDay * f.BytesPerEvent
+	}
+	f.ProjectedBytes = dbBytes + int64(f.BytesPerDay*float64(opts.HorizonDays))
+	if !disk.Known || disk.Total == 0 {
+		return f
+	}
+	if f.BytesPerDay > 0 {
+		f.DaysUntilFull = float64(disk.Free) / f.BytesPerDay
+	}
+	growth := f.BytesPerDay * float64(opts.HorizonDays)
+	headroom := f.HeadroomPercent()
+	after := (float64(disk.Free) - growth) / float64(disk.Total) * 100
+	switch {
+	case headroom < opts.MinHeadroom:
+		f.Warnings = append(f.Warnings, fmt.Sprintf("disk headroom is %.1f%%, below the %g%% minimum", headroom, opts.MinHeadroom))
+	case f.DaysUntilFull < float64(opts.HorizonDays):
+		f.Warnings = append(f.Warnings, fmt.Sprintf("at the current rate the ledger fills the disk in %.0f days, within the %d-day horizon", f.DaysUntilFull, opts.HorizonDays))
+	case after < opts.MinHeadroom:
+		f.Warnings = append(f.Warnings, fmt.Sprintf("at the current rate disk headroom falls to %.1f%% within %d days, below the %g%% minimum", after, opts.HorizonDays, opts.MinHeadroom))
+	}
+	return f
+}
+
+// DBSize is the size of the SQLite database at path, with its write-ahead log.
+func DBSize(path string) (int64, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0, err
+	}
+	size := info.Size()
+	if wal, err := os.Stat(path + "-wal"); err == nil {
+		size += wal.Size()
+	}
+	return size, nil
+}
+
+// Source is the store the monitor reads daily totals from.
+type Source interface {
+	GetDailyStats(days int) ([]store.DayStats, error)
+}
+
+// Monitor forecasts a live ledger on demand, for the capacity gauges.
+type Monitor struct {
+	path string
+	src  Source
+	opts Options
+}
+
+// NewMonitor forecasts the database at path, whose daily totals src reads.
+func NewMonitor(path string, src Source, opts Options) (*Monitor, error) {
+	if err := assert.NotNil(src, "capacity source"); err != nil {
+		return nil, err
+	}
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+	return &Monitor{path: path, src: src, opts: opts}, nil
+}
+
+// Forecast measures the database and its disk now.
+func (m *Monitor) Forecast() (*Forecast, error) {
+	return Measure(m.path, m.src, m.opts, time.Now())
+}
+
+// Measure reads the database size, the daily totals and the disk, and forecasts.
+func Measure(path string, src Source, opts Options, now time.Time) (*Forecast, error) {
+	size, err := DBSize(path)
+	if err != nil {
+		return nil, fmt.Errorf("measuring ledger: %w", err)
+	}
+	days, err := src.GetDailyStats(0)
+	if err != nil {
+		return nil, err
+	}
+	disk := Disk{}
+	if abs, err := filepath.Abs(path); err == nil {
+		if free, total, err := DiskUsage(filepath.Dir(abs)); err == nil {
+			disk = Disk{Free: free, Total: total, Known: true}
+		}
+	}
+	return Compute(size, days, disk, opts, now), nil
+}
@@ -0,0 +1,78 @@
+package capacity
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/slyt3/Logryph/internal/ledger/store"
+)
+
+var now = time.Date(2026, 3, 10, 15, 0, 0, 0, time.UTC)
+
+func TestComputeGrowthAndProjection(t *testing.T) {
+	days := []store.DayStats{
+		{Day: "2026-02-01", Events: 6000}, // outside the 7-day window
+		{Day: "2026-03-04", Events: 1000},
+		{Day: "2026-03-10", Events: 3000},
+	}
+	disk := Disk{Free: 600_000, Total: 1_000_000, Known: true}
+	f := Compute(1_000_000, days, disk, Options{WindowDays: 7, HorizonDays: 30}, now)
+	if f.Events != 10000 || f.BytesPerEvent != 100 || f.WindowDays != 7 {
+		t.Fatalf("forecast = %+v", f)
+	}
+	if math.Abs(f.EventsPerDay-4000.0/7) > 1e-9 || math.Abs(f.BytesPerDay-400000.0/7) > 1e-6 {
+		t.Errorf("rate = %v events/day, %v bytes/day", f.EventsPerDay, f.BytesPerDay)
+	}
+	if want := int64(1_000_000) + int64(f.BytesPerDay*30); f.ProjectedBytes != want {
+		t.Errorf("projected = %d, want %d", f.ProjectedBytes, want)
+	}
+	if math.Abs(f.DaysUntilFull-10.5) > 1e-9 {
+		t.Errorf("days until full = %v", f.DaysUntilFull)
+	}
+	if len(f.Warnings) != 1 || !strings.Contains(f.Warnings[0], "fills the disk in 10 days") {
+		t.Errorf("warnings = %q", f.Warnings)
+	}
+}
+
+func TestComputeHeadroomWarnings(t *testing.T) {
+	days := []store.DayStats{{Day: "2026-03-10", Events: 10}}
+	if f := Compute(1000, days, Disk{Free: 100, Total: 1000, Known: true}, Options{}, now); len(f.Warnings) != 1 || !strings.Contains(f.Warnings[0], "headroom is 10.0%") {
+		t.Errorf("low headroom warnings = %q", f.Warnings)
+	}
+	// A young ledger is averaged over the one day it has existed.
+	f := Compute(1000, days, Disk{Free: 900_000, Total: 1_000_000, Known: true}, Options{}, now)
+	if f.WindowDays != 1 || f.EventsPerDay != 10 || len(f.Warnings) != 0 {
+		t.Errorf("young ledger forecast = %+v", f)
+	}
+	if f := Compute(1000, nil, Disk{}, Options{}, now); !math.IsInf(f.DaysUntilFull, 1) || f.ProjectedBytes != 1000 || len(f.Warnings) != 0 {
+		t.Errorf("empty ledger forecast = %+v", f)
+	}
+}
+
+type fakeSource []store.DayStats
+
+func (s fakeSource) GetDailyStats(int) ([]store.DayStats, error) { return s, nil }
+
+func TestMeasureCountsTheWriteAheadLog(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "logryph.db")
+	if err := os.WriteFile(path, make([]byte, 4096), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path+"-wal", make([]byte, 1024), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	f, err := Measure(path, fakeSource{{Day: "2026-03-10", Events: 10}}, Options{}, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.DBBytes != 5120 || f.BytesPerEvent != 512 {
+		t.Errorf("forecast = %+v", f)
+	}
+	if _, err := NewMonitor(path, fakeSource{}, Options{MinHeadroom: 100}); err == nil {
+		t.Error("headroom of 100% was accepted")
+	}
+}
@@ -0,0 +1,8 @@
+//go:build !(linux || darwin || freebsd)
+
+package capacity
+
+// DiskUsage is not supported here; forecasts leave the disk unknown.
+func DiskUsage(dir string) (free, total uint64, err error) {
+	return 0, 0, ErrDiskUnsupported
+}
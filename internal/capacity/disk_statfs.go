@@ -0,0 +1,15 @@
+//go:build linux || darwin || freebsd
+
+package capacity
+
+import "golang.org/x/sys/unix"
+
+// DiskUsage returns the bytes available to unprivileged users and the size of the
+// filesystem holding dir.
+func DiskUsage(dir string) (free, total uint64, err error) {
+	var st unix.Statfs_t
+	if err := unix.Statfs(dir, &st); err != nil {
+		return 0, 0, err
+	}
+	return uint64(st.Bavail) * uint64(st.Bsize), uint64(st.Blocks) * uint64(st.Bsize), nil
+}
@@ -3,8 +3,15 @@ package core
 import (
 	"sync"
 
+	"github.com/slyt3/Logryph/internal/approval"
+	"github.com/slyt3/Logryph/internal/attachments"
+	"github.com/slyt3/Logryph/internal/capacity"
+	"github.com/slyt3/Logryph/internal/headpub"
 	"github.com/slyt3/Logryph/internal/ledger"
+	"github.com/slyt3/Logryph/internal/notify"
 	"github.com/slyt3/Logryph/internal/observer"
+	"github.com/slyt3/Logryph/internal/schema"
+	"github.com/slyt3/Logryph/internal/slo"
 )
 
 // Engine is the central state manager for Logryph
@@ -13,6 +20,13 @@ type Engine struct {
 	ActiveTasks     *sync.Map // task_id -> state
 	Observer        *observer.ObserverEngine
 	LastEventByTask *sync.Map // task_id -> last_event_id
+	Approvals       *approval.Registry
+	Schemas         *schema.Catalog    // tool input schemas from tools/list responses
+	Attachments     *attachments.Store // payload blobs; nil disables blob storage
+	Notifier        *notify.Dispatcher // rule notify channels; nil discards notifications
+	SLO             *slo.Tracker       // per-method latency and objectives; nil disables tracking
+	Capacity        *capacity.Monitor  // ledger growth forecast; nil when the ledger is not on disk
+	Heads           *headpub.Publisher // published chain head; nil disables publication
 }
 
 // NewEngine creates a new core state engine
@@ -22,5 +36,7 @@ func NewEngine(worker *ledger.Worker, obs *observer.ObserverEngine) *Engine {
 		Observer:        obs,
 		ActiveTasks:     &sync.Map{},
 		LastEventByTask: &sync.Map{},
+		Approvals:       approval.NewRegistry(0),
+		Schemas:         schema.NewCatalog(),
 	}
 }
@@ -0,0 +1,244 @@
+// Package cors answers CORS preflights and stamps CORS headers on responses, so agents
+// hosted in a browser can call the proxy and the admin API with fetch. Each listener has
+// its own policy; a policy without origins leaves the listener untouched.
+package cors
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultMaxAge = 10 * time.Minute
+	maxMaxAge     = 24 * time.Hour
+	maxOrigins    = 64
+	maxHeaders    = 64
+)
+
+// Config is the cors section of the policy file.
+type Config struct {
+	Proxy Policy `yaml:"proxy,omitempty"`
+	Admin Policy `yaml:"admin,omitempty"`
+}
+
+// Policy is one listener's CORS policy. Origins are "*", an exact origin such as
+// "https://agent.example.com", or a subdomain wildcard such as "https://*.example.com".
+type Policy struct {
+	AllowedOrigins   []string `yaml:"allowed_origins,omitempty"`
+	AllowedHeaders   []string `yaml:"allowed_headers,omitempty"` // in addition to the listener's own headers
+	ExposedHeaders   []string `yaml:"exposed_headers,omitempty"` // in addition to the listener's own headers
+	AllowCredentials bool     `yaml:"allow_credentials,omitempty"`
+	MaxAge           string   `yaml:"max_age,omitempty"` // how long browsers cache a preflight; default 10m
+}
+
+// Defaults are the methods and headers a listener always allows and exposes.
+type Defaults struct {
+	Methods []string
+	Headers []string
+	Exposed []string
+}
+
+// ValidateConfig checks both listeners' policies.
+func ValidateConfig(c Config) error {
+	if err := validatePolicy(c.Proxy); err != nil {
+		return fmt.Errorf("proxy: %w", err)
+	}
+	if err := validatePolicy(c.Admin); err != nil {
+		return fmt.Errorf("admin: %w", err)
+	}
+	return nil
+}
+
+func validatePolicy(p Policy) error {
+	if len(p.AllowedOrigins) > maxOrigins || len(p.AllowedHeaders) > maxHeaders || len(p.ExposedHeaders) > maxHeaders {
+		return fmt.Errorf("at most %d origins and %d headers of each kind", maxOrigins, maxHeaders)
+	}
+	for i := 0; i < len(p.AllowedOrigins); i++ {
+		o := p.AllowedOrigins[i]
+		if o == "*" {
+			if p.AllowCredentials {
+				return fmt.Errorf(`allowed_origins "*" cannot be combined with allow_credentials`)
+			}
+			continue
+		}
+		u, err := url.Parse(strings.Replace(o, "://*.", "://wildcard.", 1))
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || (u.Path != "" && u.Path != "/") || u.RawQuery != "" {
+			return fmt.Errorf("allowed_origins[%d] %q: must be \"*\" or scheme://host[:port]", i, o)
+		}
+	}
+	for _, h := range append(append([]string{}, p.AllowedHeaders...), p.ExposedHeaders...) {
+		if strings.TrimSpace(h) == "" || strings.ContainsAny(h, " ,:\r\n") {
+			return fmt.Errorf("invalid header name %q", h)
+		}
+	}
+	if _, err := p.maxAge(); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (p Policy) maxAge() (time.Duration, error) {
+	if p.MaxAge == "" {
+		return defaultMaxAge, nil
+	}
+	d, err := time.ParseDuration(p.MaxAge)
+	if err != nil || d < 0 || d > maxMaxAge {
+		return 0, fmt.Errorf("invalid max_age %q: must be a duration up to %s", p.MaxAge, maxMaxAge)
+	}
+	return d, nil
+}
+
+// allows reports whether origin matches the policy.
+func (p Policy) allows(origin string) bool {
+	origin = strings.ToLower(strings.TrimSuffix(origin, "/"))
+	for i := 0; i < len(p.AllowedOrigins) && i < maxOrigins; i++ {
+		allowed := strings.ToLower(strings.TrimSuffix(p.AllowedOrigins[i], "/"))
+		if allowed == "*" || allowed == origin {
+			return true
+		}
+		scheme, host, ok := strings.Cut(allowed, "://*.")
+		if ok && strings.HasPrefix(origin, scheme+"://") && strings.HasSuffix(origin, "."+host) {
+			return true
+		}
+	}
+	return false
+}
+
+// Handler applies p in front of next. Preflights are answered here and never reach next;
+// other requests from an allowed origin get CORS headers on their response, replacing any
+// the upstream set. Requests without an Origin header pass through unchanged.
+func Handler(p Policy, d Defaults, next http.Handler) http.Handler {
+	if len(p.AllowedOrigins) == 0 {
+		return next
+	}
+	maxAge, _ := p.maxAge()
+	allowedHeaders := headerSet(d.Headers, p.AllowedHeaders)
+	exposed := strings.Join(append(append([]string{}, d.Exposed...), p.ExposedHeaders...), ", ")
+	methods := strings.Join(d.Methods, ", ")
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		if origin == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		w.Header().Add("Vary", "Origin")
+		preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+		if !p.allows(origin) {
+			if preflight {
+				http.Error(w, "origin not allowed", http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(w, r) // the browser withholds the response without CORS headers
+			return
+		}
+		if preflight {
+			requested, ok := requestedHeaders(r, allowedHeaders)
+			if !ok || !containsFold(d.Methods, r.Header.Get("Access-Control-Request-Method")) {
+				http.Error(w, "method or headers not allowed", http.StatusForbidden)
+				return
+			}
+			h := w.Header()
+			p.stamp(h, origin, "")
+			h.Set("Access-Control-Allow-Methods", methods)
+			if requested != "" {
+				h.Set("Access-Control-Allow-Headers", requested)
+			}
+			h.Set("Access-Control-Max-Age", strconv.Itoa(int(maxAge.Seconds())))
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		next.ServeHTTP(&writer{ResponseWriter: w, policy: p, origin: origin, exposed: exposed}, r)
+	})
+}
+
+// stamp replaces any Access-Control-* headers in h with the policy's for origin.
+func (p Policy) stamp(h http.Header, origin, exposed string) {
+	for k := range h {
+		if strings.HasPrefix(k, "Access-Control-") {
+			delete(h, k)
+		}
+	}
+	if containsFold(p.AllowedOrigins, "*") && !p.AllowCredentials {
+		h.Set("Access-Control-Allow-Origin", "*")
+	} else {
+		h.Set("Access-Control-Allow-Origin", origin)
+	}
+	if p.AllowCredentials {
+		h.Set("Access-Control-Allow-Credentials", "true")
+	}
+	if exposed != "" {
+		h.Set("Access-Control-Expose-Headers", exposed)
+	}
+}
+
+// requestedHeaders checks Access-Control-Request-Headers against allowed and returns the
+// list to echo back.
+func requestedHeaders(r *http.Request, allowed map[string]bool) (string, bool) {
+	var names []string
+	for _, h := range strings.Split(r.Header.Get("Access-Control-Request-Headers"), ",") {
+		h = strings.TrimSpace(h)
+		if h == "" {
+			continue
+		}
+		if !allowed[strings.ToLower(h)] {
+			return "", false
+		}
+		names = append(names, h)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", "), true
+}
+
+func headerSet(lists ...[]string) map[string]bool {
+	set := make(map[string]bool)
+	for _, list := range lists {
+		for j := 0; j < len(list); j++ {
+			set[strings.ToLower(list[j])] = true
+		}
+	}
+	return set
+}
+
+func containsFold(list []string, s string) bool {
+	for i := 0; i < len(list); i++ {
+		if strings.EqualFold(list[i], s) {
+			return true
+		}
+	}
+	return false
+}
+
+// writer stamps CORS headers on the response just before it is written, after the
+// reverse proxy has copied in the upstream's headers.
+type writer struct {
+	http.ResponseWriter
+	policy      Policy
+	origin      string
+	exposed     string
+	wroteHeader bool
+}
+
+func (w *writer) WriteHeader(code int) {
+	if !w.wroteHeader {
+		w.wroteHeader = true
+		w.policy.stamp(w.Header(), w.origin, w.exposed)
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *writer) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// Unwrap keeps flushing and hijacking reachable through http.ResponseController.
+func (w *writer) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
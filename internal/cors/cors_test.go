@@ -0,0 +1,103 @@
+package cors
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+var testDefaults = Defaults{
+	Methods: []string{http.MethodPost, http.MethodOptions},
+	Headers: []string{"Content-Type", "X-Logryph-Task-ID"},
+	Exposed: []string{"X-Logryph-Event-ID"},
+}
+
+func TestHandler(t *testing.T) {
+	reached := 0
+	upstream := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reached++
+		w.Header().Set("Access-Control-Allow-Origin", "https://upstream.example")
+		w.Header().Set("X-Logryph-Event-ID", "abc12345")
+		w.WriteHeader(http.StatusOK)
+	})
+	h := Handler(Policy{
+		AllowedOrigins:   []string{"https://agent.example.com", "https://*.apps.example.com"},
+		AllowedHeaders:   []string{"X-Custom"},
+		AllowCredentials: true,
+		MaxAge:           "5m",
+	}, testDefaults, upstream)
+	serve := func(method, origin string, header map[string]string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/", nil)
+		if origin != "" {
+			req.Header.Set("Origin", origin)
+		}
+		for k, v := range header {
+			req.Header.Set(k, v)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+	preflight := func(headers string) map[string]string {
+		return map[string]string{"Access-Control-Request-Method": "POST", "Access-Control-Request-Headers": headers}
+	}
+
+	rec := serve(http.MethodOptions, "https://agent.example.com", preflight("content-type, x-logryph-task-id, X-Custom"))
+	if rec.Code != http.StatusNoContent || rec.Header().Get("Access-Control-Allow-Origin") != "https://agent.example.com" {
+		t.Fatalf("preflight: status %d, headers %v", rec.Code, rec.Header())
+	}
+	if rec.Header().Get("Access-Control-Allow-Credentials") != "true" || rec.Header().Get("Access-Control-Max-Age") != "300" {
+		t.Errorf("preflight should carry credentials and max age: %v", rec.Header())
+	}
+	if reached != 0 {
+		t.Errorf("a preflight must not reach the upstream")
+	}
+	if rec := serve(http.MethodOptions, "https://evil.example", preflight("")); rec.Code != http.StatusForbidden {
+		t.Errorf("preflight from an unknown origin: status %d, want 403", rec.Code)
+	}
+	if rec := serve(http.MethodOptions, "https://agent.example.com", preflight("X-Other")); rec.Code != http.StatusForbidden {
+		t.Errorf("preflight asking for an unlisted header: status %d, want 403", rec.Code)
+	}
+
+	rec = serve(http.MethodPost, "https://ui.apps.example.com", nil)
+	if got := rec.Header().Values("Access-Control-Allow-Origin"); len(got) != 1 || got[0] != "https://ui.apps.example.com" {
+		t.Errorf("upstream CORS headers should be replaced, got %v", got)
+	}
+	if rec.Header().Get("Access-Control-Expose-Headers") != "X-Logryph-Event-ID" {
+		t.Errorf("event ID header should be exposed: %v", rec.Header())
+	}
+
+	rec = serve(http.MethodPost, "https://evil.example", nil)
+	if got := rec.Header().Get("Access-Control-Allow-Origin"); got != "https://upstream.example" {
+		t.Errorf("a disallowed origin should get no CORS headers of ours, got %q", got)
+	}
+	if rec := serve(http.MethodPost, "", nil); rec.Header().Get("Vary") != "" {
+		t.Errorf("requests without an Origin should pass through untouched")
+	}
+	if reached != 3 {
+		t.Errorf("reached upstream %d times, want 3", reached)
+	}
+}
+
+func TestValidateConfig(t *testing.T) {
+	cases := []struct {
+		name string
+		p    Policy
+		ok   bool
+	}{
+		{"empty", Policy{}, true},
+		{"exact and wildcard", Policy{AllowedOrigins: []string{"http://localhost:3000", "https://*.example.com"}}, true},
+		{"any origin", Policy{AllowedOrigins: []string{"*"}}, true},
+		{"any origin with credentials", Policy{AllowedOrigins: []string{"*"}, AllowCredentials: true}, false},
+		{"path", Policy{AllowedOrigins: []string{"https://example.com/app"}}, false},
+		{"scheme", Policy{AllowedOrigins: []string{"example.com"}}, false},
+		{"header", Policy{AllowedOrigins: []string{"*"}, AllowedHeaders: []string{"a, b"}}, false},
+		{"max age", Policy{AllowedOrigins: []string{"*"}, MaxAge: "48h"}, false},
+	}
+	for _, tc := range cases {
+		err := ValidateConfig(Config{Admin: tc.p})
+		if (err == nil) != tc.ok {
+			t.Errorf("%s: err = %v, want ok=%v", tc.name, err, tc.ok)
+		}
+	}
+}
@@ -31,6 +31,31 @@ func TestCalculateEventHash(t *testing.T) {
 	}
 }
 
+func TestPayloadHashNormalizesParams(t *testing.T) {
+	a, err := PayloadHash("db:query", map[string]interface{}{
+		"sql": "  SELECT 1 \n", "limit": 10, "opts": map[string]interface{}{"tags": []interface{}{" a", "b "}},
+	})
+	if err != nil {
+		t.Fatalf("PayloadHash: %v", err)
+	}
+	b, err := PayloadHash("db:query", map[string]interface{}{
+		"opts": map[string]interface{}{"tags": []string{"a", "b"}}, "limit": 1e1, "sql": "SELECT 1",
+	})
+	if err != nil {
+		t.Fatalf("PayloadHash: %v", err)
+	}
+	if a != b {
+		t.Errorf("formatting differences should not change the params hash: %s != %s", a, b)
+	}
+	c, err := PayloadHash("db:query", map[string]interface{}{"sql": "SELECT  1", "limit": 10, "opts": map[string]interface{}{"tags": []string{"a", "b"}}})
+	if err != nil {
+		t.Fatalf("PayloadHash: %v", err)
+	}
+	if c == a {
+		t.Error("inner whitespace is part of the value")
+	}
+}
+
 func TestSigner(t *testing.T) {
 	keyPath := ".test_key"
 	defer func() {
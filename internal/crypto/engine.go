@@ -4,6 +4,7 @@ import (
 	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
+	"fmt"
 	"strings"
 
 	"github.com/slyt3/Logryph/internal/assert"
@@ -27,6 +28,105 @@ func CalculateEventHash(prevHash string, payload interface{}) (string, error) {
 	if err := assert.Check(payload != nil, "payload must not be nil"); err != nil {
 		return "", err
 	}
+	canonicalJSON, err := canonicalize(payload)
+	if err != nil {
+		return "", err
+	}
+
+	// Hash(Prev + Current)
+	hasher := sha256.New()
+	hasher.Write([]byte(prevHash))
+	hasher.Write([]byte(canonicalJSON))
+
+	return hex.EncodeToString(hasher.Sum(nil)), nil
+}
+
+// PayloadHash identifies a call by its method and params alone: SHA-256 of the canonical
+// JSON of {"method": method, "params": NormalizeParams(params)}. Unlike the event hash it
+// does not depend on the chain, so the same payload always has the same hash, whenever it
+// was recorded. It is the params_hash used for duplicate lookups and replay matching.
+func PayloadHash(method string, params interface{}) (string, error) {
+	normalized, err := NormalizeParams(params)
+	if err != nil {
+		return "", err
+	}
+	canonicalJSON, err := canonicalize(map[string]interface{}{"method": method, "params": normalized})
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256([]byte(canonicalJSON))
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// maxNormalizeDepth bounds the nesting NormalizeParams walks; deeper values are kept as
+// decoded.
+const maxNormalizeDepth = 64
+
+// maxNormalizeValues bounds the non-string values NormalizeParams visits.
+const maxNormalizeValues = 1 << 20
+
+// NormalizeParams returns params in the form payload hashes are taken over: decoded from
+// JSON, so numbers are float64 and compare by value (1, 1.0 and 1e0 are one number), with
+// leading and trailing whitespace trimmed from every string value. Keys are left as they
+// are; canonicalization sorts them. Two calls that differ only in formatting normalize to
+// the same value.
+func NormalizeParams(params interface{}) (interface{}, error) {
+	jsonBytes, err := json.Marshal(params)
+	if err != nil {
+		return nil, fmt.Errorf("encoding params: %w", err)
+	}
+	var decoded interface{}
+	if err := json.Unmarshal(jsonBytes, &decoded); err != nil {
+		return nil, fmt.Errorf("decoding params: %w", err)
+	}
+	return trimStrings(decoded)
+}
+
+// trimStrings trims string values in place, with an explicit stack of the maps and lists
+// still to visit. Params with more values than maxNormalizeValues are an error rather than
+// half normalized, since which half would depend on map order.
+func trimStrings(v interface{}) (interface{}, error) {
+	if s, ok := v.(string); ok {
+		return strings.TrimSpace(s), nil
+	}
+	type trimFrame struct {
+		value interface{}
+		depth int
+	}
+	stack := []trimFrame{{v, 0}}
+	for n := 0; n < maxNormalizeValues && len(stack) > 0; n++ {
+		f := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if f.depth+1 >= maxNormalizeDepth {
+			continue
+		}
+		switch t := f.value.(type) {
+		case map[string]interface{}:
+			for k, item := range t {
+				if s, ok := item.(string); ok {
+					t[k] = strings.TrimSpace(s)
+				} else {
+					stack = append(stack, trimFrame{item, f.depth + 1})
+				}
+			}
+		case []interface{}:
+			for i := 0; i < len(t); i++ {
+				if s, ok := t[i].(string); ok {
+					t[i] = strings.TrimSpace(s)
+				} else {
+					stack = append(stack, trimFrame{t[i], f.depth + 1})
+				}
+			}
+		}
+	}
+	if len(stack) > 0 {
+		return nil, fmt.Errorf("params hold more than %d values", maxNormalizeValues)
+	}
+	return v, nil
+}
+
+// canonicalize renders payload as RFC 8785 canonical JSON.
+func canonicalize(payload interface{}) (string, error) {
 	// 1. First marshal to JSON to normalize the data structure
 	jsonBytes, err := json.Marshal(payload)
 	if err := assert.Check(err == nil, "json marshal failed: %v", err); err != nil {
@@ -44,15 +144,5 @@ func CalculateEventHash(prevHash string, payload interface{}) (string, error) {
 
 	// 3. Canonicalize using JCS (RFC 8785)
 	// This ensures identical output regardless of key order
-	canonicalJSON, err := jcs.Format(normalized)
-	if err != nil {
-		return "", err
-	}
-
-	// 4. Hash(Prev + Current)
-	hasher := sha256.New()
-	hasher.Write([]byte(prevHash))
-	hasher.Write([]byte(canonicalJSON))
-
-	return hex.EncodeToString(hasher.Sum(nil)), nil
+	return jcs.Format(normalized)
 }
@@ -49,6 +49,47 @@ func NewSigner(keyPath string) (*Signer, error) {
 	}, nil
 }
 
+// LoadSigner loads the key at keyPath without generating one when it is missing, for
+// tools that must sign with the ledger's existing key or not at all.
+func LoadSigner(keyPath string) (*Signer, error) {
+	privateKey, err := loadPrivateKey(keyPath)
+	if err != nil {
+		return nil, fmt.Errorf("loading key %s: %w", keyPath, err)
+	}
+	return &Signer{
+		privateKey: privateKey,
+		publicKey:  privateKey.Public().(ed25519.PublicKey),
+	}, nil
+}
+
+// NewSignerFromSeed derives a signer from a 32-byte Ed25519 seed, without touching disk,
+// for keys that must be reproducible such as the test-vector corpus's. A seed known to
+// anyone else must never sign a real ledger.
+func NewSignerFromSeed(seed []byte) (*Signer, error) {
+	if len(seed) != ed25519.SeedSize {
+		return nil, fmt.Errorf("invalid seed size: expected %d, got %d", ed25519.SeedSize, len(seed))
+	}
+	privateKey := ed25519.NewKeyFromSeed(seed)
+	return &Signer{
+		privateKey: privateKey,
+		publicKey:  privateKey.Public().(ed25519.PublicKey),
+	}, nil
+}
+
+// VerifyWithPublicKey checks a hex-encoded signature of hash against a hex-encoded
+// Ed25519 public key, without needing the private key.
+func VerifyWithPublicKey(pubKeyHex, hash, signatureHex string) bool {
+	pub, err := hex.DecodeString(pubKeyHex)
+	if err != nil || len(pub) != ed25519.PublicKeySize {
+		return false
+	}
+	signature, err := hex.DecodeString(signatureHex)
+	if err != nil {
+		return false
+	}
+	return ed25519.Verify(ed25519.PublicKey(pub), []byte(hash), signature)
+}
+
 // SignHash signs a hash string with Ed25519 and returns the signature as hex-encoded string.
 // The hash is signed directly (not re-hashed). Returns an error only on encoding failure (never fails in practice).
 func (s *Signer) SignHash(hash string) (string, error) {
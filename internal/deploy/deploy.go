@@ -0,0 +1,147 @@
+// Package deploy generates container deployment assets for the proxy. Everything is
+// derived from the code, not from templates: the policy is the validated observer.Config,
+// and ports, paths and probes are the ones the binary actually uses, so regenerating
+// after an upgrade picks up new settings.
+package deploy
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/slyt3/Logryph/internal/observer"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// AdminPort serves /healthz, /readyz and /metrics; it matches the serve default.
+	AdminPort = 9998
+	// DefaultPort is the proxy's default --port.
+	DefaultPort = 9999
+	// PolicyDir is where the policy ConfigMap is mounted.
+	PolicyDir = "/etc/logryph"
+	// DataDir is the working directory, so logryph.db, .logryph_key and attachments
+	// land on the persistent volume.
+	DataDir = "/var/lib/logryph"
+	// PolicyFile is the ConfigMap key and file name of the policy.
+	PolicyFile = "logryph-policy.yaml"
+	// AdminTokenKey is the Secret key exposed as LOGRYPH_ADMIN_TOKEN.
+	AdminTokenKey = "admin-token"
+)
+
+// Spec describes one proxy deployment.
+type Spec struct {
+	Name         string           // resource name prefix (default "logryph")
+	Namespace    string           // omitted from manifests when empty
+	Image        string           // proxy container image, required
+	Target       string           // upstream tool server URL, required
+	Port         int              // proxy port (default DefaultPort)
+	Backpressure string           // drop (default) or block
+	StorageSize  string           // ledger volume size (default "1Gi")
+	AdminToken   string           // Kubernetes generates one when empty
+	Policy       *observer.Config // validated policy, required
+}
+
+// normalize fills defaults and rejects specs that would produce a broken deployment.
+func (s *Spec) normalize() error {
+	if s.Policy == nil {
+		return fmt.Errorf("policy is required")
+	}
+	if s.Image == "" {
+		return fmt.Errorf("image is required")
+	}
+	if u, err := url.Parse(s.Target); err != nil || u.Scheme == "" || u.Host == "" {
+		return fmt.Errorf("target must be an absolute URL, got %q", s.Target)
+	}
+	if s.Name == "" {
+		s.Name = "logryph"
+	}
+	if s.Port == 0 {
+		s.Port = DefaultPort
+	}
+	if s.Port < 1 || s.Port > 65535 || s.Port == AdminPort {
+		return fmt.Errorf("port %d is invalid or collides with the admin port", s.Port)
+	}
+	switch s.Backpressure {
+	case "":
+		s.Backpressure = "drop"
+	case "drop", "block":
+	default:
+		return fmt.Errorf("backpressure must be drop or block, got %q", s.Backpressure)
+	}
+	if s.StorageSize == "" {
+		s.StorageSize = "1Gi"
+	}
+	return nil
+}
+
+// adminToken returns the configured admin token, or a new random one.
+func (s *Spec) adminToken() (string, error) {
+	if s.AdminToken != "" {
+		return s.AdminToken, nil
+	}
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("generating admin token: %w", err)
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// policyYAML renders the policy as the proxy will read it.
+func (s *Spec) policyYAML() (string, error) {
+	data, err := encodeDocuments(s.Policy)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// args are the command line inside the container, whose image runs logyctl.
+func (s *Spec) args() []string {
+	return []string{
+		"serve",
+		"--config", PolicyDir + "/" + PolicyFile,
+		"--target", s.Target,
+		"--port", fmt.Sprint(s.Port),
+		"--backpressure", s.Backpressure,
+	}
+}
+
+func (s *Spec) labels() map[string]string {
+	return map[string]string{"app.kubernetes.io/name": "logryph", "app.kubernetes.io/instance": s.Name}
+}
+
+// splitImage splits an image reference into repository, tag and digest. A registry
+// port ("host:5000/img") is not mistaken for a tag.
+func splitImage(image string) (repo, tag, digest string) {
+	if at := strings.Index(image, "@"); at >= 0 {
+		image, digest = image[:at], image[at+1:]
+	}
+	colon, slash := strings.LastIndex(image, ":"), strings.LastIndex(image, "/")
+	if colon > slash {
+		return image[:colon], image[colon+1:], digest
+	}
+	if digest == "" {
+		tag = "latest"
+	}
+	return image, tag, digest
+}
+
+// encodeDocuments writes docs as one multi-document YAML stream.
+func encodeDocuments(docs ...interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	for i := 0; i < len(docs); i++ {
+		if err := enc.Encode(docs[i]); err != nil {
+			return nil, fmt.Errorf("encoding manifest: %w", err)
+		}
+	}
+	if err := enc.Close(); err != nil {
+		return nil, fmt.Errorf("encoding manifest: %w", err)
+	}
+	return buf.Bytes(), nil
+}
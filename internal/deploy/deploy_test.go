@@ -0,0 +1,131 @@
+package deploy
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/slyt3/Logryph/internal/observer"
+	"gopkg.in/yaml.v3"
+)
+
+func testSpec(t *testing.T) Spec {
+	t.Helper()
+	policy, err := observer.LoadConfig("../../logryph-policy.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return Spec{Image: "registry.local:5000/logryph:v1", Target: "http://tools:8080", Namespace: "agents", Policy: policy}
+}
+
+func decodeAll(t *testing.T, data []byte) []map[string]interface{} {
+	t.Helper()
+	var docs []map[string]interface{}
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	for i := 0; i < 100; i++ {
+		var doc map[string]interface{}
+		if err := dec.Decode(&doc); errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		docs = append(docs, doc)
+	}
+	return docs
+}
+
+func TestKubernetesManifests(t *testing.T) {
+	out, err := Kubernetes(testSpec(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	docs := decodeAll(t, out)
+	var kinds []string
+	for _, d := range docs {
+		kinds = append(kinds, d["kind"].(string))
+	}
+	if got := strings.Join(kinds, ","); got != "ConfigMap,Secret,PersistentVolumeClaim,Deployment,Service" {
+		t.Fatalf("kinds = %s", got)
+	}
+
+	// The embedded policy must load back through the proxy's own validation.
+	policy := docs[0]["data"].(map[string]interface{})[PolicyFile].(string)
+	path := filepath.Join(t.TempDir(), PolicyFile)
+	if err := os.WriteFile(path, []byte(policy), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := observer.LoadConfig(path); err != nil {
+		t.Errorf("generated policy does not load: %v", err)
+	}
+	if token := docs[1]["stringData"].(map[string]interface{})[AdminTokenKey].(string); len(token) != 64 {
+		t.Errorf("admin token = %q", token)
+	}
+	for _, want := range []string{"path: /healthz", "path: /readyz", "port: admin", "containerPort: 9998", "namespace: agents", "- http://tools:8080"} {
+		if !strings.Contains(string(out), want) {
+			t.Errorf("manifests missing %q", want)
+		}
+	}
+}
+
+func TestHelmValuesAndSidecar(t *testing.T) {
+	values, err := HelmValues(testSpec(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc := decodeAll(t, values)[0]
+	image := doc["image"].(map[string]interface{})
+	if image["repository"] != "registry.local:5000/logryph" || image["tag"] != "v1" {
+		t.Errorf("image = %v", image)
+	}
+	if doc["policy"].(map[string]interface{})["version"] == nil {
+		t.Error("values are missing the policy")
+	}
+	if token := doc["adminToken"].(map[string]interface{}); token["value"] != "" || token["key"] != AdminTokenKey {
+		t.Errorf("Helm values must not carry an admin token: %v", token)
+	}
+
+	sidecar, err := Sidecar(testSpec(t), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(sidecar), "value: http://localhost:9999") || !strings.Contains(string(sidecar), "- 127.0.0.1:9999") ||
+		!strings.Contains(string(sidecar), "claimName: logryph-agent-ledger") {
+		t.Errorf("sidecar example:\n%s", sidecar)
+	}
+}
+
+func TestSpecValidation(t *testing.T) {
+	bad := []func(*Spec){
+		func(s *Spec) { s.Image = "" },
+		func(s *Spec) { s.Target = "tools:8080" },
+		func(s *Spec) { s.Port = AdminPort },
+		func(s *Spec) { s.Backpressure = "queue" },
+		func(s *Spec) { s.Policy = nil },
+	}
+	for i, mutate := range bad {
+		spec := testSpec(t)
+		mutate(&spec)
+		if _, err := Kubernetes(spec); err == nil {
+			t.Errorf("case %d: invalid spec accepted", i)
+		}
+	}
+}
+
+func TestSplitImage(t *testing.T) {
+	cases := map[string][3]string{
+		"logryph":                        {"logryph", "latest", ""},
+		"ghcr.io/acme/logryph:v1.2":      {"ghcr.io/acme/logryph", "v1.2", ""},
+		"host:5000/logryph":              {"host:5000/logryph", "latest", ""},
+		"ghcr.io/acme/logryph@sha256:ab": {"ghcr.io/acme/logryph", "", "sha256:ab"},
+	}
+	for in, want := range cases {
+		repo, tag, digest := splitImage(in)
+		if [3]string{repo, tag, digest} != want {
+			t.Errorf("splitImage(%q) = %q %q %q", in, repo, tag, digest)
+		}
+	}
+}
@@ -0,0 +1,266 @@
+package deploy
+
+import "fmt"
+
+// Minimal Kubernetes object shapes; field order follows kubectl's output.
+
+type objectMeta struct {
+	Name        string            `yaml:"name,omitempty"`
+	Namespace   string            `yaml:"namespace,omitempty"`
+	Labels      map[string]string `yaml:"labels,omitempty"`
+	Annotations map[string]string `yaml:"annotations,omitempty"`
+}
+
+type object struct {
+	APIVersion string            `yaml:"apiVersion"`
+	Kind       string            `yaml:"kind"`
+	Metadata   objectMeta        `yaml:"metadata"`
+	Type       string            `yaml:"type,omitempty"`
+	Data       map[string]string `yaml:"data,omitempty"`
+	StringData map[string]string `yaml:"stringData,omitempty"`
+	Spec       interface{}       `yaml:"spec,omitempty"`
+}
+
+type container struct {
+	Name            string          `yaml:"name"`
+	Image           string          `yaml:"image"`
+	Args            []string        `yaml:"args,omitempty"`
+	WorkingDir      string          `yaml:"workingDir,omitempty"`
+	Ports           []containerPort `yaml:"ports,omitempty"`
+	Env             []envVar        `yaml:"env,omitempty"`
+	VolumeMounts    []volumeMount   `yaml:"volumeMounts,omitempty"`
+	LivenessProbe   *Probe          `yaml:"livenessProbe,omitempty"`
+	ReadinessProbe  *Probe          `yaml:"readinessProbe,omitempty"`
+	SecurityContext *securityCtx    `yaml:"securityContext,omitempty"`
+}
+
+type containerPort struct {
+	Name          string `yaml:"name"`
+	ContainerPort int    `yaml:"containerPort"`
+}
+
+type envVar struct {
+	Name      string     `yaml:"name"`
+	Value     string     `yaml:"value,omitempty"`
+	ValueFrom *envSource `yaml:"valueFrom,omitempty"`
+}
+
+type envSource struct {
+	SecretKeyRef keyRef `yaml:"secretKeyRef"`
+}
+
+type keyRef struct {
+	Name string `yaml:"name"`
+	Key  string `yaml:"key"`
+}
+
+type volumeMount struct {
+	Name      string `yaml:"name"`
+	MountPath string `yaml:"mountPath"`
+	ReadOnly  bool   `yaml:"readOnly,omitempty"`
+}
+
+// Probe is an HTTP probe against a named container port.
+type Probe struct {
+	HTTPGet             HTTPGetAction `yaml:"httpGet"`
+	InitialDelaySeconds int           `yaml:"initialDelaySeconds,omitempty"`
+	PeriodSeconds       int           `yaml:"periodSeconds,omitempty"`
+	FailureThreshold    int           `yaml:"failureThreshold,omitempty"`
+}
+
+// HTTPGetAction is the request a Probe makes.
+type HTTPGetAction struct {
+	Path string `yaml:"path"`
+	Port string `yaml:"port"`
+}
+
+type securityCtx struct {
+	RunAsNonRoot             bool `yaml:"runAsNonRoot"`
+	ReadOnlyRootFilesystem   bool `yaml:"readOnlyRootFilesystem"`
+	AllowPrivilegeEscalation bool `yaml:"allowPrivilegeEscalation"`
+}
+
+type volume struct {
+	Name                  string    `yaml:"name"`
+	ConfigMap             *nameRef  `yaml:"configMap,omitempty"`
+	PersistentVolumeClaim *claimRef `yaml:"persistentVolumeClaim,omitempty"`
+}
+
+type nameRef struct {
+	Name string `yaml:"name"`
+}
+
+type claimRef struct {
+	ClaimName string `yaml:"claimName"`
+}
+
+type podSpec struct {
+	SecurityContext map[string]int `yaml:"securityContext,omitempty"`
+	Containers      []container    `yaml:"containers"`
+	Volumes         []volume       `yaml:"volumes,omitempty"`
+}
+
+type podTemplate struct {
+	Metadata objectMeta `yaml:"metadata"`
+	Spec     podSpec    `yaml:"spec"`
+}
+
+type deploymentSpec struct {
+	Replicas int                          `yaml:"replicas"`
+	Strategy map[string]string            `yaml:"strategy"`
+	Selector map[string]map[string]string `yaml:"selector"`
+	Template podTemplate                  `yaml:"template"`
+}
+
+type servicePort struct {
+	Name       string `yaml:"name"`
+	Port       int    `yaml:"port"`
+	TargetPort string `yaml:"targetPort"`
+}
+
+type serviceSpec struct {
+	Selector map[string]string `yaml:"selector"`
+	Ports    []servicePort     `yaml:"ports"`
+}
+
+type claimSpec struct {
+	AccessModes []string                     `yaml:"accessModes"`
+	Resources   map[string]map[string]string `yaml:"resources"`
+}
+
+// Probes returns the liveness and readiness probes for the proxy container. Liveness
+// only checks the process answers; readiness also fails while the ledger worker is
+// unhealthy, so traffic stops before evidence is lost.
+func Probes() (liveness, readiness *Probe) {
+	liveness = &Probe{HTTPGet: HTTPGetAction{Path: "/healthz", Port: "admin"}, InitialDelaySeconds: 5, PeriodSeconds: 10, FailureThreshold: 3}
+	readiness = &Probe{HTTPGet: HTTPGetAction{Path: "/readyz", Port: "admin"}, PeriodSeconds: 5, FailureThreshold: 2}
+	return liveness, readiness
+}
+
+func (s *Spec) meta(name string) objectMeta {
+	return objectMeta{Name: name, Namespace: s.Namespace, Labels: s.labels()}
+}
+
+// proxyContainer is the Logryph container, shared by the standalone and sidecar layouts.
+func (s *Spec) proxyContainer() container {
+	liveness, readiness := Probes()
+	return container{
+		Name:       "logryph",
+		Image:      s.Image,
+		Args:       s.args(),
+		WorkingDir: DataDir,
+		Ports: []containerPort{
+			{Name: "proxy", ContainerPort: s.Port},
+			{Name: "admin", ContainerPort: AdminPort},
+		},
+		Env: []envVar{{Name: "LOGRYPH_ADMIN_TOKEN", ValueFrom: &envSource{SecretKeyRef: keyRef{Name: s.Name, Key: AdminTokenKey}}}},
+		VolumeMounts: []volumeMount{
+			{Name: "policy", MountPath: PolicyDir, ReadOnly: true},
+			{Name: "ledger", MountPath: DataDir},
+		},
+		LivenessProbe:   liveness,
+		ReadinessProbe:  readiness,
+		SecurityContext: &securityCtx{RunAsNonRoot: true, ReadOnlyRootFilesystem: true},
+	}
+}
+
+// deployment wraps containers in a single-replica Deployment. The ledger is one SQLite
+// file on a ReadWriteOnce volume, so pods are replaced, never run side by side.
+func (s *Spec) deployment(name, claim string, containers []container) object {
+	return object{
+		APIVersion: "apps/v1",
+		Kind:       "Deployment",
+		Metadata:   s.meta(name),
+		Spec: deploymentSpec{
+			Replicas: 1,
+			Strategy: map[string]string{"type": "Recreate"},
+			Selector: map[string]map[string]string{"matchLabels": {"app.kubernetes.io/instance": name}},
+			Template: podTemplate{
+				Metadata: objectMeta{
+					Labels: map[string]string{"app.kubernetes.io/name": "logryph", "app.kubernetes.io/instance": name},
+					Annotations: map[string]string{
+						"prometheus.io/scrape": "true",
+						"prometheus.io/port":   fmt.Sprint(AdminPort),
+						"prometheus.io/path":   "/metrics",
+					},
+				},
+				Spec: podSpec{
+					SecurityContext: map[string]int{"runAsUser": 65532, "runAsGroup": 65532, "fsGroup": 65532},
+					Containers:      containers,
+					Volumes: []volume{
+						{Name: "policy", ConfigMap: &nameRef{Name: s.Name + "-policy"}},
+						{Name: "ledger", PersistentVolumeClaim: &claimRef{ClaimName: claim}},
+					},
+				},
+			},
+		},
+	}
+}
+
+func (s *Spec) claim(name string) object {
+	return object{
+		APIVersion: "v1",
+		Kind:       "PersistentVolumeClaim",
+		Metadata:   s.meta(name),
+		Spec: claimSpec{
+			AccessModes: []string{"ReadWriteOnce"},
+			Resources:   map[string]map[string]string{"requests": {"storage": s.StorageSize}},
+		},
+	}
+}
+
+// Kubernetes returns the manifests for a standalone proxy: the policy ConfigMap, the
+// admin token Secret, the ledger volume claim, the Deployment and its Service.
+func Kubernetes(spec Spec) ([]byte, error) {
+	if err := spec.normalize(); err != nil {
+		return nil, err
+	}
+	policy, err := spec.policyYAML()
+	if err != nil {
+		return nil, err
+	}
+	token, err := spec.adminToken()
+	if err != nil {
+		return nil, err
+	}
+	configMap := object{APIVersion: "v1", Kind: "ConfigMap", Metadata: spec.meta(spec.Name + "-policy"), Data: map[string]string{PolicyFile: policy}}
+	secret := object{APIVersion: "v1", Kind: "Secret", Metadata: spec.meta(spec.Name), Type: "Opaque", StringData: map[string]string{AdminTokenKey: token}}
+	service := object{
+		APIVersion: "v1",
+		Kind:       "Service",
+		Metadata:   spec.meta(spec.Name),
+		Spec: serviceSpec{
+			Selector: map[string]string{"app.kubernetes.io/instance": spec.Name},
+			Ports: []servicePort{
+				{Name: "proxy", Port: spec.Port, TargetPort: "proxy"},
+				{Name: "admin", Port: AdminPort, TargetPort: "admin"},
+			},
+		},
+	}
+	deployment := spec.deployment(spec.Name, spec.Name+"-ledger", []container{spec.proxyContainer()})
+	return encodeDocuments(configMap, secret, spec.claim(spec.Name+"-ledger"), deployment, service)
+}
+
+// Sidecar returns an example of running the proxy next to an agent in the same pod. The
+// proxy only accepts the agent's traffic on localhost; the admin port stays on the pod
+// network because kubelet probes cannot reach loopback. The ConfigMap and Secret from
+// Kubernetes are reused, and the pod gets its own ledger volume.
+func Sidecar(spec Spec, agentImage string) ([]byte, error) {
+	if err := spec.normalize(); err != nil {
+		return nil, err
+	}
+	if agentImage == "" {
+		agentImage = "registry.example.com/my-agent:latest"
+	}
+	name := spec.Name + "-agent"
+	agent := container{
+		Name:  "agent",
+		Image: agentImage,
+		Env:   []envVar{{Name: "TOOL_SERVER_URL", Value: fmt.Sprintf("http://localhost:%d", spec.Port)}},
+	}
+	proxy := spec.proxyContainer()
+	proxy.Args = append(proxy.Args, "--listen", fmt.Sprintf("127.0.0.1:%d", spec.Port))
+	proxy.Ports = []containerPort{{Name: "admin", ContainerPort: AdminPort}}
+	deployment := spec.deployment(name, name+"-ledger", []container{agent, proxy})
+	return encodeDocuments(spec.claim(name+"-ledger"), deployment)
+}
@@ -0,0 +1,79 @@
+package deploy
+
+import (
+	"fmt"
+
+	"github.com/slyt3/Logryph/internal/observer"
+)
+
+// helmValues follows the layout of `helm create` charts, so the file drops into a
+// standard chart: image.repository/tag, service, probes, persistence. The policy is a
+// structured value a chart renders into its ConfigMap with toYaml.
+type helmValues struct {
+	ReplicaCount   int               `yaml:"replicaCount"`
+	Image          helmImage         `yaml:"image"`
+	Args           []string          `yaml:"args"`
+	WorkingDir     string            `yaml:"workingDir"`
+	PodAnnotations map[string]string `yaml:"podAnnotations"`
+	Service        helmService       `yaml:"service"`
+	LivenessProbe  *Probe            `yaml:"livenessProbe"`
+	ReadinessProbe *Probe            `yaml:"readinessProbe"`
+	Persistence    helmPersistence   `yaml:"persistence"`
+	AdminToken     helmSecret        `yaml:"adminToken"`
+	Policy         *observer.Config  `yaml:"policy"`
+}
+
+type helmImage struct {
+	Repository string `yaml:"repository"`
+	Tag        string `yaml:"tag,omitempty"`
+	Digest     string `yaml:"digest,omitempty"`
+	PullPolicy string `yaml:"pullPolicy"`
+}
+
+type helmService struct {
+	Type      string `yaml:"type"`
+	Port      int    `yaml:"port"`
+	AdminPort int    `yaml:"adminPort"`
+}
+
+type helmPersistence struct {
+	Enabled    bool   `yaml:"enabled"`
+	Size       string `yaml:"size"`
+	AccessMode string `yaml:"accessMode"`
+	MountPath  string `yaml:"mountPath"`
+}
+
+type helmSecret struct {
+	ExistingSecret string `yaml:"existingSecret"`
+	Key            string `yaml:"key"`
+	Value          string `yaml:"value"`
+}
+
+// HelmValues returns the same deployment as Kubernetes, as a values.yaml for a chart.
+// adminToken.value is left empty; set it or point existingSecret at a Secret holding
+// the token, so the file can be committed.
+func HelmValues(spec Spec) ([]byte, error) {
+	if err := spec.normalize(); err != nil {
+		return nil, err
+	}
+	repo, tag, digest := splitImage(spec.Image)
+	liveness, readiness := Probes()
+	values := helmValues{
+		ReplicaCount: 1,
+		Image:        helmImage{Repository: repo, Tag: tag, Digest: digest, PullPolicy: "IfNotPresent"},
+		Args:         spec.args(),
+		WorkingDir:   DataDir,
+		PodAnnotations: map[string]string{
+			"prometheus.io/scrape": "true",
+			"prometheus.io/port":   fmt.Sprint(AdminPort),
+			"prometheus.io/path":   "/metrics",
+		},
+		Service:        helmService{Type: "ClusterIP", Port: spec.Port, AdminPort: AdminPort},
+		LivenessProbe:  liveness,
+		ReadinessProbe: readiness,
+		Persistence:    helmPersistence{Enabled: true, Size: spec.StorageSize, AccessMode: "ReadWriteOnce", MountPath: DataDir},
+		AdminToken:     helmSecret{Key: AdminTokenKey},
+		Policy:         spec.Policy,
+	}
+	return encodeDocuments(values)
+}
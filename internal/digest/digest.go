@@ -0,0 +1,202 @@
+// Package digest summarises a day of ledger activity (call volumes, the riskiest methods,
+// stalled and blocked calls, detector alerts, chain verification and dropped events) and
+// sends it to notification channels on a daily schedule.
+package digest
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/slyt3/Logryph/internal/ledger/audit"
+	"github.com/slyt3/Logryph/internal/models"
+	"github.com/slyt3/Logryph/internal/notify"
+)
+
+// Period is the span a digest covers.
+const Period = 24 * time.Hour
+
+const maxTop = 5
+
+// alertTypes are the event types a digest counts as alerts.
+var alertTypes = map[string]bool{
+	"secret_leak": true, "secret_reference": true, "exfiltration_suspected": true,
+	"dangerous_command": true, "endpoint_violation": true, "slo_violation": true,
+	"ledger_tamper_alarm": true, "call_timeout": true, "integrity_warning": true,
+	"upstream_error": true,
+}
+
+// Source is the subset of the ledger a digest reads.
+type Source interface {
+	GetRunID() (string, error)
+	ForEachEvent(runID string, fn func(*models.Event) error) error
+}
+
+// Count is a method and how many events it had.
+type Count struct {
+	Method string `json:"method"`
+	Count  int    `json:"count"`
+}
+
+// Verification is the chain check made when the digest was built.
+type Verification struct {
+	Valid       bool   `json:"valid"`
+	TotalEvents int    `json:"total_events"`
+	Error       string `json:"error,omitempty"`
+}
+
+// Digest summarises one period of a run.
+type Digest struct {
+	From         time.Time      `json:"from"`
+	To           time.Time      `json:"to"`
+	RunID        string         `json:"run_id"`
+	Calls        int            `json:"calls"`
+	Responses    int            `json:"responses"`
+	TopMethods   []Count        `json:"top_methods"`
+	TopRisky     []Count        `json:"top_risky_methods"` // high and critical risk calls
+	Stalled      int            `json:"stalled"`           // calls held for approval
+	Blocked      int            `json:"blocked"`           // stalled calls rejected or expired
+	Alerts       map[string]int `json:"alerts,omitempty"`  // detector and health events by type
+	Verification *Verification  `json:"verification,omitempty"`
+	Dropped      *uint64        `json:"dropped_since_startup,omitempty"`
+}
+
+// Build summarises runID's events in [to-Period, to). With pubKey set, the whole chain is
+// verified against it.
+func Build(src Source, runID, pubKey string, to time.Time) (*Digest, error) {
+	d := &Digest{From: to.Add(-Period), To: to, RunID: runID, Alerts: map[string]int{}}
+	methods, risky := map[string]int{}, map[string]int{}
+	err := src.ForEachEvent(runID, func(e *models.Event) error {
+		if e.Timestamp.Before(d.From) || !e.Timestamp.Before(to) {
+			return nil
+		}
+		switch {
+		case e.EventType == "tool_call":
+			d.Calls++
+			method := toolName(e)
+			methods[method]++
+			if e.RiskLevel == "high" || e.RiskLevel == "critical" {
+				risky[method]++
+			}
+		case e.EventType == "tool_response":
+			d.Responses++
+		case e.EventType == "stall_resolved":
+			d.Stalled++
+			if e.Params["decision"] != "approved" {
+				d.Blocked++
+			}
+		case alertTypes[e.EventType]:
+			d.Alerts[e.EventType]++
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("reading events: %w", err)
+	}
+	d.TopMethods, d.TopRisky = top(methods), top(risky)
+	if pubKey != "" {
+		d.Verification = verify(src, runID, pubKey)
+	}
+	return d, nil
+}
+
+// toolName is the tool a tools/call invokes, or the canonical method for other calls.
+func toolName(e *models.Event) string {
+	if name, ok := e.Params["name"].(string); ok && e.Method == "tools/call" && name != "" {
+		return name
+	}
+	return e.CanonicalMethod()
+}
+
+func verify(src Source, runID, pubKey string) *Verification {
+	result, err := audit.VerifyChainWithKey(src, runID, pubKey)
+	if err != nil {
+		return &Verification{Error: err.Error()}
+	}
+	v := &Verification{Valid: result.Valid, TotalEvents: result.TotalEvents}
+	if !result.Valid {
+		v.Error = fmt.Sprintf("seq %d: %s", result.FailedAtSeq, result.ErrorMessage)
+	}
+	return v
+}
+
+// top returns the maxTop largest counts, ties broken by method.
+func top(counts map[string]int) []Count {
+	out := make([]Count, 0, len(counts))
+	for method, n := range counts {
+		out = append(out, Count{Method: method, Count: n})
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Count != out[j].Count {
+			return out[i].Count > out[j].Count
+		}
+		return out[i].Method < out[j].Method
+	})
+	if len(out) > maxTop {
+		out = out[:maxTop]
+	}
+	return out
+}
+
+// Subject is the one-line title used for email.
+func (d *Digest) Subject() string {
+	status := "chain not checked"
+	if v := d.Verification; v != nil && v.Valid {
+		status = "chain verified"
+	} else if v != nil {
+		status = "CHAIN VERIFICATION FAILED"
+	}
+	return fmt.Sprintf("Logryph digest %s: %d calls, %d blocked, %s", d.To.UTC().Format("2006-01-02"), d.Calls, d.Blocked, status)
+}
+
+// Text renders the digest for Slack and email.
+func (d *Digest) Text() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Logryph daily digest, %s to %s UTC\n", d.From.UTC().Format("2006-01-02 15:04"), d.To.UTC().Format("2006-01-02 15:04"))
+	fmt.Fprintf(&b, "Run: %s\n", d.RunID)
+	fmt.Fprintf(&b, "Calls: %d (%d responses)\n", d.Calls, d.Responses)
+	fmt.Fprintf(&b, "Approvals: %d stalled, %d blocked\n", d.Stalled, d.Blocked)
+	switch v := d.Verification; {
+	case v == nil:
+	case v.Valid:
+		fmt.Fprintf(&b, "Chain: verified (%d events)\n", v.TotalEvents)
+	default:
+		fmt.Fprintf(&b, "Chain: VERIFICATION FAILED (%s)\n", v.Error)
+	}
+	if d.Dropped != nil {
+		fmt.Fprintf(&b, "Dropped events: %d since startup\n", *d.Dropped)
+	}
+	writeCounts(&b, "Top methods", d.TopMethods)
+	writeCounts(&b, "Top risky methods", d.TopRisky)
+	if len(d.Alerts) > 0 {
+		types := make([]string, 0, len(d.Alerts))
+		for t := range d.Alerts {
+			types = append(types, t)
+		}
+		sort.Strings(types)
+		for i := 0; i < len(types); i++ {
+			types[i] = fmt.Sprintf("%s %d", types[i], d.Alerts[types[i]])
+		}
+		fmt.Fprintf(&b, "Alerts: %s\n", strings.Join(types, ", "))
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+func writeCounts(b *strings.Builder, title string, counts []Count) {
+	if len(counts) == 0 {
+		return
+	}
+	parts := make([]string, 0, len(counts))
+	for i := 0; i < len(counts); i++ {
+		parts = append(parts, fmt.Sprintf("%s (%d)", counts[i].Method, counts[i].Count))
+	}
+	fmt.Fprintf(b, "%s: %s\n", title, strings.Join(parts, ", "))
+}
+
+// Notification wraps the digest for notify channels.
+func (d *Digest) Notification() notify.Notification {
+	return notify.Notification{
+		Method: "logryph:digest", Subject: d.Subject(), Text: d.Text(), Digest: d, Timestamp: d.To,
+	}
+}
@@ -0,0 +1,91 @@
+package digest
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/slyt3/Logryph/internal/models"
+	"github.com/slyt3/Logryph/internal/notify"
+)
+
+type memLedger struct {
+	events []models.Event
+}
+
+func (l *memLedger) GetRunID() (string, error) { return "run-1", nil }
+
+func (l *memLedger) ForEachEvent(runID string, fn func(*models.Event) error) error {
+	for i := range l.events {
+		if err := fn(&l.events[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (l *memLedger) add(eventType, method, risk string, at time.Time, params map[string]interface{}) {
+	l.events = append(l.events, models.Event{EventType: eventType, Method: method, RiskLevel: risk, Timestamp: at, Params: params})
+}
+
+func TestBuild(t *testing.T) {
+	now := time.Date(2026, 3, 2, 8, 0, 0, 0, time.UTC)
+	l := &memLedger{}
+	l.add("tool_call", "stripe:refund", "high", now.Add(-48*time.Hour), nil) // before the period
+	for i := 0; i < 3; i++ {
+		l.add("tool_call", "aws:s3:GetObject", "low", now.Add(-time.Hour), nil)
+		l.add("tool_response", "", "", now.Add(-time.Hour), nil)
+	}
+	l.add("tool_call", "stripe:refund", "critical", now.Add(-2*time.Hour), nil)
+	l.add("stall_resolved", "logryph:approval", "", now.Add(-2*time.Hour), map[string]interface{}{"decision": "approved"})
+	l.add("tool_call", "stripe:refund", "high", now.Add(-3*time.Hour), nil)
+	l.add("stall_resolved", "logryph:approval", "", now.Add(-3*time.Hour), map[string]interface{}{"decision": "expired"})
+	l.add("tool_call", "tools/call", "low", now.Add(-time.Hour), map[string]interface{}{"name": "aws:s3:GetObject"})
+	l.add("secret_leak", "slack:post", "high", now.Add(-time.Minute), nil)
+
+	d, err := Build(l, "run-1", "", now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.Calls != 6 || d.Responses != 3 || d.Stalled != 2 || d.Blocked != 1 || d.Alerts["secret_leak"] != 1 {
+		t.Fatalf("unexpected counts: %+v", d)
+	}
+	if d.TopMethods[0] != (Count{"aws:s3:GetObject", 4}) || len(d.TopRisky) != 1 || d.TopRisky[0] != (Count{"stripe:refund", 2}) {
+		t.Errorf("unexpected top methods: %+v / %+v", d.TopMethods, d.TopRisky)
+	}
+	text := d.Text()
+	for _, want := range []string{"2026-03-01 08:00 to 2026-03-02 08:00 UTC", "Calls: 6 (3 responses)", "1 blocked", "stripe:refund (2)", "Alerts: secret_leak 1"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("digest text lacks %q:\n%s", want, text)
+		}
+	}
+	if !strings.Contains(d.Subject(), "chain not checked") {
+		t.Errorf("unexpected subject %q", d.Subject())
+	}
+}
+
+func TestNext(t *testing.T) {
+	now := time.Date(2026, 3, 2, 8, 0, 0, 0, time.UTC)
+	if got := next(now, 9*time.Hour); !got.Equal(now.Add(time.Hour)) {
+		t.Errorf("later today: %s", got)
+	}
+	if got := next(now, 8*time.Hour); !got.Equal(now.Add(Period)) {
+		t.Errorf("the send time itself should roll to tomorrow: %s", got)
+	}
+}
+
+func TestValidateConfig(t *testing.T) {
+	channels := map[string]notify.ChannelConfig{"mail": {Name: "mail"}}
+	if err := ValidateConfig(Config{Notify: []string{"mail"}, At: "07:30"}, channels); err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range []Config{
+		{At: "07:30"},
+		{Notify: []string{"mail"}, At: "7pm"},
+		{Notify: []string{"pager"}},
+	} {
+		if err := ValidateConfig(c, channels); err == nil {
+			t.Errorf("%+v should be rejected", c)
+		}
+	}
+}
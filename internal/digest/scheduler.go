@@ -0,0 +1,143 @@
+package digest
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/slyt3/Logryph/internal/assert"
+	"github.com/slyt3/Logryph/internal/logging"
+	"github.com/slyt3/Logryph/internal/notify"
+)
+
+const maxWaits = 1 << 30
+
+// Config is the digest section of the policy file. An empty Notify disables the digest.
+type Config struct {
+	Notify []string `yaml:"notify,omitempty"` // channels from notifications.channels
+	At     string   `yaml:"at,omitempty"`     // time of day to send, HH:MM UTC; default 00:00
+}
+
+// ValidateConfig checks the digest section against the configured notification channels.
+func ValidateConfig(c Config, channels map[string]notify.ChannelConfig) error {
+	if len(c.Notify) == 0 {
+		if c.At != "" {
+			return errors.New("at is set but notify lists no channels")
+		}
+		return nil
+	}
+	if _, err := c.offset(); err != nil {
+		return err
+	}
+	for i := 0; i < len(c.Notify); i++ {
+		if _, ok := channels[c.Notify[i]]; !ok {
+			return fmt.Errorf("unknown notification channel %q", c.Notify[i])
+		}
+	}
+	return nil
+}
+
+// offset is the send time as a duration after midnight UTC.
+func (c Config) offset() (time.Duration, error) {
+	if c.At == "" {
+		return 0, nil
+	}
+	t, err := time.Parse("15:04", c.At)
+	if err != nil {
+		return 0, fmt.Errorf("invalid at %q: want HH:MM (UTC)", c.At)
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// next returns the first send time after now.
+func next(now time.Time, offset time.Duration) time.Time {
+	day := now.UTC().Truncate(Period)
+	at := day.Add(offset)
+	if !at.After(now) {
+		at = at.Add(Period)
+	}
+	return at
+}
+
+// Scheduler sends a digest of the previous day to the configured channels once a day.
+type Scheduler struct {
+	src      Source
+	cfg      Config
+	offset   time.Duration
+	pubKey   string
+	dropped  func() uint64
+	notifier *notify.Dispatcher
+
+	stop     chan struct{}
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+// NewScheduler prepares daily digests. pubKey verifies the chain in each digest; dropped
+// reports events dropped since startup, normally from Worker.Stats.
+func NewScheduler(cfg Config, src Source, pubKey string, dropped func() uint64, notifier *notify.Dispatcher) (*Scheduler, error) {
+	if err := assert.NotNil(src, "digest source"); err != nil {
+		return nil, err
+	}
+	if notifier == nil || len(cfg.Notify) == 0 {
+		return nil, errors.New("digest needs notification channels")
+	}
+	offset, err := cfg.offset()
+	if err != nil {
+		return nil, err
+	}
+	return &Scheduler{
+		src: src, cfg: cfg, offset: offset, pubKey: pubKey, dropped: dropped, notifier: notifier,
+		stop: make(chan struct{}), done: make(chan struct{}),
+	}, nil
+}
+
+// Start sends a digest at the configured time each day until Stop.
+func (s *Scheduler) Start() {
+	go s.run()
+}
+
+func (s *Scheduler) run() {
+	defer close(s.done)
+	for i := 0; i < maxWaits; i++ {
+		timer := time.NewTimer(time.Until(next(time.Now(), s.offset)))
+		select {
+		case <-s.stop:
+			timer.Stop()
+			return
+		case now := <-timer.C:
+			if err := s.Send(now); err != nil {
+				logging.Error("digest_failed", logging.Fields{Component: "digest", Error: err.Error()})
+			}
+		}
+	}
+}
+
+// Stop ends the daily schedule.
+func (s *Scheduler) Stop() {
+	s.stopOnce.Do(func() { close(s.stop) })
+	<-s.done
+}
+
+// Send builds the digest of the day before now and queues it for the channels.
+func (s *Scheduler) Send(now time.Time) error {
+	runID, err := s.src.GetRunID()
+	if err != nil {
+		return err
+	}
+	if runID == "" {
+		return errors.New("no run to summarise")
+	}
+	d, err := Build(s.src, runID, s.pubKey, now)
+	if err != nil {
+		return err
+	}
+	if s.dropped != nil {
+		n := s.dropped()
+		d.Dropped = &n
+	}
+	s.notifier.Notify(s.cfg.Notify, d.Notification())
+	logging.Info("digest_sent", logging.Fields{Component: "digest", RunID: runID})
+	return nil
+}
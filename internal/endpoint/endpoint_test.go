@@ -0,0 +1,99 @@
+package endpoint
+
+import (
+	"testing"
+)
+
+func TestParseHost(t *testing.T) {
+	tests := []struct {
+		raw  string
+		want string
+		ip   bool
+	}{
+		{"API.Example.com.", "api.example.com", false},
+		{"example.com:8443", "example.com", false},
+		{"127.0.0.1", "127.0.0.1", true},
+		{"2130706433", "127.0.0.1", true},
+		{"0x7f.1", "127.0.0.1", true},
+		{"0177.0.0.1", "127.0.0.1", true},
+		{"[::ffff:169.254.169.254]:80", "169.254.169.254", true},
+		{"[::1]", "::1", true},
+	}
+	for _, tt := range tests {
+		h, err := ParseHost(tt.raw)
+		if err != nil {
+			t.Errorf("ParseHost(%q) failed: %v", tt.raw, err)
+			continue
+		}
+		if h.Name != tt.want || h.IsIP() != tt.ip {
+			t.Errorf("ParseHost(%q) = %q (ip=%v), want %q (ip=%v)", tt.raw, h.Name, h.IsIP(), tt.want, tt.ip)
+		}
+	}
+
+	for _, bad := range []string{"", "exa mple.com", "-bad.com", "a..b"} {
+		if _, err := ParseHost(bad); err == nil {
+			t.Errorf("ParseHost(%q) should fail", bad)
+		}
+	}
+}
+
+func TestListMatch(t *testing.T) {
+	l, err := ParseList([]string{"api.github.com", "*.example.com", "10.0.0.0/8", "192.168.1.5"})
+	if err != nil {
+		t.Fatalf("ParseList failed: %v", err)
+	}
+	cases := map[string]bool{
+		"api.github.com":   true,
+		"github.com":       false,
+		"a.example.com":    true,
+		"example.com":      false, // wildcard covers subdomains only
+		"evilexample.com":  false,
+		"10.2.3.4":         true,
+		"0x0a000001":       true, // 10.0.0.1 in hex
+		"192.168.1.5":      true,
+		"192.168.1.6":      false,
+		"10.0.0.1.nip.io":  false, // hostnames never match IP ranges
+		"api.github.com.":  true,
+		"API.GITHUB.COM":   true,
+		"api.github.com.x": false,
+	}
+	for raw, want := range cases {
+		h, err := ParseHost(raw)
+		if err != nil {
+			t.Fatalf("ParseHost(%q) failed: %v", raw, err)
+		}
+		if got := l.Match(h); got != want {
+			t.Errorf("Match(%q) = %v, want %v", raw, got, want)
+		}
+	}
+
+	if _, err := ParseList([]string{"10.0.0.0/33"}); err == nil {
+		t.Error("expected invalid CIDR to be rejected")
+	}
+}
+
+func TestExtract(t *testing.T) {
+	params := map[string]interface{}{
+		"url":     "https://allowed.test@evil.test/path",
+		"options": map[string]interface{}{"host": "internal.corp:5432"},
+		"mirrors": []interface{}{"http://evil.test\\@allowed.test/"},
+		"note":    "see docs at example dot com",
+	}
+	eps := Extract(params)
+	if len(eps) != 3 {
+		t.Fatalf("expected 3 endpoints, got %+v", eps)
+	}
+	byPath := map[string]Endpoint{}
+	for _, ep := range eps {
+		byPath[ep.Path] = ep
+	}
+	if ep := byPath["url"]; ep.Err != nil || ep.Host.Name != "evil.test" {
+		t.Errorf("userinfo must not hide the real host: %+v", ep)
+	}
+	if ep := byPath["options.host"]; ep.Err != nil || ep.Host.Name != "internal.corp" {
+		t.Errorf("bare host param not extracted: %+v", ep)
+	}
+	if ep := byPath["mirrors[0]"]; ep.Err == nil {
+		t.Errorf("backslash URL should be unparseable, got %+v", ep)
+	}
+}
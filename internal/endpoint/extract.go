@@ -0,0 +1,107 @@
+package endpoint
+
+import (
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	maxExtractDepth   = 16
+	maxExtractStrings = 4096
+	maxExtractNodes   = 1 << 16 // maps, lists and strings visited per call
+	maxEndpoints      = 64
+)
+
+// hostKeys are param names whose string value is a bare host rather than a URL.
+var hostKeys = map[string]bool{"host": true, "hostname": true, "domain": true, "server": true}
+
+// Endpoint is a destination found in tool call params.
+type Endpoint struct {
+	Path string // dotted param path
+	Host Host
+	Err  error // set when the value looked like a destination but could not be parsed
+}
+
+// Extract finds URL-valued strings (anything with a "scheme://" prefix) and bare host
+// params (host, hostname, domain, server) in params. Values that look like destinations
+// but do not parse cleanly are returned with Err set so callers can fail closed.
+func Extract(params map[string]interface{}) []Endpoint {
+	type extractFrame struct {
+		path, key string
+		value     interface{}
+		depth     int
+	}
+	var out []Endpoint
+	// Children are pushed last to first, so values are visited in key order.
+	stack := []extractFrame{{value: params}}
+	seen := 0
+	for n := 0; n < maxExtractNodes && len(stack) > 0; n++ {
+		if seen >= maxExtractStrings || len(out) >= maxEndpoints {
+			break
+		}
+		f := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if f.depth > maxExtractDepth {
+			continue
+		}
+		switch v := f.value.(type) {
+		case string:
+			seen++
+			if ep, ok := classify(f.path, f.key, v); ok {
+				out = append(out, ep)
+			}
+		case map[string]interface{}:
+			keys := make([]string, 0, len(v))
+			for k := range v {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+			for i := min(len(keys), maxExtractStrings) - 1; i >= 0; i-- {
+				child := keys[i]
+				if f.path != "" {
+					child = f.path + "." + keys[i]
+				}
+				stack = append(stack, extractFrame{child, strings.ToLower(keys[i]), v[keys[i]], f.depth + 1})
+			}
+		case []interface{}:
+			for i := min(len(v), maxExtractStrings) - 1; i >= 0; i-- {
+				stack = append(stack, extractFrame{f.path + "[" + strconv.Itoa(i) + "]", f.key, v[i], f.depth + 1})
+			}
+		}
+	}
+	return out
+}
+
+func classify(path, key, value string) (Endpoint, bool) {
+	trimmed := strings.TrimSpace(value)
+	if idx := strings.Index(trimmed, "://"); idx > 0 && !strings.ContainsAny(trimmed[:idx], " \t\n") {
+		h, err := hostFromURL(trimmed)
+		return Endpoint{Path: path, Host: h, Err: err}, true
+	}
+	if hostKeys[key] && trimmed != "" {
+		h, err := ParseHost(trimmed)
+		return Endpoint{Path: path, Host: h, Err: err}, true
+	}
+	return Endpoint{}, false
+}
+
+// hostFromURL parses a URL strictly. Backslashes and control characters are rejected
+// outright: HTTP clients disagree on how to split them, which is how allowlists get
+// bypassed (e.g. "http://evil.test\@allowed.test/").
+func hostFromURL(raw string) (Host, error) {
+	for i := 0; i < len(raw); i++ {
+		if raw[i] == '\\' || raw[i] < 0x20 || raw[i] == 0x7f {
+			return Host{}, ErrInvalidHost
+		}
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return Host{}, err
+	}
+	if u.Host == "" {
+		return Host{}, ErrEmptyHost
+	}
+	return ParseHost(u.Host)
+}
@@ -0,0 +1,123 @@
+// Package endpoint extracts network destinations from tool call params and matches them
+// against domain/IP allow and deny lists.
+//
+// Decisions are made on the literal host only; names are never resolved. A resolver answer
+// can change between our check and the upstream's connect (DNS rebinding), so an IP range
+// entry only ever matches an IP literal, and a hostname can never satisfy an IP allowlist.
+package endpoint
+
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+	"strconv"
+	"strings"
+)
+
+const (
+	maxHostLen    = 253
+	maxListSize   = 512
+	maxLabelCount = 127
+)
+
+var (
+	ErrEmptyHost   = errors.New("empty host")
+	ErrInvalidHost = errors.New("invalid host")
+)
+
+// Host is a normalized destination. IP is valid only for IP literals.
+type Host struct {
+	Name string     // lowercase, no trailing dot, no port; IP literals in canonical form
+	IP   netip.Addr // zero for domain names
+}
+
+// IsIP reports whether the host is an IP literal.
+func (h Host) IsIP() bool {
+	return h.IP.IsValid()
+}
+
+// ParseHost normalizes a bare host (optionally with port or IPv6 brackets).
+// IPv4 literals in the shorthand forms accepted by inet_aton (decimal, octal, hex,
+// fewer than four parts) are decoded so they cannot slip past an IP deny list.
+func ParseHost(raw string) (Host, error) {
+	h := strings.TrimSpace(raw)
+	if h == "" {
+		return Host{}, ErrEmptyHost
+	}
+	if strings.HasPrefix(h, "[") {
+		end := strings.Index(h, "]")
+		if end < 0 {
+			return Host{}, fmt.Errorf("%w: %q", ErrInvalidHost, raw)
+		}
+		h = h[1:end]
+	} else if strings.Count(h, ":") == 1 {
+		h = h[:strings.Index(h, ":")]
+	}
+	h = strings.TrimSuffix(strings.ToLower(h), ".")
+	if h == "" || len(h) > maxHostLen {
+		return Host{}, fmt.Errorf("%w: %q", ErrInvalidHost, raw)
+	}
+
+	if ip, err := netip.ParseAddr(strings.SplitN(h, "%", 2)[0]); err == nil {
+		ip = ip.Unmap()
+		return Host{Name: ip.String(), IP: ip}, nil
+	}
+	if ip, ok := parseLooseIPv4(h); ok {
+		return Host{Name: ip.String(), IP: ip}, nil
+	}
+	if !validHostname(h) {
+		return Host{}, fmt.Errorf("%w: %q", ErrInvalidHost, raw)
+	}
+	return Host{Name: h}, nil
+}
+
+// parseLooseIPv4 decodes inet_aton-style IPv4 forms such as 2130706433, 0x7f.1 or 0177.0.0.1.
+func parseLooseIPv4(h string) (netip.Addr, bool) {
+	parts := strings.Split(h, ".")
+	if len(parts) == 0 || len(parts) > 4 {
+		return netip.Addr{}, false
+	}
+	vals := make([]uint64, 0, 4)
+	for i := 0; i < len(parts); i++ {
+		v, err := strconv.ParseUint(parts[i], 0, 32)
+		if err != nil {
+			return netip.Addr{}, false
+		}
+		vals = append(vals, v)
+	}
+	// The last part fills the remaining bytes; earlier parts are one byte each.
+	last := vals[len(vals)-1]
+	if last >= 1<<(8*(5-len(vals))) {
+		return netip.Addr{}, false
+	}
+	var n uint64
+	for i := 0; i < len(vals)-1; i++ {
+		if vals[i] > 0xff {
+			return netip.Addr{}, false
+		}
+		n |= vals[i] << (24 - 8*i)
+	}
+	n |= last
+	return netip.AddrFrom4([4]byte{byte(n >> 24), byte(n >> 16), byte(n >> 8), byte(n)}), true
+}
+
+// validHostname accepts LDH labels (plus underscore, common in service records).
+func validHostname(h string) bool {
+	labels := strings.Split(h, ".")
+	if len(labels) > maxLabelCount {
+		return false
+	}
+	for i := 0; i < len(labels); i++ {
+		l := labels[i]
+		if l == "" || len(l) > 63 || l[0] == '-' || l[len(l)-1] == '-' {
+			return false
+		}
+		for j := 0; j < len(l); j++ {
+			c := l[j]
+			if !(c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || c == '-' || c == '_') {
+				return false
+			}
+		}
+	}
+	return true
+}
@@ -0,0 +1,89 @@
+package endpoint
+
+import (
+	"fmt"
+	"net/netip"
+	"strings"
+)
+
+// List is a compiled set of host patterns: exact domains, "*.domain" wildcards
+// (subdomains only, on a label boundary), IP literals and CIDR ranges.
+type List struct {
+	exact    map[string]bool
+	suffixes []string // ".example.com" for "*.example.com"
+	prefixes []netip.Prefix
+}
+
+// ParseList compiles list entries. Returns an error naming the first invalid entry.
+func ParseList(entries []string) (*List, error) {
+	if len(entries) > maxListSize {
+		return nil, fmt.Errorf("host list exceeds max: %d", len(entries))
+	}
+	l := &List{exact: make(map[string]bool)}
+	for i := 0; i < len(entries); i++ {
+		entry := strings.TrimSpace(strings.ToLower(entries[i]))
+		if err := l.add(entry); err != nil {
+			return nil, fmt.Errorf("host list entry %q: %w", entries[i], err)
+		}
+	}
+	return l, nil
+}
+
+func (l *List) add(entry string) error {
+	if strings.Contains(entry, "/") {
+		p, err := netip.ParsePrefix(entry)
+		if err != nil {
+			return err
+		}
+		l.prefixes = append(l.prefixes, p.Masked())
+		return nil
+	}
+	if strings.HasPrefix(entry, "*.") {
+		base, err := ParseHost(entry[2:])
+		if err != nil || base.IsIP() {
+			return ErrInvalidHost
+		}
+		l.suffixes = append(l.suffixes, "."+base.Name)
+		return nil
+	}
+	h, err := ParseHost(entry)
+	if err != nil {
+		return err
+	}
+	if h.IsIP() {
+		l.prefixes = append(l.prefixes, netip.PrefixFrom(h.IP, h.IP.BitLen()))
+		return nil
+	}
+	l.exact[h.Name] = true
+	return nil
+}
+
+// Empty reports whether the list has no entries.
+func (l *List) Empty() bool {
+	return l == nil || (len(l.exact) == 0 && len(l.suffixes) == 0 && len(l.prefixes) == 0)
+}
+
+// Match reports whether host is covered by the list. IP entries only match IP literals
+// and domain entries only match names.
+func (l *List) Match(h Host) bool {
+	if l.Empty() {
+		return false
+	}
+	if h.IsIP() {
+		for i := 0; i < len(l.prefixes) && i < maxListSize; i++ {
+			if l.prefixes[i].Contains(h.IP) {
+				return true
+			}
+		}
+		return false
+	}
+	if l.exact[h.Name] {
+		return true
+	}
+	for i := 0; i < len(l.suffixes) && i < maxListSize; i++ {
+		if strings.HasSuffix(h.Name, l.suffixes[i]) {
+			return true
+		}
+	}
+	return false
+}
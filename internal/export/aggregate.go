@@ -0,0 +1,332 @@
+package export
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/slyt3/Logryph/internal/assert"
+	"github.com/slyt3/Logryph/internal/ledger/audit"
+	"github.com/slyt3/Logryph/internal/models"
+)
+
+// AggregateVersion identifies the aggregate statistics layout.
+const AggregateVersion = "1"
+
+const (
+	maxAggregateCells   = 10000
+	maxAggregatePending = 100000
+	maxAggregateK       = 1000000
+)
+
+// LatencyBucketsMs are the upper bounds, in milliseconds, of the latency histogram
+// buckets. A last bucket counts everything slower.
+var LatencyBucketsMs = []int64{10, 50, 100, 250, 500, 1000, 2500, 5000, 10000, 30000}
+
+// AggregateOptions controls how much an aggregate export reveals.
+type AggregateOptions struct {
+	// K is the k-anonymity threshold: a count is published only when at least K distinct
+	// tasks contributed to it. Events without a task count together as one task.
+	K int
+	// Epsilon, when above zero, adds Laplace noise of scale 1/Epsilon to every published
+	// count. A count changes by one per event, so this protects single events; a task
+	// contributing many events is protected less.
+	Epsilon float64
+}
+
+// Aggregate is statistics about a run with no raw payloads, identifiers or timestamps
+// finer than the hour. Head is the chain head the statistics were computed up to, so an
+// attestation can tie them to the ledger.
+type Aggregate struct {
+	Version    string           `json:"version"`
+	RunID      string           `json:"run_id"`
+	Head       audit.Checkpoint `json:"head"`
+	From       time.Time        `json:"from"` // hour of the first event
+	To         time.Time        `json:"to"`   // hour after the last event
+	K          int              `json:"k"`
+	Epsilon    float64          `json:"epsilon,omitempty"`
+	Events     int              `json:"events"`
+	Methods    []MethodStats    `json:"methods"`
+	EventTypes map[string]int   `json:"event_types"`
+	RiskLevels map[string]int   `json:"risk_levels"` // of tool calls, "none" when no rule matched
+	Suppressed SuppressedStats  `json:"suppressed"`
+	// Verified is the exact number of events verified. It is not written out, since
+	// Events may carry noise.
+	Verified int `json:"-"`
+}
+
+// MethodStats counts the calls of one method, by canonical name, and how long the
+// upstream took to answer. Latency has one count per LatencyBucketsMs bucket plus the overflow bucket.
+type MethodStats struct {
+	Method  string `json:"method"`
+	Calls   int    `json:"calls"`
+	Blocked int    `json:"blocked"`
+	Latency []int  `json:"latency_ms_histogram"`
+}
+
+// SuppressedStats counts what was left out for coming from fewer than K tasks.
+type SuppressedStats struct {
+	Methods    int `json:"methods"`
+	Calls      int `json:"calls"`
+	EventTypes int `json:"event_types"`
+	RiskLevels int `json:"risk_levels"`
+	Uncounted  int `json:"uncounted,omitempty"` // events with keys past the limit of distinct keys
+}
+
+// cell is one count and the distinct tasks behind it, tracked up to k.
+type cell struct {
+	count int
+	tasks map[string]struct{}
+}
+
+func (c *cell) add(task string, k int) {
+	c.count++
+	if len(c.tasks) < k {
+		c.tasks[task] = struct{}{}
+	}
+}
+
+type methodCell struct {
+	cell
+	blocked int
+	latency []int
+}
+
+// aggregator accumulates the cells of a run a page at a time.
+type aggregator struct {
+	k          int
+	events     int
+	methods    map[string]*methodCell
+	eventTypes map[string]*cell
+	risk       map[string]*cell
+	pending    map[string]string // tool_call id -> method, until its response
+	overflow   int               // events past maxAggregateCells distinct keys
+	first      time.Time
+	last       time.Time
+}
+
+// BuildAggregate reads runID from src, verifying the whole chain against pubKey on the
+// way, and returns its aggregate statistics under opts.
+func BuildAggregate(src Source, runID, pubKey string, opts AggregateOptions) (*Aggregate, error) {
+	if err := assert.NotNil(src, "export source"); err != nil {
+		return nil, err
+	}
+	if runID == "" || pubKey == "" {
+		return nil, errors.New("run id and public key are required")
+	}
+	if opts.K < 1 || opts.K > maxAggregateK {
+		return nil, fmt.Errorf("k must be between 1 and %d", maxAggregateK)
+	}
+	if opts.Epsilon < 0 || math.IsNaN(opts.Epsilon) || math.IsInf(opts.Epsilon, 0) {
+		return nil, errors.New("epsilon must be a finite number, zero or above")
+	}
+	acked, err := acknowledgedGaps(src, runID)
+	if err != nil {
+		return nil, err
+	}
+	verifier := audit.NewStreamVerifier(pubKey, acked)
+	agg := &aggregator{
+		k:          opts.K,
+		methods:    make(map[string]*methodCell),
+		eventTypes: make(map[string]*cell),
+		risk:       make(map[string]*cell),
+		pending:    make(map[string]string),
+	}
+	var head *audit.Checkpoint
+	err = eachPage(src, runID, func(events []models.Event) error {
+		if !verifier.Verify(events) {
+			v := verifier.Result()
+			return fmt.Errorf("%w at seq %d: %s", ErrUnverified, v.FailedAtSeq, v.ErrorMessage)
+		}
+		for i := 0; i < len(events); i++ {
+			agg.add(&events[i])
+		}
+		last := events[len(events)-1]
+		head = &audit.Checkpoint{RunID: runID, Seq: last.SeqIndex, Hash: last.CurrentHash}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if head == nil {
+		return nil, fmt.Errorf("run %s has no events", runID)
+	}
+	var noiseErr error
+	noise := func(n int) int { return n }
+	if opts.Epsilon > 0 {
+		noise = func(n int) int {
+			x, err := laplace(1 / opts.Epsilon)
+			if err != nil && noiseErr == nil {
+				noiseErr = err
+			}
+			return max(0, int(math.Round(float64(n)+x)))
+		}
+	}
+	out := agg.publish(noise)
+	if noiseErr != nil {
+		return nil, fmt.Errorf("drawing noise: %w", noiseErr)
+	}
+	out.Version, out.RunID, out.Head, out.K, out.Epsilon = AggregateVersion, runID, *head, opts.K, opts.Epsilon
+	out.Verified = agg.events
+	return out, nil
+}
+
+func (a *aggregator) add(e *models.Event) {
+	a.events++
+	if a.first.IsZero() {
+		a.first = e.Timestamp
+	}
+	a.last = e.Timestamp
+	task := e.TaskID
+	if c := a.cellFor(a.eventTypes, e.EventType); c != nil {
+		c.add(task, a.k)
+	}
+	switch e.EventType {
+	case "tool_call":
+		method := e.CanonicalMethod()
+		if method == "" {
+			return
+		}
+		risk := e.RiskLevel
+		if risk == "" {
+			risk = "none"
+		}
+		if c := a.cellFor(a.risk, risk); c != nil {
+			c.add(task, a.k)
+		}
+		m := a.methodFor(method)
+		if m == nil {
+			return
+		}
+		m.add(task, a.k)
+		if e.WasBlocked {
+			m.blocked++
+		}
+		if len(a.pending) < maxAggregatePending {
+			a.pending[e.ID] = method
+		}
+	case "tool_response":
+		method, ok := a.pending[e.ParentID]
+		if !ok {
+			return
+		}
+		delete(a.pending, e.ParentID)
+		if ms, ok := latencyMs(e.Params["latency_ms"]); ok {
+			a.methods[method].latency[latencyBucket(ms)]++
+		}
+	}
+}
+
+func (a *aggregator) cellFor(cells map[string]*cell, key string) *cell {
+	c, ok := cells[key]
+	if !ok {
+		if len(cells) >= maxAggregateCells {
+			a.overflow++
+			return nil
+		}
+		c = &cell{tasks: make(map[string]struct{})}
+		cells[key] = c
+	}
+	return c
+}
+
+func (a *aggregator) methodFor(method string) *methodCell {
+	m, ok := a.methods[method]
+	if !ok {
+		if len(a.methods) >= maxAggregateCells {
+			a.overflow++
+			return nil
+		}
+		m = &methodCell{cell: cell{tasks: make(map[string]struct{})}, latency: make([]int, len(LatencyBucketsMs)+1)}
+		a.methods[method] = m
+	}
+	return m
+}
+
+// publish keeps the cells at least k tasks contributed to, passing every count through
+// noise, and counts the rest as suppressed.
+func (a *aggregator) publish(noise func(int) int) *Aggregate {
+	out := &Aggregate{
+		From:       a.first.UTC().Truncate(time.Hour),
+		To:         a.last.UTC().Truncate(time.Hour).Add(time.Hour),
+		Events:     noise(a.events),
+		Methods:    []MethodStats{},
+		EventTypes: make(map[string]int),
+		RiskLevels: make(map[string]int),
+	}
+	for method, m := range a.methods {
+		if len(m.tasks) < a.k {
+			out.Suppressed.Methods++
+			out.Suppressed.Calls += m.count
+			continue
+		}
+		s := MethodStats{Method: method, Calls: noise(m.count), Blocked: noise(m.blocked), Latency: make([]int, len(m.latency))}
+		for j := range m.latency {
+			s.Latency[j] = noise(m.latency[j])
+		}
+		out.Methods = append(out.Methods, s)
+	}
+	sort.Slice(out.Methods, func(x, y int) bool { return out.Methods[x].Method < out.Methods[y].Method })
+	out.Suppressed.Calls = noise(out.Suppressed.Calls)
+	if a.overflow > 0 {
+		out.Suppressed.Uncounted = noise(a.overflow)
+	}
+	out.Suppressed.EventTypes = publishCells(a.eventTypes, a.k, out.EventTypes, noise)
+	out.Suppressed.RiskLevels = publishCells(a.risk, a.k, out.RiskLevels, noise)
+	return out
+}
+
+// publishCells copies the cells with at least k tasks into dst and returns how many were
+// left out.
+func publishCells(cells map[string]*cell, k int, dst map[string]int, noise func(int) int) int {
+	suppressed := 0
+	for key, c := range cells {
+		if len(c.tasks) < k {
+			suppressed++
+			continue
+		}
+		dst[key] = noise(c.count)
+	}
+	return suppressed
+}
+
+func latencyBucket(ms int64) int {
+	for j := 0; j < len(LatencyBucketsMs); j++ {
+		if ms <= LatencyBucketsMs[j] {
+			return j
+		}
+	}
+	return len(LatencyBucketsMs)
+}
+
+// latencyMs reads latency_ms, an integer when recorded and a float64 once read back
+// from JSON.
+func latencyMs(v interface{}) (int64, bool) {
+	switch n := v.(type) {
+	case int64:
+		return n, true
+	case int:
+		return int64(n), true
+	case float64:
+		return int64(n), true
+	}
+	return 0, false
+}
+
+// laplace draws from a Laplace distribution centred on zero with the given scale.
+func laplace(scale float64) (float64, error) {
+	var b [8]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return 0, err
+	}
+	// u is uniform on (-0.5, 0.5).
+	u := (float64(binary.BigEndian.Uint64(b[:])>>11)+0.5)/(1<<53) - 0.5
+	if u < 0 {
+		return scale * math.Log(1+2*u), nil
+	}
+	return -scale * math.Log(1-2*u), nil
+}
@@ -0,0 +1,90 @@
+package export
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/slyt3/Logryph/internal/crypto"
+	"github.com/slyt3/Logryph/internal/ledger/memstore"
+	"github.com/slyt3/Logryph/internal/ledger/memstore/memstoretest"
+	"github.com/slyt3/Logryph/internal/models"
+)
+
+// newAggregateRun records, for each of five tasks, an http.get call and its response, and
+// one secret.read call only from task t0.
+func newAggregateRun(t *testing.T) (*memstore.Store, *crypto.Signer) {
+	t.Helper()
+	r := memstoretest.NewRun(t)
+	for i := 0; i < 5; i++ {
+		task := fmt.Sprintf("t%d", i)
+		r.Record(&models.Event{ID: "c" + task, EventType: "tool_call", Method: "http.get", TaskID: task, RiskLevel: "medium",
+			Params: map[string]interface{}{"url": "https://secret.example/" + task}})
+		r.Record(&models.Event{ID: "r" + task, EventType: "tool_response", TaskID: task, ParentID: "c" + task,
+			Params: map[string]interface{}{"latency_ms": int64(40 * (i + 1))}, Response: map[string]interface{}{"body": "private"}})
+	}
+	r.Record(&models.Event{ID: "s", EventType: "tool_call", Method: "secret.read", TaskID: "t0", RiskLevel: "critical", WasBlocked: true})
+	return r.Store, r.Signer
+}
+
+func TestBuildAggregateSuppressesCellsBelowK(t *testing.T) {
+	mem, signer := newAggregateRun(t)
+	agg, err := BuildAggregate(mem, "run-1", signer.GetPublicKey(), AggregateOptions{K: 3})
+	if err != nil {
+		t.Fatalf("BuildAggregate: %v", err)
+	}
+	if agg.Events != 11 || agg.Head.Seq != 10 {
+		t.Fatalf("events %d, head seq %d", agg.Events, agg.Head.Seq)
+	}
+	if len(agg.Methods) != 1 || agg.Methods[0].Method != "http.get" || agg.Methods[0].Calls != 5 {
+		t.Fatalf("methods = %+v", agg.Methods)
+	}
+	// 40 and 80ms fall in the 50ms and 100ms buckets, 120 to 200ms in the 250ms bucket.
+	if got := agg.Methods[0].Latency[:4]; fmt.Sprint(got) != "[0 1 1 3]" {
+		t.Errorf("latency histogram starts %v", got)
+	}
+	if agg.RiskLevels["medium"] != 5 || agg.RiskLevels["critical"] != 0 {
+		t.Errorf("risk levels = %v", agg.RiskLevels)
+	}
+	if agg.Suppressed.Methods != 1 || agg.Suppressed.Calls != 1 || agg.Suppressed.RiskLevels != 1 {
+		t.Errorf("suppressed = %+v", agg.Suppressed)
+	}
+	out, err := json.Marshal(agg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, leak := range []string{"secret.example", "private", "secret.read", `"t0"`} {
+		if strings.Contains(string(out), leak) {
+			t.Errorf("aggregate contains %q: %s", leak, out)
+		}
+	}
+}
+
+func TestBuildAggregateNoiseKeepsCountsNonNegative(t *testing.T) {
+	mem, signer := newAggregateRun(t)
+	agg, err := BuildAggregate(mem, "run-1", signer.GetPublicKey(), AggregateOptions{K: 5, Epsilon: 0.5})
+	if err != nil {
+		t.Fatalf("BuildAggregate: %v", err)
+	}
+	if agg.Epsilon != 0.5 || agg.Events < 0 || len(agg.Methods) != 1 {
+		t.Fatalf("aggregate = %+v", agg)
+	}
+	for _, n := range agg.Methods[0].Latency {
+		if n < 0 {
+			t.Fatalf("negative count in %v", agg.Methods[0].Latency)
+		}
+	}
+	if _, err := BuildAggregate(mem, "run-1", signer.GetPublicKey(), AggregateOptions{K: 0}); err == nil {
+		t.Error("k of 0 was accepted")
+	}
+}
+
+func TestBuildAggregateRefusesATamperedChain(t *testing.T) {
+	mem, signer := newAggregateRun(t)
+	_, err := BuildAggregate(tamperedSource{Store: mem, seq: 4}, "run-1", signer.GetPublicKey(), AggregateOptions{K: 1})
+	if !errors.Is(err, ErrUnverified) {
+		t.Fatalf("err = %v, want ErrUnverified", err)
+	}
+}
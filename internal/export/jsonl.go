@@ -0,0 +1,240 @@
+// Package export streams a run out of the ledger as JSON Lines, one event per line,
+// verifying the chain as it goes. Events are read and written a page at a time, so memory
+// use does not grow with the size of the run.
+package export
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/slyt3/Logryph/internal/assert"
+	"github.com/slyt3/Logryph/internal/ledger/audit"
+	"github.com/slyt3/Logryph/internal/models"
+)
+
+const (
+	pageSize = 1000
+	maxPages = 1 << 20
+)
+
+// ErrUnverified is returned when the chain fails verification part way through an
+// export. What was written up to then must be discarded.
+var ErrUnverified = errors.New("chain failed verification")
+
+// Source is the subset of the ledger an export reads.
+type Source interface {
+	GetEventsFrom(runID string, fromSeq uint64, limit int) ([]models.Event, error)
+	GetEventsByType(eventType string) ([]models.Event, error)
+}
+
+// Progress is reported after every page written.
+type Progress struct {
+	Events  int    // events written so far
+	LastSeq uint64 // sequence index of the last one
+}
+
+// Result describes a finished export.
+type Result struct {
+	Events           int
+	LastSeq          uint64
+	LastHash         string
+	SHA256           string // of the bytes written
+	AcknowledgedGaps []audit.Gap
+}
+
+// WriteJSONL writes every event of runID to w, one JSON object per line, verifying each
+// page against pubKey before it is written. progress, if set, is called after each page.
+func WriteJSONL(w io.Writer, src Source, runID, pubKey string, progress func(Progress)) (*Result, error) {
+	if err := assert.NotNil(src, "export source"); err != nil {
+		return nil, err
+	}
+	if runID == "" || pubKey == "" {
+		return nil, errors.New("run id and public key are required")
+	}
+	acked, err := acknowledgedGaps(src, runID)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.New()
+	buf := bufio.NewWriter(io.MultiWriter(w, sum))
+	enc := json.NewEncoder(buf)
+	verifier := audit.NewStreamVerifier(pubKey, acked)
+	res := &Result{}
+	var from uint64
+	for i := 0; i < maxPages; i++ {
+		events, err := src.GetEventsFrom(runID, from, pageSize)
+		if err != nil {
+			return nil, fmt.Errorf("reading events from seq %d: %w", from, err)
+		}
+		if len(events) == 0 {
+			break
+		}
+		if !verifier.Verify(events) {
+			v := verifier.Result()
+			return nil, fmt.Errorf("%w at seq %d: %s", ErrUnverified, v.FailedAtSeq, v.ErrorMessage)
+		}
+		if err := writePage(enc, events); err != nil {
+			return nil, err
+		}
+		last := events[len(events)-1]
+		res.Events, res.LastSeq, res.LastHash = res.Events+len(events), last.SeqIndex, last.CurrentHash
+		if progress != nil {
+			progress(Progress{Events: res.Events, LastSeq: res.LastSeq})
+		}
+		if len(events) < pageSize {
+			break
+		}
+		from = last.SeqIndex + 1
+	}
+	if res.Events == 0 {
+		return nil, fmt.Errorf("run %s has no events", runID)
+	}
+	if err := buf.Flush(); err != nil {
+		return nil, fmt.Errorf("writing export: %w", err)
+	}
+	res.SHA256 = hex.EncodeToString(sum.Sum(nil))
+	res.AcknowledgedGaps = verifier.Result().AcknowledgedGaps
+	return res, nil
+}
+
+func writePage(enc *json.Encoder, events []models.Event) error {
+	for i := 0; i < len(events); i++ {
+		if err := enc.Encode(&events[i]); err != nil {
+			return fmt.Errorf("writing event seq %d: %w", events[i].SeqIndex, err)
+		}
+	}
+	return nil
+}
+
+// acknowledgedGaps collects the run's gap acknowledgements up front, since each one is
+// stored after the gap it covers.
+func acknowledgedGaps(src Source, runID string) (map[audit.Gap]bool, error) {
+	all, err := src.GetEventsByType(audit.EventTypeGapAcknowledged)
+	if err != nil {
+		return nil, fmt.Errorf("reading gap acknowledgements: %w", err)
+	}
+	run := make([]models.Event, 0, len(all))
+	for i := 0; i < len(all); i++ {
+		if all[i].RunID == runID {
+			run = append(run, all[i])
+		}
+	}
+	return audit.AcknowledgedGaps(run), nil
+}
+
+// Stretch describes the events VerifyStream read.
+type Stretch struct {
+	RunID    string
+	Events   int
+	FirstSeq uint64
+	LastSeq  uint64
+	LastHash string
+}
+
+// VerifyStream decodes events written by WriteJSONL, or a JSON array of events, from r
+// and checks them against pubKey a page at a time, so only one page is held in memory.
+// The events must be a contiguous stretch of one run; head is the event before the first,
+// nil when the stretch is checked on its own. Gaps are accepted only when a
+// gap_acknowledged event in the stream covers them. Reading stops at the first failure,
+// so the stretch then ends there. More than max events, or input that does not decode,
+// is an error.
+func VerifyStream(r io.Reader, head *audit.Checkpoint, pubKey string, max int) (*Stretch, *audit.VerificationResult, error) {
+	dec, err := newEventDecoder(r)
+	if err != nil {
+		return nil, nil, err
+	}
+	verifier := audit.NewStreamVerifier(pubKey, nil)
+	verifier.DeferGaps()
+	if head != nil {
+		verifier.Resume(*head)
+	}
+	st := &Stretch{}
+	page := make([]models.Event, 0, pageSize)
+	for i := 0; ; i++ {
+		if i > max {
+			return nil, nil, fmt.Errorf("more than %d events", max)
+		}
+		var e models.Event
+		if more, err := dec.next(&e); err != nil {
+			return nil, nil, fmt.Errorf("decoding event %d: %w", i, err)
+		} else if !more {
+			break
+		}
+		if i == 0 {
+			st.RunID, st.FirstSeq = e.RunID, e.SeqIndex
+		} else if e.RunID != st.RunID {
+			return nil, nil, fmt.Errorf("event %s belongs to run %s, not %s", e.ID, e.RunID, st.RunID)
+		}
+		st.Events, st.LastSeq, st.LastHash = i+1, e.SeqIndex, e.CurrentHash
+		if page = append(page, e); len(page) == pageSize {
+			if !verifier.Verify(page) {
+				return st, verifier.Result(), nil
+			}
+			page = page[:0]
+		}
+	}
+	if st.Events == 0 {
+		return nil, nil, errors.New("no events")
+	}
+	verifier.Verify(page)
+	return st, verifier.Result(), nil
+}
+
+// eventDecoder reads events one at a time from JSON Lines or a JSON array.
+type eventDecoder struct {
+	dec   *json.Decoder
+	array bool
+}
+
+func newEventDecoder(r io.Reader) (*eventDecoder, error) {
+	br := bufio.NewReader(r)
+	first, err := peekNonSpace(br)
+	if err != nil {
+		return nil, err
+	}
+	d := &eventDecoder{dec: json.NewDecoder(br), array: first == '['}
+	if d.array {
+		if _, err := d.dec.Token(); err != nil {
+			return nil, fmt.Errorf("decoding events: %w", err)
+		}
+	}
+	return d, nil
+}
+
+// next decodes the next event into e, reporting false at the end of the input.
+func (d *eventDecoder) next(e *models.Event) (bool, error) {
+	if d.array && !d.dec.More() {
+		if _, err := d.dec.Token(); err != nil {
+			return false, err
+		}
+		return false, nil
+	}
+	if err := d.dec.Decode(e); errors.Is(err, io.EOF) && !d.array {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func peekNonSpace(br *bufio.Reader) (byte, error) {
+	for i := 0; i < 1<<20; i++ {
+		b, err := br.Peek(1)
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return 0, errors.New("no events")
+			}
+			return 0, err
+		}
+		if b[0] != ' ' && b[0] != '\t' && b[0] != '\r' && b[0] != '\n' {
+			return b[0], nil
+		}
+		_, _ = br.ReadByte()
+	}
+	return 0, errors.New("no events")
+}
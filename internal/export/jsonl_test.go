@@ -0,0 +1,79 @@
+package export
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/slyt3/Logryph/internal/crypto"
+	"github.com/slyt3/Logryph/internal/ledger/memstore"
+	"github.com/slyt3/Logryph/internal/ledger/memstore/memstoretest"
+	"github.com/slyt3/Logryph/internal/models"
+)
+
+// newTestRun records os.read calls, assigned round-robin to tasks when any are given.
+func newTestRun(t *testing.T, events int, tasks ...string) (*memstore.Store, *crypto.Signer) {
+	t.Helper()
+	r := memstoretest.NewRun(t)
+	r.RecordCalls(events, tasks...)
+	return r.Store, r.Signer
+}
+
+func TestWriteJSONLStreamsEveryEventInPages(t *testing.T) {
+	mem, signer := newTestRun(t, 2500)
+	var out bytes.Buffer
+	var pages []Progress
+	res, err := WriteJSONL(&out, mem, "run-1", signer.GetPublicKey(), func(p Progress) { pages = append(pages, p) })
+	if err != nil {
+		t.Fatalf("WriteJSONL: %v", err)
+	}
+	if res.Events != 2500 || res.LastSeq != 2499 || len(pages) != 3 || pages[2].Events != 2500 {
+		t.Fatalf("result %+v, progress %+v", res, pages)
+	}
+	sum := sha256.Sum256(out.Bytes())
+	if res.SHA256 != hex.EncodeToString(sum[:]) {
+		t.Error("SHA256 does not match the bytes written")
+	}
+	lines := bufio.NewScanner(&out)
+	lines.Buffer(make([]byte, 64*1024), 1<<20)
+	n := 0
+	for lines.Scan() {
+		var e models.Event
+		if err := json.Unmarshal(lines.Bytes(), &e); err != nil || e.SeqIndex != uint64(n) {
+			t.Fatalf("line %d: seq %d, %v", n, e.SeqIndex, err)
+		}
+		n++
+	}
+	if n != 2500 {
+		t.Errorf("%d lines, want 2500", n)
+	}
+}
+
+// tamperedSource changes one event's params on the way out.
+type tamperedSource struct {
+	*memstore.Store
+	seq uint64
+}
+
+func (s tamperedSource) GetEventsFrom(runID string, fromSeq uint64, limit int) ([]models.Event, error) {
+	events, err := s.Store.GetEventsFrom(runID, fromSeq, limit)
+	for i := 0; i < len(events); i++ {
+		if events[i].SeqIndex == s.seq {
+			events[i].Params = map[string]interface{}{"i": -1}
+		}
+	}
+	return events, err
+}
+
+func TestWriteJSONLRefusesATamperedChain(t *testing.T) {
+	mem, signer := newTestRun(t, 1500)
+	var out bytes.Buffer
+	_, err := WriteJSONL(&out, tamperedSource{Store: mem, seq: 1200}, "run-1", signer.GetPublicKey(), nil)
+	if !errors.Is(err, ErrUnverified) {
+		t.Fatalf("err = %v, want ErrUnverified", err)
+	}
+}
@@ -0,0 +1,334 @@
+package export
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/slyt3/Logryph/internal/assert"
+	"github.com/slyt3/Logryph/internal/crypto"
+	"github.com/slyt3/Logryph/internal/ledger/audit"
+	"github.com/slyt3/Logryph/internal/models"
+)
+
+// TaskBundleVersion identifies the task bundle layout.
+const TaskBundleVersion = "1"
+
+// TaskBundleFile is the name of the bundle inside a task evidence bag.
+const TaskBundleFile = "task.json"
+
+const (
+	maxTaskEvents = 100000
+	maxTaskLinks  = 1 << 20
+	maxBundleSize = 1 << 30
+)
+
+// Link is an event outside the task reduced to its place in the chain: enough to follow
+// the chain past it and to check the ledger key signed it, without its contents.
+type Link struct {
+	Seq       uint64 `json:"seq"`
+	PrevHash  string `json:"prev_hash"`
+	Hash      string `json:"hash"`
+	Signature string `json:"signature"`
+}
+
+// TaskBundle is one task's events with the chain context needed to verify them on their
+// own. Start is the event before the task's first (absent when the task starts at
+// genesis). Links are the other events between the task's first and last, so the segment
+// can be followed unbroken, and End is the event after the task's last, absent when that
+// is the chain head. Head is the run's head when the bundle was made. Gaps holds the
+// gap_acknowledged events for breaks inside the segment.
+type TaskBundle struct {
+	Version string            `json:"version"`
+	RunID   string            `json:"run_id"`
+	TaskID  string            `json:"task_id"`
+	PubKey  string            `json:"ledger_pub_key"`
+	Start   *audit.Checkpoint `json:"start,omitempty"`
+	End     *Link             `json:"end,omitempty"`
+	Head    audit.Checkpoint  `json:"head"`
+	Events  []models.Event    `json:"events"`
+	Links   []Link            `json:"links"`
+	Gaps    []models.Event    `json:"gap_acknowledgements,omitempty"`
+}
+
+// BuildTask reads runID from src, verifying the whole chain against pubKey on the way,
+// and returns the bundle for taskID.
+func BuildTask(src Source, runID, taskID, pubKey string) (*TaskBundle, error) {
+	if err := assert.NotNil(src, "export source"); err != nil {
+		return nil, err
+	}
+	if runID == "" || taskID == "" || pubKey == "" {
+		return nil, errors.New("run id, task id and public key are required")
+	}
+	first, last, err := taskSpan(src, runID, taskID)
+	if err != nil {
+		return nil, err
+	}
+	all, err := src.GetEventsByType(audit.EventTypeGapAcknowledged)
+	if err != nil {
+		return nil, fmt.Errorf("reading gap acknowledgements: %w", err)
+	}
+	acks := make([]models.Event, 0, len(all))
+	for i := 0; i < len(all); i++ {
+		if all[i].RunID == runID {
+			acks = append(acks, all[i])
+		}
+	}
+
+	b := &TaskBundle{Version: TaskBundleVersion, RunID: runID, TaskID: taskID, PubKey: pubKey}
+	verifier := audit.NewStreamVerifier(pubKey, audit.AcknowledgedGaps(acks))
+	var prev *audit.Checkpoint
+	err = eachPage(src, runID, func(events []models.Event) error {
+		if !verifier.Verify(events) {
+			v := verifier.Result()
+			return fmt.Errorf("%w at seq %d: %s", ErrUnverified, v.FailedAtSeq, v.ErrorMessage)
+		}
+		for i := 0; i < len(events); i++ {
+			if err := b.add(&events[i], prev, first, last); err != nil {
+				return err
+			}
+			prev = &audit.Checkpoint{RunID: runID, Seq: events[i].SeqIndex, Hash: events[i].CurrentHash}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	b.Head = *prev
+	upper := last
+	if b.End != nil {
+		upper = b.End.Seq
+	}
+	b.Gaps = gapsWithin(acks, b.Start, upper)
+	return b, nil
+}
+
+// add places one event of the run in the bundle.
+func (b *TaskBundle) add(e *models.Event, prev *audit.Checkpoint, first, last uint64) error {
+	switch {
+	case e.SeqIndex == first:
+		b.Start = prev
+		b.Events = append(b.Events, *e)
+	case e.SeqIndex > first && e.SeqIndex <= last && e.TaskID == b.TaskID:
+		if len(b.Events) >= maxTaskEvents {
+			return fmt.Errorf("task %s has more than %d events", b.TaskID, maxTaskEvents)
+		}
+		b.Events = append(b.Events, *e)
+	case e.SeqIndex > first && e.SeqIndex < last:
+		if len(b.Links) >= maxTaskLinks {
+			return fmt.Errorf("task %s spans more than %d other events", b.TaskID, maxTaskLinks)
+		}
+		b.Links = append(b.Links, linkOf(e))
+	case e.SeqIndex > last && b.End == nil:
+		end := linkOf(e)
+		b.End = &end
+	}
+	return nil
+}
+
+func linkOf(e *models.Event) Link {
+	return Link{Seq: e.SeqIndex, PrevHash: e.PrevHash, Hash: e.CurrentHash, Signature: e.Signature}
+}
+
+// taskSpan returns the sequence indexes of the task's first and last events in the run.
+func taskSpan(src Source, runID, taskID string) (first, last uint64, err error) {
+	found := false
+	err = eachPage(src, runID, func(events []models.Event) error {
+		for i := 0; i < len(events); i++ {
+			if events[i].TaskID != taskID {
+				continue
+			}
+			if !found {
+				first, found = events[i].SeqIndex, true
+			}
+			last = events[i].SeqIndex
+		}
+		return nil
+	})
+	if err == nil && !found {
+		err = fmt.Errorf("task %s has no events in run %s", taskID, runID)
+	}
+	return first, last, err
+}
+
+// eachPage calls fn with every page of the run's events, in order.
+func eachPage(src Source, runID string, fn func([]models.Event) error) error {
+	var from uint64
+	for i := 0; i < maxPages; i++ {
+		events, err := src.GetEventsFrom(runID, from, pageSize)
+		if err != nil {
+			return fmt.Errorf("reading events from seq %d: %w", from, err)
+		}
+		if len(events) == 0 {
+			return nil
+		}
+		if err := fn(events); err != nil {
+			return err
+		}
+		if len(events) < pageSize {
+			return nil
+		}
+		from = events[len(events)-1].SeqIndex + 1
+	}
+	return nil
+}
+
+// gapsWithin returns the acknowledgements of gaps that open after start and by upper.
+func gapsWithin(acks []models.Event, start *audit.Checkpoint, upper uint64) []models.Event {
+	var from uint64
+	if start != nil {
+		from = start.Seq
+	}
+	var out []models.Event
+	for i := 0; i < len(acks); i++ {
+		for gap := range audit.AcknowledgedGaps(acks[i : i+1]) {
+			if gap.From > from && gap.From <= upper {
+				out = append(out, acks[i])
+				break
+			}
+		}
+	}
+	return out
+}
+
+// VerifyTask checks a bundle on its own: every event's hash and signature, every link's
+// signature, and that the events and links chain unbroken from Start to End (or to
+// Head). With pinnedKey set, the bundle must be signed by that key; otherwise the
+// embedded key is trusted and should be compared with one obtained out of band.
+// A bundle cannot show that the exporter left none of the task's events out as links.
+func VerifyTask(b *TaskBundle, pinnedKey string) error {
+	if err := assert.NotNil(b, "task bundle"); err != nil {
+		return err
+	}
+	if b.Version != TaskBundleVersion {
+		return fmt.Errorf("unsupported task bundle version %q", b.Version)
+	}
+	if pinnedKey != "" && !strings.EqualFold(pinnedKey, b.PubKey) {
+		return fmt.Errorf("signed by %s, expected %s", b.PubKey, pinnedKey)
+	}
+	if len(b.Events) == 0 {
+		return errors.New("bundle has no events")
+	}
+	for i := 0; i < len(b.Gaps); i++ {
+		if b.Gaps[i].RunID != b.RunID {
+			return fmt.Errorf("gap acknowledgement %s belongs to run %s", b.Gaps[i].ID, b.Gaps[i].RunID)
+		}
+		if err := audit.VerifyEventWithKey(&b.Gaps[i], b.PubKey); err != nil {
+			return fmt.Errorf("gap acknowledgement %s: %w", b.Gaps[i].ID, err)
+		}
+	}
+	chain, err := b.chain()
+	if err != nil {
+		return err
+	}
+	if b.End != nil {
+		chain = append(chain, *b.End)
+	}
+	acked := audit.AcknowledgedGaps(b.Gaps)
+	prev := b.Start
+	for i := 0; i < len(chain); i++ {
+		cur := chain[i]
+		if prev == nil && cur.Seq != 0 {
+			return fmt.Errorf("seq %d: no starting bound", cur.Seq)
+		}
+		if prev != nil && cur.PrevHash != prev.Hash {
+			gap := audit.Gap{From: prev.Seq + 1, To: cur.Seq - 1}
+			if cur.Seq <= gap.From || !acked[gap] {
+				return fmt.Errorf("seq %d does not link to seq %d", cur.Seq, prev.Seq)
+			}
+		}
+		prev = &audit.Checkpoint{Seq: cur.Seq, Hash: cur.Hash}
+	}
+	if b.End == nil && (prev.Seq != b.Head.Seq || prev.Hash != b.Head.Hash) {
+		return errors.New("bundle has no end bound and does not reach the head")
+	}
+	if b.End != nil && b.End.Seq > b.Head.Seq {
+		return errors.New("end bound is past the head")
+	}
+	return nil
+}
+
+// chain merges the events and links into one sequence, verifying each as it goes.
+func (b *TaskBundle) chain() ([]Link, error) {
+	out := make([]Link, 0, len(b.Events)+len(b.Links))
+	ei, li := 0, 0
+	for ei < len(b.Events) || li < len(b.Links) {
+		var next Link
+		if li >= len(b.Links) || (ei < len(b.Events) && b.Events[ei].SeqIndex < b.Links[li].Seq) {
+			e := &b.Events[ei]
+			if e.TaskID != b.TaskID || e.RunID != b.RunID {
+				return nil, fmt.Errorf("event %s belongs to task %q of run %s", e.ID, e.TaskID, e.RunID)
+			}
+			if err := audit.VerifyEventWithKey(e, b.PubKey); err != nil {
+				return nil, fmt.Errorf("event %s (seq %d): %w", e.ID, e.SeqIndex, err)
+			}
+			next, ei = linkOf(e), ei+1
+		} else {
+			next, li = b.Links[li], li+1
+			if !crypto.VerifyWithPublicKey(b.PubKey, next.Hash, next.Signature) {
+				return nil, fmt.Errorf("link seq %d: invalid signature", next.Seq)
+			}
+		}
+		if len(out) > 0 && next.Seq <= out[len(out)-1].Seq {
+			return nil, fmt.Errorf("seq %d is out of order", next.Seq)
+		}
+		out = append(out, next)
+	}
+	if b.End != nil && !crypto.VerifyWithPublicKey(b.PubKey, b.End.Hash, b.End.Signature) {
+		return nil, fmt.Errorf("end bound seq %d: invalid signature", b.End.Seq)
+	}
+	return out, nil
+}
+
+// ReadTaskBundle loads a bundle from a task export: a task evidence bag (ZIP) or the
+// bundle JSON itself.
+func ReadTaskBundle(path string) (*TaskBundle, error) {
+	var data []byte
+	zr, err := zip.OpenReader(path)
+	if err == nil {
+		defer func() { _ = zr.Close() }()
+		if data, err = readBundleFile(&zr.Reader); err != nil {
+			return nil, fmt.Errorf("%s is not a task export: %w", path, err)
+		}
+	} else if data, err = os.ReadFile(path); err != nil {
+		return nil, err
+	}
+	return parseTaskBundle(data)
+}
+
+// DecodeTaskBundle is ReadTaskBundle for an export already in memory.
+func DecodeTaskBundle(data []byte) (*TaskBundle, error) {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err == nil {
+		if data, err = readBundleFile(zr); err != nil {
+			return nil, fmt.Errorf("not a task export: %w", err)
+		}
+	}
+	return parseTaskBundle(data)
+}
+
+func readBundleFile(zr *zip.Reader) ([]byte, error) {
+	f, err := zr.Open(TaskBundleFile)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = f.Close() }()
+	data, err := io.ReadAll(io.LimitReader(f, maxBundleSize))
+	if err != nil {
+		return nil, fmt.Errorf("reading %s: %w", TaskBundleFile, err)
+	}
+	return data, nil
+}
+
+func parseTaskBundle(data []byte) (*TaskBundle, error) {
+	var b TaskBundle
+	if err := json.Unmarshal(data, &b); err != nil {
+		return nil, fmt.Errorf("parsing task bundle: %w", err)
+	}
+	return &b, nil
+}
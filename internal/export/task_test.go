@@ -0,0 +1,60 @@
+package export
+
+import "testing"
+
+func TestBuildTaskKeepsOnlyTheTaskAndItsChainContext(t *testing.T) {
+	mem, signer := newTestRun(t, 30, "a", "b", "c")
+	b, err := BuildTask(mem, "run-1", "b", signer.GetPublicKey())
+	if err != nil {
+		t.Fatalf("BuildTask: %v", err)
+	}
+	// Task b is seq 1, 4, ..., 28: ten events, with the 18 other events between them as links.
+	if len(b.Events) != 10 || len(b.Links) != 18 {
+		t.Fatalf("%d events and %d links, want 10 and 18", len(b.Events), len(b.Links))
+	}
+	if b.Start == nil || b.Start.Seq != 0 || b.End == nil || b.End.Seq != 29 || b.Head.Seq != 29 {
+		t.Fatalf("bounds start %+v, end %+v, head %+v", b.Start, b.End, b.Head)
+	}
+	if err := VerifyTask(b, signer.GetPublicKey()); err != nil {
+		t.Fatalf("VerifyTask: %v", err)
+	}
+
+	last, err := BuildTask(mem, "run-1", "c", signer.GetPublicKey())
+	if err != nil {
+		t.Fatalf("BuildTask: %v", err)
+	}
+	if last.End != nil || VerifyTask(last, "") != nil {
+		t.Errorf("a task ending at the head needs no end bound and should verify")
+	}
+	if _, err := BuildTask(mem, "run-1", "missing", signer.GetPublicKey()); err == nil {
+		t.Error("expected an error for a task with no events")
+	}
+}
+
+func TestVerifyTaskRejectsTampering(t *testing.T) {
+	mem, signer := newTestRun(t, 12, "a", "b")
+	build := func() *TaskBundle {
+		b, err := BuildTask(mem, "run-1", "a", signer.GetPublicKey())
+		if err != nil {
+			t.Fatalf("BuildTask: %v", err)
+		}
+		return b
+	}
+	cases := map[string]func(*TaskBundle){
+		"edited event":  func(b *TaskBundle) { b.Events[1].Params = map[string]interface{}{"i": 99} },
+		"dropped link":  func(b *TaskBundle) { b.Links = append(b.Links[:1], b.Links[2:]...) },
+		"dropped event": func(b *TaskBundle) { b.Events = append(b.Events[:1], b.Events[2:]...) },
+		"forged link":   func(b *TaskBundle) { b.Links[0].Hash = b.Links[1].Hash },
+		"other task":    func(b *TaskBundle) { b.TaskID = "b" },
+	}
+	for name, tamper := range cases {
+		b := build()
+		tamper(b)
+		if err := VerifyTask(b, ""); err == nil {
+			t.Errorf("%s: expected verification to fail", name)
+		}
+	}
+	if err := VerifyTask(build(), "00"+signer.GetPublicKey()[2:]); err == nil {
+		t.Error("expected a pinned key mismatch to fail")
+	}
+}
@@ -0,0 +1,131 @@
+package federation
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/slyt3/Logryph/internal/ledger"
+	"github.com/slyt3/Logryph/internal/ledger/store"
+	"github.com/slyt3/Logryph/internal/models"
+)
+
+// writeLedger records one tool_call per trace, at the given time, and returns the ledger's
+// path and public key.
+func writeLedger(t *testing.T, dir, name string, traces map[string]time.Time) (string, string) {
+	t.Helper()
+	path := filepath.Join(dir, name+".db")
+	db, err := store.NewDB(path)
+	if err != nil {
+		t.Fatalf("store: %v", err)
+	}
+	defer db.Close()
+	worker, err := ledger.NewWorker(16, db, filepath.Join(dir, name+".key"))
+	if err != nil {
+		t.Fatalf("worker: %v", err)
+	}
+	if err := worker.Start(); err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	n := 0
+	for traceID, ts := range traces {
+		n++
+		worker.Submit(&models.Event{
+			ID: fmt.Sprintf("%s-%d", name, n), Timestamp: ts, EventType: "tool_call",
+			Method: "agent:call", TraceID: traceID, Params: map[string]interface{}{},
+		})
+	}
+	pubKey := worker.GetSigner().GetPublicKey()
+	if err := worker.Shutdown(2 * time.Second); err != nil {
+		t.Fatalf("shutdown: %v", err)
+	}
+	return path, pubKey
+}
+
+func writeManifest(t *testing.T, dir, body string) *Manifest {
+	t.Helper()
+	path := filepath.Join(dir, "federation.yaml")
+	if err := os.WriteFile(path, []byte(body), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	m, err := LoadManifest(path)
+	if err != nil {
+		t.Fatalf("manifest: %v", err)
+	}
+	return m
+}
+
+func TestVerifyFederation(t *testing.T) {
+	dir := t.TempDir()
+	t0 := time.Now().Add(-time.Hour)
+	const shared, early = "4bf92f3577b34da6a3ce929d0e0e4736", "0af7651916cd43dd8448eb211c80319c"
+	_, parentKey := writeLedger(t, dir, "orchestrator", map[string]time.Time{shared: t0, early: t0})
+	writeLedger(t, dir, "billing", map[string]time.Time{shared: t0.Add(time.Second), early: t0.Add(-time.Minute)})
+
+	m := writeManifest(t, dir, `
+ledgers:
+  - name: orchestrator
+    path: orchestrator.db
+    public_key: `+parentKey+`
+  - name: billing
+    path: billing.db
+links:
+  - from: orchestrator
+    to: billing
+`)
+	report, err := Verify(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, l := range report.Ledgers {
+		if !l.Valid || l.Events != 3 {
+			t.Errorf("ledger %s: %+v", l.Name, l)
+		}
+	}
+	if !report.Ledgers[0].Pinned || report.Ledgers[1].Pinned {
+		t.Errorf("pinning not reported: %+v", report.Ledgers)
+	}
+	link := report.Links[0]
+	if link.SharedTraces != 2 || len(link.Problems) != 1 || !strings.Contains(link.Problems[0], early) {
+		t.Errorf("expected the early trace to be flagged: %+v", link)
+	}
+	if report.Valid() {
+		t.Error("a sub-agent trace that predates its parent must fail the federation")
+	}
+}
+
+func TestVerifyFederationPinnedKey(t *testing.T) {
+	dir := t.TempDir()
+	writeLedger(t, dir, "a", map[string]time.Time{"4bf92f3577b34da6a3ce929d0e0e4736": time.Now()})
+	_, otherKey := writeLedger(t, dir, "b", nil)
+
+	m := writeManifest(t, dir, "ledgers:\n  - {name: a, path: a.db, public_key: "+otherKey+"}\n  - {name: b, path: b.db}\n")
+	report, err := Verify(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Ledgers[0].Valid || !report.Ledgers[1].Valid || report.Valid() {
+		t.Errorf("a ledger signed by another key must fail: %+v", report.Ledgers)
+	}
+}
+
+func TestLoadManifestRejects(t *testing.T) {
+	dir := t.TempDir()
+	for _, body := range []string{
+		"ledgers:\n  - {name: a, path: a.db}\n",
+		"ledgers:\n  - {name: a, path: a.db}\n  - {name: a, path: b.db}\n",
+		"ledgers:\n  - {name: a, path: a.db}\n  - {name: b, path: b.db}\nlinks:\n  - {from: a, to: c}\n",
+		"ledgers:\n  - {name: a, path: a.db}\n  - {name: b, path: b.db}\nmax_skew: soon\n",
+	} {
+		path := filepath.Join(dir, "federation.yaml")
+		if err := os.WriteFile(path, []byte(body), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := LoadManifest(path); err == nil {
+			t.Errorf("manifest should be rejected:\n%s", body)
+		}
+	}
+}
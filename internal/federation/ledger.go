@@ -0,0 +1,82 @@
+package federation
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/slyt3/Logryph/internal/ledger/store"
+)
+
+// Open opens a ledger database, or the one inside an evidence bag written by logyctl
+// export. The returned function closes it and removes any extracted copy.
+func Open(path string) (*store.DB, func() error, error) {
+	if _, err := os.Stat(path); err != nil {
+		return nil, nil, err
+	}
+	if !strings.EqualFold(filepath.Ext(path), ".zip") {
+		db, err := store.NewDB(path)
+		if err != nil {
+			return nil, nil, fmt.Errorf("opening ledger: %w", err)
+		}
+		return db, db.Close, nil
+	}
+
+	dir, err := os.MkdirTemp("", "logryph-federation-*")
+	if err != nil {
+		return nil, nil, err
+	}
+	dbPath := filepath.Join(dir, "logryph.db")
+	if err := extractLedger(path, dbPath); err != nil {
+		_ = os.RemoveAll(dir)
+		return nil, nil, err
+	}
+	db, err := store.NewDB(dbPath)
+	if err != nil {
+		_ = os.RemoveAll(dir)
+		return nil, nil, fmt.Errorf("opening ledger: %w", err)
+	}
+	closeFn := func() error {
+		err := db.Close()
+		if rmErr := os.RemoveAll(dir); err == nil {
+			err = rmErr
+		}
+		return err
+	}
+	return db, closeFn, nil
+}
+
+// extractLedger copies the ledger database out of an evidence bag.
+func extractLedger(zipPath, dest string) (err error) {
+	r, err := zip.OpenReader(zipPath)
+	if err != nil {
+		return fmt.Errorf("opening evidence bag: %w", err)
+	}
+	defer func() {
+		if closeErr := r.Close(); closeErr != nil && err == nil {
+			err = fmt.Errorf("closing evidence bag: %w", closeErr)
+		}
+	}()
+
+	src, err := r.Open("logryph.db")
+	if err != nil {
+		return fmt.Errorf("evidence bag has no logryph.db: %w", err)
+	}
+	defer func() {
+		if closeErr := src.Close(); closeErr != nil && err == nil {
+			err = fmt.Errorf("closing logryph.db: %w", closeErr)
+		}
+	}()
+	out, err := os.OpenFile(dest, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, src); err != nil {
+		_ = out.Close()
+		return fmt.Errorf("extracting logryph.db: %w", err)
+	}
+	return out.Close()
+}
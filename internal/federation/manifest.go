@@ -0,0 +1,102 @@
+// Package federation checks the ledgers of several Logryph instances together, such as
+// an orchestrating agent's proxy and the proxies of the sub-agents it calls.
+package federation
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	defaultMaxSkew = 5 * time.Second
+	maxMembers     = 64
+	maxLinks       = 256
+)
+
+// Manifest lists the ledgers of a federation and the links between them.
+type Manifest struct {
+	Ledgers []Member `yaml:"ledgers"`
+	Links   []Link   `yaml:"links,omitempty"`
+	// MaxSkew is the clock difference tolerated between instances, e.g. "2s" (default 5s).
+	MaxSkew string `yaml:"max_skew,omitempty"`
+
+	skew time.Duration
+}
+
+// Member is one instance's ledger: a logryph.db file or an evidence bag from logyctl
+// export. PublicKey pins the instance's ledger key; without it, the key the ledger
+// records for itself is used.
+type Member struct {
+	Name      string `yaml:"name"`
+	Path      string `yaml:"path"`
+	PublicKey string `yaml:"public_key,omitempty"`
+}
+
+// Link declares that agents behind From call agents behind To, so traces started in From
+// continue in To.
+type Link struct {
+	From string `yaml:"from"`
+	To   string `yaml:"to"`
+}
+
+// LoadManifest reads and validates a federation manifest. Relative ledger paths are
+// resolved against the manifest's directory.
+func LoadManifest(path string) (*Manifest, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading manifest: %w", err)
+	}
+	var m Manifest
+	if err := yaml.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("parsing manifest: %w", err)
+	}
+	if err := m.validate(); err != nil {
+		return nil, err
+	}
+	for i := 0; i < len(m.Ledgers); i++ {
+		if !filepath.IsAbs(m.Ledgers[i].Path) {
+			m.Ledgers[i].Path = filepath.Join(filepath.Dir(path), m.Ledgers[i].Path)
+		}
+	}
+	return &m, nil
+}
+
+func (m *Manifest) validate() error {
+	if len(m.Ledgers) < 2 || len(m.Ledgers) > maxMembers {
+		return fmt.Errorf("a federation needs between 2 and %d ledgers, got %d", maxMembers, len(m.Ledgers))
+	}
+	if len(m.Links) > maxLinks {
+		return fmt.Errorf("too many links: %d (max %d)", len(m.Links), maxLinks)
+	}
+	names := make(map[string]bool, len(m.Ledgers))
+	for _, l := range m.Ledgers {
+		if l.Name == "" || l.Path == "" {
+			return fmt.Errorf("every ledger needs a name and a path")
+		}
+		if names[l.Name] {
+			return fmt.Errorf("duplicate ledger name %q", l.Name)
+		}
+		names[l.Name] = true
+	}
+	for _, link := range m.Links {
+		if !names[link.From] || !names[link.To] {
+			return fmt.Errorf("link %s -> %s names an unknown ledger", link.From, link.To)
+		}
+		if link.From == link.To {
+			return fmt.Errorf("link %s -> %s points at itself", link.From, link.To)
+		}
+	}
+	m.skew = defaultMaxSkew
+	if m.MaxSkew != "" {
+		d, err := time.ParseDuration(m.MaxSkew)
+		if err != nil || d < 0 {
+			return fmt.Errorf("invalid max_skew %q", m.MaxSkew)
+		}
+		m.skew = d
+	}
+	return nil
+}
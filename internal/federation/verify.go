@@ -0,0 +1,143 @@
+package federation
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/slyt3/Logryph/internal/assert"
+	"github.com/slyt3/Logryph/internal/ledger/audit"
+)
+
+// LedgerResult is the chain verdict for one member of the federation.
+type LedgerResult struct {
+	Name   string
+	RunID  string
+	PubKey string
+	Pinned bool // PubKey came from the manifest rather than the ledger itself
+	Events int
+	Valid  bool
+	Error  string
+}
+
+// LinkResult is the verdict for one declared link.
+type LinkResult struct {
+	From         string
+	To           string
+	SharedTraces int      // traces recorded by both ledgers
+	Problems     []string // cross-link inconsistencies; empty when the link checks out
+}
+
+// Report is the combined verdict for a federation.
+type Report struct {
+	Ledgers []LedgerResult
+	Links   []LinkResult
+}
+
+// Valid reports whether every chain verified and every link is consistent.
+func (r *Report) Valid() bool {
+	for _, l := range r.Ledgers {
+		if !l.Valid {
+			return false
+		}
+	}
+	for _, l := range r.Links {
+		if len(l.Problems) > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// Verify validates each ledger's chain under its pinned or recorded key, then checks every
+// link: a trace the downstream ledger shares with the upstream one must not start there
+// earlier than the upstream instance started it, beyond max_skew.
+func Verify(m *Manifest) (*Report, error) {
+	if err := assert.NotNil(m, "manifest"); err != nil {
+		return nil, err
+	}
+	report := &Report{}
+	traceStarts := make(map[string]map[string]time.Time, len(m.Ledgers))
+	for _, member := range m.Ledgers {
+		result, starts := verifyMember(member)
+		report.Ledgers = append(report.Ledgers, result)
+		if result.Valid {
+			traceStarts[member.Name] = starts
+		}
+	}
+	for _, link := range m.Links {
+		report.Links = append(report.Links, checkLink(link, traceStarts, m.skew))
+	}
+	return report, nil
+}
+
+// verifyMember verifies one ledger's latest run and returns when each trace started in it.
+func verifyMember(member Member) (LedgerResult, map[string]time.Time) {
+	result := LedgerResult{Name: member.Name, PubKey: member.PublicKey, Pinned: member.PublicKey != ""}
+	fail := func(format string, args ...interface{}) (LedgerResult, map[string]time.Time) {
+		result.Error = fmt.Sprintf(format, args...)
+		return result, nil
+	}
+	db, closeFn, err := Open(member.Path)
+	if err != nil {
+		return fail("%v", err)
+	}
+	defer func() { _ = closeFn() }()
+
+	if result.RunID, err = db.GetRunID(); err != nil || result.RunID == "" {
+		return fail("no run found in ledger (%v)", err)
+	}
+	_, _, recorded, err := db.GetRunInfo(result.RunID)
+	if err != nil {
+		return fail("%v", err)
+	}
+	if !result.Pinned {
+		result.PubKey = recorded
+	}
+	chain, err := audit.VerifyChainWithKey(db, result.RunID, result.PubKey)
+	if err != nil {
+		return fail("%v", err)
+	}
+	result.Events = chain.TotalEvents
+	if !chain.Valid {
+		return fail("%s", chain.ErrorMessage)
+	}
+
+	events, err := db.GetAllEvents(result.RunID)
+	if err != nil {
+		return fail("%v", err)
+	}
+	starts := make(map[string]time.Time)
+	for i := 0; i < len(events); i++ {
+		e := events[i]
+		if first, ok := starts[e.TraceID]; e.TraceID != "" && (!ok || e.Timestamp.Before(first)) {
+			starts[e.TraceID] = e.Timestamp
+		}
+	}
+	result.Valid = true
+	return result, starts
+}
+
+// checkLink compares the traces two linked ledgers share.
+func checkLink(link Link, traceStarts map[string]map[string]time.Time, skew time.Duration) LinkResult {
+	result := LinkResult{From: link.From, To: link.To}
+	from, fromOK := traceStarts[link.From]
+	to, toOK := traceStarts[link.To]
+	if !fromOK || !toOK {
+		result.Problems = append(result.Problems, "cannot be checked: a ledger failed verification")
+		return result
+	}
+	for traceID, childStart := range to {
+		parentStart, ok := from[traceID]
+		if !ok {
+			continue
+		}
+		result.SharedTraces++
+		if lead := parentStart.Sub(childStart); lead > skew {
+			result.Problems = append(result.Problems, fmt.Sprintf(
+				"trace %s starts in %s %v before %s started it", traceID, link.To, lead.Truncate(time.Millisecond), link.From))
+		}
+	}
+	sort.Strings(result.Problems)
+	return result
+}
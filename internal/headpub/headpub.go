@@ -0,0 +1,139 @@
+// Package headpub publishes the ledger's chain head where anyone can watch it: at
+// /.well-known/logryph-head on the admin listener, and optionally in a DNS TXT record.
+// Each published head is signed by the ledger key. A monitor that polls it and sees the
+// sequence go backwards, or the hash change at a sequence it has already seen, has caught
+// the ledger being rewritten rather than appended to.
+package headpub
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/slyt3/Logryph/internal/canary"
+)
+
+// WellKnownPath is where the admin listener serves the latest head.
+const WellKnownPath = "/.well-known/logryph-head"
+
+// DNS providers.
+const (
+	ProviderCloudflare = "cloudflare"
+)
+
+const (
+	defaultInterval      = time.Minute
+	minInterval          = 5 * time.Second
+	defaultCloudflareAPI = "https://api.cloudflare.com/client/v4"
+	defaultTTL           = 60
+	maxTTL               = 86400
+)
+
+var zoneIDPattern = regexp.MustCompile(`^[A-Za-z0-9]{1,64}$`)
+
+// Config is the head_publication section of the policy file. Nothing is published
+// unless well_known is set or a DNS provider is named.
+type Config struct {
+	WellKnown bool      `yaml:"well_known,omitempty"` // serve WellKnownPath on the admin listener
+	Interval  string    `yaml:"interval,omitempty"`   // how often the head is refreshed; default 1m
+	DNS       DNSConfig `yaml:"dns,omitempty"`
+}
+
+// DNSConfig names a TXT record kept at the latest head. Only Cloudflare's API is
+// supported; api_token is best given as a secret reference such as ${env:CF_API_TOKEN}.
+type DNSConfig struct {
+	Provider string `yaml:"provider,omitempty"` // cloudflare; empty disables DNS publication
+	ZoneID   string `yaml:"zone_id,omitempty"`
+	Record   string `yaml:"record,omitempty"` // fully qualified name, e.g. _logryph-head.example.com
+	APIToken string `yaml:"api_token,omitempty"`
+	APIURL   string `yaml:"api_url,omitempty"` // default https://api.cloudflare.com/client/v4
+	TTL      int    `yaml:"ttl,omitempty"`     // seconds; default 60
+}
+
+// Enabled reports whether anything is published.
+func (c Config) Enabled() bool {
+	return c.WellKnown || c.DNS.Provider != ""
+}
+
+// ValidateConfig checks the head_publication section. A disabled section is always valid.
+func ValidateConfig(c Config) error {
+	if !c.Enabled() {
+		return nil
+	}
+	if _, err := c.interval(); err != nil {
+		return err
+	}
+	d := c.DNS
+	if d.Provider == "" {
+		return nil
+	}
+	if d.Provider != ProviderCloudflare {
+		return fmt.Errorf("dns.provider %q: must be %q", d.Provider, ProviderCloudflare)
+	}
+	if !zoneIDPattern.MatchString(d.ZoneID) {
+		return errors.New("dns.zone_id is required")
+	}
+	if d.Record == "" || strings.ContainsAny(d.Record, " /") {
+		return errors.New("dns.record must be a domain name")
+	}
+	if d.APIToken == "" {
+		return errors.New("dns.api_token is required")
+	}
+	if d.APIURL != "" {
+		u, err := url.Parse(d.APIURL)
+		if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+			return errors.New("dns.api_url must be an absolute http(s) URL")
+		}
+	}
+	if d.TTL < 0 || d.TTL > maxTTL {
+		return fmt.Errorf("dns.ttl must be between 1 and %d seconds", maxTTL)
+	}
+	return nil
+}
+
+func (c Config) interval() (time.Duration, error) {
+	if c.Interval == "" {
+		return defaultInterval, nil
+	}
+	d, err := time.ParseDuration(c.Interval)
+	if err != nil || d < minInterval {
+		return 0, fmt.Errorf("invalid interval %q: must be a duration of at least %s", c.Interval, minInterval)
+	}
+	return d, nil
+}
+
+// TXT is the TXT record content for a head: space-separated key=value pairs, with the
+// signature over canary.Entry.Digest as in the canary file.
+func TXT(e *canary.Entry) string {
+	return fmt.Sprintf("v=logryph1 run=%s seq=%d hash=%s ts=%s key=%s sig=%s",
+		e.RunID, e.Seq, e.Hash, e.Timestamp.UTC().Format(time.RFC3339Nano), e.PubKey, e.Signature)
+}
+
+// ParseTXT reads a head back from TXT record content, for monitors. A TXT record longer
+// than 255 bytes is served as several strings, which must be joined first.
+func ParseTXT(s string) (*canary.Entry, error) {
+	fields := map[string]string{}
+	for _, f := range strings.Fields(s) {
+		k, v, ok := strings.Cut(f, "=")
+		if !ok {
+			return nil, fmt.Errorf("malformed field %q", f)
+		}
+		fields[k] = v
+	}
+	if fields["v"] != "logryph1" {
+		return nil, errors.New("not a logryph1 head record")
+	}
+	e := &canary.Entry{RunID: fields["run"], Hash: fields["hash"], PubKey: fields["key"], Signature: fields["sig"]}
+	if _, err := fmt.Sscanf(fields["seq"], "%d", &e.Seq); err != nil {
+		return nil, fmt.Errorf("seq: %w", err)
+	}
+	ts, err := time.Parse(time.RFC3339Nano, fields["ts"])
+	if err != nil {
+		return nil, fmt.Errorf("ts: %w", err)
+	}
+	e.Timestamp = ts
+	return e, nil
+}
@@ -0,0 +1,161 @@
+package headpub
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/slyt3/Logryph/internal/canary"
+	"github.com/slyt3/Logryph/internal/crypto"
+	"github.com/slyt3/Logryph/internal/models"
+)
+
+// chain is a ledger whose head the test moves by hand.
+type chain struct {
+	seq  uint64
+	hash string
+}
+
+func (c *chain) GetRunID() (string, error) { return "run-1", nil }
+func (c *chain) GetRunInfo(string) (string, string, string, error) {
+	return "", "", "", nil
+}
+func (c *chain) GetLastEvent(string) (uint64, string, error) { return c.seq, c.hash, nil }
+func (c *chain) GetEventsFrom(string, uint64, int) ([]models.Event, error) {
+	return nil, nil
+}
+
+// fakeCloudflare serves the DNS records API for one zone.
+type fakeCloudflare struct {
+	mu      sync.Mutex
+	records map[string]cloudflareRecord
+	writes  int
+}
+
+func (f *fakeCloudflare) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if r.Header.Get("Authorization") != "Bearer cf-token" {
+		w.WriteHeader(http.StatusForbidden)
+		_, _ = w.Write([]byte(`{"success":false,"errors":[{"code":9109,"message":"Invalid access token"}]}`))
+		return
+	}
+	var result interface{}
+	switch {
+	case r.Method == http.MethodGet && r.URL.Path == "/zones/z1/dns_records":
+		list := []cloudflareRecord{}
+		for _, rec := range f.records {
+			if rec.Name == r.URL.Query().Get("name") {
+				list = append(list, rec)
+			}
+		}
+		result = list
+	case r.Method == http.MethodPost && r.URL.Path == "/zones/z1/dns_records",
+		r.Method == http.MethodPut && r.URL.Path == "/zones/z1/dns_records/rec-1":
+		var rec cloudflareRecord
+		body, _ := io.ReadAll(r.Body)
+		_ = json.Unmarshal(body, &rec)
+		rec.ID = "rec-1"
+		f.records[rec.ID] = rec
+		f.writes++
+		result = rec
+	default:
+		http.NotFound(w, r)
+		return
+	}
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{"success": true, "errors": []string{}, "result": result})
+}
+
+func TestPublisherServesAndPublishesSignedHeads(t *testing.T) {
+	cf := &fakeCloudflare{records: map[string]cloudflareRecord{}}
+	api := httptest.NewServer(cf)
+	defer api.Close()
+	signer, err := crypto.NewSigner(filepath.Join(t.TempDir(), ".logryph_key"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	src := &chain{seq: 3, hash: "h3"}
+	p, err := NewPublisher(src, signer, Config{WellKnown: true, DNS: DNSConfig{
+		Provider: ProviderCloudflare, ZoneID: "z1", Record: "_logryph-head.example.com", APIToken: "cf-token", APIURL: api.URL,
+	}})
+	if err != nil {
+		t.Fatalf("NewPublisher: %v", err)
+	}
+
+	rec := httptest.NewRecorder()
+	p.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, WellKnownPath, nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("before the first refresh: status %d", rec.Code)
+	}
+
+	if err := p.Refresh(); err != nil {
+		t.Fatalf("Refresh: %v", err)
+	}
+	rec = httptest.NewRecorder()
+	p.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, WellKnownPath, nil))
+	var served canary.Entry
+	if err := json.Unmarshal(rec.Body.Bytes(), &served); err != nil || served.Seq != 3 || served.Hash != "h3" || !Verify(&served) {
+		t.Fatalf("served head %s (%v)", rec.Body.String(), err)
+	}
+	if served.PubKey != signer.GetPublicKey() {
+		t.Errorf("head signed by %s", served.PubKey)
+	}
+
+	// An unchanged chain is not re-signed or re-published.
+	if err := p.Refresh(); err != nil {
+		t.Fatalf("Refresh: %v", err)
+	}
+	if cf.writes != 1 || !p.Head().Timestamp.Equal(served.Timestamp) {
+		t.Errorf("unchanged head: %d DNS writes, head %+v", cf.writes, p.Head())
+	}
+
+	src.seq, src.hash = 4, "h4"
+	if err := p.Refresh(); err != nil {
+		t.Fatalf("Refresh: %v", err)
+	}
+	if cf.writes != 2 || len(cf.records) != 1 {
+		t.Fatalf("moved head: %d DNS writes, records %v", cf.writes, cf.records)
+	}
+	txt, err := ParseTXT(cf.records["rec-1"].Content)
+	if err != nil || txt.Seq != 4 || txt.Hash != "h4" || !Verify(txt) {
+		t.Fatalf("TXT record %q: %+v, %v", cf.records["rec-1"].Content, txt, err)
+	}
+	txt.Seq = 5
+	if Verify(txt) {
+		t.Error("a head with an altered seq must not verify")
+	}
+
+	p.cfg.DNS.APIToken = "wrong"
+	src.seq, src.hash = 5, "h5"
+	if err := p.Refresh(); err == nil {
+		t.Error("a rejected DNS update should be reported")
+	}
+	if p.Head().Seq != 5 {
+		t.Errorf("the well-known head should move even when DNS fails, got seq %d", p.Head().Seq)
+	}
+}
+
+func TestValidateConfig(t *testing.T) {
+	dns := DNSConfig{Provider: ProviderCloudflare, ZoneID: "z1", Record: "_logryph-head.example.com", APIToken: "t"}
+	for _, c := range []Config{
+		{WellKnown: true, Interval: "1s"},
+		{DNS: DNSConfig{Provider: "route53"}},
+		{DNS: DNSConfig{Provider: ProviderCloudflare, Record: "_h.example.com", APIToken: "t"}},
+		{DNS: DNSConfig{Provider: ProviderCloudflare, ZoneID: "z1", Record: "_h.example.com"}},
+		{DNS: DNSConfig{Provider: ProviderCloudflare, ZoneID: "z1", Record: "_h.example.com", APIToken: "t", TTL: -1}},
+	} {
+		if err := ValidateConfig(c); err == nil {
+			t.Errorf("config %+v was accepted", c)
+		}
+	}
+	if err := ValidateConfig(Config{Interval: "30s", DNS: dns}); err != nil {
+		t.Errorf("valid config: %v", err)
+	}
+	if err := ValidateConfig(Config{Interval: "bogus"}); err != nil {
+		t.Errorf("a disabled section should be valid: %v", err)
+	}
+}
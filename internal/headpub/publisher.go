@@ -0,0 +1,266 @@
+package headpub
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/slyt3/Logryph/internal/assert"
+	"github.com/slyt3/Logryph/internal/canary"
+	"github.com/slyt3/Logryph/internal/crypto"
+	"github.com/slyt3/Logryph/internal/logging"
+)
+
+const (
+	requestTimeout   = 10 * time.Second
+	maxResponseBytes = 256 * 1024
+	maxRefreshTicks  = 1 << 30
+)
+
+// Verify reports whether a published head carries a valid signature by its key. Callers
+// should also check the key is the ledger's.
+func Verify(e *canary.Entry) bool {
+	return e != nil && crypto.VerifyWithPublicKey(e.PubKey, e.Digest(), e.Signature)
+}
+
+// Publisher keeps the latest signed head and publishes it. The head is re-signed only
+// when the chain has moved, so its timestamp is when this process first saw that head.
+type Publisher struct {
+	src      canary.Source
+	signer   *crypto.Signer
+	cfg      Config
+	interval time.Duration
+	client   *http.Client
+
+	mu        sync.Mutex // serialises Refresh and guards head and published
+	head      *canary.Entry
+	published string // TXT content last written to DNS
+
+	ctx      context.Context
+	cancel   context.CancelFunc
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+// NewPublisher publishes the head of the ledger src, signed by signer, as c describes.
+func NewPublisher(src canary.Source, signer *crypto.Signer, c Config) (*Publisher, error) {
+	if err := assert.NotNil(src, "head source"); err != nil {
+		return nil, err
+	}
+	if err := assert.NotNil(signer, "signer"); err != nil {
+		return nil, err
+	}
+	if err := ValidateConfig(c); err != nil {
+		return nil, err
+	}
+	if !c.Enabled() {
+		return nil, errors.New("head publication is not enabled")
+	}
+	interval, err := c.interval()
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Publisher{
+		src: src, signer: signer, cfg: c, interval: interval,
+		client: &http.Client{Timeout: requestTimeout},
+		ctx:    ctx, cancel: cancel, done: make(chan struct{}),
+	}, nil
+}
+
+// Start refreshes the head now and then every interval until Stop.
+func (p *Publisher) Start() {
+	go p.run()
+}
+
+func (p *Publisher) run() {
+	defer close(p.done)
+	p.refreshLogged()
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+	for i := 0; i < maxRefreshTicks; i++ {
+		select {
+		case <-p.ctx.Done():
+			return
+		case <-ticker.C:
+			p.refreshLogged()
+		}
+	}
+}
+
+func (p *Publisher) refreshLogged() {
+	if err := p.Refresh(); err != nil {
+		logging.Warn("head_publication_failed", logging.Fields{Component: "headpub", Error: err.Error()})
+	}
+}
+
+// Stop ends publication, abandoning any DNS update in flight. The last published head
+// stays in DNS.
+func (p *Publisher) Stop() {
+	p.stopOnce.Do(p.cancel)
+	<-p.done
+}
+
+// Refresh signs the current head if the chain has moved, and brings the DNS record up to
+// date.
+func (p *Publisher) Refresh() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	runID, err := p.src.GetRunID()
+	if err != nil {
+		return fmt.Errorf("getting run id: %w", err)
+	}
+	if runID == "" {
+		return nil
+	}
+	seq, hash, err := p.src.GetLastEvent(runID)
+	if err != nil {
+		return fmt.Errorf("getting chain head: %w", err)
+	}
+	if p.head == nil || p.head.RunID != runID || p.head.Seq != seq || p.head.Hash != hash {
+		head, err := canary.Head(p.src, p.signer)
+		if err != nil || head == nil {
+			return err
+		}
+		p.head = head
+	}
+	if p.cfg.DNS.Provider == "" {
+		return nil
+	}
+	content := TXT(p.head)
+	if content == p.published {
+		return nil
+	}
+	if err := p.updateCloudflare(content); err != nil {
+		return fmt.Errorf("updating TXT %s: %w", p.cfg.DNS.Record, err)
+	}
+	p.published = content
+	return nil
+}
+
+// Head returns the latest signed head, or nil before the first refresh.
+func (p *Publisher) Head() *canary.Entry {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.head == nil {
+		return nil
+	}
+	head := *p.head
+	return &head
+}
+
+// WellKnown reports whether the head is to be served at WellKnownPath.
+func (p *Publisher) WellKnown() bool {
+	return p.cfg.WellKnown
+}
+
+// ServeHTTP answers GET and HEAD with the latest head as JSON, and 503 until there is
+// one. It is meant to be reachable without the admin token.
+func (p *Publisher) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	head := p.Head()
+	if head == nil {
+		http.Error(w, "no chain head yet", http.StatusServiceUnavailable)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	if r.Method == http.MethodHead {
+		return
+	}
+	if err := json.NewEncoder(w).Encode(head); err != nil {
+		logging.Error("head_response_write_failed", logging.Fields{Component: "headpub", Error: err.Error()})
+	}
+}
+
+// cloudflareRecord is a DNS record in Cloudflare's API.
+type cloudflareRecord struct {
+	ID      string `json:"id,omitempty"`
+	Type    string `json:"type"`
+	Name    string `json:"name"`
+	Content string `json:"content"`
+	TTL     int    `json:"ttl"`
+}
+
+// cloudflareResponse is the envelope every Cloudflare API answer comes in.
+type cloudflareResponse struct {
+	Success bool `json:"success"`
+	Errors  []struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"errors"`
+	Result json.RawMessage `json:"result"`
+}
+
+// updateCloudflare writes content to the TXT record, creating it if it does not exist.
+func (p *Publisher) updateCloudflare(content string) error {
+	d := p.cfg.DNS
+	base := strings.TrimSuffix(d.APIURL, "/")
+	if base == "" {
+		base = defaultCloudflareAPI
+	}
+	records := base + "/zones/" + url.PathEscape(d.ZoneID) + "/dns_records"
+	var found []cloudflareRecord
+	if err := p.cloudflare(http.MethodGet, records+"?type=TXT&name="+url.QueryEscape(d.Record), nil, &found); err != nil {
+		return err
+	}
+	ttl := d.TTL
+	if ttl == 0 {
+		ttl = defaultTTL
+	}
+	record := cloudflareRecord{Type: "TXT", Name: d.Record, Content: content, TTL: ttl}
+	if len(found) == 0 {
+		return p.cloudflare(http.MethodPost, records, record, nil)
+	}
+	return p.cloudflare(http.MethodPut, records+"/"+url.PathEscape(found[0].ID), record, nil)
+}
+
+// cloudflare makes one API call, decoding the result into out when it is not nil.
+func (p *Publisher) cloudflare(method, target string, body, out interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(p.ctx, method, target, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+p.cfg.DNS.APIToken)
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	var envelope cloudflareResponse
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxResponseBytes)).Decode(&envelope); err != nil {
+		return fmt.Errorf("%s %s: status %d, unreadable answer: %w", method, req.URL.Path, resp.StatusCode, err)
+	}
+	if resp.StatusCode >= 300 || !envelope.Success {
+		msgs := make([]string, 0, len(envelope.Errors))
+		for _, e := range envelope.Errors {
+			msgs = append(msgs, fmt.Sprintf("%d %s", e.Code, e.Message))
+		}
+		return fmt.Errorf("%s %s: status %d: %s", method, req.URL.Path, resp.StatusCode, strings.Join(msgs, "; "))
+	}
+	if out != nil {
+		return json.Unmarshal(envelope.Result, out)
+	}
+	return nil
+}
@@ -0,0 +1,199 @@
+// Package integrity verifies the existing chain when the proxy starts, so nothing is
+// appended to a ledger that was tampered with while the proxy was down. Verification is
+// incremental: the head verified last time is kept in a checkpoint file, and only the
+// events after it are checked, after confirming the checkpointed event is unchanged.
+package integrity
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/slyt3/Logryph/internal/assert"
+	"github.com/slyt3/Logryph/internal/ledger/audit"
+	"github.com/slyt3/Logryph/internal/models"
+)
+
+// What the proxy does when the startup check fails.
+const (
+	ModeOff      = "off"       // no check (default)
+	ModeWarn     = "warn"      // start, and record an integrity_warning event
+	ModeReadOnly = "read_only" // start without appending; the proxy refuses tool calls
+	ModeRefuse   = "refuse"    // exit
+)
+
+// EventTypeWarning records a failed startup check in the chain (warn mode).
+const EventTypeWarning = "integrity_warning"
+
+const (
+	defaultCheckpoint = "logryph-verified.json"
+	batchSize         = 1000
+	maxBatches        = 1 << 20
+)
+
+// Config is the integrity section of the policy file.
+type Config struct {
+	OnStartup  string `yaml:"on_startup,omitempty"` // off, warn, read_only or refuse
+	Checkpoint string `yaml:"checkpoint,omitempty"` // verified head file; default logryph-verified.json
+}
+
+// ValidateConfig checks the integrity section.
+func ValidateConfig(c Config) error {
+	switch c.OnStartup {
+	case "", ModeOff, ModeWarn, ModeReadOnly, ModeRefuse:
+		return nil
+	}
+	return fmt.Errorf("invalid on_startup %q: want %s, %s, %s or %s", c.OnStartup, ModeOff, ModeWarn, ModeReadOnly, ModeRefuse)
+}
+
+// Enabled reports whether the startup check runs.
+func (c Config) Enabled() bool {
+	return c.OnStartup != "" && c.OnStartup != ModeOff
+}
+
+// CheckpointPath is the checkpoint file, defaulted.
+func (c Config) CheckpointPath() string {
+	if c.Checkpoint == "" {
+		return defaultCheckpoint
+	}
+	return c.Checkpoint
+}
+
+// Source is the subset of the ledger the check reads.
+type Source interface {
+	GetRunID() (string, error)
+	GetRunInfo(runID string) (agent, genesisHash, pubKey string, err error)
+	GetEventsFrom(runID string, fromSeq uint64, limit int) ([]models.Event, error)
+}
+
+// Result is the outcome of a startup check.
+type Result struct {
+	RunID       string            `json:"run_id,omitempty"`
+	FromSeq     uint64            `json:"from_seq"` // first sequence index checked
+	Verified    int               `json:"verified"` // events checked
+	Head        *audit.Checkpoint `json:"head,omitempty"`
+	Failure     string            `json:"failure,omitempty"`
+	FailedAtSeq uint64            `json:"failed_at_seq,omitempty"`
+}
+
+// OK reports whether the check passed.
+func (r *Result) OK() bool {
+	return r.Failure == ""
+}
+
+// Check verifies the current run from the checkpoint at path onward. A missing checkpoint,
+// or one for another run, means the whole run is checked. A ledger with no run passes.
+func Check(src Source, path string) (*Result, error) {
+	if err := assert.NotNil(src, "integrity source"); err != nil {
+		return nil, err
+	}
+	runID, err := src.GetRunID()
+	if err != nil || runID == "" {
+		return &Result{}, err
+	}
+	_, _, pubKey, err := src.GetRunInfo(runID)
+	if err != nil {
+		return nil, fmt.Errorf("reading run info: %w", err)
+	}
+	res := &Result{RunID: runID}
+	head, err := LoadCheckpoint(path)
+	if err != nil {
+		return nil, err
+	}
+	if head != nil && head.RunID == runID {
+		if err := confirmCheckpoint(src, head, res); err != nil || !res.OK() {
+			return res, err
+		}
+		res.FromSeq, res.Head = head.Seq+1, head
+	} else {
+		head = nil
+	}
+	return res, verifyFrom(src, pubKey, head, res)
+}
+
+// confirmCheckpoint checks the checkpointed event still has the hash it had when verified.
+func confirmCheckpoint(src Source, head *audit.Checkpoint, res *Result) error {
+	events, err := src.GetEventsFrom(head.RunID, head.Seq, 1)
+	if err != nil {
+		return fmt.Errorf("reading checkpoint event: %w", err)
+	}
+	if len(events) == 0 || events[0].SeqIndex != head.Seq || events[0].CurrentHash != head.Hash {
+		res.Failure = fmt.Sprintf("event at checkpoint seq %d is missing or changed since it was verified", head.Seq)
+		res.FailedAtSeq = head.Seq
+	}
+	return nil
+}
+
+// verifyFrom checks the events after head in batches, moving res.Head forward.
+func verifyFrom(src Source, pubKey string, head *audit.Checkpoint, res *Result) error {
+	from := res.FromSeq
+	for i := 0; i < maxBatches; i++ {
+		events, err := src.GetEventsFrom(res.RunID, from, batchSize)
+		if err != nil {
+			return fmt.Errorf("reading events from seq %d: %w", from, err)
+		}
+		if len(events) == 0 {
+			return nil
+		}
+		v := audit.VerifyEventsWithKey(events, head, pubKey)
+		if !v.Valid {
+			res.Failure, res.FailedAtSeq = v.ErrorMessage, v.FailedAtSeq
+			return nil
+		}
+		last := events[len(events)-1]
+		head = &audit.Checkpoint{RunID: res.RunID, Seq: last.SeqIndex, Hash: last.CurrentHash}
+		res.Head, res.Verified = head, res.Verified+len(events)
+		if len(events) < batchSize {
+			return nil
+		}
+		from = last.SeqIndex + 1
+	}
+	return errors.New("integrity check exceeded max batches")
+}
+
+// LoadCheckpoint reads the checkpoint at path, or returns nil if there is none.
+func LoadCheckpoint(path string) (*audit.Checkpoint, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading checkpoint: %w", err)
+	}
+	var cp audit.Checkpoint
+	if err := json.Unmarshal(data, &cp); err != nil {
+		return nil, fmt.Errorf("parsing checkpoint %s: %w", path, err)
+	}
+	return &cp, nil
+}
+
+// SaveCheckpoint replaces the checkpoint at path with head.
+func SaveCheckpoint(path string, head *audit.Checkpoint) error {
+	if err := assert.NotNil(head, "checkpoint"); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(struct {
+		*audit.Checkpoint
+		VerifiedAt time.Time `json:"verified_at"`
+	}{head, time.Now().UTC()}, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".logryph-verified-*")
+	if err != nil {
+		return fmt.Errorf("writing checkpoint: %w", err)
+	}
+	if _, err := tmp.Write(append(data, '\n')); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+		return fmt.Errorf("writing checkpoint: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		_ = os.Remove(tmp.Name())
+		return fmt.Errorf("writing checkpoint: %w", err)
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,94 @@
+package integrity
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/slyt3/Logryph/internal/crypto"
+	"github.com/slyt3/Logryph/internal/ledger"
+	"github.com/slyt3/Logryph/internal/ledger/audit"
+	"github.com/slyt3/Logryph/internal/ledger/memstore"
+	"github.com/slyt3/Logryph/internal/models"
+)
+
+// tampered serves the ledger with one event's params rewritten.
+type tampered struct {
+	*memstore.Store
+	seq uint64
+}
+
+func (t tampered) GetEventsFrom(runID string, fromSeq uint64, limit int) ([]models.Event, error) {
+	events, err := t.Store.GetEventsFrom(runID, fromSeq, limit)
+	for i := range events {
+		if events[i].SeqIndex == t.seq {
+			events[i].Params = map[string]interface{}{"rewritten": true}
+		}
+	}
+	return events, err
+}
+
+func appendEvents(t *testing.T, p *ledger.EventProcessor, n int) {
+	t.Helper()
+	for i := 0; i < n; i++ {
+		if err := p.ProcessEvent(&models.Event{ID: uuid.New().String()[:8], Timestamp: time.Now(), Actor: "agent", EventType: "tool_call", Method: "os.read"}); err != nil {
+			t.Fatalf("ProcessEvent: %v", err)
+		}
+	}
+}
+
+func TestCheckIsIncremental(t *testing.T) {
+	dir := t.TempDir()
+	signer, err := crypto.NewSigner(filepath.Join(dir, "key"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	mem := memstore.New(0)
+	runID, err := ledger.CreateGenesisBlock(mem, signer, "agent")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := ledger.NewEventProcessor(mem, signer, runID)
+	appendEvents(t, p, 3)
+	path := filepath.Join(dir, "verified.json")
+
+	res, err := Check(mem, path)
+	if err != nil || !res.OK() || res.Verified != 4 || res.Head.Seq != 3 {
+		t.Fatalf("first check = %+v, %v", res, err)
+	}
+	if err := SaveCheckpoint(path, res.Head); err != nil {
+		t.Fatal(err)
+	}
+
+	appendEvents(t, p, 2)
+	res, err = Check(mem, path)
+	if err != nil || !res.OK() || res.FromSeq != 4 || res.Verified != 2 || res.Head.Seq != 5 {
+		t.Fatalf("incremental check = %+v, %v", res, err)
+	}
+
+	if res, _ = Check(tampered{mem, 5}, path); res.OK() || res.FailedAtSeq != 5 {
+		t.Errorf("an event rewritten after the checkpoint should fail: %+v", res)
+	}
+	// Events before the checkpoint are not re-verified, but the checkpoint event is compared.
+	if res, _ = Check(tampered{mem, 1}, path); !res.OK() {
+		t.Errorf("events before the checkpoint are trusted: %+v", res)
+	}
+	if err := SaveCheckpoint(path, &audit.Checkpoint{RunID: runID, Seq: 3, Hash: "other"}); err != nil {
+		t.Fatal(err)
+	}
+	if res, _ = Check(mem, path); res.OK() || res.FailedAtSeq != 3 {
+		t.Errorf("a changed checkpoint event should fail: %+v", res)
+	}
+}
+
+func TestValidateConfig(t *testing.T) {
+	for _, mode := range []string{"", ModeOff, ModeWarn, ModeReadOnly, ModeRefuse} {
+		if err := ValidateConfig(Config{OnStartup: mode}); err != nil {
+			t.Errorf("%q: %v", mode, err)
+		}
+	}
+	if err := ValidateConfig(Config{OnStartup: "readonly"}); err == nil {
+		t.Error("unknown mode should be rejected")
+	}
+}
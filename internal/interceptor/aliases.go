@@ -0,0 +1,25 @@
+package interceptor
+
+import "github.com/slyt3/Logryph/internal/models"
+
+// canonicalMethod returns the name method_aliases maps method to, or method itself.
+func (i *Interceptor) canonicalMethod(method string) string {
+	if i.Core == nil || i.Core.Observer == nil {
+		return method
+	}
+	return i.Core.Observer.CanonicalMethod(method)
+}
+
+// labelCanonicalMethod records an aliased call's canonical name in its labels, which
+// the ledger indexes and method statistics count by. Method keeps the name the agent
+// sent, so the params hash still matches what the upstream received.
+func (i *Interceptor) labelCanonicalMethod(event *models.Event) {
+	canonical := i.canonicalMethod(event.Method)
+	if canonical == event.Method {
+		return
+	}
+	if event.Labels == nil {
+		event.Labels = make(map[string]string, 1)
+	}
+	event.Labels[models.LabelCanonicalMethod] = canonical
+}
@@ -0,0 +1,51 @@
+package interceptor
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/slyt3/Logryph/internal/models"
+)
+
+const aliasPolicy = `
+version: "1.0"
+method_aliases:
+  filesystem.read_file: "fs:read"
+policies:
+  - id: "reads"
+    match_methods: ["fs:read"]
+    risk_level: "medium"
+    labels:
+      team: "storage"
+`
+
+func TestMethodAliasesMatchRulesAndKeepTheOriginalName(t *testing.T) {
+	i, events := newLedgeredInterceptor(t, aliasPolicy)
+	h := i.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	for _, method := range []string{"filesystem.read_file", "fs:read"} {
+		body := `{"jsonrpc":"2.0","id":1,"method":"` + method + `","params":{"path":"/etc/hosts"}}`
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body)))
+	}
+
+	calls := map[string]models.Event{}
+	for _, e := range events() {
+		if e.EventType == "tool_call" {
+			calls[e.Method] = e
+		}
+	}
+	aliased, ok := calls["filesystem.read_file"]
+	if !ok {
+		t.Fatalf("the aliased call was not recorded under its original name: %v", calls)
+	}
+	if aliased.PolicyID != "reads" || aliased.Labels["team"] != "storage" {
+		t.Errorf("the aliased call did not match the fs:read rule: policy %q, labels %v", aliased.PolicyID, aliased.Labels)
+	}
+	if aliased.CanonicalMethod() != "fs:read" {
+		t.Errorf("canonical method = %q, labels %v", aliased.CanonicalMethod(), aliased.Labels)
+	}
+	if direct := calls["fs:read"]; direct.PolicyID != "reads" || direct.Labels[models.LabelCanonicalMethod] != "" {
+		t.Errorf("a canonical call should match without a canonical_method label: %+v", direct)
+	}
+}
@@ -0,0 +1,188 @@
+package interceptor
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/slyt3/Logryph/internal/logging"
+	"github.com/slyt3/Logryph/internal/observer"
+	"github.com/slyt3/Logryph/internal/pool"
+)
+
+// EventTaskBudgetExceeded is both the tag on every call a task makes past its budget and
+// the event recorded, once per task, under the call that first went over.
+const EventTaskBudgetExceeded = "task_budget_exceeded"
+
+// taskBudgetPolicyID is recorded as the policy for stalls raised by the task budget.
+const taskBudgetPolicyID = "task-budget"
+
+// Budget limits a task can go over.
+const (
+	budgetMaxEvents   = "max_events"
+	budgetMaxDuration = "max_duration"
+)
+
+const (
+	maxBudgetTasks  = 10000     // tasks tracked at once; idle ones are dropped first
+	budgetTaskIdle  = time.Hour // a task with no calls for this long is forgotten when space is needed
+	codeOverBudget  = -32001
+	budgetRiskLevel = "high"
+)
+
+// taskBudgets counts each task's calls since its first call seen by this proxy.
+type taskBudgets struct {
+	mu    sync.Mutex
+	tasks map[string]*taskUsage
+}
+
+type taskUsage struct {
+	events   int
+	started  time.Time
+	lastSeen time.Time
+	exceeded bool // the task_budget_exceeded event was recorded
+}
+
+// budgetBreach describes a call made past its task's budget.
+type budgetBreach struct {
+	budget  observer.TaskBudget
+	limit   string // budgetMaxEvents or budgetMaxDuration
+	events  int
+	elapsed time.Duration
+	first   bool // first call past the budget
+}
+
+// charge counts a call against taskID and returns the breach if the task is now over b.
+func (tb *taskBudgets) charge(taskID string, now time.Time, b observer.TaskBudget) *budgetBreach {
+	tb.mu.Lock()
+	defer tb.mu.Unlock()
+	u := tb.tasks[taskID]
+	if u == nil {
+		if tb.tasks == nil {
+			tb.tasks = make(map[string]*taskUsage)
+		}
+		tb.prune(now)
+		u = &taskUsage{started: now}
+		tb.tasks[taskID] = u
+	}
+	u.events++
+	u.lastSeen = now
+	elapsed := now.Sub(u.started)
+	limit := ""
+	switch {
+	case b.MaxEvents > 0 && u.events > b.MaxEvents:
+		limit = budgetMaxEvents
+	case b.MaxDuration > 0 && elapsed > b.MaxDuration:
+		limit = budgetMaxDuration
+	default:
+		return nil
+	}
+	breach := &budgetBreach{budget: b, limit: limit, events: u.events, elapsed: elapsed, first: !u.exceeded}
+	u.exceeded = true
+	return breach
+}
+
+// prune makes room for a new task once the map is full: idle tasks go first, then the
+// least recently seen one. Callers hold tb.mu.
+func (tb *taskBudgets) prune(now time.Time) {
+	if len(tb.tasks) < maxBudgetTasks {
+		return
+	}
+	oldest, oldestSeen := "", now
+	for id, u := range tb.tasks {
+		if now.Sub(u.lastSeen) > budgetTaskIdle {
+			delete(tb.tasks, id)
+			continue
+		}
+		if u.lastSeen.Before(oldestSeen) {
+			oldest, oldestSeen = id, u.lastSeen
+		}
+	}
+	if len(tb.tasks) >= maxBudgetTasks {
+		delete(tb.tasks, oldest)
+	}
+}
+
+// chargeTask counts a task's call against its budget. A call past it is tagged when it is
+// recorded; applyTaskBudget then takes the budget action.
+func (i *Interceptor) chargeTask(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if st := callStateFrom(r.Context()); st != nil && st.call != nil {
+			st.call.breach = i.chargeBudget(st.call.taskID)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// applyTaskBudget records the first call past its task's budget and takes the budget
+// action: deny refuses the call and stall holds it for approval, both only in enforce
+// mode. Tag needs nothing more.
+func (i *Interceptor) applyTaskBudget(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		st := callStateFrom(r.Context())
+		if st == nil || st.call == nil || st.call.breach == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		c, br := st.call, st.call.breach
+		if br.first {
+			i.recordBudgetExceeded(st.callID, c.taskID, c.env, c.req.Method, br)
+		}
+		switch br.budget.Action {
+		case observer.BudgetActionDeny:
+			if i.Core.Observer.IsEnforcing() {
+				i.reject(w, r, &Rejection{RequestID: c.req.ID, Status: http.StatusForbidden, Code: codeOverBudget,
+					Message: fmt.Sprintf("Task %s is over its %s budget", c.taskID, br.limit)})
+				return
+			}
+		case observer.BudgetActionStall:
+			c.budgetStall = &observer.Rule{ID: taskBudgetPolicyID, RiskLevel: budgetRiskLevel, Action: observer.RuleActionStall}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// chargeBudget counts the call against its task's budget; nil when the task is within
+// it, has no ID, or no budget is set.
+func (i *Interceptor) chargeBudget(taskID string) *budgetBreach {
+	if taskID == "" || i.Core == nil || i.Core.Observer == nil {
+		return nil
+	}
+	b := i.Core.Observer.GetTaskBudget()
+	if !b.Enabled() {
+		return nil
+	}
+	return i.budgets.charge(taskID, time.Now(), b)
+}
+
+// recordBudgetExceeded ledgers a task_budget_exceeded event under the call that first
+// went over the budget.
+func (i *Interceptor) recordBudgetExceeded(callID, taskID, env, method string, br *budgetBreach) {
+	logging.Warn(EventTaskBudgetExceeded, logging.Fields{Component: "interceptor", EventID: callID, TaskID: taskID, Method: method, Error: br.limit})
+	if callID == "" || i.Core.Worker == nil {
+		return
+	}
+	event := pool.GetEvent()
+	event.ID = uuid.New().String()[:8]
+	event.Timestamp = time.Now()
+	event.Actor = "system"
+	event.EventType = EventTaskBudgetExceeded
+	event.Method = "logryph:task_budget"
+	event.TaskID = taskID
+	event.ParentID = callID
+	event.RiskLevel = budgetRiskLevel
+	event.Environment = env
+	event.Params["limit"] = br.limit
+	event.Params["action"] = br.budget.Action
+	event.Params["events"] = br.events
+	event.Params["elapsed_ms"] = br.elapsed.Milliseconds()
+	if br.budget.MaxEvents > 0 {
+		event.Params["max_events"] = br.budget.MaxEvents
+	}
+	if br.budget.MaxDuration > 0 {
+		event.Params["max_duration"] = br.budget.MaxDuration.String()
+	}
+	i.Core.Worker.Submit(event)
+}
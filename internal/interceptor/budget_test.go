@@ -0,0 +1,85 @@
+package interceptor
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/slyt3/Logryph/internal/observer"
+)
+
+func TestTaskBudgetDeniesCallsPastMaxEvents(t *testing.T) {
+	policy := `
+version: "1.0"
+defaults:
+  enforcement_mode: enforce
+task_budget:
+  max_events: 2
+  action: deny
+policies: []
+`
+	i, events := newLedgeredInterceptor(t, policy)
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":{}}`))
+	})
+	call := func(task string) int {
+		body := `{"jsonrpc":"2.0","id":1,"method":"tool:run","params":{"task_id":"` + task + `"}}`
+		rec := httptest.NewRecorder()
+		i.Handler(next).ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/", bytes.NewBufferString(body)))
+		return rec.Code
+	}
+
+	want := []int{http.StatusOK, http.StatusOK, http.StatusForbidden, http.StatusForbidden}
+	for j, code := range want {
+		if got := call("loop"); got != code {
+			t.Fatalf("call %d of the looping task: status %d, want %d", j+1, got, code)
+		}
+	}
+	if got := call("other"); got != http.StatusOK {
+		t.Fatalf("another task should have its own budget, got %d", got)
+	}
+
+	exceeded, tagged := 0, 0
+	for _, e := range events() {
+		if e.EventType == EventTaskBudgetExceeded {
+			exceeded++
+			if e.TaskID != "loop" || e.ParentID == "" || e.Params["limit"] != budgetMaxEvents || e.Params["action"] != observer.BudgetActionDeny {
+				t.Errorf("task_budget_exceeded = %+v", e)
+			}
+		}
+		for _, tag := range e.Tags {
+			if tag == EventTaskBudgetExceeded {
+				tagged++
+			}
+		}
+	}
+	if exceeded != 1 || tagged != 2 {
+		t.Errorf("got %d task_budget_exceeded events and %d tagged calls, want 1 and 2", exceeded, tagged)
+	}
+}
+
+func TestTaskBudgetsTrackDurationAndPrune(t *testing.T) {
+	var tb taskBudgets
+	start := time.Now()
+	b := observer.TaskBudget{MaxDuration: 30 * time.Minute, Action: observer.BudgetActionTag}
+	if br := tb.charge("t", start, b); br != nil {
+		t.Fatalf("first call should be within budget, got %+v", br)
+	}
+	br := tb.charge("t", start.Add(31*time.Minute), b)
+	if br == nil || br.limit != budgetMaxDuration || !br.first {
+		t.Fatalf("a call after max_duration should breach it first, got %+v", br)
+	}
+	if br := tb.charge("t", start.Add(32*time.Minute), b); br == nil || br.first {
+		t.Fatalf("later calls stay over budget without a new event, got %+v", br)
+	}
+
+	for j := 0; j < maxBudgetTasks+10; j++ {
+		tb.charge(string(rune('a'+j%26))+time.Duration(j).String(), start.Add(time.Duration(j)*time.Second), b)
+	}
+	if len(tb.tasks) > maxBudgetTasks {
+		t.Errorf("tracked %d tasks, want at most %d", len(tb.tasks), maxBudgetTasks)
+	}
+}
@@ -0,0 +1,129 @@
+package interceptor
+
+import (
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/slyt3/Logryph/internal/observer"
+)
+
+const (
+	redactedValue      = "[REDACTED]"
+	maxCapturedValue   = 1024
+	maxCapturedQueries = 64
+)
+
+// credentialHeaders are redacted even when allowlisted for capture.
+var credentialHeaders = map[string]bool{
+	"authorization":       true,
+	"proxy-authorization": true,
+	"cookie":              true,
+	"set-cookie":          true,
+}
+
+// httpCapture is the HTTP context recorded on a tool_call.
+type httpCapture struct {
+	headers map[string]string
+	query   map[string]string
+}
+
+// captureRequest records the allowlisted request headers and, if enabled, the query string.
+func (i *Interceptor) captureRequest(req *http.Request) httpCapture {
+	if req == nil || i.Core == nil || i.Core.Observer == nil {
+		return httpCapture{}
+	}
+	cfg := i.Core.Observer.GetCapture()
+	redact := redactSet(cfg.Redact)
+	out := httpCapture{headers: captureHeaders(req.Header, cfg.RequestHeaders, redact)}
+	if cfg.Query && req.URL != nil {
+		out.query = captureQuery(req.URL.Query(), redact)
+	}
+	return out
+}
+
+// captureResponseHeaders records the allowlisted upstream response headers.
+func (i *Interceptor) captureResponseHeaders(h http.Header) map[string]string {
+	if i.Core == nil || i.Core.Observer == nil {
+		return nil
+	}
+	cfg := i.Core.Observer.GetCapture()
+	return captureHeaders(h, cfg.ResponseHeaders, redactSet(cfg.Redact))
+}
+
+func redactSet(names []string) map[string]bool {
+	set := make(map[string]bool, len(names))
+	for j := 0; j < len(names) && j < observer.MaxCaptureEntries; j++ {
+		set[strings.ToLower(strings.TrimSpace(names[j]))] = true
+	}
+	return set
+}
+
+// captureHeaders copies the named headers present in h, keyed by canonical name. Multiple
+// values are joined with ", ". Credential headers keep only their auth scheme.
+func captureHeaders(h http.Header, names []string, redact map[string]bool) map[string]string {
+	if len(h) == 0 || len(names) == 0 {
+		return nil
+	}
+	var out map[string]string
+	for j := 0; j < len(names) && j < observer.MaxCaptureEntries; j++ {
+		name := http.CanonicalHeaderKey(strings.TrimSpace(names[j]))
+		values := h.Values(name)
+		if len(values) == 0 {
+			continue
+		}
+		if out == nil {
+			out = make(map[string]string)
+		}
+		lower := strings.ToLower(name)
+		switch {
+		case credentialHeaders[lower]:
+			out[name] = redactCredential(values[0])
+		case redact[lower]:
+			out[name] = redactedValue
+		default:
+			out[name] = truncateCaptured(strings.Join(values, ", "))
+		}
+	}
+	return out
+}
+
+// redactCredential keeps the scheme of an Authorization-style value ("Bearer [REDACTED]")
+// so the kind of credential stays visible without the secret.
+func redactCredential(value string) string {
+	scheme, _, found := strings.Cut(strings.TrimSpace(value), " ")
+	if !found || scheme == "" || len(scheme) > 32 {
+		return redactedValue
+	}
+	return scheme + " " + redactedValue
+}
+
+// captureQuery records query parameters in sorted key order, redacting configured keys.
+func captureQuery(values url.Values, redact map[string]bool) map[string]string {
+	if len(values) == 0 {
+		return nil
+	}
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	out := make(map[string]string, len(keys))
+	for j := 0; j < len(keys) && j < maxCapturedQueries; j++ {
+		k := keys[j]
+		if redact[strings.ToLower(k)] {
+			out[k] = redactedValue
+			continue
+		}
+		out[k] = truncateCaptured(strings.Join(values[k], ","))
+	}
+	return out
+}
+
+func truncateCaptured(v string) string {
+	if len(v) <= maxCapturedValue {
+		return v
+	}
+	return strings.ToValidUTF8(v[:maxCapturedValue], "") + "...[truncated]"
+}
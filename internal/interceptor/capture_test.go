@@ -0,0 +1,43 @@
+package interceptor
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+)
+
+func TestCaptureHeadersRedactsCredentials(t *testing.T) {
+	h := http.Header{}
+	h.Set("Authorization", "Bearer sk-live-123")
+	h.Set("X-Tool-Route", "eu-1")
+	h.Set("X-Api-Key", "abc")
+	h.Add("Accept", "application/json")
+	h.Add("Accept", "text/plain")
+
+	got := captureHeaders(h, []string{"authorization", "X-Tool-Route", "x-api-key", "Accept", "X-Missing"}, redactSet([]string{"X-API-Key"}))
+	want := map[string]string{
+		"Authorization": "Bearer [REDACTED]",
+		"X-Tool-Route":  "eu-1",
+		"X-Api-Key":     redactedValue,
+		"Accept":        "application/json, text/plain",
+	}
+	if len(got) != len(want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("%s: expected %q, got %q", k, v, got[k])
+		}
+	}
+	if captureHeaders(h, nil, nil) != nil {
+		t.Error("no headers should be captured without an allowlist")
+	}
+}
+
+func TestCaptureQueryRedactsKeys(t *testing.T) {
+	values, _ := url.ParseQuery("page=2&Token=secret&tag=a&tag=b")
+	got := captureQuery(values, redactSet([]string{"token"}))
+	if got["page"] != "2" || got["tag"] != "a,b" || got["Token"] != redactedValue {
+		t.Fatalf("unexpected query capture: %v", got)
+	}
+}
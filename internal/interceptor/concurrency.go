@@ -0,0 +1,339 @@
+package interceptor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/slyt3/Logryph/internal/logging"
+	"github.com/slyt3/Logryph/internal/observer"
+	"github.com/slyt3/Logryph/internal/pool"
+)
+
+// EventCallThrottled records a call that had to wait for an upstream slot, or was refused
+// one, because the concurrency cap was reached.
+const EventCallThrottled = "call_throttled"
+
+// JSON-RPC error code returned to the agent when no upstream slot is available.
+const codeThrottled = -32004
+
+// abortStageSlot is the abort stage of a call abandoned while queued for a slot.
+const abortStageSlot = "awaiting_slot"
+
+// Throttle outcomes recorded on call_throttled events.
+const (
+	throttleQueued   = "queued"    // waited for a slot, then forwarded
+	throttleRejected = "rejected"  // overflow is reject, or the queue was full
+	throttleTimedOut = "timed_out" // no slot within queue_timeout
+)
+
+var (
+	errAtCapacity   = errors.New("upstream concurrency limit reached")
+	errQueueFull    = errors.New("upstream concurrency queue full")
+	errQueueTimeout = errors.New("timed out waiting for an upstream slot")
+)
+
+var (
+	inFlightCalls  atomic.Int64
+	queuedCalls    atomic.Int64
+	queuedTotal    atomic.Uint64
+	throttledTotal atomic.Uint64
+	queueWaitNs    atomic.Uint64
+)
+
+// ConcurrencyStats is a snapshot of upstream concurrency for metrics.
+type ConcurrencyStats struct {
+	InFlight  int           // calls currently forwarded upstream
+	Queued    int           // calls currently waiting for a slot
+	Waited    uint64        // calls that waited for a slot, in total
+	Throttled uint64        // calls refused a slot, in total
+	QueueWait time.Duration // total time spent waiting for slots
+}
+
+// Concurrency returns the current upstream concurrency counters.
+// Safe for concurrent access.
+func Concurrency() ConcurrencyStats {
+	return ConcurrencyStats{
+		InFlight:  int(inFlightCalls.Load()),
+		Queued:    int(queuedCalls.Load()),
+		Waited:    queuedTotal.Load(),
+		Throttled: throttledTotal.Load(),
+		QueueWait: time.Duration(queueWaitNs.Load()),
+	}
+}
+
+// limiter hands out upstream slots, serving waiters in arrival order. The cap is passed
+// on every call rather than fixed, so a policy reload applies to the next call.
+type limiter struct {
+	mu       sync.Mutex
+	inFlight int
+	waiters  []chan struct{}
+}
+
+// acquire takes a slot, waiting up to p.QueueTimeout when the cap is reached and queueing
+// is allowed. It returns how long the call waited.
+func (l *limiter) acquire(ctx context.Context, p observer.ConcurrencyPolicy) (time.Duration, error) {
+	l.mu.Lock()
+	if p.MaxInFlight == 0 || (l.inFlight < p.MaxInFlight && len(l.waiters) == 0) {
+		l.inFlight++
+		l.mu.Unlock()
+		return 0, nil
+	}
+	if !p.Queue {
+		l.mu.Unlock()
+		return 0, errAtCapacity
+	}
+	if len(l.waiters) >= p.MaxQueue {
+		l.mu.Unlock()
+		return 0, errQueueFull
+	}
+	ready := make(chan struct{})
+	l.waiters = append(l.waiters, ready)
+	l.mu.Unlock()
+	queuedCalls.Add(1)
+	defer queuedCalls.Add(-1)
+
+	start := time.Now()
+	timer := time.NewTimer(p.QueueTimeout)
+	defer timer.Stop()
+	var err error
+	select {
+	case <-ready:
+		return time.Since(start), nil
+	case <-timer.C:
+		err = errQueueTimeout
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if !l.dequeue(ready) {
+		// The slot was handed over as the wait ended; pass it on.
+		l.inFlight--
+		l.wake(p.MaxInFlight)
+	}
+	return time.Since(start), err
+}
+
+// release returns a slot and hands it to the next waiter if the cap allows.
+func (l *limiter) release(max int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.inFlight--
+	l.wake(max)
+}
+
+// wake hands free slots to waiters. Callers hold l.mu.
+func (l *limiter) wake(max int) {
+	for len(l.waiters) > 0 && (max == 0 || l.inFlight < max) {
+		close(l.waiters[0])
+		l.waiters = l.waiters[1:]
+		l.inFlight++
+	}
+}
+
+// dequeue removes ready from the waiters, reporting whether it was still waiting.
+// Callers hold l.mu.
+func (l *limiter) dequeue(ready chan struct{}) bool {
+	for j := 0; j < len(l.waiters); j++ {
+		if l.waiters[j] == ready {
+			l.waiters = append(l.waiters[:j], l.waiters[j+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// ruleLimiters holds a limiter per rule with max_concurrent, keyed by rule ID, so calls
+// matching one rule share its slots whichever of its methods they call.
+type ruleLimiters struct {
+	mu     sync.Mutex
+	byRule map[string]*limiter
+}
+
+// get returns the rule's limiter, creating it on first use. Idle limiters of rules a
+// reload removed are dropped once there are more than maxPolicies.
+func (rl *ruleLimiters) get(rule string) *limiter {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	if l, ok := rl.byRule[rule]; ok {
+		return l
+	}
+	if rl.byRule == nil {
+		rl.byRule = make(map[string]*limiter)
+	}
+	if len(rl.byRule) >= maxPolicies {
+		for id, l := range rl.byRule {
+			if l.idle() {
+				delete(rl.byRule, id)
+			}
+		}
+	}
+	l := &limiter{}
+	rl.byRule[rule] = l
+	return l
+}
+
+// idle reports whether no call holds or waits for a slot.
+func (l *limiter) idle() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.inFlight == 0 && len(l.waiters) == 0
+}
+
+// concurrencyPolicy returns the cap from the policy, or no cap without one.
+func (i *Interceptor) concurrencyPolicy() observer.ConcurrencyPolicy {
+	if i.Core == nil || i.Core.Observer == nil {
+		return observer.ConcurrencyPolicy{}
+	}
+	return i.Core.Observer.GetConcurrency()
+}
+
+// limitConcurrency holds the call until it gets a slot under its rule's cap and the
+// upstream cap, then forwards it under the policy's upstream deadline.
+func (i *Interceptor) limitConcurrency(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		st := callStateFrom(r.Context())
+		if st == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		release, rej := i.acquireSlot(r, st)
+		if release == nil {
+			if rej == nil {
+				i.recordAbort(r.Context(), st, abortStageSlot)
+				return
+			}
+			w.Header().Set(RequestIDHeader, st.corr.requestID)
+			i.WriteRejection(w, rej)
+			return
+		}
+		defer release()
+		if st.timeout > 0 {
+			ctx, cancel := context.WithTimeout(r.Context(), st.timeout)
+			defer cancel()
+			r = r.WithContext(ctx)
+		}
+		st.forwarded = time.Now()
+		next.ServeHTTP(w, r)
+		i.recordAbort(r.Context(), st, abortStageUpstream)
+	})
+}
+
+// acquireSlot takes a slot under the matched rule's max_concurrent, if it sets one, then
+// an upstream slot. On success it returns the function that releases both. Otherwise it
+// returns the rejection to send, or nil when the agent went away while queued.
+func (i *Interceptor) acquireSlot(r *http.Request, st *callState) (func(), *Rejection) {
+	var releaseRule func()
+	if st.ruleCap.MaxInFlight > 0 {
+		l := i.ruleSlots.get(st.rule)
+		rp := st.ruleCap
+		waited, err := l.acquire(r.Context(), rp)
+		if err != nil {
+			return nil, i.throttled(r, st, err, waited, rp, st.rule)
+		}
+		if waited > 0 {
+			noteWait(waited)
+			i.recordThrottle(st, throttleQueued, waited, rp, st.rule)
+		}
+		releaseRule = func() { l.release(rp.MaxInFlight) }
+	}
+
+	p := i.concurrencyPolicy()
+	waited, err := i.slots.acquire(r.Context(), p)
+	if err != nil {
+		if releaseRule != nil {
+			releaseRule()
+		}
+		return nil, i.throttled(r, st, err, waited, p, "")
+	}
+	if waited > 0 {
+		noteWait(waited)
+		i.recordThrottle(st, throttleQueued, waited, p, "")
+	}
+	inFlightCalls.Add(1)
+	return func() {
+		inFlightCalls.Add(-1)
+		i.slots.release(i.concurrencyPolicy().MaxInFlight)
+		if releaseRule != nil {
+			releaseRule()
+		}
+	}, nil
+}
+
+// throttled records a call refused a slot under p, the cap of rule or the upstream cap
+// when rule is empty, and returns the rejection to send; nil if the agent went away.
+func (i *Interceptor) throttled(r *http.Request, st *callState, err error, waited time.Duration, p observer.ConcurrencyPolicy, rule string) *Rejection {
+	if waited > 0 {
+		noteWait(waited)
+	}
+	if r.Context().Err() != nil {
+		return nil
+	}
+	outcome := throttleRejected
+	if errors.Is(err, errQueueTimeout) {
+		outcome = throttleTimedOut
+	}
+	throttledTotal.Add(1)
+	st.responded = true
+	i.recordThrottle(st, outcome, waited, p, rule)
+	msg := fmt.Sprintf("Upstream busy: %v (max_in_flight %d)", err, p.MaxInFlight)
+	if rule != "" {
+		msg = fmt.Sprintf("Tool busy: %v (rule %s max_concurrent %d)", err, rule, p.MaxInFlight)
+	}
+	return &Rejection{
+		RequestID: st.rpcID,
+		Status:    http.StatusServiceUnavailable,
+		Code:      codeThrottled,
+		Message:   msg,
+	}
+}
+
+func noteWait(waited time.Duration) {
+	queuedTotal.Add(1)
+	queueWaitNs.Add(uint64(waited))
+}
+
+// recordThrottle ledgers a call_throttled event as a child of the call. A non-empty rule
+// means the rule's max_concurrent held the call back rather than the upstream cap.
+func (i *Interceptor) recordThrottle(st *callState, outcome string, waited time.Duration, p observer.ConcurrencyPolicy, rule string) {
+	fields := logging.Fields{Component: "interceptor", EventID: st.callID, TaskID: st.taskID, Method: st.method, CorrelationID: st.corr.requestID, TraceID: st.corr.traceID}
+	if outcome == throttleQueued {
+		logging.Debug("call_throttled", fields)
+	} else {
+		fields.Error = outcome
+		logging.Warn("call_throttled", fields)
+	}
+	if st.callID == "" || i.Core == nil || i.Core.Worker == nil {
+		return
+	}
+
+	event := pool.GetEvent()
+	event.ID = uuid.New().String()[:8]
+	event.Timestamp = time.Now()
+	event.Actor = "system"
+	event.EventType = EventCallThrottled
+	event.Method = st.method
+	event.TaskID = st.taskID
+	event.ParentID = st.callID
+	event.Environment = st.env
+	event.CorrelationID = st.corr.requestID
+	event.TraceID = st.corr.traceID
+	event.SpanID = st.corr.spanID
+	event.Params["outcome"] = outcome
+	event.Params["wait_ms"] = waited.Milliseconds()
+	if rule != "" {
+		event.Params["rule"] = rule
+		event.Params["max_concurrent"] = p.MaxInFlight
+	} else {
+		event.Params["max_in_flight"] = p.MaxInFlight
+	}
+
+	i.Core.Worker.Submit(event)
+}
@@ -0,0 +1,203 @@
+package interceptor
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/slyt3/Logryph/internal/observer"
+)
+
+const concurrencyPolicy = `
+version: "1.0"
+concurrency:
+  max_in_flight: 1
+  max_queue: 1
+  queue_timeout: "5s"
+policies: []
+`
+
+func TestHandlerCapsUpstreamConcurrency(t *testing.T) {
+	i, events := newLedgeredInterceptor(t, concurrencyPolicy)
+	entered := make(chan struct{}, 4)
+	release := make(chan struct{}, 4)
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		entered <- struct{}{}
+		<-release
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":{}}`))
+	})
+	call := func(id string) *httptest.ResponseRecorder {
+		body := `{"jsonrpc":"2.0","id":` + id + `,"method":"tool:run","params":{}}`
+		rec := httptest.NewRecorder()
+		i.Handler(next).ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/", bytes.NewBufferString(body)))
+		return rec
+	}
+	before := Concurrency()
+
+	done := make(chan *httptest.ResponseRecorder, 2)
+	go func() { done <- call("1") }()
+	<-entered
+	go func() { done <- call("2") }()
+	for deadline := time.Now().Add(2 * time.Second); Concurrency().Queued == 0; {
+		if time.Now().After(deadline) {
+			t.Fatal("second call never queued")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	rec := call("3")
+	var resp struct {
+		Error struct {
+			Code int `json:"code"`
+		} `json:"error"`
+	}
+	if rec.Code != http.StatusServiceUnavailable || json.Unmarshal(rec.Body.Bytes(), &resp) != nil || resp.Error.Code != codeThrottled {
+		t.Fatalf("a call past a full queue should get a JSON-RPC 503, got %d %q", rec.Code, rec.Body.String())
+	}
+
+	release <- struct{}{}
+	<-entered
+	release <- struct{}{}
+	for j := 0; j < 2; j++ {
+		if rec := <-done; rec.Code != http.StatusOK {
+			t.Errorf("capped call: status %d, want 200", rec.Code)
+		}
+	}
+	after := Concurrency()
+	if after.InFlight != before.InFlight || after.Queued != 0 || after.Waited != before.Waited+1 || after.Throttled != before.Throttled+1 {
+		t.Errorf("stats before %+v, after %+v", before, after)
+	}
+
+	outcomes := map[string]int{}
+	for _, e := range events() {
+		if e.EventType == EventCallThrottled {
+			outcome, _ := e.Params["outcome"].(string)
+			outcomes[outcome]++
+			if e.ParentID == "" {
+				t.Errorf("call_throttled should be linked to its tool_call")
+			}
+		}
+	}
+	if outcomes[throttleQueued] != 1 || outcomes[throttleRejected] != 1 {
+		t.Errorf("call_throttled outcomes = %v, want one queued and one rejected", outcomes)
+	}
+}
+
+func TestLimiterTimeoutAndReload(t *testing.T) {
+	var l limiter
+	ctx := context.Background()
+	p := observer.ConcurrencyPolicy{MaxInFlight: 1, Queue: true, MaxQueue: 4, QueueTimeout: 20 * time.Millisecond}
+	if _, err := l.acquire(ctx, p); err != nil {
+		t.Fatalf("first acquire: %v", err)
+	}
+	if _, err := l.acquire(ctx, p); !errors.Is(err, errQueueTimeout) {
+		t.Fatalf("expected a queue timeout, got %v", err)
+	}
+	if _, err := l.acquire(ctx, observer.ConcurrencyPolicy{MaxInFlight: 1}); !errors.Is(err, errAtCapacity) {
+		t.Fatalf("reject overflow should fail fast, got %v", err)
+	}
+
+	// Raising the cap lets new calls through at once; lowering it holds freed slots back.
+	if _, err := l.acquire(ctx, observer.ConcurrencyPolicy{MaxInFlight: 2}); err != nil {
+		t.Fatalf("acquire under a raised cap: %v", err)
+	}
+	l.release(1)
+	l.release(1)
+	if l.inFlight != 0 || len(l.waiters) != 0 {
+		t.Errorf("limiter leaked: %d in flight, %d waiting", l.inFlight, len(l.waiters))
+	}
+}
+
+const ruleConcurrencyPolicy = `
+version: "1.0"
+policies:
+  - id: serialize-apply
+    match_methods: ["terraform:apply"]
+    risk_level: high
+    max_concurrent: 1
+  - id: one-deploy
+    match_methods: ["deploy:run"]
+    risk_level: high
+    max_concurrent: 1
+    overflow: reject
+`
+
+func TestHandlerSerializesRuleMaxConcurrent(t *testing.T) {
+	i, events := newLedgeredInterceptor(t, ruleConcurrencyPolicy)
+	entered := make(chan string, 4)
+	release := make(chan struct{}, 4)
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Method string `json:"method"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		entered <- req.Method
+		if req.Method != "tool:other" {
+			<-release
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":{}}`))
+	})
+	call := func(method string) *httptest.ResponseRecorder {
+		body := `{"jsonrpc":"2.0","id":1,"method":"` + method + `","params":{}}`
+		rec := httptest.NewRecorder()
+		i.Handler(next).ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/", bytes.NewBufferString(body)))
+		return rec
+	}
+
+	done := make(chan *httptest.ResponseRecorder, 2)
+	go func() { done <- call("terraform:apply") }()
+	<-entered
+	go func() { done <- call("terraform:apply") }()
+	for deadline := time.Now().Add(2 * time.Second); Concurrency().Queued == 0; {
+		if time.Now().After(deadline) {
+			t.Fatal("second apply never queued")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if rec := call("tool:other"); rec.Code != http.StatusOK {
+		t.Fatalf("a call outside the rule should not wait, got %d", rec.Code)
+	}
+	<-entered
+
+	go func() { done <- call("deploy:run") }()
+	<-entered
+	if rec := call("deploy:run"); rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("overflow reject should refuse a second deploy, got %d", rec.Code)
+	}
+
+	release <- struct{}{}
+	<-entered
+	release <- struct{}{}
+	release <- struct{}{}
+	for j := 0; j < 3; j++ {
+		if rec := <-done; rec.Code != http.StatusOK {
+			t.Errorf("capped call: status %d, want 200", rec.Code)
+		}
+	}
+
+	outcomes := map[string]int{}
+	for _, e := range events() {
+		if e.EventType != EventCallThrottled {
+			continue
+		}
+		rule, _ := e.Params["rule"].(string)
+		outcome, _ := e.Params["outcome"].(string)
+		outcomes[rule+"/"+outcome]++
+		if max, _ := e.Params["max_concurrent"].(float64); max != 1 {
+			t.Errorf("call_throttled max_concurrent = %v, want 1", e.Params["max_concurrent"])
+		}
+	}
+	if outcomes["serialize-apply/"+throttleQueued] != 1 || outcomes["one-deploy/"+throttleRejected] != 1 || len(outcomes) != 2 {
+		t.Errorf("call_throttled outcomes = %v, want one queued apply and one rejected deploy", outcomes)
+	}
+	if !i.ruleSlots.get("serialize-apply").idle() || !i.ruleSlots.get("one-deploy").idle() {
+		t.Error("rule limiters leaked slots")
+	}
+}
@@ -0,0 +1,135 @@
+package interceptor
+
+import (
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/slyt3/Logryph/internal/logging"
+)
+
+// RequestIDHeader carries the correlation ID of an HTTP exchange. An inbound value is kept,
+// otherwise one is generated; it is forwarded to the upstream and echoed to the agent.
+const RequestIDHeader = "X-Logryph-Request-ID"
+
+// TraceparentHeader is the W3C Trace Context header. A valid inbound value is forwarded
+// untouched; its trace and parent span IDs are recorded on the call's events.
+const TraceparentHeader = "traceparent"
+
+var (
+	validRequestID = regexp.MustCompile(`^[A-Za-z0-9._:/+=-]{1,128}$`)
+	traceparentRE  = regexp.MustCompile(`^([0-9a-f]{2})-([0-9a-f]{32})-([0-9a-f]{16})-([0-9a-f]{2})$`)
+)
+
+// TaskIDHeader names the task a call belongs to, for agents that cannot add task_id to
+// the JSON-RPC params. A task_id param takes precedence over the header.
+const TaskIDHeader = "X-Logryph-Task-ID"
+
+// ParentEventHeader names the ledger event a call follows from. It overrides the parent
+// otherwise inferred from the task's previous call, so agents can branch a task.
+const ParentEventHeader = "X-Logryph-Parent-Event"
+
+// EventIDHeader is set on responses to the ID of the call's tool_call event, which an
+// agent can pass back as ParentEventHeader on calls that follow from it. With
+// defaults.upstream_event_headers it is also set on the call forwarded upstream.
+const EventIDHeader = "X-Logryph-Event-ID"
+
+// EventHashHeader carries the params hash of the call's tool_call event (the params_hash
+// looked up by /api/seen) on calls forwarded upstream, with defaults.upstream_event_headers.
+// The chain hash is assigned after forwarding; a receipt for the event ID provides it.
+const EventHashHeader = "X-Logryph-Event-Hash"
+
+// correlation identifies the HTTP exchange and distributed trace an event belongs to,
+// and the task hierarchy the agent declared in headers.
+type correlation struct {
+	requestID string
+	traceID   string
+	spanID    string
+	taskID    string
+	parentID  string
+}
+
+// EnsureRequestID returns the request's X-Logryph-Request-ID, replacing a missing or
+// malformed value with a new UUID so the header is always safe to log and echo.
+func EnsureRequestID(req *http.Request) string {
+	if req == nil {
+		return ""
+	}
+	id := req.Header.Get(RequestIDHeader)
+	if !validRequestID.MatchString(id) {
+		id = uuid.New().String()
+		req.Header.Set(RequestIDHeader, id)
+	}
+	return id
+}
+
+// EnsureTraceparent starts a trace for requests that carry no valid traceparent, so an
+// agent the call reaches through another Logryph proxy records the same trace ID. It
+// returns the trace and span IDs the request now carries.
+func EnsureTraceparent(req *http.Request) (traceID, spanID string) {
+	if req == nil {
+		return "", ""
+	}
+	if traceID, spanID, ok := ParseTraceparent(req.Header.Get(TraceparentHeader)); ok {
+		return traceID, spanID
+	}
+	traceID = strings.ReplaceAll(uuid.New().String(), "-", "")
+	spanID = strings.ReplaceAll(uuid.New().String(), "-", "")[:16]
+	req.Header.Set(TraceparentHeader, "00-"+traceID+"-"+spanID+"-01")
+	return traceID, spanID
+}
+
+// ParseTraceparent extracts the trace-id and parent-id from a W3C traceparent value.
+// Invalid values, including all-zero IDs and the reserved version ff, are rejected.
+func ParseTraceparent(value string) (traceID, spanID string, ok bool) {
+	m := traceparentRE.FindStringSubmatch(strings.TrimSpace(value))
+	if m == nil || m[1] == "ff" {
+		return "", "", false
+	}
+	if strings.Trim(m[2], "0") == "" || strings.Trim(m[3], "0") == "" {
+		return "", "", false
+	}
+	return m[2], m[3], true
+}
+
+func requestCorrelation(req *http.Request) correlation {
+	if req == nil {
+		return correlation{}
+	}
+	c := correlation{requestID: req.Header.Get(RequestIDHeader)}
+	if !validRequestID.MatchString(c.requestID) {
+		c.requestID = ""
+	}
+	if traceID, spanID, ok := ParseTraceparent(req.Header.Get(TraceparentHeader)); ok {
+		c.traceID, c.spanID = traceID, spanID
+	}
+	c.taskID = headerID(req, TaskIDHeader)
+	c.parentID = headerID(req, ParentEventHeader)
+	return c
+}
+
+// headerID returns an ID header's value, or empty when it is malformed. IDs share the
+// request ID's character set and length limit so they are safe to log.
+func headerID(req *http.Request, name string) string {
+	id := strings.TrimSpace(req.Header.Get(name))
+	if id == "" {
+		return ""
+	}
+	if !validRequestID.MatchString(id) {
+		logging.Warn("invalid_id_header", logging.Fields{Component: "interceptor", Error: name})
+		return ""
+	}
+	return id
+}
+
+// callCorrelation prefers the correlation fixed by the Handler chain, so the request and
+// response events of one exchange always agree even if headers change in between.
+func callCorrelation(req *http.Request) correlation {
+	if req != nil {
+		if st := callStateFrom(req.Context()); st != nil {
+			return st.corr
+		}
+	}
+	return requestCorrelation(req)
+}
@@ -0,0 +1,195 @@
+package interceptor
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/http/httputil"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/slyt3/Logryph/internal/crypto"
+	"github.com/slyt3/Logryph/internal/models"
+)
+
+func TestParseTraceparent(t *testing.T) {
+	traceID, spanID, ok := ParseTraceparent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	if !ok || traceID != "4bf92f3577b34da6a3ce929d0e0e4736" || spanID != "00f067aa0ba902b7" {
+		t.Fatalf("valid traceparent not parsed: %q %q %v", traceID, spanID, ok)
+	}
+	for _, bad := range []string{
+		"",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7",    // missing flags
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01", // zero trace id
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01", // zero span id
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", // reserved version
+		"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01", // uppercase
+	} {
+		if _, _, ok := ParseTraceparent(bad); ok {
+			t.Errorf("expected %q to be rejected", bad)
+		}
+	}
+}
+
+func TestEnsureRequestID(t *testing.T) {
+	req := httptest.NewRequest("POST", "/", nil)
+	req.Header.Set(RequestIDHeader, "client-42")
+	if got := EnsureRequestID(req); got != "client-42" {
+		t.Fatalf("inbound request ID not kept: %q", got)
+	}
+
+	req = httptest.NewRequest("POST", "/", nil)
+	req.Header.Set(RequestIDHeader, "bad id\nwith newline")
+	got := EnsureRequestID(req)
+	if got == "bad id\nwith newline" || got == "" || req.Header.Get(RequestIDHeader) != got {
+		t.Fatalf("malformed request ID not replaced: %q", got)
+	}
+}
+
+func TestEnsureTraceparent(t *testing.T) {
+	const inbound = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	req := httptest.NewRequest("POST", "/", nil)
+	req.Header.Set(TraceparentHeader, inbound)
+	if traceID, _ := EnsureTraceparent(req); traceID != "4bf92f3577b34da6a3ce929d0e0e4736" || req.Header.Get(TraceparentHeader) != inbound {
+		t.Fatalf("inbound traceparent not kept: %q", req.Header.Get(TraceparentHeader))
+	}
+
+	req = httptest.NewRequest("POST", "/", nil)
+	traceID, spanID := EnsureTraceparent(req)
+	gotTrace, gotSpan, ok := ParseTraceparent(req.Header.Get(TraceparentHeader))
+	if !ok || gotTrace != traceID || gotSpan != spanID {
+		t.Fatalf("generated traceparent %q does not match %s/%s", req.Header.Get(TraceparentHeader), traceID, spanID)
+	}
+}
+
+func TestHierarchyHeaders(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":{}}`))
+	}))
+	defer upstream.Close()
+	i, events := newLedgeredInterceptor(t, "version: \"1.0\"\npolicies: []\n")
+	target, _ := url.Parse(upstream.URL)
+	proxy := httputil.NewSingleHostReverseProxy(target)
+	proxy.ModifyResponse = i.InterceptResponse
+	h := i.Handler(proxy)
+	call := func(method, params string, headers map[string]string) string {
+		body := `{"jsonrpc":"2.0","id":1,"method":"` + method + `","params":` + params + `}`
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec.Header().Get(EventIDHeader)
+	}
+	first := call("fs:read", `{}`, map[string]string{TaskIDHeader: "task-h"})
+	call("fs:list", `{}`, map[string]string{TaskIDHeader: "task-h"})
+	call("fs:stat", `{"task_id":"task-p"}`, map[string]string{TaskIDHeader: "task-h"})
+	call("fs:write", `{}`, map[string]string{TaskIDHeader: "task-h", ParentEventHeader: first})
+	call("fs:rm", `{}`, map[string]string{TaskIDHeader: "bad id"})
+
+	calls := map[string]models.Event{}
+	for _, e := range events() {
+		if e.EventType == "tool_call" {
+			calls[e.Method] = e
+		}
+	}
+	if first == "" || calls["fs:read"].ID != first || calls["fs:read"].TaskID != "task-h" {
+		t.Errorf("first call %+v, echoed event ID %q", calls["fs:read"], first)
+	}
+	if calls["fs:list"].ParentID != first {
+		t.Errorf("second call should follow the first, parent %q", calls["fs:list"].ParentID)
+	}
+	if calls["fs:stat"].TaskID != "task-p" {
+		t.Errorf("task_id param should win over the header, got %q", calls["fs:stat"].TaskID)
+	}
+	if calls["fs:write"].ParentID != first {
+		t.Errorf("parent header ignored, parent %q", calls["fs:write"].ParentID)
+	}
+	if calls["fs:rm"].TaskID != "" {
+		t.Errorf("malformed task header accepted: %q", calls["fs:rm"].TaskID)
+	}
+}
+
+func TestUpstreamEventHeaders(t *testing.T) {
+	i, events := newLedgeredInterceptor(t, "version: \"1.0\"\ndefaults:\n  upstream_event_headers: true\npolicies: []\n")
+	var gotID, gotHash string
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotID, gotHash = r.Header.Get(EventIDHeader), r.Header.Get(EventHashHeader)
+	})
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"jsonrpc":"2.0","id":1,"method":"crm:update","params":{"account":"a-1"}}`))
+	req.Header.Set(EventHashHeader, "forged")
+	i.Handler(next).ServeHTTP(httptest.NewRecorder(), req)
+
+	var call *models.Event
+	all := events()
+	for j := range all {
+		if all[j].EventType == "tool_call" {
+			call = &all[j]
+		}
+	}
+	if call == nil {
+		t.Fatal("no tool_call recorded")
+	}
+	want, err := crypto.PayloadHash(call.Method, call.Params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gotID != call.ID || gotHash != want {
+		t.Errorf("upstream saw event %q hash %q, want %q %q", gotID, gotHash, call.ID, want)
+	}
+}
+
+func TestResponseCarriesItsCallsPolicyOutcome(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":{"ok":true}}`))
+	}))
+	defer upstream.Close()
+
+	i, events := newLedgeredInterceptor(t, `
+version: "1.0"
+policies:
+  - id: "drop-table"
+    match_methods: ["db:drop_table"]
+    risk_level: "critical"
+`)
+	target, _ := url.Parse(upstream.URL)
+	proxy := httputil.NewSingleHostReverseProxy(target)
+	proxy.ModifyResponse = i.InterceptResponse
+	h := i.Handler(proxy)
+	for _, call := range []string{
+		`"method":"db:drop_table","params":{"table":"users"}`,
+		`"method":"shell:exec","params":{"command":"rm -rf /"}`,
+		`"method":"fs:stat","params":{"path":"/tmp"}`,
+	} {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"jsonrpc":"2.0","id":1,`+call+`}`)))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s: status %d %s", call, rec.Code, rec.Body.String())
+		}
+	}
+
+	calls := map[string]models.Event{}
+	var responses []models.Event
+	for _, e := range events() {
+		switch e.EventType {
+		case "tool_call":
+			calls[e.ID] = e
+		case "tool_response":
+			responses = append(responses, e)
+		}
+	}
+	if len(responses) != 3 {
+		t.Fatalf("expected 3 tool_responses, got %d", len(responses))
+	}
+	want := map[string]string{"db:drop_table": "critical", "shell:exec": "critical", "fs:stat": ""}
+	for _, resp := range responses {
+		call, ok := calls[resp.ParentID]
+		if !ok {
+			t.Fatalf("response %s is not linked to its call", resp.ID)
+		}
+		if resp.PolicyID != call.PolicyID || resp.RiskLevel != call.RiskLevel || resp.RiskLevel != want[call.Method] {
+			t.Errorf("%s: response has policy %q risk %q, call has %q %q", call.Method, resp.PolicyID, resp.RiskLevel, call.PolicyID, call.RiskLevel)
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package interceptor
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// maxDecodedBody bounds how far a compressed response is inflated for inspection, so a
+// small upstream body cannot expand without limit in the proxy.
+const maxDecodedBody = 32 << 20
+
+// responseBody is a buffered upstream response body. raw is what the upstream sent; plain
+// is raw with the Content-Encoding removed, and is what the interceptor parses.
+type responseBody struct {
+	raw      []byte
+	plain    []byte
+	encoding string // normalized Content-Encoding, "" for identity
+}
+
+// readResponseBody buffers resp.Body and decodes it. An encoding the proxy cannot decode
+// leaves plain nil; the raw body is then forwarded untouched.
+func readResponseBody(resp *http.Response) (*responseBody, error) {
+	raw, err := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if err != nil {
+		return nil, fmt.Errorf("reading upstream response: %w", err)
+	}
+	body := &responseBody{raw: raw, encoding: contentEncoding(resp.Header)}
+	body.plain, err = decodeBody(body.encoding, raw)
+	return body, err
+}
+
+// forward puts the buffered body back on resp unchanged. The body was read in full, so
+// a chunked upstream response is forwarded with an exact Content-Length.
+func (b *responseBody) forward(resp *http.Response) {
+	setResponseBody(resp, b.raw)
+}
+
+// rewrite replaces the body with plain, re-encoded with the upstream's Content-Encoding
+// so the agent still receives what it negotiated. Content-Length follows the new body.
+func (b *responseBody) rewrite(resp *http.Response, plain []byte) error {
+	encoded, err := encodeBody(b.encoding, plain)
+	if err != nil {
+		return err
+	}
+	b.plain = plain
+	b.raw = encoded
+	setResponseBody(resp, encoded)
+	return nil
+}
+
+func setResponseBody(resp *http.Response, body []byte) {
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	resp.ContentLength = int64(len(body))
+	resp.TransferEncoding = nil
+	resp.Header.Set("Content-Length", strconv.Itoa(len(body)))
+}
+
+// contentEncoding returns the single coding applied to the body, or "" for identity.
+// Stacked codings ("gzip, br") are reported verbatim and treated as undecodable.
+func contentEncoding(h http.Header) string {
+	enc := strings.ToLower(strings.TrimSpace(h.Get("Content-Encoding")))
+	if enc == "identity" {
+		return ""
+	}
+	return enc
+}
+
+// decodeBody removes the content coding from data. deflate accepts both the zlib-wrapped
+// form the HTTP spec requires and the raw form some servers send.
+func decodeBody(encoding string, data []byte) ([]byte, error) {
+	if encoding == "" || len(data) == 0 {
+		return data, nil
+	}
+	var r io.ReadCloser
+	var err error
+	switch encoding {
+	case "gzip", "x-gzip":
+		r, err = gzip.NewReader(bytes.NewReader(data))
+	case "deflate":
+		r, err = zlib.NewReader(bytes.NewReader(data))
+		if err != nil {
+			r, err = flate.NewReader(bytes.NewReader(data)), nil
+		}
+	default:
+		return nil, fmt.Errorf("unsupported content encoding %q", encoding)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("decoding %s body: %w", encoding, err)
+	}
+	defer r.Close()
+	plain, err := io.ReadAll(io.LimitReader(r, maxDecodedBody+1))
+	if err != nil {
+		return nil, fmt.Errorf("decoding %s body: %w", encoding, err)
+	}
+	if len(plain) > maxDecodedBody {
+		return nil, fmt.Errorf("decoded %s body exceeds %d bytes", encoding, maxDecodedBody)
+	}
+	return plain, nil
+}
+
+// encodeBody applies encoding to data; the inverse of decodeBody.
+func encodeBody(encoding string, data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	var w io.WriteCloser
+	switch encoding {
+	case "":
+		return data, nil
+	case "gzip", "x-gzip":
+		w = gzip.NewWriter(&buf)
+	case "deflate":
+		w = zlib.NewWriter(&buf)
+	default:
+		return nil, fmt.Errorf("unsupported content encoding %q", encoding)
+	}
+	if _, err := w.Write(data); err != nil {
+		return nil, fmt.Errorf("encoding %s body: %w", encoding, err)
+	}
+	if err := w.Close(); err != nil {
+		return nil, fmt.Errorf("encoding %s body: %w", encoding, err)
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,100 @@
+package interceptor
+
+import (
+	"bytes"
+	"compress/flate"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+)
+
+func TestDecodeBodyRoundTrip(t *testing.T) {
+	plain := []byte(`{"jsonrpc":"2.0","id":1,"result":{"ok":true}}`)
+	for _, enc := range []string{"", "gzip", "deflate"} {
+		encoded, err := encodeBody(enc, plain)
+		if err != nil {
+			t.Fatalf("%q encode: %v", enc, err)
+		}
+		got, err := decodeBody(enc, encoded)
+		if err != nil || !bytes.Equal(got, plain) {
+			t.Fatalf("%q round trip: got %q (%v)", enc, got, err)
+		}
+	}
+
+	// Raw deflate without the zlib wrapper is still accepted.
+	var raw bytes.Buffer
+	w, _ := flate.NewWriter(&raw, flate.DefaultCompression)
+	_, _ = w.Write(plain)
+	_ = w.Close()
+	if got, err := decodeBody("deflate", raw.Bytes()); err != nil || !bytes.Equal(got, plain) {
+		t.Fatalf("raw deflate: got %q (%v)", got, err)
+	}
+
+	if _, err := decodeBody("br", plain); err == nil {
+		t.Error("expected an error for an unsupported encoding")
+	}
+}
+
+func TestInterceptResponseDecodesGzip(t *testing.T) {
+	i, events := newLedgeredInterceptor(t, "")
+	plain := []byte(`{"jsonrpc":"2.0","id":7,"result":{"content":"hello"}}`)
+	compressed, err := encodeBody("gzip", plain)
+	if err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/", nil)
+	req = req.WithContext(withCallState(req.Context(), &callState{callID: "call-1", corr: correlation{requestID: "req-1"}}))
+	resp := &http.Response{
+		StatusCode:       http.StatusOK,
+		Header:           http.Header{"Content-Encoding": {"gzip"}},
+		Body:             io.NopCloser(bytes.NewReader(compressed)),
+		ContentLength:    -1,
+		TransferEncoding: []string{"chunked"},
+		Request:          req,
+	}
+	if err := i.InterceptResponse(resp); err != nil {
+		t.Fatalf("intercept: %v", err)
+	}
+
+	forwarded, _ := io.ReadAll(resp.Body)
+	if !bytes.Equal(forwarded, compressed) {
+		t.Error("compressed body was not forwarded unchanged")
+	}
+	if resp.ContentLength != int64(len(compressed)) || resp.Header.Get("Content-Length") != strconv.Itoa(len(compressed)) || resp.TransferEncoding != nil {
+		t.Errorf("unexpected length handling: %d %q %v", resp.ContentLength, resp.Header.Get("Content-Length"), resp.TransferEncoding)
+	}
+
+	var found bool
+	for _, e := range events() {
+		if e.EventType == "tool_response" {
+			found = e.Response["content"] == "hello" && e.ParentID == "call-1"
+		}
+	}
+	if !found {
+		t.Fatal("decoded result was not ledgered")
+	}
+}
+
+func TestResponseBodyRewriteReencodes(t *testing.T) {
+	compressed, _ := encodeBody("gzip", []byte(`{"secret":"x"}`))
+	resp := &http.Response{Header: http.Header{"Content-Encoding": {"gzip"}}, Body: io.NopCloser(bytes.NewReader(compressed))}
+	body, err := readResponseBody(resp)
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if err := body.rewrite(resp, []byte(`{"secret":"[REDACTED]"}`)); err != nil {
+		t.Fatalf("rewrite: %v", err)
+	}
+
+	sent, _ := io.ReadAll(resp.Body)
+	if resp.Header.Get("Content-Length") != strconv.Itoa(len(sent)) {
+		t.Errorf("Content-Length %q does not match body length %d", resp.Header.Get("Content-Length"), len(sent))
+	}
+	got, err := decodeBody("gzip", sent)
+	if err != nil || string(got) != `{"secret":"[REDACTED]"}` {
+		t.Fatalf("client body not re-compressed: %q (%v)", got, err)
+	}
+}
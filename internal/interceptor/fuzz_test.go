@@ -0,0 +1,105 @@
+package interceptor
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/slyt3/Logryph/internal/assert"
+)
+
+// quietAsserts turns assertion panics into errors for the length of a fuzz target, so
+// only genuine crashes fail it.
+func quietAsserts(f *testing.F) {
+	oldStrictMode, oldSuppressLogs := assert.StrictMode, assert.SuppressLogs
+	assert.StrictMode, assert.SuppressLogs = false, true
+	f.Cleanup(func() { assert.StrictMode, assert.SuppressLogs = oldStrictMode, oldSuppressLogs })
+}
+
+// parserSeeds are request bodies covering the crash classes untrusted agents can send:
+// deep nesting, numbers outside float64, invalid UTF-8 and non-object params.
+func parserSeeds(f *testing.F) {
+	f.Add([]byte(`{"jsonrpc":"2.0","id":1,"method":"stripe:charge","params":{"amount":5000,"task_id":"t-1"}}`))
+	f.Add([]byte(`{"jsonrpc":"2.0","id":"x","method":"tools/call","params":{"name":"db:query","arguments":{"sql":"DROP TABLE users"}}}`))
+	f.Add([]byte(`{"jsonrpc":"2.0","id":1,"method":"m","params":{"n":1e400}}`))
+	f.Add([]byte(`{"jsonrpc":"2.0","id":123456789012345678901234567890,"method":"m","params":{"n":-0.0000001e-400}}`))
+	f.Add([]byte("{\"jsonrpc\":\"2.0\",\"method\":\"a\xff\xfe\",\"params\":{\"k\xc3\":\"\xed\xa0\x80\"}}"))
+	f.Add([]byte(`{"jsonrpc":"2.0","method":"m","params":[1,2,3]}`))
+	f.Add([]byte(`{"jsonrpc":"2.0","method":"m","params":{"task_id":{"nested":true}}}`))
+	f.Add([]byte(`{"jsonrpc":"2.0","method":"m","params":{"a":` + strings.Repeat(`{"a":`, 200) + `1` + strings.Repeat(`}`, 201) + `}`))
+	f.Add([]byte(`{"jsonrpc":"2.0","method":"m","params":{"a":` + strings.Repeat(`[`, 5000) + strings.Repeat(`]`, 5000) + `}}`))
+}
+
+func TestExtractTaskMetadataRejectsHostileBodies(t *testing.T) {
+	i := &Interceptor{}
+	for name, body := range map[string]string{
+		"invalid utf8": "{\"jsonrpc\":\"2.0\",\"method\":\"stripe:charge\xff\",\"params\":{}}",
+		"huge number":  `{"jsonrpc":"2.0","method":"m","params":{"n":1e400}}`,
+	} {
+		if _, _, _, err := i.extractTaskMetadata([]byte(body)); err == nil {
+			t.Errorf("%s: accepted", name)
+		}
+	}
+}
+
+func FuzzExtractTaskMetadata(f *testing.F) {
+	quietAsserts(f)
+	parserSeeds(f)
+	i := &Interceptor{}
+	limits := i.requestLimits()
+	f.Fuzz(func(t *testing.T, body []byte) {
+		req, taskID, method, err := i.extractTaskMetadata(body)
+		if err != nil {
+			return
+		}
+		if req == nil || method == "" || method != req.Method {
+			t.Fatalf("accepted request without a method: %+v", req)
+		}
+		if taskID != "" && taskID != req.Params["task_id"] {
+			t.Fatalf("task id %q does not come from params", taskID)
+		}
+		if le := checkParams(req.Params, limits); le != nil && le.status() != http.StatusRequestEntityTooLarge {
+			t.Fatalf("params limit %s reported as %d", le.limit, le.status())
+		}
+	})
+}
+
+func FuzzRedactSensitiveData(f *testing.F) {
+	quietAsserts(f)
+	parserSeeds(f)
+	i := &Interceptor{}
+	f.Fuzz(func(t *testing.T, body []byte) {
+		out, err := i.redactSensitiveData(body, []string{"amount", "sql", "k�", "task_id"})
+		if err != nil {
+			return
+		}
+		var redacted struct {
+			Params map[string]interface{} `json:"params"`
+		}
+		if err := json.Unmarshal(out, &redacted); err != nil {
+			t.Fatalf("redacted body is not valid JSON: %v", err)
+		}
+		for _, key := range []string{"amount", "sql", "task_id"} {
+			if v, ok := redacted.Params[key]; ok && v != "[REDACTED]" {
+				t.Fatalf("%s survived redaction: %v", key, v)
+			}
+		}
+	})
+}
+
+func FuzzRedactResult(f *testing.F) {
+	quietAsserts(f)
+	f.Add([]byte(`{"jsonrpc":"2.0","id":1,"result":{"SecretAccessKey":"x","n":123456789012345678901234567890}}`))
+	f.Add([]byte(`{"jsonrpc":"2.0","id":1,"result":[` + strings.Repeat(`[`, 3000) + strings.Repeat(`]`, 3000) + `]}`))
+	f.Add([]byte("{\"result\":{\"secretaccesskey\xff\":1}}"))
+	f.Fuzz(func(t *testing.T, body []byte) {
+		out, n, err := redactResult(body, []string{"SecretAccessKey"})
+		if err != nil || n == 0 {
+			return
+		}
+		if !json.Valid(out) {
+			t.Fatalf("redacted response is not valid JSON")
+		}
+	})
+}
@@ -0,0 +1,91 @@
+package interceptor
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"sync/atomic"
+
+	"github.com/slyt3/Logryph/internal/logging"
+	"github.com/slyt3/Logryph/internal/observer"
+)
+
+// JSON-RPC error code returned to the agent when a fail_closed proxy cannot record calls.
+const codeLedgerUnavailable = -32005
+
+var (
+	ledgerRejections atomic.Uint64
+	unrecordedCalls  atomic.Uint64
+)
+
+// LedgerRejections returns how many calls were refused because the ledger could not
+// record them. Safe for concurrent access.
+func LedgerRejections() uint64 {
+	return ledgerRejections.Load()
+}
+
+// UnrecordedCalls returns how many calls were forwarded with only a logged digest while
+// the ledger could not record them. Safe for concurrent access.
+func UnrecordedCalls() uint64 {
+	return unrecordedCalls.Load()
+}
+
+// LedgerGuarantee returns the configured ledger guarantee, fail_open without a policy.
+func (i *Interceptor) LedgerGuarantee() string {
+	if i.Core == nil || i.Core.Observer == nil {
+		return observer.GuaranteeFailOpen
+	}
+	return i.Core.Observer.GetLedgerGuarantee()
+}
+
+// guardCall applies the ledger guarantee to every POST body before anything records it.
+func (i *Interceptor) guardCall(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if st := callStateFrom(r.Context()); st != nil && st.hasBody {
+			if rej := i.guardLedger(r, st.body); rej != nil {
+				i.reject(w, r, rej)
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// guardLedger applies the ledger guarantee to a call arriving while the worker is
+// unhealthy. fail_closed refuses the call; degraded logs its digest and lets it through;
+// fail_open lets it through as before.
+func (i *Interceptor) guardLedger(req *http.Request, body []byte) *Rejection {
+	if i.Core == nil || i.Core.Worker == nil || i.Core.Worker.IsHealthy() {
+		return nil
+	}
+	mode := i.LedgerGuarantee()
+	if mode == observer.GuaranteeFailOpen {
+		return nil
+	}
+	var peek struct {
+		ID     interface{} `json:"id"`
+		Method string      `json:"method"`
+	}
+	_ = json.Unmarshal(body, &peek)
+	fields := logging.Fields{Component: "interceptor", Method: peek.Method, Error: i.Core.Worker.UnhealthyReason()}
+	if st := callStateFrom(req.Context()); st != nil {
+		fields.CorrelationID, fields.TaskID, fields.TraceID = st.corr.requestID, st.corr.taskID, st.corr.traceID
+	}
+
+	if mode == observer.GuaranteeDegraded {
+		sum := sha256.Sum256(body)
+		fields.Digest = hex.EncodeToString(sum[:])
+		unrecordedCalls.Add(1)
+		logging.Warn("call_unrecorded", fields)
+		return nil
+	}
+	ledgerRejections.Add(1)
+	logging.Warn("call_rejected_ledger_unavailable", fields)
+	return &Rejection{
+		RequestID: peek.ID,
+		Status:    http.StatusServiceUnavailable,
+		Code:      codeLedgerUnavailable,
+		Message:   "Ledger unavailable: calls cannot be recorded",
+	}
+}
@@ -0,0 +1,57 @@
+package interceptor
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestLedgerGuaranteeWhileUnhealthy(t *testing.T) {
+	for _, c := range []struct {
+		mode       string
+		status     int
+		forwarded  bool
+		rejected   uint64
+		unrecorded uint64
+	}{
+		{"fail_open", http.StatusOK, true, 0, 0},
+		{"fail_closed", http.StatusServiceUnavailable, false, 1, 0},
+		{"degraded", http.StatusOK, true, 0, 1},
+	} {
+		t.Run(c.mode, func(t *testing.T) {
+			i, _ := newLedgeredInterceptor(t, "version: \"1.0\"\ndefaults:\n  ledger_guarantee: "+c.mode+"\npolicies: []\n")
+			i.Core.Worker.SetReadOnly("test: ledger unavailable")
+			rejected, unrecorded := LedgerRejections(), UnrecordedCalls()
+
+			forwarded := false
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				forwarded = true
+				_, _ = w.Write([]byte(`{"jsonrpc":"2.0","id":9,"result":{}}`))
+			})
+			body := `{"jsonrpc":"2.0","id":9,"method":"fs:write","params":{"path":"/tmp/x"}}`
+			rec := httptest.NewRecorder()
+			i.Handler(next).ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/", bytes.NewBufferString(body)))
+
+			if rec.Code != c.status || forwarded != c.forwarded {
+				t.Fatalf("status %d, forwarded %v; want %d, %v", rec.Code, forwarded, c.status, c.forwarded)
+			}
+			if LedgerRejections()-rejected != c.rejected || UnrecordedCalls()-unrecorded != c.unrecorded {
+				t.Errorf("rejections +%d, unrecorded +%d", LedgerRejections()-rejected, UnrecordedCalls()-unrecorded)
+			}
+			if c.status != http.StatusServiceUnavailable {
+				return
+			}
+			var resp struct {
+				ID    int `json:"id"`
+				Error struct {
+					Code int `json:"code"`
+				} `json:"error"`
+			}
+			if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil || resp.ID != 9 || resp.Error.Code != codeLedgerUnavailable {
+				t.Errorf("expected a JSON-RPC ledger error for id 9, got %q", rec.Body.String())
+			}
+		})
+	}
+}
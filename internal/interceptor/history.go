@@ -0,0 +1,167 @@
+package interceptor
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/slyt3/Logryph/internal/observer"
+	"github.com/slyt3/Logryph/internal/vql"
+)
+
+const (
+	maxHistoryTasks = 10000   // tasks remembered at once; idle ones are dropped first
+	maxCopyDepth    = 32      // params nested deeper are remembered as null
+	maxCopyValues   = 1 << 20 // params values copied per call; the rest are dropped
+)
+
+// taskHistories remembers each task's recent calls, for rules whose when: reads
+// history(). Calls are kept as the policy sees them: canonical method, params before
+// redaction, and environment.
+type taskHistories struct {
+	mu    sync.Mutex
+	tasks map[string]*taskCalls
+}
+
+type taskCalls struct {
+	calls    []pastCall // oldest first, at most the history size
+	lastSeen time.Time
+}
+
+type pastCall struct {
+	at  time.Time
+	env vql.MapEnv
+}
+
+// prior returns taskID's calls within h.MaxAge of now, most recent first.
+func (th *taskHistories) prior(taskID string, now time.Time, h observer.TaskHistory) []vql.Env {
+	th.mu.Lock()
+	defer th.mu.Unlock()
+	tc := th.tasks[taskID]
+	if tc == nil {
+		return nil
+	}
+	out := make([]vql.Env, 0, len(tc.calls))
+	for i := len(tc.calls) - 1; i >= 0 && len(out) < h.Size; i-- {
+		if now.Sub(tc.calls[i].at) > h.MaxAge {
+			break
+		}
+		out = append(out, tc.calls[i].env)
+	}
+	return out
+}
+
+// record remembers a call of taskID, forgetting the oldest past h.Size. params are
+// copied, since the ledger event that carries them is recycled.
+func (th *taskHistories) record(taskID string, now time.Time, h observer.TaskHistory, method string, params map[string]interface{}, env string) {
+	call := pastCall{at: now, env: vql.MapEnv{"method": method, "params": copyValue(params), "environment": env}}
+	th.mu.Lock()
+	defer th.mu.Unlock()
+	tc := th.tasks[taskID]
+	if tc == nil {
+		if th.tasks == nil {
+			th.tasks = make(map[string]*taskCalls)
+		}
+		th.prune(now, h.MaxAge)
+		tc = &taskCalls{}
+		th.tasks[taskID] = tc
+	}
+	tc.calls = append(tc.calls, call)
+	if excess := len(tc.calls) - h.Size; excess > 0 {
+		tc.calls = append(tc.calls[:0], tc.calls[excess:]...)
+	}
+	tc.lastSeen = now
+}
+
+// prune makes room for a new task once the map is full: tasks idle past maxAge go
+// first, then the least recently seen one. Callers hold th.mu.
+func (th *taskHistories) prune(now time.Time, maxAge time.Duration) {
+	if len(th.tasks) < maxHistoryTasks {
+		return
+	}
+	oldest, oldestSeen := "", now
+	for id, tc := range th.tasks {
+		if now.Sub(tc.lastSeen) > maxAge {
+			delete(th.tasks, id)
+			continue
+		}
+		if tc.lastSeen.Before(oldestSeen) {
+			oldest, oldestSeen = id, tc.lastSeen
+		}
+	}
+	if len(th.tasks) >= maxHistoryTasks {
+		delete(th.tasks, oldest)
+	}
+}
+
+// copyValue deep-copies the maps and lists of a decoded JSON value. Each frame holds a
+// value and the slot of the copy it goes into; a 1-element list holds the root.
+func copyValue(v interface{}) interface{} {
+	type copyFrame struct {
+		value interface{}
+		depth int
+		m     map[string]interface{} // the copy goes in m[key], or l[index] when m is nil
+		key   string
+		l     []interface{}
+		index int
+	}
+	root := make([]interface{}, 1)
+	stack := []copyFrame{{value: v, l: root}}
+	for n := 0; n < maxCopyValues && len(stack) > 0; n++ {
+		f := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		c := f.value
+		if f.depth > maxCopyDepth {
+			c = nil
+		}
+		switch t := c.(type) {
+		case map[string]interface{}:
+			if t == nil {
+				break
+			}
+			out := make(map[string]interface{}, len(t))
+			for k, item := range t {
+				stack = append(stack, copyFrame{value: item, depth: f.depth + 1, m: out, key: k})
+			}
+			c = out
+		case []interface{}:
+			out := make([]interface{}, len(t))
+			for i := 0; i < len(t); i++ {
+				stack = append(stack, copyFrame{value: t[i], depth: f.depth + 1, l: out, index: i})
+			}
+			c = out
+		}
+		if f.m != nil {
+			f.m[f.key] = c
+		} else {
+			f.l[f.index] = c
+		}
+	}
+	return root[0]
+}
+
+// rememberHistory gives a task's call its earlier calls for history() conditions, then
+// adds it to the history its successors see, whatever the policy decides.
+func (i *Interceptor) rememberHistory(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if st := callStateFrom(r.Context()); st != nil && st.call != nil {
+			c := st.call
+			if h, ok := i.taskHistory(c.taskID); ok {
+				now := time.Now()
+				c.prior = i.history.prior(c.taskID, now, h)
+				i.history.record(c.taskID, now, h, c.canonical, c.req.Params, c.env)
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// taskHistory returns the settings when some rule reads history() and the call belongs to
+// a task, and false otherwise.
+func (i *Interceptor) taskHistory(taskID string) (observer.TaskHistory, bool) {
+	if taskID == "" || i.Core == nil || i.Core.Observer == nil {
+		return observer.TaskHistory{}, false
+	}
+	h := i.Core.Observer.GetTaskHistory()
+	return h, h.Enabled
+}
@@ -0,0 +1,86 @@
+package interceptor
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/slyt3/Logryph/internal/observer"
+)
+
+func TestHistoryRuleMatchesOnTheTasksEarlierCalls(t *testing.T) {
+	policy := `
+version: "1.0"
+defaults:
+  enforcement_mode: enforce
+task_history:
+  size: 8
+policies:
+  - id: delete-after-passwd
+    match_methods: ["database:delete"]
+    risk_level: critical
+    when: history(method = "fs:read" and params.path = "/etc/passwd")
+`
+	i, events := newLedgeredInterceptor(t, policy)
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":{}}`))
+	})
+	call := func(task, method, params string) {
+		body := `{"jsonrpc":"2.0","id":1,"method":"` + method + `","params":{"task_id":"` + task + `"` + params + `}}`
+		rec := httptest.NewRecorder()
+		i.Handler(next).ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/", bytes.NewBufferString(body)))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s %s: status %d", task, method, rec.Code)
+		}
+	}
+
+	call("clean", "fs:read", `,"path":"/tmp/notes"`)
+	call("clean", "database:delete", `,"table":"users"`)
+	call("snoop", "fs:read", `,"path":"/etc/passwd"`)
+	call("snoop", "http:get", `,"url":"https://example.com"`)
+	call("snoop", "database:delete", `,"table":"users"`)
+
+	matched := map[string]string{}
+	for _, e := range events() {
+		if e.EventType == "tool_call" && e.Method == "database:delete" {
+			matched[e.TaskID] = e.PolicyID
+		}
+	}
+	if matched["snoop"] != "delete-after-passwd" {
+		t.Errorf("the task that read /etc/passwd should match the rule, got %q", matched["snoop"])
+	}
+	if matched["clean"] != "" {
+		t.Errorf("another task's history must not count, got %q", matched["clean"])
+	}
+}
+
+func TestTaskHistoriesBoundSizeAndAge(t *testing.T) {
+	var th taskHistories
+	h := observer.TaskHistory{Enabled: true, Size: 3, MaxAge: time.Minute}
+	start := time.Now()
+	for j := 0; j < 5; j++ {
+		th.record("t", start.Add(time.Duration(j)*time.Second), h, "m", map[string]interface{}{"n": float64(j)}, "")
+	}
+	prior := th.prior("t", start.Add(5*time.Second), h)
+	if len(prior) != 3 {
+		t.Fatalf("kept %d calls, want the newest 3", len(prior))
+	}
+	if v, _ := prior[0].Field("params"); v.(map[string]interface{})["n"] != 4.0 {
+		t.Errorf("most recent call should come first, got %v", v)
+	}
+	if prior := th.prior("t", start.Add(2*time.Minute), h); len(prior) != 0 {
+		t.Errorf("calls older than max_age should be forgotten, got %d", len(prior))
+	}
+
+	params := map[string]interface{}{"path": "/etc/passwd", "opts": map[string]interface{}{"x": 1.0}}
+	th.record("copy", start, h, "fs:read", params, "")
+	delete(params, "path")
+	params["opts"].(map[string]interface{})["x"] = 2.0
+	v, _ := th.prior("copy", start, h)[0].Field("params")
+	if got := v.(map[string]interface{}); got["path"] != "/etc/passwd" || got["opts"].(map[string]interface{})["x"] != 1.0 {
+		t.Errorf("recorded params should be a copy, got %v", got)
+	}
+}
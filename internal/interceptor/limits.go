@@ -0,0 +1,170 @@
+package interceptor
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/slyt3/Logryph/internal/logging"
+	"github.com/slyt3/Logryph/internal/observer"
+	"github.com/slyt3/Logryph/internal/pool"
+)
+
+// EventRequestRejectedLimits records a request refused because it broke one of the
+// proxy listener's limits. The request was never forwarded.
+const EventRequestRejectedLimits = "request_rejected_limits"
+
+// codeLimitExceeded is the JSON-RPC error code sent with limit rejections (Invalid Request).
+const codeLimitExceeded = -32600
+
+// limitError names the limit a request broke. For bodies, value may be a lower bound:
+// reading stops one byte past the larger body limit.
+type limitError struct {
+	limit string
+	value int64
+	max   int64
+}
+
+func (e *limitError) Error() string {
+	return fmt.Sprintf("request exceeds %s: %d > %d", e.limit, e.value, e.max)
+}
+
+// status is 431 for headers and 413 for everything else.
+func (e *limitError) status() int {
+	if e.limit == "max_header_bytes" {
+		return http.StatusRequestHeaderFieldsTooLarge
+	}
+	return http.StatusRequestEntityTooLarge
+}
+
+// limitRequest refuses requests past the proxy listener's header and body limits. It
+// reads a POST body once, into the call state, for the stages after it; params depth and
+// array length are checked once the body is decoded.
+func (i *Interceptor) limitRequest(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lim := i.requestLimits()
+		if n := headerBytes(r); n > int64(lim.MaxHeaderBytes) {
+			i.reject(w, r, i.rejectLimits(r, &limitError{limit: "max_header_bytes", value: n, max: int64(lim.MaxHeaderBytes)}, "", nil))
+			return
+		}
+		st := callStateFrom(r.Context())
+		if st == nil || r.Method != http.MethodPost || r.Body == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		buf := pool.GetBuffer()
+		defer pool.PutBuffer(buf)
+		// Read at most one byte past the larger body limit; anything longer is refused below.
+		if _, err := buf.ReadFrom(io.LimitReader(r.Body, max(lim.MaxBodyBytes, lim.MaxUploadBytes)+1)); err != nil {
+			logging.Error("request_body_read_failed", logging.Fields{Component: "interceptor", Error: err.Error()})
+			next.ServeHTTP(w, r)
+			return
+		}
+		st.body, st.hasBody = buf.Bytes(), true
+		r.Body = io.NopCloser(bytes.NewBuffer(st.body))
+		st.jsonBody = len(st.body) == 0 || isJSONPayload(r.Header.Get("Content-Type"), st.body)
+		if le := bodyLimit(lim, st.jsonBody, len(st.body)); le != nil {
+			i.reject(w, r, i.rejectLimits(r, le, "", nil))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// requestLimits returns the proxy listener's limits, or the defaults with no policy.
+func (i *Interceptor) requestLimits() observer.ListenerLimits {
+	if i.Core == nil || i.Core.Observer == nil {
+		return observer.ListenerLimits{}.WithDefaults()
+	}
+	return i.Core.Observer.GetLimits(observer.ListenerProxy)
+}
+
+// headerBytes approximates the size of the request line and headers as sent.
+func headerBytes(req *http.Request) int64 {
+	n := int64(len(req.Method) + len(req.RequestURI) + len(req.Proto) + len(req.Host) + 12)
+	for k, values := range req.Header {
+		for j := 0; j < len(values); j++ {
+			n += int64(len(k) + len(values[j]) + 4)
+		}
+	}
+	return n
+}
+
+// bodyLimit returns the limit that applies to a body of the given kind.
+func bodyLimit(lim observer.ListenerLimits, isJSON bool, size int) *limitError {
+	if isJSON && int64(size) > lim.MaxBodyBytes {
+		return &limitError{limit: "max_body_bytes", value: int64(size), max: lim.MaxBodyBytes}
+	}
+	if !isJSON && int64(size) > lim.MaxUploadBytes {
+		return &limitError{limit: "max_upload_bytes", value: int64(size), max: lim.MaxUploadBytes}
+	}
+	return nil
+}
+
+// checkParams reports the first depth or array length limit params breaks. params is
+// level 1; the walk never descends past the depth limit. Every value takes at least a
+// byte of the body, so the walk visits no more values than the body limit.
+func checkParams(params map[string]interface{}, lim observer.ListenerLimits) *limitError {
+	type shapeFrame struct {
+		value interface{}
+		level int
+	}
+	stack := []shapeFrame{{params, 1}}
+	for n := int64(0); n <= lim.MaxBodyBytes && len(stack) > 0; n++ {
+		f := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		switch val := f.value.(type) {
+		case map[string]interface{}:
+			if f.level > lim.MaxParamsDepth {
+				return &limitError{limit: "max_params_depth", value: int64(f.level), max: int64(lim.MaxParamsDepth)}
+			}
+			for _, child := range val {
+				stack = append(stack, shapeFrame{child, f.level + 1})
+			}
+		case []interface{}:
+			if f.level > lim.MaxParamsDepth {
+				return &limitError{limit: "max_params_depth", value: int64(f.level), max: int64(lim.MaxParamsDepth)}
+			}
+			if len(val) > lim.MaxArrayLen {
+				return &limitError{limit: "max_array_len", value: int64(len(val)), max: int64(lim.MaxArrayLen)}
+			}
+			for j := 0; j < len(val); j++ {
+				stack = append(stack, shapeFrame{val[j], f.level + 1})
+			}
+		}
+	}
+	return nil
+}
+
+// rejectLimits ledgers a request_rejected_limits event and returns the rejection sent
+// to the agent. method and rpcID are empty when the body was not parsed.
+func (i *Interceptor) rejectLimits(req *http.Request, le *limitError, method string, rpcID interface{}) *Rejection {
+	if method == "" {
+		method = "http:" + strings.ToLower(req.Method)
+	}
+	corr := callCorrelation(req)
+	logging.Warn("request_rejected_limits", logging.Fields{Component: "interceptor", Method: method, TaskID: corr.taskID, CorrelationID: corr.requestID, Error: le.Error()})
+	if i.Core != nil && i.Core.Worker != nil {
+		event := pool.GetEvent()
+		event.ID = uuid.New().String()[:8]
+		event.Timestamp = time.Now()
+		event.Actor = "agent"
+		event.EventType = EventRequestRejectedLimits
+		event.Method = method
+		event.TaskID = corr.taskID
+		event.Environment = i.resolveEnvironment(req)
+		event.CorrelationID = corr.requestID
+		event.TraceID = corr.traceID
+		event.SpanID = corr.spanID
+		event.Params["listener"] = observer.ListenerProxy
+		event.Params["limit"] = le.limit
+		event.Params["value"] = le.value
+		event.Params["max"] = le.max
+		i.Core.Worker.Submit(event)
+	}
+	return &Rejection{RequestID: rpcID, Status: le.status(), Code: codeLimitExceeded, Message: le.Error()}
+}
@@ -0,0 +1,95 @@
+package interceptor
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+const limitsPolicy = `
+version: "1.0"
+limits:
+  proxy:
+    max_body_bytes: 2048
+    max_upload_bytes: 4096
+    max_header_bytes: 2048
+    max_params_depth: 4
+    max_array_len: 8
+policies: []
+`
+
+func TestHandlerEnforcesRequestLimits(t *testing.T) {
+	i, events := newLedgeredInterceptor(t, limitsPolicy)
+	forwarded := 0
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { forwarded++ })
+	call := func(body, contentType string, header map[string]string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/", bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", contentType)
+		for k, v := range header {
+			req.Header.Set(k, v)
+		}
+		rec := httptest.NewRecorder()
+		i.Handler(next).ServeHTTP(rec, req)
+		return rec
+	}
+	rpc := func(params string) string {
+		return `{"jsonrpc":"2.0","id":3,"method":"db:query","params":` + params + `}`
+	}
+
+	cases := []struct {
+		name, body, contentType string
+		header                  map[string]string
+		status                  int
+	}{
+		{"within limits", rpc(`{"a":[[1,2]]}`), "application/json", nil, http.StatusOK},
+		{"json body", rpc(`{"q":"` + strings.Repeat("x", 3000) + `"}`), "application/json", nil, http.StatusRequestEntityTooLarge},
+		{"upload within its limit", strings.Repeat("u", 3000), "application/octet-stream", nil, http.StatusOK},
+		{"upload", strings.Repeat("u", 5000), "application/octet-stream", nil, http.StatusRequestEntityTooLarge},
+		{"depth", rpc(`{"a":[[[[1]]]]}`), "application/json", nil, http.StatusRequestEntityTooLarge},
+		{"array", rpc(`{"a":[1,2,3,4,5,6,7,8,9]}`), "application/json", nil, http.StatusRequestEntityTooLarge},
+		{"headers", rpc(`{}`), "application/json", map[string]string{"X-Padding": strings.Repeat("h", 2100)}, http.StatusRequestHeaderFieldsTooLarge},
+	}
+	for _, tc := range cases {
+		rec := call(tc.body, tc.contentType, tc.header)
+		if rec.Code != tc.status {
+			t.Errorf("%s: status %d, want %d", tc.name, rec.Code, tc.status)
+			continue
+		}
+		if tc.status == http.StatusOK {
+			continue
+		}
+		var resp struct {
+			Error struct {
+				Code int `json:"code"`
+			} `json:"error"`
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil || resp.Error.Code != codeLimitExceeded {
+			t.Errorf("%s: expected a JSON-RPC limit error, got %q", tc.name, rec.Body.String())
+		}
+	}
+	if forwarded != 2 {
+		t.Errorf("forwarded %d requests, want only the 2 within limits", forwarded)
+	}
+
+	limits := map[string]int{}
+	for _, e := range events() {
+		if e.EventType != EventRequestRejectedLimits {
+			continue
+		}
+		limit, _ := e.Params["limit"].(string)
+		limits[limit]++
+		if limit == "max_params_depth" && e.Method != "db:query" {
+			t.Errorf("a parsed call should be recorded under its method, got %q", e.Method)
+		}
+	}
+	want := map[string]int{"max_body_bytes": 1, "max_upload_bytes": 1, "max_params_depth": 1, "max_array_len": 1, "max_header_bytes": 1}
+	for limit, n := range want {
+		if limits[limit] != n {
+			t.Errorf("request_rejected_limits events = %v, want %v", limits, want)
+			break
+		}
+	}
+}
@@ -0,0 +1,209 @@
+package interceptor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"runtime/debug"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/slyt3/Logryph/internal/assert"
+	"github.com/slyt3/Logryph/internal/logging"
+	"github.com/slyt3/Logryph/internal/observer"
+	"github.com/slyt3/Logryph/internal/pool"
+)
+
+// EventCallAborted marks calls the agent abandoned (disconnected or canceled) before a
+// response came back, so traces tell them apart from upstream failures.
+const EventCallAborted = "call_aborted"
+
+// Stages at which a call can be abandoned.
+const (
+	abortStageApproval = "awaiting_approval"
+	abortStageUpstream = "awaiting_upstream"
+)
+
+// callState is the interception state of one proxied exchange. It lives in the request
+// context rather than on the Interceptor, so concurrent calls never share it and the
+// response hook can find the call it answers.
+type callState struct {
+	corr       correlation
+	callID     string // ID of the ledgered tool_call event, empty if none was recorded
+	taskID     string
+	method     string
+	tool       string // the tools/call target, else method; latency is tracked per tool
+	env        string
+	started    time.Time
+	responded  bool // a tool_response was recorded for this call
+	idempotent bool // the matched rule allows retrying transient upstream failures
+	rpcID      interface{}
+	timeout    time.Duration              // upstream deadline from the policy, zero for none
+	forwarded  time.Time                  // when the call was sent upstream, after any approval stall
+	redact     []string                   // result fields the matched rule scrubs from the response
+	rule       string                     // ID of the matched rule
+	risk       string                     // risk level recorded on the tool_call
+	ruleCap    observer.ConcurrencyPolicy // the matched rule's max_concurrent, zero for none
+	sampledOut bool                       // the tool_call was recorded without its params
+	body       []byte                     // the POST body, read by the limits stage
+	hasBody    bool                       // a POST body was read; it may still be empty
+	jsonBody   bool                       // the body goes through JSON-RPC interception
+	call       *rpcCall                   // the decoded call; nil for other bodies and calls forwarded uninspected
+}
+
+type callStateKey struct{}
+
+func withCallState(ctx context.Context, st *callState) context.Context {
+	return context.WithValue(ctx, callStateKey{}, st)
+}
+
+// callStateFrom returns the exchange's state, or nil outside the Handler chain.
+func callStateFrom(ctx context.Context) *callState {
+	if ctx == nil {
+		return nil
+	}
+	st, _ := ctx.Value(callStateKey{}).(*callState)
+	return st
+}
+
+// Handler wraps the upstream proxy with the interception chain. Each stage handles one
+// concern and passes the request on, or answers it with a rejection:
+//
+//   - panic isolation, then the call state with its request and trace IDs
+//   - listener header and body limits, which read the body once for the stages after
+//   - the ledger guarantee, and evidence for non-JSON bodies such as uploads
+//   - JSON-RPC decoding with the params limits, then the task's history and budget
+//   - policy evaluation, content checks and the tool_call event
+//   - the budget action, then enforce-mode rejections and stalls
+//   - the per-rule and upstream concurrency caps and the upstream deadline
+//
+// The request context carries the call state to the response hook and bounds stalls and
+// queueing, so a disconnecting agent releases its goroutine.
+func (i *Interceptor) Handler(next http.Handler) http.Handler {
+	if err := assert.NotNil(next, "next handler"); err != nil {
+		return http.NotFoundHandler()
+	}
+	stages := [...]func(http.Handler) http.Handler{
+		i.trackCall,
+		i.limitRequest,
+		i.guardCall,
+		i.recordUpload,
+		i.decodeCall,
+		i.rememberHistory,
+		i.chargeTask,
+		i.interceptCall,
+		i.applyTaskBudget,
+		i.enforceCall,
+		i.limitConcurrency,
+	}
+	h := next
+	for j := len(stages) - 1; j >= 0; j-- {
+		h = stages[j](h)
+	}
+	return i.recoverPanics(h)
+}
+
+// trackCall starts the exchange's call state and assigns its request and trace IDs. When
+// the proxy names the ledger event to the upstream, an agent's own values are dropped.
+func (i *Interceptor) trackCall(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		st := &callState{corr: requestCorrelation(r), started: time.Now()}
+		st.corr.requestID = EnsureRequestID(r)
+		st.corr.traceID, st.corr.spanID = EnsureTraceparent(r)
+		if i.Core != nil && i.Core.Observer != nil && i.Core.Observer.SendsUpstreamEventHeaders() {
+			r.Header.Del(EventIDHeader)
+			r.Header.Del(EventHashHeader)
+		}
+		next.ServeHTTP(w, r.WithContext(withCallState(r.Context(), st)))
+	})
+}
+
+// reject answers a call a stage refused. A recorded call the agent gave up on while it
+// was held is ledgered as aborted.
+func (i *Interceptor) reject(w http.ResponseWriter, r *http.Request, err error) {
+	if st := callStateFrom(r.Context()); st != nil {
+		i.recordAbort(r.Context(), st, abortStageApproval)
+		w.Header().Set(RequestIDHeader, st.corr.requestID)
+	}
+	i.WriteRejection(w, err)
+}
+
+// recoverPanics confines a panic to the request that raised it. The agent gets a JSON-RPC
+// error if nothing was written yet; http.ErrAbortHandler keeps its meaning.
+func (i *Interceptor) recoverPanics(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tw := &trackingWriter{ResponseWriter: w}
+		defer func() {
+			rec := recover()
+			if rec == nil {
+				return
+			}
+			if rec == http.ErrAbortHandler {
+				panic(rec)
+			}
+			fields := logging.Fields{Component: "interceptor", Error: fmt.Sprint(rec)}
+			if st := callStateFrom(r.Context()); st != nil {
+				fields.CorrelationID = st.corr.requestID
+			}
+			logging.Error("proxy_handler_panic", fields)
+			logging.Debug("proxy_handler_panic_stack", logging.Fields{Component: "interceptor", Error: string(debug.Stack())})
+			if !tw.wrote {
+				i.WriteRejection(tw, nil)
+			}
+		}()
+		next.ServeHTTP(tw, r)
+	})
+}
+
+// trackingWriter records whether a response has started so a recovered panic does not
+// write a second status line. Unwrap keeps flushing available to the reverse proxy.
+type trackingWriter struct {
+	http.ResponseWriter
+	wrote bool
+}
+
+func (t *trackingWriter) WriteHeader(code int) {
+	t.wrote = true
+	t.ResponseWriter.WriteHeader(code)
+}
+
+func (t *trackingWriter) Write(b []byte) (int, error) {
+	t.wrote = true
+	return t.ResponseWriter.Write(b)
+}
+
+func (t *trackingWriter) Unwrap() http.ResponseWriter {
+	return t.ResponseWriter
+}
+
+// recordAbort ledgers a call_aborted event, linked to the tool_call, when the agent
+// canceled the request before any response was recorded. Timeouts are not aborts.
+func (i *Interceptor) recordAbort(ctx context.Context, st *callState, stage string) {
+	if st == nil || st.callID == "" || st.responded || !errors.Is(ctx.Err(), context.Canceled) {
+		return
+	}
+	if err := assert.Check(i.Core != nil && i.Core.Worker != nil, "worker must be initialized"); err != nil {
+		return
+	}
+	elapsed := time.Since(st.started)
+	logging.Warn("call_aborted", logging.Fields{Component: "interceptor", EventID: st.callID, TaskID: st.taskID, Method: st.method, CorrelationID: st.corr.requestID, TraceID: st.corr.traceID})
+
+	event := pool.GetEvent()
+	event.ID = uuid.New().String()[:8]
+	event.Timestamp = time.Now()
+	event.Actor = "agent"
+	event.EventType = EventCallAborted
+	event.Method = st.method
+	event.TaskID = st.taskID
+	event.ParentID = st.callID
+	event.Environment = st.env
+	event.CorrelationID = st.corr.requestID
+	event.TraceID = st.corr.traceID
+	event.SpanID = st.corr.spanID
+	event.Params["stage"] = stage
+	event.Params["elapsed_ms"] = elapsed.Milliseconds()
+	event.Params["reason"] = ctx.Err().Error()
+
+	i.Core.Worker.Submit(event)
+}
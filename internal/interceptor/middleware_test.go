@@ -0,0 +1,168 @@
+package interceptor
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/slyt3/Logryph/internal/core"
+	"github.com/slyt3/Logryph/internal/ledger"
+	"github.com/slyt3/Logryph/internal/ledger/store"
+	"github.com/slyt3/Logryph/internal/models"
+	"github.com/slyt3/Logryph/internal/observer"
+)
+
+func TestHandlerIsolatesPanics(t *testing.T) {
+	i := &Interceptor{}
+	h := i.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("upstream hook exploded")
+	}))
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if rec.Code != http.StatusBadGateway {
+		t.Fatalf("expected 502 after panic, got %d", rec.Code)
+	}
+	var body struct {
+		Error struct {
+			Code int `json:"code"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil || body.Error.Code != -32603 {
+		t.Fatalf("expected JSON-RPC internal error, got %q (%v)", rec.Body.String(), err)
+	}
+
+	// A second request through the same handler is unaffected.
+	ok := i.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	rec = httptest.NewRecorder()
+	ok.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("expected 204, got %d", rec.Code)
+	}
+}
+
+func TestHandlerKeepsAbortPanics(t *testing.T) {
+	h := (&Interceptor{}).Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic(http.ErrAbortHandler)
+	}))
+	defer func() {
+		if rec := recover(); rec != http.ErrAbortHandler {
+			t.Fatalf("expected ErrAbortHandler to propagate, got %v", rec)
+		}
+	}()
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+}
+
+func TestHandlerAttachesCallState(t *testing.T) {
+	var seen *callState
+	h := (&Interceptor{}).Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = callStateFrom(r.Context())
+		if got := callCorrelation(r).requestID; got != r.Header.Get(RequestIDHeader) {
+			t.Errorf("correlation %q does not match header %q", got, r.Header.Get(RequestIDHeader))
+		}
+	}))
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(TraceparentHeader, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+
+	if seen == nil {
+		t.Fatal("request context carries no call state")
+	}
+	if seen.corr.requestID == "" || seen.corr.traceID != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Fatalf("unexpected correlation: %+v", seen.corr)
+	}
+	if seen.started.IsZero() {
+		t.Error("start time not recorded")
+	}
+}
+
+func TestRecordAbortLedgersCanceledCalls(t *testing.T) {
+	i, events := newLedgeredInterceptor(t, "")
+
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	timedOut, cancelTimeout := context.WithTimeout(context.Background(), time.Nanosecond)
+	defer cancelTimeout()
+	<-timedOut.Done()
+
+	st := &callState{callID: "call-1", method: "fs:read", corr: correlation{requestID: "req-1"}, started: time.Now().Add(-time.Second)}
+	i.recordAbort(canceled, st, abortStageUpstream)
+	i.recordAbort(timedOut, &callState{callID: "call-2", method: "fs:read"}, abortStageUpstream)
+	i.recordAbort(canceled, &callState{callID: "call-3", method: "fs:read", responded: true}, abortStageUpstream)
+
+	var aborted []string
+	for _, e := range events() {
+		if e.EventType != EventCallAborted {
+			continue
+		}
+		aborted = append(aborted, e.ParentID)
+		if e.CorrelationID != "req-1" || e.Params["stage"] != abortStageUpstream {
+			t.Errorf("unexpected abort event: %+v", e)
+		}
+		if ms, _ := e.Params["elapsed_ms"].(float64); ms < 1000 {
+			t.Errorf("elapsed_ms not recorded: %v", e.Params["elapsed_ms"])
+		}
+	}
+	if len(aborted) != 1 || aborted[0] != "call-1" {
+		t.Fatalf("expected one abort linked to call-1, got %v", aborted)
+	}
+}
+
+// newLedgeredInterceptor builds an interceptor backed by a real worker and, when policy is
+// non-empty, an observer loaded from it. events stops the worker and returns the ledger.
+func newLedgeredInterceptor(t *testing.T, policy string) (*Interceptor, func() []models.Event) {
+	t.Helper()
+	dir := t.TempDir()
+	dbPath := filepath.Join(dir, "logryph_test.db")
+	db, err := store.NewDB(dbPath)
+	if err != nil {
+		t.Fatalf("store: %v", err)
+	}
+	worker, err := ledger.NewWorker(16, db, filepath.Join(dir, "test.key"))
+	if err != nil {
+		t.Fatalf("worker: %v", err)
+	}
+	if err := worker.Start(); err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	engine := &core.Engine{Worker: worker, ActiveTasks: &sync.Map{}, LastEventByTask: &sync.Map{}}
+	if policy != "" {
+		policyPath := filepath.Join(dir, "logryph-policy.yaml")
+		if err := os.WriteFile(policyPath, []byte(policy), 0o644); err != nil {
+			t.Fatalf("policy: %v", err)
+		}
+		if engine.Observer, err = observer.NewObserverEngine(policyPath); err != nil {
+			t.Fatalf("observer: %v", err)
+		}
+	}
+
+	events := func() []models.Event {
+		t.Helper()
+		if err := worker.Shutdown(2 * time.Second); err != nil {
+			t.Fatalf("shutdown: %v", err)
+		}
+		db, err := store.NewDB(dbPath)
+		if err != nil {
+			t.Fatalf("reopen: %v", err)
+		}
+		defer db.Close()
+		runID, err := db.GetRunID()
+		if err != nil {
+			t.Fatalf("run id: %v", err)
+		}
+		out, err := db.GetRecentEvents(runID, 100)
+		if err != nil {
+			t.Fatalf("events: %v", err)
+		}
+		return out
+	}
+	return NewInterceptor(engine), events
+}
@@ -0,0 +1,33 @@
+package interceptor
+
+import (
+	"time"
+
+	"github.com/slyt3/Logryph/internal/analyzer"
+	"github.com/slyt3/Logryph/internal/notify"
+	"github.com/slyt3/Logryph/internal/observer"
+)
+
+// notifyMatch fires the matched rule's notify channels for a recorded tool_call. It runs
+// before enforcement, so observe and enforce mode notify alike.
+func (i *Interceptor) notifyMatch(rule *observer.Rule, eventID, taskID, method, env string, insp *callInspection, corr correlation) {
+	if rule == nil || len(rule.Notify) == 0 || eventID == "" || i.Core.Notifier == nil {
+		return
+	}
+	n := notify.Notification{
+		RuleID:        rule.ID,
+		EventID:       eventID,
+		Method:        method,
+		TaskID:        taskID,
+		RiskLevel:     rule.RiskLevel,
+		Environment:   env,
+		CorrelationID: corr.requestID,
+		TraceID:       corr.traceID,
+		Timestamp:     time.Now().UTC(),
+	}
+	if insp != nil {
+		n.Tags = insp.tags
+		n.RiskLevel = analyzer.MaxRisk(rule.RiskLevel, insp.risk)
+	}
+	i.Core.Notifier.Notify(rule.Notify, n)
+}
@@ -0,0 +1,68 @@
+package interceptor
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/slyt3/Logryph/internal/notify"
+)
+
+func TestHandlerNotifiesOnMatch(t *testing.T) {
+	got := make(chan notify.Notification, 2)
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var n notify.Notification
+		b, _ := io.ReadAll(r.Body)
+		if err := json.Unmarshal(b, &n); err != nil {
+			t.Errorf("decode: %v", err)
+		}
+		got <- n
+	}))
+	defer hook.Close()
+
+	policy := strings.ReplaceAll(`
+version: "1.0"
+notifications:
+  channels:
+    - name: "billing-watch"
+      url: "HOOK"
+policies:
+  - id: "billing"
+    match_methods: ["stripe:*"]
+    risk_level: "high"
+    notify: ["billing-watch"]
+`, "HOOK", hook.URL)
+	i, events := newLedgeredInterceptor(t, policy)
+	var err error
+	if i.Core.Notifier, err = notify.NewDispatcher(i.Core.Observer.GetConfig().Notifications.Channels); err != nil {
+		t.Fatalf("dispatcher: %v", err)
+	}
+
+	h := i.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	for _, method := range []string{"stripe:refund", "fs:read"} {
+		body := `{"jsonrpc":"2.0","id":1,"method":"` + method + `","params":{}}`
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/", bytes.NewBufferString(body)))
+	}
+	if err := i.Core.Notifier.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	close(got)
+
+	var callID string
+	for _, e := range events() {
+		if e.EventType == "tool_call" && e.Method == "stripe:refund" {
+			callID = e.ID
+		}
+	}
+	var sent []notify.Notification
+	for n := range got {
+		sent = append(sent, n)
+	}
+	if len(sent) != 1 || sent[0].RuleID != "billing" || sent[0].EventID != callID || sent[0].RiskLevel != "high" {
+		t.Fatalf("expected one billing notification for %s, got %+v", callID, sent)
+	}
+}
@@ -0,0 +1,156 @@
+package interceptor
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/slyt3/Logryph/internal/assert"
+	"github.com/slyt3/Logryph/internal/attachments"
+	"github.com/slyt3/Logryph/internal/logging"
+	"github.com/slyt3/Logryph/internal/pool"
+)
+
+// EventPayloadObserved records a request body that is not JSON-RPC, such as a file upload.
+// The body itself is not ledgered: only its content type, size and SHA-256, plus the
+// same facts for each multipart part.
+const EventPayloadObserved = "payload_observed"
+
+const maxMultipartParts = 64
+
+// isJSONPayload reports whether a body should go through JSON-RPC interception. Bodies
+// without a content type, or sent as text/plain, are sniffed.
+func isJSONPayload(contentType string, body []byte) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if contentType == "" || err != nil || mediaType == "text/plain" {
+		return json.Valid(body)
+	}
+	return mediaType == "application/json" || mediaType == "text/json" || strings.HasSuffix(mediaType, "+json")
+}
+
+// recordUpload records evidence of a body that is not JSON-RPC, such as a file upload. It
+// is forwarded untouched; the JSON-RPC stages pass it by.
+func (i *Interceptor) recordUpload(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if st := callStateFrom(r.Context()); st != nil && st.hasBody && !st.jsonBody {
+			eventID := i.recordPayload(r, st.body)
+			st.callID, st.taskID, st.method = eventID, st.corr.taskID, "http:"+strings.ToLower(r.Method)
+			st.tool = st.method
+			st.env = i.resolveEnvironment(r)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// recordPayload ledgers evidence of a non-JSON body and, when store_bodies is enabled,
+// keeps the body (or each multipart part) in the attachment store. Returns the event ID.
+func (i *Interceptor) recordPayload(req *http.Request, body []byte) string {
+	if err := assert.Check(i.Core != nil && i.Core.Worker != nil, "worker must be initialized"); err != nil {
+		return ""
+	}
+	store := i.attachmentStore()
+	contentType := req.Header.Get("Content-Type")
+	var evidence map[string]interface{}
+	if mediaType, params, err := mime.ParseMediaType(contentType); err == nil && strings.HasPrefix(mediaType, "multipart/") {
+		// Parts are stored individually; the whole body is only hashed.
+		evidence = blobEvidence(nil, body)
+		if parts := multipartEvidence(store, body, params["boundary"]); len(parts) > 0 {
+			evidence["parts"] = parts
+		}
+	} else {
+		evidence = blobEvidence(store, body)
+	}
+	evidence["content_type"] = contentType
+	evidence["http_method"] = req.Method
+	evidence["path"] = req.URL.Path
+
+	corr := callCorrelation(req)
+	capture := i.captureRequest(req)
+	event := pool.GetEvent()
+	event.ID = uuid.New().String()[:8]
+	event.Timestamp = time.Now()
+	event.Actor = "agent"
+	event.EventType = EventPayloadObserved
+	event.Method = "http:" + strings.ToLower(req.Method)
+	event.Environment = i.resolveEnvironment(req)
+	event.TaskID = corr.taskID
+	event.CorrelationID = corr.requestID
+	event.TraceID = corr.traceID
+	event.SpanID = corr.spanID
+	event.Headers = capture.headers
+	event.QueryParams = capture.query
+	for k, v := range evidence {
+		event.Params[k] = v
+	}
+	logging.Info("payload_observed", logging.Fields{Component: "interceptor", Method: event.Method, CorrelationID: corr.requestID, TraceID: corr.traceID})
+
+	i.linkParent(event, corr.taskID, corr.parentID)
+
+	eventID := event.ID
+	i.Core.Worker.Submit(event)
+	return eventID
+}
+
+// attachmentStore returns the blob store when store_bodies is enabled.
+func (i *Interceptor) attachmentStore() *attachments.Store {
+	if i.Core.Attachments == nil || i.Core.Observer == nil || !i.Core.Observer.GetCapture().StoreBodies {
+		return nil
+	}
+	return i.Core.Attachments
+}
+
+// blobEvidence describes data and stores it if store is non-nil.
+func blobEvidence(store *attachments.Store, data []byte) map[string]interface{} {
+	out := map[string]interface{}{
+		"size":   len(data),
+		"sha256": attachments.Sum(data),
+	}
+	if store == nil || len(data) == 0 {
+		return out
+	}
+	if _, err := store.Put(data); err != nil {
+		logging.Error("attachment_store_failed", logging.Fields{Component: "interceptor", Error: err.Error()})
+		return out
+	}
+	out["stored"] = true
+	return out
+}
+
+// multipartEvidence describes each part of a multipart body. Parse errors end the walk;
+// whatever was read so far is still returned.
+func multipartEvidence(store *attachments.Store, body []byte, boundary string) []interface{} {
+	if boundary == "" {
+		return nil
+	}
+	reader := multipart.NewReader(bytes.NewReader(body), boundary)
+	var parts []interface{}
+	for n := 0; n < maxMultipartParts; n++ {
+		part, err := reader.NextRawPart()
+		if err != nil {
+			if err != io.EOF {
+				logging.Warn("multipart_parse_failed", logging.Fields{Component: "interceptor", Error: err.Error()})
+			}
+			break
+		}
+		data, err := io.ReadAll(io.LimitReader(part, attachments.MaxBlobBytes))
+		_ = part.Close()
+		if err != nil {
+			logging.Warn("multipart_parse_failed", logging.Fields{Component: "interceptor", Error: err.Error()})
+			break
+		}
+		entry := blobEvidence(store, data)
+		entry["field"] = part.FormName()
+		entry["content_type"] = part.Header.Get("Content-Type")
+		if name := part.FileName(); name != "" {
+			entry["filename"] = name
+		}
+		parts = append(parts, entry)
+	}
+	return parts
+}
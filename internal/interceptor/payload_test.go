@@ -0,0 +1,81 @@
+package interceptor
+
+import (
+	"bytes"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/slyt3/Logryph/internal/attachments"
+)
+
+func TestIsJSONPayload(t *testing.T) {
+	cases := []struct {
+		contentType string
+		body        string
+		want        bool
+	}{
+		{"application/json", `{"a":1}`, true},
+		{"application/vnd.api+json; charset=utf-8", `{}`, true},
+		{"", `{"jsonrpc":"2.0"}`, true},
+		{"text/plain", `not json`, false},
+		{"application/octet-stream", `{"a":1}`, false},
+		{"multipart/form-data; boundary=x", `--x`, false},
+	}
+	for _, c := range cases {
+		if got := isJSONPayload(c.contentType, []byte(c.body)); got != c.want {
+			t.Errorf("isJSONPayload(%q, %q) = %v, want %v", c.contentType, c.body, got, c.want)
+		}
+	}
+}
+
+func TestHandlerRecordsMultipartEvidence(t *testing.T) {
+	i, events := newLedgeredInterceptor(t, "version: \"1.0\"\ncapture:\n  store_bodies: true\npolicies: []\n")
+	store, err := attachments.NewStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	i.Core.Attachments = store
+
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	_ = mw.WriteField("purpose", "invoice")
+	fw, _ := mw.CreateFormFile("file", "invoice.pdf")
+	_, _ = fw.Write([]byte("%PDF-1.7 invoice"))
+	_ = mw.Close()
+
+	forwarded := false
+	h := i.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { forwarded = true }))
+	req := httptest.NewRequest(http.MethodPost, "/upload", bytes.NewReader(body.Bytes()))
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	h.ServeHTTP(httptest.NewRecorder(), req)
+	if !forwarded {
+		t.Fatal("non-JSON body must still be forwarded")
+	}
+
+	var found bool
+	for _, e := range events() {
+		if e.EventType != EventPayloadObserved {
+			continue
+		}
+		found = true
+		if e.Params["sha256"] != attachments.Sum(body.Bytes()) || e.Params["path"] != "/upload" {
+			t.Errorf("unexpected body evidence: %v", e.Params)
+		}
+		parts, _ := e.Params["parts"].([]interface{})
+		if len(parts) != 2 {
+			t.Fatalf("expected 2 parts, got %v", e.Params["parts"])
+		}
+		file, _ := parts[1].(map[string]interface{})
+		if file["filename"] != "invoice.pdf" || file["stored"] != true {
+			t.Errorf("file part not described or stored: %v", file)
+		}
+		if data, err := store.Open(attachments.Sum([]byte("%PDF-1.7 invoice"))); err != nil || string(data) != "%PDF-1.7 invoice" {
+			t.Errorf("file part not in the attachment store: %v", err)
+		}
+	}
+	if !found {
+		t.Fatal("no payload_observed event recorded")
+	}
+}
@@ -0,0 +1,124 @@
+package interceptor
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/slyt3/Logryph/internal/logging"
+)
+
+// TagResponseRedacted marks tool_response events whose result had fields scrubbed by a
+// rule's redact_response list before it was forwarded.
+const TagResponseRedacted = "response_redacted"
+
+const (
+	maxRedactDepth = 32
+	maxRedactNodes = 1 << 20 // maps, lists and values visited per result
+)
+
+// redactResult replaces every field of the JSON-RPC result named in keys with
+// "[REDACTED]" and returns the rewritten body with the number of fields scrubbed. Only
+// the result is decoded; the envelope and untouched bodies are returned byte for byte.
+func redactResult(body []byte, keys []string) ([]byte, int, error) {
+	if len(keys) == 0 || len(body) == 0 {
+		return body, 0, nil
+	}
+	var envelope map[string]json.RawMessage
+	if err := json.Unmarshal(body, &envelope); err != nil {
+		return body, 0, nil // not a JSON object; nothing addressable to scrub
+	}
+	raw, ok := envelope["result"]
+	if !ok {
+		return body, 0, nil
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber() // keep large integers intact when the result is re-encoded
+	var result interface{}
+	if err := dec.Decode(&result); err != nil {
+		return nil, 0, fmt.Errorf("decoding result for redaction: %w", err)
+	}
+	set := make(map[string]bool, len(keys))
+	for i := 0; i < len(keys) && i < maxRedactKeys; i++ {
+		set[strings.ToLower(strings.TrimSpace(keys[i]))] = true
+	}
+	n, err := scrubFields(result, set)
+	if err != nil {
+		return nil, 0, err
+	}
+	if n == 0 {
+		return body, 0, nil
+	}
+
+	scrubbed, err := json.Marshal(result)
+	if err != nil {
+		return nil, 0, fmt.Errorf("encoding redacted result: %w", err)
+	}
+	envelope["result"] = scrubbed
+	out, err := json.Marshal(envelope)
+	if err != nil {
+		return nil, 0, fmt.Errorf("encoding redacted response: %w", err)
+	}
+	return out, n, nil
+}
+
+// scrubFields redacts matching keys in place and returns how many were replaced. A
+// matched key is replaced whole, whatever its value, so nested secrets go with it. A
+// result too large to walk fails rather than being forwarded partly scrubbed.
+func scrubFields(v interface{}, keys map[string]bool) (int, error) {
+	type scrubFrame struct {
+		value interface{}
+		depth int
+	}
+	stack := []scrubFrame{{value: v}}
+	n := 0
+	for visited := 0; visited < maxRedactNodes && len(stack) > 0; visited++ {
+		f := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if f.depth > maxRedactDepth {
+			continue
+		}
+		switch val := f.value.(type) {
+		case map[string]interface{}:
+			for k, child := range val {
+				if keys[strings.ToLower(k)] {
+					val[k] = redactedValue
+					n++
+					continue
+				}
+				stack = append(stack, scrubFrame{child, f.depth + 1})
+			}
+		case []interface{}:
+			for j := 0; j < len(val); j++ {
+				stack = append(stack, scrubFrame{val[j], f.depth + 1})
+			}
+		}
+	}
+	if len(stack) > 0 {
+		return 0, fmt.Errorf("result has more than %d values to scrub", maxRedactNodes)
+	}
+	return n, nil
+}
+
+// redactResponse applies the matched rule's redact_response list to the buffered body and
+// puts the scrubbed, re-encoded body on resp. Returns the number of fields scrubbed.
+func (i *Interceptor) redactResponse(resp *http.Response, body *responseBody, st *callState) (int, error) {
+	if st == nil || len(st.redact) == 0 {
+		return 0, nil
+	}
+	plain, n, err := redactResult(body.plain, st.redact)
+	if err == nil && n > 0 {
+		err = body.rewrite(resp, plain)
+	}
+	if err != nil {
+		logging.Error("response_redaction_failed", logging.Fields{Component: "interceptor", EventID: st.callID, Method: st.method, CorrelationID: st.corr.requestID, Error: err.Error()})
+		return 0, fmt.Errorf("response redaction for %s: %w", st.method, err)
+	}
+	if n > 0 {
+		logging.Info("response_redacted", logging.Fields{Component: "interceptor", EventID: st.callID, TaskID: st.taskID, Method: st.method, CorrelationID: st.corr.requestID})
+	}
+	return n, nil
+}
@@ -0,0 +1,113 @@
+package interceptor
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httputil"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+const responseRedactPolicy = `
+version: "1.0"
+policies:
+  - id: "aws-describe"
+    match_methods: ["aws:*"]
+    risk_level: "medium"
+    redact_response: ["SecretAccessKey", "session_token"]
+`
+
+func TestRedactResult(t *testing.T) {
+	body := []byte(`{"jsonrpc":"2.0","id":9007199254740993,"result":{"user":"ci","size":9007199254740993,"Credentials":{"AccessKeyId":"AKIA","secretaccesskey":"wJalr"},"keys":[{"Session_Token":"t1"}]}}`)
+	out, n, err := redactResult(body, []string{"SecretAccessKey", "session_token"})
+	if err != nil || n != 2 {
+		t.Fatalf("expected 2 fields redacted, got %d (%v)", n, err)
+	}
+	if bytes.Contains(out, []byte("wJalr")) || bytes.Contains(out, []byte("t1")) {
+		t.Fatalf("secret survived redaction: %s", out)
+	}
+	if !bytes.Contains(out, []byte(`"size":9007199254740993`)) || !bytes.Contains(out, []byte(`"id":9007199254740993`)) {
+		t.Errorf("large integers were not preserved: %s", out)
+	}
+
+	untouched := []byte(`{"jsonrpc":"2.0","id":1,"result":{"user":"ci"}}`)
+	if out, n, _ := redactResult(untouched, []string{"secret"}); n != 0 || !bytes.Equal(out, untouched) {
+		t.Errorf("body without matches must pass through unchanged, got %s", out)
+	}
+}
+
+func TestHandlerRedactsCompressedResponse(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gz, _ := encodeBody("gzip", []byte(`{"jsonrpc":"2.0","id":3,"result":{"AccessKeyId":"AKIAEXAMPLE","SecretAccessKey":"wJalrXUtnFEMI/K7MDENG"}}`))
+		w.Header().Set("Content-Encoding", "gzip")
+		w.Header().Set("Content-Length", strconv.Itoa(len(gz)))
+		_, _ = w.Write(gz)
+	}))
+	defer upstream.Close()
+
+	i, events := newLedgeredInterceptor(t, responseRedactPolicy)
+	target, _ := url.Parse(upstream.URL)
+	proxy := httputil.NewSingleHostReverseProxy(target)
+	proxy.ModifyResponse = i.InterceptResponse
+	proxy.ErrorHandler = i.ProxyError
+
+	body := `{"jsonrpc":"2.0","id":3,"method":"aws:get_credentials","params":{}}`
+	req := httptest.NewRequest(http.MethodPost, "/", bytes.NewBufferString(body))
+	req.Header.Set("Accept-Encoding", "gzip")
+	rec := httptest.NewRecorder()
+	i.Handler(proxy).ServeHTTP(rec, req)
+
+	if rec.Header().Get("Content-Encoding") != "gzip" || rec.Header().Get("Content-Length") != strconv.Itoa(rec.Body.Len()) {
+		t.Fatalf("unexpected headers %v for a %d byte body", rec.Header(), rec.Body.Len())
+	}
+	plain, err := decodeBody("gzip", rec.Body.Bytes())
+	if err != nil {
+		t.Fatalf("agent body is not gzip: %v", err)
+	}
+	var got struct {
+		Result map[string]string `json:"result"`
+	}
+	if err := json.Unmarshal(plain, &got); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if got.Result["SecretAccessKey"] != redactedValue || got.Result["AccessKeyId"] != "AKIAEXAMPLE" {
+		t.Fatalf("unexpected result forwarded: %v", got.Result)
+	}
+
+	for _, e := range events() {
+		if e.EventType != "tool_response" {
+			continue
+		}
+		if e.Response["SecretAccessKey"] != redactedValue || !e.HasTag(TagResponseRedacted) {
+			t.Fatalf("ledgered response not redacted: %+v", e)
+		}
+		return
+	}
+	t.Fatal("no tool_response recorded")
+}
+
+func TestHandlerFailsClosedOnUndecodableRedactedResponse(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Encoding", "br")
+		_, _ = io.WriteString(w, "opaque")
+	}))
+	defer upstream.Close()
+
+	i, _ := newLedgeredInterceptor(t, responseRedactPolicy)
+	target, _ := url.Parse(upstream.URL)
+	proxy := httputil.NewSingleHostReverseProxy(target)
+	proxy.ModifyResponse = i.InterceptResponse
+	proxy.ErrorHandler = i.ProxyError
+
+	body := `{"jsonrpc":"2.0","id":4,"method":"aws:get_credentials","params":{}}`
+	rec := httptest.NewRecorder()
+	i.Handler(proxy).ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/", bytes.NewBufferString(body)))
+	if rec.Code != http.StatusBadGateway || strings.Contains(rec.Body.String(), "opaque") {
+		t.Fatalf("expected a 502 without the upstream body, got %d %q", rec.Code, rec.Body.String())
+	}
+}
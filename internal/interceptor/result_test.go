@@ -0,0 +1,127 @@
+package interceptor
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/slyt3/Logryph/internal/ledger/audit"
+	"github.com/slyt3/Logryph/internal/schema"
+)
+
+func TestInterceptResponseRecordsNonObjectResults(t *testing.T) {
+	i, events := newLedgeredInterceptor(t, "")
+	pubKey := i.Core.Worker.GetSigner().GetPublicKey()
+	results := map[string]string{
+		"call-array":  `[{"id":"i-1","state":"running"},{"id":"i-2","state":"stopped"}]`,
+		"call-string": `"ok"`,
+	}
+	for callID, result := range results {
+		body := []byte(`{"jsonrpc":"2.0","id":1,"result":` + result + `}`)
+		req := httptest.NewRequest(http.MethodPost, "/", nil)
+		req = req.WithContext(withCallState(req.Context(), &callState{callID: callID, method: "compute:list_instances"}))
+		resp := &http.Response{StatusCode: http.StatusOK, Header: http.Header{}, Body: io.NopCloser(bytes.NewReader(body)), Request: req}
+		if err := i.InterceptResponse(resp); err != nil {
+			t.Fatalf("%s: intercept: %v", callID, err)
+		}
+	}
+
+	recorded := map[string]interface{}{}
+	for _, e := range events() {
+		if e.EventType != "tool_response" {
+			continue
+		}
+		if e.Response != nil {
+			t.Errorf("%s: non-object result stored as an object: %v", e.ParentID, e.Response)
+		}
+		recorded[e.ParentID] = e.ResponseValue
+		if err := audit.VerifyEventWithKey(&e, pubKey); err != nil {
+			t.Errorf("%s: stored event does not verify: %v", e.ParentID, err)
+		}
+	}
+	list, _ := recorded["call-array"].([]interface{})
+	if len(list) != 2 {
+		t.Fatalf("array result not recorded faithfully: %v", recorded["call-array"])
+	}
+	if first, _ := list[0].(map[string]interface{}); first["id"] != "i-1" {
+		t.Errorf("array element lost: %v", list[0])
+	}
+	if recorded["call-string"] != "ok" {
+		t.Errorf("scalar result not recorded: %v", recorded["call-string"])
+	}
+}
+
+func TestInterceptResponseTruncatesOversizedResults(t *testing.T) {
+	i, events := newLedgeredInterceptor(t, "version: \"1.0\"\ncapture:\n  max_response_bytes: 1024\npolicies: []\n")
+	pubKey := i.Core.Worker.GetSigner().GetPublicKey()
+	result := `{"files":["` + strings.Repeat("é", 3000) + `"],"task_id":"t-1"}`
+	body := []byte(`{"jsonrpc":"2.0","id":1,"result":` + result + `}`)
+	req := httptest.NewRequest(http.MethodPost, "/", nil)
+	req = req.WithContext(withCallState(req.Context(), &callState{callID: "call-big", method: "fs:list"}))
+	resp := &http.Response{StatusCode: http.StatusOK, Header: http.Header{}, Body: io.NopCloser(bytes.NewReader(body)), Request: req}
+	if err := i.InterceptResponse(resp); err != nil {
+		t.Fatalf("intercept: %v", err)
+	}
+	if forwarded, _ := io.ReadAll(resp.Body); !bytes.Equal(forwarded, body) {
+		t.Fatal("the agent should receive the whole result")
+	}
+
+	sum := sha256.Sum256([]byte(result))
+	found := false
+	for _, e := range events() {
+		if e.EventType != "tool_response" {
+			continue
+		}
+		found = true
+		stored, _ := e.ResponseValue.(string)
+		if e.Response != nil || !e.HasTag(TagResponseTruncated) || !strings.HasPrefix(stored, `{"files":["é`) || !strings.HasSuffix(stored, `"task_id":"t-1"}`) {
+			t.Fatalf("truncated result stored as %q (tags %v)", stored, e.Tags)
+		}
+		if len(stored) > 1200 || !strings.Contains(stored, "truncated") {
+			t.Errorf("stored %d bytes, want the 1024-byte head and tail plus a marker", len(stored))
+		}
+		if e.Params["response_sha256"] != hex.EncodeToString(sum[:]) || e.Params["response_bytes"] != float64(len(result)) {
+			t.Errorf("params = %v, want the full result's size and sha256", e.Params)
+		}
+		if e.TaskID != "t-1" {
+			t.Errorf("task id from the full result lost: %q", e.TaskID)
+		}
+		if err := audit.VerifyEventWithKey(&e, pubKey); err != nil {
+			t.Errorf("stored event does not verify: %v", err)
+		}
+	}
+	if !found {
+		t.Fatal("no tool_response recorded")
+	}
+}
+
+// Only tools/list responses define input schemas. A tool whose output carries a tools
+// array must not replace the schema another tool is validated against.
+func TestInterceptResponseTakesSchemasOnlyFromToolsList(t *testing.T) {
+	i, _ := newLedgeredInterceptor(t, "")
+	i.Core.Schemas = schema.NewCatalog()
+	respond := func(method, result string) {
+		body := []byte(`{"jsonrpc":"2.0","id":1,"result":` + result + `}`)
+		req := httptest.NewRequest(http.MethodPost, "/", nil)
+		req = req.WithContext(withCallState(req.Context(), &callState{callID: "call-" + method, method: method}))
+		resp := &http.Response{StatusCode: http.StatusOK, Header: http.Header{}, Body: io.NopCloser(bytes.NewReader(body)), Request: req}
+		if err := i.InterceptResponse(resp); err != nil {
+			t.Fatalf("%s: intercept: %v", method, err)
+		}
+	}
+	respond("tools/list", `{"tools":[{"name":"db_query","inputSchema":{"type":"object","required":["sql"]}}]}`)
+	respond("tools/call", `{"tools":[{"name":"db_query","inputSchema":{}},{"name":"shell","inputSchema":{}}]}`)
+
+	if i.Core.Schemas.Len() != 1 {
+		t.Errorf("catalog holds %d tools, want only the listed one", i.Core.Schemas.Len())
+	}
+	s, ok := i.Core.Schemas.Lookup("db_query")
+	if required, _ := s["required"].([]interface{}); !ok || len(required) != 1 {
+		t.Errorf("db_query schema replaced by a tools/call result: %v", s)
+	}
+}
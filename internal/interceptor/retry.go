@@ -0,0 +1,140 @@
+package interceptor
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"syscall"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/slyt3/Logryph/internal/assert"
+	"github.com/slyt3/Logryph/internal/logging"
+	"github.com/slyt3/Logryph/internal/observer"
+	"github.com/slyt3/Logryph/internal/pool"
+)
+
+// EventCallRetry records one retry of an idempotent call after a transient upstream
+// failure. Each retry is a child of the original tool_call.
+const EventCallRetry = "call_retry"
+
+const maxRetryDrain = 1 << 16
+
+// Transport wraps the upstream round tripper with retries for calls matched by an
+// idempotent rule. Other calls make exactly one attempt, so a write is never repeated.
+func (i *Interceptor) Transport(base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &retryTransport{i: i, base: base}
+}
+
+type retryTransport struct {
+	i    *Interceptor
+	base http.RoundTripper
+}
+
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	st := callStateFrom(req.Context())
+	if st == nil || !st.idempotent || st.callID == "" || t.i.Core == nil || t.i.Core.Observer == nil {
+		return t.base.RoundTrip(req)
+	}
+	policy := t.i.Core.Observer.GetRetryPolicy()
+	if policy.MaxAttempts <= 1 {
+		return t.base.RoundTrip(req)
+	}
+	body, err := drainRequestBody(req)
+	if err != nil {
+		return nil, err
+	}
+
+	for attempt := 1; attempt <= observer.MaxRetryAttempts; attempt++ {
+		resp, err := t.base.RoundTrip(withBody(req, body))
+		reason := transientFailure(resp, err)
+		if reason == "" || attempt >= policy.MaxAttempts {
+			return resp, err
+		}
+		if resp != nil {
+			_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, maxRetryDrain))
+			_ = resp.Body.Close()
+		}
+		delay := policy.Delay(attempt)
+		t.i.recordRetry(st, attempt+1, reason, delay)
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, req.Context().Err()
+		case <-timer.C:
+		}
+	}
+	return nil, fmt.Errorf("upstream retries exhausted for %s", st.method)
+}
+
+// drainRequestBody reads the outbound body once so every attempt can resend it.
+// Call bodies are already held in memory by the limits stage of the Handler chain.
+func drainRequestBody(req *http.Request) ([]byte, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, nil
+	}
+	defer req.Body.Close()
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		return nil, fmt.Errorf("buffering request body for retry: %w", err)
+	}
+	return body, nil
+}
+
+func withBody(req *http.Request, body []byte) *http.Request {
+	out := req.Clone(req.Context())
+	if body == nil {
+		out.Body = nil
+		return out
+	}
+	out.Body = io.NopCloser(bytes.NewReader(body))
+	out.GetBody = func() (io.ReadCloser, error) { return io.NopCloser(bytes.NewReader(body)), nil }
+	return out
+}
+
+// transientFailure names the failure if the attempt is worth retrying, or returns "".
+func transientFailure(resp *http.Response, err error) string {
+	if err != nil {
+		if errors.Is(err, syscall.ECONNREFUSED) {
+			return "connection_refused"
+		}
+		return ""
+	}
+	if resp != nil && (resp.StatusCode == http.StatusBadGateway || resp.StatusCode == http.StatusServiceUnavailable) {
+		return fmt.Sprintf("status_%d", resp.StatusCode)
+	}
+	return ""
+}
+
+// recordRetry ledgers a retry attempt as a child of the original call.
+func (i *Interceptor) recordRetry(st *callState, attempt int, reason string, delay time.Duration) {
+	if err := assert.Check(i.Core != nil && i.Core.Worker != nil, "worker must be initialized"); err != nil {
+		return
+	}
+	logging.Warn("upstream_retry", logging.Fields{Component: "interceptor", EventID: st.callID, TaskID: st.taskID, Method: st.method, CorrelationID: st.corr.requestID, Error: reason})
+
+	event := pool.GetEvent()
+	event.ID = uuid.New().String()[:8]
+	event.Timestamp = time.Now()
+	event.Actor = "system"
+	event.EventType = EventCallRetry
+	event.Method = st.method
+	event.TaskID = st.taskID
+	event.ParentID = st.callID
+	event.Environment = st.env
+	event.CorrelationID = st.corr.requestID
+	event.TraceID = st.corr.traceID
+	event.SpanID = st.corr.spanID
+	event.Params["attempt"] = attempt
+	event.Params["reason"] = reason
+	event.Params["delay_ms"] = delay.Milliseconds()
+
+	i.Core.Worker.Submit(event)
+}
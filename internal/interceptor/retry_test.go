@@ -0,0 +1,100 @@
+package interceptor
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"sync/atomic"
+	"testing"
+)
+
+const retryPolicy = `
+version: "1.0"
+retry:
+  max_attempts: 3
+  backoff: "1ms"
+policies:
+  - id: "reads"
+    match_methods: ["fs:read"]
+    risk_level: "low"
+    idempotent: true
+  - id: "writes"
+    match_methods: ["fs:write"]
+    risk_level: "medium"
+`
+
+func TestTransportRetriesIdempotentCalls(t *testing.T) {
+	var hits atomic.Int32
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if !bytes.Contains(body, []byte(`"fs:read"`)) && !bytes.Contains(body, []byte(`"fs:write"`)) {
+			t.Errorf("attempt %d lost the request body: %q", hits.Load()+1, body)
+		}
+		if hits.Add(1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":{}}`))
+	}))
+	defer upstream.Close()
+
+	i, events := newLedgeredInterceptor(t, retryPolicy)
+	resp := callThroughTransport(t, i, upstream.URL, "fs:read")
+	if resp.StatusCode != http.StatusOK || hits.Load() != 3 {
+		t.Fatalf("expected success on the third attempt, got %d after %d attempts", resp.StatusCode, hits.Load())
+	}
+
+	hits.Store(0)
+	resp = callThroughTransport(t, i, upstream.URL, "fs:write")
+	if resp.StatusCode != http.StatusServiceUnavailable || hits.Load() != 1 {
+		t.Fatalf("non-idempotent call must not be retried: %d after %d attempts", resp.StatusCode, hits.Load())
+	}
+
+	ledgered := events()
+	var callID string
+	for _, e := range ledgered {
+		if e.EventType == "tool_call" && e.Method == "fs:read" {
+			callID = e.ID
+		}
+	}
+	var attempts []int
+	for _, e := range ledgered {
+		if e.EventType != EventCallRetry {
+			continue
+		}
+		if e.ParentID != callID || e.Params["reason"] != "status_503" {
+			t.Errorf("retry not linked to call %s: %+v", callID, e)
+		}
+		n, _ := e.Params["attempt"].(float64)
+		attempts = append(attempts, int(n))
+	}
+	sort.Ints(attempts)
+	if len(attempts) != 2 || attempts[0] != 2 || attempts[1] != 3 {
+		t.Fatalf("expected retries for attempts 2 and 3, got %v", attempts)
+	}
+}
+
+func callThroughTransport(t *testing.T, i *Interceptor, url, method string) *http.Response {
+	t.Helper()
+	body := `{"jsonrpc":"2.0","id":1,"method":"` + method + `","params":{"path":"/tmp/a"}}`
+	var got *http.Response
+	h := i.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		out, err := http.NewRequestWithContext(r.Context(), http.MethodPost, url, r.Body)
+		if err != nil {
+			t.Fatalf("request: %v", err)
+		}
+		resp, err := i.Transport(nil).RoundTrip(out)
+		if err != nil {
+			t.Fatalf("round trip: %v", err)
+		}
+		_ = resp.Body.Close()
+		got = resp
+	}))
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/", bytes.NewBufferString(body)))
+	if got == nil {
+		t.Fatal("call was not forwarded")
+	}
+	return got
+}
@@ -0,0 +1,63 @@
+package interceptor
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"math/rand"
+
+	"github.com/slyt3/Logryph/internal/analyzer"
+	"github.com/slyt3/Logryph/internal/crypto"
+	"github.com/slyt3/Logryph/internal/models"
+	"github.com/slyt3/Logryph/internal/observer"
+)
+
+// TagSampledOut marks a tool_call or tool_response recorded under sampling without its
+// payload: the params or result are stored as their size and SHA-256 only.
+const TagSampledOut = "sampled_out"
+
+// sampleOut decides, before the call is ledgered, whether sampling drops its payloads.
+// A call rated high or critical, by its rule or by the detectors, or headed for a stall
+// is always kept whole, whatever the configured rate.
+func (i *Interceptor) sampleOut(method string, rule *observer.Rule, insp *callInspection) bool {
+	if i.Core == nil || i.Core.Observer == nil {
+		return false
+	}
+	cfg := i.Core.Observer.GetSampling()
+	if !cfg.Enabled() {
+		return false
+	}
+	risk := insp.risk
+	stall := i.detectorStallRule(*insp) != nil
+	if rule != nil {
+		risk = analyzer.MaxRisk(rule.RiskLevel, insp.risk)
+		stall = stall || rule.Action == observer.RuleActionStall
+	}
+	if cfg.AlwaysRecorded(method, risk, stall) {
+		return false
+	}
+	return rand.Float64() >= cfg.Rate
+}
+
+// sampleOutParams replaces a tool_call's params with their payload hash, the same digest
+// sent upstream in the X-Logryph-Event-Hash header. The caller's params map is left alone.
+func sampleOutParams(event *models.Event, params map[string]interface{}) {
+	out := map[string]interface{}{}
+	if h, err := crypto.PayloadHash(event.Method, params); err == nil {
+		out["params_sha256"] = h
+	}
+	event.Params = out
+	event.AddTag(TagSampledOut)
+}
+
+// sampleOutResult drops a tool_response's result, keeping the size and SHA-256 of the
+// result JSON as the agent received it, as truncateResult does.
+func sampleOutResult(event *models.Event, result []byte) {
+	raw := bytes.TrimSpace(result)
+	sum := sha256.Sum256(raw)
+	event.Response = nil
+	event.ResponseValue = nil
+	event.Params["response_bytes"] = len(raw)
+	event.Params["response_sha256"] = hex.EncodeToString(sum[:])
+	event.AddTag(TagSampledOut)
+}
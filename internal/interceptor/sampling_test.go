@@ -0,0 +1,92 @@
+package interceptor
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/http/httputil"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/slyt3/Logryph/internal/crypto"
+	"github.com/slyt3/Logryph/internal/ledger/audit"
+)
+
+// samplingPolicy samples so rarely that every ordinary call is recorded without payloads.
+const samplingPolicy = `
+version: "1.0"
+sampling:
+  rate: 0.000000001
+  exempt_methods: ["billing:*"]
+policies:
+  - id: "drop-table"
+    match_methods: ["db:drop_table"]
+    risk_level: "critical"
+  - id: "read"
+    match_methods: ["fs:read"]
+    risk_level: "low"
+`
+
+func TestSamplingAlwaysRecordsHighRiskCalls(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":{"rows":42}}`))
+	}))
+	defer upstream.Close()
+
+	i, events := newLedgeredInterceptor(t, samplingPolicy)
+	pubKey := i.Core.Worker.GetSigner().GetPublicKey()
+	target, _ := url.Parse(upstream.URL)
+	proxy := httputil.NewSingleHostReverseProxy(target)
+	proxy.ModifyResponse = i.InterceptResponse
+	h := i.Handler(proxy)
+	params := map[string]string{
+		"db:drop_table":  `{"target":"users"}`,
+		"fs:read":        `{"target":"users"}`,
+		"fs:stat":        `{"target":"users"}`,
+		"billing:refund": `{"target":"users"}`,
+		"shell:exec":     `{"command":"rm -rf /"}`,
+	}
+	calls := map[string]string{}
+	for method, p := range params {
+		body := `{"jsonrpc":"2.0","id":1,"method":"` + method + `","params":` + p + `}`
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body)))
+		if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"rows":42`) {
+			t.Fatalf("%s: agent got %d %s", method, rec.Code, rec.Body.String())
+		}
+		calls[rec.Header().Get(EventIDHeader)] = method
+	}
+	// shell:exec matches no rule, but the detectors rate rm -rf / critical.
+	full := map[string]bool{"db:drop_table": true, "billing:refund": true, "shell:exec": true}
+
+	responses := 0
+	for _, e := range events() {
+		if err := audit.VerifyEventWithKey(&e, pubKey); err != nil {
+			t.Errorf("%s: stored event does not verify: %v", e.ID, err)
+		}
+		switch e.EventType {
+		case "tool_call":
+			want, _ := crypto.PayloadHash(e.Method, map[string]interface{}{"target": "users"})
+			if full[e.Method] {
+				if e.HasTag(TagSampledOut) || len(e.Params) == 0 || e.Params["params_sha256"] != nil {
+					t.Errorf("%s: exempt call lost its params: %v", e.Method, e.Params)
+				}
+			} else if !e.HasTag(TagSampledOut) || e.Params["target"] != nil || e.Params["params_sha256"] != want {
+				t.Errorf("%s: sampled call stored params %v (tags %v)", e.Method, e.Params, e.Tags)
+			}
+		case "tool_response":
+			responses++
+			method := calls[e.ParentID]
+			if full[method] {
+				if e.HasTag(TagSampledOut) || e.Response["rows"] != float64(42) {
+					t.Errorf("%s: exempt response lost its result: %v", method, e.Response)
+				}
+			} else if !e.HasTag(TagSampledOut) || e.Response != nil || e.Params["response_sha256"] == nil {
+				t.Errorf("%s: sampled response stored %v (params %v)", method, e.Response, e.Params)
+			}
+		}
+	}
+	if responses != len(params) {
+		t.Fatalf("expected %d tool_responses, got %d", len(params), responses)
+	}
+}
@@ -2,19 +2,31 @@ package interceptor
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"strings"
 	"time"
+	"unicode/utf8"
 
 	"github.com/google/uuid"
+	"github.com/slyt3/Logryph/internal/analyzer"
+	"github.com/slyt3/Logryph/internal/approval"
 	"github.com/slyt3/Logryph/internal/assert"
 	"github.com/slyt3/Logryph/internal/core"
+	"github.com/slyt3/Logryph/internal/crypto"
+	"github.com/slyt3/Logryph/internal/endpoint"
 	"github.com/slyt3/Logryph/internal/logging"
 	"github.com/slyt3/Logryph/internal/mcp"
+	"github.com/slyt3/Logryph/internal/models"
 	"github.com/slyt3/Logryph/internal/observer"
 	"github.com/slyt3/Logryph/internal/pool"
+	"github.com/slyt3/Logryph/internal/schema"
+	"github.com/slyt3/Logryph/internal/slo"
+	"github.com/slyt3/Logryph/internal/vql"
 )
 
 // PolicyAction defines the outcome of a policy check
@@ -26,6 +38,22 @@ const (
 	ActionRedact PolicyAction = "redact" // Keep for hygiene, but not blocking
 )
 
+// TagSchemaViolation marks tool calls whose arguments do not match the tool's input schema.
+const TagSchemaViolation = "schema_violation"
+
+// EventEndpointViolation marks calls whose destinations break a rule's allow/deny host lists.
+const EventEndpointViolation = "endpoint_violation"
+
+// Endpoint finding kinds.
+const (
+	kindEndpointDenied      = "endpoint_denied"
+	kindEndpointNotAllowed  = "endpoint_not_allowed"
+	kindEndpointUnparseable = "endpoint_unparseable"
+)
+
+// EnvironmentHeader selects a deployment profile per request when the policy allows it.
+const EnvironmentHeader = "X-Logryph-Environment"
+
 const (
 	maxPolicies   = 256
 	maxPatterns   = 128
@@ -38,71 +66,587 @@ const (
 // It evaluates policies, applies redaction rules, and submits events to the ledger
 // without blocking agent traffic (fail-open behavior).
 type Interceptor struct {
-	Core *core.Engine
+	Core      *core.Engine
+	slots     limiter       // upstream concurrency cap
+	ruleSlots ruleLimiters  // per-rule max_concurrent caps
+	budgets   taskBudgets   // per-task usage against the task budget
+	history   taskHistories // per-task recent calls for history() rules
 }
 
 func NewInterceptor(engine *core.Engine) *Interceptor {
 	return &Interceptor{Core: engine}
 }
 
-// InterceptRequest captures HTTP POST requests, extracts MCP metadata, evaluates policies,
-// applies redaction rules, and submits events to the async worker.
-// Returns immediately without blocking proxy traffic. Drops events on backpressure.
-func (i *Interceptor) InterceptRequest(req *http.Request) {
-	if req.Method != http.MethodPost {
-		return
+// Rejection is sent by a Handler stage when the call must not reach the upstream.
+// Only produced in enforce mode; observe mode never blocks traffic.
+type Rejection struct {
+	RequestID interface{} // JSON-RPC id echoed back to the agent
+	Status    int
+	Code      int
+	Message   string
+}
+
+func (r *Rejection) Error() string {
+	return fmt.Sprintf("call rejected (%d): %s", r.Code, r.Message)
+}
+
+// rpcCall is a JSON-RPC call as the interception stages see it. The decode stage fills in
+// the request; later stages add what they learn about it.
+type rpcCall struct {
+	req         *mcp.MCPRequest
+	taskID      string
+	requestID   string // the JSON-RPC id as text
+	env         string
+	canonical   string         // the method after aliasing: rules, detectors and sampling see it, the event keeps the original
+	prior       []vql.Env      // the task's earlier calls, most recent first, for history()
+	breach      *budgetBreach  // set when the call is past its task's budget
+	budgetStall *observer.Rule // the budget holds the call for approval
+	rule        *observer.Rule // the matched rule
+	insp        callInspection
+}
+
+// decodeCall parses a JSON-RPC body and checks its params against the listener's depth
+// and array length limits. A body that does not parse is forwarded uninspected.
+func (i *Interceptor) decodeCall(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		st := callStateFrom(r.Context())
+		if st == nil || !st.hasBody || !st.jsonBody {
+			next.ServeHTTP(w, r)
+			return
+		}
+		mcpReq, taskID, method, err := i.extractTaskMetadata(st.body)
+		if err != nil {
+			i.SendErrorResponse(r, http.StatusBadRequest, -32000, err.Error())
+			next.ServeHTTP(w, r)
+			return
+		}
+		if le := checkParams(mcpReq.Params, i.requestLimits()); le != nil {
+			i.reject(w, r, i.rejectLimits(r, le, method, mcpReq.ID))
+			return
+		}
+		if taskID == "" {
+			taskID = callCorrelation(r).taskID
+		}
+		st.call = &rpcCall{req: mcpReq, taskID: taskID, env: i.resolveEnvironment(r), canonical: i.canonicalMethod(method)}
+		if mcpReq.ID != nil {
+			st.call.requestID = fmt.Sprint(mcpReq.ID)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// interceptCall evaluates the policy, runs the content checks and records the tool_call.
+// A call the policy cannot be evaluated for is forwarded unrecorded, as before policies
+// could block.
+func (i *Interceptor) interceptCall(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		st := callStateFrom(r.Context())
+		if st != nil && st.call != nil && i.recordCall(r, st) != nil {
+			st.call = nil
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// recordCall runs the policy and content checks over the decoded call and submits its
+// tool_call event.
+func (i *Interceptor) recordCall(r *http.Request, st *callState) error {
+	c := st.call
+	method := c.req.Method
+	// SQL is classified first so rules can match on it
+	c.insp.sql = i.classifySQL(method, c.req.Params)
+	if c.breach != nil {
+		c.insp.tags = append(c.insp.tags, EventTaskBudgetExceeded)
+	}
+	action, rule, err := i.evaluatePolicy(c.canonical, c.req.Params, c.env, analyzer.SQLMatchKeys(c.insp.sql), c.prior)
+	if err != nil {
+		logging.Warn("policy_evaluation_failed", logging.Fields{Component: "interceptor", RequestID: c.requestID, TaskID: c.taskID, Method: method, Error: err.Error()})
+		i.SendErrorResponse(r, http.StatusBadRequest, -32000, "Policy violation")
+		return err
+	}
+	if rule != nil {
+		i.Core.Observer.RecordMatch(rule.ID, i.matchAction(action, rule))
+	}
+	c.rule = rule
+
+	// Content checks: schema validation and detector heuristics
+	i.inspectCall(&c.insp, c.requestID, c.taskID, c.canonical, c.req.Params, rule)
+	c.insp.sampledOut = i.sampleOut(c.canonical, rule, &c.insp)
+
+	eventID, err := i.applyRedactionAndSubmit(r, action, rule, st.body, c.requestID, c.taskID, method, c.env, &c.insp, c.req)
+	if err != nil {
+		return err
+	}
+	i.submitFindings(c.insp, eventID, c.taskID, c.env, method)
+	i.notifyMatch(rule, eventID, c.taskID, method, c.env, &c.insp, callCorrelation(r))
+	i.trackRecordedCall(st, eventID)
+	return nil
+}
+
+// trackRecordedCall keeps what the response hook and the concurrency caps need to know
+// about a recorded call.
+func (i *Interceptor) trackRecordedCall(st *callState, eventID string) {
+	c := st.call
+	st.callID, st.taskID, st.method, st.env = eventID, c.taskID, c.req.Method, c.env
+	st.tool, _, _ = resolveToolCall(c.canonical, c.req.Params)
+	st.idempotent = c.rule != nil && c.rule.Idempotent
+	st.rpcID, st.timeout = c.req.ID, i.Core.Observer.GetUpstreamTimeout(c.rule)
+	st.sampledOut = c.insp.sampledOut
+	st.risk = c.insp.risk
+	if c.rule != nil {
+		st.redact = c.rule.RedactResponse
+		st.rule, st.ruleCap = c.rule.ID, i.Core.Observer.GetRuleConcurrency(c.rule)
+		st.risk = analyzer.MaxRisk(c.rule.RiskLevel, c.insp.risk)
 	}
+}
+
+// enforceCall applies the enforce-mode outcomes for a recorded call: rejections from the
+// content checks, then any stalls, each waiting for an operator's decision. Observe mode
+// records and forwards.
+func (i *Interceptor) enforceCall(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		st := callStateFrom(r.Context())
+		if st == nil || st.call == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		c := st.call
+		if rej := i.enforceInspection(c.insp, c.req, c.rule); rej != nil {
+			i.reject(w, r, rej)
+			return
+		}
+		ruleStall := i.detectorStallRule(c.insp)
+		if c.rule != nil && c.rule.Action == observer.RuleActionStall {
+			ruleStall = c.rule
+		}
+		stalls := [2]*observer.Rule{c.budgetStall, ruleStall}
+		for j := 0; j < len(stalls); j++ {
+			if stalls[j] == nil {
+				continue
+			}
+			if err := i.handleStall(r.Context(), c.req, st.callID, c.taskID, c.env, stalls[j]); err != nil {
+				i.reject(w, r, err)
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// callInspection collects the results of content checks run on a call before it is ledgered.
+type callInspection struct {
+	tags       []string
+	risk       string             // highest detector-assigned risk, raises the rule's risk level
+	violations []string           // schema violations
+	exfil      []analyzer.Finding // outbound data heuristics
+	endpoints  []analyzer.Finding // destinations outside the rule's host lists
+	shell      []analyzer.Finding // dangerous command-line patterns
+	secretRefs []analyzer.Finding // vault references passed instead of literal secrets
+	leaks      []analyzer.Finding // literal secrets in params
+	sql        []analyzer.SQLStatement
+	sampledOut bool // sampling records the call's payloads as digests only
+}
 
-	if req.Body == nil {
+// shellAnalyzerPolicyID is recorded as the policy for stalls raised by the shell analyzer.
+const shellAnalyzerPolicyID = "shell-analyzer"
+
+// inspectCall runs schema validation and detector heuristics over the call params.
+// Params are inspected before redaction; findings never carry the matched content.
+func (i *Interceptor) inspectCall(insp *callInspection, requestID, taskID, method string, params map[string]interface{}, rule *observer.Rule) {
+	if err := assert.NotNil(insp, "inspection"); err != nil {
+		return
+	}
+	if err := assert.Check(method != "", "method must not be empty"); err != nil {
 		return
 	}
+	insp.tags = append(insp.tags, analyzer.SQLTags(insp.sql)...)
+
+	insp.violations = i.validateParams(method, params)
+	if len(insp.violations) > 0 {
+		insp.tags = append(insp.tags, TagSchemaViolation)
+		logging.Warn("schema_violation", logging.Fields{Component: "interceptor", RequestID: requestID, TaskID: taskID, Method: method, Error: strings.Join(insp.violations, "; ")})
+	}
+
+	insp.exfil = i.detectExfiltration(method, params)
+	if len(insp.exfil) > 0 {
+		insp.tags = append(insp.tags, analyzer.EventExfiltrationSuspected)
+		logging.Warn("exfiltration_suspected", logging.Fields{Component: "interceptor", RequestID: requestID, TaskID: taskID, Method: method})
+	}
+
+	insp.shell = i.detectShellRisk(method, params)
+	if len(insp.shell) > 0 {
+		insp.risk = analyzer.HighestRisk(insp.shell)
+		insp.tags = append(insp.tags, analyzer.EventDangerousCommand)
+		logging.Warn("dangerous_command", logging.Fields{Component: "interceptor", RequestID: requestID, TaskID: taskID, Method: method, RiskLevel: insp.risk, Error: insp.shell[0].Detail})
+	}
+
+	secrets := i.detectSecrets(method, params)
+	insp.secretRefs, insp.leaks = secrets.Refs, secrets.Leaks
+	if len(insp.secretRefs) > 0 {
+		insp.tags = append(insp.tags, analyzer.KindSecretRef)
+	}
+	if len(insp.leaks) > 0 {
+		insp.tags = append(insp.tags, analyzer.EventSecretLeak)
+		logging.Warn("secret_leak", logging.Fields{Component: "interceptor", RequestID: requestID, TaskID: taskID, Method: method, Error: insp.leaks[0].Kind + " at " + insp.leaks[0].Path})
+	}
 
-	buf := pool.GetBuffer()
-	defer pool.PutBuffer(buf)
+	insp.endpoints = checkEndpoints(rule, method, params)
+	if len(insp.endpoints) > 0 {
+		insp.tags = append(insp.tags, EventEndpointViolation)
+		logging.Warn("endpoint_violation", logging.Fields{Component: "interceptor", RequestID: requestID, TaskID: taskID, Method: method, PolicyID: rule.ID, Error: insp.endpoints[0].Detail})
+	}
+}
 
-	if _, err := buf.ReadFrom(req.Body); err != nil {
-		logging.Error("request_body_read_failed", logging.Fields{Component: "interceptor", Error: err.Error()})
+// enforceInspection turns inspection results into a rejection in enforce mode.
+// Endpoint violations are always enforced; schema violations only with schema_validation: deny.
+func (i *Interceptor) enforceInspection(insp callInspection, mcpReq *mcp.MCPRequest, rule *observer.Rule) *Rejection {
+	if err := assert.Check(mcpReq != nil, "mcpReq must not be nil"); err != nil {
+		return nil
+	}
+	if !i.Core.Observer.IsEnforcing() {
+		return nil
+	}
+	if len(insp.endpoints) > 0 && rule != nil {
+		return &Rejection{RequestID: mcpReq.ID, Status: http.StatusForbidden, Code: -32001,
+			Message: fmt.Sprintf("Destination %s not allowed by policy %s", insp.endpoints[0].Detail, rule.ID)}
+	}
+	if len(insp.violations) > 0 && i.Core.Observer.GetSchemaValidation() == observer.SchemaValidationDeny {
+		return &Rejection{RequestID: mcpReq.ID, Status: http.StatusBadRequest, Code: -32602, Message: "Invalid params: " + strings.Join(insp.violations, "; ")}
+	}
+	return nil
+}
+
+// checkEndpoints matches destinations in params against the rule's host lists.
+// Deny entries win over allow entries; with a non-empty allow list anything unlisted is
+// a violation, and so is any destination that cannot be parsed (fail closed).
+func checkEndpoints(rule *observer.Rule, method string, params map[string]interface{}) []analyzer.Finding {
+	if rule == nil || !rule.HasHostLists() {
+		return nil
+	}
+	allow, errAllow := endpoint.ParseList(rule.AllowHosts)
+	deny, errDeny := endpoint.ParseList(rule.DenyHosts)
+	if errAllow != nil || errDeny != nil {
+		// validateConfig rejects bad lists at load time; treat a bad list as deny-all.
+		return []analyzer.Finding{{Kind: kindEndpointUnparseable, Detail: "invalid host list in policy " + rule.ID}}
+	}
+
+	_, args, _ := resolveToolCall(method, params)
+	eps := endpoint.Extract(args)
+	var findings []analyzer.Finding
+	for j := 0; j < len(eps) && j < maxParams; j++ {
+		ep := eps[j]
+		switch {
+		case ep.Err != nil:
+			findings = append(findings, analyzer.Finding{Kind: kindEndpointUnparseable, Path: ep.Path, Detail: "unparseable destination"})
+		case deny.Match(ep.Host):
+			findings = append(findings, analyzer.Finding{Kind: kindEndpointDenied, Path: ep.Path, Detail: ep.Host.Name})
+		case !allow.Empty() && !allow.Match(ep.Host):
+			findings = append(findings, analyzer.Finding{Kind: kindEndpointNotAllowed, Path: ep.Path, Detail: ep.Host.Name})
+		}
+	}
+	return findings
+}
+
+// submitFindings ledgers a linked finding event for each detector that fired on the call.
+func (i *Interceptor) submitFindings(insp callInspection, callID, taskID, env, method string) {
+	if callID == "" {
 		return
 	}
-	bodyBytes := buf.Bytes()
-	req.Body = io.NopCloser(bytes.NewBuffer(bodyBytes))
+	if len(insp.exfil) > 0 {
+		i.submitFindingEvent(analyzer.EventExfiltrationSuspected, "high", callID, taskID, env, method, insp.exfil)
+	}
+	if len(insp.endpoints) > 0 {
+		i.submitFindingEvent(EventEndpointViolation, "high", callID, taskID, env, method, insp.endpoints)
+	}
+	if len(insp.shell) > 0 {
+		i.submitFindingEvent(analyzer.EventDangerousCommand, insp.risk, callID, taskID, env, method, insp.shell)
+	}
+	if len(insp.leaks) > 0 {
+		i.submitFindingEvent(analyzer.EventSecretLeak, analyzer.HighestRisk(insp.leaks), callID, taskID, env, method, insp.leaks)
+	}
+	if len(insp.secretRefs) > 0 {
+		i.submitFindingEvent(analyzer.EventSecretReference, analyzer.RiskLow, callID, taskID, env, method, insp.secretRefs)
+	}
+}
 
-	// 1. Extract Metadata
-	mcpReq, taskID, method, err := i.extractTaskMetadata(bodyBytes)
-	if err != nil {
-		i.SendErrorResponse(req, http.StatusBadRequest, -32000, err.Error())
+// validateParams checks call arguments against the tool's captured input schema.
+// MCP tools/call carries the tool name and arguments inside params; any other method
+// is looked up by method name with params as its arguments. Unknown tools pass.
+func (i *Interceptor) validateParams(method string, params map[string]interface{}) []string {
+	if err := assert.Check(method != "", "method must not be empty"); err != nil {
+		return nil
+	}
+	if i.Core.Schemas == nil || i.Core.Observer.GetSchemaValidation() == observer.SchemaValidationOff {
+		return nil
+	}
+
+	tool, args, argsOK := resolveToolCall(method, params)
+	inputSchema, ok := i.Core.Schemas.Lookup(tool)
+	if !ok {
+		return nil
+	}
+	if !argsOK {
+		return []string{"arguments: expected object"}
+	}
+	return schema.Validate(inputSchema, args)
+}
+
+// resolveToolCall returns the tool name and arguments of a call. MCP tools/call nests
+// them in params; other methods are the tool name with params as arguments.
+// argsOK is false when tools/call carries arguments that are not an object.
+func resolveToolCall(method string, params map[string]interface{}) (string, map[string]interface{}, bool) {
+	if method != "tools/call" {
+		return method, params, true
+	}
+	tool, _ := params["name"].(string)
+	raw, present := params["arguments"]
+	args, isObject := raw.(map[string]interface{})
+	return tool, args, !present || raw == nil || isObject
+}
+
+// detectExfiltration runs the outbound data heuristics unless disabled by policy.
+func (i *Interceptor) detectExfiltration(method string, params map[string]interface{}) []analyzer.Finding {
+	if err := assert.Check(i.Core.Observer != nil, "observer engine missing"); err != nil {
+		return nil
+	}
+	cfg := i.Core.Observer.GetDetectors().Exfiltration
+	if cfg.Disabled {
+		return nil
+	}
+	tool, args, _ := resolveToolCall(method, params)
+	return analyzer.DetectExfiltration(tool, args, analyzer.ExfilOptions{
+		Methods:      cfg.Methods,
+		MinBlobBytes: cfg.MinBlobBytes,
+		BulkBytes:    cfg.BulkBytes,
+	})
+}
+
+// classifySQL classifies SQL in database call params unless disabled by policy.
+func (i *Interceptor) classifySQL(method string, params map[string]interface{}) []analyzer.SQLStatement {
+	if err := assert.Check(i.Core != nil && i.Core.Observer != nil, "observer engine missing"); err != nil {
+		return nil
+	}
+	cfg := i.Core.Observer.GetDetectors().SQL
+	if cfg.Disabled || method == "" {
+		return nil
+	}
+	tool, args, _ := resolveToolCall(method, params)
+	return analyzer.DetectSQL(tool, args, analyzer.SQLOptions{Methods: cfg.Methods})
+}
+
+// detectShellRisk runs the command-line analyzer unless disabled by policy.
+func (i *Interceptor) detectShellRisk(method string, params map[string]interface{}) []analyzer.Finding {
+	if err := assert.Check(i.Core.Observer != nil, "observer engine missing"); err != nil {
+		return nil
+	}
+	cfg := i.Core.Observer.GetDetectors().Shell
+	if cfg.Disabled {
+		return nil
+	}
+	tool, args, _ := resolveToolCall(method, params)
+	return analyzer.DetectShellRisk(tool, args, analyzer.ShellOptions{Methods: cfg.Methods})
+}
+
+// detectSecrets scans params for vault references and literal secrets unless disabled by policy.
+func (i *Interceptor) detectSecrets(method string, params map[string]interface{}) analyzer.SecretScan {
+	if err := assert.Check(i.Core.Observer != nil, "observer engine missing"); err != nil {
+		return analyzer.SecretScan{}
+	}
+	cfg := i.Core.Observer.GetDetectors().Secrets
+	if cfg.Disabled {
+		return analyzer.SecretScan{}
+	}
+	tool, args, _ := resolveToolCall(method, params)
+	return analyzer.DetectSecrets(tool, args, analyzer.SecretOptions{
+		Methods:    cfg.Methods,
+		RefSchemes: cfg.RefSchemes,
+		MinLength:  cfg.MinLength,
+		MinEntropy: cfg.MinEntropy,
+	})
+}
+
+// detectorStallRule returns a synthetic stall rule when detector risk reaches the
+// configured stall threshold, or nil. handleStall decides whether it actually blocks.
+func (i *Interceptor) detectorStallRule(insp callInspection) *observer.Rule {
+	if len(insp.shell) == 0 {
+		return nil
+	}
+	threshold := i.Core.Observer.GetDetectors().Shell.StallRisk
+	if threshold == "" {
+		threshold = analyzer.RiskCritical
+	}
+	if threshold == "none" || !analyzer.RiskAtLeast(insp.risk, threshold) {
+		return nil
+	}
+	return &observer.Rule{ID: shellAnalyzerPolicyID, RiskLevel: insp.risk, Action: observer.RuleActionStall}
+}
+
+// submitFindingEvent ledgers detector findings as a system event linked to the flagged call.
+// Findings carry kinds, paths and sizes only, never the matched content.
+func (i *Interceptor) submitFindingEvent(eventType, riskLevel, callID, taskID, env, method string, findings []analyzer.Finding) {
+	if err := assert.Check(eventType != "", "finding event type must not be empty"); err != nil {
 		return
 	}
-	requestID := ""
-	if mcpReq.ID != nil {
-		requestID = fmt.Sprint(mcpReq.ID)
+	if err := assert.Check(callID != "", "flagged event id must not be empty"); err != nil {
+		return
+	}
+
+	event := pool.GetEvent()
+	event.ID = uuid.New().String()[:8]
+	event.Timestamp = time.Now()
+	event.Actor = "system"
+	event.EventType = eventType
+	event.Method = "logryph:detector"
+	event.TaskID = taskID
+	event.ParentID = callID
+	event.RiskLevel = riskLevel
+	event.Environment = env
+	event.Params["event_id"] = callID
+	event.Params["method"] = method
+	event.Params["findings"] = findings
+
+	i.Core.Worker.Submit(event)
+}
+
+// resolveEnvironment picks the deployment profile for a request: the policy default,
+// or the X-Logryph-Environment header when the policy allows it and names a known profile.
+func (i *Interceptor) resolveEnvironment(req *http.Request) string {
+	if err := assert.Check(i.Core != nil && i.Core.Observer != nil, "observer engine missing"); err != nil {
+		return ""
+	}
+	env := i.Core.Observer.GetEnvironment()
+	if req == nil || !i.Core.Observer.EnvironmentHeaderAllowed() {
+		return env
+	}
+	requested := req.Header.Get(EnvironmentHeader)
+	if requested == "" {
+		return env
+	}
+	if !i.Core.Observer.HasEnvironment(requested) {
+		logging.Warn("unknown_environment_header", logging.Fields{Component: "interceptor", Error: requested})
+		return env
+	}
+	return requested
+}
+
+// handleStall holds an enforce-mode stall until an operator decides or the stall times out.
+// The decision is recorded as its own event linked to the stalled call. The wait ends early
+// when ctx is done, so an agent that disconnects does not keep a stall goroutine alive.
+func (i *Interceptor) handleStall(ctx context.Context, mcpReq *mcp.MCPRequest, eventID, taskID, env string, rule *observer.Rule) error {
+	if err := assert.Check(mcpReq != nil, "mcpReq must not be nil"); err != nil {
+		return nil
+	}
+	if err := assert.Check(rule != nil, "stall rule must not be nil"); err != nil {
+		return nil
+	}
+	if !i.Core.Observer.IsEnforcing() {
+		logging.Info("stall_observed", logging.Fields{Component: "interceptor", EventID: eventID, TaskID: taskID, Method: mcpReq.Method, PolicyID: rule.ID})
+		return nil
+	}
+	if i.Core.Approvals == nil || eventID == "" {
+		logging.Error("stall_unavailable", logging.Fields{Component: "interceptor", EventID: eventID, Method: mcpReq.Method, PolicyID: rule.ID})
+		return &Rejection{RequestID: mcpReq.ID, Status: http.StatusServiceUnavailable, Code: -32002, Message: "Approval required but unavailable"}
+	}
+
+	now := time.Now()
+	err := i.Core.Approvals.Register(approval.Request{
+		EventID:   eventID,
+		Method:    mcpReq.Method,
+		TaskID:    taskID,
+		PolicyID:  rule.ID,
+		RiskLevel: rule.RiskLevel,
+		CreatedAt: now,
+		Deadline:  now.Add(i.Core.Observer.GetStallTimeout()),
+	})
+	if err != nil {
+		logging.Error("stall_register_failed", logging.Fields{Component: "interceptor", EventID: eventID, Method: mcpReq.Method, Error: err.Error()})
+		return &Rejection{RequestID: mcpReq.ID, Status: http.StatusServiceUnavailable, Code: -32002, Message: "Approval queue full"}
 	}
+	logging.Warn("call_stalled", logging.Fields{Component: "interceptor", EventID: eventID, TaskID: taskID, Method: mcpReq.Method, PolicyID: rule.ID, RiskLevel: rule.RiskLevel})
 
-	// 2. Policy Evaluation
-	action, matchedRule, err := i.evaluatePolicy(method, mcpReq.Params)
+	outcome, err := i.Core.Approvals.WaitContext(ctx, eventID)
+	if ctx.Err() != nil && err != nil {
+		logging.Warn("stall_abandoned", logging.Fields{Component: "interceptor", EventID: eventID, TaskID: taskID, Method: mcpReq.Method, PolicyID: rule.ID, Error: err.Error()})
+		return &Rejection{RequestID: mcpReq.ID, Status: http.StatusServiceUnavailable, Code: -32002, Message: "Call canceled while awaiting approval"}
+	}
 	if err != nil {
-		logging.Warn("policy_evaluation_failed", logging.Fields{Component: "interceptor", RequestID: requestID, TaskID: taskID, Method: method, Error: err.Error()})
-		i.SendErrorResponse(req, http.StatusBadRequest, -32000, "Policy violation")
+		return &Rejection{RequestID: mcpReq.ID, Status: http.StatusServiceUnavailable, Code: -32002, Message: "Approval wait failed"}
+	}
+	i.submitApprovalEvent(eventID, taskID, env, rule, outcome, time.Since(now))
+	i.Core.Observer.RecordDecision(rule.ID, string(outcome.Decision))
+
+	if outcome.Decision != approval.DecisionApproved {
+		return &Rejection{RequestID: mcpReq.ID, Status: http.StatusForbidden, Code: -32001, Message: fmt.Sprintf("Call %s by approval policy %s", outcome.Decision, rule.ID)}
+	}
+	return nil
+}
+
+// submitApprovalEvent ledgers the outcome of a stall as a child of the stalled call.
+func (i *Interceptor) submitApprovalEvent(eventID, taskID, env string, rule *observer.Rule, outcome approval.Outcome, waited time.Duration) {
+	if err := assert.Check(eventID != "", "stalled event id must not be empty"); err != nil {
+		return
+	}
+	if err := assert.Check(rule != nil, "stall rule must not be nil"); err != nil {
 		return
 	}
 
-	// 3. Handle Stall (REMOVED - Phase 2 Lobotomy)
-	// We no longer block traffic. We only observe.
-	// if action == ActionStall { ... }
+	event := pool.GetEvent()
+	event.ID = uuid.New().String()[:8]
+	event.Timestamp = time.Now()
+	event.Actor = "user"
+	if outcome.Decision == approval.DecisionExpired {
+		event.Actor = "system"
+	}
+	event.EventType = "stall_resolved"
+	event.Method = "logryph:approval"
+	event.TaskID = taskID
+	event.ParentID = eventID
+	event.PolicyID = rule.ID
+	event.RiskLevel = rule.RiskLevel
+	event.Environment = env
+	event.Params["event_id"] = eventID
+	event.Params["decision"] = string(outcome.Decision)
+	event.Params["approver"] = outcome.Approver
+	event.Params["waited_ms"] = waited.Milliseconds()
+	if outcome.Group != "" {
+		event.Params["group_id"] = outcome.Group
+		event.Params["group_size"] = outcome.GroupSize
+	}
+	event.WasBlocked = outcome.Decision != approval.DecisionApproved
+
+	i.Core.Worker.Submit(event)
+}
 
-	// 4. Apply Redaction & Submit Event
-	if err := i.applyRedactionAndSubmit(req, action, matchedRule, bodyBytes, requestID, taskID, method, mcpReq); err != nil {
+// WriteRejection answers a rejected call with a JSON-RPC error instead of forwarding it.
+func (i *Interceptor) WriteRejection(w http.ResponseWriter, err error) {
+	if err := assert.NotNil(w, "response writer"); err != nil {
 		return
 	}
+	var rej *Rejection
+	if !errors.As(err, &rej) {
+		rej = &Rejection{Status: http.StatusBadGateway, Code: -32603, Message: "Internal proxy error"}
+	}
+
+	body := map[string]interface{}{
+		"jsonrpc": "2.0",
+		"id":      rej.RequestID,
+		"error":   map[string]interface{}{"code": rej.Code, "message": rej.Message},
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(rej.Status)
+	if encErr := json.NewEncoder(w).Encode(body); encErr != nil {
+		logging.Error("rejection_write_failed", logging.Fields{Component: "interceptor", Error: encErr.Error()})
+	}
 }
 
-// applyRedactionAndSubmit handles redaction and event submission
-func (i *Interceptor) applyRedactionAndSubmit(req *http.Request, action PolicyAction, matchedRule *observer.Rule, bodyBytes []byte, requestID, taskID, method string, mcpReq *mcp.MCPRequest) error {
+// applyRedactionAndSubmit handles redaction and event submission.
+// Returns the ID of the submitted tool_call event.
+func (i *Interceptor) applyRedactionAndSubmit(req *http.Request, action PolicyAction, matchedRule *observer.Rule, bodyBytes []byte, requestID, taskID, method, env string, insp *callInspection, mcpReq *mcp.MCPRequest) (string, error) {
 	if err := assert.Check(mcpReq != nil, "mcpReq must not be nil"); err != nil {
-		return err
+		return "", err
 	}
 	if err := assert.Check(len(method) > 0, "method must not be empty"); err != nil {
-		return err
+		return "", err
 	}
 
 	// Redaction (if needed)
@@ -111,17 +655,30 @@ func (i *Interceptor) applyRedactionAndSubmit(req *http.Request, action PolicyAc
 		if err != nil {
 			logging.Error("redaction_failed", logging.Fields{Component: "interceptor", RequestID: requestID, TaskID: taskID, Method: method, PolicyID: matchedRule.ID, RiskLevel: matchedRule.RiskLevel, Error: err.Error()})
 			i.SendErrorResponse(req, http.StatusInternalServerError, -32000, "Redaction failed")
-			return err
+			return "", err
 		}
 		bodyBytes = scrubbedBody
 		req.Body = io.NopCloser(bytes.NewBuffer(bodyBytes))
 	}
 
-	logging.Info("request_observed", logging.Fields{Component: "interceptor", RequestID: requestID, TaskID: taskID, Method: method, PolicyID: policyIDOrEmpty(matchedRule), RiskLevel: riskLevelOrEmpty(matchedRule)})
+	corr := callCorrelation(req)
+	logging.Info("request_observed", logging.Fields{Component: "interceptor", RequestID: requestID, TaskID: taskID, Method: method, PolicyID: policyIDOrEmpty(matchedRule), RiskLevel: riskLevelOrEmpty(matchedRule), CorrelationID: corr.requestID, TraceID: corr.traceID})
+
+	// The params hash is taken before Submit, which hands the params to the worker.
+	var paramsHash string
+	if i.Core.Observer.SendsUpstreamEventHeaders() {
+		if h, err := crypto.PayloadHash(method, mcpReq.Params); err == nil {
+			paramsHash = h
+		}
+	}
 
 	// Submit Event & Forward
-	i.submitToolCallEvent(taskID, mcpReq, matchedRule)
-	return nil
+	eventID := i.submitToolCallEvent(taskID, env, insp, mcpReq, matchedRule, corr, i.captureRequest(req))
+	if eventID != "" && paramsHash != "" {
+		req.Header.Set(EventIDHeader, eventID)
+		req.Header.Set(EventHashHeader, paramsHash)
+	}
+	return eventID, nil
 }
 
 // extractTaskMetadata parses and validates the request
@@ -129,8 +686,10 @@ func (i *Interceptor) extractTaskMetadata(body []byte) (*mcp.MCPRequest, string,
 	if err := assert.Check(len(body) > 0, "request body is empty"); err != nil {
 		return nil, "", "", err
 	}
-	if err := assert.Check(len(body) < 1024*1024, "request body too large: size=%d", len(body)); err != nil {
-		return nil, "", "", err
+	// The decoder would silently swap invalid UTF-8 for U+FFFD, so the ledger and policy
+	// would see a different method or params than the upstream receives.
+	if !utf8.Valid(body) {
+		return nil, "", "", errors.New("invalid JSON-RPC: body is not valid UTF-8")
 	}
 
 	var mcpReq mcp.MCPRequest
@@ -149,15 +708,17 @@ func (i *Interceptor) extractTaskMetadata(body []byte) (*mcp.MCPRequest, string,
 	return &mcpReq, taskID, mcpReq.Method, nil
 }
 
-// evaluatePolicy determines the action for the request
-func (i *Interceptor) evaluatePolicy(method string, params map[string]interface{}) (PolicyAction, *observer.Rule, error) {
+// evaluatePolicy determines the action for the request under the given deployment profile
+// sqlKeys holds the classes and verbs of any SQL in the call, for rules with match_sql.
+// prior holds the task's earlier calls, most recent first, for rules reading history().
+func (i *Interceptor) evaluatePolicy(method string, params map[string]interface{}, env string, sqlKeys map[string]bool, prior []vql.Env) (PolicyAction, *observer.Rule, error) {
 	if err := assert.Check(i.Core.Observer != nil, "observer engine missing"); err != nil {
 		return ActionAllow, nil, err
 	}
 	if err := assert.Check(method != "", "method name is non-empty"); err != nil {
 		return ActionAllow, nil, err
 	}
-	policies := i.Core.Observer.GetPolicies()
+	policies := i.Core.Observer.GetPoliciesFor(env)
 	if err := assert.Check(len(policies) <= maxPolicies, "policy count exceeds max: %d", len(policies)); err != nil {
 		return ActionAllow, nil, err
 	}
@@ -180,7 +741,10 @@ func (i *Interceptor) evaluatePolicy(method string, params map[string]interface{
 			}
 			pattern := rule.MatchMethods[j]
 			if observer.MatchPattern(pattern, method) {
-				if len(rule.MatchConditions) > 0 && !observer.CheckConditions(rule.MatchConditions, params) {
+				if !rule.MatchesTaskCall(method, params, env, prior) {
+					continue
+				}
+				if len(rule.MatchSQL) > 0 && !observer.MatchSQL(rule.MatchSQL, sqlKeys) {
 					continue
 				}
 
@@ -197,16 +761,27 @@ func (i *Interceptor) evaluatePolicy(method string, params map[string]interface{
 	return ActionAllow, nil, nil
 }
 
-// handleStall was removed in Phase 2 (Lobotomy).
-//func (i *Interceptor) handleStall(...) error { ... }
+// matchAction names what a matched rule did to the call, for per-rule statistics.
+func (i *Interceptor) matchAction(action PolicyAction, rule *observer.Rule) string {
+	switch {
+	case rule.Action == observer.RuleActionStall && i.Core.Observer.IsEnforcing():
+		return observer.MatchActionStall
+	case rule.Action == observer.RuleActionStall:
+		return observer.MatchActionStallObserved
+	case action == ActionRedact:
+		return observer.MatchActionRedact
+	}
+	return observer.MatchActionTag
+}
 
-// submitToolCallEvent prepares and sends the tool_call event to the ledger
-func (i *Interceptor) submitToolCallEvent(taskID string, mcpReq *mcp.MCPRequest, matchedRule *observer.Rule) {
+// submitToolCallEvent prepares and sends the tool_call event to the ledger.
+// Returns the event ID, captured before Submit hands the pooled event to the worker.
+func (i *Interceptor) submitToolCallEvent(taskID, env string, insp *callInspection, mcpReq *mcp.MCPRequest, matchedRule *observer.Rule, corr correlation, capture httpCapture) string {
 	if err := assert.Check(mcpReq != nil, "mcpReq must not be nil"); err != nil {
-		return
+		return ""
 	}
 	if err := assert.Check(i.Core.Worker != nil, "worker must be initialized"); err != nil {
-		return
+		return ""
 	}
 
 	event := pool.GetEvent()
@@ -216,26 +791,57 @@ func (i *Interceptor) submitToolCallEvent(taskID string, mcpReq *mcp.MCPRequest,
 	event.Method = mcpReq.Method
 	event.Params = mcpReq.Params
 	event.TaskID = taskID
+	event.Environment = env
+	event.CorrelationID = corr.requestID
+	event.TraceID = corr.traceID
+	event.SpanID = corr.spanID
+	event.Headers = capture.headers
+	event.QueryParams = capture.query
 
 	if matchedRule != nil {
 		event.PolicyID = matchedRule.ID
 		event.RiskLevel = matchedRule.RiskLevel
-	}
-
-	if taskID != "" {
-		if parentID, ok := i.Core.LastEventByTask.Load(taskID); ok {
-			if pid, ok := parentID.(string); ok {
-				event.ParentID = pid
-			} else {
-				if err := assert.Check(false, "parentID has unexpected type for taskID=%s", taskID); err != nil {
-					logging.Warn("parent_id_type_mismatch", logging.Fields{Component: "interceptor", TaskID: taskID})
-				}
+		if len(matchedRule.Labels) > 0 {
+			event.Labels = make(map[string]string, len(matchedRule.Labels)+1)
+			for k, v := range matchedRule.Labels {
+				event.Labels[k] = v
 			}
 		}
-		i.Core.LastEventByTask.Store(taskID, event.ID)
 	}
+	i.labelCanonicalMethod(event)
+	if insp != nil {
+		event.Tags = insp.tags
+		// Detector-assigned risk only ever raises the rule's level.
+		event.RiskLevel = analyzer.MaxRisk(event.RiskLevel, insp.risk)
+		if insp.sampledOut {
+			sampleOutParams(event, mcpReq.Params)
+		}
+	}
+
+	i.linkParent(event, taskID, corr.parentID)
 
+	eventID := event.ID
 	i.Core.Worker.Submit(event)
+	return eventID
+}
+
+// linkParent chains a call to its task's previous call, unless the agent named the parent
+// in the X-Logryph-Parent-Event header, and makes the call the task's latest.
+func (i *Interceptor) linkParent(event *models.Event, taskID, parentID string) {
+	event.ParentID = parentID
+	if taskID == "" {
+		return
+	}
+	if last, ok := i.Core.LastEventByTask.Load(taskID); ok && parentID == "" {
+		if pid, ok := last.(string); ok {
+			event.ParentID = pid
+		} else {
+			if err := assert.Check(false, "parentID has unexpected type for taskID=%s", taskID); err != nil {
+				logging.Warn("parent_id_type_mismatch", logging.Fields{Component: "interceptor", TaskID: taskID})
+			}
+		}
+	}
+	i.Core.LastEventByTask.Store(taskID, event.ID)
 }
 
 // redactSensitiveData scrubs PII based on policy (accepts and returns bytes)
@@ -275,60 +881,150 @@ func (i *Interceptor) redactSensitiveData(body []byte, keys []string) ([]byte, e
 // and submits tool_response events to the ledger. Returns nil on JSON parse errors
 // to maintain fail-open behavior.
 func (i *Interceptor) InterceptResponse(resp *http.Response) error {
-	buf := pool.GetBuffer()
-	defer pool.PutBuffer(buf)
+	var st *callState
+	if resp.Request != nil {
+		st = callStateFrom(resp.Request.Context())
+	}
+	corr := callCorrelation(resp.Request)
+	if corr.requestID != "" {
+		resp.Header.Set(RequestIDHeader, corr.requestID)
+	}
+	if st != nil && st.callID != "" {
+		resp.Header.Set(EventIDHeader, st.callID)
+	}
 
-	if _, err := buf.ReadFrom(resp.Body); err != nil {
+	body, redacted, err := i.readResponse(resp, st, corr)
+	if body == nil {
 		return err
 	}
-	bodyBytes := buf.Bytes()
-	resp.Body = io.NopCloser(bytes.NewBuffer(bodyBytes))
-
 	var mcpResp mcp.MCPResponse
-	if err := json.Unmarshal(bodyBytes, &mcpResp); err != nil {
+	if err := json.Unmarshal(body.plain, &mcpResp); err != nil {
 		return nil
 	}
-
-	requestID := ""
-	if mcpResp.ID != nil {
-		requestID = fmt.Sprint(mcpResp.ID)
+	result, resultValue, err := mcpResp.DecodeResult()
+	if err != nil {
+		return nil
 	}
-
 	if !i.Core.Worker.IsHealthy() {
 		return nil
 	}
 
-	var taskID string
-	var taskState string
+	event := i.responseEvent(resp, st, corr, &mcpResp, result, resultValue)
+	if redacted > 0 {
+		event.AddTag(TagResponseRedacted)
+	}
+	i.observeLatency(event, st)
+	i.Core.Worker.Submit(event)
+	return nil
+}
 
-	if result := mcpResp.Result; result != nil {
-		if tid, ok := result["task_id"].(string); ok {
-			taskID = tid
-		}
-		if state, ok := result["state"].(string); ok {
-			taskState = state
-			if taskID != "" {
-				i.Core.ActiveTasks.Store(taskID, taskState)
-			}
+// readResponse decodes the response body for inspection and applies the call's response
+// redaction, returning how many fields were scrubbed. A nil body means the response is
+// forwarded as it is, with the error to return: only a body that must be scrubbed but
+// cannot be read fails the response.
+func (i *Interceptor) readResponse(resp *http.Response, st *callState, corr correlation) (*responseBody, int, error) {
+	body, err := readResponseBody(resp)
+	if body == nil {
+		return nil, 0, err
+	}
+	body.forward(resp)
+	if err != nil {
+		logging.Warn("response_decode_failed", logging.Fields{Component: "interceptor", CorrelationID: corr.requestID, Error: err.Error()})
+		if st != nil && len(st.redact) > 0 {
+			// The body cannot be inspected, so it cannot be scrubbed either: fail closed.
+			return nil, 0, fmt.Errorf("response redaction for %s: %w", st.method, err)
 		}
+		return nil, 0, nil
 	}
+	redacted, err := i.redactResponse(resp, body, st)
+	if err != nil {
+		return nil, 0, err
+	}
+	return body, redacted, nil
+}
 
-	logging.Info("response_observed", logging.Fields{Component: "interceptor", RequestID: requestID, TaskID: taskID})
+// responseEvent builds the tool_response for a decoded result, linked to its call.
+func (i *Interceptor) responseEvent(resp *http.Response, st *callState, corr correlation, mcpResp *mcp.MCPResponse, result map[string]interface{}, resultValue interface{}) *models.Event {
+	requestID := ""
+	if mcpResp.ID != nil {
+		requestID = fmt.Sprint(mcpResp.ID)
+	}
+	taskID, taskState := i.observeResult(st, result, requestID)
+	logging.Info("response_observed", logging.Fields{Component: "interceptor", RequestID: requestID, TaskID: taskID, CorrelationID: corr.requestID, TraceID: corr.traceID})
 
 	event := pool.GetEvent()
 	event.ID = uuid.New().String()[:8]
 	event.Timestamp = time.Now()
 	event.EventType = "tool_response"
-	event.Response = mcpResp.Result
+	event.Response = result
+	event.ResponseValue = resultValue
 	event.TaskID = taskID
 	event.TaskState = taskState
+	if st == nil {
+		event.Environment = i.resolveEnvironment(resp.Request)
+	} else {
+		event.ParentID, event.Environment = st.callID, st.env
+		// The response carries its call's policy outcome, so risk queries return both.
+		event.PolicyID, event.RiskLevel = st.rule, st.risk
+		st.responded = true
+	}
+	event.CorrelationID = corr.requestID
+	event.TraceID = corr.traceID
+	event.SpanID = corr.spanID
+	event.Headers = i.captureResponseHeaders(resp.Header)
+	if st != nil && st.sampledOut {
+		sampleOutResult(event, mcpResp.Result)
+	} else if truncateResult(event, mcpResp.Result, i.maxResponseBytes()) {
+		logging.Info("response_truncated", logging.Fields{Component: "interceptor", RequestID: requestID, TaskID: taskID, CorrelationID: corr.requestID})
+	}
+	return event
+}
 
-	i.Core.Worker.Submit(event)
-	return nil
+// observeResult takes tool schemas from a tools/list result and the task's ID and state
+// from any result, returning the task the response belongs to.
+func (i *Interceptor) observeResult(st *callState, result map[string]interface{}, requestID string) (taskID, taskState string) {
+	if st != nil {
+		taskID = st.taskID
+	}
+	if result == nil {
+		return taskID, ""
+	}
+	// Only a tools/list listing defines schemas; a tool's own output could otherwise
+	// replace the schema of any tool and switch its validation off.
+	if i.Core.Schemas != nil && st != nil && st.method == "tools/list" {
+		if n := i.Core.Schemas.Observe(result); n > 0 {
+			logging.Info("tool_schemas_captured", logging.Fields{Component: "interceptor", RequestID: requestID, Method: "tools/list"})
+		}
+	}
+	if tid, ok := result["task_id"].(string); ok {
+		taskID = tid
+	}
+	if state, ok := result["state"].(string); ok {
+		taskState = state
+		if taskID != "" {
+			i.Core.ActiveTasks.Store(taskID, taskState)
+		}
+	}
+	return taskID, taskState
+}
+
+// observeLatency records on the tool_response how long the upstream took to answer the
+// call, and feeds it to the latency objectives. Approval stalls are not counted.
+func (i *Interceptor) observeLatency(event *models.Event, st *callState) {
+	if st == nil || st.callID == "" || st.tool == "" || st.forwarded.IsZero() {
+		return
+	}
+	latency := event.Timestamp.Sub(st.forwarded)
+	event.Params["latency_ms"] = latency.Milliseconds()
+	i.Core.SLO.Observe(slo.Sample{
+		Method: st.tool, Latency: latency, At: event.Timestamp,
+		CallID: st.callID, TaskID: event.TaskID, Environment: event.Environment,
+	})
 }
 
 // SendErrorResponse is a no-op stub retained for backwards compatibility.
-// Phase 2 (Lobotomy) removed request blocking - Logryph now operates as a passive recorder.
+// Phase 2 (Lobotomy) removed request blocking for malformed or unparseable traffic -
+// Logryph records what it can and forwards. Enforce-mode rejections use WriteRejection.
 // Does not send HTTP errors or block traffic.
 func (i *Interceptor) SendErrorResponse(req *http.Request, statusCode int, code int, message string) {
 	// Passive: We do not block. We just log the failure to record if needed.
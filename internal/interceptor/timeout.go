@@ -0,0 +1,95 @@
+package interceptor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/slyt3/Logryph/internal/assert"
+	"github.com/slyt3/Logryph/internal/logging"
+	"github.com/slyt3/Logryph/internal/pool"
+)
+
+// EventCallTimeout marks calls whose upstream did not answer within the policy timeout.
+const EventCallTimeout = "call_timeout"
+
+// JSON-RPC error code returned to the agent when the upstream times out.
+const codeUpstreamTimeout = -32003
+
+var upstreamTimeouts atomic.Uint64
+
+// UpstreamTimeouts returns how many calls have exceeded their upstream timeout.
+// Safe for concurrent access.
+func UpstreamTimeouts() uint64 {
+	return upstreamTimeouts.Load()
+}
+
+// ProxyError is the reverse proxy's ErrorHandler. A call that ran past its policy timeout
+// gets a JSON-RPC timeout error and a call_timeout event; a call the agent abandoned gets
+// nothing, since nobody is listening; other failures get a JSON-RPC 502 and an
+// upstream_error event.
+func (i *Interceptor) ProxyError(w http.ResponseWriter, r *http.Request, err error) {
+	if err := assert.NotNil(w, "response writer"); err != nil {
+		return
+	}
+	st := callStateFrom(r.Context())
+	if st != nil && st.timeout > 0 && errors.Is(err, context.DeadlineExceeded) {
+		i.recordTimeout(st)
+		w.Header().Set(RequestIDHeader, st.corr.requestID)
+		i.WriteRejection(w, &Rejection{
+			RequestID: st.rpcID,
+			Status:    http.StatusGatewayTimeout,
+			Code:      codeUpstreamTimeout,
+			Message:   fmt.Sprintf("Upstream timed out after %s", st.timeout),
+		})
+		return
+	}
+	if errors.Is(err, context.Canceled) {
+		w.WriteHeader(http.StatusBadGateway)
+		return
+	}
+
+	class := upstreamErrorClass(err)
+	rej := &Rejection{Status: http.StatusBadGateway, Code: -32603, Message: "Upstream unavailable: " + class}
+	fields := logging.Fields{Component: "interceptor", Error: err.Error()}
+	if st != nil {
+		rej.RequestID = st.rpcID
+		fields.EventID, fields.TaskID, fields.Method, fields.CorrelationID, fields.TraceID = st.callID, st.taskID, st.method, st.corr.requestID, st.corr.traceID
+		w.Header().Set(RequestIDHeader, st.corr.requestID)
+	}
+	logging.Error("upstream_failed", fields)
+	i.recordUpstreamError(st, class, err)
+	i.WriteRejection(w, rej)
+}
+
+// recordTimeout counts the timeout and ledgers it as a child of the call, which keeps the
+// call from being reported as aborted as well.
+func (i *Interceptor) recordTimeout(st *callState) {
+	upstreamTimeouts.Add(1)
+	st.responded = true
+	logging.Warn("call_timeout", logging.Fields{Component: "interceptor", EventID: st.callID, TaskID: st.taskID, Method: st.method, CorrelationID: st.corr.requestID, TraceID: st.corr.traceID})
+	if st.callID == "" || i.Core == nil || i.Core.Worker == nil {
+		return
+	}
+
+	event := pool.GetEvent()
+	event.ID = uuid.New().String()[:8]
+	event.Timestamp = time.Now()
+	event.Actor = "system"
+	event.EventType = EventCallTimeout
+	event.Method = st.method
+	event.TaskID = st.taskID
+	event.ParentID = st.callID
+	event.Environment = st.env
+	event.CorrelationID = st.corr.requestID
+	event.TraceID = st.corr.traceID
+	event.SpanID = st.corr.spanID
+	event.Params["timeout_ms"] = st.timeout.Milliseconds()
+	event.Params["elapsed_ms"] = time.Since(st.started).Milliseconds()
+
+	i.Core.Worker.Submit(event)
+}
@@ -0,0 +1,151 @@
+package interceptor
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httputil"
+	"net/url"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/slyt3/Logryph/internal/models"
+)
+
+const timeoutPolicy = `
+version: "1.0"
+defaults:
+  upstream_timeout: "10s"
+policies:
+  - id: "slow-tool"
+    match_methods: ["report:build"]
+    risk_level: "low"
+    timeout: "30ms"
+`
+
+func TestHandlerTimesOutSlowUpstream(t *testing.T) {
+	release := make(chan struct{})
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer upstream.Close()
+	defer close(release)
+
+	i, events := newLedgeredInterceptor(t, timeoutPolicy)
+	target, _ := url.Parse(upstream.URL)
+	proxy := httputil.NewSingleHostReverseProxy(target)
+	proxy.ErrorHandler = i.ProxyError
+	before := UpstreamTimeouts()
+
+	body := `{"jsonrpc":"2.0","id":7,"method":"report:build","params":{}}`
+	rec := httptest.NewRecorder()
+	start := time.Now()
+	i.Handler(proxy).ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/", bytes.NewBufferString(body)))
+
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("rule timeout not applied, call took %s", elapsed)
+	}
+	if rec.Code != http.StatusGatewayTimeout {
+		t.Fatalf("expected 504, got %d", rec.Code)
+	}
+	var resp struct {
+		ID    int `json:"id"`
+		Error struct {
+			Code int `json:"code"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil || resp.ID != 7 || resp.Error.Code != codeUpstreamTimeout {
+		t.Fatalf("expected JSON-RPC timeout error for id 7, got %q (%v)", rec.Body.String(), err)
+	}
+	if UpstreamTimeouts() != before+1 {
+		t.Errorf("timeout counter not incremented")
+	}
+	if st := i.Core.Observer.GetRuleStat("slow-tool"); st.Matches != 1 || st.Actions["tag"] != 1 {
+		t.Errorf("rule match not counted: %+v", st)
+	}
+
+	var callID string
+	seen := map[string]string{}
+	for _, e := range events() {
+		if e.EventType == "tool_call" {
+			callID = e.ID
+		}
+		seen[e.EventType] = e.ParentID
+	}
+	if parent, ok := seen[EventCallTimeout]; !ok || parent != callID {
+		t.Fatalf("expected call_timeout linked to %s, got %v", callID, seen)
+	}
+	if _, ok := seen[EventCallAborted]; ok {
+		t.Error("a timed-out call must not also be reported as aborted")
+	}
+}
+
+func TestProxyErrorRecordsUnreachableUpstream(t *testing.T) {
+	upstream := httptest.NewServer(http.NotFoundHandler())
+	target, _ := url.Parse(upstream.URL)
+	upstream.Close()
+
+	i, events := newLedgeredInterceptor(t, "version: \"1.0\"\npolicies: []\n")
+	proxy := httputil.NewSingleHostReverseProxy(target)
+	proxy.ErrorHandler = i.ProxyError
+	before := UpstreamErrors()
+
+	body := `{"jsonrpc":"2.0","id":3,"method":"compute:list_instances","params":{}}`
+	rec := httptest.NewRecorder()
+	i.Handler(proxy).ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/", bytes.NewBufferString(body)))
+	if rec.Code != http.StatusBadGateway {
+		t.Fatalf("expected 502, got %d", rec.Code)
+	}
+	if UpstreamErrors() != before+1 {
+		t.Errorf("upstream error counter not incremented")
+	}
+
+	var callID string
+	var failure *models.Event
+	all := events()
+	for j := range all {
+		switch all[j].EventType {
+		case "tool_call":
+			callID = all[j].ID
+		case EventUpstreamError:
+			failure = &all[j]
+		}
+	}
+	if failure == nil {
+		t.Fatal("no upstream_error event recorded")
+	}
+	if failure.ParentID != callID || failure.Method != "compute:list_instances" || failure.Params["error_class"] != upstreamErrRefused {
+		t.Errorf("unexpected upstream_error event: parent %s, method %s, params %v", failure.ParentID, failure.Method, failure.Params)
+	}
+}
+
+func TestUpstreamErrorClass(t *testing.T) {
+	cases := []struct {
+		err  error
+		want string
+	}{
+		{&net.OpError{Op: "dial", Err: syscall.ECONNREFUSED}, upstreamErrRefused},
+		{&net.OpError{Op: "read", Err: syscall.ECONNRESET}, upstreamErrReset},
+		{&net.OpError{Op: "dial", Err: &net.DNSError{Err: "no such host", Name: "tools.internal", IsNotFound: true}}, upstreamErrDNS},
+		{fmt.Errorf("tls: %w", x509.UnknownAuthorityError{}), upstreamErrTLS},
+		{&net.OpError{Op: "dial", Err: os.ErrDeadlineExceeded}, upstreamErrTimeout},
+		{io.ErrUnexpectedEOF, upstreamErrClosed},
+		{errors.New("boom"), upstreamErrOther},
+	}
+	for _, c := range cases {
+		if got := upstreamErrorClass(c.err); got != c.want {
+			t.Errorf("upstreamErrorClass(%v) = %s, want %s", c.err, got, c.want)
+		}
+	}
+}
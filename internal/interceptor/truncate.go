@@ -0,0 +1,58 @@
+package interceptor
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"unicode/utf8"
+
+	"github.com/slyt3/Logryph/internal/models"
+)
+
+// TagResponseTruncated marks a tool_response whose result was larger than
+// capture.max_response_bytes and is stored as its head and tail only.
+const TagResponseTruncated = "response_truncated"
+
+// truncateResult stores an oversized result on the tool_response as a string: the first
+// and last max/2 bytes of the result JSON around a marker naming the bytes left out. The
+// full size and the SHA-256 of the result as the agent received it go in params, so a
+// copy kept elsewhere can still be checked against the signed event. It reports whether
+// the result was truncated.
+func truncateResult(event *models.Event, result []byte, max int) bool {
+	raw := bytes.TrimSpace(result)
+	if max <= 0 || len(raw) <= max {
+		return false
+	}
+	half := max / 2
+	headEnd := runeBoundary(raw, half)
+	tailStart := runeBoundary(raw, len(raw)-half)
+	sum := sha256.Sum256(raw)
+	digest := hex.EncodeToString(sum[:])
+
+	event.Response = nil
+	event.ResponseValue = fmt.Sprintf("%s\n...[logryph: %d of %d bytes truncated, sha256 %s]...\n%s",
+		raw[:headEnd], tailStart-headEnd, len(raw), digest, raw[tailStart:])
+	event.Params["response_bytes"] = len(raw)
+	event.Params["response_stored_bytes"] = headEnd + len(raw) - tailStart
+	event.Params["response_sha256"] = digest
+	event.AddTag(TagResponseTruncated)
+	return true
+}
+
+// maxResponseBytes returns capture.max_response_bytes, or zero without a policy.
+func (i *Interceptor) maxResponseBytes() int {
+	if i.Core == nil || i.Core.Observer == nil {
+		return 0
+	}
+	return i.Core.Observer.GetCapture().MaxResponseBytes
+}
+
+// runeBoundary moves n back to the start of the UTF-8 sequence it falls in, so a cut
+// never splits a character.
+func runeBoundary(b []byte, n int) int {
+	for j := 0; j < utf8.UTFMax && n > 0 && n < len(b) && !utf8.RuneStart(b[n]); j++ {
+		n--
+	}
+	return n
+}
@@ -0,0 +1,95 @@
+package interceptor
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io"
+	"net"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/slyt3/Logryph/internal/pool"
+)
+
+// EventUpstreamError records a call the proxy could not complete because the upstream
+// was unreachable or dropped the connection.
+const EventUpstreamError = "upstream_error"
+
+// Error classes recorded on upstream_error events.
+const (
+	upstreamErrRefused = "connection_refused"
+	upstreamErrReset   = "connection_reset"
+	upstreamErrDNS     = "dns"
+	upstreamErrTLS     = "tls"
+	upstreamErrTimeout = "timeout"
+	upstreamErrClosed  = "connection_closed"
+	upstreamErrOther   = "other"
+)
+
+var upstreamErrors atomic.Uint64
+
+// UpstreamErrors returns how many calls failed to reach the upstream.
+// Safe for concurrent access.
+func UpstreamErrors() uint64 {
+	return upstreamErrors.Load()
+}
+
+// upstreamErrorClass names the kind of transport failure, coarse enough to aggregate on.
+func upstreamErrorClass(err error) string {
+	var dnsErr *net.DNSError
+	var certErr *tls.CertificateVerificationError
+	var recordErr tls.RecordHeaderError
+	var unknownAuth x509.UnknownAuthorityError
+	var hostErr x509.HostnameError
+	var netErr net.Error
+	switch {
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return upstreamErrRefused
+	case errors.Is(err, syscall.ECONNRESET), errors.Is(err, syscall.EPIPE):
+		return upstreamErrReset
+	case errors.As(err, &dnsErr):
+		return upstreamErrDNS
+	case errors.As(err, &certErr), errors.As(err, &recordErr), errors.As(err, &unknownAuth), errors.As(err, &hostErr):
+		return upstreamErrTLS
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr) && netErr.Timeout():
+		return upstreamErrTimeout
+	case errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+		return upstreamErrClosed
+	}
+	return upstreamErrOther
+}
+
+// recordUpstreamError counts the failure and ledgers it as a child of the call, which
+// keeps the call from being reported as aborted as well.
+func (i *Interceptor) recordUpstreamError(st *callState, class string, err error) {
+	upstreamErrors.Add(1)
+	if st == nil {
+		return
+	}
+	st.responded = true
+	if st.callID == "" || i.Core == nil || i.Core.Worker == nil {
+		return
+	}
+
+	event := pool.GetEvent()
+	event.ID = uuid.New().String()[:8]
+	event.Timestamp = time.Now()
+	event.Actor = "system"
+	event.EventType = EventUpstreamError
+	event.Method = st.method
+	event.TaskID = st.taskID
+	event.ParentID = st.callID
+	event.Environment = st.env
+	event.CorrelationID = st.corr.requestID
+	event.TraceID = st.corr.traceID
+	event.SpanID = st.corr.spanID
+	event.Params["error_class"] = class
+	event.Params["error"] = err.Error()
+	event.Params["elapsed_ms"] = time.Since(st.started).Milliseconds()
+
+	i.Core.Worker.Submit(event)
+}
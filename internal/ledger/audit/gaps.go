@@ -0,0 +1,88 @@
+package audit
+
+import (
+	"fmt"
+
+	"github.com/slyt3/Logryph/internal/models"
+)
+
+// EventTypeGapAcknowledged records an operator's acknowledgement of a missing range of
+// sequence indexes. Its params carry missing_from, missing_to, missing_count, operator
+// and reason.
+const EventTypeGapAcknowledged = "gap_acknowledged"
+
+// Gap is an inclusive range of sequence indexes with no stored event.
+type Gap struct {
+	From uint64 `json:"from"`
+	To   uint64 `json:"to"`
+}
+
+// Count is the number of missing events in the gap.
+func (g Gap) Count() uint64 {
+	return g.To - g.From + 1
+}
+
+func (g Gap) String() string {
+	if g.From == g.To {
+		return fmt.Sprintf("seq %d", g.From)
+	}
+	return fmt.Sprintf("seq %d-%d", g.From, g.To)
+}
+
+// FindGaps returns the missing sequence ranges in events, which must be ordered by
+// sequence index, split into those already acknowledged and those that are not.
+func FindGaps(events []models.Event) (acknowledged, unacknowledged []Gap) {
+	acked := AcknowledgedGaps(events)
+	var next uint64
+	for i := 0; i < len(events); i++ {
+		seq := events[i].SeqIndex
+		if seq > next {
+			gap := Gap{From: next, To: seq - 1}
+			if acked[gap] {
+				acknowledged = append(acknowledged, gap)
+			} else {
+				unacknowledged = append(unacknowledged, gap)
+			}
+		}
+		if seq >= next {
+			next = seq + 1
+		}
+	}
+	return acknowledged, unacknowledged
+}
+
+// AcknowledgedGaps returns the ranges covered by gap_acknowledged events. The events'
+// signatures are not checked here; chain verification does that.
+func AcknowledgedGaps(events []models.Event) map[Gap]bool {
+	gaps := make(map[Gap]bool)
+	for i := 0; i < len(events); i++ {
+		if events[i].EventType != EventTypeGapAcknowledged {
+			continue
+		}
+		from, okFrom := paramUint(events[i].Params, "missing_from")
+		to, okTo := paramUint(events[i].Params, "missing_to")
+		if okFrom && okTo && from <= to {
+			gaps[Gap{From: from, To: to}] = true
+		}
+	}
+	return gaps
+}
+
+// paramUint reads a sequence index that may have been decoded from JSON as a float64.
+func paramUint(params map[string]interface{}, key string) (uint64, bool) {
+	switch v := params[key].(type) {
+	case uint64:
+		return v, true
+	case float64:
+		if v < 0 || v != float64(uint64(v)) {
+			return 0, false
+		}
+		return uint64(v), true
+	case int:
+		if v < 0 {
+			return 0, false
+		}
+		return uint64(v), true
+	}
+	return 0, false
+}
@@ -0,0 +1,96 @@
+package audit
+
+import (
+	"fmt"
+
+	"github.com/slyt3/Logryph/internal/assert"
+	"github.com/slyt3/Logryph/internal/crypto"
+	"github.com/slyt3/Logryph/internal/models"
+)
+
+// EventTypeRunClosed is the last event of a run ended by rotation. Its params carry
+// reason, head_seq and head_hash (the event before it). The next run's genesis links back
+// to it with the params below.
+const EventTypeRunClosed = "run_closed"
+
+// Genesis params linking a run to the run it replaced.
+const (
+	ParamPrevRunID   = "prev_run_id"
+	ParamPrevRunSeq  = "prev_run_seq"  // seq of the previous run's run_closed event
+	ParamPrevRunHead = "prev_run_head" // hash of that event
+)
+
+const maxLinkedRuns = 10000
+
+// RunVerification is the result for one run of a linked verification.
+type RunVerification struct {
+	RunID  string
+	Result *VerificationResult
+}
+
+// LinkedVerificationResult covers a run and the runs before it, newest first.
+type LinkedVerificationResult struct {
+	Runs         []RunVerification
+	Valid        bool
+	ErrorMessage string
+}
+
+// VerifyLinkedRuns verifies runID and follows each genesis back to the run it replaced,
+// checking every chain and that each closed run ends at the head the next genesis names.
+// It stops at the first run whose genesis links to nothing, or at the first failure.
+func VerifyLinkedRuns(db EventReader, runID string, signer *crypto.Signer) (*LinkedVerificationResult, error) {
+	if err := assert.Check(signer != nil, "signer is nil"); err != nil {
+		return nil, err
+	}
+	if err := assert.Check(db != nil, "database connection missing"); err != nil {
+		return nil, err
+	}
+	out := &LinkedVerificationResult{Valid: true}
+	var link *Checkpoint // what the newer run's genesis says this run ends with
+	seen := make(map[string]bool)
+	for i := 0; i < maxLinkedRuns; i++ {
+		if runID == "" || seen[runID] {
+			return out, nil
+		}
+		seen[runID] = true
+		var first, last models.Event
+		res, err := walkRun(db, runID, signer.VerifySignature, func(e *models.Event) {
+			if e.SeqIndex == 0 {
+				first = *e
+			}
+			last = *e
+		})
+		if err != nil {
+			return nil, fmt.Errorf("run %s: %w", runID, err)
+		}
+		out.Runs = append(out.Runs, RunVerification{RunID: runID, Result: res})
+		if !res.Valid {
+			out.Valid, out.ErrorMessage = false, fmt.Sprintf("run %s: %s", runID, res.ErrorMessage)
+			return out, nil
+		}
+		if link != nil && !closedAt(&last, link) {
+			out.Valid = false
+			out.ErrorMessage = fmt.Sprintf("run %s does not end with the run_closed event (seq %d) the next run's genesis references", runID, link.Seq)
+			return out, nil
+		}
+		runID, link = previousRun(&first)
+	}
+	return nil, fmt.Errorf("more than %d linked runs", maxLinkedRuns)
+}
+
+// closedAt reports whether last is the run_closed event link identifies.
+func closedAt(last *models.Event, link *Checkpoint) bool {
+	return last.EventType == EventTypeRunClosed && last.SeqIndex == link.Seq && last.CurrentHash == link.Hash
+}
+
+// previousRun reads the link from a genesis event, returning "" if the run was not
+// started by rotation.
+func previousRun(genesis *models.Event) (string, *Checkpoint) {
+	runID, _ := genesis.Params[ParamPrevRunID].(string)
+	hash, _ := genesis.Params[ParamPrevRunHead].(string)
+	seq, ok := paramUint(genesis.Params, ParamPrevRunSeq)
+	if runID == "" || hash == "" || !ok {
+		return "", nil
+	}
+	return runID, &Checkpoint{RunID: runID, Seq: seq, Hash: hash}
+}
@@ -0,0 +1,122 @@
+package audit
+
+import (
+	"github.com/slyt3/Logryph/internal/crypto"
+	"github.com/slyt3/Logryph/internal/models"
+)
+
+// StreamVerifier checks a run's chain a page at a time, so a run of any size verifies in
+// constant memory. Pages must be consecutive and in sequence order.
+type StreamVerifier struct {
+	head   *Checkpoint
+	acked  map[Gap]bool
+	verify signatureCheck
+	result VerificationResult
+
+	// Set by DeferGaps: the acknowledgements seen so far, and the gaps let through
+	// before theirs arrived.
+	confirmed map[Gap]bool
+	pending   []pendingGap
+}
+
+// pendingGap is a gap crossed before its acknowledgement was seen, and the sequence
+// index of the event after it.
+type pendingGap struct {
+	gap Gap
+	seq uint64
+}
+
+// maxPendingGaps bounds the gaps DeferGaps holds open; past it, a gap must already be
+// acknowledged.
+const maxPendingGaps = 1 << 16
+
+// NewStreamVerifier verifies against a hex-encoded public key. acked are the run's
+// acknowledged gaps (see AcknowledgedGaps); they are passed in because an acknowledgement
+// is appended after the gap it covers and may be on a later page.
+func NewStreamVerifier(pubKeyHex string, acked map[Gap]bool) *StreamVerifier {
+	return &StreamVerifier{
+		acked: acked,
+		verify: func(hash, signatureHex string) bool {
+			return crypto.VerifyWithPublicKey(pubKeyHex, hash, signatureHex)
+		},
+		result: VerificationResult{Valid: true},
+	}
+}
+
+// Resume makes the first page link to head, for a stretch that does not start at the
+// beginning of the run. Call it before Verify.
+func (v *StreamVerifier) Resume(head Checkpoint) {
+	v.head = &head
+}
+
+// DeferGaps lets a gap through on the strength of an acknowledgement on a later page,
+// for a caller reading a stream once that cannot collect acknowledgements up front. A
+// gap still unacknowledged when Result is called fails the chain at the event after it.
+// Call it before Verify.
+func (v *StreamVerifier) DeferGaps() {
+	v.confirmed = make(map[Gap]bool, len(v.acked))
+	acked := make(map[Gap]bool, len(v.acked))
+	for gap := range v.acked {
+		v.confirmed[gap], acked[gap] = true, true
+	}
+	v.acked = acked
+}
+
+// Verify checks the next page and reports whether the chain is still valid.
+func (v *StreamVerifier) Verify(events []models.Event) bool {
+	if !v.result.Valid || len(events) == 0 {
+		return v.result.Valid
+	}
+	v.result.TotalEvents += len(events)
+	if v.confirmed != nil {
+		v.deferGaps(events)
+	}
+	verifySequence(events, v.head, v.acked, v.verify, &v.result)
+	last := events[len(events)-1]
+	v.head = &Checkpoint{RunID: last.RunID, Seq: last.SeqIndex, Hash: last.CurrentHash}
+	return v.result.Valid
+}
+
+// deferGaps records the page's acknowledgements and lets through the gaps it crosses
+// that have none yet, remembering them for Result.
+func (v *StreamVerifier) deferGaps(events []models.Event) {
+	for gap := range AcknowledgedGaps(events) {
+		v.confirmed[gap], v.acked[gap] = true, true
+	}
+	prev := v.head
+	for i := 0; i < len(events); i++ {
+		if prev != nil && events[i].PrevHash != prev.Hash && events[i].SeqIndex > prev.Seq+1 {
+			gap := Gap{From: prev.Seq + 1, To: events[i].SeqIndex - 1}
+			if !v.acked[gap] && len(v.pending) < maxPendingGaps {
+				v.acked[gap] = true
+				v.pending = append(v.pending, pendingGap{gap: gap, seq: events[i].SeqIndex})
+			}
+		}
+		prev = &Checkpoint{Seq: events[i].SeqIndex, Hash: events[i].CurrentHash}
+	}
+}
+
+// Result returns the outcome of the pages verified so far.
+func (v *StreamVerifier) Result() *VerificationResult {
+	result := v.result
+	result.AcknowledgedGaps = nil
+	for i := 0; i < len(v.result.AcknowledgedGaps); i++ {
+		if gap := v.result.AcknowledgedGaps[i]; v.confirmed == nil || v.confirmed[gap] {
+			result.AcknowledgedGaps = append(result.AcknowledgedGaps, gap)
+		}
+	}
+	// Pending gaps are in sequence order, so the first unacknowledged one is the earliest.
+	for i := 0; i < len(v.pending); i++ {
+		p := v.pending[i]
+		if v.confirmed[p.gap] {
+			continue
+		}
+		if result.Valid || p.seq < result.FailedAtSeq {
+			result.Valid = false
+			result.ErrorMessage = ErrChainTampered.Error()
+			result.FailedAtSeq = p.seq
+		}
+		break
+	}
+	return &result
+}
@@ -1,8 +1,11 @@
 package audit
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
+	"runtime"
+	"sync"
 	"time"
 
 	"github.com/slyt3/Logryph/internal/assert"
@@ -10,9 +13,10 @@ import (
 	"github.com/slyt3/Logryph/internal/models"
 )
 
-// EventReader defines the subset of ledger operations needed for verification.
+// EventReader defines the subset of ledger operations needed for verification. Runs are
+// read one event at a time, so verification does not hold a run in memory.
 type EventReader interface {
-	GetAllEvents(runID string) ([]models.Event, error)
+	ForEachEvent(runID string, fn func(*models.Event) error) error
 }
 
 // VerificationResult contains the results of chain verification
@@ -21,71 +25,218 @@ type VerificationResult struct {
 	TotalEvents  int
 	ErrorMessage string
 	FailedAtSeq  uint64
+	// AcknowledgedGaps are chain breaks accepted because a signed gap_acknowledged event
+	// documents the missing range.
+	AcknowledgedGaps []Gap
 }
 
+const (
+	maxVerifyEvents   = 100000
+	maxVerifyWorkers  = 64
+	minParallelEvents = 64   // smaller slices are not worth the goroutines
+	verifyPageSize    = 4096 // events read before a page is verified
+)
+
+// signatureCheck reports whether signatureHex is a valid signature of hash.
+type signatureCheck func(hash, signatureHex string) bool
+
 // VerifyChain validates the entire event chain for a given run
 func VerifyChain(db EventReader, runID string, signer *crypto.Signer) (*VerificationResult, error) {
-	if err := assert.Check(runID != "", "runID must not be empty"); err != nil {
+	if err := assert.Check(signer != nil, "signer is nil"); err != nil {
 		return nil, err
 	}
-	if err := assert.Check(db != nil, "database connection missing"); err != nil {
+	return verifyChain(db, runID, signer.VerifySignature)
+}
+
+// VerifyChainWithKey validates a run's chain against a hex-encoded Ed25519 public key, for
+// ledgers whose private key is held by another instance.
+func VerifyChainWithKey(db EventReader, runID, pubKeyHex string) (*VerificationResult, error) {
+	if err := assert.Check(pubKeyHex != "", "public key must not be empty"); err != nil {
 		return nil, err
 	}
-	if err := assert.Check(signer != nil, "signer is nil"); err != nil {
+	return verifyChain(db, runID, func(hash, signatureHex string) bool {
+		return crypto.VerifyWithPublicKey(pubKeyHex, hash, signatureHex)
+	})
+}
+
+func verifyChain(db EventReader, runID string, verify signatureCheck) (*VerificationResult, error) {
+	if err := assert.Check(runID != "", "runID must not be empty"); err != nil {
 		return nil, err
 	}
-	result := &VerificationResult{
-		Valid: true,
+	if err := assert.Check(db != nil, "database connection missing"); err != nil {
+		return nil, err
 	}
+	return walkRun(db, runID, verify, nil)
+}
 
-	// Get all events for this run, ordered by sequence
-	events, err := db.GetAllEvents(runID)
+// errStopWalk ends a ForEachEvent walk at the first verification failure.
+var errStopWalk = errors.New("verification failed")
+
+// walkRun verifies runID's chain a page at a time, calling visit, if set, with each event
+// as it is read. Gap acknowledgements are collected in a first pass, since
+// each is stored after the gap it covers.
+func walkRun(db EventReader, runID string, verify signatureCheck, visit func(*models.Event)) (*VerificationResult, error) {
+	var acks []models.Event
+	err := db.ForEachEvent(runID, func(e *models.Event) error {
+		if e.EventType == EventTypeGapAcknowledged {
+			acks = append(acks, *e)
+		}
+		return nil
+	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to get events: %w", err)
 	}
-
-	result.TotalEvents = len(events)
-
-	if len(events) == 0 {
+	v := &StreamVerifier{acked: AcknowledgedGaps(acks), verify: verify, result: VerificationResult{Valid: true}}
+	page := make([]models.Event, 0, verifyPageSize)
+	err = db.ForEachEvent(runID, func(e *models.Event) error {
+		if visit != nil {
+			visit(e)
+		}
+		if page = append(page, *e); len(page) < verifyPageSize {
+			return nil
+		}
+		ok := v.Verify(page)
+		page = page[:0]
+		if !ok {
+			return errStopWalk
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errStopWalk) {
+		return nil, fmt.Errorf("failed to get events: %w", err)
+	}
+	v.Verify(page)
+	result := v.Result()
+	if result.TotalEvents == 0 {
 		result.Valid = false
 		result.ErrorMessage = ErrNoEvents.Error()
-		return result, nil
 	}
+	return result, nil
+}
 
-	const maxVerifyEvents = 100000
-	if err := assert.Check(len(events) <= maxVerifyEvents, "event count exceeds max: %d", len(events)); err != nil {
-		return nil, err
+// VerifyEventsWithKey checks a contiguous slice of a run's chain against a hex-encoded
+// public key, for incremental verification. head is the last event already verified,
+// which the first event must link to; nil means events starts at genesis.
+func VerifyEventsWithKey(events []models.Event, head *Checkpoint, pubKeyHex string) *VerificationResult {
+	result := &VerificationResult{Valid: true, TotalEvents: len(events)}
+	verifySequence(events, head, AcknowledgedGaps(events), func(hash, signatureHex string) bool {
+		return crypto.VerifyWithPublicKey(pubKeyHex, hash, signatureHex)
+	}, result)
+	return result
+}
+
+// Checkpoint identifies a verified chain head.
+type Checkpoint struct {
+	RunID string `json:"run_id"`
+	Seq   uint64 `json:"seq"`
+	Hash  string `json:"hash"`
+}
+
+// verifySequence checks events in order, recording the first failure in result. Links
+// between events are checked in one sequential pass; each event's own hash and signature
+// depend on nothing else and are checked in parallel. Breaks covered by a range in acked
+// are accepted.
+func verifySequence(events []models.Event, head *Checkpoint, acked map[Gap]bool, verify signatureCheck, result *VerificationResult) {
+	if len(events) > maxVerifyEvents {
+		events = events[:maxVerifyEvents]
 	}
-	// Verify each event
-	for i := 0; i < maxVerifyEvents; i++ {
-		if i >= len(events) {
-			break
+	broken, gaps := checkLinks(events, head, acked)
+	errs := checkEvents(events[:broken], verify)
+	for i := 0; i < broken; i++ {
+		for len(gaps) > 0 && gaps[0].index <= i {
+			result.AcknowledgedGaps = append(result.AcknowledgedGaps, gaps[0].gap)
+			gaps = gaps[1:]
 		}
-		event := events[i]
-		// Verify hash chain linkage (except for genesis)
-		if i > 0 {
-			prevEvent := events[i-1]
-			if event.PrevHash != prevEvent.CurrentHash {
-				result.Valid = false
-				result.ErrorMessage = ErrChainTampered.Error()
-				result.FailedAtSeq = event.SeqIndex
-				return result, nil
-			}
+		if errs[i] != nil {
+			result.Valid = false
+			index := result.TotalEvents - len(events) + i // position in the run when streaming
+			result.ErrorMessage = fmt.Sprintf("Event %d (seq %d) failed verification: %v", index, events[i].SeqIndex, errs[i])
+			result.FailedAtSeq = events[i].SeqIndex
+			return
 		}
+	}
+	if broken < len(events) {
+		result.Valid = false
+		result.ErrorMessage = ErrChainTampered.Error()
+		result.FailedAtSeq = events[broken].SeqIndex
+	}
+}
 
-		if err := VerifyEvent(&event, signer); err != nil {
-			result.Valid = false
-			result.ErrorMessage = fmt.Sprintf("Event %d (seq %d) failed verification: %v", i, event.SeqIndex, err)
-			result.FailedAtSeq = event.SeqIndex
-			return result, nil
+// linkGap is an acknowledged gap and the index of the event after it.
+type linkGap struct {
+	index int
+	gap   Gap
+}
+
+// checkLinks returns the index of the first event that does not link to the one before it
+// (len(events) if all do) and the acknowledged gaps crossed on the way.
+func checkLinks(events []models.Event, head *Checkpoint, acked map[Gap]bool) (int, []linkGap) {
+	var gaps []linkGap
+	for i := 0; i < len(events); i++ {
+		prev := head
+		if i > 0 {
+			prev = &Checkpoint{Seq: events[i-1].SeqIndex, Hash: events[i-1].CurrentHash}
+		}
+		// Genesis has nothing to link to
+		if prev == nil || events[i].PrevHash == prev.Hash {
+			continue
 		}
+		gap := Gap{From: prev.Seq + 1, To: events[i].SeqIndex - 1}
+		if events[i].SeqIndex <= gap.From || !acked[gap] {
+			return i, gaps
+		}
+		gaps = append(gaps, linkGap{index: i, gap: gap})
 	}
+	return len(events), gaps
+}
 
-	return result, nil
+// checkEvents verifies every event's hash and signature, spreading the work over up to
+// GOMAXPROCS goroutines. errs[i] is the failure of events[i]; within each goroutine's
+// share, events after the first failure are skipped, since only the earliest is reported.
+func checkEvents(events []models.Event, verify signatureCheck) []error {
+	errs := make([]error, len(events))
+	workers := min(runtime.GOMAXPROCS(0), maxVerifyWorkers)
+	if len(events) < minParallelEvents || workers < 2 {
+		workers = 1
+	}
+	share := (len(events) + workers - 1) / workers
+	var wg sync.WaitGroup
+	for start := 0; start < len(events); start += share {
+		end := min(start+share, len(events))
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := start; i < end; i++ {
+				if errs[i] = verifyEvent(&events[i], verify); errs[i] != nil {
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	return errs
 }
 
 // VerifyEvent validates a single event's hash and signature
 func VerifyEvent(event *models.Event, signer *crypto.Signer) error {
+	if err := assert.Check(signer != nil, "signer is nil"); err != nil {
+		return err
+	}
+	return verifyEvent(event, signer.VerifySignature)
+}
+
+// VerifyEventWithKey validates a single event's hash and signature against a hex-encoded
+// public key, for events checked outside their run's chain.
+func VerifyEventWithKey(event *models.Event, pubKeyHex string) error {
+	if err := assert.NotNil(event, "event"); err != nil {
+		return err
+	}
+	return verifyEvent(event, func(hash, signatureHex string) bool {
+		return crypto.VerifyWithPublicKey(pubKeyHex, hash, signatureHex)
+	})
+}
+
+func verifyEvent(event *models.Event, verify signatureCheck) error {
 	// Safety Assertion: Check signature before hash verification
 	if err := assert.Check(event.Signature != "", "event signature must not be empty: id=%s", event.ID); err != nil {
 		return err
@@ -94,24 +245,7 @@ func VerifyEvent(event *models.Event, signer *crypto.Signer) error {
 		return err
 	}
 	// 4. Calculate hash using normalized payload and JCS
-	tsStr := event.Timestamp.Format(time.RFC3339Nano)
-	// Recalculate the hash
-	payload := map[string]interface{}{
-		"id":         event.ID,
-		"run_id":     event.RunID,
-		"seq_index":  event.SeqIndex,
-		"timestamp":  tsStr, // Use the formatted string
-		"actor":      event.Actor,
-		"event_type": event.EventType,
-		"method":     event.Method,
-		"params":     event.Params,
-		"response":   event.Response,
-		"task_id":    event.TaskID,
-		"task_state": event.TaskState,
-		"parent_id":  event.ParentID,
-		"policy_id":  event.PolicyID,
-		"risk_level": event.RiskLevel,
-	}
+	payload := event.HashPayload()
 
 	calculatedHash, err := crypto.CalculateEventHash(event.PrevHash, payload)
 	if err != nil {
@@ -124,7 +258,7 @@ func VerifyEvent(event *models.Event, signer *crypto.Signer) error {
 	}
 
 	// Verify signature
-	isValid := signer.VerifySignature(calculatedHash, event.Signature)
+	isValid := verify(calculatedHash, event.Signature)
 	if !isValid {
 		return ErrInvalidSignature
 	}
@@ -141,30 +275,17 @@ type AnchorVerificationResult struct {
 
 // VerifyAnchors validates all anchor events in the ledger against the Bitcoin blockchain
 func VerifyAnchors(db EventReader, runID string) (*AnchorVerificationResult, error) {
-	events, err := db.GetAllEvents(runID)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get events for anchor verification: %w", err)
-	}
-
 	result := &AnchorVerificationResult{Valid: true}
-	const maxAnchorEvents = 100000
-	if err := assert.Check(len(events) <= maxAnchorEvents, "anchor events exceed max: %d", len(events)); err != nil {
-		return nil, err
-	}
-	for i := 0; i < maxAnchorEvents; i++ {
-		if i >= len(events) {
-			break
-		}
-		event := events[i]
+	err := db.ForEachEvent(runID, func(event *models.Event) error {
 		if event.EventType != "genesis" && event.EventType != "anchor" {
-			continue
+			return nil
 		}
 
 		anchorHash, okHash := event.Params["anchor_hash"].(string)
 		anchorHeight, okHeight := event.Params["anchor_height"].(float64) // JSON numbers are float64
 
 		if !okHash || !okHeight {
-			continue
+			return nil
 		}
 
 		result.AnchorsChecked++
@@ -174,14 +295,18 @@ func VerifyAnchors(db EventReader, runID string) (*AnchorVerificationResult, err
 		if err != nil {
 			result.Valid = false
 			result.ErrorMessage = fmt.Sprintf("failed to verify anchor at height %d: %v", uint64(anchorHeight), err)
-			return result, nil
+			return errStopWalk
 		}
 
 		if liveAnchor.BlockHash != anchorHash {
 			result.Valid = false
 			result.ErrorMessage = fmt.Sprintf("anchor mismatch at height %d: ledger=%s, live=%s", uint64(anchorHeight), anchorHash, liveAnchor.BlockHash)
-			return result, nil
+			return errStopWalk
 		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errStopWalk) {
+		return nil, fmt.Errorf("failed to get events for anchor verification: %w", err)
 	}
 
 	return result, nil
@@ -1,6 +1,8 @@
 package audit_test
 
 import (
+	"encoding/json"
+	"fmt"
 	"os"
 	"path/filepath"
 	"testing"
@@ -114,6 +116,19 @@ func TestVerifyChain(t *testing.T) {
 		t.Errorf("Expected 2 events verified, got %d", result.TotalEvents)
 	}
 
+	// The same chain checks out against the public key alone, and not against another key.
+	result, err = audit.VerifyChainWithKey(db, genesisID, signer.GetPublicKey())
+	if err != nil || !result.Valid {
+		t.Errorf("Chain should be valid under its public key: %v %+v", err, result)
+	}
+	other, err := crypto.NewSigner(filepath.Join(tmpDir, "other.key"))
+	if err != nil {
+		t.Fatalf("other signer: %v", err)
+	}
+	if result, _ = audit.VerifyChainWithKey(db, genesisID, other.GetPublicKey()); result.Valid {
+		t.Error("Chain should not verify under another instance's key")
+	}
+
 	// Test Tampering
 	// Helper to modify an event in database via SQL since db is store.DB which has unexported conn,
 	// BUT wait, db.conn is unexported. I should maybe export it or use a raw connection.
@@ -134,3 +149,142 @@ func TestVerifyChain(t *testing.T) {
 		t.Error("Chain should be invalid after signature tampering")
 	}
 }
+
+// An event read back from an export's JSON must verify exactly as the stored one does.
+func TestVerifyEventAfterJSONRoundTrip(t *testing.T) {
+	signer, err := crypto.NewSigner(filepath.Join(t.TempDir(), "roundtrip.key"))
+	if err != nil {
+		t.Fatalf("signer: %v", err)
+	}
+	event := models.Event{
+		ID: "event-rt", RunID: "run-rt", SeqIndex: 7, Timestamp: time.Now(),
+		Actor: "agent", EventType: "tool_call", Method: "tools/call",
+		Params:      map[string]interface{}{"name": "stripe:refund", "arguments": map[string]interface{}{"amount": 12.5, "ids": []interface{}{"a", "b"}}},
+		Response:    map[string]interface{}{},
+		Environment: "prod", Tags: []string{"schema_violation"}, Labels: map[string]string{"team": "payments"},
+		TraceID: "4bf92f3577b34da6a3ce929d0e0e4736", SpanID: "00f067aa0ba902b7",
+		PrevHash: "prev",
+	}
+	event.CurrentHash, err = crypto.CalculateEventHash(event.PrevHash, event.HashPayload())
+	if err != nil {
+		t.Fatalf("hash: %v", err)
+	}
+	if event.Signature, err = signer.SignHash(event.CurrentHash); err != nil {
+		t.Fatalf("sign: %v", err)
+	}
+
+	data, err := json.Marshal(event)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	var decoded models.Event
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if err := audit.VerifyEvent(&decoded, signer); err != nil {
+		t.Errorf("round-tripped event does not verify: %v", err)
+	}
+}
+
+// signedChain builds n linked, signed events in memory.
+func signedChain(tb testing.TB, signer *crypto.Signer, n int) []models.Event {
+	tb.Helper()
+	events := make([]models.Event, n)
+	prev := "0000000000000000000000000000000000000000000000000000000000000000"
+	for i := 0; i < n; i++ {
+		e := models.Event{
+			ID: fmt.Sprintf("e%d", i), RunID: "run-p", SeqIndex: uint64(i), Timestamp: time.Now(),
+			Actor: "agent", EventType: "tool_call", Method: "os.read",
+			Params: map[string]interface{}{"i": i}, Response: map[string]interface{}{}, PrevHash: prev,
+		}
+		sign(tb, signer, &e)
+		events[i], prev = e, e.CurrentHash
+	}
+	return events
+}
+
+// sign sets the event's hash and signature from its contents.
+func sign(tb testing.TB, signer *crypto.Signer, e *models.Event) {
+	tb.Helper()
+	hash, err := crypto.CalculateEventHash(e.PrevHash, e.HashPayload())
+	if err != nil {
+		tb.Fatalf("hash: %v", err)
+	}
+	e.CurrentHash = hash
+	e.Signature, _ = signer.SignHash(hash)
+}
+
+func TestStreamVerifierDefersGapsToALaterAcknowledgement(t *testing.T) {
+	signer, err := crypto.NewSigner(filepath.Join(t.TempDir(), "gaps.key"))
+	if err != nil {
+		t.Fatalf("signer: %v", err)
+	}
+	verify := func(events []models.Event, deferGaps bool) *audit.VerificationResult {
+		v := audit.NewStreamVerifier(signer.GetPublicKey(), nil)
+		if deferGaps {
+			v.DeferGaps()
+		}
+		for from := 0; from < len(events); from += 2 {
+			v.Verify(events[from:min(from+2, len(events))])
+		}
+		return v.Result()
+	}
+	// seq 3-4 are missing; the acknowledgement is the last event, two pages later.
+	events := signedChain(t, signer, 8)
+	events = append(events[:3], events[5:]...)
+	last := &events[len(events)-1]
+	last.EventType = audit.EventTypeGapAcknowledged
+	last.Params = map[string]interface{}{"missing_from": 3, "missing_to": 4}
+	sign(t, signer, last)
+
+	if r := verify(events, true); !r.Valid || len(r.AcknowledgedGaps) != 1 || r.AcknowledgedGaps[0] != (audit.Gap{From: 3, To: 4}) {
+		t.Errorf("a gap acknowledged later in the stream should verify: %+v", r)
+	}
+	if r := verify(events, false); r.Valid || r.FailedAtSeq != 5 {
+		t.Errorf("without DeferGaps the gap should fail at seq 5: %+v", r)
+	}
+	last.EventType, last.Params = "tool_call", map[string]interface{}{}
+	sign(t, signer, last)
+	if r := verify(events, true); r.Valid || r.FailedAtSeq != 5 || len(r.AcknowledgedGaps) != 0 {
+		t.Errorf("an unacknowledged gap should fail at seq 5: %+v", r)
+	}
+}
+
+func TestVerifyEventsReportsTheEarliestFailure(t *testing.T) {
+	signer, err := crypto.NewSigner(filepath.Join(t.TempDir(), "parallel.key"))
+	if err != nil {
+		t.Fatalf("signer: %v", err)
+	}
+	events := signedChain(t, signer, 1000)
+	if result := audit.VerifyEventsWithKey(events, nil, signer.GetPublicKey()); !result.Valid {
+		t.Fatalf("untouched chain should verify: %s", result.ErrorMessage)
+	}
+
+	events[700].Signature = events[699].Signature
+	events[300].Signature = events[299].Signature
+	if result := audit.VerifyEventsWithKey(events, nil, signer.GetPublicKey()); result.Valid || result.FailedAtSeq != 300 {
+		t.Errorf("want failure at seq 300, got %+v", result)
+	}
+
+	events = signedChain(t, signer, 1000)
+	events[600].Signature = events[599].Signature
+	events[500].PrevHash = events[498].CurrentHash
+	result := audit.VerifyEventsWithKey(events, nil, signer.GetPublicKey())
+	if result.Valid || result.FailedAtSeq != 500 || result.ErrorMessage != audit.ErrChainTampered.Error() {
+		t.Errorf("want the broken link at seq 500, got %+v", result)
+	}
+}
+
+func BenchmarkVerifyEventsWithKey(b *testing.B) {
+	signer, err := crypto.NewSigner(filepath.Join(b.TempDir(), "bench.key"))
+	if err != nil {
+		b.Fatalf("signer: %v", err)
+	}
+	events := signedChain(b, signer, 4096)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if result := audit.VerifyEventsWithKey(events, nil, signer.GetPublicKey()); !result.Valid {
+			b.Fatalf("chain should verify: %s", result.ErrorMessage)
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package ledger
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/slyt3/Logryph/internal/assert"
+	"github.com/slyt3/Logryph/internal/crypto"
+	"github.com/slyt3/Logryph/internal/ledger/audit"
+	"github.com/slyt3/Logryph/internal/models"
+)
+
+const maxReasonLen = 1024
+
+// AcknowledgeGaps appends a signed gap_acknowledged event for every unacknowledged
+// sequence gap in runID, so writes can continue after events were lost. The chain stays
+// broken at each gap; verification accepts the break only because the acknowledgement
+// records who accepted it and why. Returns the gaps acknowledged, none if the chain has
+// no new gaps. The proxy must not be writing to the ledger at the same time.
+func AcknowledgeGaps(db EventRepository, signer *crypto.Signer, runID, operator, reason string) ([]audit.Gap, error) {
+	if err := assert.NotNil(db, "database"); err != nil {
+		return nil, err
+	}
+	if err := assert.NotNil(signer, "signer"); err != nil {
+		return nil, err
+	}
+	if operator == "" || reason == "" {
+		return nil, errors.New("operator and reason are required")
+	}
+	if len(reason) > maxReasonLen {
+		return nil, fmt.Errorf("reason too long: %d bytes (max %d)", len(reason), maxReasonLen)
+	}
+	events, err := db.GetAllEvents(runID)
+	if err != nil {
+		return nil, fmt.Errorf("reading events: %w", err)
+	}
+	_, gaps := audit.FindGaps(events)
+	p := NewEventProcessor(db, signer, runID)
+	for i := 0; i < len(gaps); i++ {
+		if err := p.appendGapAcknowledgement(gaps[i], operator, reason); err != nil {
+			return gaps[:i], fmt.Errorf("acknowledging %s: %w", gaps[i], err)
+		}
+	}
+	return gaps, nil
+}
+
+// appendGapAcknowledgement stores the acknowledgement of gap after the last event. It
+// bypasses the event-count check in assignSequenceAndPrevHash, which is what fails while
+// the gap is unacknowledged.
+func (p *EventProcessor) appendGapAcknowledgement(gap audit.Gap, operator, reason string) error {
+	lastIndex, lastHash, err := p.db.GetLastEvent(p.runID)
+	if err != nil {
+		return fmt.Errorf("getting last event: %w", err)
+	}
+	event := &models.Event{
+		ID:        uuid.New().String()[:8],
+		RunID:     p.runID,
+		SeqIndex:  lastIndex + 1,
+		Timestamp: time.Now(),
+		Actor:     "user",
+		EventType: audit.EventTypeGapAcknowledged,
+		Method:    "logryph:chain_repair",
+		Params: map[string]interface{}{
+			"missing_from":  gap.From,
+			"missing_to":    gap.To,
+			"missing_count": gap.Count(),
+			"operator":      operator,
+			"reason":        reason,
+		},
+		Response: map[string]interface{}{},
+		PrevHash: lastHash,
+	}
+	if err := p.hashAndSignEvent(event); err != nil {
+		return err
+	}
+	return p.db.StoreEvent(event)
+}
@@ -5,13 +5,92 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/slyt3/Logryph/internal/assert"
 	"github.com/slyt3/Logryph/internal/crypto"
 	"github.com/slyt3/Logryph/internal/ledger/audit"
+	"github.com/slyt3/Logryph/internal/models"
 	"github.com/slyt3/Logryph/internal/pool"
 )
 
+const (
+	maxAgentNameLen = 128
+	maxOperatorLen  = 256
+)
+
+// GenesisConfig is the genesis section of the policy file: who the agent is and who runs
+// it. It is written into the signed genesis event of every new run, so an auditor holding
+// only the ledger can tell whose agent it belongs to.
+type GenesisConfig struct {
+	// AgentName names the agent on the run record. Defaults to "Logryph-Agent".
+	AgentName string `yaml:"agent_name,omitempty"`
+	// Operator is who is accountable for the agent, e.g. "payments-team@example.com".
+	Operator string `yaml:"operator,omitempty"`
+	// Labels are deployment labels (team, region, cluster) set on the genesis event.
+	Labels map[string]string `yaml:"labels,omitempty"`
+}
+
+// RunIdentity is who a run belongs to, as its genesis event records it.
+type RunIdentity struct {
+	AgentName   string            `json:"agent_name"`
+	Operator    string            `json:"operator,omitempty"`
+	Environment string            `json:"environment,omitempty"`
+	Labels      map[string]string `json:"labels,omitempty"`
+}
+
+// ValidateGenesisConfig checks the genesis section.
+func ValidateGenesisConfig(c GenesisConfig) error {
+	if len(c.AgentName) > maxAgentNameLen {
+		return fmt.Errorf("agent_name longer than %d bytes", maxAgentNameLen)
+	}
+	if len(c.Operator) > maxOperatorLen {
+		return fmt.Errorf("operator longer than %d bytes", maxOperatorLen)
+	}
+	if err := models.ValidateLabels(c.Labels); err != nil {
+		return fmt.Errorf("labels: %w", err)
+	}
+	return nil
+}
+
+// SetGenesis sets the agent name, operator and labels written into the genesis of new
+// runs. Runs already started keep theirs. Must be called before Start().
+func (w *Worker) SetGenesis(c GenesisConfig) error {
+	if err := assert.NotNil(w, "worker"); err != nil {
+		return err
+	}
+	if err := ValidateGenesisConfig(c); err != nil {
+		return err
+	}
+	w.genesis = c
+	return nil
+}
+
+// IdentityFromGenesis reads the agent name, operator, environment and labels recorded by
+// a run's genesis event.
+func IdentityFromGenesis(genesis *models.Event) RunIdentity {
+	var id RunIdentity
+	if genesis == nil || genesis.EventType != "genesis" {
+		return id
+	}
+	id.AgentName, _ = genesis.Params["agent_name"].(string)
+	id.Operator, _ = genesis.Params["operator"].(string)
+	id.Environment = genesis.Environment
+	id.Labels = genesis.Labels
+	return id
+}
+
 // CreateGenesisBlock creates the initial genesis event for a new run
 func CreateGenesisBlock(db EventRepository, signer *crypto.Signer, agentName string) (string, error) {
+	return createGenesisBlock(db, signer, GenesisConfig{AgentName: agentName}, "", nil)
+}
+
+// createGenesisBlock creates the genesis event stamped with the agent's metadata and the
+// deployment profile. prev, if set, is the run_closed event of the run this one replaces.
+func createGenesisBlock(db EventRepository, signer *crypto.Signer, meta GenesisConfig, environment string, prev *audit.Checkpoint) (string, error) {
+	agentName := meta.AgentName
+	if agentName == "" {
+		agentName = defaultAgentName
+	}
+
 	// Generate run ID (UUIDv7 for time-ordering)
 	runID := uuid.New().String()
 
@@ -28,6 +107,21 @@ func CreateGenesisBlock(db EventRepository, signer *crypto.Signer, agentName str
 	genesisEvent.Params["public_key"] = signer.GetPublicKey()
 	genesisEvent.Params["agent_name"] = agentName
 	genesisEvent.Params["version"] = "1.0.0"
+	if meta.Operator != "" {
+		genesisEvent.Params["operator"] = meta.Operator
+	}
+	if len(meta.Labels) > 0 {
+		genesisEvent.Labels = make(map[string]string, len(meta.Labels))
+		for k, v := range meta.Labels {
+			genesisEvent.Labels[k] = v
+		}
+	}
+	if prev != nil {
+		genesisEvent.Params[audit.ParamPrevRunID] = prev.RunID
+		genesisEvent.Params[audit.ParamPrevRunSeq] = prev.Seq
+		genesisEvent.Params[audit.ParamPrevRunHead] = prev.Hash
+	}
+	genesisEvent.Environment = environment
 	genesisEvent.PrevHash = "0000000000000000000000000000000000000000000000000000000000000000" // 64 zeros
 	genesisEvent.WasBlocked = false
 
@@ -43,22 +137,7 @@ func CreateGenesisBlock(db EventRepository, signer *crypto.Signer, agentName str
 	}
 
 	// Calculate genesis hash
-	payload := map[string]interface{}{
-		"id":         genesisEvent.ID,
-		"run_id":     genesisEvent.RunID,
-		"seq_index":  genesisEvent.SeqIndex,
-		"timestamp":  genesisEvent.Timestamp.Format(time.RFC3339Nano),
-		"actor":      genesisEvent.Actor,
-		"event_type": genesisEvent.EventType,
-		"method":     genesisEvent.Method,
-		"params":     genesisEvent.Params,
-		"response":   genesisEvent.Response,
-		"task_id":    genesisEvent.TaskID,
-		"task_state": genesisEvent.TaskState,
-		"parent_id":  genesisEvent.ParentID,
-		"policy_id":  genesisEvent.PolicyID,
-		"risk_level": genesisEvent.RiskLevel,
-	}
+	payload := genesisEvent.HashPayload()
 
 	currentHash, err := crypto.CalculateEventHash(genesisEvent.PrevHash, payload)
 	if err != nil {
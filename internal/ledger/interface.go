@@ -9,6 +9,9 @@ type RunStats struct {
 	CallCount     uint64         `json:"call_count"`
 	BlockedCount  uint64         `json:"blocked_count"`
 	RiskBreakdown map[string]int `json:"risk_breakdown"`
+	// AcknowledgedMissing is the number of sequence indexes covered by gap_acknowledged
+	// events, so TotalEvents+AcknowledgedMissing is the next sequence index.
+	AcknowledgedMissing uint64 `json:"acknowledged_missing,omitempty"`
 }
 
 type GlobalStats struct {
@@ -28,9 +31,15 @@ type EventRepository interface {
 	GetLastEvent(runID string) (uint64, string, error)
 	GetEventByID(eventID string) (*models.Event, error)
 	GetAllEvents(runID string) ([]models.Event, error)
+	// ForEachEvent calls fn with each event of a run in sequence order, one at a time and
+	// without GetAllEvents' row cap. It stops at the first error from fn and returns it.
+	ForEachEvent(runID string, fn func(*models.Event) error) error
 	GetRecentEvents(runID string, limit int) ([]models.Event, error)
 	GetEventsByTaskID(taskID string) ([]models.Event, error)
 	GetRiskEvents() ([]models.Event, error)
+	// GetRiskEventsPage returns one page of q, newest first, and the cursor for the next
+	// page ("" after the last).
+	GetRiskEventsPage(q models.RiskQuery) ([]models.Event, string, error)
 
 	// Meta
 	HasRuns() (bool, error)
@@ -44,3 +53,26 @@ type EventRepository interface {
 	// Lifecycle
 	Close() error
 }
+
+// HashIndex is implemented by repositories that index event and payload hashes, for
+// "has this ever been recorded" lookups without scanning the ledger.
+type HashIndex interface {
+	// FindByHash returns the event whose current_hash is hash, or nil if none is stored.
+	FindByHash(hash string) (*models.Event, error)
+	// FindByParamsHash returns up to limit events whose method and params hash to hash
+	// (see crypto.PayloadHash), oldest first.
+	FindByParamsHash(hash string, limit int) ([]models.Event, error)
+}
+
+// AnnotationReader is implemented by repositories that index annotation events.
+type AnnotationReader interface {
+	GetAnnotations(eventID string) ([]models.Annotation, error)
+}
+
+// LegalHoldReader is implemented by repositories that read legal holds back from their
+// events and resolve the cases they name.
+type LegalHoldReader interface {
+	GetLegalHolds() ([]models.LegalHold, error)
+	GetLegalHoldScope() (*models.HoldScope, error)
+	GetCase(nameOrID string) (*models.Case, error)
+}
@@ -0,0 +1,209 @@
+// Package memstore is a RAM-backed ledger.EventRepository for CI pipelines and tests that
+// should not touch disk or need CGO SQLite. Events are kept JSON-encoded, as the SQLite
+// store keeps them, so anything read back hashes and verifies exactly as it would there.
+// Contents are lost on exit unless a flush function is set.
+package memstore
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/slyt3/Logryph/internal/assert"
+	"github.com/slyt3/Logryph/internal/crypto"
+	"github.com/slyt3/Logryph/internal/ledger"
+	"github.com/slyt3/Logryph/internal/models"
+)
+
+// DefaultMaxEvents bounds memory use when New is given no limit.
+const DefaultMaxEvents = 1_000_000
+
+// ErrFull is returned by StoreEvent once the store holds its maximum number of events.
+var ErrFull = errors.New("in-memory ledger is full")
+
+type run struct {
+	id, agentName, genesisHash, pubKey string
+}
+
+// row is one stored event: the columns the queries filter on, and the event as JSON.
+type row struct {
+	id, runID, taskID, parentID, eventType, riskLevel, currentHash string
+	seq                                                            uint64
+	timestamp                                                      time.Time
+	data                                                           []byte
+}
+
+// Store holds runs and events in memory. It is safe for concurrent use.
+type Store struct {
+	mu          sync.RWMutex
+	runs        []run
+	rows        []row
+	byID        map[string]int
+	byHash      map[string]int
+	byParams    map[string][]int // params hash to row indexes, oldest first
+	annotations map[string][]models.Annotation
+	maxEvents   int
+	flush       func(*Store) error
+	closed      bool
+}
+
+// New returns an empty store holding at most maxEvents events (DefaultMaxEvents if <= 0).
+func New(maxEvents int) *Store {
+	if maxEvents <= 0 {
+		maxEvents = DefaultMaxEvents
+	}
+	return &Store{
+		byID:        make(map[string]int),
+		byHash:      make(map[string]int),
+		byParams:    make(map[string][]int),
+		annotations: make(map[string][]models.Annotation),
+		maxEvents:   maxEvents,
+	}
+}
+
+// SetFlush registers fn to run once on Close, e.g. to copy the ledger to SQLite.
+func (s *Store) SetFlush(fn func(*Store) error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.flush = fn
+}
+
+// Close runs the flush function, if any. Later calls do nothing.
+func (s *Store) Close() error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return nil
+	}
+	s.closed = true
+	flush := s.flush
+	s.mu.Unlock()
+	if flush == nil {
+		return nil
+	}
+	return flush(s)
+}
+
+// CopyTo writes every run and event to dst, in the order they were stored.
+func (s *Store) CopyTo(dst ledger.EventRepository) error {
+	if err := assert.NotNil(dst, "destination repository"); err != nil {
+		return err
+	}
+	s.mu.RLock()
+	runs := append([]run(nil), s.runs...)
+	rows := append([]row(nil), s.rows...)
+	s.mu.RUnlock()
+	for i := 0; i < len(runs); i++ {
+		r := runs[i]
+		if err := dst.InsertRun(r.id, r.agentName, r.genesisHash, r.pubKey); err != nil {
+			return fmt.Errorf("copying run %s: %w", r.id, err)
+		}
+	}
+	for i := 0; i < len(rows); i++ {
+		e, err := decode(rows[i])
+		if err != nil {
+			return err
+		}
+		if err := dst.StoreEvent(e); err != nil {
+			return fmt.Errorf("copying event %s: %w", e.ID, err)
+		}
+	}
+	return nil
+}
+
+// InsertRun creates a new run record.
+func (s *Store) InsertRun(id, agentName, genesisHash, ledgerPubKey string) error {
+	if err := assert.Check(id != "" && agentName != "" && genesisHash != "" && ledgerPubKey != "", "run fields must not be empty"); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := 0; i < len(s.runs); i++ {
+		if s.runs[i].id == id {
+			return fmt.Errorf("inserting run: %s already exists", id)
+		}
+	}
+	s.runs = append(s.runs, run{id: id, agentName: agentName, genesisHash: genesisHash, pubKey: ledgerPubKey})
+	return nil
+}
+
+// StoreEvent appends a hashed and signed event.
+func (s *Store) StoreEvent(event *models.Event) error {
+	if err := assert.NotNil(event, "event"); err != nil {
+		return err
+	}
+	if err := assert.Check(event.ID != "", "event id must not be empty"); err != nil {
+		return err
+	}
+	if err := assert.Check(event.CurrentHash != "" && event.Signature != "", "event must be hashed and signed: id=%s", event.ID); err != nil {
+		return err
+	}
+	data, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("encoding event: %w", err)
+	}
+	paramsHash, err := crypto.PayloadHash(event.Method, event.Params)
+	if err != nil {
+		return fmt.Errorf("hashing params: %w", err)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, dup := s.byID[event.ID]; dup {
+		return fmt.Errorf("inserting event: %s already exists", event.ID)
+	}
+	if len(s.rows) >= s.maxEvents {
+		return ErrFull
+	}
+	s.byID[event.ID] = len(s.rows)
+	s.byHash[event.CurrentHash] = len(s.rows)
+	s.byParams[paramsHash] = append(s.byParams[paramsHash], len(s.rows))
+	s.rows = append(s.rows, row{
+		id: event.ID, runID: event.RunID, taskID: event.TaskID, parentID: event.ParentID,
+		eventType: event.EventType, riskLevel: event.RiskLevel, currentHash: event.CurrentHash, seq: event.SeqIndex,
+		timestamp: event.Timestamp, data: data,
+	})
+	if a, ok := models.AnnotationFromEvent(event); ok {
+		s.annotations[a.EventID] = append(s.annotations[a.EventID], a)
+	}
+	return nil
+}
+
+func decode(r row) (*models.Event, error) {
+	var e models.Event
+	if err := json.Unmarshal(r.data, &e); err != nil {
+		return nil, fmt.Errorf("decoding event %s: %w", r.id, err)
+	}
+	e.WasBlocked = false // not persisted by the SQLite store either
+	return &e, nil
+}
+
+// query decodes the rows matching keep, sorted by less when it is set.
+func (s *Store) query(keep func(*row) bool, less func(a, b *row) bool) ([]models.Event, error) {
+	s.mu.RLock()
+	var matched []row
+	for i := 0; i < len(s.rows); i++ {
+		if keep(&s.rows[i]) {
+			matched = append(matched, s.rows[i])
+		}
+	}
+	s.mu.RUnlock()
+	if less != nil {
+		sort.SliceStable(matched, func(i, j int) bool { return less(&matched[i], &matched[j]) })
+	}
+	events := make([]models.Event, 0, len(matched))
+	for i := 0; i < len(matched); i++ {
+		e, err := decode(matched[i])
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, *e)
+	}
+	return events, nil
+}
+
+func bySeq(a, b *row) bool      { return a.seq < b.seq }
+func byTime(a, b *row) bool     { return a.timestamp.Before(b.timestamp) }
+func byTimeDesc(a, b *row) bool { return a.timestamp.After(b.timestamp) }
@@ -0,0 +1,96 @@
+package memstore
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/slyt3/Logryph/internal/crypto"
+	"github.com/slyt3/Logryph/internal/ledger"
+	"github.com/slyt3/Logryph/internal/ledger/audit"
+	"github.com/slyt3/Logryph/internal/models"
+)
+
+func TestStoreChainVerifiesAndQueries(t *testing.T) {
+	signer, err := crypto.NewSigner(filepath.Join(t.TempDir(), "test.key"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	mem := New(0)
+	if err := mem.InsertRun("run-1", "agent", "genesis-hash", signer.GetPublicKey()); err != nil {
+		t.Fatal(err)
+	}
+	var _ ledger.EventRepository = mem
+	var _ ledger.AnnotationReader = mem
+	processor := ledger.NewEventProcessor(mem, signer, "run-1")
+
+	events := []*models.Event{
+		{ID: "e0", EventType: "genesis", Method: "logryph:init", Params: map[string]interface{}{}},
+		{ID: "e1", EventType: "tool_call", Method: "stripe:charge", TaskID: "t1", RiskLevel: "critical",
+			Params: map[string]interface{}{"amount": 1200, "meta": map[string]interface{}{"tags": []string{"a"}}}},
+		{ID: "e2", EventType: models.EventTypeAnnotation, Method: "logryph:annotate", ParentID: "e1", TaskID: "t1",
+			Params: map[string]interface{}{"author": "alice", "note": "refund issued"}},
+	}
+	for _, e := range events {
+		e.Timestamp = time.Now()
+		if err := processor.ProcessEvent(e); err != nil {
+			t.Fatalf("ProcessEvent %s: %v", e.ID, err)
+		}
+	}
+	// The worker recycles events after storing them; the store must not share their maps.
+	events[1].Params["amount"] = 1
+
+	result, err := audit.VerifyChain(mem, "run-1", signer)
+	if err != nil || !result.Valid {
+		t.Fatalf("VerifyChain = %+v, %v", result, err)
+	}
+	if seq, hash, _ := mem.GetLastEvent("run-1"); seq != 2 || hash != events[2].CurrentHash {
+		t.Errorf("GetLastEvent = %d %s", seq, hash)
+	}
+	if recent, _ := mem.GetRecentEvents("run-1", 2); len(recent) != 2 || recent[0].ID != "e2" {
+		t.Errorf("GetRecentEvents = %+v", recent)
+	}
+	if task, _ := mem.GetEventsByTaskID("t1"); len(task) != 2 {
+		t.Errorf("GetEventsByTaskID returned %d events", len(task))
+	}
+	if risk, _ := mem.GetRiskEvents(); len(risk) != 1 || risk[0].Params["amount"] != float64(1200) {
+		t.Errorf("GetRiskEvents = %+v", risk)
+	}
+	if page, next, _ := mem.GetRiskEventsPage(models.RiskQuery{Levels: []string{"critical"}, Limit: 1}); len(page) != 1 || page[0].ID != "e1" || next != "" {
+		t.Errorf("GetRiskEventsPage = %+v, next %q", page, next)
+	}
+	if notes, _ := mem.GetAnnotations("e1"); len(notes) != 1 || notes[0].Note != "refund issued" {
+		t.Errorf("GetAnnotations = %+v", notes)
+	}
+	if stats, _ := mem.GetRunStats("run-1"); stats.TotalEvents != 3 || stats.CallCount != 1 || stats.RiskBreakdown["critical"] != 1 {
+		t.Errorf("GetRunStats = %+v", stats)
+	}
+	if _, err := mem.GetEventByID("missing"); err == nil {
+		t.Error("unknown event must return an error")
+	}
+
+	copied := New(0)
+	mem.SetFlush(func(s *Store) error { return s.CopyTo(copied) })
+	if err := mem.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if result, err := audit.VerifyChain(copied, "run-1", signer); err != nil || !result.Valid || result.TotalEvents != 3 {
+		t.Errorf("flushed copy VerifyChain = %+v, %v", result, err)
+	}
+}
+
+func TestStoreBounds(t *testing.T) {
+	mem := New(1)
+	e := &models.Event{ID: "a", RunID: "r", CurrentHash: "h", Signature: "s"}
+	if err := mem.StoreEvent(e); err != nil {
+		t.Fatal(err)
+	}
+	if err := mem.StoreEvent(e); err == nil {
+		t.Error("duplicate event ID must be rejected")
+	}
+	e2 := &models.Event{ID: "b", RunID: "r", CurrentHash: "h", Signature: "s"}
+	if err := mem.StoreEvent(e2); !errors.Is(err, ErrFull) {
+		t.Errorf("StoreEvent on a full store = %v, want ErrFull", err)
+	}
+}
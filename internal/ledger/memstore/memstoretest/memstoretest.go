@@ -0,0 +1,65 @@
+// Package memstoretest builds signed in-memory ledger runs for tests.
+package memstoretest
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/slyt3/Logryph/internal/crypto"
+	"github.com/slyt3/Logryph/internal/ledger"
+	"github.com/slyt3/Logryph/internal/ledger/memstore"
+	"github.com/slyt3/Logryph/internal/models"
+)
+
+// RunID is the ID of every run NewRun creates.
+const RunID = "run-1"
+
+// Run is a run in a memstore, signed with its own key, and the processor that appends
+// to its chain.
+type Run struct {
+	Store     *memstore.Store
+	Signer    *crypto.Signer
+	Processor *ledger.EventProcessor
+	t         testing.TB
+}
+
+// NewRun creates an empty run signed with a new key.
+func NewRun(t testing.TB) *Run {
+	t.Helper()
+	signer, err := crypto.NewSigner(filepath.Join(t.TempDir(), "test.key"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	mem := memstore.New(0)
+	if err := mem.InsertRun(RunID, "agent", "genesis-hash", signer.GetPublicKey()); err != nil {
+		t.Fatal(err)
+	}
+	return &Run{Store: mem, Signer: signer, Processor: ledger.NewEventProcessor(mem, signer, RunID), t: t}
+}
+
+// Record appends e to the chain, stamped with the current time if it has none.
+func (r *Run) Record(e *models.Event) {
+	r.t.Helper()
+	if e.Timestamp.IsZero() {
+		e.Timestamp = time.Now()
+	}
+	if err := r.Processor.ProcessEvent(e); err != nil {
+		r.t.Fatalf("ProcessEvent %s: %v", e.ID, err)
+	}
+}
+
+// RecordCalls records n os.read calls e0, e1, ... with params {"i": n}, assigned
+// round-robin to tasks when any are given.
+func (r *Run) RecordCalls(n int, tasks ...string) {
+	r.t.Helper()
+	for i := 0; i < n; i++ {
+		e := &models.Event{ID: fmt.Sprintf("e%d", i), EventType: "tool_call", Method: "os.read",
+			Params: map[string]interface{}{"i": i}}
+		if len(tasks) > 0 {
+			e.TaskID = tasks[i%len(tasks)]
+		}
+		r.Record(e)
+	}
+}
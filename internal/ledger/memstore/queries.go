@@ -0,0 +1,322 @@
+package memstore
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+
+	"github.com/slyt3/Logryph/internal/assert"
+	"github.com/slyt3/Logryph/internal/ledger"
+	"github.com/slyt3/Logryph/internal/ledger/audit"
+	"github.com/slyt3/Logryph/internal/models"
+)
+
+// HasRuns reports whether any run exists.
+func (s *Store) HasRuns() (bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.runs) > 0, nil
+}
+
+// GetRunID returns the most recent run ID, or "" when there are none.
+func (s *Store) GetRunID() (string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if len(s.runs) == 0 {
+		return "", nil
+	}
+	return s.runs[len(s.runs)-1].id, nil
+}
+
+// GetRunInfo returns run metadata. An unknown run wraps sql.ErrNoRows, as in the SQLite store.
+func (s *Store) GetRunInfo(runID string) (agentName, genesisHash, pubKey string, err error) {
+	if err := assert.Check(runID != "", "runID must not be empty"); err != nil {
+		return "", "", "", err
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for i := 0; i < len(s.runs); i++ {
+		if r := s.runs[i]; r.id == runID {
+			return r.agentName, r.genesisHash, r.pubKey, nil
+		}
+	}
+	return "", "", "", fmt.Errorf("querying run info: %w", sql.ErrNoRows)
+}
+
+// GetLastEvent returns the seq and hash of a run's latest event, or zero values if it has none.
+func (s *Store) GetLastEvent(runID string) (uint64, string, error) {
+	if err := assert.Check(runID != "", "runID must not be empty"); err != nil {
+		return 0, "", err
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	found := false
+	var seq uint64
+	var hash string
+	for i := 0; i < len(s.rows); i++ {
+		r := &s.rows[i]
+		if r.runID == runID && (!found || r.seq > seq) {
+			found, seq, hash = true, r.seq, r.currentHash
+		}
+	}
+	return seq, hash, nil
+}
+
+// GetEventByID returns a single event. An unknown ID wraps sql.ErrNoRows.
+func (s *Store) GetEventByID(eventID string) (*models.Event, error) {
+	if err := assert.Check(eventID != "", "eventID must not be empty"); err != nil {
+		return nil, err
+	}
+	s.mu.RLock()
+	idx, ok := s.byID[eventID]
+	var r row
+	if ok {
+		r = s.rows[idx]
+	}
+	s.mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("querying event: %w", sql.ErrNoRows)
+	}
+	return decode(r)
+}
+
+// FindByHash returns the event whose current hash is hash, or nil if none is stored.
+func (s *Store) FindByHash(hash string) (*models.Event, error) {
+	if err := assert.Check(hash != "", "hash must not be empty"); err != nil {
+		return nil, err
+	}
+	s.mu.RLock()
+	idx, ok := s.byHash[hash]
+	var r row
+	if ok {
+		r = s.rows[idx]
+	}
+	s.mu.RUnlock()
+	if !ok {
+		return nil, nil
+	}
+	return decode(r)
+}
+
+// FindByParamsHash returns up to limit events whose method and params hash to hash,
+// oldest first.
+func (s *Store) FindByParamsHash(hash string, limit int) ([]models.Event, error) {
+	if err := assert.Check(hash != "", "hash must not be empty"); err != nil {
+		return nil, err
+	}
+	if err := assert.Check(limit > 0, "limit must be positive"); err != nil {
+		return nil, err
+	}
+	s.mu.RLock()
+	idxs := s.byParams[hash]
+	matched := make([]row, 0, min(len(idxs), limit))
+	for i := 0; i < len(idxs) && i < limit; i++ {
+		matched = append(matched, s.rows[idxs[i]])
+	}
+	s.mu.RUnlock()
+	events := make([]models.Event, 0, len(matched))
+	for i := 0; i < len(matched); i++ {
+		e, err := decode(matched[i])
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, *e)
+	}
+	return events, nil
+}
+
+// GetAllEvents returns a run's events in seq order.
+func (s *Store) GetAllEvents(runID string) ([]models.Event, error) {
+	if err := assert.Check(runID != "", "runID must not be empty"); err != nil {
+		return nil, err
+	}
+	return s.query(func(r *row) bool { return r.runID == runID }, bySeq)
+}
+
+// ForEachEvent calls fn with each of a run's events in seq order, decoding one at a time.
+// It stops at the first error from fn and returns it.
+func (s *Store) ForEachEvent(runID string, fn func(*models.Event) error) error {
+	if err := assert.Check(runID != "", "runID must not be empty"); err != nil {
+		return err
+	}
+	if err := assert.NotNil(fn, "event callback"); err != nil {
+		return err
+	}
+	s.mu.RLock()
+	var matched []row
+	for i := 0; i < len(s.rows); i++ {
+		if s.rows[i].runID == runID {
+			matched = append(matched, s.rows[i])
+		}
+	}
+	s.mu.RUnlock()
+	sort.SliceStable(matched, func(i, j int) bool { return bySeq(&matched[i], &matched[j]) })
+	for i := 0; i < len(matched); i++ {
+		e, err := decode(matched[i])
+		if err != nil {
+			return err
+		}
+		if err := fn(e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetRecentEvents returns a run's latest limit events, newest first.
+func (s *Store) GetRecentEvents(runID string, limit int) ([]models.Event, error) {
+	if err := assert.Check(runID != "", "runID must not be empty"); err != nil {
+		return nil, err
+	}
+	if err := assert.Check(limit > 0, "limit must be positive"); err != nil {
+		return nil, err
+	}
+	events, err := s.query(func(r *row) bool { return r.runID == runID }, func(a, b *row) bool { return a.seq > b.seq })
+	if err != nil || len(events) <= limit {
+		return events, err
+	}
+	return events[:limit], nil
+}
+
+// GetEventsFrom returns up to limit events of a run starting at seq fromSeq, in order.
+func (s *Store) GetEventsFrom(runID string, fromSeq uint64, limit int) ([]models.Event, error) {
+	if err := assert.Check(runID != "", "runID must not be empty"); err != nil {
+		return nil, err
+	}
+	if err := assert.Check(limit > 0, "limit must be positive"); err != nil {
+		return nil, err
+	}
+	events, err := s.query(func(r *row) bool { return r.runID == runID && r.seq >= fromSeq }, bySeq)
+	if err != nil || len(events) <= limit {
+		return events, err
+	}
+	return events[:limit], nil
+}
+
+// GetEventsByTaskID returns a task's events in seq order.
+func (s *Store) GetEventsByTaskID(taskID string) ([]models.Event, error) {
+	if err := assert.Check(taskID != "", "taskID must not be empty"); err != nil {
+		return nil, err
+	}
+	return s.query(func(r *row) bool { return r.taskID == taskID }, bySeq)
+}
+
+// GetRiskEvents returns high and critical events, newest first.
+func (s *Store) GetRiskEvents() ([]models.Event, error) {
+	return s.query(func(r *row) bool { return r.riskLevel == "high" || r.riskLevel == "critical" }, byTimeDesc)
+}
+
+// GetRiskEventsPage returns one page of risk events, newest first, with the cursor for
+// the next, keyed on insertion order like the SQLite store.
+func (s *Store) GetRiskEventsPage(q models.RiskQuery) ([]models.Event, string, error) {
+	if err := assert.Check(len(q.Levels) > 0 && q.Limit > 0, "risk query needs levels and a limit"); err != nil {
+		return nil, "", err
+	}
+	levels := make(map[string]bool, len(q.Levels))
+	for i := 0; i < len(q.Levels); i++ {
+		levels[q.Levels[i]] = true
+	}
+	s.mu.RLock()
+	from := len(s.rows) - 1
+	if q.After != "" {
+		from = -1
+		if i, ok := s.byID[q.After]; ok {
+			from = i - 1
+		}
+	}
+	var matched []row
+	for i := from; i >= 0 && len(matched) <= q.Limit; i-- {
+		r := s.rows[i]
+		if !q.Since.IsZero() && r.timestamp.Before(q.Since) {
+			break
+		}
+		if levels[r.riskLevel] {
+			matched = append(matched, r)
+		}
+	}
+	s.mu.RUnlock()
+	next := ""
+	if len(matched) > q.Limit {
+		matched, next = matched[:q.Limit], matched[q.Limit-1].id
+	}
+	events := make([]models.Event, 0, len(matched))
+	for i := 0; i < len(matched); i++ {
+		e, err := decode(matched[i])
+		if err != nil {
+			return nil, "", err
+		}
+		events = append(events, *e)
+	}
+	return events, next, nil
+}
+
+// GetEventsByParentID returns the events linked to a parent event, oldest first.
+func (s *Store) GetEventsByParentID(parentID string) ([]models.Event, error) {
+	if err := assert.Check(parentID != "", "parentID must not be empty"); err != nil {
+		return nil, err
+	}
+	return s.query(func(r *row) bool { return r.parentID == parentID }, byTime)
+}
+
+// GetEventsByType returns all events of a type across runs, oldest first.
+func (s *Store) GetEventsByType(eventType string) ([]models.Event, error) {
+	if err := assert.Check(eventType != "", "eventType must not be empty"); err != nil {
+		return nil, err
+	}
+	return s.query(func(r *row) bool { return r.eventType == eventType }, byTime)
+}
+
+// GetAnnotations returns the annotations on an event, oldest first.
+func (s *Store) GetAnnotations(eventID string) ([]models.Annotation, error) {
+	if err := assert.Check(eventID != "", "eventID must not be empty"); err != nil {
+		return nil, err
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return append([]models.Annotation(nil), s.annotations[eventID]...), nil
+}
+
+// GetRunStats returns event, call, block and risk counts for a run.
+func (s *Store) GetRunStats(runID string) (*ledger.RunStats, error) {
+	if err := assert.Check(runID != "", "runID must not be empty"); err != nil {
+		return nil, err
+	}
+	stats := &ledger.RunStats{RunID: runID, RiskBreakdown: make(map[string]int)}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for i := 0; i < len(s.rows); i++ {
+		r := &s.rows[i]
+		if r.runID != runID {
+			continue
+		}
+		stats.TotalEvents++
+		switch r.eventType {
+		case "blocked":
+			stats.BlockedCount++
+		case "tool_call":
+			stats.CallCount++
+		case audit.EventTypeGapAcknowledged:
+			if e, err := decode(*r); err == nil {
+				n, _ := e.Params["missing_count"].(float64)
+				stats.AcknowledgedMissing += uint64(n)
+			}
+		}
+		if r.riskLevel != "" {
+			stats.RiskBreakdown[r.riskLevel]++
+		}
+	}
+	return stats, nil
+}
+
+// GetGlobalStats returns totals across all runs.
+func (s *Store) GetGlobalStats() (*ledger.GlobalStats, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	stats := &ledger.GlobalStats{TotalRuns: len(s.runs), TotalEvents: uint64(len(s.rows))}
+	for i := 0; i < len(s.rows); i++ {
+		if s.rows[i].riskLevel == "critical" {
+			stats.CriticalCount++
+		}
+	}
+	return stats, nil
+}
@@ -13,10 +13,11 @@ import (
 
 // EventProcessor handles the logic for hashing, signing, and state tracking
 type EventProcessor struct {
-	db         EventRepository
-	signer     *crypto.Signer
-	runID      string
-	taskStates map[string]string
+	db          EventRepository
+	signer      *crypto.Signer
+	runID       string
+	environment string // default profile for events without one
+	taskStates  map[string]string
 }
 
 func NewEventProcessor(db EventRepository, signer *crypto.Signer, runID string) *EventProcessor {
@@ -74,6 +75,10 @@ func (p *EventProcessor) persistEvent(event *models.Event) error {
 		return err
 	}
 
+	if event.Environment == "" {
+		event.Environment = p.environment
+	}
+
 	// 1. Assign sequence index and validate chain
 	if err := p.assignSequenceAndPrevHash(event); err != nil {
 		return err
@@ -98,7 +103,7 @@ func (p *EventProcessor) assignSequenceAndPrevHash(event *models.Event) error {
 	if err := assert.Check(err == nil, "failed to get run stats: %v", err); err != nil {
 		return fmt.Errorf("getting run stats: %w", err)
 	}
-	event.SeqIndex = stats.TotalEvents
+	event.SeqIndex = stats.TotalEvents + stats.AcknowledgedMissing
 	event.RunID = p.runID
 
 	lastIndex, lastHash, err := p.db.GetLastEvent(p.runID)
@@ -133,22 +138,7 @@ func (p *EventProcessor) hashAndSignEvent(event *models.Event) error {
 		return err
 	}
 
-	payload := map[string]interface{}{
-		"id":         event.ID,
-		"run_id":     event.RunID,
-		"seq_index":  event.SeqIndex,
-		"timestamp":  event.Timestamp.Format(time.RFC3339Nano),
-		"actor":      event.Actor,
-		"event_type": event.EventType,
-		"method":     event.Method,
-		"params":     event.Params,
-		"response":   event.Response,
-		"task_id":    event.TaskID,
-		"task_state": event.TaskState,
-		"parent_id":  event.ParentID,
-		"policy_id":  event.PolicyID,
-		"risk_level": event.RiskLevel,
-	}
+	payload := event.HashPayload()
 
 	currentHash, err := crypto.CalculateEventHash(event.PrevHash, payload)
 	if err != nil {
@@ -51,6 +51,16 @@ func (m *mockEventRepository) GetAllEvents(runID string) ([]models.Event, error)
 	return result, nil
 }
 
+func (m *mockEventRepository) ForEachEvent(runID string, fn func(*models.Event) error) error {
+	for _, e := range m.events {
+		event := *e
+		if err := fn(&event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (m *mockEventRepository) GetRecentEvents(runID string, limit int) ([]models.Event, error) {
 	return nil, nil
 }
@@ -63,6 +73,10 @@ func (m *mockEventRepository) GetRiskEvents() ([]models.Event, error) {
 	return nil, nil
 }
 
+func (m *mockEventRepository) GetRiskEventsPage(q models.RiskQuery) ([]models.Event, string, error) {
+	return nil, "", nil
+}
+
 func (m *mockEventRepository) HasRuns() (bool, error) {
 	return len(m.events) > 0, nil
 }
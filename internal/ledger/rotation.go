@@ -0,0 +1,126 @@
+package ledger
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/slyt3/Logryph/internal/assert"
+	"github.com/slyt3/Logryph/internal/ledger/audit"
+	"github.com/slyt3/Logryph/internal/logging"
+	"github.com/slyt3/Logryph/internal/pool"
+)
+
+const (
+	minRotationInterval = time.Minute
+	minRotationEvents   = 10
+)
+
+// RotationConfig is the rotation section of the policy file. When either limit is
+// reached the worker closes the current run with a run_closed event and starts a new run
+// whose genesis references it, so logyctl verify can walk from the newest run back.
+type RotationConfig struct {
+	// Interval ends runs at multiples of the interval since the Unix epoch, so "24h"
+	// rotates at midnight UTC. The run is closed when the first event after the
+	// boundary arrives.
+	Interval string `yaml:"interval,omitempty"`
+	// MaxEvents closes a run once it holds this many events.
+	MaxEvents uint64 `yaml:"max_events,omitempty"`
+}
+
+// ValidateRotationConfig checks the rotation section.
+func ValidateRotationConfig(c RotationConfig) error {
+	if c.Interval != "" {
+		d, err := time.ParseDuration(c.Interval)
+		if err != nil {
+			return fmt.Errorf("invalid interval %q: %w", c.Interval, err)
+		}
+		if d < minRotationInterval {
+			return fmt.Errorf("interval %s is shorter than %s", d, minRotationInterval)
+		}
+	}
+	if c.MaxEvents != 0 && c.MaxEvents < minRotationEvents {
+		return fmt.Errorf("max_events %d is below %d", c.MaxEvents, minRotationEvents)
+	}
+	return nil
+}
+
+// SetRotation enables automatic run rotation. Must be called before Start().
+func (w *Worker) SetRotation(c RotationConfig) error {
+	if err := assert.NotNil(w, "worker"); err != nil {
+		return err
+	}
+	if err := ValidateRotationConfig(c); err != nil {
+		return err
+	}
+	w.rotateEvery = 0
+	if c.Interval != "" {
+		w.rotateEvery, _ = time.ParseDuration(c.Interval)
+	}
+	w.rotateAfter = c.MaxEvents
+	return nil
+}
+
+// rotationWindow numbers the interval t falls in.
+func (w *Worker) rotationWindow(t time.Time) int64 {
+	if w.rotateEvery <= 0 {
+		return 0
+	}
+	return t.UnixNano() / int64(w.rotateEvery)
+}
+
+// loadRotationState reads where the current run stands against the rotation limits.
+func (w *Worker) loadRotationState() {
+	w.runWindow = w.rotationWindow(time.Now())
+	recent, err := w.db.GetRecentEvents(w.runID, 1)
+	if err == nil && len(recent) > 0 {
+		w.runWindow = w.rotationWindow(recent[0].Timestamp)
+	}
+}
+
+// rotationDue returns why the current run should be closed before the next event, or "".
+func (w *Worker) rotationDue(now time.Time) string {
+	if w.rotateAfter > 0 && w.lastSeq.Load()+1 >= w.rotateAfter {
+		return "max_events"
+	}
+	if w.rotateEvery > 0 && w.rotationWindow(now) != w.runWindow {
+		return "interval"
+	}
+	return ""
+}
+
+// rotate closes the current run and starts the next one. It runs on the processing
+// goroutine, so no event is appended between the close and the new genesis.
+func (w *Worker) rotate(reason string) error {
+	lastSeq, lastHash, err := w.db.GetLastEvent(w.runID)
+	if err != nil {
+		return fmt.Errorf("getting last event: %w", err)
+	}
+	closing := pool.GetEvent()
+	defer pool.PutEvent(closing)
+	closing.ID = uuid.New().String()[:8]
+	closing.Timestamp = time.Now()
+	closing.EventType = audit.EventTypeRunClosed
+	closing.Method = "logryph:rotate"
+	closing.Actor = "system"
+	closing.Params["reason"] = reason
+	closing.Params["head_seq"] = lastSeq
+	closing.Params["head_hash"] = lastHash
+	if err := w.processor.persistEvent(closing); err != nil {
+		return fmt.Errorf("closing run: %w", err)
+	}
+
+	prev := &audit.Checkpoint{RunID: w.runID, Seq: closing.SeqIndex, Hash: closing.CurrentHash}
+	runID, err := createGenesisBlock(w.db, w.signer, w.genesis, w.environment, prev)
+	if err != nil {
+		return fmt.Errorf("starting next run: %w", err)
+	}
+	next := NewEventProcessor(w.db, w.signer, runID)
+	next.environment = w.environment
+	next.taskStates = w.processor.taskStates // open tasks continue in the new run
+	w.processor, w.runID = next, runID
+	w.lastSeq.Store(0)
+	w.runWindow = w.rotationWindow(closing.Timestamp)
+	logging.Info("run_rotated", logging.Fields{Component: "worker", RunID: runID, Method: reason})
+	return nil
+}
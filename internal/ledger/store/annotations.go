@@ -0,0 +1,61 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/slyt3/Logryph/internal/assert"
+	"github.com/slyt3/Logryph/internal/models"
+)
+
+const maxAnnotationRows = 10000
+
+// insertAnnotation indexes an annotation event in the same transaction that stores it.
+func insertAnnotation(tx *sql.Tx, a models.Annotation) error {
+	if err := assert.Check(a.ID != "" && a.EventID != "", "annotation must reference an event: id=%s", a.ID); err != nil {
+		return err
+	}
+	_, err := tx.Exec(`INSERT INTO annotations (id, event_id, author, note, created_at) VALUES (?, ?, ?, ?, ?)`,
+		a.ID, a.EventID, a.Author, a.Note, a.CreatedAt.Format(time.RFC3339Nano))
+	if err != nil {
+		return fmt.Errorf("inserting annotation: %w", err)
+	}
+	return nil
+}
+
+// GetAnnotations returns the annotations on an event, oldest first.
+func (db *DB) GetAnnotations(eventID string) (annotations []models.Annotation, err error) {
+	if err := assert.Check(eventID != "", "eventID must not be empty"); err != nil {
+		return nil, err
+	}
+	rows, err := db.conn.Query(`SELECT id, event_id, author, note, created_at FROM annotations
+		WHERE event_id = ? ORDER BY created_at ASC`, eventID)
+	if err != nil {
+		return nil, fmt.Errorf("querying annotations: %w", err)
+	}
+	defer func() {
+		if closeErr := rows.Close(); closeErr != nil && err == nil {
+			err = fmt.Errorf("closing annotation rows: %w", closeErr)
+		}
+	}()
+
+	for i := 0; i < maxAnnotationRows; i++ {
+		if !rows.Next() {
+			break
+		}
+		var a models.Annotation
+		var createdAt string
+		if err := rows.Scan(&a.ID, &a.EventID, &a.Author, &a.Note, &createdAt); err != nil {
+			return nil, fmt.Errorf("scanning annotation: %w", err)
+		}
+		if t, err := time.Parse(time.RFC3339Nano, createdAt); err == nil {
+			a.CreatedAt = t
+		}
+		annotations = append(annotations, a)
+	}
+	if err := assert.Check(rows.Err() == nil, "annotation rows error: %v", rows.Err()); err != nil {
+		return nil, err
+	}
+	return annotations, nil
+}
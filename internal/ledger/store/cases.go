@@ -0,0 +1,141 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/slyt3/Logryph/internal/assert"
+	"github.com/slyt3/Logryph/internal/models"
+)
+
+const (
+	maxCaseRows     = 10000
+	maxCaseNameLen  = 128
+	maxCaseItemRows = 10000
+)
+
+// ErrCaseNotFound is returned when no case matches the given name or ID.
+var ErrCaseNotFound = errors.New("case not found")
+
+// CreateCase stores a new case. Names are unique.
+func (db *DB) CreateCase(c models.Case) error {
+	if err := assert.Check(c.ID != "", "case id must not be empty"); err != nil {
+		return err
+	}
+	if err := assert.Check(c.Name != "" && len(c.Name) <= maxCaseNameLen, "case name must be 1-%d bytes", maxCaseNameLen); err != nil {
+		return err
+	}
+	_, err := db.conn.Exec(`INSERT INTO cases (id, name, created_at) VALUES (?, ?, ?)`,
+		c.ID, c.Name, c.CreatedAt.Format(time.RFC3339Nano))
+	if err != nil {
+		return fmt.Errorf("inserting case: %w", err)
+	}
+	return nil
+}
+
+// GetCase looks a case up by name, then by ID.
+func (db *DB) GetCase(nameOrID string) (*models.Case, error) {
+	if err := assert.Check(nameOrID != "", "case name must not be empty"); err != nil {
+		return nil, err
+	}
+	var c models.Case
+	var createdAt string
+	err := db.conn.QueryRow(`SELECT id, name, created_at FROM cases WHERE name = ? OR id = ?
+		ORDER BY name = ? DESC LIMIT 1`, nameOrID, nameOrID, nameOrID).Scan(&c.ID, &c.Name, &createdAt)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("%w: %s", ErrCaseNotFound, nameOrID)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("querying case: %w", err)
+	}
+	if t, err := time.Parse(time.RFC3339Nano, createdAt); err == nil {
+		c.CreatedAt = t
+	}
+	return &c, nil
+}
+
+// ListCases returns all cases, oldest first.
+func (db *DB) ListCases() (cases []models.Case, err error) {
+	rows, err := db.conn.Query(`SELECT id, name, created_at FROM cases ORDER BY rowid ASC`)
+	if err != nil {
+		return nil, fmt.Errorf("querying cases: %w", err)
+	}
+	defer func() {
+		if closeErr := rows.Close(); closeErr != nil && err == nil {
+			err = fmt.Errorf("closing case rows: %w", closeErr)
+		}
+	}()
+
+	for i := 0; i < maxCaseRows; i++ {
+		if !rows.Next() {
+			break
+		}
+		var c models.Case
+		var createdAt string
+		if err := rows.Scan(&c.ID, &c.Name, &createdAt); err != nil {
+			return nil, fmt.Errorf("scanning case: %w", err)
+		}
+		if t, err := time.Parse(time.RFC3339Nano, createdAt); err == nil {
+			c.CreatedAt = t
+		}
+		cases = append(cases, c)
+	}
+	if err := assert.Check(rows.Err() == nil, "case rows error: %v", rows.Err()); err != nil {
+		return nil, err
+	}
+	return cases, nil
+}
+
+// AddCaseItem attaches an event or task to a case. Adding the same item twice is a no-op.
+func (db *DB) AddCaseItem(item models.CaseItem) error {
+	if err := assert.Check(item.CaseID != "" && item.Ref != "", "case item must have a case and a ref"); err != nil {
+		return err
+	}
+	if err := assert.Check(item.Kind == models.CaseItemEvent || item.Kind == models.CaseItemTask, "invalid case item kind: %s", item.Kind); err != nil {
+		return err
+	}
+	_, err := db.conn.Exec(`INSERT OR IGNORE INTO case_items (case_id, kind, ref, added_at) VALUES (?, ?, ?, ?)`,
+		item.CaseID, item.Kind, item.Ref, item.AddedAt.Format(time.RFC3339Nano))
+	if err != nil {
+		return fmt.Errorf("inserting case item: %w", err)
+	}
+	return nil
+}
+
+// GetCaseItems returns the items attached to a case in the order they were added.
+func (db *DB) GetCaseItems(caseID string) (items []models.CaseItem, err error) {
+	if err := assert.Check(caseID != "", "case id must not be empty"); err != nil {
+		return nil, err
+	}
+	rows, err := db.conn.Query(`SELECT case_id, kind, ref, added_at FROM case_items
+		WHERE case_id = ? ORDER BY rowid ASC`, caseID)
+	if err != nil {
+		return nil, fmt.Errorf("querying case items: %w", err)
+	}
+	defer func() {
+		if closeErr := rows.Close(); closeErr != nil && err == nil {
+			err = fmt.Errorf("closing case item rows: %w", closeErr)
+		}
+	}()
+
+	for i := 0; i < maxCaseItemRows; i++ {
+		if !rows.Next() {
+			break
+		}
+		var item models.CaseItem
+		var addedAt string
+		if err := rows.Scan(&item.CaseID, &item.Kind, &item.Ref, &addedAt); err != nil {
+			return nil, fmt.Errorf("scanning case item: %w", err)
+		}
+		if t, err := time.Parse(time.RFC3339Nano, addedAt); err == nil {
+			item.AddedAt = t
+		}
+		items = append(items, item)
+	}
+	if err := assert.Check(rows.Err() == nil, "case item rows error: %v", rows.Err()); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
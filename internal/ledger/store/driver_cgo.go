@@ -0,0 +1,15 @@
+//go:build cgo && !sqlite_purego
+
+package store
+
+import _ "github.com/mattn/go-sqlite3"
+
+// DriverName is the database/sql driver the ledger is opened with: mattn/go-sqlite3
+// when CGO is available.
+const DriverName = "sqlite3"
+
+// dsn returns the connection string for dbPath. mattn/go-sqlite3 already waits up to
+// 5s on a locked database.
+func dsn(dbPath string) string {
+	return dbPath
+}
@@ -0,0 +1,19 @@
+//go:build !cgo || sqlite_purego
+
+package store
+
+import (
+	"net/url"
+
+	_ "modernc.org/sqlite"
+)
+
+// DriverName is the database/sql driver the ledger is opened with: the pure-Go
+// modernc.org/sqlite, used when CGO is disabled or the sqlite_purego tag is set.
+const DriverName = "sqlite"
+
+// dsn returns the connection string for dbPath, with the same 5s busy timeout that
+// mattn/go-sqlite3 sets by default.
+func dsn(dbPath string) string {
+	return dbPath + "?_pragma=" + url.QueryEscape("busy_timeout(5000)")
+}
@@ -5,13 +5,18 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
+	"strings"
 	"time"
 
 	"github.com/slyt3/Logryph/internal/assert"
+	"github.com/slyt3/Logryph/internal/crypto"
 	"github.com/slyt3/Logryph/internal/models"
 )
 
-const maxEventRows = 100000
+const (
+	maxEventRows    = 100000
+	maxStreamEvents = 1 << 40 // ForEachEvent loop bound, not a practical limit
+)
 
 // StoreEvent persists a models.Event to the ledger, unpacking it for the SQL query
 func (db *DB) StoreEvent(event *models.Event) error {
@@ -19,33 +24,104 @@ func (db *DB) StoreEvent(event *models.Event) error {
 	if err != nil {
 		return fmt.Errorf("marshaling params: %w", err)
 	}
-	responseBytes, err := json.Marshal(event.Response)
+	// A non-object result shares the response column; reads tell them apart by shape.
+	var response interface{} = event.Response
+	if event.ResponseValue != nil {
+		response = event.ResponseValue
+	}
+	responseBytes, err := json.Marshal(response)
 	if err != nil {
 		return fmt.Errorf("marshaling response: %w", err)
 	}
+	tags := ""
+	if len(event.Tags) > 0 {
+		tagBytes, err := json.Marshal(event.Tags)
+		if err != nil {
+			return fmt.Errorf("marshaling tags: %w", err)
+		}
+		tags = string(tagBytes)
+	}
+	labels, err := marshalStringMap(event.Labels)
+	if err != nil {
+		return fmt.Errorf("marshaling labels: %w", err)
+	}
+	headers, err := marshalStringMap(event.Headers)
+	if err != nil {
+		return fmt.Errorf("marshaling headers: %w", err)
+	}
+	queryParams, err := marshalStringMap(event.QueryParams)
+	if err != nil {
+		return fmt.Errorf("marshaling query params: %w", err)
+	}
+	paramsHash, err := crypto.PayloadHash(event.Method, event.Params)
+	if err != nil {
+		return fmt.Errorf("hashing params: %w", err)
+	}
 
-	return db.InsertEvent(
-		event.ID,
-		event.RunID,
-		event.SeqIndex,
-		event.Timestamp.Format(time.RFC3339Nano),
-		event.Actor,
-		event.EventType,
-		event.Method,
-		string(paramsBytes),
-		string(responseBytes),
-		event.TaskID,
-		event.TaskState,
-		event.ParentID,
-		event.PolicyID,
-		event.RiskLevel,
-		event.PrevHash,
-		event.CurrentHash,
-		event.Signature,
+	if err := assert.Check(event.ID != "", "event id must not be empty"); err != nil {
+		return err
+	}
+	if err := assert.Check(event.CurrentHash != "" && event.Signature != "", "event must be hashed and signed: id=%s", event.ID); err != nil {
+		return err
+	}
+
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return fmt.Errorf("beginning event transaction: %w", err)
+	}
+	query := `INSERT INTO events (` + eventColumns + `, params_hash) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	res, err := tx.Exec(query,
+		event.ID, event.RunID, event.SeqIndex, event.Timestamp.Format(time.RFC3339Nano),
+		event.Actor, event.EventType, event.Method, string(paramsBytes), string(responseBytes),
+		event.TaskID, event.TaskState, event.ParentID, event.PolicyID, event.RiskLevel,
+		event.Environment, tags, labels, event.CorrelationID, event.TraceID, event.SpanID,
+		headers, queryParams, event.PrevHash, event.CurrentHash, event.Signature, paramsHash,
 	)
+	if err != nil {
+		return rollback(tx, fmt.Errorf("inserting event: %w", err))
+	}
+	rows, err := res.RowsAffected()
+	if err != nil || rows != 1 {
+		return rollback(tx, fmt.Errorf("failed to insert event: rows affected = %d", rows))
+	}
+	labelTarget := event.ID
+	if a, ok := models.AnnotationFromEvent(event); ok {
+		if err := insertAnnotation(tx, a); err != nil {
+			return rollback(tx, err)
+		}
+		labelTarget = a.EventID // annotation labels apply to the annotated event
+	}
+	if err := indexLabels(tx, labelTarget, event.ID, event.Labels); err != nil {
+		return rollback(tx, err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("committing event: %w", err)
+	}
+	return nil
+}
+
+// marshalStringMap encodes m as a JSON object, or "" when it is empty.
+func marshalStringMap(m map[string]string) (string, error) {
+	if len(m) == 0 {
+		return "", nil
+	}
+	b, err := json.Marshal(m)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// rollback aborts tx and returns cause, joined with any rollback failure.
+func rollback(tx *sql.Tx, cause error) error {
+	if err := tx.Rollback(); err != nil {
+		return fmt.Errorf("%v; rolling back: %w", cause, err)
+	}
+	return cause
 }
 
-// InsertEvent inserts a new event into the ledger
+// InsertEvent inserts a new event into the ledger from pre-serialized core columns.
+// Columns added after the 2026.1 format keep their defaults; use StoreEvent for full events.
 func (db *DB) InsertEvent(id, runID string, seqIndex uint64, timestamp, actor, eventType, method, params, response, taskID, taskState, parentID, policyID, riskLevel, prevHash, currentHash, signature string) error {
 	if err := assert.Check(id != "", "event id must not be empty"); err != nil {
 		return err
@@ -96,27 +172,97 @@ func (db *DB) GetLastEvent(runID string) (seqIndex uint64, currentHash string, e
 	return seqIndex, currentHash, nil
 }
 
-// GetAllEvents retrieves all events for a run, ordered by sequence
-func (db *DB) GetAllEvents(runID string) (events []models.Event, err error) {
-	if err := assert.Check(runID != "", "runID must not be empty"); err != nil {
+// eventColumns is the column list shared by every events query; scanEvent expects this order.
+const eventColumns = `id, run_id, seq_index, timestamp, actor, event_type, method, params, response,
+		task_id, task_state, parent_id, policy_id, risk_level, environment, tags, labels,
+		correlation_id, trace_id, span_id, headers, query_params, prev_hash, current_hash, signature`
+
+// rowScanner is satisfied by both *sql.Row and *sql.Rows.
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanEvent decodes one row selected with eventColumns into a models.Event.
+// Malformed JSON payloads are logged and left nil so a single bad row cannot hide the rest.
+func scanEvent(row rowScanner) (*models.Event, error) {
+	if err := assert.NotNil(row, "row"); err != nil {
+		return nil, err
+	}
+	var e models.Event
+	var timestamp, params, response, tags, labels, headers, queryParams string
+	err := row.Scan(
+		&e.ID, &e.RunID, &e.SeqIndex, &timestamp, &e.Actor, &e.EventType, &e.Method,
+		&params, &response, &e.TaskID, &e.TaskState, &e.ParentID, &e.PolicyID, &e.RiskLevel,
+		&e.Environment, &tags, &labels, &e.CorrelationID, &e.TraceID, &e.SpanID,
+		&headers, &queryParams, &e.PrevHash, &e.CurrentHash, &e.Signature,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if err := assert.Check(e.ID != "", "scanned event id must not be empty"); err != nil {
 		return nil, err
 	}
 
-	query := `
-		SELECT id, run_id, seq_index, timestamp, actor, event_type, method, 
-		       params, response, task_id, task_state, parent_id, policy_id, risk_level, prev_hash, current_hash, signature
-		FROM events 
-		WHERE run_id = ? 
-		ORDER BY seq_index ASC
-	`
+	if t, err := time.Parse(time.RFC3339Nano, timestamp); err == nil {
+		e.Timestamp = t
+	}
+	if params != "" && params != "null" {
+		var paramsMap map[string]interface{}
+		if err := json.Unmarshal([]byte(params), &paramsMap); err != nil {
+			log.Printf("Warning: failed to unmarshal params for event %s: %v", e.ID, err)
+		} else {
+			e.Params = paramsMap
+		}
+	}
+	if response != "" && response != "null" {
+		var value interface{}
+		if err := json.Unmarshal([]byte(response), &value); err != nil {
+			log.Printf("Warning: failed to unmarshal response for event %s: %v", e.ID, err)
+		} else if responseMap, ok := value.(map[string]interface{}); ok {
+			e.Response = responseMap
+		} else {
+			e.ResponseValue = value
+		}
+	}
+	if tags != "" {
+		if err := json.Unmarshal([]byte(tags), &e.Tags); err != nil {
+			log.Printf("Warning: failed to unmarshal tags for event %s: %v", e.ID, err)
+		}
+	}
+	if labels != "" {
+		if err := json.Unmarshal([]byte(labels), &e.Labels); err != nil {
+			log.Printf("Warning: failed to unmarshal labels for event %s: %v", e.ID, err)
+		}
+	}
+	if headers != "" {
+		if err := json.Unmarshal([]byte(headers), &e.Headers); err != nil {
+			log.Printf("Warning: failed to unmarshal headers for event %s: %v", e.ID, err)
+		}
+	}
+	if queryParams != "" {
+		if err := json.Unmarshal([]byte(queryParams), &e.QueryParams); err != nil {
+			log.Printf("Warning: failed to unmarshal query params for event %s: %v", e.ID, err)
+		}
+	}
+	return &e, nil
+}
 
-	rows, err := db.conn.Query(query, runID)
+// queryEvents runs a query selecting eventColumns and collects at most maxEventRows results.
+func (db *DB) queryEvents(label, query string, args ...interface{}) (events []models.Event, err error) {
+	if err := assert.Check(label != "", "query label must not be empty"); err != nil {
+		return nil, err
+	}
+	if err := assert.Check(query != "", "query must not be empty"); err != nil {
+		return nil, err
+	}
+
+	rows, err := db.conn.Query(query, args...)
 	if err != nil {
-		return nil, fmt.Errorf("querying events: %w", err)
+		return nil, fmt.Errorf("querying %s: %w", label, err)
 	}
 	defer func() {
 		if closeErr := rows.Close(); closeErr != nil && err == nil {
-			err = fmt.Errorf("closing events rows: %w", closeErr)
+			err = fmt.Errorf("closing %s rows: %w", label, closeErr)
 		}
 	}()
 
@@ -124,113 +270,85 @@ func (db *DB) GetAllEvents(runID string) (events []models.Event, err error) {
 		if !rows.Next() {
 			break
 		}
-		var e models.Event
-		var timestamp, params, response, taskID, taskState, parentID, policyID, riskLevel string
-
-		err := rows.Scan(
-			&e.ID, &e.RunID, &e.SeqIndex, &timestamp, &e.Actor, &e.EventType, &e.Method,
-			&params, &response, &taskID, &taskState, &parentID, &policyID, &riskLevel, &e.PrevHash, &e.CurrentHash, &e.Signature,
-		)
+		e, err := scanEvent(rows)
 		if err != nil {
 			return nil, fmt.Errorf("scanning event: %w", err)
 		}
-
-		e.ParentID = parentID
-		e.PolicyID = policyID
-		e.RiskLevel = riskLevel
-
-		// Parse timestamp
-		t, err := time.Parse(time.RFC3339Nano, timestamp)
-		if err == nil {
-			e.Timestamp = t
-		}
-		e.TaskID = taskID
-		e.TaskState = taskState
-
-		// Parse JSON fields
-		if params != "" && params != "null" {
-			var paramsMap map[string]interface{}
-			if err := json.Unmarshal([]byte(params), &paramsMap); err != nil {
-				log.Printf("Warning: failed to unmarshal params for event %s: %v", e.ID, err)
-			} else {
-				e.Params = paramsMap
-			}
-		}
-
-		if response != "" && response != "null" {
-			var responseMap map[string]interface{}
-			if err := json.Unmarshal([]byte(response), &responseMap); err != nil {
-				log.Printf("Warning: failed to unmarshal response for event %s: %v", e.ID, err)
-			} else {
-				e.Response = responseMap
-			}
-		}
-
-		events = append(events, e)
+		events = append(events, *e)
 	}
 
-	if err := assert.Check(rows.Err() == nil, "get all events rows error: %v", rows.Err()); err != nil {
+	if err := assert.Check(rows.Err() == nil, "%s rows error: %v", label, rows.Err()); err != nil {
 		return nil, err
 	}
 	return events, nil
 }
 
-// GetRecentEvents retrieves the N most recent events
-func (db *DB) GetRecentEvents(runID string, limit int) (events []models.Event, err error) {
+// GetAllEvents retrieves all events for a run, ordered by sequence
+func (db *DB) GetAllEvents(runID string) ([]models.Event, error) {
 	if err := assert.Check(runID != "", "runID must not be empty"); err != nil {
 		return nil, err
 	}
-	if err := assert.Check(limit > 0, "limit must be positive"); err != nil {
-		return nil, err
-	}
-
-	query := `
-		SELECT id, run_id, seq_index, timestamp, actor, event_type, method, 
-		       params, response, task_id, task_state, parent_id, policy_id, risk_level, prev_hash, current_hash, signature
-		FROM events 
-		WHERE run_id = ? 
-		ORDER BY seq_index DESC 
-		LIMIT ?
-	`
+	query := `SELECT ` + eventColumns + ` FROM events WHERE run_id = ? ORDER BY seq_index ASC`
+	return db.queryEvents("events", query, runID)
+}
 
-	rows, err := db.conn.Query(query, runID, limit)
+// ForEachEvent calls fn with each event of a run in sequence order, scanning one row at a
+// time so runs larger than maxEventRows are read in constant memory. It stops at the
+// first error from fn and returns it.
+func (db *DB) ForEachEvent(runID string, fn func(*models.Event) error) (err error) {
+	if err := assert.Check(runID != "", "runID must not be empty"); err != nil {
+		return err
+	}
+	if err := assert.NotNil(fn, "event callback"); err != nil {
+		return err
+	}
+	query := `SELECT ` + eventColumns + ` FROM events WHERE run_id = ? ORDER BY seq_index ASC`
+	rows, err := db.conn.Query(query, runID)
 	if err != nil {
-		return nil, fmt.Errorf("querying recent events: %w", err)
+		return fmt.Errorf("querying events: %w", err)
 	}
 	defer func() {
 		if closeErr := rows.Close(); closeErr != nil && err == nil {
-			err = fmt.Errorf("closing recent events rows: %w", closeErr)
+			err = fmt.Errorf("closing events rows: %w", closeErr)
 		}
 	}()
-
-	for i := 0; i < maxEventRows; i++ {
+	for i := 0; i < maxStreamEvents; i++ {
 		if !rows.Next() {
 			break
 		}
-		var e models.Event
-		var timestamp, params, response, taskID, taskState, parentID, policyID, riskLevel string
-
-		err := rows.Scan(
-			&e.ID, &e.RunID, &e.SeqIndex, &timestamp, &e.Actor, &e.EventType, &e.Method,
-			&params, &response, &taskID, &taskState, &parentID, &policyID, &riskLevel, &e.PrevHash, &e.CurrentHash, &e.Signature,
-		)
+		e, err := scanEvent(rows)
 		if err != nil {
-			return nil, fmt.Errorf("scanning event: %w", err)
+			return fmt.Errorf("scanning event: %w", err)
 		}
+		if err := fn(e); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
 
-		e.TaskID = taskID
-		e.TaskState = taskState
-		e.ParentID = parentID
-		e.PolicyID = policyID
-		e.RiskLevel = riskLevel
-
-		events = append(events, e)
+// GetRecentEvents retrieves the N most recent events
+func (db *DB) GetRecentEvents(runID string, limit int) ([]models.Event, error) {
+	if err := assert.Check(runID != "", "runID must not be empty"); err != nil {
+		return nil, err
 	}
+	if err := assert.Check(limit > 0, "limit must be positive"); err != nil {
+		return nil, err
+	}
+	query := `SELECT ` + eventColumns + ` FROM events WHERE run_id = ? ORDER BY seq_index DESC LIMIT ?`
+	return db.queryEvents("recent events", query, runID, limit)
+}
 
-	if err := assert.Check(rows.Err() == nil, "recent events rows error: %v", rows.Err()); err != nil {
+// GetEventsFrom retrieves up to limit events of a run starting at seq fromSeq, in order
+func (db *DB) GetEventsFrom(runID string, fromSeq uint64, limit int) ([]models.Event, error) {
+	if err := assert.Check(runID != "", "runID must not be empty"); err != nil {
 		return nil, err
 	}
-	return events, nil
+	if err := assert.Check(limit > 0, "limit must be positive"); err != nil {
+		return nil, err
+	}
+	query := `SELECT ` + eventColumns + ` FROM events WHERE run_id = ? AND seq_index >= ? ORDER BY seq_index ASC LIMIT ?`
+	return db.queryEvents("events from seq", query, runID, fromSeq, limit)
 }
 
 // GetEventByID retrieves a specific event by ID
@@ -238,128 +356,96 @@ func (db *DB) GetEventByID(eventID string) (*models.Event, error) {
 	if err := assert.Check(eventID != "", "eventID must not be empty"); err != nil {
 		return nil, err
 	}
-
-	query := `
-		SELECT id, run_id, seq_index, timestamp, actor, event_type, method, 
-		       params, response, task_id, task_state, parent_id, policy_id, risk_level, prev_hash, current_hash, signature
-		FROM events 
-		WHERE id = ?
-	`
-
-	var e models.Event
-	var timestamp, params, response, taskID, taskState, parentID, policyID, riskLevel string
-
-	err := db.conn.QueryRow(query, eventID).Scan(
-		&e.ID, &e.RunID, &e.SeqIndex, &timestamp, &e.Actor, &e.EventType, &e.Method,
-		&params, &response, &taskID, &taskState, &parentID, &policyID, &riskLevel, &e.PrevHash, &e.CurrentHash, &e.Signature,
-	)
+	query := `SELECT ` + eventColumns + ` FROM events WHERE id = ?`
+	e, err := scanEvent(db.conn.QueryRow(query, eventID))
 	if err != nil {
 		return nil, fmt.Errorf("querying event: %w", err)
 	}
-
-	e.TaskID = taskID
-	e.TaskState = taskState
-	e.ParentID = parentID
-	e.PolicyID = policyID
-	e.RiskLevel = riskLevel
-
-	return &e, nil
+	return e, nil
 }
 
 // GetEventsByTaskID retrieves all events for a specific task
-func (db *DB) GetEventsByTaskID(taskID string) (events []models.Event, err error) {
+func (db *DB) GetEventsByTaskID(taskID string) ([]models.Event, error) {
 	if err := assert.Check(taskID != "", "taskID must not be empty"); err != nil {
 		return nil, err
 	}
-	query := `
-		SELECT id, run_id, seq_index, timestamp, actor, event_type, method, 
-		       params, response, task_id, task_state, parent_id, policy_id, risk_level, prev_hash, current_hash, signature
-		FROM events 
-		WHERE task_id = ? 
-		ORDER BY seq_index ASC
-	`
-	rows, err := db.conn.Query(query, taskID)
-	if err != nil {
-		return nil, fmt.Errorf("querying task events: %w", err)
-	}
-	defer func() {
-		if closeErr := rows.Close(); closeErr != nil && err == nil {
-			err = fmt.Errorf("closing task events rows: %w", closeErr)
-		}
-	}()
-
-	for i := 0; i < maxEventRows; i++ {
-		if !rows.Next() {
-			break
-		}
-		var e models.Event
-		var timestamp, params, response, tID, tState, parentID, policyID, riskLevel string
-
-		err := rows.Scan(
-			&e.ID, &e.RunID, &e.SeqIndex, &timestamp, &e.Actor, &e.EventType, &e.Method,
-			&params, &response, &tID, &tState, &parentID, &policyID, &riskLevel, &e.PrevHash, &e.CurrentHash, &e.Signature,
-		)
-		if err != nil {
-			return nil, fmt.Errorf("scanning event: %w", err)
-		}
+	query := `SELECT ` + eventColumns + ` FROM events WHERE task_id = ? ORDER BY seq_index ASC`
+	return db.queryEvents("task events", query, taskID)
+}
 
-		e.TaskID = tID
-		e.TaskState = tState
-		e.ParentID = parentID
-		e.PolicyID = policyID
-		e.RiskLevel = riskLevel
-		events = append(events, e)
-	}
-	if err := assert.Check(rows.Err() == nil, "task events rows error: %v", rows.Err()); err != nil {
+// GetEventsByTraceID returns the events recorded under a W3C trace ID, oldest first
+func (db *DB) GetEventsByTraceID(traceID string) ([]models.Event, error) {
+	if err := assert.Check(traceID != "", "traceID must not be empty"); err != nil {
 		return nil, err
 	}
-	return events, nil
+	query := `SELECT ` + eventColumns + ` FROM events WHERE trace_id = ? ORDER BY timestamp ASC, seq_index ASC`
+	return db.queryEvents("trace events", query, traceID)
 }
 
 // GetRiskEvents returns events with high or critical risk
-func (db *DB) GetRiskEvents() (events []models.Event, err error) {
-	query := `
-		SELECT id, run_id, seq_index, timestamp, actor, event_type, method, params, response,
-		       task_id, task_state, parent_id, policy_id, risk_level, prev_hash, current_hash, signature
-		FROM events 
+func (db *DB) GetRiskEvents() ([]models.Event, error) {
+	query := `SELECT ` + eventColumns + ` FROM events
 		WHERE risk_level IN ('high', 'critical')
-		ORDER BY timestamp DESC
-	`
-	rows, err := db.conn.Query(query)
+		ORDER BY timestamp DESC`
+	return db.queryEvents("risk events", query)
+}
+
+// GetRiskEventsPage returns one page of risk events, newest first, with the cursor for
+// the next. Pages are keyed on insertion order, so each costs the same however deep into
+// the ledger it is.
+func (db *DB) GetRiskEventsPage(q models.RiskQuery) ([]models.Event, string, error) {
+	if err := assert.Check(len(q.Levels) > 0 && q.Limit > 0 && q.Limit <= maxEventRows, "risk query needs levels and a limit up to %d", maxEventRows); err != nil {
+		return nil, "", err
+	}
+	query := `SELECT ` + eventColumns + ` FROM events WHERE risk_level IN (?` + strings.Repeat(", ?", len(q.Levels)-1) + `)`
+	args := make([]interface{}, 0, len(q.Levels)+2)
+	for i := 0; i < len(q.Levels); i++ {
+		args = append(args, q.Levels[i])
+	}
+	if q.After != "" {
+		query += ` AND rowid < (SELECT rowid FROM events WHERE id = ?)`
+		args = append(args, q.After)
+	}
+	query += ` ORDER BY rowid DESC LIMIT ?`
+	args = append(args, q.Limit+1)
+	events, err := db.queryEvents("risk events", query, args...)
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
-	defer func() {
-		if closeErr := rows.Close(); closeErr != nil && err == nil {
-			err = fmt.Errorf("closing risk events rows: %w", closeErr)
-		}
-	}()
-
-	for i := 0; i < maxEventRows; i++ {
-		if !rows.Next() {
-			break
-		}
-		var e models.Event
-		var timestamp, params, response, taskID, taskState, parentID, policyID, riskLevel string
+	return riskPage(events, q)
+}
 
-		err := rows.Scan(
-			&e.ID, &e.RunID, &e.SeqIndex, &timestamp, &e.Actor, &e.EventType, &e.Method,
-			&params, &response, &taskID, &taskState, &parentID, &policyID, &riskLevel, &e.PrevHash, &e.CurrentHash, &e.Signature,
-		)
-		if err != nil {
-			return nil, err
+// riskPage trims a result fetched with one row to spare to the page and its cursor.
+// Rows come newest first, so the first one before q.Since ends the listing.
+func riskPage(events []models.Event, q models.RiskQuery) ([]models.Event, string, error) {
+	for i := 0; i < len(events); i++ {
+		if !q.Since.IsZero() && events[i].Timestamp.Before(q.Since) {
+			return events[:i], "", nil
 		}
+	}
+	if len(events) <= q.Limit {
+		return events, "", nil
+	}
+	return events[:q.Limit], events[q.Limit-1].ID, nil
+}
 
-		e.ParentID = parentID
-		e.PolicyID = policyID
-		e.RiskLevel = riskLevel
-		events = append(events, e)
+// GetEventsByParentID returns the events linked to a parent event (findings, approvals,
+// annotations), oldest first
+func (db *DB) GetEventsByParentID(parentID string) ([]models.Event, error) {
+	if err := assert.Check(parentID != "", "parentID must not be empty"); err != nil {
+		return nil, err
 	}
+	query := `SELECT ` + eventColumns + ` FROM events WHERE parent_id = ? ORDER BY timestamp ASC`
+	return db.queryEvents("child events", query, parentID)
+}
 
-	if err := assert.Check(rows.Err() == nil, "risk events rows error: %v", rows.Err()); err != nil {
+// GetEventsByType returns all events of a given type across runs, oldest first
+func (db *DB) GetEventsByType(eventType string) ([]models.Event, error) {
+	if err := assert.Check(eventType != "", "eventType must not be empty"); err != nil {
 		return nil, err
 	}
-	return events, nil
+	query := `SELECT ` + eventColumns + ` FROM events WHERE event_type = ? ORDER BY timestamp ASC`
+	return db.queryEvents("typed events", query, eventType)
 }
 
 // GetUniqueTasks returns all unique task IDs in the ledger
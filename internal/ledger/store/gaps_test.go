@@ -0,0 +1,66 @@
+package store
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/slyt3/Logryph/internal/assert"
+	"github.com/slyt3/Logryph/internal/crypto"
+	"github.com/slyt3/Logryph/internal/ledger"
+	"github.com/slyt3/Logryph/internal/ledger/audit"
+	"github.com/slyt3/Logryph/internal/models"
+)
+
+func TestAcknowledgeGapsLetsTheChainContinue(t *testing.T) {
+	dir := t.TempDir()
+	db, err := NewDB(filepath.Join(dir, "logryph.db"))
+	if err != nil {
+		t.Fatalf("failed to create database: %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	signer, err := crypto.NewSigner(filepath.Join(dir, "key"))
+	if err != nil {
+		t.Fatalf("signer: %v", err)
+	}
+	runID, err := ledger.CreateGenesisBlock(db, signer, "agent")
+	if err != nil {
+		t.Fatalf("genesis: %v", err)
+	}
+	p := ledger.NewEventProcessor(db, signer, runID)
+	for i := 0; i < 3; i++ {
+		if err := p.ProcessEvent(&models.Event{ID: "e" + string(rune('1'+i)), Timestamp: time.Now(), Actor: "agent", EventType: "tool_call", Method: "os.read"}); err != nil {
+			t.Fatalf("ProcessEvent: %v", err)
+		}
+	}
+	if _, err := db.conn.Exec(`DELETE FROM events WHERE seq_index = 2`); err != nil {
+		t.Fatalf("deleting event: %v", err)
+	}
+
+	oldStrict, oldSuppress := assert.StrictMode, assert.SuppressLogs
+	assert.StrictMode, assert.SuppressLogs = false, true
+	defer func() { assert.StrictMode, assert.SuppressLogs = oldStrict, oldSuppress }()
+	if err := p.ProcessEvent(&models.Event{ID: "blocked", Timestamp: time.Now(), EventType: "tool_call"}); err == nil {
+		t.Fatal("writes should fail while the gap is unacknowledged")
+	}
+
+	gaps, err := ledger.AcknowledgeGaps(db, signer, runID, "alice", "disk failure on node-3")
+	if err != nil || len(gaps) != 1 || gaps[0] != (audit.Gap{From: 2, To: 2}) {
+		t.Fatalf("AcknowledgeGaps = %v, %v", gaps, err)
+	}
+	if again, err := ledger.AcknowledgeGaps(db, signer, runID, "alice", "again"); err != nil || len(again) != 0 {
+		t.Errorf("an acknowledged gap should not be acknowledged twice: %v, %v", again, err)
+	}
+	if err := p.ProcessEvent(&models.Event{ID: "after", Timestamp: time.Now(), Actor: "agent", EventType: "tool_call", Method: "os.read"}); err != nil {
+		t.Fatalf("writes should continue after the acknowledgement: %v", err)
+	}
+
+	result, err := audit.VerifyChain(db, runID, signer)
+	if err != nil || !result.Valid || len(result.AcknowledgedGaps) != 1 {
+		t.Errorf("chain with an acknowledged gap should verify: %v %+v", err, result)
+	}
+	last, _, _ := db.GetLastEvent(runID)
+	if last != 5 {
+		t.Errorf("last seq = %d, want 5 (acknowledgement at 4)", last)
+	}
+}
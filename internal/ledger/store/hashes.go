@@ -0,0 +1,39 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/slyt3/Logryph/internal/assert"
+	"github.com/slyt3/Logryph/internal/models"
+)
+
+// FindByHash returns the event whose current_hash is hash, or nil if none is stored.
+func (db *DB) FindByHash(hash string) (*models.Event, error) {
+	if err := assert.Check(hash != "", "hash must not be empty"); err != nil {
+		return nil, err
+	}
+	query := `SELECT ` + eventColumns + ` FROM events WHERE current_hash = ? LIMIT 1`
+	e, err := scanEvent(db.conn.QueryRow(query, hash))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("querying event by hash: %w", err)
+	}
+	return e, nil
+}
+
+// FindByParamsHash returns up to limit events whose method and params hash to hash (see
+// crypto.PayloadHash), oldest first.
+func (db *DB) FindByParamsHash(hash string, limit int) ([]models.Event, error) {
+	if err := assert.Check(hash != "", "hash must not be empty"); err != nil {
+		return nil, err
+	}
+	if err := assert.Check(limit > 0, "limit must be positive"); err != nil {
+		return nil, err
+	}
+	query := `SELECT ` + eventColumns + ` FROM events WHERE params_hash = ? ORDER BY timestamp ASC LIMIT ?`
+	return db.queryEvents("events by params hash", query, hash, limit)
+}
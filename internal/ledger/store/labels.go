@@ -0,0 +1,160 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/slyt3/Logryph/internal/assert"
+	"github.com/slyt3/Logryph/internal/ledger"
+	"github.com/slyt3/Logryph/internal/models"
+)
+
+const (
+	maxLabelFilters   = 16
+	maxLabelCountRows = 10000
+)
+
+// LabelCount is the number of events carrying one label value.
+type LabelCount struct {
+	Key    string `json:"key"`
+	Value  string `json:"value"`
+	Events int    `json:"events"`
+}
+
+// indexLabels records labels set by sourceID on eventID. Keys are written in sorted order so
+// the index is deterministic; a later source replaces an earlier value for the same key.
+func indexLabels(tx *sql.Tx, eventID, sourceID string, labels map[string]string) error {
+	if len(labels) == 0 {
+		return nil
+	}
+	if err := assert.Check(len(labels) <= models.MaxEventLabels, "too many labels: %d", len(labels)); err != nil {
+		return err
+	}
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for i := 0; i < len(keys); i++ {
+		_, err := tx.Exec(`INSERT OR REPLACE INTO event_labels (event_id, key, value, source_id) VALUES (?, ?, ?, ?)`,
+			eventID, keys[i], labels[keys[i]], sourceID)
+		if err != nil {
+			return fmt.Errorf("indexing label %s: %w", keys[i], err)
+		}
+	}
+	return nil
+}
+
+// labelFilter returns a SQL condition matching events that carry every label in labels.
+func labelFilter(labels map[string]string) (string, []interface{}, error) {
+	if err := assert.Check(len(labels) <= maxLabelFilters, "too many label filters: %d", len(labels)); err != nil {
+		return "", nil, err
+	}
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	conds := make([]string, 0, len(keys))
+	args := make([]interface{}, 0, 2*len(keys))
+	for i := 0; i < len(keys); i++ {
+		conds = append(conds, `id IN (SELECT event_id FROM event_labels WHERE key = ? AND value = ?)`)
+		args = append(args, keys[i], labels[keys[i]])
+	}
+	if len(conds) == 0 {
+		return "1 = 1", nil, nil
+	}
+	return strings.Join(conds, " AND "), args, nil
+}
+
+// GetEventsByLabels returns the most recent events of a run carrying every given label,
+// newest first. Labels set later by annotations count.
+func (db *DB) GetEventsByLabels(runID string, labels map[string]string, limit int) ([]models.Event, error) {
+	if err := assert.Check(runID != "", "runID must not be empty"); err != nil {
+		return nil, err
+	}
+	if err := assert.Check(limit > 0, "limit must be positive"); err != nil {
+		return nil, err
+	}
+	cond, args, err := labelFilter(labels)
+	if err != nil {
+		return nil, err
+	}
+	query := `SELECT ` + eventColumns + ` FROM events WHERE run_id = ? AND ` + cond + ` ORDER BY seq_index DESC LIMIT ?`
+	args = append([]interface{}{runID}, append(args, limit)...)
+	return db.queryEvents("labeled events", query, args...)
+}
+
+// GetLabelStats returns event, call, blocked and risk counts over all runs for events
+// carrying every given label. RunID is left empty.
+func (db *DB) GetLabelStats(labels map[string]string) (stats *ledger.RunStats, err error) {
+	cond, args, err := labelFilter(labels)
+	if err != nil {
+		return nil, err
+	}
+	stats = &ledger.RunStats{RiskBreakdown: make(map[string]int)}
+	err = db.conn.QueryRow(`
+		SELECT COUNT(*),
+		       COALESCE(SUM(CASE WHEN event_type = 'blocked' THEN 1 ELSE 0 END), 0),
+		       COALESCE(SUM(CASE WHEN event_type = 'tool_call' THEN 1 ELSE 0 END), 0)
+		FROM events WHERE `+cond, args...).Scan(&stats.TotalEvents, &stats.BlockedCount, &stats.CallCount)
+	if err != nil {
+		return nil, fmt.Errorf("querying label stats: %w", err)
+	}
+
+	rows, err := db.conn.Query(`SELECT risk_level, COUNT(*) FROM events
+		WHERE risk_level != '' AND `+cond+` GROUP BY risk_level`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("querying label risk breakdown: %w", err)
+	}
+	defer func() {
+		if closeErr := rows.Close(); closeErr != nil && err == nil {
+			err = fmt.Errorf("closing label risk rows: %w", closeErr)
+		}
+	}()
+	const maxRiskLevels = 32
+	for i := 0; i < maxRiskLevels; i++ {
+		if !rows.Next() {
+			break
+		}
+		var risk string
+		var count int
+		if err := rows.Scan(&risk, &count); err != nil {
+			return nil, fmt.Errorf("scanning label risk breakdown: %w", err)
+		}
+		stats.RiskBreakdown[risk] = count
+	}
+	if err := assert.Check(rows.Err() == nil, "label risk rows error: %v", rows.Err()); err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
+
+// GetLabelCounts returns how many events carry each label value, by key then value.
+func (db *DB) GetLabelCounts() (counts []LabelCount, err error) {
+	rows, err := db.conn.Query(`SELECT key, value, COUNT(*) FROM event_labels GROUP BY key, value ORDER BY key, value`)
+	if err != nil {
+		return nil, fmt.Errorf("querying label counts: %w", err)
+	}
+	defer func() {
+		if closeErr := rows.Close(); closeErr != nil && err == nil {
+			err = fmt.Errorf("closing label count rows: %w", closeErr)
+		}
+	}()
+	for i := 0; i < maxLabelCountRows; i++ {
+		if !rows.Next() {
+			break
+		}
+		var c LabelCount
+		if err := rows.Scan(&c.Key, &c.Value, &c.Events); err != nil {
+			return nil, fmt.Errorf("scanning label count: %w", err)
+		}
+		counts = append(counts, c)
+	}
+	if err := assert.Check(rows.Err() == nil, "label count rows error: %v", rows.Err()); err != nil {
+		return nil, err
+	}
+	return counts, nil
+}
@@ -0,0 +1,52 @@
+package store
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/slyt3/Logryph/internal/models"
+)
+
+// GetLegalHolds returns the legal holds currently set, in the order they were set. Holds
+// are read back from their signed events across all runs, so they survive rotation.
+func (db *DB) GetLegalHolds() ([]models.LegalHold, error) {
+	set, err := db.GetEventsByType(models.EventTypeLegalHoldSet)
+	if err != nil {
+		return nil, err
+	}
+	released, err := db.GetEventsByType(models.EventTypeLegalHoldReleased)
+	if err != nil {
+		return nil, err
+	}
+	events := append(set, released...)
+	sort.SliceStable(events, func(a, b int) bool { return events[a].Timestamp.Before(events[b].Timestamp) })
+	return models.ActiveLegalHolds(events), nil
+}
+
+// GetLegalHoldScope resolves the active holds to the tasks and events they cover. A case
+// hold covers the case's items as they are now, including items added after the hold.
+func (db *DB) GetLegalHoldScope() (*models.HoldScope, error) {
+	holds, err := db.GetLegalHolds()
+	if err != nil {
+		return nil, err
+	}
+	scope := &models.HoldScope{Tasks: map[string]bool{}, Events: map[string]bool{}}
+	for i := 0; i < len(holds); i++ {
+		if holds[i].Kind == models.HoldKindTask {
+			scope.Tasks[holds[i].Ref] = true
+			continue
+		}
+		items, err := db.GetCaseItems(holds[i].Ref)
+		if err != nil {
+			return nil, fmt.Errorf("reading case %s: %w", holds[i].Ref, err)
+		}
+		for j := 0; j < len(items); j++ {
+			if items[j].Kind == models.CaseItemTask {
+				scope.Tasks[items[j].Ref] = true
+			} else {
+				scope.Events[items[j].Ref] = true
+			}
+		}
+	}
+	return scope, nil
+}
@@ -0,0 +1,258 @@
+package store
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+
+	"github.com/slyt3/Logryph/internal/assert"
+	"github.com/slyt3/Logryph/internal/crypto"
+)
+
+// columnMigration describes a column added to an existing table after the initial schema.
+// schema.sql declares the full current layout for fresh databases; migrations bring
+// ledgers created by older releases up to date without touching existing rows.
+type columnMigration struct {
+	table      string
+	column     string
+	definition string
+	backfill   func(*sql.DB) error // fills the new column for existing rows, if set
+}
+
+var columnMigrations = []columnMigration{
+	{table: "events", column: "environment", definition: "TEXT DEFAULT ''"},
+	{table: "events", column: "tags", definition: "TEXT DEFAULT ''"},
+	{table: "events", column: "labels", definition: "TEXT DEFAULT ''"},
+	{table: "events", column: "correlation_id", definition: "TEXT DEFAULT ''"},
+	{table: "events", column: "trace_id", definition: "TEXT DEFAULT ''"},
+	{table: "events", column: "span_id", definition: "TEXT DEFAULT ''"},
+	{table: "events", column: "headers", definition: "TEXT DEFAULT ''"},
+	{table: "events", column: "query_params", definition: "TEXT DEFAULT ''"},
+	{table: "events", column: "params_hash", definition: "TEXT DEFAULT ''", backfill: backfillParamsHashes},
+}
+
+// indexMigrations run after the columns they cover exist.
+var indexMigrations = []string{
+	"CREATE INDEX IF NOT EXISTS idx_events_params_hash ON events(params_hash)",
+}
+
+// dataMigrations rewrite derived columns when the way they are computed changes. Each
+// runs once, in order; PRAGMA user_version counts how many a database has had. They must
+// be safe to repeat, since two processes opening an old ledger may both run them.
+var dataMigrations = []func(*sql.DB) error{
+	rehashParams,          // params_hash over normalized params (trimmed strings)
+	rebuildStats,          // stats_* aggregate tables
+	recreateStatsTriggers, // stats_methods counts aliased methods under their canonical name
+}
+
+const maxTableColumns = 128
+
+// migrate adds any columns missing from databases created by older schema versions.
+func migrate(conn *sql.DB) error {
+	if err := assert.NotNil(conn, "connection"); err != nil {
+		return err
+	}
+	if err := assert.Check(len(columnMigrations) <= maxTableColumns, "too many column migrations: %d", len(columnMigrations)); err != nil {
+		return err
+	}
+
+	for i := 0; i < maxTableColumns; i++ {
+		if i >= len(columnMigrations) {
+			break
+		}
+		m := columnMigrations[i]
+		exists, err := hasColumn(conn, m.table, m.column)
+		if err != nil {
+			return err
+		}
+		if exists {
+			continue
+		}
+		stmt := fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", m.table, m.column, m.definition)
+		if _, err := conn.Exec(stmt); err != nil {
+			return fmt.Errorf("adding column %s.%s: %w", m.table, m.column, err)
+		}
+		if m.backfill != nil {
+			if err := m.backfill(conn); err != nil {
+				return fmt.Errorf("backfilling %s.%s: %w", m.table, m.column, err)
+			}
+		}
+	}
+	for i := 0; i < len(indexMigrations); i++ {
+		if _, err := conn.Exec(indexMigrations[i]); err != nil {
+			return fmt.Errorf("creating index: %w", err)
+		}
+	}
+	return migrateData(conn)
+}
+
+// migrateData runs the data migrations this database has not had yet.
+func migrateData(conn *sql.DB) error {
+	var version int
+	if err := conn.QueryRow(`PRAGMA user_version`).Scan(&version); err != nil {
+		return fmt.Errorf("reading schema version: %w", err)
+	}
+	for i := version; i >= 0 && i < len(dataMigrations); i++ {
+		if err := dataMigrations[i](conn); err != nil {
+			return fmt.Errorf("data migration %d: %w", i+1, err)
+		}
+		if _, err := conn.Exec(fmt.Sprintf("PRAGMA user_version = %d", i+1)); err != nil {
+			return fmt.Errorf("recording schema version: %w", err)
+		}
+	}
+	return nil
+}
+
+// recreateStatsTriggers replaces the stats triggers of older ledgers, which counted
+// aliased methods under the name the agent sent, and recounts.
+func recreateStatsTriggers(conn *sql.DB) error {
+	for _, stmt := range []string{
+		`DROP TRIGGER IF EXISTS trg_events_stats_insert`,
+		`DROP TRIGGER IF EXISTS trg_events_stats_delete`,
+		schemaSQL,
+	} {
+		if _, err := conn.Exec(stmt); err != nil {
+			return err
+		}
+	}
+	return rebuildStats(conn)
+}
+
+// paramsHashBatch is how many events are hashed and committed at a time, so migrating a
+// large ledger holds neither all its hashes in memory nor all its updates in the WAL.
+var paramsHashBatch = 1000
+
+// backfillParamsHashes computes params_hash for events stored before the column existed.
+// If it is interrupted, rehashParams, which has not run yet either, finishes the job.
+func backfillParamsHashes(conn *sql.DB) error {
+	return writeParamsHashes(conn, `params_hash = ''`)
+}
+
+// rehashParams recomputes every params_hash, for ledgers hashed before params were
+// normalized.
+func rehashParams(conn *sql.DB) error {
+	return writeParamsHashes(conn, `1`)
+}
+
+// paramsHash is the hash computed for one event.
+type paramsHash struct {
+	id, hash string
+}
+
+// writeParamsHashes hashes the params of the events matching where and stores the hashes,
+// a batch at a time in rowid order, committing each batch. An interrupted run keeps the
+// batches it committed; the migration is not recorded as done, so it runs again on the
+// next open.
+func writeParamsHashes(conn *sql.DB, where string) error {
+	query := `SELECT rowid, id, method, params FROM events WHERE rowid > ? AND (` + where + `) ORDER BY rowid LIMIT ?`
+	var after int64
+	for i := 0; i < maxStreamEvents; i++ {
+		hashes, last, err := paramsHashes(conn, query, after)
+		if err != nil {
+			return err
+		}
+		if last == after {
+			return nil
+		}
+		if err := storeParamsHashes(conn, hashes); err != nil {
+			return err
+		}
+		after = last
+	}
+	return nil
+}
+
+// storeParamsHashes writes one batch of hashes in a transaction.
+func storeParamsHashes(conn *sql.DB, hashes []paramsHash) error {
+	tx, err := conn.Begin()
+	if err != nil {
+		return err
+	}
+	for i := 0; i < len(hashes); i++ {
+		if _, err := tx.Exec(`UPDATE events SET params_hash = ? WHERE id = ?`, hashes[i].hash, hashes[i].id); err != nil {
+			return rollback(tx, err)
+		}
+	}
+	return tx.Commit()
+}
+
+// paramsHashes reads the next batch of rowid, id, method and params rows after rowid
+// after from query and hashes their params. It returns the last rowid read, or after when
+// there are no more rows. Rows whose params do not decode are logged and left unindexed,
+// as scanEvent leaves them nil.
+func paramsHashes(conn *sql.DB, query string, after int64) (_ []paramsHash, last int64, err error) {
+	rows, err := conn.Query(query, after, paramsHashBatch)
+	if err != nil {
+		return nil, after, err
+	}
+	defer func() {
+		if closeErr := rows.Close(); closeErr != nil && err == nil {
+			err = fmt.Errorf("closing params rows: %w", closeErr)
+		}
+	}()
+	last = after
+	hashes := make([]paramsHash, 0, paramsHashBatch)
+	for i := 0; i < paramsHashBatch; i++ {
+		if !rows.Next() {
+			break
+		}
+		var id, method string
+		var raw sql.NullString
+		if err := rows.Scan(&last, &id, &method, &raw); err != nil {
+			return nil, after, err
+		}
+		var params interface{}
+		if raw.Valid && raw.String != "" {
+			if err := json.Unmarshal([]byte(raw.String), &params); err != nil {
+				log.Printf("Warning: not indexing params of event %s: %v", id, err)
+				continue
+			}
+		}
+		hash, err := crypto.PayloadHash(method, params)
+		if err != nil {
+			return nil, after, err
+		}
+		hashes = append(hashes, paramsHash{id: id, hash: hash})
+	}
+	return hashes, last, rows.Err()
+}
+
+// hasColumn reports whether table already defines column.
+func hasColumn(conn *sql.DB, table, column string) (found bool, err error) {
+	if err := assert.Check(table != "", "table must not be empty"); err != nil {
+		return false, err
+	}
+	if err := assert.Check(column != "", "column must not be empty"); err != nil {
+		return false, err
+	}
+
+	rows, err := conn.Query(fmt.Sprintf("PRAGMA table_info(%s)", table))
+	if err != nil {
+		return false, fmt.Errorf("reading table info for %s: %w", table, err)
+	}
+	defer func() {
+		if closeErr := rows.Close(); closeErr != nil && err == nil {
+			err = fmt.Errorf("closing table info rows: %w", closeErr)
+		}
+	}()
+
+	for i := 0; i < maxTableColumns; i++ {
+		if !rows.Next() {
+			break
+		}
+		var cid, notNull, pk int
+		var name, colType string
+		var dflt sql.NullString
+		if err := rows.Scan(&cid, &name, &colType, &notNull, &dflt, &pk); err != nil {
+			return false, fmt.Errorf("scanning table info: %w", err)
+		}
+		if name == column {
+			found = true
+		}
+	}
+	if err := assert.Check(rows.Err() == nil, "table info rows error: %v", rows.Err()); err != nil {
+		return false, err
+	}
+	return found, nil
+}
@@ -0,0 +1,100 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/slyt3/Logryph/internal/assert"
+	"github.com/slyt3/Logryph/internal/models"
+)
+
+const (
+	maxQueryRows    = 10000
+	maxQueryNameLen = 128
+)
+
+// ErrQueryNotFound is returned when no saved query has the given name.
+var ErrQueryNotFound = errors.New("saved query not found")
+
+// SaveQuery stores q under its name, replacing the expression of an existing query.
+func (db *DB) SaveQuery(q models.SavedQuery) error {
+	if err := assert.Check(q.Name != "" && len(q.Name) <= maxQueryNameLen, "query name must be 1-%d bytes", maxQueryNameLen); err != nil {
+		return err
+	}
+	if err := assert.Check(q.Expr != "", "query expression must not be empty"); err != nil {
+		return err
+	}
+	now := q.UpdatedAt.Format(time.RFC3339Nano)
+	_, err := db.conn.Exec(`INSERT INTO saved_queries (name, expr, created_at, updated_at) VALUES (?, ?, ?, ?)
+		ON CONFLICT(name) DO UPDATE SET expr = excluded.expr, updated_at = excluded.updated_at`,
+		q.Name, q.Expr, now, now)
+	if err != nil {
+		return fmt.Errorf("saving query: %w", err)
+	}
+	return nil
+}
+
+// GetSavedQuery looks a saved query up by name.
+func (db *DB) GetSavedQuery(name string) (*models.SavedQuery, error) {
+	if err := assert.Check(name != "", "query name must not be empty"); err != nil {
+		return nil, err
+	}
+	var q models.SavedQuery
+	var createdAt, updatedAt string
+	err := db.conn.QueryRow(`SELECT name, expr, created_at, updated_at FROM saved_queries WHERE name = ?`, name).
+		Scan(&q.Name, &q.Expr, &createdAt, &updatedAt)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("%w: %s", ErrQueryNotFound, name)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("querying saved query: %w", err)
+	}
+	q.CreatedAt, _ = time.Parse(time.RFC3339Nano, createdAt)
+	q.UpdatedAt, _ = time.Parse(time.RFC3339Nano, updatedAt)
+	return &q, nil
+}
+
+// ListSavedQueries returns all saved queries by name.
+func (db *DB) ListSavedQueries() (queries []models.SavedQuery, err error) {
+	rows, err := db.conn.Query(`SELECT name, expr, created_at, updated_at FROM saved_queries ORDER BY name ASC`)
+	if err != nil {
+		return nil, fmt.Errorf("querying saved queries: %w", err)
+	}
+	defer func() {
+		if closeErr := rows.Close(); closeErr != nil && err == nil {
+			err = fmt.Errorf("closing saved query rows: %w", closeErr)
+		}
+	}()
+
+	for i := 0; i < maxQueryRows; i++ {
+		if !rows.Next() {
+			break
+		}
+		var q models.SavedQuery
+		var createdAt, updatedAt string
+		if err := rows.Scan(&q.Name, &q.Expr, &createdAt, &updatedAt); err != nil {
+			return nil, fmt.Errorf("scanning saved query: %w", err)
+		}
+		q.CreatedAt, _ = time.Parse(time.RFC3339Nano, createdAt)
+		q.UpdatedAt, _ = time.Parse(time.RFC3339Nano, updatedAt)
+		queries = append(queries, q)
+	}
+	if err := assert.Check(rows.Err() == nil, "saved query rows error: %v", rows.Err()); err != nil {
+		return nil, err
+	}
+	return queries, nil
+}
+
+// DeleteSavedQuery removes a saved query.
+func (db *DB) DeleteSavedQuery(name string) error {
+	res, err := db.conn.Exec(`DELETE FROM saved_queries WHERE name = ?`, name)
+	if err != nil {
+		return fmt.Errorf("deleting saved query: %w", err)
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return fmt.Errorf("%w: %s", ErrQueryNotFound, name)
+	}
+	return nil
+}
@@ -0,0 +1,129 @@
+package store
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/slyt3/Logryph/internal/crypto"
+	"github.com/slyt3/Logryph/internal/ledger"
+	"github.com/slyt3/Logryph/internal/ledger/audit"
+	"github.com/slyt3/Logryph/internal/pool"
+)
+
+func TestRotationLinksRunsForVerification(t *testing.T) {
+	dir := t.TempDir()
+	dbPath, keyPath := filepath.Join(dir, "logryph.db"), filepath.Join(dir, "key")
+	db, err := NewDB(dbPath)
+	if err != nil {
+		t.Fatalf("failed to create database: %v", err)
+	}
+	worker, err := ledger.NewWorker(64, db, keyPath)
+	if err != nil {
+		t.Fatalf("NewWorker: %v", err)
+	}
+	if err := worker.SetRotation(ledger.RotationConfig{MaxEvents: 10}); err != nil {
+		t.Fatalf("SetRotation: %v", err)
+	}
+	if err := worker.Start(); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	for i := 0; i < 25; i++ {
+		event := pool.GetEvent()
+		event.ID = "e" + string(rune('a'+i))
+		event.Timestamp = time.Now()
+		event.Actor = "agent"
+		event.EventType = "tool_call"
+		event.Method = "os.read"
+		worker.Submit(event)
+	}
+	if err := worker.Shutdown(5 * time.Second); err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+
+	db, err = NewDB(dbPath)
+	if err != nil {
+		t.Fatalf("reopening database: %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	signer, err := crypto.NewSigner(keyPath)
+	if err != nil {
+		t.Fatalf("signer: %v", err)
+	}
+	runID, err := db.GetRunID()
+	if err != nil {
+		t.Fatalf("GetRunID: %v", err)
+	}
+	result, err := audit.VerifyLinkedRuns(db, runID, signer)
+	if err != nil || !result.Valid || len(result.Runs) != 3 {
+		t.Fatalf("VerifyLinkedRuns = %+v, %v; want 3 valid runs", result, err)
+	}
+	first := result.Runs[2]
+	if first.Result.TotalEvents != 11 {
+		t.Errorf("first run has %d events, want 10 and its run_closed event", first.Result.TotalEvents)
+	}
+
+	if _, err := db.conn.Exec(`DELETE FROM events WHERE run_id = ? AND event_type = ?`, first.RunID, audit.EventTypeRunClosed); err != nil {
+		t.Fatalf("deleting run_closed: %v", err)
+	}
+	result, err = audit.VerifyLinkedRuns(db, runID, signer)
+	if err != nil || result.Valid {
+		t.Errorf("a run truncated before its run_closed event should fail: %+v, %v", result, err)
+	}
+}
+
+func TestGenesisRecordsAgentIdentity(t *testing.T) {
+	dir := t.TempDir()
+	dbPath, keyPath := filepath.Join(dir, "logryph.db"), filepath.Join(dir, "key")
+	db, err := NewDB(dbPath)
+	if err != nil {
+		t.Fatalf("failed to create database: %v", err)
+	}
+	worker, err := ledger.NewWorker(64, db, keyPath)
+	if err != nil {
+		t.Fatalf("NewWorker: %v", err)
+	}
+	if err := worker.SetGenesis(ledger.GenesisConfig{AgentName: "billing-agent", Operator: "payments@example.com", Labels: map[string]string{"team": "payments"}}); err != nil {
+		t.Fatalf("SetGenesis: %v", err)
+	}
+	if err := worker.SetEnvironment("prod"); err != nil {
+		t.Fatalf("SetEnvironment: %v", err)
+	}
+	if err := worker.SetGenesis(ledger.GenesisConfig{Labels: map[string]string{"": "x"}}); err == nil {
+		t.Error("SetGenesis accepted an empty label key")
+	}
+	if err := worker.Start(); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	if err := worker.Shutdown(5 * time.Second); err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+
+	db, err = NewDB(dbPath)
+	if err != nil {
+		t.Fatalf("reopening database: %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	runID, err := db.GetRunID()
+	if err != nil {
+		t.Fatalf("GetRunID: %v", err)
+	}
+	agent, _, _, err := db.GetRunInfo(runID)
+	if err != nil || agent != "billing-agent" {
+		t.Errorf("run agent = %q, %v", agent, err)
+	}
+	id, err := db.GetRunIdentity(runID)
+	if err != nil {
+		t.Fatalf("GetRunIdentity: %v", err)
+	}
+	if id.AgentName != "billing-agent" || id.Operator != "payments@example.com" || id.Environment != "prod" || id.Labels["team"] != "payments" {
+		t.Errorf("identity = %+v", id)
+	}
+	signer, err := crypto.NewSigner(keyPath)
+	if err != nil {
+		t.Fatalf("signer: %v", err)
+	}
+	if result, err := audit.VerifyLinkedRuns(db, runID, signer); err != nil || !result.Valid {
+		t.Errorf("genesis with metadata does not verify: %+v, %v", result, err)
+	}
+}
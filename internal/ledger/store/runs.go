@@ -5,6 +5,7 @@ import (
 	"fmt"
 
 	"github.com/slyt3/Logryph/internal/assert"
+	"github.com/slyt3/Logryph/internal/ledger"
 )
 
 // InsertRun creates a new run record
@@ -47,7 +48,7 @@ func (db *DB) HasRuns() (bool, error) {
 // GetRunID retrieves the most recent run ID
 func (db *DB) GetRunID() (string, error) {
 	var runID string
-	err := db.conn.QueryRow("SELECT id FROM runs ORDER BY started_at DESC LIMIT 1").Scan(&runID)
+	err := db.conn.QueryRow("SELECT id FROM runs ORDER BY started_at DESC, rowid DESC LIMIT 1").Scan(&runID)
 	if err == sql.ErrNoRows {
 		return "", nil
 	}
@@ -69,3 +70,17 @@ func (db *DB) GetRunInfo(runID string) (agentName, genesisHash, pubKey string, e
 	}
 	return agentName, genesisHash, pubKey, nil
 }
+
+// GetRunIdentity reads the agent name, operator, environment and labels from the run's
+// genesis event. Runs started before the genesis section existed report the agent name only.
+func (db *DB) GetRunIdentity(runID string) (*ledger.RunIdentity, error) {
+	events, err := db.GetEventsFrom(runID, 0, 1)
+	if err != nil {
+		return nil, fmt.Errorf("reading genesis: %w", err)
+	}
+	if len(events) == 0 {
+		return nil, fmt.Errorf("run %s has no genesis event", runID)
+	}
+	id := ledger.IdentityFromGenesis(&events[0])
+	return &id, nil
+}
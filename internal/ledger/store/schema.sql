@@ -22,10 +22,138 @@ CREATE TABLE IF NOT EXISTS events (
     parent_id TEXT,      -- UUID of the parent event (if any)
     policy_id TEXT,      -- Matched policy ID
     risk_level TEXT,     -- low | medium | high | critical
+    environment TEXT DEFAULT '', -- dev | staging | prod (deployment profile)
+    tags TEXT DEFAULT '', -- JSON array of detector tags (e.g. schema_violation)
+    labels TEXT DEFAULT '', -- JSON object of key=value labels (e.g. {"team":"payments"})
+    correlation_id TEXT DEFAULT '', -- X-Logryph-Request-ID shared by a call and its response
+    trace_id TEXT DEFAULT '', -- W3C trace-id from the caller's traceparent
+    span_id TEXT DEFAULT '',  -- caller's span id from traceparent
+    headers TEXT DEFAULT '',  -- JSON object of captured HTTP headers (credentials redacted)
+    query_params TEXT DEFAULT '', -- JSON object of the captured request query string
     prev_hash TEXT,
     current_hash TEXT,
     signature TEXT,
+    params_hash TEXT DEFAULT '', -- SHA-256 of the canonical {method, params} (crypto.PayloadHash)
     FOREIGN KEY(run_id) REFERENCES runs(id)
 );
 
 CREATE INDEX IF NOT EXISTS idx_events_run_id ON events(run_id);
+CREATE INDEX IF NOT EXISTS idx_events_current_hash ON events(current_hash);
+-- idx_events_params_hash is created in migrate.go, after older ledgers gain the column.
+
+-- Index of annotation events; the signed events in the chain remain the evidence.
+CREATE TABLE IF NOT EXISTS annotations (
+    id TEXT PRIMARY KEY, -- ID of the annotation event
+    event_id TEXT,       -- annotated event
+    author TEXT,
+    note TEXT,
+    created_at TEXT
+);
+
+CREATE INDEX IF NOT EXISTS idx_annotations_event_id ON annotations(event_id);
+
+-- Effective labels per event, for filtering. Set from the event's own labels and from
+-- annotations on it; a later annotation replaces the value of the same key.
+CREATE TABLE IF NOT EXISTS event_labels (
+    event_id TEXT,
+    key TEXT,
+    value TEXT,
+    source_id TEXT,      -- event that set the label (the event itself or an annotation)
+    PRIMARY KEY(event_id, key)
+);
+
+CREATE INDEX IF NOT EXISTS idx_event_labels_key_value ON event_labels(key, value);
+
+-- Investigations grouping events and tasks across runs (investigator metadata, not chained).
+CREATE TABLE IF NOT EXISTS cases (
+    id TEXT PRIMARY KEY,
+    name TEXT UNIQUE,
+    created_at TEXT
+);
+
+CREATE TABLE IF NOT EXISTS case_items (
+    case_id TEXT,
+    kind TEXT,           -- event | task
+    ref TEXT,            -- event ID or task ID
+    added_at TEXT,
+    PRIMARY KEY(case_id, kind, ref),
+    FOREIGN KEY(case_id) REFERENCES cases(id)
+);
+
+-- Named event queries saved with logyctl query save (operator metadata, not chained).
+CREATE TABLE IF NOT EXISTS saved_queries (
+    name TEXT PRIMARY KEY,
+    expr TEXT,
+    created_at TEXT,
+    updated_at TEXT
+);
+
+-- Running totals kept in step with events by the triggers below, so stats never scan the
+-- events table. Deleted rows are subtracted too, so a lost event still shows as a gap.
+-- Ledgers created before these tables existed are filled once by rebuildStats.
+CREATE TABLE IF NOT EXISTS stats_runs (
+    run_id TEXT PRIMARY KEY,
+    events INTEGER DEFAULT 0,
+    calls INTEGER DEFAULT 0,
+    blocked INTEGER DEFAULT 0,
+    acknowledged_missing INTEGER DEFAULT 0 -- sequence indexes covered by gap_acknowledged events
+);
+
+CREATE TABLE IF NOT EXISTS stats_risk (
+    run_id TEXT,
+    risk_level TEXT,
+    events INTEGER DEFAULT 0,
+    PRIMARY KEY(run_id, risk_level)
+);
+
+CREATE TABLE IF NOT EXISTS stats_methods (
+    run_id TEXT,
+    method TEXT,
+    events INTEGER DEFAULT 0,
+    calls INTEGER DEFAULT 0,
+    blocked INTEGER DEFAULT 0,
+    PRIMARY KEY(run_id, method)
+);
+
+CREATE TABLE IF NOT EXISTS stats_days (
+    day TEXT PRIMARY KEY, -- YYYY-MM-DD prefix of the event timestamp
+    events INTEGER DEFAULT 0,
+    calls INTEGER DEFAULT 0,
+    blocked INTEGER DEFAULT 0,
+    critical INTEGER DEFAULT 0
+);
+
+CREATE TRIGGER IF NOT EXISTS trg_events_stats_insert AFTER INSERT ON events BEGIN
+    INSERT INTO stats_runs (run_id, events, calls, blocked, acknowledged_missing)
+        VALUES (NEW.run_id, 1, NEW.event_type = 'tool_call', NEW.event_type = 'blocked',
+                CASE WHEN NEW.event_type = 'gap_acknowledged' THEN COALESCE(CAST(json_extract(NEW.params, '$.missing_count') AS INTEGER), 0) ELSE 0 END)
+        ON CONFLICT(run_id) DO UPDATE SET events = events + 1, calls = calls + excluded.calls,
+            blocked = blocked + excluded.blocked, acknowledged_missing = acknowledged_missing + excluded.acknowledged_missing;
+    INSERT INTO stats_risk (run_id, risk_level, events)
+        SELECT NEW.run_id, NEW.risk_level, 1 WHERE COALESCE(NEW.risk_level, '') != ''
+        ON CONFLICT(run_id, risk_level) DO UPDATE SET events = events + 1;
+    INSERT INTO stats_methods (run_id, method, events, calls, blocked)
+        VALUES (NEW.run_id, COALESCE(NULLIF(CASE WHEN json_valid(NEW.labels) THEN json_extract(NEW.labels, '$.canonical_method') END, ''), NEW.method, ''),
+                1, NEW.event_type = 'tool_call', NEW.event_type = 'blocked')
+        ON CONFLICT(run_id, method) DO UPDATE SET events = events + 1, calls = calls + excluded.calls, blocked = blocked + excluded.blocked;
+    INSERT INTO stats_days (day, events, calls, blocked, critical)
+        VALUES (substr(NEW.timestamp, 1, 10), 1, NEW.event_type = 'tool_call', NEW.event_type = 'blocked', NEW.risk_level = 'critical')
+        ON CONFLICT(day) DO UPDATE SET events = events + 1, calls = calls + excluded.calls,
+            blocked = blocked + excluded.blocked, critical = critical + excluded.critical;
+END;
+
+CREATE TRIGGER IF NOT EXISTS trg_events_stats_delete AFTER DELETE ON events BEGIN
+    UPDATE stats_runs SET events = events - 1, calls = calls - (OLD.event_type = 'tool_call'),
+        blocked = blocked - (OLD.event_type = 'blocked'),
+        acknowledged_missing = acknowledged_missing - CASE WHEN OLD.event_type = 'gap_acknowledged'
+            THEN COALESCE(CAST(json_extract(OLD.params, '$.missing_count') AS INTEGER), 0) ELSE 0 END
+        WHERE run_id = OLD.run_id;
+    UPDATE stats_risk SET events = events - 1 WHERE run_id = OLD.run_id AND risk_level = OLD.risk_level;
+    UPDATE stats_methods SET events = events - 1, calls = calls - (OLD.event_type = 'tool_call'),
+        blocked = blocked - (OLD.event_type = 'blocked')
+        WHERE run_id = OLD.run_id
+          AND method = COALESCE(NULLIF(CASE WHEN json_valid(OLD.labels) THEN json_extract(OLD.labels, '$.canonical_method') END, ''), OLD.method, '');
+    UPDATE stats_days SET events = events - 1, calls = calls - (OLD.event_type = 'tool_call'),
+        blocked = blocked - (OLD.event_type = 'blocked'), critical = critical - (OLD.risk_level = 'critical')
+        WHERE day = substr(OLD.timestamp, 1, 10);
+END;
@@ -6,14 +6,12 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
-
-	_ "github.com/mattn/go-sqlite3"
 )
 
 //go:embed schema.sql
 var schemaSQL string
 
-// DB wraps the SQLite database connection
+// DB wraps the SQLite database connection. The driver is chosen at build time; see DriverName.
 type DB struct {
 	conn *sql.DB
 }
@@ -27,7 +25,7 @@ func NewDB(dbPath string) (*DB, error) {
 	}
 
 	// Open database connection
-	conn, err := sql.Open("sqlite3", dbPath)
+	conn, err := sql.Open(DriverName, dsn(dbPath))
 	if err != nil {
 		return nil, fmt.Errorf("opening database: %w", err)
 	}
@@ -48,6 +46,14 @@ func NewDB(dbPath string) (*DB, error) {
 		return nil, fmt.Errorf("executing schema: %w", err)
 	}
 
+	// Bring ledgers created by older releases up to the current layout
+	if err := migrate(conn); err != nil {
+		if closeErr := conn.Close(); closeErr != nil {
+			return nil, fmt.Errorf("migrating schema: %v; closing database: %w", err, closeErr)
+		}
+		return nil, fmt.Errorf("migrating schema: %w", err)
+	}
+
 	return &DB{conn: conn}, nil
 }
 
@@ -55,3 +61,16 @@ func NewDB(dbPath string) (*DB, error) {
 func (db *DB) Close() error {
 	return db.conn.Close()
 }
+
+// Snapshot writes a transactionally consistent copy of the database to dest, including
+// changes still in the WAL. Writers are not blocked while it runs, so the proxy can keep
+// recording during an export. dest must not exist yet.
+func (db *DB) Snapshot(dest string) error {
+	if _, err := os.Stat(dest); err == nil {
+		return fmt.Errorf("snapshot target %s already exists", dest)
+	}
+	if _, err := db.conn.Exec(`VACUUM INTO ?`, dest); err != nil {
+		return fmt.Errorf("snapshotting database: %w", err)
+	}
+	return nil
+}
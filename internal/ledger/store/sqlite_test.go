@@ -1,10 +1,16 @@
 package store
 
 import (
+	"errors"
+	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 	"time"
+
+	"github.com/slyt3/Logryph/internal/crypto"
+	"github.com/slyt3/Logryph/internal/models"
 )
 
 func TestDB(t *testing.T) {
@@ -124,3 +130,399 @@ func TestDB(t *testing.T) {
 		t.Errorf("Expected event ID %s, got %s", eventID, event.ID)
 	}
 }
+
+func TestStoreEvent_EnvironmentAndTags(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "logryph-test-*")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	t.Cleanup(func() {
+		if err := os.RemoveAll(tmpDir); err != nil {
+			t.Errorf("Failed to remove temp dir: %v", err)
+		}
+	})
+
+	db, err := NewDB(filepath.Join(tmpDir, "logryph.db"))
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	t.Cleanup(func() {
+		if err := db.Close(); err != nil {
+			t.Errorf("Failed to close database: %v", err)
+		}
+	})
+
+	event := &models.Event{
+		ID: "event-tags", RunID: "run-1", SeqIndex: 1, Timestamp: time.Now(),
+		Actor: "agent", EventType: "tool_call", Method: "db:query",
+		Environment: "prod", Tags: []string{"schema_violation"},
+		TraceID: "4bf92f3577b34da6a3ce929d0e0e4736", SpanID: "00f067aa0ba902b7",
+		Headers:     map[string]string{"X-Tool-Route": "eu-1"},
+		QueryParams: map[string]string{"page": "2"},
+		PrevHash:    "genesis-hash", CurrentHash: "hash-1", Signature: "sig-1",
+	}
+	if err := db.StoreEvent(event); err != nil {
+		t.Fatalf("StoreEvent failed: %v", err)
+	}
+
+	got, err := db.GetEventByID("event-tags")
+	if err != nil {
+		t.Fatalf("GetEventByID failed: %v", err)
+	}
+	if got.Environment != "prod" {
+		t.Errorf("Expected environment prod, got %q", got.Environment)
+	}
+	if !got.HasTag("schema_violation") || len(got.Tags) != 1 {
+		t.Errorf("Expected tags [schema_violation], got %v", got.Tags)
+	}
+	if got.Headers["X-Tool-Route"] != "eu-1" || got.QueryParams["page"] != "2" {
+		t.Errorf("Expected captured headers and query params, got %v %v", got.Headers, got.QueryParams)
+	}
+	traced, err := db.GetEventsByTraceID("4bf92f3577b34da6a3ce929d0e0e4736")
+	if err != nil || len(traced) != 1 || traced[0].SpanID != "00f067aa0ba902b7" {
+		t.Errorf("Expected the event under its trace ID, got %v (%v)", traced, err)
+	}
+}
+
+func TestStoreEvent_IndexesAnnotations(t *testing.T) {
+	db, err := NewDB(filepath.Join(t.TempDir(), "logryph.db"))
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	t.Cleanup(func() {
+		if err := db.Close(); err != nil {
+			t.Errorf("Failed to close database: %v", err)
+		}
+	})
+
+	event := &models.Event{
+		ID: "note-1", RunID: "run-1", SeqIndex: 1, Timestamp: time.Now(),
+		Actor: "user", EventType: models.EventTypeAnnotation, Method: "logryph:annotate",
+		ParentID: "event-1", Params: map[string]interface{}{"author": "alice", "note": "benign"},
+		PrevHash: "genesis-hash", CurrentHash: "hash-1", Signature: "sig-1",
+	}
+	if err := db.StoreEvent(event); err != nil {
+		t.Fatalf("StoreEvent failed: %v", err)
+	}
+
+	annotations, err := db.GetAnnotations("event-1")
+	if err != nil {
+		t.Fatalf("GetAnnotations failed: %v", err)
+	}
+	if len(annotations) != 1 || annotations[0].ID != "note-1" || annotations[0].Note != "benign" {
+		t.Errorf("Unexpected annotations: %+v", annotations)
+	}
+}
+
+func TestNewDB_ConnectionSettings(t *testing.T) {
+	db, err := NewDB(filepath.Join(t.TempDir(), "logryph.db"))
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	t.Cleanup(func() {
+		if err := db.Close(); err != nil {
+			t.Errorf("Failed to close database: %v", err)
+		}
+	})
+
+	// Both drivers must behave the same under concurrent writers.
+	var mode string
+	var timeout int
+	if err := db.conn.QueryRow("PRAGMA journal_mode").Scan(&mode); err != nil || mode != "wal" {
+		t.Errorf("journal_mode = %q, %v (driver %s)", mode, err, DriverName)
+	}
+	if err := db.conn.QueryRow("PRAGMA busy_timeout").Scan(&timeout); err != nil || timeout != 5000 {
+		t.Errorf("busy_timeout = %d, %v (driver %s)", timeout, err, DriverName)
+	}
+}
+
+func TestCases(t *testing.T) {
+	db, err := NewDB(filepath.Join(t.TempDir(), "logryph.db"))
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	t.Cleanup(func() {
+		if err := db.Close(); err != nil {
+			t.Errorf("Failed to close database: %v", err)
+		}
+	})
+
+	if err := db.CreateCase(models.Case{ID: "c1", Name: "incident-42", CreatedAt: time.Now()}); err != nil {
+		t.Fatalf("CreateCase failed: %v", err)
+	}
+	if err := db.CreateCase(models.Case{ID: "c2", Name: "incident-42", CreatedAt: time.Now()}); err == nil {
+		t.Error("Expected duplicate case name to be rejected")
+	}
+
+	c, err := db.GetCase("incident-42")
+	if err != nil || c.ID != "c1" {
+		t.Fatalf("GetCase by name failed: %v %+v", err, c)
+	}
+	if _, err := db.GetCase("missing"); !errors.Is(err, ErrCaseNotFound) {
+		t.Errorf("Expected ErrCaseNotFound, got %v", err)
+	}
+
+	for _, item := range []models.CaseItem{
+		{CaseID: "c1", Kind: models.CaseItemEvent, Ref: "event-1", AddedAt: time.Now()},
+		{CaseID: "c1", Kind: models.CaseItemTask, Ref: "task-9", AddedAt: time.Now()},
+		{CaseID: "c1", Kind: models.CaseItemEvent, Ref: "event-1", AddedAt: time.Now()},
+	} {
+		if err := db.AddCaseItem(item); err != nil {
+			t.Fatalf("AddCaseItem failed: %v", err)
+		}
+	}
+	items, err := db.GetCaseItems("c1")
+	if err != nil {
+		t.Fatalf("GetCaseItems failed: %v", err)
+	}
+	if len(items) != 2 || items[0].Ref != "event-1" || items[1].Kind != models.CaseItemTask {
+		t.Errorf("Unexpected case items: %+v", items)
+	}
+}
+
+func TestSavedQueries(t *testing.T) {
+	db, err := NewDB(filepath.Join(t.TempDir(), "logryph.db"))
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	t.Cleanup(func() {
+		if err := db.Close(); err != nil {
+			t.Errorf("Failed to close database: %v", err)
+		}
+	})
+
+	first := time.Now().Add(-time.Hour)
+	for _, q := range []models.SavedQuery{
+		{Name: "high-spend", Expr: "params.amount > 1000", UpdatedAt: first},
+		{Name: "denied", Expr: "blocked", UpdatedAt: first},
+		{Name: "high-spend", Expr: "params.amount > 5000", UpdatedAt: time.Now()},
+	} {
+		if err := db.SaveQuery(q); err != nil {
+			t.Fatalf("SaveQuery failed: %v", err)
+		}
+	}
+	q, err := db.GetSavedQuery("high-spend")
+	if err != nil || q.Expr != "params.amount > 5000" || !q.UpdatedAt.After(q.CreatedAt) {
+		t.Fatalf("Saving again should replace the expression: %v %+v", err, q)
+	}
+	list, err := db.ListSavedQueries()
+	if err != nil || len(list) != 2 || list[0].Name != "denied" {
+		t.Errorf("Unexpected saved queries: %v %+v", err, list)
+	}
+	if err := db.DeleteSavedQuery("denied"); err != nil {
+		t.Fatalf("DeleteSavedQuery failed: %v", err)
+	}
+	if _, err := db.GetSavedQuery("denied"); !errors.Is(err, ErrQueryNotFound) {
+		t.Errorf("Expected ErrQueryNotFound, got %v", err)
+	}
+	if err := db.DeleteSavedQuery("denied"); !errors.Is(err, ErrQueryNotFound) {
+		t.Errorf("Deleting a missing query should fail, got %v", err)
+	}
+}
+
+func TestEventLabels(t *testing.T) {
+	db, err := NewDB(filepath.Join(t.TempDir(), "logryph.db"))
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	t.Cleanup(func() {
+		if err := db.Close(); err != nil {
+			t.Errorf("Failed to close database: %v", err)
+		}
+	})
+
+	events := []*models.Event{
+		{ID: "call-1", EventType: "tool_call", Method: "stripe:charge", RiskLevel: "high",
+			Labels: map[string]string{"team": "payments", "tier": "1"}},
+		{ID: "call-2", EventType: "tool_call", Method: "fs:read", Labels: map[string]string{"team": "infra"}},
+		{ID: "note-1", EventType: models.EventTypeAnnotation, Method: "logryph:annotate", ParentID: "call-2",
+			Params: map[string]interface{}{"note": "owned by payments"}, Labels: map[string]string{"team": "payments"}},
+	}
+	for i, e := range events {
+		e.RunID, e.SeqIndex, e.Timestamp, e.Actor = "run-1", uint64(i+1), time.Now(), "agent"
+		e.PrevHash, e.CurrentHash, e.Signature = "prev", "hash", "sig"
+		if err := db.StoreEvent(e); err != nil {
+			t.Fatalf("StoreEvent %s failed: %v", e.ID, err)
+		}
+	}
+
+	got, err := db.GetEventByID("call-1")
+	if err != nil || got.Labels["team"] != "payments" {
+		t.Fatalf("Expected labels to round-trip, got %v (%v)", got, err)
+	}
+
+	// The annotation relabels call-2; the annotation event itself is not labeled.
+	labeled, err := db.GetEventsByLabels("run-1", map[string]string{"team": "payments"}, 10)
+	if err != nil {
+		t.Fatalf("GetEventsByLabels failed: %v", err)
+	}
+	if len(labeled) != 2 || labeled[0].ID != "call-2" || labeled[1].ID != "call-1" {
+		t.Errorf("Unexpected labeled events: %+v", labeled)
+	}
+
+	stats, err := db.GetLabelStats(map[string]string{"team": "payments", "tier": "1"})
+	if err != nil {
+		t.Fatalf("GetLabelStats failed: %v", err)
+	}
+	if stats.TotalEvents != 1 || stats.CallCount != 1 || stats.RiskBreakdown["high"] != 1 {
+		t.Errorf("Unexpected label stats: %+v", stats)
+	}
+}
+
+func TestSnapshot(t *testing.T) {
+	dir := t.TempDir()
+	db, err := NewDB(filepath.Join(dir, "logryph.db"))
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	t.Cleanup(func() {
+		if err := db.Close(); err != nil {
+			t.Errorf("Failed to close database: %v", err)
+		}
+	})
+	event := &models.Event{
+		ID: "event-snap", RunID: "run-1", SeqIndex: 1, Timestamp: time.Now(),
+		Actor: "agent", EventType: "tool_call", Method: "fs:read",
+		PrevHash: "genesis-hash", CurrentHash: "hash-1", Signature: "sig-1",
+	}
+	if err := db.StoreEvent(event); err != nil {
+		t.Fatalf("StoreEvent failed: %v", err)
+	}
+
+	// The event is still in the WAL while the connection is open; the snapshot must have it.
+	snapPath := filepath.Join(dir, "snapshot.db")
+	if err := db.Snapshot(snapPath); err != nil {
+		t.Fatalf("Snapshot failed: %v", err)
+	}
+	snap, err := NewDB(snapPath)
+	if err != nil {
+		t.Fatalf("Failed to open snapshot: %v", err)
+	}
+	defer snap.Close()
+	if got, err := snap.GetEventByID("event-snap"); err != nil || got.CurrentHash != "hash-1" {
+		t.Errorf("Snapshot is missing the event: %+v, %v", got, err)
+	}
+	if err := db.Snapshot(snapPath); err == nil {
+		t.Error("Snapshot must not overwrite an existing file")
+	}
+}
+
+func TestForEachEventReadsPastTheRowCap(t *testing.T) {
+	db, err := NewDB(filepath.Join(t.TempDir(), "logryph.db"))
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	total := maxEventRows + 5
+	tx, err := db.conn.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stmt, err := tx.Prepare(`INSERT INTO events (id, run_id, seq_index, timestamp, actor, event_type, method, params, response,
+		task_id, task_state, parent_id, policy_id, risk_level, prev_hash, current_hash, signature)
+		VALUES (?, 'run-1', ?, '', 'agent', 'tool_call', 'os.read', '{}', '{}', '', '', '', '', '', '', '', '')`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < total; i++ {
+		if _, err := stmt.Exec(fmt.Sprintf("e%d", i), i); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	if events, _ := db.GetAllEvents("run-1"); len(events) != maxEventRows {
+		t.Errorf("GetAllEvents returned %d events, want the %d cap", len(events), maxEventRows)
+	}
+	n := 0
+	err = db.ForEachEvent("run-1", func(e *models.Event) error {
+		if e.SeqIndex != uint64(n) {
+			return fmt.Errorf("event %d has seq %d", n, e.SeqIndex)
+		}
+		n++
+		return nil
+	})
+	if err != nil || n != total {
+		t.Errorf("ForEachEvent visited %d events (%v), want %d", n, err, total)
+	}
+
+	stop := errors.New("stop")
+	n = 0
+	err = db.ForEachEvent("run-1", func(e *models.Event) error {
+		if n++; n == 3 {
+			return stop
+		}
+		return nil
+	})
+	if !errors.Is(err, stop) || n != 3 {
+		t.Errorf("ForEachEvent should stop at the callback's error: %v after %d", err, n)
+	}
+}
+
+func TestHashLookups(t *testing.T) {
+	db, err := NewDB(filepath.Join(t.TempDir(), "logryph.db"))
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+
+	for i := 0; i < 3; i++ {
+		event := &models.Event{
+			ID: fmt.Sprintf("e%d", i), RunID: "run-1", SeqIndex: uint64(i), Timestamp: time.Now(),
+			Actor: "agent", EventType: "tool_call", Method: "os.read",
+			Params:   map[string]interface{}{"path": "/etc/hosts" + strings.Repeat(" ", i), "n": i % 2},
+			PrevHash: "prev", CurrentHash: fmt.Sprintf("hash-%d", i), Signature: "sig",
+		}
+		if err := db.StoreEvent(event); err != nil {
+			t.Fatalf("StoreEvent failed: %v", err)
+		}
+	}
+
+	if e, err := db.FindByHash("hash-1"); err != nil || e == nil || e.ID != "e1" {
+		t.Errorf("FindByHash(hash-1) = %+v, %v", e, err)
+	}
+	if e, err := db.FindByHash("missing"); err != nil || e != nil {
+		t.Errorf("FindByHash(missing) = %+v, %v; want nil, nil", e, err)
+	}
+
+	// Key order, number formatting and surrounding whitespace must not change the payload hash.
+	hash, err := crypto.PayloadHash("os.read", map[string]interface{}{"n": 0.0, "path": "/etc/hosts"})
+	if err != nil {
+		t.Fatalf("PayloadHash: %v", err)
+	}
+	events, err := db.FindByParamsHash(hash, 10)
+	if err != nil || len(events) != 2 || events[0].ID != "e0" || events[1].ID != "e2" {
+		t.Fatalf("FindByParamsHash = %+v, %v; want e0 and e2", events, err)
+	}
+
+	// Rows written before the column existed are filled in by the migration, a batch at
+	// a time.
+	defer func(n int) { paramsHashBatch = n }(paramsHashBatch)
+	paramsHashBatch = 2
+	if _, err := db.conn.Exec(`UPDATE events SET params_hash = ''`); err != nil {
+		t.Fatalf("clearing params_hash: %v", err)
+	}
+	if err := backfillParamsHashes(db.conn); err != nil {
+		t.Fatalf("backfillParamsHashes: %v", err)
+	}
+	if events, err := db.FindByParamsHash(hash, 10); err != nil || len(events) != 2 {
+		t.Errorf("after backfill FindByParamsHash = %d events, %v; want 2", len(events), err)
+	}
+
+	// Ledgers hashed before normalization are rehashed once, on open.
+	if _, err := db.conn.Exec(`UPDATE events SET params_hash = 'stale'; PRAGMA user_version = 0`); err != nil {
+		t.Fatalf("resetting hashes: %v", err)
+	}
+	if err := migrate(db.conn); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	var version int
+	if err := db.conn.QueryRow(`PRAGMA user_version`).Scan(&version); err != nil || version != len(dataMigrations) {
+		t.Errorf("user_version = %d, %v; want %d", version, err, len(dataMigrations))
+	}
+	if events, err := db.FindByParamsHash(hash, 10); err != nil || len(events) != 2 {
+		t.Errorf("after rehash FindByParamsHash = %d events, %v; want 2", len(events), err)
+	}
+}
@@ -1,12 +1,82 @@
 package store
 
 import (
+	"database/sql"
 	"fmt"
 
 	"github.com/slyt3/Logryph/internal/assert"
 	"github.com/slyt3/Logryph/internal/ledger"
 )
 
+const (
+	maxRiskLevels  = 32
+	maxMethodStats = 1000
+	maxDayStats    = 3660
+)
+
+// MethodStats counts one method's events in a run. Aliased methods are counted under
+// their canonical name.
+type MethodStats struct {
+	Method  string `json:"method"`
+	Events  uint64 `json:"events"`
+	Calls   uint64 `json:"calls"`
+	Blocked uint64 `json:"blocked"`
+}
+
+// DayStats counts the events of one day (the date part of their timestamps) across runs.
+type DayStats struct {
+	Day      string `json:"day"`
+	Events   uint64 `json:"events"`
+	Calls    uint64 `json:"calls"`
+	Blocked  uint64 `json:"blocked"`
+	Critical uint64 `json:"critical"`
+}
+
+// statsMethodExpr is the name stats_methods counts an event under: its canonical_method
+// label when method_aliases gave it one, else its method. The triggers in schema.sql use
+// the same expression.
+const statsMethodExpr = `COALESCE(NULLIF(CASE WHEN json_valid(labels) THEN json_extract(labels, '$.canonical_method') END, ''), method, '')`
+
+// rebuildStats recounts the stats tables from the events table, for ledgers written
+// before the tables existed. Rebuilding from scratch makes it safe to repeat.
+func rebuildStats(conn *sql.DB) error {
+	tx, err := conn.Begin()
+	if err != nil {
+		return err
+	}
+	stmts := []string{
+		`DELETE FROM stats_runs`,
+		`DELETE FROM stats_risk`,
+		`DELETE FROM stats_methods`,
+		`DELETE FROM stats_days`,
+		`INSERT INTO stats_runs (run_id, events, calls, blocked, acknowledged_missing)
+			SELECT run_id, COUNT(*),
+			       COALESCE(SUM(CASE WHEN event_type = 'tool_call' THEN 1 ELSE 0 END), 0),
+			       COALESCE(SUM(CASE WHEN event_type = 'blocked' THEN 1 ELSE 0 END), 0),
+			       COALESCE(SUM(CASE WHEN event_type = 'gap_acknowledged' THEN CAST(json_extract(params, '$.missing_count') AS INTEGER) ELSE 0 END), 0)
+			FROM events GROUP BY run_id`,
+		`INSERT INTO stats_risk (run_id, risk_level, events)
+			SELECT run_id, risk_level, COUNT(*) FROM events WHERE risk_level != '' GROUP BY run_id, risk_level`,
+		`INSERT INTO stats_methods (run_id, method, events, calls, blocked)
+			SELECT run_id, ` + statsMethodExpr + `, COUNT(*),
+			       COALESCE(SUM(CASE WHEN event_type = 'tool_call' THEN 1 ELSE 0 END), 0),
+			       COALESCE(SUM(CASE WHEN event_type = 'blocked' THEN 1 ELSE 0 END), 0)
+			FROM events GROUP BY run_id, ` + statsMethodExpr,
+		`INSERT INTO stats_days (day, events, calls, blocked, critical)
+			SELECT substr(timestamp, 1, 10), COUNT(*),
+			       COALESCE(SUM(CASE WHEN event_type = 'tool_call' THEN 1 ELSE 0 END), 0),
+			       COALESCE(SUM(CASE WHEN event_type = 'blocked' THEN 1 ELSE 0 END), 0),
+			       COALESCE(SUM(CASE WHEN risk_level = 'critical' THEN 1 ELSE 0 END), 0)
+			FROM events GROUP BY substr(timestamp, 1, 10)`,
+	}
+	for i := 0; i < len(stmts); i++ {
+		if _, err := tx.Exec(stmts[i]); err != nil {
+			return rollback(tx, err)
+		}
+	}
+	return tx.Commit()
+}
+
 // GetRunStats returns statistics for a specific run
 func (db *DB) GetRunStats(runID string) (stats *ledger.RunStats, err error) {
 	if err := assert.Check(runID != "", "runID must not be empty"); err != nil {
@@ -18,20 +88,17 @@ func (db *DB) GetRunStats(runID string) (stats *ledger.RunStats, err error) {
 	}
 
 	// Total and Blocked counts
-	err = db.conn.QueryRow(`
-		SELECT COUNT(*), 
-		       COALESCE(SUM(CASE WHEN event_type = 'blocked' THEN 1 ELSE 0 END), 0),
-		       COALESCE(SUM(CASE WHEN event_type = 'tool_call' THEN 1 ELSE 0 END), 0)
-		FROM events WHERE run_id = ?`, runID).Scan(&stats.TotalEvents, &stats.BlockedCount, &stats.CallCount)
+	err = db.conn.QueryRow(`SELECT events, blocked, calls, acknowledged_missing FROM stats_runs WHERE run_id = ?`, runID).
+		Scan(&stats.TotalEvents, &stats.BlockedCount, &stats.CallCount, &stats.AcknowledgedMissing)
+	if err == sql.ErrNoRows {
+		return stats, nil
+	}
 	if err != nil {
 		return nil, err
 	}
 
 	// Risk breakdown
-	rows, err := db.conn.Query(`
-		SELECT risk_level, COUNT(*) FROM events 
-		WHERE run_id = ? AND risk_level != '' 
-		GROUP BY risk_level`, runID)
+	rows, err := db.conn.Query(`SELECT risk_level, events FROM stats_risk WHERE run_id = ? AND events > 0`, runID)
 	if err != nil {
 		return nil, err
 	}
@@ -41,7 +108,6 @@ func (db *DB) GetRunStats(runID string) (stats *ledger.RunStats, err error) {
 		}
 	}()
 
-	const maxRiskLevels = 32
 	for i := 0; i < maxRiskLevels; i++ {
 		if !rows.Next() {
 			break
@@ -68,15 +134,80 @@ func (db *DB) GetGlobalStats() (*ledger.GlobalStats, error) {
 		return nil, err
 	}
 
-	err = db.conn.QueryRow(`SELECT COUNT(*) FROM events`).Scan(&stats.TotalEvents)
+	err = db.conn.QueryRow(`SELECT COALESCE(SUM(events), 0) FROM stats_runs`).Scan(&stats.TotalEvents)
 	if err != nil {
 		return nil, err
 	}
 
-	err = db.conn.QueryRow(`SELECT COUNT(*) FROM events WHERE risk_level = 'critical'`).Scan(&stats.CriticalCount)
+	err = db.conn.QueryRow(`SELECT COALESCE(SUM(events), 0) FROM stats_risk WHERE risk_level = 'critical'`).Scan(&stats.CriticalCount)
+	if err != nil {
+		return nil, err
+	}
+
+	return stats, nil
+}
+
+// GetMethodStats returns the event counts of a run's busiest methods, most events first.
+func (db *DB) GetMethodStats(runID string, limit int) (stats []MethodStats, err error) {
+	if err := assert.Check(runID != "", "runID must not be empty"); err != nil {
+		return nil, err
+	}
+	if limit <= 0 || limit > maxMethodStats {
+		limit = maxMethodStats
+	}
+	rows, err := db.conn.Query(`SELECT method, events, calls, blocked FROM stats_methods
+		WHERE run_id = ? AND events > 0 ORDER BY events DESC, method LIMIT ?`, runID, limit)
 	if err != nil {
+		return nil, fmt.Errorf("querying method stats: %w", err)
+	}
+	defer func() {
+		if closeErr := rows.Close(); closeErr != nil && err == nil {
+			err = fmt.Errorf("closing method stats rows: %w", closeErr)
+		}
+	}()
+	for i := 0; i < maxMethodStats; i++ {
+		if !rows.Next() {
+			break
+		}
+		var m MethodStats
+		if err := rows.Scan(&m.Method, &m.Events, &m.Calls, &m.Blocked); err != nil {
+			return nil, fmt.Errorf("scanning method stats: %w", err)
+		}
+		stats = append(stats, m)
+	}
+	if err := assert.Check(rows.Err() == nil, "method stats rows error: %v", rows.Err()); err != nil {
 		return nil, err
 	}
+	return stats, nil
+}
 
+// GetDailyStats returns event counts for the last days days that have events, oldest first.
+func (db *DB) GetDailyStats(days int) (stats []DayStats, err error) {
+	if days <= 0 || days > maxDayStats {
+		days = maxDayStats
+	}
+	rows, err := db.conn.Query(`SELECT day, events, calls, blocked, critical FROM
+		(SELECT * FROM stats_days WHERE events > 0 ORDER BY day DESC LIMIT ?) ORDER BY day`, days)
+	if err != nil {
+		return nil, fmt.Errorf("querying daily stats: %w", err)
+	}
+	defer func() {
+		if closeErr := rows.Close(); closeErr != nil && err == nil {
+			err = fmt.Errorf("closing daily stats rows: %w", closeErr)
+		}
+	}()
+	for i := 0; i < maxDayStats; i++ {
+		if !rows.Next() {
+			break
+		}
+		var d DayStats
+		if err := rows.Scan(&d.Day, &d.Events, &d.Calls, &d.Blocked, &d.Critical); err != nil {
+			return nil, fmt.Errorf("scanning daily stats: %w", err)
+		}
+		stats = append(stats, d)
+	}
+	if err := assert.Check(rows.Err() == nil, "daily stats rows error: %v", rows.Err()); err != nil {
+		return nil, err
+	}
 	return stats, nil
 }
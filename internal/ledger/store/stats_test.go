@@ -1,10 +1,15 @@
 package store
 
 import (
+	"fmt"
 	"os"
 	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
 	"time"
+
+	"github.com/slyt3/Logryph/internal/models"
 )
 
 func TestStats(t *testing.T) {
@@ -78,3 +83,138 @@ func TestStats(t *testing.T) {
 		t.Errorf("Expected 2 risky events, got %d", len(risky))
 	}
 }
+
+func TestGetRiskEventsPage(t *testing.T) {
+	db, err := NewDB(filepath.Join(t.TempDir(), "logryph.db"))
+	if err != nil {
+		t.Fatalf("failed to create database: %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	_ = db.InsertRun("run-1", "agent-1", "gen-hash", "pub-key")
+
+	start := time.Now().Add(-10 * time.Hour)
+	levels := []string{"low", "high", "critical", "medium", "critical", "high", "critical"}
+	for i, level := range levels {
+		ts := start.Add(time.Duration(i) * time.Hour).Format(time.RFC3339Nano)
+		id := fmt.Sprintf("e%d", i)
+		if err := db.InsertEvent(id, "run-1", uint64(i), ts, "agent", "tool_call", "os.exec", "{}", "{}", "", "", "", "", level, "h", "h"+id, "s"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var ids []string
+	q := models.RiskQuery{Levels: []string{"high", "critical"}, Limit: 2}
+	for page := 0; page < 5; page++ {
+		events, next, err := db.GetRiskEventsPage(q)
+		if err != nil {
+			t.Fatalf("GetRiskEventsPage: %v", err)
+		}
+		for _, e := range events {
+			ids = append(ids, e.ID)
+		}
+		if next == "" {
+			break
+		}
+		q.After = next
+	}
+	if got := strings.Join(ids, ","); got != "e6,e5,e4,e2,e1" {
+		t.Errorf("paged high and critical events = %s, want newest first e6,e5,e4,e2,e1", got)
+	}
+
+	events, next, err := db.GetRiskEventsPage(models.RiskQuery{Levels: []string{"critical"}, Since: start.Add(3 * time.Hour), Limit: 10})
+	if err != nil || next != "" || len(events) != 2 || events[0].ID != "e6" || events[1].ID != "e4" {
+		t.Errorf("critical since +3h: %v, next %q, err %v", events, next, err)
+	}
+}
+
+func TestStatsTablesFollowEvents(t *testing.T) {
+	db, err := NewDB(filepath.Join(t.TempDir(), "logryph.db"))
+	if err != nil {
+		t.Fatalf("failed to create database: %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	_ = db.InsertRun("run-1", "agent-1", "gen-hash", "pub-key")
+
+	day1, day2 := "2026-03-01T10:00:00Z", "2026-03-02T10:00:00Z"
+	_ = db.InsertEvent("e0", "run-1", 0, day1, "system", "genesis", "logryph:init", "{}", "{}", "", "", "", "", "", "000", "h0", "s")
+	_ = db.InsertEvent("e1", "run-1", 1, day1, "agent", "tool_call", "os.read", "{}", "{}", "", "", "", "", "low", "h0", "h1", "s")
+	_ = db.InsertEvent("e2", "run-1", 2, day2, "agent", "tool_call", "os.exec", "{}", "{}", "", "", "", "", "critical", "h1", "h2", "s")
+	_ = db.InsertEvent("e3", "run-1", 3, day2, "agent", "blocked", "os.exec", "{}", "{}", "", "", "", "", "critical", "h2", "h3", "s")
+	_ = db.InsertEvent("e4", "run-1", 5, day2, "system", "gap_acknowledged", "logryph:gap", `{"missing_count":1}`, "{}", "", "", "", "", "", "h3", "h5", "s")
+
+	methods, err := db.GetMethodStats("run-1", 10)
+	if err != nil || len(methods) != 4 || methods[0] != (MethodStats{Method: "os.exec", Events: 2, Calls: 1, Blocked: 1}) {
+		t.Errorf("method stats = %+v, %v", methods, err)
+	}
+	days, err := db.GetDailyStats(30)
+	if err != nil || len(days) != 2 || days[1] != (DayStats{Day: "2026-03-02", Events: 3, Calls: 1, Blocked: 1, Critical: 2}) {
+		t.Errorf("daily stats = %+v, %v", days, err)
+	}
+	stats, err := db.GetRunStats("run-1")
+	if err != nil || stats.TotalEvents != 5 || stats.AcknowledgedMissing != 1 || stats.RiskBreakdown["critical"] != 2 {
+		t.Errorf("run stats = %+v, %v", stats, err)
+	}
+
+	// A row removed behind the ledger's back is subtracted, so the processor still sees the gap.
+	if _, err := db.conn.Exec(`DELETE FROM events WHERE id = 'e2'`); err != nil {
+		t.Fatal(err)
+	}
+	after, err := db.GetRunStats("run-1")
+	if err != nil || after.TotalEvents != 4 || after.CallCount != 1 || after.RiskBreakdown["critical"] != 1 {
+		t.Errorf("run stats after delete = %+v, %v", after, err)
+	}
+	global, err := db.GetGlobalStats()
+	if err != nil || global.TotalEvents != 4 || global.CriticalCount != 1 {
+		t.Errorf("global stats after delete = %+v, %v", global, err)
+	}
+
+	// Rebuilding from the events table, as older ledgers are, gives the same counts.
+	if err := rebuildStats(db.conn); err != nil {
+		t.Fatalf("rebuildStats: %v", err)
+	}
+	rebuilt, err := db.GetRunStats("run-1")
+	if err != nil || !reflect.DeepEqual(rebuilt, after) {
+		t.Errorf("rebuilt stats = %+v, want %+v (%v)", rebuilt, after, err)
+	}
+}
+
+func TestMethodStatsUseCanonicalMethod(t *testing.T) {
+	db, err := NewDB(filepath.Join(t.TempDir(), "logryph.db"))
+	if err != nil {
+		t.Fatalf("failed to create database: %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	_ = db.InsertRun("run-1", "agent-1", "gen-hash", "pub-key")
+
+	aliased := map[string]string{models.LabelCanonicalMethod: "fs:read"}
+	for seq, e := range []models.Event{
+		{ID: "e1", Method: "fs:read"},
+		{ID: "e2", Method: "filesystem.read_file", Labels: aliased},
+		{ID: "e3", Method: "filesystem.read_file", Labels: map[string]string{"team": "ops", models.LabelCanonicalMethod: "fs:read"}},
+	} {
+		e.RunID, e.SeqIndex, e.Timestamp, e.EventType = "run-1", uint64(seq+1), time.Now(), "tool_call"
+		e.CurrentHash, e.Signature = fmt.Sprintf("h%d", seq), "s"
+		if err := db.StoreEvent(&e); err != nil {
+			t.Fatalf("StoreEvent: %v", err)
+		}
+	}
+	want := []MethodStats{{Method: "fs:read", Events: 3, Calls: 3}}
+	methods, err := db.GetMethodStats("run-1", 10)
+	if err != nil || !reflect.DeepEqual(methods, want) {
+		t.Errorf("method stats = %+v, %v", methods, err)
+	}
+
+	if _, err := db.conn.Exec(`DELETE FROM events WHERE id = 'e2'`); err != nil {
+		t.Fatal(err)
+	}
+	want[0].Events, want[0].Calls = 2, 2
+	if methods, err = db.GetMethodStats("run-1", 10); err != nil || !reflect.DeepEqual(methods, want) {
+		t.Errorf("method stats after delete = %+v, %v", methods, err)
+	}
+	if err := rebuildStats(db.conn); err != nil {
+		t.Fatalf("rebuildStats: %v", err)
+	}
+	if methods, err = db.GetMethodStats("run-1", 10); err != nil || !reflect.DeepEqual(methods, want) {
+		t.Errorf("rebuilt method stats = %+v, %v", methods, err)
+	}
+}
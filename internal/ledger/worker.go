@@ -37,9 +37,18 @@ type Worker struct {
 	db               EventRepository
 	signer           *crypto.Signer
 	runID            string
+	environment      string
+	genesis          GenesisConfig // agent metadata for new runs
 	processor        *EventProcessor
 	backpressureMode BackpressureMode
 	isUnhealthy      atomic.Bool   // Health sentinel
+	unhealthyReason  atomic.Value  // string: why isUnhealthy was set
+	lastSeq          atomic.Uint64 // sequence index of the last committed event
+	lastAnchorNs     atomic.Int64  // when the last anchor event was committed (unix ns)
+	readOnly         atomic.Bool   // Submit discards events; set when the ledger failed its integrity check
+	rotateEvery      time.Duration // rotation interval; 0 disables
+	rotateAfter      uint64        // events per run before rotation; 0 disables
+	runWindow        int64         // rotation interval the current run's last event falls in
 	processedEvents  atomic.Uint64 // Metrics
 	droppedEvents    atomic.Uint64 // Metrics
 	blockedSubmits   atomic.Uint64 // Count of blocked Submit() calls
@@ -57,6 +66,7 @@ const (
 	maxDrainEvents    = 1 << 20
 	maxShutdownTicks  = 1 << 12
 	maxLatencyBuckets = 7
+	defaultAgentName  = "Logryph-Agent"
 )
 
 var latencyBucketUpperNs = [maxLatencyBuckets]uint64{
@@ -138,6 +148,19 @@ func (w *Worker) SetBackpressureMode(mode BackpressureMode) error {
 	return nil
 }
 
+// SetEnvironment sets the deployment profile stamped on system events (genesis, anchors,
+// task transitions) that are not tied to a request. Must be called before Start().
+func (w *Worker) SetEnvironment(env string) error {
+	if err := assert.NotNil(w, "worker"); err != nil {
+		return err
+	}
+	if err := assert.Check(len(env) <= 64, "environment name too long: %d", len(env)); err != nil {
+		return err
+	}
+	w.environment = env
+	return nil
+}
+
 // BackpressureMode returns the current backpressure handling mode.
 func (w *Worker) BackpressureMode() BackpressureMode {
 	if err := assert.NotNil(w, "worker"); err != nil {
@@ -170,6 +193,61 @@ func (w *Worker) IsHealthy() bool {
 	return !w.isUnhealthy.Load()
 }
 
+// UnhealthyReason explains why the worker became unhealthy, or is empty while it is healthy.
+func (w *Worker) UnhealthyReason() string {
+	if err := assert.NotNil(w, "worker"); err != nil {
+		return ""
+	}
+	reason, _ := w.unhealthyReason.Load().(string)
+	return reason
+}
+
+// markUnhealthy flags the worker as unhealthy. The first reason is kept.
+func (w *Worker) markUnhealthy(reason string) {
+	if w.isUnhealthy.CompareAndSwap(false, true) {
+		w.unhealthyReason.Store(reason)
+	}
+}
+
+// SetReadOnly stops the worker from appending to the ledger: every later Submit is
+// discarded and the worker reports unhealthy with reason.
+func (w *Worker) SetReadOnly(reason string) {
+	if err := assert.NotNil(w, "worker"); err != nil {
+		return
+	}
+	w.readOnly.Store(true)
+	w.markUnhealthy(reason)
+}
+
+// ReadOnly reports whether SetReadOnly was called.
+func (w *Worker) ReadOnly() bool {
+	if err := assert.NotNil(w, "worker"); err != nil {
+		return false
+	}
+	return w.readOnly.Load()
+}
+
+// LastSeq returns the sequence index of the last event committed to the ledger.
+func (w *Worker) LastSeq() uint64 {
+	if err := assert.NotNil(w, "worker"); err != nil {
+		return 0
+	}
+	return w.lastSeq.Load()
+}
+
+// LastAnchor returns when the last external anchor was committed, or the zero time if
+// none has been since startup.
+func (w *Worker) LastAnchor() time.Time {
+	if err := assert.NotNil(w, "worker"); err != nil {
+		return time.Time{}
+	}
+	ns := w.lastAnchorNs.Load()
+	if ns == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, ns)
+}
+
 func (w *Worker) GetSigner() *crypto.Signer {
 	return w.signer
 }
@@ -188,7 +266,7 @@ func (w *Worker) Start() error {
 	}
 
 	if !hasRuns {
-		runID, err := CreateGenesisBlock(w.db, w.signer, "Logryph-Agent")
+		runID, err := createGenesisBlock(w.db, w.signer, w.genesis, w.environment, nil)
 		if err != nil {
 			return fmt.Errorf("creating genesis block: %w", err)
 		}
@@ -203,7 +281,12 @@ func (w *Worker) Start() error {
 		logging.Info("run_loaded", logging.Fields{Component: "worker", RunID: runID})
 	}
 
+	if seq, _, err := w.db.GetLastEvent(w.runID); err == nil {
+		w.lastSeq.Store(seq)
+	}
+	w.loadRotationState()
 	w.processor = NewEventProcessor(w.db, w.signer, w.runID)
+	w.processor.environment = w.environment
 	w.closing.Store(false)
 
 	w.wg.Add(1)
@@ -235,6 +318,11 @@ func (w *Worker) Submit(event *models.Event) {
 		logging.Warn("event_dropped_shutdown", logging.Fields{Component: "worker", EventID: event.ID, TaskID: event.TaskID})
 		return
 	}
+	if w.readOnly.Load() {
+		w.droppedEvents.Add(1)
+		logging.Warn("event_dropped_read_only", logging.Fields{Component: "worker", EventID: event.ID, TaskID: event.TaskID})
+		return
+	}
 
 	// Backpressure handling based on configured mode
 	if w.backpressureMode == BackpressureBlock {
@@ -401,7 +489,7 @@ func (w *Worker) drainBuffer() error {
 	}
 
 	if err := assert.Check(w.ringBuffer.Cap() <= maxDrainEvents, "ring buffer cap exceeds max: %d", w.ringBuffer.Cap()); err != nil {
-		w.isUnhealthy.Store(true)
+		w.markUnhealthy("ring buffer capacity exceeds drain limit")
 		return err
 	}
 
@@ -413,14 +501,7 @@ func (w *Worker) drainBuffer() error {
 		if err != nil {
 			break
 		}
-		start := time.Now()
-		if err := w.processor.ProcessEvent(event); err != nil {
-			logging.Critical("event_processing_failed", logging.Fields{Component: "worker", EventID: event.ID, TaskID: event.TaskID, Error: err.Error()})
-			w.isUnhealthy.Store(true)
-		}
-		w.recordLatency(time.Since(start))
-		w.processedEvents.Add(1)
-		pool.PutEvent(event)
+		w.processOne(event)
 	}
 	return nil
 }
@@ -471,7 +552,7 @@ func (w *Worker) processEvents() {
 		}
 		// Drain buffer
 		if err := assert.Check(w.ringBuffer.Cap() <= maxDrainEvents, "ring buffer cap exceeds max: %d", w.ringBuffer.Cap()); err != nil {
-			w.isUnhealthy.Store(true)
+			w.markUnhealthy("ring buffer capacity exceeds drain limit")
 			return
 		}
 		for j := 0; j < maxDrainEvents; j++ {
@@ -482,14 +563,7 @@ func (w *Worker) processEvents() {
 			if err != nil {
 				break
 			}
-			start := time.Now()
-			if err := w.processor.ProcessEvent(event); err != nil {
-				logging.Critical("event_processing_failed", logging.Fields{Component: "worker", EventID: event.ID, TaskID: event.TaskID, Error: err.Error()})
-				w.isUnhealthy.Store(true)
-			}
-			w.recordLatency(time.Since(start))
-			w.processedEvents.Add(1)
-			pool.PutEvent(event)
+			w.processOne(event)
 		}
 	}
 	if err := assert.Check(false, "processEvents exceeded max signal batches"); err != nil {
@@ -497,6 +571,29 @@ func (w *Worker) processEvents() {
 	}
 }
 
+// processOne commits event to the ledger and returns it to the pool.
+func (w *Worker) processOne(event *models.Event) {
+	start := time.Now()
+	if reason := w.rotationDue(start); reason != "" {
+		if err := w.rotate(reason); err != nil {
+			logging.Error("run_rotation_failed", logging.Fields{Component: "worker", RunID: w.runID, Error: err.Error()})
+		}
+	}
+	if err := w.processor.ProcessEvent(event); err != nil {
+		logging.Critical("event_processing_failed", logging.Fields{Component: "worker", EventID: event.ID, TaskID: event.TaskID, Error: err.Error()})
+		w.markUnhealthy("event processing failed: " + err.Error())
+	} else {
+		w.lastSeq.Store(event.SeqIndex)
+		w.runWindow = w.rotationWindow(start)
+		if event.EventType == "anchor" {
+			w.lastAnchorNs.Store(time.Now().UnixNano())
+		}
+	}
+	w.recordLatency(time.Since(start))
+	w.processedEvents.Add(1)
+	pool.PutEvent(event)
+}
+
 func (w *Worker) recordLatency(d time.Duration) {
 	if err := assert.NotNil(w, "worker"); err != nil {
 		return
@@ -0,0 +1,144 @@
+package logging
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultMaxSizeMB  = 100
+	defaultMaxBackups = 7
+	maxBackupScan     = 1024
+	backupTimeFormat  = "20060102T150405.000000000"
+)
+
+// fileSink appends JSON lines to a file and rotates it to <path>.<timestamp> when it
+// would grow past maxSize or is older than maxAge. Only maxBackups rotated files are kept.
+type fileSink struct {
+	mu         sync.Mutex
+	path       string
+	maxSize    int64
+	maxAge     time.Duration
+	maxBackups int
+	f          *os.File
+	size       int64
+	opened     time.Time
+}
+
+func newFileSink(c SinkConfig) (*fileSink, error) {
+	maxAge, err := parseOptionalDuration(c.MaxAge)
+	if err != nil {
+		return nil, err
+	}
+	s := &fileSink{
+		path:       c.Path,
+		maxSize:    int64(c.MaxSizeMB) << 20,
+		maxAge:     maxAge,
+		maxBackups: c.MaxBackups,
+	}
+	if s.maxSize <= 0 {
+		s.maxSize = defaultMaxSizeMB << 20
+	}
+	if s.maxBackups <= 0 {
+		s.maxBackups = defaultMaxBackups
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o755); err != nil {
+		return nil, fmt.Errorf("creating log directory: %w", err)
+	}
+	if err := s.open(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *fileSink) open() error {
+	f, err := os.OpenFile(s.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o640)
+	if err != nil {
+		return fmt.Errorf("opening log file: %w", err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+		return fmt.Errorf("stat log file: %w", err)
+	}
+	s.f, s.size, s.opened = f, info.Size(), time.Now()
+	if info.Size() > 0 {
+		s.opened = info.ModTime() // an existing file ages from its last write, not from restart
+	}
+	return nil
+}
+
+func (s *fileSink) Write(_ string, line []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.f == nil {
+		return fmt.Errorf("log file %s is closed", s.path)
+	}
+	n := int64(len(line)) + 1
+	tooBig := s.size > 0 && s.size+n > s.maxSize
+	tooOld := s.maxAge > 0 && s.size > 0 && time.Since(s.opened) > s.maxAge
+	if tooBig || tooOld {
+		if err := s.rotate(); err != nil {
+			return err
+		}
+	}
+	buf := make([]byte, 0, len(line)+1)
+	written, err := s.f.Write(append(append(buf, line...), '\n'))
+	s.size += int64(written)
+	return err
+}
+
+func (s *fileSink) rotate() error {
+	if err := s.f.Close(); err != nil {
+		return fmt.Errorf("closing log file: %w", err)
+	}
+	s.f = nil
+	backup := s.path + "." + time.Now().UTC().Format(backupTimeFormat)
+	if err := os.Rename(s.path, backup); err != nil {
+		return fmt.Errorf("rotating log file: %w", err)
+	}
+	if err := s.open(); err != nil {
+		return err
+	}
+	s.opened = time.Now()
+	return s.prune()
+}
+
+// prune deletes the oldest rotated files beyond maxBackups. Backup names sort by time.
+func (s *fileSink) prune() error {
+	matches, err := filepath.Glob(s.path + ".*")
+	if err != nil {
+		return fmt.Errorf("listing rotated logs: %w", err)
+	}
+	var backups []string
+	prefix := filepath.Base(s.path) + "."
+	for i := 0; i < len(matches) && i < maxBackupScan; i++ {
+		suffix := strings.TrimPrefix(filepath.Base(matches[i]), prefix)
+		if _, err := time.Parse(backupTimeFormat, suffix); err == nil {
+			backups = append(backups, matches[i])
+		}
+	}
+	sort.Strings(backups)
+	for i := 0; i < len(backups)-s.maxBackups; i++ {
+		if err := os.Remove(backups[i]); err != nil {
+			return fmt.Errorf("removing rotated log: %w", err)
+		}
+	}
+	return nil
+}
+
+func (s *fileSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.f == nil {
+		return nil
+	}
+	err := s.f.Close()
+	s.f = nil
+	return err
+}
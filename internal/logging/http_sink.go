@@ -0,0 +1,143 @@
+package logging
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	defaultHTTPBatch    = 100
+	defaultHTTPFlush    = 5 * time.Second
+	httpSinkQueue       = 10000
+	httpSinkTimeout     = 10 * time.Second
+	httpSinkCloseWait   = 5 * time.Second
+	maxHTTPSinkBatch    = 5000
+	maxHTTPSinkLoopTick = 1 << 30
+)
+
+// httpSink ships lines to a collector as newline-delimited JSON, batching by count and
+// time. Logging never waits on the network: when the queue is full lines are dropped
+// and counted, and the count is reported with the next successful batch.
+type httpSink struct {
+	url       string
+	headers   map[string]string
+	batchSize int
+	interval  time.Duration
+	client    *http.Client
+	queue     chan []byte
+	done      chan struct{}
+	closeOnce sync.Once
+	dropped   atomic.Uint64
+}
+
+func newHTTPSink(c SinkConfig) (*httpSink, error) {
+	interval, err := parseOptionalDuration(c.FlushInterval)
+	if err != nil {
+		return nil, err
+	}
+	if interval == 0 {
+		interval = defaultHTTPFlush
+	}
+	batch := c.BatchSize
+	if batch <= 0 {
+		batch = defaultHTTPBatch
+	}
+	if batch > maxHTTPSinkBatch {
+		return nil, fmt.Errorf("batch_size %d exceeds %d", batch, maxHTTPSinkBatch)
+	}
+	s := &httpSink{
+		url:       c.URL,
+		headers:   c.Headers,
+		batchSize: batch,
+		interval:  interval,
+		client:    &http.Client{Timeout: httpSinkTimeout},
+		queue:     make(chan []byte, httpSinkQueue),
+		done:      make(chan struct{}),
+	}
+	go s.run()
+	return s, nil
+}
+
+func (s *httpSink) Write(_ string, line []byte) error {
+	cp := make([]byte, len(line))
+	copy(cp, line)
+	select {
+	case s.queue <- cp:
+	default:
+		s.dropped.Add(1)
+	}
+	return nil
+}
+
+func (s *httpSink) run() {
+	defer close(s.done)
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+	var batch [][]byte
+	for i := 0; i < maxHTTPSinkLoopTick; i++ {
+		select {
+		case line, ok := <-s.queue:
+			if !ok {
+				s.send(batch)
+				return
+			}
+			batch = append(batch, line)
+			if len(batch) >= s.batchSize {
+				s.send(batch)
+				batch = nil
+			}
+		case <-ticker.C:
+			s.send(batch)
+			batch = nil
+		}
+	}
+}
+
+func (s *httpSink) send(batch [][]byte) {
+	if len(batch) == 0 {
+		return
+	}
+	body := bytes.Join(batch, []byte("\n"))
+	body = append(body, '\n')
+	if dropped := s.dropped.Swap(0); dropped > 0 {
+		note := fmt.Sprintf("{\"ts\":%q,\"level\":\"warn\",\"msg\":\"log_lines_dropped\",\"count\":%d,\"component\":\"logging\"}\n",
+			time.Now().UTC().Format(time.RFC3339Nano), dropped)
+		body = append(body, note...)
+	}
+	req, err := http.NewRequest(http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		log.Printf("{\"level\":\"error\",\"msg\":\"log_ship_failed\",\"error\":%q}", err.Error())
+		return
+	}
+	req.Header.Set("Content-Type", "application/x-ndjson")
+	for k, v := range s.headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		log.Printf("{\"level\":\"error\",\"msg\":\"log_ship_failed\",\"error\":%q}", err.Error())
+		return
+	}
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<16))
+	_ = resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		log.Printf("{\"level\":\"error\",\"msg\":\"log_ship_failed\",\"status\":%d}", resp.StatusCode)
+	}
+}
+
+// Close flushes queued lines, waiting at most httpSinkCloseWait.
+func (s *httpSink) Close() error {
+	s.closeOnce.Do(func() { close(s.queue) })
+	select {
+	case <-s.done:
+		return nil
+	case <-time.After(httpSinkCloseWait):
+		return fmt.Errorf("timed out flushing logs to %s", s.url)
+	}
+}
@@ -30,7 +30,12 @@ type Fields struct {
 	EventID   string `json:"event_id,omitempty"`
 	RunID     string `json:"run_id,omitempty"`
 	Component string `json:"component,omitempty"`
-	Error     string `json:"error,omitempty"`
+	// CorrelationID is the X-Logryph-Request-ID of the HTTP exchange; TraceID its W3C trace.
+	CorrelationID string `json:"correlation_id,omitempty"`
+	TraceID       string `json:"trace_id,omitempty"`
+	// Digest is the SHA-256 of a call body logged in place of an event the ledger could not record.
+	Digest string `json:"digest,omitempty"`
+	Error  string `json:"error,omitempty"`
 }
 
 type entry struct {
@@ -45,6 +50,39 @@ var (
 	minLevel  = levelInfo
 )
 
+// maxRecentEntries bounds the in-memory copy of recent log lines kept for diagnostics.
+const maxRecentEntries = 1000
+
+var (
+	recentMu   sync.Mutex
+	recent     [maxRecentEntries]string
+	recentNext int
+	recentLen  int
+)
+
+// Recent returns up to the last 1000 log lines written, oldest first.
+// Used by diagnostics bundles so support gets logs without access to stdout.
+func Recent() []string {
+	recentMu.Lock()
+	defer recentMu.Unlock()
+	out := make([]string, 0, recentLen)
+	start := (recentNext - recentLen + maxRecentEntries) % maxRecentEntries
+	for i := 0; i < recentLen; i++ {
+		out = append(out, recent[(start+i)%maxRecentEntries])
+	}
+	return out
+}
+
+func remember(line string) {
+	recentMu.Lock()
+	defer recentMu.Unlock()
+	recent[recentNext] = line
+	recentNext = (recentNext + 1) % maxRecentEntries
+	if recentLen < maxRecentEntries {
+		recentLen++
+	}
+}
+
 func init() {
 	if err := assert.Check(log.Default() != nil, "default logger must not be nil"); err != nil {
 		return
@@ -137,7 +175,10 @@ func logWithLevel(level string, fieldsMsg string, fields Fields) {
 		log.Printf("{\"level\":\"error\",\"msg\":\"log_marshal_failed\",\"error\":%q}", err.Error())
 		return
 	}
-	log.Print(string(payload))
+	remember(string(payload))
+	if !dispatch(level, payload) {
+		log.Print(string(payload))
+	}
 }
 
 func shouldLog(level string) bool {
@@ -147,16 +188,24 @@ func shouldLog(level string) bool {
 	if err := assert.Check(len(level) <= 16, "log level too long: %d", len(level)); err != nil {
 		return false
 	}
+	if floor := sinkLevelFloor(); floor >= 0 {
+		return levelValue(level) >= floor
+	}
 	levelOnce.Do(func() {
-		envLevel := strings.ToLower(os.Getenv("LOGRYPH_LOG_LEVEL"))
-		if envLevel == "" {
-			envLevel = "info"
-		}
-		minLevel = levelValue(envLevel)
+		minLevel = levelValue(envLevel())
 	})
 	return levelValue(level) >= minLevel
 }
 
+// envLevel returns LOGRYPH_LOG_LEVEL, defaulting to info.
+func envLevel() string {
+	level := strings.ToLower(os.Getenv("LOGRYPH_LOG_LEVEL"))
+	if level == "" {
+		return "info"
+	}
+	return level
+}
+
 func levelValue(level string) int {
 	if err := assert.Check(level != "", "log level must not be empty"); err != nil {
 		return levelInfo
@@ -0,0 +1,220 @@
+package logging
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/slyt3/Logryph/internal/assert"
+)
+
+// Sink types.
+const (
+	SinkConsole = "console" // the standard logger (stderr); the default when no sinks are configured
+	SinkFile    = "file"    // JSON lines to a file, rotated by size and age
+	SinkSyslog  = "syslog"  // local or remote syslog (not available on Windows)
+	SinkHTTP    = "http"    // batched JSON lines POSTed to a collector
+)
+
+const maxSinks = 16
+
+// SinkConfig configures one log destination. Level filters what the sink receives
+// (debug, info, warn, error, critical; default: LOGRYPH_LOG_LEVEL or info).
+type SinkConfig struct {
+	Type  string `yaml:"type"`
+	Level string `yaml:"level,omitempty"`
+
+	// file
+	Path       string `yaml:"path,omitempty"`
+	MaxSizeMB  int    `yaml:"max_size_mb,omitempty"` // rotate when the file would exceed this (default 100)
+	MaxAge     string `yaml:"max_age,omitempty"`     // rotate when the file is older than this, e.g. "24h"
+	MaxBackups int    `yaml:"max_backups,omitempty"` // rotated files kept (default 7)
+
+	// syslog
+	Network string `yaml:"network,omitempty"` // "", "udp" or "tcp"; empty means the local syslog daemon
+	Address string `yaml:"address,omitempty"`
+	Tag     string `yaml:"tag,omitempty"`
+
+	// http
+	URL           string            `yaml:"url,omitempty"`
+	Headers       map[string]string `yaml:"headers,omitempty"`
+	BatchSize     int               `yaml:"batch_size,omitempty"`     // lines per request (default 100)
+	FlushInterval string            `yaml:"flush_interval,omitempty"` // default "5s"
+}
+
+// Sink receives formatted JSON log lines (without trailing newline).
+type Sink interface {
+	Write(level string, line []byte) error
+	Close() error
+}
+
+type configuredSink struct {
+	sink     Sink
+	minLevel int
+	kind     string
+}
+
+var (
+	sinksMu      sync.RWMutex
+	activeSinks  []configuredSink
+	sinksMinimum = -1 // lowest level any sink accepts; -1 when no sinks are configured
+)
+
+// ConfigureSinks replaces the active sinks. With no configs, logs go to the console
+// as before. On error the previous sinks stay in place.
+func ConfigureSinks(configs []SinkConfig) error {
+	if err := assert.Check(len(configs) <= maxSinks, "too many log sinks: %d", len(configs)); err != nil {
+		return err
+	}
+	built := make([]configuredSink, 0, len(configs))
+	lowest := -1
+	for i := 0; i < len(configs); i++ {
+		s, err := buildSink(configs[i])
+		if err != nil {
+			closeSinks(built)
+			return fmt.Errorf("log sink %d (%s): %w", i, configs[i].Type, err)
+		}
+		if lowest < 0 || s.minLevel < lowest {
+			lowest = s.minLevel
+		}
+		built = append(built, s)
+	}
+
+	sinksMu.Lock()
+	old := activeSinks
+	activeSinks = built
+	sinksMinimum = lowest
+	sinksMu.Unlock()
+	closeSinks(old)
+	return nil
+}
+
+// CloseSinks flushes and closes all sinks and reverts to console output.
+func CloseSinks() {
+	if err := ConfigureSinks(nil); err != nil {
+		log.Printf("closing log sinks: %v", err)
+	}
+}
+
+// ValidateSinkConfig checks a sink configuration without opening anything.
+func ValidateSinkConfig(c SinkConfig) error {
+	if c.Level != "" && !validLevel(c.Level) {
+		return fmt.Errorf("unknown level %q", c.Level)
+	}
+	if _, err := parseOptionalDuration(c.MaxAge); err != nil {
+		return fmt.Errorf("max_age: %w", err)
+	}
+	if _, err := parseOptionalDuration(c.FlushInterval); err != nil {
+		return fmt.Errorf("flush_interval: %w", err)
+	}
+	switch c.Type {
+	case SinkConsole:
+	case SinkFile:
+		if c.Path == "" {
+			return errors.New("file sink needs a path")
+		}
+	case SinkSyslog:
+		if c.Network != "" && c.Address == "" {
+			return errors.New("remote syslog needs an address")
+		}
+	case SinkHTTP:
+		if c.URL == "" {
+			return errors.New("http sink needs a url")
+		}
+	default:
+		return fmt.Errorf("unknown sink type %q (want console, file, syslog or http)", c.Type)
+	}
+	return nil
+}
+
+func buildSink(c SinkConfig) (configuredSink, error) {
+	if err := ValidateSinkConfig(c); err != nil {
+		return configuredSink{}, err
+	}
+	level := c.Level
+	if level == "" {
+		level = envLevel()
+	}
+	out := configuredSink{minLevel: levelValue(level), kind: c.Type}
+	var err error
+	switch c.Type {
+	case SinkConsole:
+		out.sink = consoleSink{}
+	case SinkFile:
+		out.sink, err = newFileSink(c)
+	case SinkSyslog:
+		out.sink, err = newSyslogSink(c)
+	case SinkHTTP:
+		out.sink, err = newHTTPSink(c)
+	}
+	return out, err
+}
+
+// dispatch writes line to every sink accepting level; without sinks it uses the console.
+// Reports whether any sink is configured.
+func dispatch(level string, line []byte) bool {
+	sinksMu.RLock()
+	defer sinksMu.RUnlock()
+	if len(activeSinks) == 0 {
+		return false
+	}
+	lv := levelValue(level)
+	for i := 0; i < len(activeSinks); i++ {
+		if lv < activeSinks[i].minLevel {
+			continue
+		}
+		if err := activeSinks[i].sink.Write(level, line); err != nil {
+			// Never recurse into the logger from a sink failure.
+			log.Printf("{\"level\":\"error\",\"msg\":\"log_sink_write_failed\",\"sink\":%q,\"error\":%q}", activeSinks[i].kind, err.Error())
+		}
+	}
+	return true
+}
+
+// sinkLevelFloor returns the lowest level any configured sink accepts, or -1.
+func sinkLevelFloor() int {
+	sinksMu.RLock()
+	defer sinksMu.RUnlock()
+	return sinksMinimum
+}
+
+func closeSinks(sinks []configuredSink) {
+	for i := 0; i < len(sinks); i++ {
+		if err := sinks[i].sink.Close(); err != nil {
+			log.Printf("{\"level\":\"warn\",\"msg\":\"log_sink_close_failed\",\"sink\":%q,\"error\":%q}", sinks[i].kind, err.Error())
+		}
+	}
+}
+
+func validLevel(level string) bool {
+	switch level {
+	case "debug", "info", "warn", "error", "critical":
+		return true
+	}
+	return false
+}
+
+func parseOptionalDuration(s string) (time.Duration, error) {
+	if s == "" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, err
+	}
+	if d <= 0 {
+		return 0, fmt.Errorf("must be positive, got %s", s)
+	}
+	return d, nil
+}
+
+type consoleSink struct{}
+
+func (consoleSink) Write(_ string, line []byte) error {
+	log.Print(string(line))
+	return nil
+}
+
+func (consoleSink) Close() error { return nil }
@@ -0,0 +1,152 @@
+package logging
+
+import (
+	"bufio"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestFileSinkRotatesBySizeAndPrunes(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "logryph.log")
+	s, err := newFileSink(SinkConfig{Type: SinkFile, Path: path, MaxSizeMB: 1, MaxBackups: 2})
+	if err != nil {
+		t.Fatalf("newFileSink: %v", err)
+	}
+	defer s.Close()
+	s.maxSize = 100 // bytes, to rotate quickly
+
+	line := []byte(strings.Repeat("x", 60))
+	for i := 0; i < 8; i++ {
+		if err := s.Write("info", line); err != nil {
+			t.Fatalf("write %d: %v", i, err)
+		}
+	}
+	backups, _ := filepath.Glob(path + ".*")
+	if len(backups) != 2 {
+		t.Fatalf("expected 2 backups after pruning, got %d: %v", len(backups), backups)
+	}
+	info, err := os.Stat(path)
+	if err != nil || info.Size() > 100 {
+		t.Fatalf("active file should be below the size limit: %v %v", info, err)
+	}
+}
+
+func TestFileSinkRotatesByAge(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "logryph.log")
+	s, err := newFileSink(SinkConfig{Type: SinkFile, Path: path, MaxAge: "1h"})
+	if err != nil {
+		t.Fatalf("newFileSink: %v", err)
+	}
+	defer s.Close()
+	if err := s.Write("info", []byte("first")); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	s.opened = time.Now().Add(-2 * time.Hour)
+	if err := s.Write("info", []byte("second")); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	backups, _ := filepath.Glob(path + ".*")
+	if len(backups) != 1 {
+		t.Fatalf("expected 1 backup after age rotation, got %v", backups)
+	}
+}
+
+func TestSinksFilterByLevel(t *testing.T) {
+	dir := t.TempDir()
+	errPath := filepath.Join(dir, "errors.log")
+	allPath := filepath.Join(dir, "all.log")
+	err := ConfigureSinks([]SinkConfig{
+		{Type: SinkFile, Path: errPath, Level: "error"},
+		{Type: SinkFile, Path: allPath, Level: "debug"},
+	})
+	if err != nil {
+		t.Fatalf("ConfigureSinks: %v", err)
+	}
+	Debug("debug_line", Fields{Component: "test"})
+	Error("error_line", Fields{Component: "test"})
+	CloseSinks()
+
+	errLines := readLines(t, errPath)
+	if len(errLines) != 1 || !strings.Contains(errLines[0], "error_line") {
+		t.Fatalf("error sink got %v", errLines)
+	}
+	if allLines := readLines(t, allPath); len(allLines) != 2 {
+		t.Fatalf("debug sink should get both lines, got %v", allLines)
+	}
+}
+
+func TestHTTPSinkShipsBatches(t *testing.T) {
+	var mu sync.Mutex
+	var got []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		got = append(got, strings.Split(strings.TrimSpace(string(body)), "\n")...)
+		mu.Unlock()
+		if r.Header.Get("Authorization") != "Bearer t" {
+			t.Errorf("missing configured header")
+		}
+	}))
+	defer srv.Close()
+
+	s, err := newHTTPSink(SinkConfig{Type: SinkHTTP, URL: srv.URL, BatchSize: 2, FlushInterval: "1h",
+		Headers: map[string]string{"Authorization": "Bearer t"}})
+	if err != nil {
+		t.Fatalf("newHTTPSink: %v", err)
+	}
+	for _, line := range []string{`{"a":1}`, `{"a":2}`, `{"a":3}`} {
+		if err := s.Write("info", []byte(line)); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+	}
+	if err := s.Close(); err != nil { // flushes the partial batch
+		t.Fatalf("close: %v", err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(got) != 3 {
+		t.Fatalf("expected 3 shipped lines, got %v", got)
+	}
+}
+
+func TestValidateSinkConfig(t *testing.T) {
+	bad := []SinkConfig{
+		{Type: "kafka"},
+		{Type: SinkFile},
+		{Type: SinkHTTP},
+		{Type: SinkConsole, Level: "loud"},
+		{Type: SinkFile, Path: "x.log", MaxAge: "soon"},
+		{Type: SinkSyslog, Network: "udp"},
+	}
+	for _, c := range bad {
+		if err := ValidateSinkConfig(c); err == nil {
+			t.Errorf("expected error for %+v", c)
+		}
+	}
+	if err := ValidateSinkConfig(SinkConfig{Type: SinkFile, Path: "x.log", MaxAge: "24h", Level: "warn"}); err != nil {
+		t.Errorf("valid config rejected: %v", err)
+	}
+}
+
+func readLines(t *testing.T, path string) []string {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("open %s: %v", path, err)
+	}
+	defer f.Close()
+	var lines []string
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		lines = append(lines, sc.Text())
+	}
+	return lines
+}
@@ -0,0 +1,47 @@
+//go:build !windows
+
+package logging
+
+import (
+	"fmt"
+	"log/syslog"
+)
+
+const defaultSyslogTag = "logryph"
+
+// syslogSink forwards lines to syslog at the matching severity.
+type syslogSink struct {
+	w *syslog.Writer
+}
+
+func newSyslogSink(c SinkConfig) (*syslogSink, error) {
+	tag := c.Tag
+	if tag == "" {
+		tag = defaultSyslogTag
+	}
+	w, err := syslog.Dial(c.Network, c.Address, syslog.LOG_INFO|syslog.LOG_DAEMON, tag)
+	if err != nil {
+		return nil, fmt.Errorf("connecting to syslog: %w", err)
+	}
+	return &syslogSink{w: w}, nil
+}
+
+func (s *syslogSink) Write(level string, line []byte) error {
+	msg := string(line)
+	switch level {
+	case "debug":
+		return s.w.Debug(msg)
+	case "warn":
+		return s.w.Warning(msg)
+	case "error":
+		return s.w.Err(msg)
+	case "critical":
+		return s.w.Crit(msg)
+	default:
+		return s.w.Info(msg)
+	}
+}
+
+func (s *syslogSink) Close() error {
+	return s.w.Close()
+}
@@ -0,0 +1,9 @@
+//go:build windows
+
+package logging
+
+import "errors"
+
+func newSyslogSink(SinkConfig) (Sink, error) {
+	return nil, errors.New("syslog sink is not supported on windows")
+}
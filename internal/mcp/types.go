@@ -1,5 +1,10 @@
 package mcp
 
+import (
+	"bytes"
+	"encoding/json"
+)
+
 // MCPRequest represents a Model Context Protocol JSON-RPC request
 type MCPRequest struct {
 	JSONRPC string                 `json:"jsonrpc"`
@@ -8,10 +13,27 @@ type MCPRequest struct {
 	Params  map[string]interface{} `json:"params"`
 }
 
-// MCPResponse represents a Model Context Protocol JSON-RPC response
+// MCPResponse represents a Model Context Protocol JSON-RPC response. Result is kept raw:
+// tools may answer with an object, an array or a scalar, and DecodeResult types it.
 type MCPResponse struct {
 	JSONRPC string                 `json:"jsonrpc"`
 	ID      interface{}            `json:"id"`
-	Result  map[string]interface{} `json:"result,omitempty"`
+	Result  json.RawMessage        `json:"result,omitempty"`
 	Error   map[string]interface{} `json:"error,omitempty"`
 }
+
+// DecodeResult decodes the result. An object is returned as obj; any other value (an
+// array, string, number or boolean) as other. Both are nil when there is no result or
+// it is null.
+func (r *MCPResponse) DecodeResult() (obj map[string]interface{}, other interface{}, err error) {
+	raw := bytes.TrimSpace(r.Result)
+	if len(raw) == 0 || bytes.Equal(raw, []byte("null")) {
+		return nil, nil, nil
+	}
+	if raw[0] == '{' {
+		err = json.Unmarshal(raw, &obj)
+		return obj, nil, err
+	}
+	err = json.Unmarshal(raw, &other)
+	return nil, other, err
+}
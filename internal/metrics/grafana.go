@@ -0,0 +1,196 @@
+package metrics
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	maxDashboardPanels = 128
+	panelWidth         = 12
+	panelHeight        = 8
+	gridWidth          = 24
+	datasourceVar      = "${DS_PROMETHEUS}"
+)
+
+type grafanaDashboard struct {
+	Inputs        []grafanaInput `json:"__inputs"`
+	Title         string         `json:"title"`
+	UID           string         `json:"uid"`
+	Tags          []string       `json:"tags"`
+	Timezone      string         `json:"timezone"`
+	Refresh       string         `json:"refresh"`
+	SchemaVersion int            `json:"schemaVersion"`
+	Time          grafanaTime    `json:"time"`
+	Panels        []grafanaPanel `json:"panels"`
+}
+
+type grafanaInput struct {
+	Name     string `json:"name"`
+	Label    string `json:"label"`
+	Type     string `json:"type"`
+	PluginID string `json:"pluginId"`
+}
+
+type grafanaTime struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+type grafanaPanel struct {
+	ID          int                `json:"id"`
+	Type        string             `json:"type"`
+	Title       string             `json:"title"`
+	Description string             `json:"description,omitempty"`
+	GridPos     grafanaGridPos     `json:"gridPos"`
+	Datasource  *grafanaDatasource `json:"datasource,omitempty"`
+	FieldConfig *grafanaFieldCfg   `json:"fieldConfig,omitempty"`
+	Targets     []grafanaTarget    `json:"targets,omitempty"`
+}
+
+type grafanaGridPos struct {
+	X int `json:"x"`
+	Y int `json:"y"`
+	W int `json:"w"`
+	H int `json:"h"`
+}
+
+type grafanaDatasource struct {
+	Type string `json:"type"`
+	UID  string `json:"uid"`
+}
+
+type grafanaFieldCfg struct {
+	Defaults struct {
+		Unit string `json:"unit"`
+	} `json:"defaults"`
+}
+
+type grafanaTarget struct {
+	Expr         string `json:"expr"`
+	LegendFormat string `json:"legendFormat"`
+	RefID        string `json:"refId"`
+}
+
+// GrafanaDashboard returns an importable Grafana dashboard with one panel per registered
+// metric, grouped in rows. The Prometheus datasource is chosen at import time.
+func GrafanaDashboard() ([]byte, error) {
+	d := grafanaDashboard{
+		Inputs: []grafanaInput{{
+			Name: "DS_PROMETHEUS", Label: "Prometheus", Type: "datasource", PluginID: "prometheus",
+		}},
+		Title:         "Logryph",
+		UID:           "logryph-overview",
+		Tags:          []string{"logryph"},
+		Timezone:      "browser",
+		Refresh:       "30s",
+		SchemaVersion: 39,
+		Time:          grafanaTime{From: "now-6h", To: "now"},
+	}
+	y, id := 0, 1
+	for _, row := range []string{RowLedger, RowEngine, RowProxy, RowPool, RowCapacity} {
+		d.Panels = append(d.Panels, grafanaPanel{ID: id, Type: "row", Title: row, GridPos: grafanaGridPos{Y: y, W: gridWidth, H: 1}})
+		id++
+		y++
+		x := 0
+		for i := 0; i < len(All) && len(d.Panels) < maxDashboardPanels; i++ {
+			if All[i].Panel != row {
+				continue
+			}
+			d.Panels = append(d.Panels, metricPanel(All[i], id, x, y))
+			id++
+			if x += panelWidth; x >= gridWidth {
+				x = 0
+				y += panelHeight
+			}
+		}
+		if x != 0 {
+			y += panelHeight
+		}
+	}
+	return json.MarshalIndent(d, "", "  ")
+}
+
+func metricPanel(m Desc, id, x, y int) grafanaPanel {
+	p := grafanaPanel{
+		ID:          id,
+		Type:        "timeseries",
+		Title:       m.Help,
+		Description: m.Name,
+		GridPos:     grafanaGridPos{X: x, Y: y, W: panelWidth, H: panelHeight},
+		Datasource:  &grafanaDatasource{Type: "prometheus", UID: datasourceVar},
+		FieldConfig: &grafanaFieldCfg{},
+		Targets:     panelQueries(m),
+	}
+	p.FieldConfig.Defaults.Unit = m.Unit
+	if m.Type == TypeCounter {
+		p.FieldConfig.Defaults.Unit = "ops"
+		p.Title += " (per second)"
+	}
+	return p
+}
+
+// panelQueries returns the PromQL shown for m: rates for counters, p50/p99 for
+// histograms and the raw series for gauges.
+func panelQueries(m Desc) []grafanaTarget {
+	switch m.Type {
+	case TypeCounter:
+		return []grafanaTarget{{Expr: fmt.Sprintf("rate(%s[$__rate_interval])", m.Name), LegendFormat: "{{instance}}", RefID: "A"}}
+	case TypeHistogram:
+		q := "histogram_quantile(%s, sum(rate(%s_bucket[$__rate_interval])) by (le))"
+		return []grafanaTarget{
+			{Expr: fmt.Sprintf(q, "0.5", m.Name), LegendFormat: "p50", RefID: "A"},
+			{Expr: fmt.Sprintf(q, "0.99", m.Name), LegendFormat: "p99", RefID: "B"},
+		}
+	}
+	legend := "{{instance}}"
+	if len(m.Labels) > 0 {
+		legend = "{{" + m.Labels[0] + "}}"
+	}
+	return []grafanaTarget{{Expr: m.Name, LegendFormat: legend, RefID: "A"}}
+}
+
+type ruleFile struct {
+	Groups []ruleGroup `yaml:"groups"`
+}
+
+type ruleGroup struct {
+	Name  string      `yaml:"name"`
+	Rules []alertRule `yaml:"rules"`
+}
+
+type alertRule struct {
+	Alert       string            `yaml:"alert"`
+	Expr        string            `yaml:"expr"`
+	For         string            `yaml:"for,omitempty"`
+	Labels      map[string]string `yaml:"labels"`
+	Annotations map[string]string `yaml:"annotations"`
+}
+
+// PrometheusAlertRules returns the registry's Alerts as a Prometheus rules file.
+func PrometheusAlertRules() ([]byte, error) {
+	group := ruleGroup{Name: "logryph"}
+	for i := 0; i < len(Alerts); i++ {
+		a := Alerts[i]
+		group.Rules = append(group.Rules, alertRule{
+			Alert:       a.Name,
+			Expr:        a.Expr,
+			For:         a.For,
+			Labels:      map[string]string{"severity": a.Severity},
+			Annotations: map[string]string{"summary": a.Summary},
+		})
+	}
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(ruleFile{Groups: []ruleGroup{group}}); err != nil {
+		return nil, fmt.Errorf("encoding alert rules: %w", err)
+	}
+	if err := enc.Close(); err != nil {
+		return nil, fmt.Errorf("encoding alert rules: %w", err)
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,55 @@
+package metrics
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestGrafanaDashboardCoversRegistry(t *testing.T) {
+	data, err := GrafanaDashboard()
+	if err != nil {
+		t.Fatalf("GrafanaDashboard: %v", err)
+	}
+	var d grafanaDashboard
+	if err := json.Unmarshal(data, &d); err != nil {
+		t.Fatalf("dashboard is not valid JSON: %v", err)
+	}
+	for i := 0; i < len(All); i++ {
+		if !strings.Contains(string(data), All[i].Name) {
+			t.Errorf("dashboard has no panel for %s", All[i].Name)
+		}
+	}
+	ids := map[int]bool{}
+	for i := 0; i < len(d.Panels); i++ {
+		if ids[d.Panels[i].ID] {
+			t.Fatalf("duplicate panel id %d", d.Panels[i].ID)
+		}
+		ids[d.Panels[i].ID] = true
+	}
+}
+
+func TestPrometheusAlertRulesReferenceRegistry(t *testing.T) {
+	data, err := PrometheusAlertRules()
+	if err != nil {
+		t.Fatalf("PrometheusAlertRules: %v", err)
+	}
+	var rf ruleFile
+	if err := yaml.Unmarshal(data, &rf); err != nil {
+		t.Fatalf("rules are not valid YAML: %v", err)
+	}
+	if len(rf.Groups) != 1 || len(rf.Groups[0].Rules) != len(Alerts) {
+		t.Fatalf("expected %d rules in one group, got %+v", len(Alerts), rf.Groups)
+	}
+	for _, r := range rf.Groups[0].Rules {
+		known := false
+		for i := 0; i < len(All); i++ {
+			known = known || strings.Contains(r.Expr, All[i].Name)
+		}
+		if !known {
+			t.Errorf("alert %s references no registered metric: %s", r.Alert, r.Expr)
+		}
+	}
+}
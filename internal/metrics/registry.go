@@ -0,0 +1,235 @@
+// Package metrics is the registry of every metric Logryph exports. The /metrics
+// handler, the push emitters and the generated Grafana dashboard and alert rules all
+// read their names from here, so a renamed or added metric cannot drift between them.
+package metrics
+
+// Metric types, as written in Prometheus TYPE lines.
+const (
+	TypeCounter   = "counter"
+	TypeGauge     = "gauge"
+	TypeHistogram = "histogram"
+)
+
+// Desc describes one exported metric.
+type Desc struct {
+	Name   string
+	Help   string
+	Type   string
+	Unit   string   // Grafana unit for dashboard panels, e.g. "s" or "short"
+	Labels []string // label names carried by the series
+	Panel  string   // dashboard row the metric is shown in
+}
+
+// Alert is a Prometheus alerting rule shipped with the generated rules file.
+type Alert struct {
+	Name     string
+	Expr     string
+	For      string
+	Severity string
+	Summary  string
+}
+
+// Dashboard rows.
+const (
+	RowLedger   = "Ledger"
+	RowEngine   = "Engine"
+	RowPool     = "Event pool"
+	RowProxy    = "Proxy"
+	RowCapacity = "Capacity"
+)
+
+// Exported metrics.
+var (
+	PoolEventHits = Desc{
+		Name: "logryph_pool_event_hits_total", Help: "Total hits on the event pool",
+		Type: TypeCounter, Unit: "short", Panel: RowPool,
+	}
+	PoolEventMisses = Desc{
+		Name: "logryph_pool_event_misses_total", Help: "Total misses (allocations) in the event pool",
+		Type: TypeCounter, Unit: "short", Panel: RowPool,
+	}
+	EventsProcessed = Desc{
+		Name: "logryph_ledger_events_processed_total", Help: "Total events successfully written to the ledger",
+		Type: TypeCounter, Unit: "short", Panel: RowLedger,
+	}
+	EventsDropped = Desc{
+		Name: "logryph_ledger_events_dropped_total", Help: "Total events dropped due to backpressure",
+		Type: TypeCounter, Unit: "short", Panel: RowLedger,
+	}
+	EventsBlocked = Desc{
+		Name: "logryph_ledger_events_blocked_total", Help: "Total submit attempts blocked by backpressure",
+		Type: TypeCounter, Unit: "short", Panel: RowLedger,
+	}
+	BackpressureMode = Desc{
+		Name: "logryph_ledger_backpressure_mode", Help: "Current backpressure mode (drop|block)",
+		Type: TypeGauge, Unit: "short", Labels: []string{"mode"}, Panel: RowLedger,
+	}
+	LedgerGuarantee = Desc{
+		Name: "logryph_ledger_guarantee", Help: "Configured ledger guarantee (fail_open|fail_closed|degraded)",
+		Type: TypeGauge, Unit: "short", Labels: []string{"mode"}, Panel: RowLedger,
+	}
+	LedgerRecording = Desc{
+		Name: "logryph_ledger_recording", Help: "1 while the ledger can record events, 0 while it cannot",
+		Type: TypeGauge, Unit: "short", Panel: RowLedger,
+	}
+	ActiveTasks = Desc{
+		Name: "logryph_engine_active_tasks_total", Help: "Number of currently active causal tasks",
+		Type: TypeGauge, Unit: "short", Panel: RowEngine,
+	}
+	QueueDepth = Desc{
+		Name: "logryph_ledger_queue_depth", Help: "Current queue depth",
+		Type: TypeGauge, Unit: "short", Panel: RowLedger,
+	}
+	QueueCapacity = Desc{
+		Name: "logryph_ledger_queue_capacity", Help: "Queue capacity",
+		Type: TypeGauge, Unit: "short", Panel: RowLedger,
+	}
+	UpstreamTimeouts = Desc{
+		Name: "logryph_proxy_upstream_timeouts_total", Help: "Total calls that exceeded their upstream timeout",
+		Type: TypeCounter, Unit: "short", Panel: RowProxy,
+	}
+	UpstreamErrors = Desc{
+		Name: "logryph_proxy_upstream_errors_total", Help: "Total calls that failed to reach the upstream",
+		Type: TypeCounter, Unit: "short", Panel: RowProxy,
+	}
+	LedgerRejections = Desc{
+		Name: "logryph_proxy_ledger_rejections_total", Help: "Total calls refused because the ledger could not record them",
+		Type: TypeCounter, Unit: "short", Panel: RowProxy,
+	}
+	UnrecordedCalls = Desc{
+		Name: "logryph_proxy_unrecorded_calls_total", Help: "Total calls forwarded with only a logged digest while the ledger could not record",
+		Type: TypeCounter, Unit: "short", Panel: RowProxy,
+	}
+	ProxyInFlight = Desc{
+		Name: "logryph_proxy_in_flight", Help: "Calls currently forwarded to the upstream",
+		Type: TypeGauge, Unit: "short", Panel: RowProxy,
+	}
+	ProxyQueueLength = Desc{
+		Name: "logryph_proxy_queue_length", Help: "Calls currently waiting for an upstream slot",
+		Type: TypeGauge, Unit: "short", Panel: RowProxy,
+	}
+	ProxyQueued = Desc{
+		Name: "logryph_proxy_queued_total", Help: "Total calls that waited for an upstream slot",
+		Type: TypeCounter, Unit: "short", Panel: RowProxy,
+	}
+	ProxyQueueWait = Desc{
+		Name: "logryph_proxy_queue_wait_seconds_total", Help: "Total time calls spent waiting for an upstream slot",
+		Type: TypeCounter, Unit: "s", Panel: RowProxy,
+	}
+	ProxyThrottled = Desc{
+		Name: "logryph_proxy_throttled_total", Help: "Total calls refused an upstream slot by the concurrency cap",
+		Type: TypeCounter, Unit: "short", Panel: RowProxy,
+	}
+	ToolLatency = Desc{
+		Name: "logryph_tool_latency_seconds", Help: "Upstream tool latency by method over the last 5 minutes",
+		Type: TypeGauge, Unit: "s", Labels: []string{"method", "quantile"}, Panel: RowProxy,
+	}
+	SLOViolations = Desc{
+		Name: "logryph_slo_violations_total", Help: "Total latency objective violations recorded",
+		Type: TypeCounter, Unit: "short", Labels: []string{"method"}, Panel: RowProxy,
+	}
+	LedgerDBBytes = Desc{
+		Name: "logryph_ledger_db_bytes", Help: "Size of the ledger database and its write-ahead log",
+		Type: TypeGauge, Unit: "bytes", Panel: RowCapacity,
+	}
+	LedgerGrowthEvents = Desc{
+		Name: "logryph_ledger_growth_events_per_day", Help: "Events recorded per day, averaged over the capacity window",
+		Type: TypeGauge, Unit: "short", Panel: RowCapacity,
+	}
+	LedgerGrowthBytes = Desc{
+		Name: "logryph_ledger_growth_bytes_per_day", Help: "Bytes the ledger grows by per day at the current rate",
+		Type: TypeGauge, Unit: "bytes", Panel: RowCapacity,
+	}
+	LedgerProjectedBytes = Desc{
+		Name: "logryph_ledger_projected_bytes", Help: "Ledger size projected at the retention horizon",
+		Type: TypeGauge, Unit: "bytes", Panel: RowCapacity,
+	}
+	LedgerDiskFree = Desc{
+		Name: "logryph_ledger_disk_free_bytes", Help: "Free space on the filesystem holding the ledger",
+		Type: TypeGauge, Unit: "bytes", Panel: RowCapacity,
+	}
+	LedgerDiskTotal = Desc{
+		Name: "logryph_ledger_disk_total_bytes", Help: "Size of the filesystem holding the ledger",
+		Type: TypeGauge, Unit: "bytes", Panel: RowCapacity,
+	}
+	LedgerDaysUntilFull = Desc{
+		Name: "logryph_ledger_disk_days_until_full", Help: "Days until the ledger fills its disk at the current rate",
+		Type: TypeGauge, Unit: "d", Panel: RowCapacity,
+	}
+	EventLatency = Desc{
+		Name: "logryph_ledger_event_latency_seconds", Help: "Event processing latency",
+		Type: TypeHistogram, Unit: "s", Labels: []string{"le"}, Panel: RowLedger,
+	}
+)
+
+// All lists every exported metric in /metrics order.
+var All = []Desc{
+	PoolEventHits, PoolEventMisses,
+	EventsProcessed, EventsDropped, EventsBlocked, BackpressureMode, LedgerGuarantee, LedgerRecording,
+	ActiveTasks, QueueDepth, QueueCapacity,
+	UpstreamTimeouts, UpstreamErrors, LedgerRejections, UnrecordedCalls, ProxyInFlight, ProxyQueueLength, ProxyQueued, ProxyQueueWait, ProxyThrottled,
+	ToolLatency, SLOViolations,
+	LedgerDBBytes, LedgerGrowthEvents, LedgerGrowthBytes, LedgerProjectedBytes, LedgerDiskFree, LedgerDiskTotal, LedgerDaysUntilFull,
+	EventLatency,
+}
+
+// Alerts are the recommended alerting rules over the registry's metrics.
+var Alerts = []Alert{
+	{
+		Name: "LogryphEventsDropped", Expr: "increase(" + EventsDropped.Name + "[5m]) > 0",
+		For: "0m", Severity: "critical",
+		Summary: "Ledger events are being dropped; the audit trail has gaps",
+	},
+	{
+		Name: "LogryphSubmitsBlocked", Expr: "increase(" + EventsBlocked.Name + "[5m]) > 0",
+		For: "5m", Severity: "warning",
+		Summary: "Backpressure is blocking agent calls while the ledger catches up",
+	},
+	{
+		Name: "LogryphQueueNearlyFull", Expr: QueueDepth.Name + " / " + QueueCapacity.Name + " > 0.8",
+		For: "2m", Severity: "warning",
+		Summary: "Ledger queue is above 80% of capacity",
+	},
+	{
+		Name:     "LogryphLedgerLatencyHigh",
+		Expr:     "histogram_quantile(0.99, sum(rate(" + EventLatency.Name + "_bucket[5m])) by (le)) > 0.5",
+		For:      "10m",
+		Severity: "warning",
+		Summary:  "p99 ledger write latency is above 500ms",
+	},
+	{
+		Name: "LogryphLedgerNotRecording", Expr: LedgerRecording.Name + " == 0",
+		For: "1m", Severity: "critical",
+		Summary: "The ledger cannot record events; calls are unrecorded, digest-only or refused",
+	},
+	{
+		Name: "LogryphUpstreamTimeouts", Expr: "increase(" + UpstreamTimeouts.Name + "[5m]) > 0",
+		For: "5m", Severity: "warning",
+		Summary: "Tool calls are timing out waiting for the upstream server",
+	},
+	{
+		Name: "LogryphUpstreamErrors", Expr: "increase(" + UpstreamErrors.Name + "[5m]) > 0",
+		For: "5m", Severity: "warning",
+		Summary: "Tool calls are failing to reach the upstream server",
+	},
+	{
+		Name: "LogryphProxyThrottling", Expr: "increase(" + ProxyThrottled.Name + "[5m]) > 0",
+		For: "5m", Severity: "warning",
+		Summary: "The upstream concurrency cap is refusing agent calls",
+	},
+	{
+		Name: "LogryphSLOViolation", Expr: "increase(" + SLOViolations.Name + "[15m]) > 0",
+		For: "0m", Severity: "warning",
+		Summary: "A tool method is missing its latency objective",
+	},
+	{
+		Name: "LogryphLedgerDiskHeadroomLow", Expr: LedgerDiskFree.Name + " / " + LedgerDiskTotal.Name + " < 0.2",
+		For: "15m", Severity: "warning",
+		Summary: "Less than 20% of the ledger's disk is free",
+	},
+	{
+		Name: "LogryphLedgerDiskFillsSoon", Expr: LedgerDaysUntilFull.Name + " < 14",
+		For: "1h", Severity: "critical",
+		Summary: "At the current growth rate the ledger fills its disk within two weeks",
+	},
+}
@@ -0,0 +1,33 @@
+package models
+
+import "time"
+
+// EventTypeAnnotation marks investigator notes. Annotations are signed and chained like any
+// other event and point at the annotated event through ParentID.
+const EventTypeAnnotation = "annotation"
+
+// Annotation limits, enforced by the API before an annotation event is ledgered.
+const (
+	MaxAnnotationNoteLen   = 4096
+	MaxAnnotationAuthorLen = 128
+)
+
+// Annotation is an investigator note on a ledgered event, indexed from its annotation event.
+type Annotation struct {
+	ID        string    `json:"id"`       // ID of the annotation event
+	EventID   string    `json:"event_id"` // annotated event
+	Author    string    `json:"author"`
+	Note      string    `json:"note"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// AnnotationFromEvent extracts the annotation carried by an annotation event.
+// Returns false for other event types or events missing a target.
+func AnnotationFromEvent(e *Event) (Annotation, bool) {
+	if e == nil || e.EventType != EventTypeAnnotation || e.ParentID == "" {
+		return Annotation{}, false
+	}
+	author, _ := e.Params["author"].(string)
+	note, _ := e.Params["note"].(string)
+	return Annotation{ID: e.ID, EventID: e.ParentID, Author: author, Note: note, CreatedAt: e.Timestamp}, true
+}
@@ -0,0 +1,24 @@
+package models
+
+import "time"
+
+// Case item kinds.
+const (
+	CaseItemEvent = "event"
+	CaseItemTask  = "task"
+)
+
+// Case groups related events and tasks, possibly from different runs, into one investigation.
+type Case struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// CaseItem is one artifact attached to a case: a single event or every event of a task.
+type CaseItem struct {
+	CaseID  string    `json:"case_id"`
+	Kind    string    `json:"kind"` // event | task
+	Ref     string    `json:"ref"`
+	AddedAt time.Time `json:"added_at"`
+}
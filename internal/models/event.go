@@ -7,6 +7,7 @@ import (
 // Event represents a single intercepted MCP JSON-RPC action in the agent's execution.
 // Includes cryptographic chain fields (PrevHash, CurrentHash, Signature) for forensic integrity.
 // Use pool.GetEvent() to acquire instances for zero-allocation hot paths.
+// It is the only event type: the proxy, both ledger stores, exports and logyctl all use it.
 type Event struct {
 	ID          string                 `json:"id"`
 	RunID       string                 `json:"run_id"`
@@ -22,8 +23,107 @@ type Event struct {
 	ParentID    string                 `json:"parent_id,omitempty"`  // Hierarchy tracking
 	PolicyID    string                 `json:"policy_id,omitempty"`
 	RiskLevel   string                 `json:"risk_level,omitempty"`
-	PrevHash    string                 `json:"prev_hash"`
-	CurrentHash string                 `json:"current_hash"`
-	Signature   string                 `json:"signature"`
-	WasBlocked  bool                   `json:"was_blocked"`
+	Environment string                 `json:"environment,omitempty"` // dev | staging | prod (deployment profile)
+	Tags        []string               `json:"tags,omitempty"`        // detector findings, e.g. schema_violation
+	Labels      map[string]string      `json:"labels,omitempty"`      // organizational key=value labels, e.g. team=payments
+	// ResponseValue holds a result that is not a JSON object (an array, string, number or
+	// boolean), recorded as returned. Response is nil when it is set.
+	ResponseValue interface{} `json:"response_value,omitempty"`
+	// CorrelationID is the X-Logryph-Request-ID of the HTTP exchange, shared by a call and its response.
+	CorrelationID string `json:"correlation_id,omitempty"`
+	TraceID       string `json:"trace_id,omitempty"` // W3C trace-id from the caller's traceparent
+	SpanID        string `json:"span_id,omitempty"`  // caller's span (traceparent parent-id)
+	// Headers holds allowlisted HTTP headers: request headers on tool_call, response headers
+	// on tool_response. Credentials are redacted before capture.
+	Headers     map[string]string `json:"headers,omitempty"`
+	QueryParams map[string]string `json:"query_params,omitempty"` // request query string, when captured
+	PrevHash    string            `json:"prev_hash"`
+	CurrentHash string            `json:"current_hash"`
+	Signature   string            `json:"signature"`
+	WasBlocked  bool              `json:"was_blocked"`
+}
+
+// HashPayload returns the field set covered by CurrentHash.
+// Fields added after the 2026.1 ledger format are only included when set,
+// so events written before they existed keep verifying unchanged.
+func (e *Event) HashPayload() map[string]interface{} {
+	payload := map[string]interface{}{
+		"id":         e.ID,
+		"run_id":     e.RunID,
+		"seq_index":  e.SeqIndex,
+		"timestamp":  e.Timestamp.Format(time.RFC3339Nano),
+		"actor":      e.Actor,
+		"event_type": e.EventType,
+		"method":     e.Method,
+		"params":     e.Params,
+		"response":   e.Response,
+		"task_id":    e.TaskID,
+		"task_state": e.TaskState,
+		"parent_id":  e.ParentID,
+		"policy_id":  e.PolicyID,
+		"risk_level": e.RiskLevel,
+	}
+	if e.ResponseValue != nil {
+		payload["response_value"] = e.ResponseValue
+	}
+	if e.Environment != "" {
+		payload["environment"] = e.Environment
+	}
+	if len(e.Tags) > 0 {
+		payload["tags"] = e.Tags
+	}
+	if len(e.Labels) > 0 {
+		payload["labels"] = e.Labels
+	}
+	if e.CorrelationID != "" {
+		payload["correlation_id"] = e.CorrelationID
+	}
+	if e.TraceID != "" {
+		payload["trace_id"] = e.TraceID
+		payload["span_id"] = e.SpanID
+	}
+	if len(e.Headers) > 0 {
+		payload["headers"] = e.Headers
+	}
+	if len(e.QueryParams) > 0 {
+		payload["query_params"] = e.QueryParams
+	}
+	return payload
+}
+
+// LabelCanonicalMethod is the label a call carries when the policy's method_aliases map
+// its method to a canonical name. Method keeps the name the agent sent.
+const LabelCanonicalMethod = "canonical_method"
+
+// CanonicalMethod returns the method name rules and analytics use for the event: its
+// canonical_method label when set, else Method.
+func (e *Event) CanonicalMethod() string {
+	if m := e.Labels[LabelCanonicalMethod]; m != "" {
+		return m
+	}
+	return e.Method
+}
+
+// MaxEventTags bounds the number of tags a single event can carry.
+const MaxEventTags = 64
+
+// HasTag reports whether the event carries the given tag.
+func (e *Event) HasTag(tag string) bool {
+	for i := 0; i < MaxEventTags; i++ {
+		if i >= len(e.Tags) {
+			break
+		}
+		if e.Tags[i] == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// AddTag appends a tag unless the event already carries it or the tag limit is reached.
+func (e *Event) AddTag(tag string) {
+	if tag == "" || len(e.Tags) >= MaxEventTags || e.HasTag(tag) {
+		return
+	}
+	e.Tags = append(e.Tags, tag)
 }
@@ -0,0 +1,66 @@
+package models
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Label limits. Labels are part of the hashed event, so they are kept small.
+const (
+	MaxEventLabels    = 16
+	MaxLabelValueLen  = 128
+	maxLabelSelectors = 16
+)
+
+var labelKeyPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_./-]{0,62}$`)
+
+// ValidateLabels checks label count, key syntax (lowercase, up to 63 bytes) and value length.
+func ValidateLabels(labels map[string]string) error {
+	if len(labels) > MaxEventLabels {
+		return fmt.Errorf("too many labels: %d (max %d)", len(labels), MaxEventLabels)
+	}
+	for k, v := range labels {
+		if !labelKeyPattern.MatchString(k) {
+			return fmt.Errorf("invalid label key %q: use lowercase letters, digits, '_', '.', '-' or '/'", k)
+		}
+		if len(v) > MaxLabelValueLen || strings.ContainsAny(v, "\x00\n\r\t") {
+			return fmt.Errorf("invalid value for label %q: at most %d bytes, no control characters", k, MaxLabelValueLen)
+		}
+	}
+	return nil
+}
+
+// ParseLabels parses key=value pairs, each possibly a comma-separated list
+// (e.g. "team=payments,env=prod"), into a validated label map.
+func ParseLabels(pairs []string) (map[string]string, error) {
+	labels := make(map[string]string)
+	for i := 0; i < len(pairs) && i < maxLabelSelectors; i++ {
+		parts := strings.Split(pairs[i], ",")
+		for j := 0; j < len(parts) && j < maxLabelSelectors; j++ {
+			k, v, ok := strings.Cut(strings.TrimSpace(parts[j]), "=")
+			if !ok || k == "" {
+				return nil, fmt.Errorf("invalid label %q: expected key=value", parts[j])
+			}
+			labels[k] = v
+		}
+	}
+	if err := ValidateLabels(labels); err != nil {
+		return nil, err
+	}
+	return labels, nil
+}
+
+// FormatLabels renders labels as sorted key=value pairs, e.g. "env=prod,team=payments".
+func FormatLabels(labels map[string]string) string {
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for i := 0; i < len(keys); i++ {
+		keys[i] = keys[i] + "=" + labels[keys[i]]
+	}
+	return strings.Join(keys, ",")
+}
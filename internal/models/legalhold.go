@@ -0,0 +1,91 @@
+package models
+
+import "time"
+
+// Legal hold events. A hold is set and released by signed events in the ledger, so who
+// held what, and when, is part of the evidence.
+const (
+	EventTypeLegalHoldSet      = "legal_hold_set"
+	EventTypeLegalHoldReleased = "legal_hold_released"
+)
+
+// Kinds of legal hold: every event of a task, or everything attached to a case.
+const (
+	HoldKindTask = "task"
+	HoldKindCase = "case"
+)
+
+// Legal hold limits, enforced by the API before a hold event is ledgered.
+const (
+	MaxHoldReasonLen    = 1024
+	MaxHoldPrincipalLen = 128
+)
+
+// LegalHold is one hold, as recorded by the hold event that set it.
+type LegalHold struct {
+	Kind      string    `json:"kind"` // task | case
+	Ref       string    `json:"ref"`  // task ID, or case ID
+	Reason    string    `json:"reason"`
+	Principal string    `json:"principal"` // who asked for the hold
+	SetAt     time.Time `json:"set_at"`
+	EventID   string    `json:"event_id"` // the legal_hold_set event
+}
+
+// LegalHoldFromEvent extracts the hold a legal_hold_set or legal_hold_released event
+// records. Returns false for other events.
+func LegalHoldFromEvent(e *Event) (LegalHold, bool) {
+	if e == nil || (e.EventType != EventTypeLegalHoldSet && e.EventType != EventTypeLegalHoldReleased) {
+		return LegalHold{}, false
+	}
+	kind, _ := e.Params["kind"].(string)
+	ref, _ := e.Params["ref"].(string)
+	if ref == "" || (kind != HoldKindTask && kind != HoldKindCase) {
+		return LegalHold{}, false
+	}
+	reason, _ := e.Params["reason"].(string)
+	principal, _ := e.Params["principal"].(string)
+	return LegalHold{Kind: kind, Ref: ref, Reason: reason, Principal: principal, SetAt: e.Timestamp, EventID: e.ID}, true
+}
+
+// ActiveLegalHolds replays hold events, oldest first, and returns the holds still set.
+func ActiveLegalHolds(events []Event) []LegalHold {
+	active := make(map[string]int)
+	var holds []LegalHold
+	for i := 0; i < len(events); i++ {
+		h, ok := LegalHoldFromEvent(&events[i])
+		if !ok {
+			continue
+		}
+		key := h.Kind + "/" + h.Ref
+		j, held := active[key]
+		switch {
+		case events[i].EventType == EventTypeLegalHoldSet && !held:
+			active[key] = len(holds)
+			holds = append(holds, h)
+		case events[i].EventType == EventTypeLegalHoldReleased && held:
+			holds[j].Ref = "" // released; dropped below
+			delete(active, key)
+		}
+	}
+	out := holds[:0]
+	for i := 0; i < len(holds); i++ {
+		if holds[i].Ref != "" {
+			out = append(out, holds[i])
+		}
+	}
+	return out
+}
+
+// HoldScope is what the active legal holds cover.
+type HoldScope struct {
+	Tasks  map[string]bool
+	Events map[string]bool
+}
+
+// Covers reports whether e is under a legal hold.
+func (s *HoldScope) Covers(e *Event) bool {
+	if s == nil || e == nil {
+		return false
+	}
+	return (e.TaskID != "" && s.Tasks[e.TaskID]) || s.Events[e.ID]
+}
@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+// SavedQuery is a named event query that logyctl and scheduled reports run by name.
+type SavedQuery struct {
+	Name      string    `json:"name"`
+	Expr      string    `json:"expr"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// RiskQuery selects a page of risk events, newest first. After is the cursor returned
+// with the previous page: the ID of its last event.
+type RiskQuery struct {
+	Levels []string  // risk levels to include
+	Since  time.Time // zero for no lower bound
+	After  string
+	Limit  int
+}
@@ -0,0 +1,221 @@
+package notary
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/slyt3/Logryph/internal/assert"
+	"github.com/slyt3/Logryph/internal/logging"
+	"github.com/slyt3/Logryph/internal/models"
+	"github.com/slyt3/Logryph/internal/pool"
+)
+
+const (
+	requestTimeout    = 10 * time.Second
+	maxResponseBytes  = 64 * 1024
+	maxRequestsPerRun = 100
+	maxSyncTicks      = 1 << 30
+)
+
+// Source is the subset of the ledger the countersigner reads.
+type Source interface {
+	GetEventsByType(eventType string) ([]models.Event, error)
+}
+
+// Countersigner periodically sends checkpoints that an endpoint has not countersigned
+// yet and submits a notary_countersignature event for each signature it gets back.
+// A checkpoint a notary fails to sign is retried on the next pass.
+type Countersigner struct {
+	endpoints []Endpoint
+	src       Source
+	ledgerKey string
+	submit    func(*models.Event)
+	client    *http.Client
+	interval  time.Duration
+
+	mu   sync.Mutex      // serialises Sync and guards sent
+	sent map[string]bool // countersignatures submitted but maybe not yet committed
+
+	stop     chan struct{}
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+// NewCountersigner sends to the endpoints in c. ledgerKey is the ledger's hex public key,
+// included in each request; submit appends countersignature events, normally
+// Worker.Submit.
+func NewCountersigner(c Config, src Source, ledgerKey string, submit func(*models.Event)) (*Countersigner, error) {
+	if err := assert.NotNil(src, "notary source"); err != nil {
+		return nil, err
+	}
+	if submit == nil {
+		return nil, errors.New("countersigner needs a submit function")
+	}
+	if err := ValidateConfig(c); err != nil {
+		return nil, err
+	}
+	interval, err := c.interval()
+	if err != nil {
+		return nil, err
+	}
+	return &Countersigner{
+		endpoints: c.Endpoints, src: src, ledgerKey: ledgerKey, submit: submit,
+		client: &http.Client{Timeout: requestTimeout}, interval: interval,
+		sent: make(map[string]bool),
+		stop: make(chan struct{}), done: make(chan struct{}),
+	}, nil
+}
+
+// Start syncs every interval until Stop.
+func (c *Countersigner) Start() {
+	go c.run()
+}
+
+func (c *Countersigner) run() {
+	defer close(c.done)
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+	for i := 0; i < maxSyncTicks; i++ {
+		select {
+		case <-c.stop:
+			return
+		case <-ticker.C:
+			if err := c.Sync(); err != nil {
+				logging.Warn("notary_sync_failed", logging.Fields{Component: "notary", Error: err.Error()})
+			}
+		}
+	}
+}
+
+// Stop ends periodic syncs. Checkpoints not yet countersigned are sent by the next
+// process to run against this ledger.
+func (c *Countersigner) Stop() {
+	c.stopOnce.Do(func() { close(c.stop) })
+	<-c.done
+}
+
+// Sync requests a countersignature for every checkpoint an endpoint has not signed, up
+// to 100 requests per call. Failures are returned together; the rest still run.
+func (c *Countersigner) Sync() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	signed, err := c.countersigned()
+	if err != nil {
+		return err
+	}
+	var errs []error
+	requests := 0
+	for t := 0; t < len(CheckpointTypes); t++ {
+		checkpoints, err := c.src.GetEventsByType(CheckpointTypes[t])
+		if err != nil {
+			return fmt.Errorf("reading %s events: %w", CheckpointTypes[t], err)
+		}
+		for i := 0; i < len(checkpoints); i++ {
+			for j := 0; j < len(c.endpoints); j++ {
+				key := c.endpoints[j].Name + "\x00" + checkpoints[i].CurrentHash
+				if signed[key] || c.sent[key] {
+					continue
+				}
+				if requests >= maxRequestsPerRun {
+					return errors.Join(errs...)
+				}
+				requests++
+				if err := c.countersign(c.endpoints[j], &checkpoints[i]); err != nil {
+					errs = append(errs, fmt.Errorf("%s: event %s: %w", c.endpoints[j].Name, checkpoints[i].ID, err))
+					continue
+				}
+				c.sent[key] = true
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// countersigned returns the endpoint and checkpoint hash pairs already in the ledger.
+func (c *Countersigner) countersigned() (map[string]bool, error) {
+	events, err := c.src.GetEventsByType(EventTypeCountersignature)
+	if err != nil {
+		return nil, fmt.Errorf("reading countersignatures: %w", err)
+	}
+	signed := make(map[string]bool, len(events))
+	for i := 0; i < len(events); i++ {
+		cs, err := ParseCountersignature(&events[i])
+		if err != nil {
+			continue
+		}
+		signed[cs.Notary+"\x00"+cs.CheckpointHash] = true
+	}
+	return signed, nil
+}
+
+// countersign asks ep to sign checkpoint and submits the verified countersignature.
+func (c *Countersigner) countersign(ep Endpoint, checkpoint *models.Event) error {
+	sig, err := c.request(ep, checkpoint)
+	if err != nil {
+		return err
+	}
+	cs := &Countersignature{Notary: ep.Name, NotaryKey: ep.PublicKey, CheckpointHash: checkpoint.CurrentHash, Signature: sig}
+	if err := cs.Check(ep.PublicKey); err != nil {
+		return err
+	}
+	event := pool.GetEvent()
+	event.ID = uuid.New().String()[:8]
+	event.Timestamp = time.Now()
+	event.EventType = EventTypeCountersignature
+	event.Method = "logryph:countersign"
+	event.Actor = "system"
+	event.ParentID = checkpoint.ID
+	event.Params[ParamNotary] = ep.Name
+	event.Params[ParamNotaryKey] = ep.PublicKey
+	event.Params[ParamCheckpointID] = checkpoint.ID
+	event.Params[ParamCheckpointRun] = checkpoint.RunID
+	event.Params[ParamCheckpointSeq] = checkpoint.SeqIndex
+	event.Params[ParamCheckpointHash] = checkpoint.CurrentHash
+	event.Params[ParamSignature] = sig
+	eventID := event.ID
+	c.submit(event)
+	logging.Info("notary_countersigned", logging.Fields{Component: "notary", RunID: checkpoint.RunID, EventID: eventID})
+	return nil
+}
+
+// request POSTs checkpoint to ep and returns the signature from its response.
+func (c *Countersigner) request(ep Endpoint, checkpoint *models.Event) (string, error) {
+	body, err := json.Marshal(Request{
+		LedgerKey: c.ledgerKey, RunID: checkpoint.RunID, EventID: checkpoint.ID, EventType: checkpoint.EventType,
+		SeqIndex: checkpoint.SeqIndex, Hash: checkpoint.CurrentHash, Signature: checkpoint.Signature, Timestamp: checkpoint.Timestamp,
+	})
+	if err != nil {
+		return "", fmt.Errorf("encoding request: %w", err)
+	}
+	req, err := http.NewRequest(http.MethodPost, ep.URL, bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range ep.Headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return "", fmt.Errorf("notary returned %s", resp.Status)
+	}
+	var out Response
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxResponseBytes)).Decode(&out); err != nil {
+		return "", fmt.Errorf("decoding response: %w", err)
+	}
+	if out.Signature == "" {
+		return "", errors.New("response has no signature")
+	}
+	return out.Signature, nil
+}
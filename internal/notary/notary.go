@@ -0,0 +1,166 @@
+// Package notary has checkpoint events countersigned by external notaries. Each time an
+// anchor, WORM segment or run rotation is ledgered, its hash is POSTed to every
+// configured notary endpoint. The notary returns an Ed25519 signature over the hash with
+// a key the ledger operator does not hold, and that countersignature is ledgered as a
+// notary_countersignature event. Two parties that do not trust each other (a customer and
+// a vendor, say) can then both attest to the same ledger state.
+package notary
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/slyt3/Logryph/internal/crypto"
+	"github.com/slyt3/Logryph/internal/ledger/audit"
+	"github.com/slyt3/Logryph/internal/models"
+	"github.com/slyt3/Logryph/internal/worm"
+)
+
+// EventTypeCountersignature records a notary's signature over a checkpoint's hash.
+const EventTypeCountersignature = "notary_countersignature"
+
+// Countersignature event params.
+const (
+	ParamNotary         = "notary"          // endpoint name
+	ParamNotaryKey      = "notary_pubkey"   // hex Ed25519 key the notary signed with
+	ParamCheckpointID   = "checkpoint_id"   // the countersigned event, also its parent_id
+	ParamCheckpointRun  = "checkpoint_run"  // its run
+	ParamCheckpointSeq  = "checkpoint_seq"  // its seq
+	ParamCheckpointHash = "checkpoint_hash" // its current_hash, which the notary signed
+	ParamSignature      = "countersignature"
+)
+
+const (
+	defaultInterval = time.Minute
+	minInterval     = 5 * time.Second
+	maxEndpoints    = 16
+	maxNameLen      = 64
+)
+
+// CheckpointTypes are the event types sent for countersigning.
+var CheckpointTypes = []string{"anchor", worm.EventTypeSegment, audit.EventTypeRunClosed}
+
+// Config is the notary section of the policy file. No endpoints disables countersigning.
+type Config struct {
+	Interval  string     `yaml:"interval,omitempty"` // how often to look for new checkpoints; default 1m
+	Endpoints []Endpoint `yaml:"endpoints,omitempty"`
+}
+
+// Endpoint is one notary. PublicKey pins the key its countersignatures must verify
+// against; responses signed with any other key are rejected.
+type Endpoint struct {
+	Name      string            `yaml:"name"`
+	URL       string            `yaml:"url"`
+	PublicKey string            `yaml:"public_key"` // hex Ed25519
+	Headers   map[string]string `yaml:"headers,omitempty"`
+}
+
+// Request is the JSON body POSTed to a notary for each checkpoint. Signature is the
+// ledger's own signature over Hash, verifiable with LedgerKey.
+type Request struct {
+	LedgerKey string    `json:"ledger_pubkey"`
+	RunID     string    `json:"run_id"`
+	EventID   string    `json:"event_id"`
+	EventType string    `json:"event_type"`
+	SeqIndex  uint64    `json:"seq_index"`
+	Hash      string    `json:"hash"`
+	Signature string    `json:"signature"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// Response is a notary's reply: its hex Ed25519 signature over Request.Hash.
+type Response struct {
+	Signature string `json:"signature"`
+}
+
+// ValidateConfig checks the notary section. A section without endpoints is always valid.
+func ValidateConfig(c Config) error {
+	if len(c.Endpoints) == 0 {
+		return nil
+	}
+	if _, err := c.interval(); err != nil {
+		return err
+	}
+	if len(c.Endpoints) > maxEndpoints {
+		return fmt.Errorf("%d endpoints exceed max of %d", len(c.Endpoints), maxEndpoints)
+	}
+	seen := make(map[string]bool, len(c.Endpoints))
+	for i := 0; i < len(c.Endpoints); i++ {
+		e := c.Endpoints[i]
+		if e.Name == "" || len(e.Name) > maxNameLen {
+			return fmt.Errorf("endpoints[%d]: invalid name %q", i, e.Name)
+		}
+		if seen[e.Name] {
+			return fmt.Errorf("endpoint %s: defined twice", e.Name)
+		}
+		seen[e.Name] = true
+		u, err := url.Parse(e.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("endpoint %s: url must be an absolute http(s) URL", e.Name)
+		}
+		if key, err := hex.DecodeString(e.PublicKey); err != nil || len(key) != 32 {
+			return fmt.Errorf("endpoint %s: public_key must be a hex Ed25519 public key", e.Name)
+		}
+	}
+	return nil
+}
+
+func (c Config) interval() (time.Duration, error) {
+	if c.Interval == "" {
+		return defaultInterval, nil
+	}
+	d, err := time.ParseDuration(c.Interval)
+	if err != nil || d < minInterval {
+		return 0, fmt.Errorf("invalid interval %q: must be a duration of at least %s", c.Interval, minInterval)
+	}
+	return d, nil
+}
+
+// Countersignature is a notary_countersignature event's params.
+type Countersignature struct {
+	Notary         string
+	NotaryKey      string
+	CheckpointID   string
+	CheckpointRun  string
+	CheckpointSeq  uint64
+	CheckpointHash string
+	Signature      string
+}
+
+// ParseCountersignature reads the params of a notary_countersignature event.
+func ParseCountersignature(e *models.Event) (*Countersignature, error) {
+	if e.EventType != EventTypeCountersignature {
+		return nil, fmt.Errorf("event %s is a %s, not a countersignature", e.ID, e.EventType)
+	}
+	str := func(key string) string { s, _ := e.Params[key].(string); return s }
+	cs := &Countersignature{
+		Notary: str(ParamNotary), NotaryKey: str(ParamNotaryKey), CheckpointID: str(ParamCheckpointID),
+		CheckpointRun: str(ParamCheckpointRun), CheckpointHash: str(ParamCheckpointHash), Signature: str(ParamSignature),
+	}
+	switch seq := e.Params[ParamCheckpointSeq].(type) {
+	case float64:
+		cs.CheckpointSeq = uint64(seq)
+	case uint64:
+		cs.CheckpointSeq = seq
+	}
+	if cs.Notary == "" || cs.NotaryKey == "" || cs.CheckpointID == "" || cs.CheckpointHash == "" || cs.Signature == "" {
+		return nil, fmt.Errorf("countersignature %s is missing params", e.ID)
+	}
+	return cs, nil
+}
+
+// Check verifies the notary's signature over the checkpoint hash. With pinnedKey set, the
+// countersignature must have been made with that key.
+func (cs *Countersignature) Check(pinnedKey string) error {
+	if pinnedKey != "" && !strings.EqualFold(pinnedKey, cs.NotaryKey) {
+		return fmt.Errorf("notary %s signed with %s, expected %s", cs.Notary, cs.NotaryKey, pinnedKey)
+	}
+	if !crypto.VerifyWithPublicKey(cs.NotaryKey, cs.CheckpointHash, cs.Signature) {
+		return errors.New("countersignature does not match the checkpoint hash")
+	}
+	return nil
+}
@@ -0,0 +1,118 @@
+package notary
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	"github.com/slyt3/Logryph/internal/crypto"
+	"github.com/slyt3/Logryph/internal/ledger"
+	"github.com/slyt3/Logryph/internal/ledger/memstore"
+	"github.com/slyt3/Logryph/internal/ledger/memstore/memstoretest"
+	"github.com/slyt3/Logryph/internal/models"
+)
+
+// newNotary serves countersignatures made with its own key and counts requests.
+func newNotary(t *testing.T) (*httptest.Server, *crypto.Signer, *atomic.Int32) {
+	t.Helper()
+	signer, err := crypto.NewSigner(filepath.Join(t.TempDir(), "notary.key"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var requests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		var req Request
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Hash == "" || req.LedgerKey == "" {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		sig, _ := signer.SignHash(req.Hash)
+		_ = json.NewEncoder(w).Encode(Response{Signature: sig})
+	}))
+	t.Cleanup(srv.Close)
+	return srv, signer, &requests
+}
+
+// newLedger records a run whose middle event is an anchor checkpoint.
+func newLedger(t *testing.T) (*memstore.Store, *ledger.EventProcessor, *crypto.Signer) {
+	t.Helper()
+	r := memstoretest.NewRun(t)
+	for i, typ := range []string{"tool_call", "anchor", "tool_call"} {
+		r.Record(&models.Event{ID: "e" + string(rune('0'+i)), EventType: typ, Method: "os.read", Params: map[string]interface{}{}})
+	}
+	return r.Store, r.Processor, r.Signer
+}
+
+func TestCountersignerRecordsVerifiedSignatures(t *testing.T) {
+	srv, notarySigner, requests := newNotary(t)
+	mem, p, signer := newLedger(t)
+	cfg := Config{Endpoints: []Endpoint{{Name: "customer", URL: srv.URL, PublicKey: notarySigner.GetPublicKey()}}}
+	c, err := NewCountersigner(cfg, mem, signer.GetPublicKey(), func(e *models.Event) {
+		if err := p.ProcessEvent(e); err != nil {
+			t.Errorf("ProcessEvent: %v", err)
+		}
+	})
+	if err != nil {
+		t.Fatalf("NewCountersigner: %v", err)
+	}
+	if err := c.Sync(); err != nil {
+		t.Fatalf("Sync: %v", err)
+	}
+	records, err := mem.GetEventsByType(EventTypeCountersignature)
+	if err != nil || len(records) != 1 {
+		t.Fatalf("countersignatures = %d, %v; want 1", len(records), err)
+	}
+	cs, err := ParseCountersignature(&records[0])
+	if err != nil || cs.CheckpointID != "e1" || records[0].ParentID != "e1" {
+		t.Fatalf("ParseCountersignature = %+v, %v", cs, err)
+	}
+	if err := cs.Check(notarySigner.GetPublicKey()); err != nil {
+		t.Errorf("Check: %v", err)
+	}
+
+	c.sent = map[string]bool{} // as after a restart: only the ledger says what is signed
+	if err := c.Sync(); err != nil || requests.Load() != 1 {
+		t.Errorf("a countersigned checkpoint must not be sent again: %d requests, %v", requests.Load(), err)
+	}
+}
+
+func TestCountersignerRejectsTheWrongKey(t *testing.T) {
+	srv, _, _ := newNotary(t)
+	mem, _, signer := newLedger(t)
+	other, _ := crypto.NewSigner(filepath.Join(t.TempDir(), "other.key"))
+	cfg := Config{Endpoints: []Endpoint{{Name: "vendor", URL: srv.URL, PublicKey: other.GetPublicKey()}}}
+	var submitted int
+	c, err := NewCountersigner(cfg, mem, signer.GetPublicKey(), func(*models.Event) { submitted++ })
+	if err != nil {
+		t.Fatalf("NewCountersigner: %v", err)
+	}
+	if err := c.Sync(); err == nil || submitted != 0 {
+		t.Errorf("a signature from an unpinned key must be refused: %v, %d submitted", err, submitted)
+	}
+}
+
+func TestValidateConfig(t *testing.T) {
+	key := "7fcf330f5123f63b429f5f0657dfad8b44c0890fd076099b9f418d3da9bf3184"
+	good := Endpoint{Name: "a", URL: "https://notary.example/sign", PublicKey: key}
+	cases := []struct {
+		name string
+		cfg  Config
+		ok   bool
+	}{
+		{"disabled", Config{}, true},
+		{"valid", Config{Interval: "30s", Endpoints: []Endpoint{good}}, true},
+		{"short interval", Config{Interval: "1s", Endpoints: []Endpoint{good}}, false},
+		{"duplicate", Config{Endpoints: []Endpoint{good, good}}, false},
+		{"bad url", Config{Endpoints: []Endpoint{{Name: "a", URL: "notary", PublicKey: key}}}, false},
+		{"no key", Config{Endpoints: []Endpoint{{Name: "a", URL: good.URL}}}, false},
+	}
+	for _, tc := range cases {
+		if err := ValidateConfig(tc.cfg); (err == nil) != tc.ok {
+			t.Errorf("%s: ValidateConfig = %v, want ok=%v", tc.name, err, tc.ok)
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package notify
+
+import (
+	"bytes"
+	"crypto/tls"
+	"fmt"
+	"mime"
+	"net"
+	"net/smtp"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const maxSubjectLen = 120
+
+// sendMail delivers n to an email channel over SMTP, upgrading to TLS with STARTTLS when
+// the server offers it. Credentials in the URL are only sent over TLS or to localhost.
+func sendMail(c ChannelConfig, n Notification) error {
+	u, err := url.Parse(c.URL)
+	if err != nil {
+		return fmt.Errorf("channel %s: %w", c.Name, err)
+	}
+	host, addr := u.Hostname(), u.Host
+	if u.Port() == "" {
+		addr = net.JoinHostPort(host, "25")
+	}
+	conn, err := net.DialTimeout("tcp", addr, sendTimeout)
+	if err != nil {
+		return fmt.Errorf("channel %s: %w", c.Name, err)
+	}
+	if err := conn.SetDeadline(time.Now().Add(sendTimeout)); err != nil {
+		_ = conn.Close()
+		return fmt.Errorf("channel %s: %w", c.Name, err)
+	}
+	client, err := smtp.NewClient(conn, host)
+	if err != nil {
+		_ = conn.Close()
+		return fmt.Errorf("channel %s: %w", c.Name, err)
+	}
+	defer func() { _ = client.Close() }()
+	if err := deliverMail(client, host, u.User, c, n); err != nil {
+		return fmt.Errorf("channel %s: %w", c.Name, err)
+	}
+	return nil
+}
+
+func deliverMail(client *smtp.Client, host string, user *url.Userinfo, c ChannelConfig, n Notification) error {
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		if err := client.StartTLS(&tls.Config{ServerName: host, MinVersion: tls.VersionTLS12}); err != nil {
+			return err
+		}
+	}
+	if user != nil {
+		password, _ := user.Password()
+		if err := client.Auth(smtp.PlainAuth("", user.Username(), password, host)); err != nil {
+			return err
+		}
+	}
+	if err := client.Mail(c.From); err != nil {
+		return err
+	}
+	for i := 0; i < len(c.To) && i < maxRecipients; i++ {
+		if err := client.Rcpt(c.To[i]); err != nil {
+			return err
+		}
+	}
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(mailMessage(c, n)); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
+
+// mailMessage renders n as a plain-text RFC 5322 message.
+func mailMessage(c ChannelConfig, n Notification) []byte {
+	text := summary(n)
+	subject := n.Subject
+	if subject == "" {
+		subject, _, _ = strings.Cut(text, "\n")
+		if len(subject) > maxSubjectLen {
+			subject = subject[:maxSubjectLen]
+		}
+	}
+	date := n.Timestamp
+	if date.IsZero() {
+		date = time.Now()
+	}
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "From: %s\r\n", c.From)
+	fmt.Fprintf(&b, "To: %s\r\n", strings.Join(c.To, ", "))
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&b, "Date: %s\r\n", date.Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\nContent-Type: text/plain; charset=utf-8\r\n\r\n")
+	b.WriteString(strings.ReplaceAll(strings.ReplaceAll(text, "\r\n", "\n"), "\n", "\r\n"))
+	b.WriteString("\r\n")
+	return b.Bytes()
+}
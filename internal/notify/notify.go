@@ -0,0 +1,270 @@
+// Package notify delivers rule-match notifications to named channels (generic webhooks,
+// Slack incoming webhooks or email over SMTP). Delivery is asynchronous and best effort:
+// it never delays the proxied call and has no bearing on enforcement.
+package notify
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/mail"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/slyt3/Logryph/internal/logging"
+)
+
+// Channel types.
+const (
+	ChannelWebhook = "webhook"
+	ChannelSlack   = "slack"
+	ChannelEmail   = "email"
+)
+
+const (
+	queueSize       = 1024
+	sendTimeout     = 10 * time.Second
+	closeWait       = 5 * time.Second
+	maxChannels     = 64
+	maxNameLen      = 64
+	maxRecipients   = 32
+	maxDispatchLoop = 1 << 30
+)
+
+// ChannelConfig is one entry of notifications.channels in the policy file. Email
+// channels take an smtp://[user:password@]host[:port] URL; STARTTLS is used when the
+// server offers it.
+type ChannelConfig struct {
+	Name    string            `yaml:"name"`
+	Type    string            `yaml:"type,omitempty"` // webhook (default), slack or email
+	URL     string            `yaml:"url"`
+	Headers map[string]string `yaml:"headers,omitempty"`
+	From    string            `yaml:"from,omitempty"` // email sender
+	To      []string          `yaml:"to,omitempty"`   // email recipients
+}
+
+// Notification is the event context sent when a rule with notify matches a call, when a
+// scheduled report crosses its threshold, or with the daily digest. Webhook channels
+// receive it as JSON; Slack and email channels receive a text summary.
+type Notification struct {
+	Channel       string      `json:"channel"`
+	RuleID        string      `json:"rule_id"`
+	EventID       string      `json:"event_id"`
+	Method        string      `json:"method"`
+	TaskID        string      `json:"task_id,omitempty"`
+	RiskLevel     string      `json:"risk_level,omitempty"`
+	Environment   string      `json:"environment,omitempty"`
+	CorrelationID string      `json:"correlation_id,omitempty"`
+	TraceID       string      `json:"trace_id,omitempty"`
+	Tags          []string    `json:"tags,omitempty"`
+	Report        string      `json:"report,omitempty"`    // scheduled report name; RuleID is empty
+	Query         string      `json:"query,omitempty"`     // the report's query expression
+	Count         int         `json:"count,omitempty"`     // events the report matched in its window
+	Threshold     int         `json:"threshold,omitempty"` // count at which the report notifies
+	Subject       string      `json:"subject,omitempty"`   // email subject; a summary when empty
+	Text          string      `json:"text,omitempty"`      // preformatted summary, e.g. the digest
+	Digest        interface{} `json:"digest,omitempty"`    // the daily digest, for webhooks
+	Timestamp     time.Time   `json:"timestamp"`
+}
+
+// ValidateChannels checks channel definitions and returns them keyed by name.
+func ValidateChannels(channels []ChannelConfig) (map[string]ChannelConfig, error) {
+	if len(channels) > maxChannels {
+		return nil, fmt.Errorf("%d channels exceed max of %d", len(channels), maxChannels)
+	}
+	byName := make(map[string]ChannelConfig, len(channels))
+	for i := 0; i < len(channels); i++ {
+		c := channels[i]
+		if c.Name == "" || len(c.Name) > maxNameLen {
+			return nil, fmt.Errorf("channels[%d]: invalid name %q", i, c.Name)
+		}
+		if _, dup := byName[c.Name]; dup {
+			return nil, fmt.Errorf("channel %s: defined twice", c.Name)
+		}
+		if err := validateChannel(c); err != nil {
+			return nil, fmt.Errorf("channel %s: %w", c.Name, err)
+		}
+		byName[c.Name] = c
+	}
+	return byName, nil
+}
+
+func validateChannel(c ChannelConfig) error {
+	u, err := url.Parse(c.URL)
+	switch c.Type {
+	case "", ChannelWebhook, ChannelSlack:
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return errors.New("url must be an absolute http(s) URL")
+		}
+	case ChannelEmail:
+		if err != nil || u.Scheme != "smtp" || u.Hostname() == "" {
+			return errors.New("url must be smtp://[user:password@]host[:port]")
+		}
+		if _, err := mail.ParseAddress(c.From); err != nil {
+			return fmt.Errorf("invalid from address %q", c.From)
+		}
+		if len(c.To) == 0 || len(c.To) > maxRecipients {
+			return fmt.Errorf("email needs 1-%d to addresses", maxRecipients)
+		}
+		for i := 0; i < len(c.To); i++ {
+			if _, err := mail.ParseAddress(c.To[i]); err != nil {
+				return fmt.Errorf("invalid to address %q", c.To[i])
+			}
+		}
+	default:
+		return fmt.Errorf("unknown type %q (want webhook, slack or email)", c.Type)
+	}
+	return nil
+}
+
+type delivery struct {
+	channel ChannelConfig
+	n       Notification
+}
+
+// Dispatcher queues notifications and delivers them from a single goroutine. When the
+// queue is full notifications are dropped and counted. A nil Dispatcher discards all.
+type Dispatcher struct {
+	channels  map[string]ChannelConfig
+	client    *http.Client
+	queue     chan delivery
+	done      chan struct{}
+	closeOnce sync.Once
+	dropped   atomic.Uint64
+}
+
+// NewDispatcher validates channels and starts the delivery goroutine.
+func NewDispatcher(channels []ChannelConfig) (*Dispatcher, error) {
+	byName, err := ValidateChannels(channels)
+	if err != nil {
+		return nil, err
+	}
+	d := &Dispatcher{
+		channels: byName,
+		client:   &http.Client{Timeout: sendTimeout},
+		queue:    make(chan delivery, queueSize),
+		done:     make(chan struct{}),
+	}
+	go d.run()
+	return d, nil
+}
+
+// Notify queues n for each named channel. Unknown names are logged and skipped; it never
+// blocks.
+func (d *Dispatcher) Notify(names []string, n Notification) {
+	if d == nil {
+		return
+	}
+	for i := 0; i < len(names) && i < maxChannels; i++ {
+		c, ok := d.channels[names[i]]
+		if !ok {
+			logging.Warn("notify_channel_unknown", logging.Fields{Component: "notify", PolicyID: n.RuleID, Error: names[i]})
+			continue
+		}
+		out := n
+		out.Channel = c.Name
+		select {
+		case d.queue <- delivery{channel: c, n: out}:
+		default:
+			d.dropped.Add(1)
+			logging.Warn("notify_dropped", logging.Fields{Component: "notify", EventID: n.EventID, PolicyID: n.RuleID})
+		}
+	}
+}
+
+// Dropped returns how many notifications were discarded because the queue was full.
+func (d *Dispatcher) Dropped() uint64 {
+	if d == nil {
+		return 0
+	}
+	return d.dropped.Load()
+}
+
+func (d *Dispatcher) run() {
+	defer close(d.done)
+	for i := 0; i < maxDispatchLoop; i++ {
+		job, ok := <-d.queue
+		if !ok {
+			return
+		}
+		if err := d.send(job); err != nil {
+			logging.Error("notify_failed", logging.Fields{Component: "notify", EventID: job.n.EventID, PolicyID: job.n.RuleID, Error: err.Error()})
+		}
+	}
+}
+
+func (d *Dispatcher) send(job delivery) error {
+	if job.channel.Type == ChannelEmail {
+		return sendMail(job.channel, job.n)
+	}
+	body, err := payload(job.channel.Type, job.n)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, job.channel.URL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("channel %s: %w", job.channel.Name, err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range job.channel.Headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("channel %s: %w", job.channel.Name, err)
+	}
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<16))
+	_ = resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("channel %s: status %d", job.channel.Name, resp.StatusCode)
+	}
+	return nil
+}
+
+// payload renders n for the channel type.
+func payload(channelType string, n Notification) ([]byte, error) {
+	if channelType != ChannelSlack {
+		return json.Marshal(n)
+	}
+	return json.Marshal(map[string]string{"text": summary(n)})
+}
+
+// summary is the text form of n used by Slack and email.
+func summary(n Notification) string {
+	if n.Text != "" {
+		return n.Text
+	}
+	if n.Report != "" {
+		return fmt.Sprintf("Logryph: report `%s` matched %d events (threshold %d, event %s)", n.Report, n.Count, n.Threshold, n.EventID)
+	}
+	text := fmt.Sprintf("Logryph: rule `%s` matched `%s` (event %s", n.RuleID, n.Method, n.EventID)
+	if n.RiskLevel != "" {
+		text += ", risk " + n.RiskLevel
+	}
+	if n.TaskID != "" {
+		text += ", task " + n.TaskID
+	}
+	if n.Environment != "" {
+		text += ", env " + n.Environment
+	}
+	return text + ")"
+}
+
+// Close delivers queued notifications, waiting at most closeWait.
+func (d *Dispatcher) Close() error {
+	if d == nil {
+		return nil
+	}
+	d.closeOnce.Do(func() { close(d.queue) })
+	select {
+	case <-d.done:
+		return nil
+	case <-time.After(closeWait):
+		return errors.New("timed out delivering queued notifications")
+	}
+}
@@ -0,0 +1,137 @@
+package notify
+
+import (
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDispatcherDeliversToChannels(t *testing.T) {
+	bodies := make(chan string, 4)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		bodies <- r.URL.Path + " " + r.Header.Get("X-Token") + " " + string(b)
+	}))
+	defer srv.Close()
+
+	d, err := NewDispatcher([]ChannelConfig{
+		{Name: "audit-hook", URL: srv.URL + "/hook", Headers: map[string]string{"X-Token": "t"}},
+		{Name: "slack-secops", Type: ChannelSlack, URL: srv.URL + "/slack"},
+	})
+	if err != nil {
+		t.Fatalf("dispatcher: %v", err)
+	}
+	d.Notify([]string{"audit-hook", "slack-secops", "missing"}, Notification{RuleID: "billing", EventID: "abc123", Method: "stripe:refund", RiskLevel: "high"})
+	if err := d.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	close(bodies)
+
+	got := map[string]string{}
+	for b := range bodies {
+		path, rest, _ := strings.Cut(b, " ")
+		got[path] = rest
+	}
+	if len(got) != 2 {
+		t.Fatalf("expected two deliveries, got %v", got)
+	}
+	token, hook, _ := strings.Cut(got["/hook"], " ")
+	var n Notification
+	if err := json.Unmarshal([]byte(hook), &n); err != nil || token != "t" || n.Channel != "audit-hook" || n.EventID != "abc123" {
+		t.Fatalf("unexpected webhook delivery %q (%v)", got["/hook"], err)
+	}
+	if !strings.Contains(got["/slack"], "`billing` matched `stripe:refund`") {
+		t.Fatalf("unexpected slack delivery %q", got["/slack"])
+	}
+}
+
+func TestValidateChannels(t *testing.T) {
+	bad := [][]ChannelConfig{
+		{{Name: "", URL: "https://example.com"}},
+		{{Name: "a", URL: "ftp://example.com"}},
+		{{Name: "a", Type: "pager", URL: "https://example.com"}},
+		{{Name: "a", URL: "https://example.com"}, {Name: "a", URL: "https://example.org"}},
+		{{Name: "a", Type: ChannelEmail, URL: "https://example.com", From: "a@example.com", To: []string{"b@example.com"}}},
+		{{Name: "a", Type: ChannelEmail, URL: "smtp://mail.example.com", From: "not an address", To: []string{"b@example.com"}}},
+		{{Name: "a", Type: ChannelEmail, URL: "smtp://mail.example.com", From: "a@example.com"}},
+	}
+	for i, channels := range bad {
+		if _, err := ValidateChannels(channels); err == nil {
+			t.Errorf("case %d: expected an error", i)
+		}
+	}
+	var d *Dispatcher
+	d.Notify([]string{"x"}, Notification{}) // a nil dispatcher discards
+}
+
+// fakeSMTP accepts one message and returns its DATA section on the channel.
+func fakeSMTP(t *testing.T) (string, <-chan string) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = ln.Close() })
+	data := make(chan string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer func() { _ = conn.Close() }()
+		tp := textproto.NewConn(conn)
+		_ = tp.PrintfLine("220 fake ESMTP")
+		for i := 0; i < 32; i++ {
+			line, err := tp.ReadLine()
+			if err != nil {
+				return
+			}
+			switch cmd := strings.ToUpper(strings.Fields(line + " x")[0]); cmd {
+			case "EHLO", "HELO":
+				_ = tp.PrintfLine("250 fake")
+			case "DATA":
+				_ = tp.PrintfLine("354 go ahead")
+				body, _ := tp.ReadDotBytes()
+				data <- string(body)
+				_ = tp.PrintfLine("250 queued")
+			case "QUIT":
+				_ = tp.PrintfLine("221 bye")
+				return
+			default:
+				_ = tp.PrintfLine("250 ok")
+			}
+		}
+	}()
+	return ln.Addr().String(), data
+}
+
+func TestDispatcherSendsEmail(t *testing.T) {
+	addr, data := fakeSMTP(t)
+	d, err := NewDispatcher([]ChannelConfig{{
+		Name: "secops-mail", Type: ChannelEmail, URL: "smtp://" + addr,
+		From: "logryph@example.com", To: []string{"secops@example.com"},
+	}})
+	if err != nil {
+		t.Fatalf("dispatcher: %v", err)
+	}
+	d.Notify([]string{"secops-mail"}, Notification{Subject: "Daily digest", Text: "Calls: 12\nBlocked: 1"})
+	if err := d.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	select {
+	case msg := <-data:
+		for _, want := range []string{"To: secops@example.com", "Subject: Daily digest", "Calls: 12\nBlocked: 1"} {
+			if !strings.Contains(msg, want) {
+				t.Errorf("message lacks %q:\n%s", want, msg)
+			}
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no message delivered")
+	}
+}
@@ -0,0 +1,41 @@
+package observer
+
+import (
+	"fmt"
+	"strings"
+)
+
+const maxMethodAliases = 4096
+
+// validateMethodAliases checks the method_aliases section, which maps a method name as
+// one MCP server spells it to the canonical name rules and analytics use, e.g.
+// filesystem.read_file: fs:read. A canonical name must not itself be an alias, so one
+// lookup always resolves a name.
+func validateMethodAliases(aliases map[string]string) error {
+	if len(aliases) > maxMethodAliases {
+		return fmt.Errorf("method_aliases has %d entries, limit is %d", len(aliases), maxMethodAliases)
+	}
+	for alias, canonical := range aliases {
+		if strings.TrimSpace(alias) == "" || strings.TrimSpace(canonical) == "" {
+			return fmt.Errorf("method_aliases: names must not be empty")
+		}
+		if alias == canonical {
+			return fmt.Errorf("method_aliases: %s is mapped to itself", alias)
+		}
+		if _, chained := aliases[canonical]; chained {
+			return fmt.Errorf("method_aliases: %s maps to %s, which is itself an alias", alias, canonical)
+		}
+	}
+	return nil
+}
+
+// CanonicalMethod returns the canonical name for method from method_aliases, or method
+// itself when it has none.
+func (e *ObserverEngine) CanonicalMethod(method string) string {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	if canonical, ok := e.config.MethodAliases[method]; ok {
+		return canonical
+	}
+	return method
+}
@@ -0,0 +1,72 @@
+package observer
+
+import (
+	"fmt"
+	"time"
+)
+
+// Task budget actions. Tag only records; stall holds the task's calls for approval and
+// deny refuses them, both in enforce mode only.
+const (
+	BudgetActionTag   = "tag"
+	BudgetActionStall = "stall"
+	BudgetActionDeny  = "deny"
+)
+
+const (
+	maxBudgetEvents   = 10000000
+	maxBudgetDuration = 30 * 24 * time.Hour
+)
+
+// TaskBudgetConfig bounds what one task may do, a backstop against runaway agent loops.
+// Zero limits are off.
+type TaskBudgetConfig struct {
+	MaxEvents   int    `yaml:"max_events,omitempty"`   // tool calls recorded for the task
+	MaxDuration string `yaml:"max_duration,omitempty"` // time since the task's first call, e.g. "30m"
+	Action      string `yaml:"action,omitempty"`       // tag (default) | stall | deny
+}
+
+// TaskBudget is the effective task budget with defaults applied.
+type TaskBudget struct {
+	MaxEvents   int
+	MaxDuration time.Duration
+	Action      string
+}
+
+// Enabled reports whether the budget limits anything.
+func (b TaskBudget) Enabled() bool {
+	return b.MaxEvents > 0 || b.MaxDuration > 0
+}
+
+func validateTaskBudget(c TaskBudgetConfig) error {
+	if c.MaxEvents < 0 || c.MaxEvents > maxBudgetEvents {
+		return fmt.Errorf("invalid task_budget.max_events %d: must be between 0 and %d", c.MaxEvents, maxBudgetEvents)
+	}
+	if c.MaxDuration != "" {
+		d, err := time.ParseDuration(c.MaxDuration)
+		if err != nil || d <= 0 || d > maxBudgetDuration {
+			return fmt.Errorf("invalid task_budget.max_duration %q: must be a positive duration up to %s", c.MaxDuration, maxBudgetDuration)
+		}
+	}
+	switch c.Action {
+	case "", BudgetActionTag, BudgetActionStall, BudgetActionDeny:
+		return nil
+	}
+	return fmt.Errorf("invalid task_budget.action %q: must be %s, %s or %s", c.Action, BudgetActionTag, BudgetActionStall, BudgetActionDeny)
+}
+
+// GetTaskBudget returns the per-task budget. It is read per call, so a policy reload
+// applies to the next call of every task.
+func (e *ObserverEngine) GetTaskBudget() TaskBudget {
+	e.mu.RLock()
+	cfg := e.config.TaskBudget
+	e.mu.RUnlock()
+	b := TaskBudget{MaxEvents: cfg.MaxEvents, Action: cfg.Action}
+	if b.Action == "" {
+		b.Action = BudgetActionTag
+	}
+	if d, err := time.ParseDuration(cfg.MaxDuration); err == nil {
+		b.MaxDuration = d
+	}
+	return b
+}
@@ -0,0 +1,99 @@
+package observer
+
+import (
+	"fmt"
+	"time"
+)
+
+// Overflow behaviours for calls past the concurrency limit.
+const (
+	OverflowQueue  = "queue"
+	OverflowReject = "reject"
+)
+
+const (
+	defaultQueueTimeout = 10 * time.Second
+	defaultMaxQueue     = 1000
+	maxInFlightLimit    = 100000
+	maxQueueTimeout     = 10 * time.Minute
+)
+
+// ConcurrencyConfig caps how many calls are in flight to the upstream at once, protecting
+// fragile tool servers from agent stampedes. Zero max_in_flight means no cap.
+type ConcurrencyConfig struct {
+	MaxInFlight  int    `yaml:"max_in_flight,omitempty"`
+	Overflow     string `yaml:"overflow,omitempty"`      // queue (default) or reject
+	MaxQueue     int    `yaml:"max_queue,omitempty"`     // calls waiting at once; default 1000
+	QueueTimeout string `yaml:"queue_timeout,omitempty"` // longest wait for a slot; default 10s
+}
+
+// ConcurrencyPolicy is the effective concurrency cap with defaults applied.
+type ConcurrencyPolicy struct {
+	MaxInFlight  int // zero disables the cap
+	Queue        bool
+	MaxQueue     int
+	QueueTimeout time.Duration
+}
+
+func validateConcurrency(c ConcurrencyConfig) error {
+	if c.MaxInFlight < 0 || c.MaxInFlight > maxInFlightLimit {
+		return fmt.Errorf("invalid concurrency.max_in_flight %d: must be between 0 and %d", c.MaxInFlight, maxInFlightLimit)
+	}
+	if c.Overflow != "" && c.Overflow != OverflowQueue && c.Overflow != OverflowReject {
+		return fmt.Errorf("invalid concurrency.overflow %q: must be %s or %s", c.Overflow, OverflowQueue, OverflowReject)
+	}
+	if c.MaxQueue < 0 || c.MaxQueue > maxInFlightLimit {
+		return fmt.Errorf("invalid concurrency.max_queue %d: must be between 0 and %d", c.MaxQueue, maxInFlightLimit)
+	}
+	if c.QueueTimeout != "" {
+		d, err := time.ParseDuration(c.QueueTimeout)
+		if err != nil || d <= 0 || d > maxQueueTimeout {
+			return fmt.Errorf("invalid concurrency.queue_timeout %q: must be a positive duration up to %s", c.QueueTimeout, maxQueueTimeout)
+		}
+	}
+	return nil
+}
+
+func validateMaxConcurrent(max int, overflow string) error {
+	if max < 0 || max > maxInFlightLimit {
+		return fmt.Errorf("invalid max_concurrent %d: must be between 0 and %d", max, maxInFlightLimit)
+	}
+	if overflow != "" && overflow != OverflowQueue && overflow != OverflowReject {
+		return fmt.Errorf("invalid overflow %q: must be %s or %s", overflow, OverflowQueue, OverflowReject)
+	}
+	return nil
+}
+
+// GetConcurrency returns the upstream concurrency cap. It is read per call, so a policy
+// reload takes effect for the next call.
+func (e *ObserverEngine) GetConcurrency() ConcurrencyPolicy {
+	e.mu.RLock()
+	cfg := e.config.Concurrency
+	e.mu.RUnlock()
+	p := ConcurrencyPolicy{
+		MaxInFlight:  cfg.MaxInFlight,
+		Queue:        cfg.Overflow != OverflowReject,
+		MaxQueue:     cfg.MaxQueue,
+		QueueTimeout: defaultQueueTimeout,
+	}
+	if p.MaxQueue == 0 {
+		p.MaxQueue = defaultMaxQueue
+	}
+	if d, err := time.ParseDuration(cfg.QueueTimeout); err == nil {
+		p.QueueTimeout = d
+	}
+	return p
+}
+
+// GetRuleConcurrency returns the per-rule cap for calls matched by rule, taking max_queue
+// and queue_timeout from the concurrency section. MaxInFlight is zero when the rule sets
+// no max_concurrent (or rule is nil).
+func (e *ObserverEngine) GetRuleConcurrency(rule *Rule) ConcurrencyPolicy {
+	if rule == nil || rule.MaxConcurrent <= 0 {
+		return ConcurrencyPolicy{}
+	}
+	p := e.GetConcurrency()
+	p.MaxInFlight = rule.MaxConcurrent
+	p.Queue = rule.Overflow != OverflowReject
+	return p
+}
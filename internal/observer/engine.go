@@ -4,13 +4,27 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
-	"strconv"
 	"strings"
 	"sync"
 	"time"
 
+	"github.com/slyt3/Logryph/internal/approval"
 	"github.com/slyt3/Logryph/internal/assert"
+	"github.com/slyt3/Logryph/internal/cors"
+	"github.com/slyt3/Logryph/internal/digest"
+	"github.com/slyt3/Logryph/internal/endpoint"
+	"github.com/slyt3/Logryph/internal/headpub"
+	"github.com/slyt3/Logryph/internal/integrity"
+	"github.com/slyt3/Logryph/internal/ledger"
 	"github.com/slyt3/Logryph/internal/logging"
+	"github.com/slyt3/Logryph/internal/models"
+	"github.com/slyt3/Logryph/internal/notary"
+	"github.com/slyt3/Logryph/internal/notify"
+	"github.com/slyt3/Logryph/internal/reports"
+	"github.com/slyt3/Logryph/internal/secretref"
+	"github.com/slyt3/Logryph/internal/slo"
+	"github.com/slyt3/Logryph/internal/vql"
+	"github.com/slyt3/Logryph/internal/worm"
 	"gopkg.in/yaml.v3"
 )
 
@@ -22,7 +36,150 @@ type Config struct {
 		RetentionDays  int    `yaml:"retention_days"`
 		SigningEnabled bool   `yaml:"signing_enabled"`
 		LogLevel       string `yaml:"log_level"`
+		// Environment is the deployment profile stamped on every event (e.g. dev, staging, prod).
+		Environment string `yaml:"environment,omitempty"`
+		// EnvironmentHeader lets agents select a profile via X-Logryph-Environment.
+		// Off by default: an agent must not be able to downgrade itself out of prod rules.
+		EnvironmentHeader bool `yaml:"environment_header,omitempty"`
+		// EnforcementMode is "observe" (default, record only) or "enforce" (apply stall actions).
+		EnforcementMode string `yaml:"enforcement_mode,omitempty"`
+		// StallTimeout bounds how long a stalled call waits for approval, e.g. "5m".
+		StallTimeout string `yaml:"stall_timeout,omitempty"`
+		// SchemaValidation checks tool_call params against schemas from tools/list:
+		// "tag" (default), "deny" (reject malformed calls in enforce mode), or "off".
+		SchemaValidation string `yaml:"schema_validation,omitempty"`
+		// UpstreamTimeout bounds how long a forwarded call may wait for the tool server,
+		// e.g. "30s". Rules can override it with timeout. Empty means no limit.
+		UpstreamTimeout string `yaml:"upstream_timeout,omitempty"`
+		// LedgerGuarantee is what the proxy does with agent traffic while the ledger cannot
+		// record: "fail_open" (default, forward unrecorded), "fail_closed" (reject calls)
+		// or "degraded" (forward, logging a digest of each call).
+		LedgerGuarantee string `yaml:"ledger_guarantee,omitempty"`
+		// UpstreamEventHeaders adds X-Logryph-Event-ID and X-Logryph-Event-Hash to calls
+		// forwarded upstream, so tool servers can log the ledger reference of each call.
+		UpstreamEventHeaders bool `yaml:"upstream_event_headers,omitempty"`
 	} `yaml:"defaults"`
+	Policies         []Rule                       `yaml:"policies"`
+	MethodAliases    map[string]string            `yaml:"method_aliases,omitempty"`
+	Environments     map[string]EnvironmentConfig `yaml:"environments,omitempty"`
+	Detectors        DetectorsConfig              `yaml:"detectors,omitempty"`
+	Logging          LoggingConfig                `yaml:"logging,omitempty"`
+	Retry            RetryConfig                  `yaml:"retry,omitempty"`
+	Capture          CaptureConfig                `yaml:"capture,omitempty"`
+	Sampling         SamplingConfig               `yaml:"sampling,omitempty"`
+	Limits           LimitsConfig                 `yaml:"limits,omitempty"`
+	Concurrency      ConcurrencyConfig            `yaml:"concurrency,omitempty"`
+	TaskBudget       TaskBudgetConfig             `yaml:"task_budget,omitempty"`
+	TaskHistory      TaskHistoryConfig            `yaml:"task_history,omitempty"`
+	CORS             cors.Config                  `yaml:"cors,omitempty"`
+	Notifications    NotificationsConfig          `yaml:"notifications,omitempty"`
+	WORM             worm.Config                  `yaml:"worm,omitempty"`
+	Integrity        integrity.Config             `yaml:"integrity,omitempty"`
+	Rotation         ledger.RotationConfig        `yaml:"rotation,omitempty"`
+	Genesis          ledger.GenesisConfig         `yaml:"genesis,omitempty"`
+	Reports          []reports.Config             `yaml:"reports,omitempty"`
+	SLOs             []slo.Config                 `yaml:"slos,omitempty"`
+	Digest           digest.Config                `yaml:"digest,omitempty"`
+	Notary           notary.Config                `yaml:"notary,omitempty"`
+	OfflineApprovals approval.OfflineConfig       `yaml:"offline_approvals,omitempty"`
+	ApprovalPoll     approval.PollConfig          `yaml:"approval_poll,omitempty"`
+	HeadPublication  headpub.Config               `yaml:"head_publication,omitempty"`
+
+	secrets     []string // values resolved from secret references, masked when shown
+	usesHistory bool     // some rule's when: reads history()
+}
+
+// Secrets returns the values the policy's ${env:...} and ${file:...} references resolved
+// to, for secretref.Mask.
+func (c *Config) Secrets() []string {
+	return c.secrets
+}
+
+// NotificationsConfig names the channels that rules can notify on match.
+type NotificationsConfig struct {
+	Channels []notify.ChannelConfig `yaml:"channels,omitempty"`
+}
+
+// CaptureConfig selects HTTP context recorded alongside JSON bodies. Nothing is captured
+// by default. Authorization, Proxy-Authorization, Cookie and Set-Cookie values are always
+// redacted; Redact adds header names and query keys (case-insensitive).
+type CaptureConfig struct {
+	RequestHeaders  []string `yaml:"request_headers,omitempty"`
+	ResponseHeaders []string `yaml:"response_headers,omitempty"`
+	Query           bool     `yaml:"query,omitempty"`
+	Redact          []string `yaml:"redact,omitempty"`
+	// StoreBodies keeps non-JSON request bodies (uploads, multipart files) in the
+	// attachment store. Their content type, size and SHA-256 are always recorded.
+	StoreBodies bool `yaml:"store_bodies,omitempty"`
+	// MaxResponseBytes caps the result stored on a tool_response. A larger result is
+	// stored as its head and tail around a truncation marker, with its full size and
+	// SHA-256. Zero stores results whole.
+	MaxResponseBytes int `yaml:"max_response_bytes,omitempty"`
+}
+
+// RetryConfig bounds upstream retries for calls matched by an idempotent rule. Only
+// transient failures are retried: connection refused, 502 and 503.
+type RetryConfig struct {
+	MaxAttempts int    `yaml:"max_attempts,omitempty"` // total attempts including the first (default 3, 1 disables)
+	Backoff     string `yaml:"backoff,omitempty"`      // delay before the first retry, doubled per retry (default 100ms)
+	MaxBackoff  string `yaml:"max_backoff,omitempty"`  // cap on a single delay (default 2s)
+}
+
+// LoggingConfig selects where operational logs go. Sinks are applied at startup; with
+// none, logs are written to the console only.
+type LoggingConfig struct {
+	Sinks []logging.SinkConfig `yaml:"sinks,omitempty"`
+}
+
+// DetectorsConfig tunes the content heuristics run over tool call params.
+// Detectors only tag calls and emit finding events; they never block traffic.
+type DetectorsConfig struct {
+	Exfiltration ExfilDetectorConfig   `yaml:"exfiltration,omitempty"`
+	Shell        ShellDetectorConfig   `yaml:"shell,omitempty"`
+	SQL          SQLDetectorConfig     `yaml:"sql,omitempty"`
+	Secrets      SecretsDetectorConfig `yaml:"secrets,omitempty"`
+}
+
+// SecretsDetectorConfig configures the secret scanner. Vault references are recorded as
+// secret_reference events; literal secrets in params are reported as secret_leak events.
+type SecretsDetectorConfig struct {
+	Disabled   bool     `yaml:"disabled,omitempty"`
+	Methods    []string `yaml:"methods,omitempty"`     // method patterns to scan (default: all)
+	RefSchemes []string `yaml:"ref_schemes,omitempty"` // e.g. "vault" recognizes vault:kv/path
+	MinLength  int      `yaml:"min_length,omitempty"`  // shortest token checked for entropy
+	MinEntropy float64  `yaml:"min_entropy,omitempty"` // bits per byte flagged as a literal secret
+}
+
+// SQLDetectorConfig configures SQL classification for database methods.
+// Classified calls are tagged sql_<class> and sql_table:<name>; rules can match on the
+// classification with match_sql.
+type SQLDetectorConfig struct {
+	Disabled bool     `yaml:"disabled,omitempty"`
+	Methods  []string `yaml:"methods,omitempty"` // database method patterns
+}
+
+// ShellDetectorConfig configures the command-line analyzer for shell/exec methods.
+// Findings raise the call's risk level automatically; calls at or above StallRisk are
+// stalled for approval in enforce mode, independent of hand-written rules.
+type ShellDetectorConfig struct {
+	Disabled  bool     `yaml:"disabled,omitempty"`
+	Methods   []string `yaml:"methods,omitempty"`    // command-execution method patterns
+	StallRisk string   `yaml:"stall_risk,omitempty"` // low | medium | high | critical (default critical); "none" disables
+}
+
+// ExfilDetectorConfig configures outbound data exfiltration heuristics.
+// Zero values fall back to the analyzer defaults.
+type ExfilDetectorConfig struct {
+	Disabled     bool     `yaml:"disabled,omitempty"`
+	Methods      []string `yaml:"methods,omitempty"`        // outbound method patterns, e.g. "http:post"
+	MinBlobBytes int      `yaml:"min_blob_bytes,omitempty"` // shortest base64/hex token flagged
+	BulkBytes    int      `yaml:"bulk_bytes,omitempty"`     // single value size flagged as bulk content
+}
+
+// EnvironmentConfig overlays the base policy list for one deployment profile.
+// Rules whose ID matches a base rule replace that rule's non-empty fields;
+// rules with new IDs are appended after the base list.
+type EnvironmentConfig struct {
 	Policies []Rule `yaml:"policies"`
 }
 
@@ -35,8 +192,86 @@ type Rule struct {
 	LogLevel        string              `yaml:"log_level,omitempty"`
 	MatchConditions []map[string]string `yaml:"conditions,omitempty"`
 	Redact          []string            `yaml:"redact,omitempty"` // List of param keys to redact
+	Action          string              `yaml:"action,omitempty"` // tag (default) | stall
+	// When narrows the rule with a query-language expression over method, params and
+	// environment, e.g. params.amount > 1000 and params.currency in ("usd", "eur").
+	// It supersedes conditions, which are translated to the same language.
+	When string `yaml:"when,omitempty"`
+	// AllowHosts/DenyHosts restrict destinations found in params (URLs, host fields).
+	// Entries are domains, "*.domain" wildcards, IP literals or CIDR ranges.
+	AllowHosts []string `yaml:"allow_hosts,omitempty"`
+	DenyHosts  []string `yaml:"deny_hosts,omitempty"`
+	// MatchSQL narrows the rule to calls whose SQL has one of these classes
+	// (read, write, ddl, admin, destructive) or verbs (drop, truncate, ...).
+	MatchSQL []string `yaml:"match_sql,omitempty"`
+	// Labels are key=value pairs stamped on matching calls, e.g. team: payments.
+	// Environment overlays add to or replace individual keys.
+	Labels map[string]string `yaml:"labels,omitempty"`
+	// Idempotent marks methods that are safe to send twice; transient upstream failures
+	// are retried according to the retry section.
+	Idempotent bool `yaml:"idempotent,omitempty"`
+	// Timeout overrides defaults.upstream_timeout for matching methods, e.g. "5s".
+	Timeout string `yaml:"timeout,omitempty"`
+	// RedactResponse lists result field names (case-insensitive, at any depth) scrubbed
+	// from upstream responses before they reach the agent or the ledger.
+	RedactResponse []string `yaml:"redact_response,omitempty"`
+	// Notify names notifications.channels fired whenever the rule matches, whatever the
+	// enforcement mode.
+	Notify []string `yaml:"notify,omitempty"`
+	// MaxConcurrent caps how many calls matching the rule are upstream at once, e.g. 1 to
+	// serialize a stateful tool like terraform:apply. Extras queue, or are rejected when
+	// overflow is reject; max_queue and queue_timeout come from the concurrency section.
+	MaxConcurrent int    `yaml:"max_concurrent,omitempty"`
+	Overflow      string `yaml:"overflow,omitempty"` // queue (default) or reject
+}
+
+// HasHostLists reports whether the rule restricts network destinations.
+func (r *Rule) HasHostLists() bool {
+	return len(r.AllowHosts) > 0 || len(r.DenyHosts) > 0
 }
 
+// Rule actions. Tag only records; stall holds the call for approval in enforce mode.
+const (
+	RuleActionTag   = "tag"
+	RuleActionStall = "stall"
+)
+
+// Enforcement modes for Defaults.EnforcementMode.
+const (
+	EnforcementObserve = "observe"
+	EnforcementEnforce = "enforce"
+)
+
+// Schema validation modes for Defaults.SchemaValidation.
+const (
+	SchemaValidationTag  = "tag"
+	SchemaValidationDeny = "deny"
+	SchemaValidationOff  = "off"
+)
+
+// Ledger guarantees for Defaults.LedgerGuarantee.
+const (
+	GuaranteeFailOpen   = "fail_open"
+	GuaranteeFailClosed = "fail_closed"
+	GuaranteeDegraded   = "degraded"
+)
+
+const (
+	maxEnvironments       = 32
+	maxEnvironmentRules   = 256
+	defaultStallTimeout   = 5 * time.Minute
+	maxStallTimeout       = 24 * time.Hour
+	maxEnvironmentNameLen = 64
+	defaultRetryAttempts  = 3
+	defaultRetryBackoff   = 100 * time.Millisecond
+	defaultRetryMaxDelay  = 2 * time.Second
+	MaxRetryAttempts      = 10
+	maxRetryDelay         = time.Minute
+	maxUpstreamTimeout    = time.Hour
+	MaxCaptureEntries     = 64
+	MaxResponseRedactKeys = 128
+)
+
 // ObserverEngine handles policy evaluation and hot-reload from logryph-policy.yaml.
 // Reloads config every 5 seconds when Watch() is running.
 // Thread-safe for concurrent policy lookups.
@@ -44,8 +279,11 @@ type ObserverEngine struct {
 	mu         sync.RWMutex
 	config     *Config
 	configPath string
-	stopChan   chan struct{}
-	stopOnce   sync.Once
+	// lastGoodPath caches each policy that loads, for NewObserverEngineWithFallback.
+	lastGoodPath string
+	stopChan     chan struct{}
+	stopOnce     sync.Once
+	stats        ruleStats // live per-rule match counts
 }
 
 // NewObserverEngine creates a new observer engine and loads the initial policy file.
@@ -72,6 +310,15 @@ func NewObserverEngine(configPath string) (*ObserverEngine, error) {
 	}, nil
 }
 
+// LoadConfig reads and validates a policy file without starting an engine, e.g. to embed
+// it in generated deployment manifests.
+func LoadConfig(path string) (*Config, error) {
+	if err := assert.Check(path != "", "config path must not be empty"); err != nil {
+		return nil, err
+	}
+	return loadConfig(path)
+}
+
 // loadConfig loads the logryph-policy.yaml file
 func loadConfig(path string) (*Config, error) {
 	data, err := os.ReadFile(path)
@@ -79,14 +326,283 @@ func loadConfig(path string) (*Config, error) {
 		return nil, fmt.Errorf("reading policy file: %w", err)
 	}
 
-	var config Config
-	if err := yaml.Unmarshal(data, &config); err != nil {
+	return parseConfig(data)
+}
+
+// parseConfig parses and validates policy YAML, resolving ${env:...} and ${file:...}
+// secret references first.
+func parseConfig(data []byte) (*Config, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
 		return nil, fmt.Errorf("parsing policy YAML: %w", err)
 	}
-
+	secrets, err := secretref.ExpandYAML(&doc)
+	if err != nil {
+		return nil, fmt.Errorf("resolving policy secrets: %w", err)
+	}
+	var config Config
+	if len(doc.Content) > 0 {
+		if err := doc.Decode(&config); err != nil {
+			return nil, fmt.Errorf("parsing policy YAML: %w", err)
+		}
+	}
+	config.secrets = secrets
+	if err := validateConfig(&config); err != nil {
+		return nil, fmt.Errorf("validating policy: %w", err)
+	}
+	config.usesHistory = usesHistory(&config)
 	return &config, nil
 }
 
+// validateConfig rejects settings that would otherwise fail silently at request time.
+func validateConfig(config *Config) error {
+	if err := assert.NotNil(config, "config"); err != nil {
+		return err
+	}
+	if err := assert.Check(len(config.Environments) <= maxEnvironments, "environments exceed max: %d", len(config.Environments)); err != nil {
+		return err
+	}
+
+	mode := config.Defaults.EnforcementMode
+	if mode != "" && mode != EnforcementObserve && mode != EnforcementEnforce {
+		return fmt.Errorf("invalid enforcement_mode %q: must be %q or %q", mode, EnforcementObserve, EnforcementEnforce)
+	}
+	switch config.Detectors.Shell.StallRisk {
+	case "", "none", "low", "medium", "high", "critical":
+	default:
+		return fmt.Errorf("invalid detectors.shell.stall_risk %q", config.Detectors.Shell.StallRisk)
+	}
+	if e := config.Detectors.Secrets.MinEntropy; e < 0 || e > 8 {
+		return fmt.Errorf("invalid detectors.secrets.min_entropy %v: must be between 0 and 8 bits", e)
+	}
+	for i := 0; i < len(config.Logging.Sinks); i++ {
+		if err := logging.ValidateSinkConfig(config.Logging.Sinks[i]); err != nil {
+			return fmt.Errorf("logging.sinks[%d]: %w", i, err)
+		}
+	}
+	switch config.Defaults.SchemaValidation {
+	case "", SchemaValidationTag, SchemaValidationDeny, SchemaValidationOff:
+	default:
+		return fmt.Errorf("invalid schema_validation %q: must be %q, %q or %q", config.Defaults.SchemaValidation, SchemaValidationTag, SchemaValidationDeny, SchemaValidationOff)
+	}
+	switch config.Defaults.LedgerGuarantee {
+	case "", GuaranteeFailOpen, GuaranteeFailClosed, GuaranteeDegraded:
+	default:
+		return fmt.Errorf("invalid ledger_guarantee %q: must be %q, %q or %q", config.Defaults.LedgerGuarantee, GuaranteeFailOpen, GuaranteeFailClosed, GuaranteeDegraded)
+	}
+	if err := validateStallTimeout(config.Defaults.StallTimeout); err != nil {
+		return err
+	}
+	if err := validateUpstreamTimeout(config.Defaults.UpstreamTimeout); err != nil {
+		return fmt.Errorf("defaults.upstream_timeout: %w", err)
+	}
+	if err := validateRetry(config.Retry); err != nil {
+		return err
+	}
+	if err := validateCapture(config.Capture); err != nil {
+		return err
+	}
+	if err := validateSampling(config.Sampling); err != nil {
+		return err
+	}
+	if err := validateMethodAliases(config.MethodAliases); err != nil {
+		return err
+	}
+	if err := validateLimits(config.Limits); err != nil {
+		return err
+	}
+	if err := validateConcurrency(config.Concurrency); err != nil {
+		return err
+	}
+	if err := validateTaskBudget(config.TaskBudget); err != nil {
+		return err
+	}
+	if err := validateTaskHistory(config.TaskHistory); err != nil {
+		return err
+	}
+	if err := cors.ValidateConfig(config.CORS); err != nil {
+		return fmt.Errorf("cors: %w", err)
+	}
+	if err := worm.ValidateConfig(config.WORM); err != nil {
+		return fmt.Errorf("worm: %w", err)
+	}
+	if err := integrity.ValidateConfig(config.Integrity); err != nil {
+		return fmt.Errorf("integrity: %w", err)
+	}
+	if err := ledger.ValidateRotationConfig(config.Rotation); err != nil {
+		return fmt.Errorf("rotation: %w", err)
+	}
+	if err := ledger.ValidateGenesisConfig(config.Genesis); err != nil {
+		return fmt.Errorf("genesis: %w", err)
+	}
+	if err := notary.ValidateConfig(config.Notary); err != nil {
+		return fmt.Errorf("notary: %w", err)
+	}
+	if err := approval.ValidateOfflineConfig(config.OfflineApprovals); err != nil {
+		return fmt.Errorf("offline_approvals: %w", err)
+	}
+	if err := approval.ValidatePollConfig(config.ApprovalPoll); err != nil {
+		return fmt.Errorf("approval_poll: %w", err)
+	}
+	if err := headpub.ValidateConfig(config.HeadPublication); err != nil {
+		return fmt.Errorf("head_publication: %w", err)
+	}
+	channels, err := notify.ValidateChannels(config.Notifications.Channels)
+	if err != nil {
+		return fmt.Errorf("notifications: %w", err)
+	}
+	if err := validateRules(config.Policies, channels); err != nil {
+		return err
+	}
+	if err := reports.ValidateConfig(config.Reports, channels); err != nil {
+		return fmt.Errorf("reports: %w", err)
+	}
+	if err := slo.ValidateConfig(config.SLOs); err != nil {
+		return fmt.Errorf("slos: %w", err)
+	}
+	if err := digest.ValidateConfig(config.Digest, channels); err != nil {
+		return fmt.Errorf("digest: %w", err)
+	}
+	for name, env := range config.Environments {
+		if name == "" || len(name) > maxEnvironmentNameLen {
+			return fmt.Errorf("invalid environment name %q", name)
+		}
+		if err := validateRules(env.Policies, channels); err != nil {
+			return fmt.Errorf("environment %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+func validateStallTimeout(raw string) error {
+	if raw == "" {
+		return nil
+	}
+	d, err := time.ParseDuration(raw)
+	if err != nil || d <= 0 || d > maxStallTimeout {
+		return fmt.Errorf("invalid stall_timeout %q: must be a positive duration up to %s", raw, maxStallTimeout)
+	}
+	return nil
+}
+
+// validateUpstreamTimeout accepts an empty value or a positive duration up to an hour.
+func validateUpstreamTimeout(raw string) error {
+	if raw == "" {
+		return nil
+	}
+	d, err := time.ParseDuration(raw)
+	if err != nil || d <= 0 || d > maxUpstreamTimeout {
+		return fmt.Errorf("invalid timeout %q: must be a positive duration up to %s", raw, maxUpstreamTimeout)
+	}
+	return nil
+}
+
+// validateCapture bounds the capture lists and the stored response size, and rejects
+// empty names.
+func validateCapture(c CaptureConfig) error {
+	lists := map[string][]string{"request_headers": c.RequestHeaders, "response_headers": c.ResponseHeaders, "redact": c.Redact}
+	for name, list := range lists {
+		if len(list) > MaxCaptureEntries {
+			return fmt.Errorf("capture.%s has %d entries, limit is %d", name, len(list), MaxCaptureEntries)
+		}
+		for _, entry := range list {
+			if strings.TrimSpace(entry) == "" {
+				return fmt.Errorf("capture.%s must not contain empty names", name)
+			}
+		}
+	}
+	if c.MaxResponseBytes != 0 && (c.MaxResponseBytes < minLimitBytes || c.MaxResponseBytes > maxLimitBodyBytes) {
+		return fmt.Errorf("capture.max_response_bytes %d: must be between %d and %d", c.MaxResponseBytes, minLimitBytes, maxLimitBodyBytes)
+	}
+	return nil
+}
+
+// validateRetry checks the attempt count and backoff durations of the retry section.
+func validateRetry(r RetryConfig) error {
+	if r.MaxAttempts < 0 || r.MaxAttempts > MaxRetryAttempts {
+		return fmt.Errorf("invalid retry.max_attempts %d: must be between 1 and %d", r.MaxAttempts, MaxRetryAttempts)
+	}
+	for _, raw := range []string{r.Backoff, r.MaxBackoff} {
+		if raw == "" {
+			continue
+		}
+		d, err := time.ParseDuration(raw)
+		if err != nil || d < 0 || d > maxRetryDelay {
+			return fmt.Errorf("invalid retry backoff %q: must be a duration up to %s", raw, maxRetryDelay)
+		}
+	}
+	return nil
+}
+
+// validateRules checks per-rule fields that have a closed set of values.
+func validateRules(rules []Rule, channels map[string]notify.ChannelConfig) error {
+	if err := assert.Check(len(rules) <= maxEnvironmentRules, "rules exceed max: %d", len(rules)); err != nil {
+		return err
+	}
+	for i := 0; i < maxEnvironmentRules; i++ {
+		if i >= len(rules) {
+			break
+		}
+		rule := rules[i]
+		if rule.ID == "" {
+			return fmt.Errorf("rule %d: id must not be empty", i)
+		}
+		if rule.Action != "" && rule.Action != RuleActionTag && rule.Action != RuleActionStall {
+			return fmt.Errorf("rule %s: invalid action %q", rule.ID, rule.Action)
+		}
+		if _, err := endpoint.ParseList(rule.AllowHosts); err != nil {
+			return fmt.Errorf("rule %s: allow_hosts: %w", rule.ID, err)
+		}
+		if _, err := endpoint.ParseList(rule.DenyHosts); err != nil {
+			return fmt.Errorf("rule %s: deny_hosts: %w", rule.ID, err)
+		}
+		if err := validateUpstreamTimeout(rule.Timeout); err != nil {
+			return fmt.Errorf("rule %s: %w", rule.ID, err)
+		}
+		if err := validateRedactResponse(rule.RedactResponse); err != nil {
+			return fmt.Errorf("rule %s: %w", rule.ID, err)
+		}
+		if err := validateMaxConcurrent(rule.MaxConcurrent, rule.Overflow); err != nil {
+			return fmt.Errorf("rule %s: %w", rule.ID, err)
+		}
+		for j := 0; j < len(rule.Notify); j++ {
+			if _, ok := channels[rule.Notify[j]]; !ok {
+				return fmt.Errorf("rule %s: notify: unknown channel %q", rule.ID, rule.Notify[j])
+			}
+		}
+		if err := models.ValidateLabels(rule.Labels); err != nil {
+			return fmt.Errorf("rule %s: labels: %w", rule.ID, err)
+		}
+		if err := validateWhen(rule.When); err != nil {
+			return fmt.Errorf("rule %s: when: %w", rule.ID, err)
+		}
+	}
+	return nil
+}
+
+func validateWhen(src string) error {
+	if src == "" {
+		return nil
+	}
+	expr, err := vql.Cached(src)
+	if err != nil {
+		return err
+	}
+	return expr.CheckFields(vql.PolicyFields)
+}
+
+func validateRedactResponse(keys []string) error {
+	if len(keys) > MaxResponseRedactKeys {
+		return fmt.Errorf("redact_response: %d keys exceed max of %d", len(keys), MaxResponseRedactKeys)
+	}
+	for i := 0; i < len(keys); i++ {
+		if strings.TrimSpace(keys[i]) == "" {
+			return fmt.Errorf("redact_response[%d]: key must not be empty", i)
+		}
+	}
+	return nil
+}
+
 // Reload reloads the policy configuration from disk.
 // Returns an error if the file cannot be read or parsed.
 // Logs "policy_reloaded" event on success.
@@ -99,6 +615,7 @@ func (e *ObserverEngine) Reload() error {
 	e.mu.Lock()
 	e.config = newConfig
 	e.mu.Unlock()
+	e.saveLastGood()
 
 	logging.Info("policy_reloaded", logging.Fields{Component: "observer"})
 	return nil
@@ -182,6 +699,269 @@ func (e *ObserverEngine) GetPolicies() []Rule {
 	return e.config.Policies
 }
 
+// GetEnvironment returns the configured default deployment profile (may be empty).
+func (e *ObserverEngine) GetEnvironment() string {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.config.Defaults.Environment
+}
+
+// EnvironmentHeaderAllowed reports whether agents may select a profile via request header.
+func (e *ObserverEngine) EnvironmentHeaderAllowed() bool {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.config.Defaults.EnvironmentHeader
+}
+
+// HasEnvironment reports whether name is the default profile or has a declared overlay.
+func (e *ObserverEngine) HasEnvironment(name string) bool {
+	if err := assert.Check(len(name) <= maxEnvironmentNameLen, "environment name too long: %d", len(name)); err != nil {
+		return false
+	}
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	if name == e.config.Defaults.Environment {
+		return true
+	}
+	_, ok := e.config.Environments[name]
+	return ok
+}
+
+// IsEnforcing reports whether stall actions are applied rather than only recorded.
+func (e *ObserverEngine) IsEnforcing() bool {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.config.Defaults.EnforcementMode == EnforcementEnforce
+}
+
+// GetSchemaValidation returns the schema validation mode, defaulting to tag.
+func (e *ObserverEngine) GetSchemaValidation() string {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	if e.config.Defaults.SchemaValidation == "" {
+		return SchemaValidationTag
+	}
+	return e.config.Defaults.SchemaValidation
+}
+
+// GetLedgerGuarantee returns the ledger guarantee, defaulting to fail_open.
+func (e *ObserverEngine) GetLedgerGuarantee() string {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	if e.config.Defaults.LedgerGuarantee == "" {
+		return GuaranteeFailOpen
+	}
+	return e.config.Defaults.LedgerGuarantee
+}
+
+// SendsUpstreamEventHeaders reports whether forwarded calls carry their ledger reference.
+func (e *ObserverEngine) SendsUpstreamEventHeaders() bool {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.config.Defaults.UpstreamEventHeaders
+}
+
+// GetConfig returns a copy of the loaded policy configuration, for diagnostics.
+func (e *ObserverEngine) GetConfig() Config {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return *e.config
+}
+
+// GetDetectors returns the detector configuration.
+func (e *ObserverEngine) GetDetectors() DetectorsConfig {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.config.Detectors
+}
+
+// GetCapture returns the HTTP header and query capture settings.
+func (e *ObserverEngine) GetCapture() CaptureConfig {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.config.Capture
+}
+
+// GetStallTimeout returns how long a stalled call waits for a decision before it expires.
+func (e *ObserverEngine) GetStallTimeout() time.Duration {
+	e.mu.RLock()
+	raw := e.config.Defaults.StallTimeout
+	e.mu.RUnlock()
+	if raw == "" {
+		return defaultStallTimeout
+	}
+	d, err := time.ParseDuration(raw)
+	if err := assert.Check(err == nil && d > 0, "stall timeout must be a positive duration: %q", raw); err != nil {
+		return defaultStallTimeout
+	}
+	return d
+}
+
+// GetUpstreamTimeout returns the upstream deadline for a call matched by rule (nil when
+// no rule matched): the rule's timeout, else defaults.upstream_timeout. Zero means none.
+func (e *ObserverEngine) GetUpstreamTimeout(rule *Rule) time.Duration {
+	raw := ""
+	if rule != nil {
+		raw = rule.Timeout
+	}
+	if raw == "" {
+		e.mu.RLock()
+		raw = e.config.Defaults.UpstreamTimeout
+		e.mu.RUnlock()
+	}
+	if raw == "" {
+		return 0
+	}
+	d, err := time.ParseDuration(raw)
+	if err := assert.Check(err == nil && d > 0, "upstream timeout must be a positive duration: %q", raw); err != nil {
+		return 0
+	}
+	return d
+}
+
+// RetryPolicy is the effective retry configuration with defaults applied.
+type RetryPolicy struct {
+	MaxAttempts int
+	Backoff     time.Duration
+	MaxBackoff  time.Duration
+}
+
+// Delay returns the wait before retry n (1-based): Backoff doubled per retry, capped.
+func (p RetryPolicy) Delay(n int) time.Duration {
+	d := p.Backoff
+	for i := 1; i < n && i < MaxRetryAttempts && d < p.MaxBackoff; i++ {
+		d *= 2
+	}
+	if d > p.MaxBackoff {
+		return p.MaxBackoff
+	}
+	return d
+}
+
+// GetRetryPolicy returns the retry configuration for idempotent calls.
+func (e *ObserverEngine) GetRetryPolicy() RetryPolicy {
+	e.mu.RLock()
+	cfg := e.config.Retry
+	e.mu.RUnlock()
+	p := RetryPolicy{MaxAttempts: cfg.MaxAttempts, Backoff: defaultRetryBackoff, MaxBackoff: defaultRetryMaxDelay}
+	if p.MaxAttempts == 0 {
+		p.MaxAttempts = defaultRetryAttempts
+	}
+	if d, err := time.ParseDuration(cfg.Backoff); err == nil {
+		p.Backoff = d
+	}
+	if d, err := time.ParseDuration(cfg.MaxBackoff); err == nil {
+		p.MaxBackoff = d
+	}
+	return p
+}
+
+// GetPoliciesFor returns the effective rule list for a deployment profile: the base
+// policies with the environment overlay applied. Unknown or empty names yield the base list.
+func (e *ObserverEngine) GetPoliciesFor(env string) []Rule {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	overlay, ok := e.config.Environments[env]
+	if env == "" || !ok || len(overlay.Policies) == 0 {
+		return e.config.Policies
+	}
+	return mergeRules(e.config.Policies, overlay.Policies)
+}
+
+// mergeRules applies overlay rules on top of base by ID without mutating either slice.
+func mergeRules(base, overlay []Rule) []Rule {
+	if err := assert.Check(len(base) <= maxEnvironmentRules, "base rules exceed max: %d", len(base)); err != nil {
+		return base
+	}
+	if err := assert.Check(len(overlay) <= maxEnvironmentRules, "overlay rules exceed max: %d", len(overlay)); err != nil {
+		return base
+	}
+
+	merged := make([]Rule, len(base), len(base)+len(overlay))
+	copy(merged, base)
+	for i := 0; i < maxEnvironmentRules; i++ {
+		if i >= len(overlay) {
+			break
+		}
+		o := overlay[i]
+		replaced := false
+		for j := 0; j < len(base); j++ {
+			if merged[j].ID == o.ID {
+				merged[j] = overlayRule(merged[j], o)
+				replaced = true
+				break
+			}
+		}
+		if !replaced {
+			merged = append(merged, o)
+		}
+	}
+	return merged
+}
+
+// overlayRule returns base with every non-empty field of o applied.
+func overlayRule(base, o Rule) Rule {
+	if len(o.MatchMethods) > 0 {
+		base.MatchMethods = o.MatchMethods
+	}
+	if o.RiskLevel != "" {
+		base.RiskLevel = o.RiskLevel
+	}
+	if o.LogLevel != "" {
+		base.LogLevel = o.LogLevel
+	}
+	if len(o.MatchConditions) > 0 {
+		base.MatchConditions = o.MatchConditions
+	}
+	if o.When != "" {
+		base.When = o.When
+	}
+	if len(o.Redact) > 0 {
+		base.Redact = o.Redact
+	}
+	if o.Action != "" {
+		base.Action = o.Action
+	}
+	if len(o.AllowHosts) > 0 {
+		base.AllowHosts = o.AllowHosts
+	}
+	if len(o.DenyHosts) > 0 {
+		base.DenyHosts = o.DenyHosts
+	}
+	if len(o.MatchSQL) > 0 {
+		base.MatchSQL = o.MatchSQL
+	}
+	if o.Idempotent {
+		base.Idempotent = true
+	}
+	if o.Timeout != "" {
+		base.Timeout = o.Timeout
+	}
+	if len(o.RedactResponse) > 0 {
+		base.RedactResponse = o.RedactResponse
+	}
+	if len(o.Notify) > 0 {
+		base.Notify = o.Notify
+	}
+	if o.MaxConcurrent > 0 {
+		base.MaxConcurrent = o.MaxConcurrent
+	}
+	if o.Overflow != "" {
+		base.Overflow = o.Overflow
+	}
+	if len(o.Labels) > 0 {
+		merged := make(map[string]string, len(base.Labels)+len(o.Labels))
+		for k, v := range base.Labels {
+			merged[k] = v
+		}
+		for k, v := range o.Labels {
+			merged[k] = v
+		}
+		base.Labels = merged
+	}
+	return base
+}
+
 // MatchPattern checks if a method matches a policy pattern.
 // Supports exact match and wildcard patterns (e.g., "aws:*" matches "aws:CreateBucket").
 // Returns false if either pattern or method is empty.
@@ -205,9 +985,48 @@ func MatchPattern(pattern, method string) bool {
 	return false
 }
 
+// MatchSQL reports whether any match_sql entry (class or verb, case-insensitive) is
+// present in the call's SQL keys.
+func MatchSQL(entries []string, sqlKeys map[string]bool) bool {
+	const maxSQLEntries = 64
+	for i := 0; i < maxSQLEntries; i++ {
+		if i >= len(entries) {
+			break
+		}
+		if sqlKeys[strings.ToLower(entries[i])] {
+			return true
+		}
+	}
+	return false
+}
+
+// MatchesWhen reports whether a call satisfies the rule's conditions and when expression.
+// Rules with neither match every call; a when that fails to compile matches none. The
+// call is taken to have no history, so history() counts nothing.
+func (r *Rule) MatchesWhen(method string, params map[string]interface{}, env string) bool {
+	return r.MatchesTaskCall(method, params, env, nil)
+}
+
+// MatchesTaskCall is MatchesWhen for a call whose task made the prior calls, most recent
+// first, for history().
+func (r *Rule) MatchesTaskCall(method string, params map[string]interface{}, env string, prior []vql.Env) bool {
+	src := vql.And(vql.FromConditions(r.MatchConditions), r.When)
+	if src == "" {
+		return true
+	}
+	expr, err := vql.Cached(src)
+	if err != nil {
+		return false
+	}
+	return expr.Eval(vql.TaskEnv{MapEnv: vql.MapEnv{"method": method, "params": params, "environment": env}, Prior: prior})
+}
+
 // CheckConditions evaluates policy conditions against request parameters.
-// Supports operators: eq, gt, lt, gte, lte. Returns true if all conditions pass.
+// Supports operators: eq, gt, lt, gte, lte, in, not_in (comma-separated values) and cidr
+// (comma-separated ranges the param's IP address must fall in). Returns true if all
+// conditions pass.
 // Returns true if conditions list is empty. Returns false if params is nil.
+// Conditions are evaluated as their query-language translation (see vql.FromConditions).
 func CheckConditions(conditions []map[string]string, params map[string]interface{}) bool {
 	if len(conditions) == 0 {
 		return true
@@ -220,73 +1039,13 @@ func CheckConditions(conditions []map[string]string, params map[string]interface
 		return false
 	}
 
-	for i := 0; i < maxConditions; i++ {
-		if i >= len(conditions) {
-			break
-		}
-		cond := conditions[i]
-		key := cond["key"]
-		operator := cond["operator"]
-		value := cond["value"]
-
-		val, ok := params[key]
-		if !ok {
-			return false
-		}
-
-		switch operator {
-		case "eq":
-			if fmt.Sprintf("%v", val) != value {
-				return false
-			}
-		case "gt", "lt", "gte", "lte":
-			fVal, ok1 := toFloat(val)
-			fTarget, err2 := strconv.ParseFloat(value, 64)
-			if !ok1 || err2 != nil {
-				return false
-			}
-
-			switch operator {
-			case "gt":
-				if !(fVal > fTarget) {
-					return false
-				}
-			case "lt":
-				if !(fVal < fTarget) {
-					return false
-				}
-			case "gte":
-				if !(fVal >= fTarget) {
-					return false
-				}
-			case "lte":
-				if !(fVal <= fTarget) {
-					return false
-				}
-			}
-		default:
-			// Unknown operator, skip
-			continue
-		}
+	src := vql.FromConditions(conditions)
+	if src == "" {
+		return true
 	}
-
-	return true
-}
-
-func toFloat(v interface{}) (float64, bool) {
-	switch i := v.(type) {
-	case float64:
-		return i, true
-	case float32:
-		return float64(i), true
-	case int:
-		return float64(i), true
-	case int64:
-		return float64(i), true
-	case string:
-		f, err := strconv.ParseFloat(i, 64)
-		return f, err == nil
-	default:
-		return 0, false
+	expr, err := vql.Cached(src)
+	if err != nil {
+		return false
 	}
+	return expr.Eval(vql.MapEnv{"params": params})
 }
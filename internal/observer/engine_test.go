@@ -2,8 +2,12 @@ package observer
 
 import (
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 	"time"
+
+	"github.com/slyt3/Logryph/internal/vql"
 )
 
 func TestObserverEngine_Reload(t *testing.T) {
@@ -219,6 +223,23 @@ func TestCheckConditions(t *testing.T) {
 			params: map[string]interface{}{"amount": 50, "mode": "live"},
 			want:   false,
 		},
+		{
+			name: "not_in and cidr success",
+			conditions: []map[string]string{
+				{"key": "region", "operator": "not_in", "value": "[us-east-1, eu-west-1]"},
+				{"key": "target_ip", "operator": "cidr", "value": "10.0.0.0/8"},
+			},
+			params: map[string]interface{}{"region": "ap-south-1", "target_ip": "10.20.0.7"},
+			want:   true,
+		},
+		{
+			name: "in fail",
+			conditions: []map[string]string{
+				{"key": "region", "operator": "in", "value": "us-east-1, eu-west-1"},
+			},
+			params: map[string]interface{}{"region": "ap-south-1"},
+			want:   false,
+		},
 	}
 
 	const maxTests = 32
@@ -234,3 +255,529 @@ func TestCheckConditions(t *testing.T) {
 		})
 	}
 }
+
+func TestRuleMatchesWhen(t *testing.T) {
+	rule := Rule{
+		ID:              "payments",
+		MatchConditions: []map[string]string{{"key": "currency", "operator": "eq", "value": "usd"}},
+		When:            `params.amount > 1000 and environment != "dev" and method =~ "stripe:*"`,
+	}
+	params := map[string]interface{}{"amount": 1500.0, "currency": "usd"}
+	if !rule.MatchesWhen("stripe:charge", params, "prod") {
+		t.Error("expected the rule to match")
+	}
+	if rule.MatchesWhen("stripe:charge", params, "dev") {
+		t.Error("when should exclude dev")
+	}
+	params["currency"] = "eur"
+	if rule.MatchesWhen("stripe:charge", params, "prod") {
+		t.Error("legacy conditions should still apply")
+	}
+	if !(&Rule{ID: "any"}).MatchesWhen("x", nil, "") {
+		t.Error("a rule without conditions should match every call")
+	}
+
+	path := filepath.Join(t.TempDir(), "policy.yaml")
+	body := "version: \"1.0\"\npolicies:\n  - id: typo\n    match_methods: [\"*\"]\n    when: 'parmas.amount > 1'\n"
+	if err := os.WriteFile(path, []byte(body), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadConfig(path); err == nil || !strings.Contains(err.Error(), "unknown field") {
+		t.Errorf("a misspelt field in when should fail the policy: %v", err)
+	}
+}
+
+func TestObserverEngine_EnvironmentOverlay(t *testing.T) {
+	tmpFile := "test-env-policy.yaml"
+	yaml := `
+version: "1.0"
+defaults:
+  environment: "prod"
+  enforcement_mode: "enforce"
+  stall_timeout: "30s"
+policies:
+  - id: "infra"
+    match_methods: ["aws:*"]
+    risk_level: "high"
+environments:
+  prod:
+    policies:
+      - id: "infra"
+        risk_level: "critical"
+        action: "stall"
+      - id: "payments"
+        match_methods: ["stripe:*"]
+        risk_level: "critical"
+`
+	if err := os.WriteFile(tmpFile, []byte(yaml), 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if err := os.Remove(tmpFile); err != nil && !os.IsNotExist(err) {
+			t.Errorf("Failed to remove temp file: %v", err)
+		}
+	})
+
+	engine, err := NewObserverEngine(tmpFile)
+	if err != nil {
+		t.Fatalf("Failed to create engine: %v", err)
+	}
+	if engine.GetEnvironment() != "prod" || !engine.IsEnforcing() {
+		t.Fatalf("expected prod/enforce, got %q enforcing=%v", engine.GetEnvironment(), engine.IsEnforcing())
+	}
+	if engine.GetStallTimeout() != 30*time.Second {
+		t.Errorf("expected 30s stall timeout, got %s", engine.GetStallTimeout())
+	}
+
+	base := engine.GetPoliciesFor("dev")
+	if len(base) != 1 || base[0].RiskLevel != "high" || base[0].Action != "" {
+		t.Errorf("unknown environment should use base rules, got %+v", base)
+	}
+
+	prod := engine.GetPoliciesFor("prod")
+	if len(prod) != 2 {
+		t.Fatalf("expected 2 prod rules, got %d", len(prod))
+	}
+	if prod[0].RiskLevel != "critical" || prod[0].Action != RuleActionStall {
+		t.Errorf("overlay not applied: %+v", prod[0])
+	}
+	if len(prod[0].MatchMethods) != 1 || prod[0].MatchMethods[0] != "aws:*" {
+		t.Errorf("overlay should keep base match_methods: %+v", prod[0].MatchMethods)
+	}
+	if prod[1].ID != "payments" {
+		t.Errorf("expected appended payments rule, got %s", prod[1].ID)
+	}
+}
+
+func TestObserverEngine_InvalidEnforcementMode(t *testing.T) {
+	tmpFile := "test-bad-policy.yaml"
+	yaml := `
+version: "1.0"
+defaults:
+  enforcement_mode: "block-everything"
+policies: []
+`
+	if err := os.WriteFile(tmpFile, []byte(yaml), 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if err := os.Remove(tmpFile); err != nil && !os.IsNotExist(err) {
+			t.Errorf("Failed to remove temp file: %v", err)
+		}
+	})
+
+	if _, err := NewObserverEngine(tmpFile); err == nil {
+		t.Fatal("expected invalid enforcement_mode to be rejected")
+	}
+}
+
+func TestMatchSQL(t *testing.T) {
+	keys := map[string]bool{"read": true, "select": true, "destructive": true, "drop": true}
+	if !MatchSQL([]string{"DROP", "TRUNCATE"}, keys) {
+		t.Error("expected DROP to match case-insensitively")
+	}
+	if !MatchSQL([]string{"destructive"}, keys) {
+		t.Error("expected class match")
+	}
+	if MatchSQL([]string{"ddl", "truncate"}, keys) {
+		t.Error("expected no match")
+	}
+	if MatchSQL([]string{"drop"}, nil) {
+		t.Error("calls without SQL must not match match_sql rules")
+	}
+}
+
+func TestObserverEngine_RetryPolicy(t *testing.T) {
+	tmpFile := "test-retry-policy.yaml"
+	t.Cleanup(func() {
+		if err := os.Remove(tmpFile); err != nil && !os.IsNotExist(err) {
+			t.Errorf("Failed to remove temp file: %v", err)
+		}
+	})
+
+	if err := os.WriteFile(tmpFile, []byte("version: \"1.0\"\nretry:\n  max_attempts: 11\npolicies: []\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewObserverEngine(tmpFile); err == nil {
+		t.Fatal("expected max_attempts above the limit to be rejected")
+	}
+
+	if err := os.WriteFile(tmpFile, []byte("version: \"1.0\"\nretry:\n  backoff: \"100ms\"\n  max_backoff: \"250ms\"\npolicies: []\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	engine, err := NewObserverEngine(tmpFile)
+	if err != nil {
+		t.Fatalf("NewObserverEngine failed: %v", err)
+	}
+	p := engine.GetRetryPolicy()
+	if p.MaxAttempts != defaultRetryAttempts {
+		t.Errorf("expected default attempts, got %d", p.MaxAttempts)
+	}
+	for n, want := range map[int]time.Duration{1: 100 * time.Millisecond, 2: 200 * time.Millisecond, 3: 250 * time.Millisecond} {
+		if got := p.Delay(n); got != want {
+			t.Errorf("Delay(%d) = %s, want %s", n, got, want)
+		}
+	}
+}
+
+func TestObserverEngine_UpstreamTimeout(t *testing.T) {
+	tmpFile := "test-timeout-policy.yaml"
+	t.Cleanup(func() {
+		if err := os.Remove(tmpFile); err != nil && !os.IsNotExist(err) {
+			t.Errorf("Failed to remove temp file: %v", err)
+		}
+	})
+
+	bad := "version: \"1.0\"\npolicies:\n  - id: \"x\"\n    match_methods: [\"*\"]\n    timeout: \"-1s\"\n"
+	if err := os.WriteFile(tmpFile, []byte(bad), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewObserverEngine(tmpFile); err == nil {
+		t.Fatal("expected negative rule timeout to be rejected")
+	}
+
+	good := "version: \"1.0\"\ndefaults:\n  upstream_timeout: \"30s\"\npolicies:\n  - id: \"x\"\n    match_methods: [\"*\"]\n    timeout: \"5s\"\n"
+	if err := os.WriteFile(tmpFile, []byte(good), 0644); err != nil {
+		t.Fatal(err)
+	}
+	engine, err := NewObserverEngine(tmpFile)
+	if err != nil {
+		t.Fatalf("NewObserverEngine failed: %v", err)
+	}
+	rules := engine.GetPolicies()
+	if got := engine.GetUpstreamTimeout(&rules[0]); got != 5*time.Second {
+		t.Errorf("rule timeout = %s, want 5s", got)
+	}
+	if got := engine.GetUpstreamTimeout(nil); got != 30*time.Second {
+		t.Errorf("default timeout = %s, want 30s", got)
+	}
+}
+
+func TestObserverEngine_NotifyChannels(t *testing.T) {
+	tmpFile := "test-notify-policy.yaml"
+	t.Cleanup(func() {
+		if err := os.Remove(tmpFile); err != nil && !os.IsNotExist(err) {
+			t.Errorf("Failed to remove temp file: %v", err)
+		}
+	})
+
+	base := "version: \"1.0\"\nnotifications:\n  channels:\n    - name: \"slack-secops\"\n      type: \"slack\"\n      url: \"https://hooks.example.com/x\"\n"
+	bad := base + "policies:\n  - id: \"billing\"\n    match_methods: [\"stripe:*\"]\n    notify: [\"pagerduty\"]\n"
+	if err := os.WriteFile(tmpFile, []byte(bad), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewObserverEngine(tmpFile); err == nil {
+		t.Fatal("expected a rule naming an undefined channel to be rejected")
+	}
+
+	good := base + "policies:\n  - id: \"billing\"\n    match_methods: [\"stripe:*\"]\n    notify: [\"slack-secops\"]\n"
+	if err := os.WriteFile(tmpFile, []byte(good), 0644); err != nil {
+		t.Fatal(err)
+	}
+	engine, err := NewObserverEngine(tmpFile)
+	if err != nil {
+		t.Fatalf("NewObserverEngine failed: %v", err)
+	}
+	if rules := engine.GetPolicies(); len(rules) != 1 || len(rules[0].Notify) != 1 {
+		t.Fatalf("notify list not loaded: %+v", rules)
+	}
+}
+
+func TestObserverEngine_Limits(t *testing.T) {
+	tmpFile := "test-limits-policy.yaml"
+	t.Cleanup(func() {
+		if err := os.Remove(tmpFile); err != nil && !os.IsNotExist(err) {
+			t.Errorf("Failed to remove temp file: %v", err)
+		}
+	})
+
+	for _, bad := range []string{"proxy:\n    max_body_bytes: 10", "proxy:\n    max_params_depth: -1", "admin:\n    max_array_len: 5"} {
+		if err := os.WriteFile(tmpFile, []byte("version: \"1.0\"\nlimits:\n  "+bad+"\npolicies: []\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := NewObserverEngine(tmpFile); err == nil {
+			t.Errorf("expected limits %q to be rejected", bad)
+		}
+	}
+
+	if err := os.WriteFile(tmpFile, []byte("version: \"1.0\"\nlimits:\n  proxy:\n    max_body_bytes: 4096\npolicies: []\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	engine, err := NewObserverEngine(tmpFile)
+	if err != nil {
+		t.Fatalf("NewObserverEngine failed: %v", err)
+	}
+	if l := engine.GetLimits(ListenerProxy); l.MaxBodyBytes != 4096 || l.MaxParamsDepth != DefaultMaxParamsDepth {
+		t.Errorf("proxy limits = %+v", l)
+	}
+	if l := engine.GetLimits(ListenerAdmin); l.MaxBodyBytes != DefaultMaxBodyBytes {
+		t.Errorf("admin limits = %+v", l)
+	}
+}
+
+func TestObserverEngine_Concurrency(t *testing.T) {
+	tmpFile := "test-concurrency-policy.yaml"
+	t.Cleanup(func() {
+		if err := os.Remove(tmpFile); err != nil && !os.IsNotExist(err) {
+			t.Errorf("Failed to remove temp file: %v", err)
+		}
+	})
+
+	for _, bad := range []string{"max_in_flight: -1", "overflow: drop", "queue_timeout: 0s"} {
+		if err := os.WriteFile(tmpFile, []byte("version: \"1.0\"\nconcurrency:\n  "+bad+"\npolicies: []\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := NewObserverEngine(tmpFile); err == nil {
+			t.Errorf("expected concurrency %q to be rejected", bad)
+		}
+	}
+
+	if err := os.WriteFile(tmpFile, []byte("version: \"1.0\"\nconcurrency:\n  max_in_flight: 8\n  overflow: reject\npolicies: []\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	engine, err := NewObserverEngine(tmpFile)
+	if err != nil {
+		t.Fatalf("NewObserverEngine failed: %v", err)
+	}
+	if p := engine.GetConcurrency(); p.MaxInFlight != 8 || p.Queue || p.MaxQueue != defaultMaxQueue || p.QueueTimeout != defaultQueueTimeout {
+		t.Errorf("concurrency = %+v", p)
+	}
+}
+
+func TestObserverEngine_RuleConcurrency(t *testing.T) {
+	tmpFile := filepath.Join(t.TempDir(), "policy.yaml")
+	rule := "policies:\n  - id: apply\n    match_methods: [\"terraform:apply\"]\n    risk_level: high\n"
+	for _, bad := range []string{"    max_concurrent: -1\n", "    max_concurrent: 1\n    overflow: drop\n"} {
+		if err := os.WriteFile(tmpFile, []byte("version: \"1.0\"\n"+rule+bad), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := NewObserverEngine(tmpFile); err == nil {
+			t.Errorf("expected rule %q to be rejected", bad)
+		}
+	}
+
+	policy := "version: \"1.0\"\nconcurrency:\n  queue_timeout: 30s\n" + rule + "    max_concurrent: 1\n"
+	if err := os.WriteFile(tmpFile, []byte(policy), 0644); err != nil {
+		t.Fatal(err)
+	}
+	engine, err := NewObserverEngine(tmpFile)
+	if err != nil {
+		t.Fatalf("NewObserverEngine failed: %v", err)
+	}
+	rules := engine.GetPolicies()
+	if p := engine.GetRuleConcurrency(&rules[0]); p.MaxInFlight != 1 || !p.Queue || p.QueueTimeout != 30*time.Second {
+		t.Errorf("rule concurrency = %+v", p)
+	}
+	if p := engine.GetRuleConcurrency(nil); p.MaxInFlight != 0 {
+		t.Errorf("no rule should mean no cap, got %+v", p)
+	}
+}
+
+func TestObserverEngine_TaskBudget(t *testing.T) {
+	tmpFile := filepath.Join(t.TempDir(), "policy.yaml")
+	for _, bad := range []string{"max_events: -1", "max_duration: 0s", "action: kill"} {
+		if err := os.WriteFile(tmpFile, []byte("version: \"1.0\"\ntask_budget:\n  "+bad+"\npolicies: []\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := NewObserverEngine(tmpFile); err == nil {
+			t.Errorf("expected task_budget %q to be rejected", bad)
+		}
+	}
+
+	if err := os.WriteFile(tmpFile, []byte("version: \"1.0\"\ntask_budget:\n  max_events: 500\n  max_duration: 30m\npolicies: []\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	engine, err := NewObserverEngine(tmpFile)
+	if err != nil {
+		t.Fatalf("NewObserverEngine failed: %v", err)
+	}
+	if b := engine.GetTaskBudget(); !b.Enabled() || b.MaxEvents != 500 || b.MaxDuration != 30*time.Minute || b.Action != BudgetActionTag {
+		t.Errorf("task budget = %+v", b)
+	}
+}
+
+func TestObserverEngine_TaskHistory(t *testing.T) {
+	tmpFile := filepath.Join(t.TempDir(), "policy.yaml")
+	for _, bad := range []string{"size: -1", "size: 5000", "max_age: 0s", "max_age: 30d"} {
+		if err := os.WriteFile(tmpFile, []byte("version: \"1.0\"\ntask_history:\n  "+bad+"\npolicies: []\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := NewObserverEngine(tmpFile); err == nil {
+			t.Errorf("expected task_history %q to be rejected", bad)
+		}
+	}
+
+	if err := os.WriteFile(tmpFile, []byte("version: \"1.0\"\npolicies:\n  - id: r\n    match_methods: [\"db:*\"]\n    when: params.table = \"users\"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	engine, err := NewObserverEngine(tmpFile)
+	if err != nil {
+		t.Fatalf("NewObserverEngine failed: %v", err)
+	}
+	if h := engine.GetTaskHistory(); h.Enabled || h.Size != DefaultHistorySize || h.MaxAge != DefaultHistoryMaxAge {
+		t.Errorf("without history() rules nothing should be remembered, got %+v", h)
+	}
+
+	policy := `version: "1.0"
+task_history:
+  size: 16
+  max_age: 10m
+policies: []
+environments:
+  prod:
+    policies:
+      - id: delete-after-read
+        match_methods: ["db:delete"]
+        when: history(method = "fs:read") >= 2
+`
+	if err := os.WriteFile(tmpFile, []byte(policy), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := engine.Reload(); err != nil {
+		t.Fatalf("Reload failed: %v", err)
+	}
+	if h := engine.GetTaskHistory(); !h.Enabled || h.Size != 16 || h.MaxAge != 10*time.Minute {
+		t.Errorf("task history = %+v", h)
+	}
+	rule := engine.GetPoliciesFor("prod")[0]
+	prior := []vql.Env{vql.MapEnv{"method": "fs:read"}, vql.MapEnv{"method": "fs:read"}}
+	if !rule.MatchesTaskCall("db:delete", nil, "prod", prior) || rule.MatchesTaskCall("db:delete", nil, "prod", prior[:1]) {
+		t.Error("the rule should match only after two reads")
+	}
+}
+
+func TestObserverEngine_Sampling(t *testing.T) {
+	tmpFile := filepath.Join(t.TempDir(), "policy.yaml")
+	for _, bad := range []string{"rate: -0.1", "rate: 1.5", "rate: 0.1\n  exempt_methods: [\"\"]"} {
+		if err := os.WriteFile(tmpFile, []byte("version: \"1.0\"\nsampling:\n  "+bad+"\npolicies: []\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := NewObserverEngine(tmpFile); err == nil {
+			t.Errorf("expected sampling %q to be rejected", bad)
+		}
+	}
+
+	if err := os.WriteFile(tmpFile, []byte("version: \"1.0\"\nsampling:\n  rate: 0.1\n  exempt_methods: [\"billing:*\"]\npolicies: []\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	engine, err := NewObserverEngine(tmpFile)
+	if err != nil {
+		t.Fatalf("NewObserverEngine failed: %v", err)
+	}
+	s := engine.GetSampling()
+	if !s.Enabled() || s.Rate != 0.1 {
+		t.Errorf("sampling = %+v", s)
+	}
+	for _, c := range []struct {
+		method, risk string
+		stall, want  bool
+	}{
+		{"fs:read", "low", false, false},
+		{"fs:read", "high", false, true},
+		{"fs:read", "critical", false, true},
+		{"fs:read", "", true, true},
+		{"billing:refund", "low", false, true},
+	} {
+		if got := s.AlwaysRecorded(c.method, c.risk, c.stall); got != c.want {
+			t.Errorf("AlwaysRecorded(%s, %q, %v) = %v", c.method, c.risk, c.stall, got)
+		}
+	}
+}
+
+func TestObserverEngine_MethodAliases(t *testing.T) {
+	tmpFile := filepath.Join(t.TempDir(), "policy.yaml")
+	for _, bad := range []string{"fs:read: fs:read", "read_file: \"\"", "read_file: file.read\n  file.read: fs:read"} {
+		if err := os.WriteFile(tmpFile, []byte("version: \"1.0\"\nmethod_aliases:\n  "+bad+"\npolicies: []\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := NewObserverEngine(tmpFile); err == nil {
+			t.Errorf("expected method_aliases %q to be rejected", bad)
+		}
+	}
+
+	if err := os.WriteFile(tmpFile, []byte("version: \"1.0\"\nmethod_aliases:\n  filesystem.read_file: \"fs:read\"\npolicies: []\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	engine, err := NewObserverEngine(tmpFile)
+	if err != nil {
+		t.Fatalf("NewObserverEngine failed: %v", err)
+	}
+	if got := engine.CanonicalMethod("filesystem.read_file"); got != "fs:read" {
+		t.Errorf("CanonicalMethod(filesystem.read_file) = %q", got)
+	}
+	if got := engine.CanonicalMethod("fs:write"); got != "fs:write" {
+		t.Errorf("an unaliased method should be kept, got %q", got)
+	}
+}
+
+func TestNewObserverEngineWithFallback(t *testing.T) {
+	dir := t.TempDir()
+	policy := filepath.Join(dir, "policy.yaml")
+	cache := filepath.Join(dir, "lkg.json")
+	good := "version: \"2.0\"\npolicies:\n  - id: \"rule-1\"\n    match_methods: [\"test:method\"]\n    risk_level: \"low\"\n"
+	if err := os.WriteFile(policy, []byte(good), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// No cache and no policy: refuse.
+	if _, _, err := NewObserverEngineWithFallback(filepath.Join(dir, "missing.yaml"), cache); err == nil {
+		t.Fatal("a missing policy with no cache should fail")
+	}
+	// A good policy loads normally and is cached.
+	e, fb, err := NewObserverEngineWithFallback(policy, cache)
+	if err != nil || fb != nil || e.GetVersion() != "2.0" {
+		t.Fatalf("good policy: %v %+v", err, fb)
+	}
+	// Corrupt it: start on the cached copy and say so.
+	if err := os.WriteFile(policy, []byte("policies: [\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	e, fb, err = NewObserverEngineWithFallback(policy, cache)
+	if err != nil || fb == nil || e.GetVersion() != "2.0" || e.GetRuleCount() != 1 || fb.SHA256 == "" || !strings.Contains(fb.Err, "parsing policy YAML") {
+		t.Fatalf("fallback: %v %+v", err, fb)
+	}
+	if err := e.Reload(); err == nil {
+		t.Error("reloading the corrupt file should still fail")
+	}
+	// A cache edited by hand fails its checksum and is not trusted.
+	data, err := os.ReadFile(cache)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(cache, []byte(strings.Replace(string(data), "rule-1", "rule-2", 1)), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := NewObserverEngineWithFallback(policy, cache); err == nil || !strings.Contains(err.Error(), "checksum") {
+		t.Errorf("tampered cache: %v", err)
+	}
+}
+
+func TestLoadConfigResolvesSecretReferences(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "webhook-token"), []byte("Bearer from-mount\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("LOGRYPH_TEST_HOOK", "https://hooks.example.com/x")
+	path := filepath.Join(dir, "policy.yaml")
+	body := "version: \"1.0\"\nnotifications:\n  channels:\n    - name: ops\n      url: ${env:LOGRYPH_TEST_HOOK}\n" +
+		"      headers: {Authorization: \"${file:" + filepath.Join(dir, "webhook-token") + "}\"}\npolicies: []\n"
+	if err := os.WriteFile(path, []byte(body), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	config, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	ch := config.Notifications.Channels[0]
+	if ch.URL != "https://hooks.example.com/x" || ch.Headers["Authorization"] != "Bearer from-mount" {
+		t.Errorf("channel = %+v", ch)
+	}
+
+	body = strings.Replace(body, "LOGRYPH_TEST_HOOK", "LOGRYPH_TEST_UNSET_HOOK", 1)
+	if err := os.WriteFile(path, []byte(body), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadConfig(path); err == nil || !strings.Contains(err.Error(), "LOGRYPH_TEST_UNSET_HOOK") {
+		t.Errorf("an unset variable should fail the policy: %v", err)
+	}
+}
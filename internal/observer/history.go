@@ -0,0 +1,89 @@
+package observer
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/slyt3/Logryph/internal/vql"
+)
+
+// Defaults for TaskHistoryConfig.
+const (
+	DefaultHistorySize   = 64
+	DefaultHistoryMaxAge = time.Hour
+)
+
+const (
+	maxHistorySize   = 1024
+	maxHistoryMaxAge = 7 * 24 * time.Hour
+)
+
+// TaskHistoryConfig bounds the recent calls the proxy remembers per task for rules whose
+// when: reads history(). Nothing is remembered unless such a rule exists.
+type TaskHistoryConfig struct {
+	Size   int    `yaml:"size,omitempty"`    // calls kept per task, newest first (default 64)
+	MaxAge string `yaml:"max_age,omitempty"` // older calls are forgotten, e.g. "1h" (default)
+}
+
+// TaskHistory is the effective task history with defaults applied. Enabled is false when
+// no rule reads history(), so calls need not be remembered.
+type TaskHistory struct {
+	Enabled bool
+	Size    int
+	MaxAge  time.Duration
+}
+
+func validateTaskHistory(c TaskHistoryConfig) error {
+	if c.Size < 0 || c.Size > maxHistorySize {
+		return fmt.Errorf("invalid task_history.size %d: must be between 0 and %d", c.Size, maxHistorySize)
+	}
+	if c.MaxAge != "" {
+		d, err := time.ParseDuration(c.MaxAge)
+		if err != nil || d <= 0 || d > maxHistoryMaxAge {
+			return fmt.Errorf("invalid task_history.max_age %q: must be a positive duration up to %s", c.MaxAge, maxHistoryMaxAge)
+		}
+	}
+	return nil
+}
+
+// UsesHistory reports whether the rule's when: reads the task's earlier calls.
+func (r *Rule) UsesHistory() bool {
+	if r.When == "" {
+		return false
+	}
+	expr, err := vql.Cached(r.When)
+	return err == nil && expr.UsesHistory()
+}
+
+// usesHistory reports whether any rule, base or environment overlay, reads history().
+func usesHistory(config *Config) bool {
+	for i := 0; i < len(config.Policies); i++ {
+		if config.Policies[i].UsesHistory() {
+			return true
+		}
+	}
+	for _, overlay := range config.Environments {
+		for i := 0; i < len(overlay.Policies); i++ {
+			if overlay.Policies[i].UsesHistory() {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// GetTaskHistory returns the per-task call history settings. It is read per call, so a
+// policy reload that adds or drops the last history() rule applies to the next call.
+func (e *ObserverEngine) GetTaskHistory() TaskHistory {
+	e.mu.RLock()
+	cfg, enabled := e.config.TaskHistory, e.config.usesHistory
+	e.mu.RUnlock()
+	h := TaskHistory{Enabled: enabled, Size: cfg.Size, MaxAge: DefaultHistoryMaxAge}
+	if h.Size == 0 {
+		h.Size = DefaultHistorySize
+	}
+	if d, err := time.ParseDuration(cfg.MaxAge); err == nil {
+		h.MaxAge = d
+	}
+	return h
+}
@@ -0,0 +1,154 @@
+package observer
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/slyt3/Logryph/internal/assert"
+	"github.com/slyt3/Logryph/internal/logging"
+)
+
+// EventTypePolicyFallback records a proxy started on its last-known-good policy because
+// the policy file could not be loaded. Its params carry the error, the cached policy's
+// source, version, sha256 and saved_at.
+const EventTypePolicyFallback = "policy_fallback"
+
+// DefaultLastGoodPath is where the last policy that loaded is cached, next to the ledger.
+const DefaultLastGoodPath = ".logryph_policy_lkg.json"
+
+const maxLastGoodBytes = 16 << 20
+
+// lastGood is the cached copy of a policy file that loaded and validated.
+type lastGood struct {
+	Source  string    `json:"source"`
+	SavedAt time.Time `json:"saved_at"`
+	SHA256  string    `json:"sha256"` // of Policy, checked before the cache is trusted
+	Policy  string    `json:"policy"`
+}
+
+// Fallback describes a start on the last-known-good policy.
+type Fallback struct {
+	Err     string    // why the policy file was not used
+	Source  string    // policy file the cached copy was read from
+	Version string    // version of the cached policy
+	SHA256  string    // of the cached policy
+	SavedAt time.Time // when it was cached
+}
+
+// Params returns the params of the policy_fallback event recording f.
+func (f *Fallback) Params() map[string]interface{} {
+	return map[string]interface{}{
+		"error":    f.Err,
+		"source":   f.Source,
+		"version":  f.Version,
+		"sha256":   f.SHA256,
+		"saved_at": f.SavedAt.Format(time.RFC3339),
+	}
+}
+
+// NewObserverEngineWithFallback loads configPath like NewObserverEngine and caches it at
+// lastGoodPath, as every later successful reload does too. If the file is missing or
+// invalid, it starts on the cached policy instead and returns a Fallback saying so; it
+// fails only when the cache is missing or fails its checksum as well.
+func NewObserverEngineWithFallback(configPath, lastGoodPath string) (*ObserverEngine, *Fallback, error) {
+	if err := assert.Check(lastGoodPath != "", "last-known-good path must not be empty"); err != nil {
+		return nil, nil, err
+	}
+	e, err := NewObserverEngine(configPath)
+	if err == nil {
+		e.lastGoodPath = lastGoodPath
+		e.saveLastGood()
+		return e, nil, nil
+	}
+	cached, config, cacheErr := loadLastGood(lastGoodPath)
+	if cacheErr != nil {
+		return nil, nil, fmt.Errorf("%w (no last-known-good policy: %v)", err, cacheErr)
+	}
+	absPath, absErr := filepath.Abs(configPath)
+	if absErr != nil {
+		absPath = configPath
+	}
+	e = &ObserverEngine{config: config, configPath: absPath, lastGoodPath: lastGoodPath, stopChan: make(chan struct{})}
+	fb := &Fallback{Err: err.Error(), Source: cached.Source, Version: config.Version, SHA256: cached.SHA256, SavedAt: cached.SavedAt}
+	logging.Error(EventTypePolicyFallback, logging.Fields{Component: "observer", Error: err.Error()})
+	return e, fb, nil
+}
+
+// saveLastGood caches the policy file the engine just loaded. It re-reads and re-validates
+// the file so a write racing the load cannot be cached. Failures are logged: a missing
+// cache only matters on a later bad start.
+func (e *ObserverEngine) saveLastGood() {
+	if e.lastGoodPath == "" {
+		return
+	}
+	data, err := os.ReadFile(e.configPath)
+	if err == nil {
+		_, err = parseConfig(data)
+	}
+	if err == nil {
+		sum := sha256.Sum256(data)
+		var out []byte
+		out, err = json.Marshal(lastGood{Source: e.configPath, SavedAt: time.Now().UTC(), SHA256: hex.EncodeToString(sum[:]), Policy: string(data)})
+		if err == nil {
+			err = writeFileAtomic(e.lastGoodPath, out)
+		}
+	}
+	if err != nil {
+		logging.Warn("policy_cache_failed", logging.Fields{Component: "observer", Error: err.Error()})
+	}
+}
+
+// loadLastGood reads the cache, checks its checksum and validates the policy in it.
+func loadLastGood(path string) (*lastGood, *Config, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	if info.Size() > maxLastGoodBytes {
+		return nil, nil, fmt.Errorf("%s is larger than %d bytes", path, maxLastGoodBytes)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	var cached lastGood
+	if err := json.Unmarshal(data, &cached); err != nil {
+		return nil, nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	sum := sha256.Sum256([]byte(cached.Policy))
+	if hex.EncodeToString(sum[:]) != cached.SHA256 {
+		return nil, nil, fmt.Errorf("%s fails its checksum", path)
+	}
+	config, err := parseConfig([]byte(cached.Policy))
+	if err != nil {
+		return nil, nil, fmt.Errorf("cached policy: %w", err)
+	}
+	return &cached, config, nil
+}
+
+// writeFileAtomic replaces path with data through a temporary file in the same directory.
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0o600); err != nil {
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,105 @@
+package observer
+
+import "fmt"
+
+// Listener names for GetLimits.
+const (
+	ListenerProxy = "proxy"
+	ListenerAdmin = "admin"
+)
+
+// Default request limits, applied to zero fields of a limits section.
+const (
+	DefaultMaxBodyBytes   = 1 << 20  // JSON-RPC bodies, and every admin API body
+	DefaultMaxUploadBytes = 64 << 20 // non-JSON proxy bodies: uploads and multipart forms
+	DefaultMaxHeaderBytes = 1 << 20  // same as net/http
+	DefaultMaxParamsDepth = 64
+	DefaultMaxArrayLen    = 10000
+)
+
+// Upper bounds on configured limits.
+const (
+	maxLimitBodyBytes   = 1 << 30
+	maxLimitHeaderBytes = 16 << 20
+	maxLimitParamsDepth = 1000
+	maxLimitArrayLen    = 10000000
+	minLimitBytes       = 1024
+)
+
+// LimitsConfig bounds what each listener accepts, so a misbehaving agent cannot exhaust
+// the proxy's memory. Zero fields take the defaults.
+type LimitsConfig struct {
+	Proxy ListenerLimits `yaml:"proxy,omitempty"`
+	Admin ListenerLimits `yaml:"admin,omitempty"`
+}
+
+// ListenerLimits are the limits of one listener. Header limits are applied at startup;
+// the others are re-read on every request, so a policy reload changes them.
+type ListenerLimits struct {
+	MaxBodyBytes   int64 `yaml:"max_body_bytes,omitempty"`   // JSON-RPC body (admin: any body); default 1 MiB
+	MaxUploadBytes int64 `yaml:"max_upload_bytes,omitempty"` // proxy only: non-JSON body; default 64 MiB
+	MaxHeaderBytes int   `yaml:"max_header_bytes,omitempty"` // request line and headers; default 1 MiB
+	MaxParamsDepth int   `yaml:"max_params_depth,omitempty"` // proxy only: object and array nesting in params; default 64
+	MaxArrayLen    int   `yaml:"max_array_len,omitempty"`    // proxy only: longest array anywhere in params; default 10000
+}
+
+// validateLimits checks both listeners' limits.
+func validateLimits(c LimitsConfig) error {
+	if err := validateListenerLimits(ListenerProxy, c.Proxy); err != nil {
+		return err
+	}
+	a := c.Admin
+	if a.MaxUploadBytes != 0 || a.MaxParamsDepth != 0 || a.MaxArrayLen != 0 {
+		return fmt.Errorf("limits.admin: only max_body_bytes and max_header_bytes apply to the admin API")
+	}
+	return validateListenerLimits(ListenerAdmin, a)
+}
+
+func validateListenerLimits(name string, l ListenerLimits) error {
+	if l.MaxBodyBytes != 0 && (l.MaxBodyBytes < minLimitBytes || l.MaxBodyBytes > maxLimitBodyBytes) {
+		return fmt.Errorf("limits.%s.max_body_bytes %d: must be between %d and %d", name, l.MaxBodyBytes, minLimitBytes, maxLimitBodyBytes)
+	}
+	if l.MaxUploadBytes != 0 && (l.MaxUploadBytes < minLimitBytes || l.MaxUploadBytes > maxLimitBodyBytes) {
+		return fmt.Errorf("limits.%s.max_upload_bytes %d: must be between %d and %d", name, l.MaxUploadBytes, minLimitBytes, maxLimitBodyBytes)
+	}
+	if l.MaxHeaderBytes != 0 && (l.MaxHeaderBytes < minLimitBytes || l.MaxHeaderBytes > maxLimitHeaderBytes) {
+		return fmt.Errorf("limits.%s.max_header_bytes %d: must be between %d and %d", name, l.MaxHeaderBytes, minLimitBytes, maxLimitHeaderBytes)
+	}
+	if l.MaxParamsDepth < 0 || l.MaxParamsDepth > maxLimitParamsDepth {
+		return fmt.Errorf("limits.%s.max_params_depth %d: must be between 1 and %d", name, l.MaxParamsDepth, maxLimitParamsDepth)
+	}
+	if l.MaxArrayLen < 0 || l.MaxArrayLen > maxLimitArrayLen {
+		return fmt.Errorf("limits.%s.max_array_len %d: must be between 1 and %d", name, l.MaxArrayLen, maxLimitArrayLen)
+	}
+	return nil
+}
+
+// WithDefaults returns l with every zero field set to its default.
+func (l ListenerLimits) WithDefaults() ListenerLimits {
+	if l.MaxBodyBytes == 0 {
+		l.MaxBodyBytes = DefaultMaxBodyBytes
+	}
+	if l.MaxUploadBytes == 0 {
+		l.MaxUploadBytes = DefaultMaxUploadBytes
+	}
+	if l.MaxHeaderBytes == 0 {
+		l.MaxHeaderBytes = DefaultMaxHeaderBytes
+	}
+	if l.MaxParamsDepth == 0 {
+		l.MaxParamsDepth = DefaultMaxParamsDepth
+	}
+	if l.MaxArrayLen == 0 {
+		l.MaxArrayLen = DefaultMaxArrayLen
+	}
+	return l
+}
+
+// GetLimits returns the limits of the named listener with defaults applied.
+func (e *ObserverEngine) GetLimits(listener string) ListenerLimits {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	if listener == ListenerAdmin {
+		return e.config.Limits.Admin.WithDefaults()
+	}
+	return e.config.Limits.Proxy.WithDefaults()
+}
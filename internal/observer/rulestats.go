@@ -0,0 +1,98 @@
+package observer
+
+import (
+	"sync"
+	"time"
+)
+
+// Rule actions counted per match.
+const (
+	MatchActionTag           = "tag"
+	MatchActionRedact        = "redact"
+	MatchActionStall         = "stall"          // held for approval in enforce mode
+	MatchActionStallObserved = "stall_observed" // would have stalled, observe mode forwarded it
+)
+
+// maxTrackedRules bounds how many rule IDs are counted, so overlays and reloads that
+// churn IDs cannot grow the table without limit.
+const maxTrackedRules = 4096
+
+// RuleStat is the live activity of one policy rule since startup.
+type RuleStat struct {
+	Matches   uint64            `json:"matches"`
+	LastMatch *time.Time        `json:"last_match,omitempty"`
+	Actions   map[string]uint64 `json:"actions,omitempty"`   // matches by action taken
+	Decisions map[string]uint64 `json:"decisions,omitempty"` // stall outcomes: approved, rejected, expired
+}
+
+// ruleStats counts matches by rule ID. Counts survive policy reloads for rules that keep
+// their ID. The zero value is ready to use.
+type ruleStats struct {
+	mu    sync.Mutex
+	rules map[string]*RuleStat
+}
+
+// entry returns the stat for id, creating it if the table has room. Callers hold s.mu.
+func (s *ruleStats) entry(id string) *RuleStat {
+	if s.rules == nil {
+		s.rules = make(map[string]*RuleStat)
+	}
+	st, ok := s.rules[id]
+	if !ok {
+		if len(s.rules) >= maxTrackedRules {
+			return nil
+		}
+		st = &RuleStat{Actions: map[string]uint64{}, Decisions: map[string]uint64{}}
+		s.rules[id] = st
+	}
+	return st
+}
+
+// RecordMatch counts a match of the rule and the action taken on it.
+func (e *ObserverEngine) RecordMatch(ruleID, action string) {
+	if ruleID == "" {
+		return
+	}
+	now := time.Now()
+	e.stats.mu.Lock()
+	defer e.stats.mu.Unlock()
+	if st := e.stats.entry(ruleID); st != nil {
+		st.Matches++
+		st.LastMatch = &now
+		st.Actions[action]++
+	}
+}
+
+// RecordDecision counts the outcome of a stall raised by the rule.
+func (e *ObserverEngine) RecordDecision(ruleID, decision string) {
+	if ruleID == "" {
+		return
+	}
+	e.stats.mu.Lock()
+	defer e.stats.mu.Unlock()
+	if st := e.stats.entry(ruleID); st != nil {
+		st.Decisions[decision]++
+	}
+}
+
+// GetRuleStat returns a copy of the rule's activity; zero if it never matched.
+func (e *ObserverEngine) GetRuleStat(ruleID string) RuleStat {
+	e.stats.mu.Lock()
+	defer e.stats.mu.Unlock()
+	st, ok := e.stats.rules[ruleID]
+	if !ok {
+		return RuleStat{}
+	}
+	out := RuleStat{Matches: st.Matches, Actions: make(map[string]uint64, len(st.Actions)), Decisions: make(map[string]uint64, len(st.Decisions))}
+	if st.LastMatch != nil {
+		last := *st.LastMatch
+		out.LastMatch = &last
+	}
+	for k, v := range st.Actions {
+		out.Actions[k] = v
+	}
+	for k, v := range st.Decisions {
+		out.Decisions[k] = v
+	}
+	return out
+}
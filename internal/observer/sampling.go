@@ -0,0 +1,66 @@
+package observer
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+const maxSamplingExemptMethods = 256
+
+// SamplingConfig is the sampling section of the policy file. With a rate below 1, only
+// that share of calls is recorded with its params and result; the rest are recorded
+// with their SHA-256 digests in place of the payloads, so the chain still shows every
+// call. A call whose rule or detectors rate it high or critical, or that a stall rule
+// matched, is always recorded in full, whatever the rate: sampling cannot be configured
+// to thin out the events that matter.
+type SamplingConfig struct {
+	// Rate is the share of calls recorded in full, above 0 and at most 1. Zero (the
+	// default) disables sampling.
+	Rate float64 `yaml:"rate,omitempty"`
+	// ExemptMethods are method globs that are always recorded in full as well.
+	ExemptMethods []string `yaml:"exempt_methods,omitempty"`
+}
+
+// Enabled reports whether some calls may be recorded without their payloads.
+func (c SamplingConfig) Enabled() bool {
+	return c.Rate > 0 && c.Rate < 1
+}
+
+// AlwaysRecorded reports whether a call must be recorded in full regardless of the
+// rate: risk is its final risk level after detectors, stall whether a stall rule
+// matched it.
+func (c SamplingConfig) AlwaysRecorded(method, risk string, stall bool) bool {
+	if stall || risk == "high" || risk == "critical" {
+		return true
+	}
+	for j := 0; j < len(c.ExemptMethods) && j < maxSamplingExemptMethods; j++ {
+		if MatchPattern(c.ExemptMethods[j], method) {
+			return true
+		}
+	}
+	return false
+}
+
+// validateSampling checks the rate and the exempt method globs.
+func validateSampling(c SamplingConfig) error {
+	if math.IsNaN(c.Rate) || c.Rate < 0 || c.Rate > 1 {
+		return fmt.Errorf("sampling.rate %v: must be between 0 and 1", c.Rate)
+	}
+	if len(c.ExemptMethods) > maxSamplingExemptMethods {
+		return fmt.Errorf("sampling.exempt_methods has %d entries, limit is %d", len(c.ExemptMethods), maxSamplingExemptMethods)
+	}
+	for _, m := range c.ExemptMethods {
+		if strings.TrimSpace(m) == "" {
+			return fmt.Errorf("sampling.exempt_methods must not contain empty globs")
+		}
+	}
+	return nil
+}
+
+// GetSampling returns the sampling settings.
+func (e *ObserverEngine) GetSampling() SamplingConfig {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.config.Sampling
+}
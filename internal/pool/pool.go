@@ -78,6 +78,15 @@ func PutEvent(e *models.Event) {
 	e.ParentID = ""
 	e.PolicyID = ""
 	e.RiskLevel = ""
+	e.Environment = ""
+	e.Tags = nil
+	e.Labels = nil
+	e.CorrelationID = ""
+	e.TraceID = ""
+	e.SpanID = ""
+	e.Headers = nil
+	e.QueryParams = nil
+	e.ResponseValue = nil
 	e.WasBlocked = false
 
 	// Clear maps but keep allocated capacity
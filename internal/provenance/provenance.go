@@ -0,0 +1,138 @@
+// Package provenance describes the Logryph build that is running, for the provenance event
+// the proxy records at startup. Evidence taken from the ledger then names the software
+// that produced and guarded it: release, commit, toolchain, build flags, the binary's
+// digest and the policy it started with.
+package provenance
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/slyt3/Logryph/internal/models"
+	"github.com/slyt3/Logryph/internal/pool"
+)
+
+// Set at release time with -ldflags "-X github.com/slyt3/Logryph/internal/provenance.Version=...".
+// Commit and Date fall back to the VCS stamp Go embeds when building from a checkout.
+var (
+	Version = ""
+	Commit  = ""
+	Date    = ""
+)
+
+// EventType is the event the proxy records at startup.
+const EventType = "provenance"
+
+const (
+	maxBinaryBytes = 1 << 30
+	maxBuildFlags  = 64
+)
+
+// Build describes the running binary.
+type Build struct {
+	Version      string
+	Commit       string
+	Date         string
+	Modified     bool // built from a checkout with uncommitted changes
+	GoVersion    string
+	Module       string
+	Platform     string            // GOOS/GOARCH
+	Flags        map[string]string // -trimpath, -ldflags, -tags, CGO_ENABLED and the like
+	BinarySHA256 string            // empty when the executable could not be read
+}
+
+// Read describes the running binary. It never fails: what cannot be determined is left
+// empty, or "devel" for the version.
+func Read() Build {
+	b := Build{Version: Version, Commit: Commit, Date: Date, GoVersion: runtime.Version(),
+		Platform: runtime.GOOS + "/" + runtime.GOARCH, Flags: map[string]string{}}
+	if info, ok := debug.ReadBuildInfo(); ok {
+		b.Module = info.Main.Path
+		if b.Version == "" && info.Main.Version != "" && info.Main.Version != "(devel)" {
+			b.Version = info.Main.Version
+		}
+		for i := 0; i < len(info.Settings) && len(b.Flags) < maxBuildFlags; i++ {
+			s := info.Settings[i]
+			switch {
+			case s.Key == "vcs.revision" && b.Commit == "":
+				b.Commit = s.Value
+			case s.Key == "vcs.time" && b.Date == "":
+				b.Date = s.Value
+			case s.Key == "vcs.modified":
+				b.Modified = s.Value == "true"
+			case !strings.HasPrefix(s.Key, "vcs"):
+				b.Flags[s.Key] = s.Value
+			}
+		}
+	}
+	if b.Version == "" {
+		b.Version = "devel"
+	}
+	if exe, err := os.Executable(); err == nil {
+		b.BinarySHA256, _ = FileSHA256(exe, maxBinaryBytes)
+	}
+	return b
+}
+
+// FileSHA256 returns the hex SHA-256 of the file at path, refusing files over max bytes.
+func FileSHA256(path string, max int64) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	n, err := io.Copy(h, io.LimitReader(f, max+1))
+	if err != nil {
+		return "", err
+	}
+	if n > max {
+		return "", fmt.Errorf("%s is larger than %d bytes", path, max)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// Policy is the policy the proxy started with.
+type Policy struct {
+	Path     string
+	Version  string // the policy's version field
+	SHA256   string // of the file, or of the cached copy when running on the last-known-good policy
+	LastGood bool
+}
+
+// Event builds the provenance event for b started with p.
+func Event(b Build, p Policy) *models.Event {
+	event := pool.GetEvent()
+	event.ID = uuid.New().String()[:8]
+	event.Timestamp = time.Now()
+	event.Actor = "system"
+	event.EventType = EventType
+	event.Method = "logryph:provenance"
+	event.Params["version"] = b.Version
+	event.Params["commit"] = b.Commit
+	event.Params["build_date"] = b.Date
+	event.Params["modified"] = b.Modified
+	event.Params["go_version"] = b.GoVersion
+	event.Params["module"] = b.Module
+	event.Params["platform"] = b.Platform
+	// Params hold JSON types, so the event hashes the same before and after storage.
+	flags := make(map[string]interface{}, len(b.Flags))
+	for k, v := range b.Flags {
+		flags[k] = v
+	}
+	event.Params["build_flags"] = flags
+	event.Params["binary_sha256"] = b.BinarySHA256
+	event.Params["config_path"] = p.Path
+	event.Params["config_version"] = p.Version
+	event.Params["config_sha256"] = p.SHA256
+	event.Params["config_last_good"] = p.LastGood
+	return event
+}
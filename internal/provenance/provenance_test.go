@@ -0,0 +1,45 @@
+package provenance
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestReadDescribesTheRunningBinary(t *testing.T) {
+	b := Read()
+	if b.Version == "" || b.GoVersion != runtime.Version() || b.Platform != runtime.GOOS+"/"+runtime.GOARCH {
+		t.Errorf("build = %+v", b)
+	}
+	if len(b.BinarySHA256) != 64 {
+		t.Errorf("binary sha256 = %q", b.BinarySHA256)
+	}
+}
+
+func TestEventRecordsBuildAndPolicy(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "policy.yaml")
+	data := []byte("version: \"1.0\"\npolicies: []\n")
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	sum, err := FileSHA256(path, 1024)
+	if want := sha256.Sum256(data); err != nil || sum != hex.EncodeToString(want[:]) {
+		t.Fatalf("FileSHA256 = %q, %v", sum, err)
+	}
+	if _, err := FileSHA256(path, 4); err == nil {
+		t.Error("a file over the limit should be refused")
+	}
+
+	b := Build{Version: "2026.1.3", Commit: "abc123", GoVersion: "go1.22.5", Flags: map[string]string{"-trimpath": "true", "CGO_ENABLED": "0"}}
+	e := Event(b, Policy{Path: path, Version: "1.0", SHA256: sum})
+	if e.EventType != EventType || e.Actor != "system" || e.Method != "logryph:provenance" {
+		t.Errorf("event = %+v", e)
+	}
+	flags, _ := e.Params["build_flags"].(map[string]interface{})
+	if e.Params["version"] != "2026.1.3" || e.Params["commit"] != "abc123" || e.Params["config_sha256"] != sum || flags["-trimpath"] != "true" {
+		t.Errorf("params = %v", e.Params)
+	}
+}
@@ -0,0 +1,199 @@
+package replication
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/slyt3/Logryph/internal/assert"
+	"github.com/slyt3/Logryph/internal/crypto"
+	"github.com/slyt3/Logryph/internal/logging"
+	"github.com/slyt3/Logryph/internal/models"
+)
+
+// ArchiveStore is the subset of the ledger the archive writes to.
+type ArchiveStore interface {
+	InsertRun(id, agent, genesisHash, pubKey string) error
+	GetRunInfo(runID string) (agent, genesisHash, pubKey string, err error)
+	GetLastEvent(runID string) (uint64, string, error)
+	StoreEvent(event *models.Event) error
+}
+
+// errGap is returned when a batch starts past the archive's cursor.
+var errGap = errors.New("batch does not continue the archived chain")
+
+// Archive receives mirrored events. Batches are applied one at a time so the cursor
+// check and the inserts cannot interleave.
+type Archive struct {
+	store ArchiveStore
+	mu    sync.Mutex
+}
+
+// NewArchive returns an archive writing to store.
+func NewArchive(store ArchiveStore) (*Archive, error) {
+	if err := assert.NotNil(store, "archive store"); err != nil {
+		return nil, err
+	}
+	return &Archive{store: store}, nil
+}
+
+// Handler serves CursorPath and EventsPath. It expects to sit behind a listener using
+// ServerTLSConfig, which authenticates the mirror.
+func (a *Archive) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc(CursorPath, a.handleCursor)
+	mux.HandleFunc(EventsPath, a.handleEvents)
+	return mux
+}
+
+func (a *Archive) handleCursor(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	runID := r.URL.Query().Get("run")
+	if runID == "" {
+		writeAck(w, http.StatusBadRequest, Ack{Error: "run is required"})
+		return
+	}
+	a.mu.Lock()
+	next, _, err := a.cursor(runID)
+	a.mu.Unlock()
+	if err != nil {
+		writeAck(w, http.StatusInternalServerError, Ack{RunID: runID, Error: err.Error()})
+		return
+	}
+	writeAck(w, http.StatusOK, Ack{RunID: runID, Next: next})
+}
+
+func (a *Archive) handleEvents(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var batch Batch
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBatchBytes)).Decode(&batch); err != nil {
+		writeAck(w, http.StatusBadRequest, Ack{Error: "decoding batch: " + err.Error()})
+		return
+	}
+	if batch.Run.ID == "" || len(batch.Events) == 0 || len(batch.Events) > maxBatchEvents {
+		writeAck(w, http.StatusBadRequest, Ack{RunID: batch.Run.ID, Error: fmt.Sprintf("batch needs a run id and 1..%d events", maxBatchEvents)})
+		return
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	next, stored, err := a.apply(&batch)
+	ack := Ack{RunID: batch.Run.ID, Next: next}
+	if err != nil {
+		ack.Error = err.Error()
+		status := http.StatusUnprocessableEntity
+		if errors.Is(err, errGap) {
+			status = http.StatusConflict
+		}
+		logging.Warn("replication_batch_rejected", logging.Fields{Component: "archive", RunID: batch.Run.ID, Error: err.Error()})
+		writeAck(w, status, ack)
+		return
+	}
+	if stored > 0 {
+		logging.Info("replication_batch_stored", logging.Fields{Component: "archive", RunID: batch.Run.ID})
+	}
+	writeAck(w, http.StatusOK, ack)
+}
+
+// cursor returns the next seq the archive expects for runID and the hash it must follow.
+func (a *Archive) cursor(runID string) (uint64, string, error) {
+	seq, head, err := a.store.GetLastEvent(runID)
+	if err != nil {
+		return 0, "", err
+	}
+	if head == "" {
+		return 0, "", nil
+	}
+	return seq + 1, head, nil
+}
+
+// apply stores the events of batch the archive does not yet hold. Events below the
+// cursor are skipped; the rest must continue the chain and hash correctly. It returns the
+// new cursor and how many events were stored.
+func (a *Archive) apply(batch *Batch) (uint64, int, error) {
+	if err := a.ensureRun(batch.Run); err != nil {
+		return 0, 0, err
+	}
+	next, head, err := a.cursor(batch.Run.ID)
+	if err != nil {
+		return 0, 0, err
+	}
+	stored := 0
+	for i := 0; i < len(batch.Events) && i < maxBatchEvents; i++ {
+		e := &batch.Events[i]
+		if e.RunID != batch.Run.ID {
+			return next, stored, fmt.Errorf("event %s belongs to run %s", e.ID, e.RunID)
+		}
+		if e.SeqIndex < next {
+			continue // already archived; the mirror is resending after a lost ack
+		}
+		if e.SeqIndex > next {
+			return next, stored, fmt.Errorf("%w: got seq %d, expected %d", errGap, e.SeqIndex, next)
+		}
+		if err := checkLink(e, batch.Run, head); err != nil {
+			return next, stored, err
+		}
+		if err := a.store.StoreEvent(e); err != nil {
+			return next, stored, fmt.Errorf("storing seq %d: %w", e.SeqIndex, err)
+		}
+		next, head = e.SeqIndex+1, e.CurrentHash
+		stored++
+	}
+	return next, stored, nil
+}
+
+// ensureRun records run on first sight and otherwise checks it matches what was recorded.
+func (a *Archive) ensureRun(run RunInfo) error {
+	_, genesisHash, pubKey, err := a.store.GetRunInfo(run.ID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return a.store.InsertRun(run.ID, run.AgentName, run.GenesisHash, run.PubKey)
+	}
+	if err != nil {
+		return err
+	}
+	if genesisHash != run.GenesisHash || pubKey != run.PubKey {
+		return fmt.Errorf("run %s is already archived with a different genesis or key", run.ID)
+	}
+	return nil
+}
+
+// checkLink verifies e follows head and that its hash covers its contents. Signatures are
+// stored as sent and checked against the run's recorded public key when the archive is
+// verified; a key rotated mid-run would otherwise stall mirroring.
+func checkLink(e *models.Event, run RunInfo, head string) error {
+	if e.SeqIndex == 0 {
+		if e.CurrentHash != run.GenesisHash {
+			return fmt.Errorf("genesis event %s does not match the run's genesis hash", e.ID)
+		}
+	} else if e.PrevHash != head {
+		return fmt.Errorf("seq %d does not link to the archived chain head", e.SeqIndex)
+	}
+	if e.Signature == "" {
+		return fmt.Errorf("seq %d is not signed", e.SeqIndex)
+	}
+	hash, err := crypto.CalculateEventHash(e.PrevHash, e.HashPayload())
+	if err != nil {
+		return fmt.Errorf("hashing seq %d: %w", e.SeqIndex, err)
+	}
+	if hash != e.CurrentHash {
+		return fmt.Errorf("seq %d: hash does not match its contents", e.SeqIndex)
+	}
+	return nil
+}
+
+func writeAck(w http.ResponseWriter, status int, ack Ack) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(ack); err != nil {
+		logging.Error("replication_ack_write_failed", logging.Fields{Component: "archive", Error: err.Error()})
+	}
+}
@@ -0,0 +1,214 @@
+package replication
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/slyt3/Logryph/internal/assert"
+	"github.com/slyt3/Logryph/internal/logging"
+	"github.com/slyt3/Logryph/internal/models"
+)
+
+const (
+	defaultInterval   = 2 * time.Second
+	defaultBatchSize  = 200
+	maxBackoff        = 5 * time.Minute
+	requestTimeout    = 30 * time.Second
+	maxAckBytes       = 1 << 16
+	maxBatchesPerSync = 10000
+	maxMirrorLoop     = 1 << 30
+)
+
+// Source is the subset of the local ledger the mirror reads.
+type Source interface {
+	GetRunID() (string, error)
+	GetRunInfo(runID string) (agent, genesisHash, pubKey string, err error)
+	GetEventsFrom(runID string, fromSeq uint64, limit int) ([]models.Event, error)
+}
+
+// Config describes the archive to mirror to.
+type Config struct {
+	URL       string        // https base URL of the archive
+	TLS       *tls.Config   // client certificate and archive CA, see ClientTLSConfig
+	Interval  time.Duration // poll interval for new events; default 2s
+	BatchSize int           // events per request; default 200
+}
+
+// Mirror sends the current run's committed events to an archive. It keeps the archive's
+// cursor in memory and re-reads it after any failure, so nothing is lost across
+// restarts or dropped acknowledgements.
+type Mirror struct {
+	src      Source
+	base     string
+	client   *http.Client
+	interval time.Duration
+	batch    int
+
+	mu     sync.Mutex // serialises Sync and guards the cursor
+	runID  string
+	next   uint64
+	synced bool // next reflects the archive's cursor for runID
+
+	stop     chan struct{}
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+// NewMirror validates cfg; call Start to begin mirroring.
+func NewMirror(src Source, cfg Config) (*Mirror, error) {
+	if err := assert.NotNil(src, "mirror source"); err != nil {
+		return nil, err
+	}
+	u, err := url.Parse(cfg.URL)
+	if err != nil || u.Scheme != "https" || u.Host == "" {
+		return nil, errors.New("mirror url must be an absolute https URL")
+	}
+	if cfg.TLS == nil || len(cfg.TLS.Certificates) == 0 {
+		return nil, errors.New("mirror needs a client certificate for mutual TLS")
+	}
+	if cfg.Interval <= 0 {
+		cfg.Interval = defaultInterval
+	}
+	if cfg.BatchSize <= 0 || cfg.BatchSize > maxBatchEvents {
+		cfg.BatchSize = defaultBatchSize
+	}
+	return &Mirror{
+		src:      src,
+		base:     strings.TrimRight(cfg.URL, "/"),
+		client:   &http.Client{Timeout: requestTimeout, Transport: &http.Transport{TLSClientConfig: cfg.TLS}},
+		interval: cfg.Interval,
+		batch:    cfg.BatchSize,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}, nil
+}
+
+// Start mirrors in the background until Stop, backing off while the archive is unreachable.
+func (m *Mirror) Start() {
+	go m.run()
+}
+
+func (m *Mirror) run() {
+	defer close(m.done)
+	wait := m.interval
+	failing := false
+	for i := 0; i < maxMirrorLoop; i++ {
+		select {
+		case <-m.stop:
+			return
+		case <-time.After(wait):
+		}
+		err := m.Sync()
+		runID, _ := m.Acked()
+		if err != nil {
+			if !failing {
+				logging.Error("replication_failed", logging.Fields{Component: "mirror", RunID: runID, Error: err.Error()})
+			}
+			failing = true
+			wait = min(wait*2, maxBackoff)
+			continue
+		}
+		if failing {
+			logging.Info("replication_resumed", logging.Fields{Component: "mirror", RunID: runID})
+		}
+		failing = false
+		wait = m.interval
+	}
+}
+
+// Stop ends background mirroring and makes a final attempt to send what remains.
+func (m *Mirror) Stop() error {
+	m.stopOnce.Do(func() { close(m.stop) })
+	<-m.done
+	return m.Sync()
+}
+
+// Acked returns the run being mirrored and the first seq the archive has not acknowledged.
+func (m *Mirror) Acked() (string, uint64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.runID, m.next
+}
+
+// Sync sends every committed event of the current run the archive does not yet hold.
+func (m *Mirror) Sync() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	runID, err := m.src.GetRunID()
+	if err != nil || runID == "" {
+		return err
+	}
+	if runID != m.runID || !m.synced {
+		ack, err := m.fetchCursor(runID)
+		if err != nil {
+			return err
+		}
+		m.runID, m.next, m.synced = runID, ack.Next, true
+	}
+	agent, genesisHash, pubKey, err := m.src.GetRunInfo(runID)
+	if err != nil {
+		return err
+	}
+	run := RunInfo{ID: runID, AgentName: agent, GenesisHash: genesisHash, PubKey: pubKey}
+	for i := 0; i < maxBatchesPerSync; i++ {
+		events, err := m.src.GetEventsFrom(runID, m.next, m.batch)
+		if err != nil {
+			return err
+		}
+		if len(events) == 0 {
+			return nil
+		}
+		ack, err := m.send(Batch{Run: run, Events: events})
+		if err != nil {
+			m.synced = false // re-read the archive's cursor before retrying
+			return err
+		}
+		if ack.Next <= m.next {
+			m.synced = false
+			return fmt.Errorf("archive did not advance past seq %d", m.next)
+		}
+		m.next = ack.Next
+	}
+	return nil
+}
+
+func (m *Mirror) fetchCursor(runID string) (*Ack, error) {
+	resp, err := m.client.Get(m.base + CursorPath + "?run=" + url.QueryEscape(runID))
+	if err != nil {
+		return nil, fmt.Errorf("archive cursor: %w", err)
+	}
+	return readAck(resp, "archive cursor")
+}
+
+func (m *Mirror) send(batch Batch) (*Ack, error) {
+	body, err := json.Marshal(batch)
+	if err != nil {
+		return nil, fmt.Errorf("encoding batch: %w", err)
+	}
+	resp, err := m.client.Post(m.base+EventsPath, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("archive events: %w", err)
+	}
+	return readAck(resp, "archive events")
+}
+
+func readAck(resp *http.Response, label string) (*Ack, error) {
+	defer resp.Body.Close()
+	var ack Ack
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxAckBytes)).Decode(&ack); err != nil {
+		return nil, fmt.Errorf("%s: status %d: %w", label, resp.StatusCode, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s: status %d: %s", label, resp.StatusCode, ack.Error)
+	}
+	return &ack, nil
+}
@@ -0,0 +1,261 @@
+package replication
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/slyt3/Logryph/internal/crypto"
+	"github.com/slyt3/Logryph/internal/ledger/store"
+	"github.com/slyt3/Logryph/internal/models"
+)
+
+const genesisPrev = "0000000000000000000000000000000000000000000000000000000000000000"
+
+type testLedger struct {
+	db     *store.DB
+	signer *crypto.Signer
+	runID  string
+	head   string
+	next   uint64
+}
+
+func newTestLedger(t *testing.T, name string) *testLedger {
+	t.Helper()
+	dir := t.TempDir()
+	db, err := store.NewDB(filepath.Join(dir, name+".db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	signer, err := crypto.NewSigner(filepath.Join(dir, name+".key"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testLedger{db: db, signer: signer, runID: "run-" + name}
+}
+
+// appendEvent hashes, signs and stores the next event of the run, creating the run with
+// its genesis event first.
+func (l *testLedger) appendEvent(t *testing.T, method string) *models.Event {
+	t.Helper()
+	e := &models.Event{
+		ID: l.runID + "-" + method, RunID: l.runID, SeqIndex: l.next, Timestamp: time.Now(),
+		Actor: "agent", EventType: "tool_call", Method: method,
+		Params: map[string]interface{}{"n": float64(l.next)}, PrevHash: l.head,
+	}
+	if l.next == 0 {
+		e.EventType, e.Actor, e.PrevHash = "genesis", "system", genesisPrev
+	}
+	var err error
+	if e.CurrentHash, err = crypto.CalculateEventHash(e.PrevHash, e.HashPayload()); err != nil {
+		t.Fatal(err)
+	}
+	if e.Signature, err = l.signer.SignHash(e.CurrentHash); err != nil {
+		t.Fatal(err)
+	}
+	if l.next == 0 {
+		if err := l.db.InsertRun(l.runID, "test-agent", e.CurrentHash, l.signer.GetPublicKey()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := l.db.StoreEvent(e); err != nil {
+		t.Fatal(err)
+	}
+	l.head, l.next = e.CurrentHash, l.next+1
+	return e
+}
+
+type testPKI struct {
+	serverTLS *tls.Config
+	clientTLS *tls.Config
+}
+
+// newTestPKI issues a CA, a server certificate for 127.0.0.1 and a client certificate,
+// and loads them through ServerTLSConfig and ClientTLSConfig.
+func newTestPKI(t *testing.T) testPKI {
+	t.Helper()
+	dir := t.TempDir()
+	caKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	caTmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1), Subject: pkix.Name{CommonName: "test ca"},
+		NotBefore: time.Now().Add(-time.Hour), NotAfter: time.Now().Add(time.Hour),
+		IsCA: true, BasicConstraintsValid: true, KeyUsage: x509.KeyUsageCertSign,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTmpl, caTmpl, &caKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	caCert, _ := x509.ParseCertificate(caDER)
+	writePEM(t, filepath.Join(dir, "ca.pem"), "CERTIFICATE", caDER)
+
+	issue := func(name string, serial int64, usage x509.ExtKeyUsage) {
+		key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		tmpl := &x509.Certificate{
+			SerialNumber: big.NewInt(serial), Subject: pkix.Name{CommonName: name},
+			NotBefore: time.Now().Add(-time.Hour), NotAfter: time.Now().Add(time.Hour),
+			ExtKeyUsage: []x509.ExtKeyUsage{usage}, IPAddresses: []net.IP{net.ParseIP("127.0.0.1")},
+		}
+		der, err := x509.CreateCertificate(rand.Reader, tmpl, caCert, &key.PublicKey, caKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		keyDER, _ := x509.MarshalECPrivateKey(key)
+		writePEM(t, filepath.Join(dir, name+".pem"), "CERTIFICATE", der)
+		writePEM(t, filepath.Join(dir, name+"-key.pem"), "EC PRIVATE KEY", keyDER)
+	}
+	issue("server", 2, x509.ExtKeyUsageServerAuth)
+	issue("client", 3, x509.ExtKeyUsageClientAuth)
+
+	serverTLS, err := ServerTLSConfig(filepath.Join(dir, "server.pem"), filepath.Join(dir, "server-key.pem"), filepath.Join(dir, "ca.pem"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	clientTLS, err := ClientTLSConfig(filepath.Join(dir, "client.pem"), filepath.Join(dir, "client-key.pem"), filepath.Join(dir, "ca.pem"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return testPKI{serverTLS: serverTLS, clientTLS: clientTLS}
+}
+
+func writePEM(t *testing.T, path, blockType string, der []byte) {
+	t.Helper()
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func startArchive(t *testing.T, pki testPKI, db *store.DB) *httptest.Server {
+	t.Helper()
+	archive, err := NewArchive(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewUnstartedServer(archive.Handler())
+	srv.TLS = pki.serverTLS
+	srv.StartTLS()
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestMirrorReplicatesAndResumes(t *testing.T) {
+	pki := newTestPKI(t)
+	local := newTestLedger(t, "local")
+	remote := newTestLedger(t, "archive")
+	srv := startArchive(t, pki, remote.db)
+
+	for _, m := range []string{"init", "a", "b"} {
+		local.appendEvent(t, m)
+	}
+	mirror, err := NewMirror(local.db, Config{URL: srv.URL, TLS: pki.clientTLS, BatchSize: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := mirror.Sync(); err != nil {
+		t.Fatalf("Sync: %v", err)
+	}
+	if run, next := mirror.Acked(); run != local.runID || next != 3 {
+		t.Fatalf("acked %s/%d, want %s/3", run, next, local.runID)
+	}
+
+	local.appendEvent(t, "c")
+	local.appendEvent(t, "d")
+	// A restarted mirror has no cursor of its own and resumes from the archive's.
+	restarted, err := NewMirror(local.db, Config{URL: srv.URL, TLS: pki.clientTLS})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := restarted.Sync(); err != nil {
+		t.Fatalf("Sync after restart: %v", err)
+	}
+
+	want, _ := local.db.GetAllEvents(local.runID)
+	got, err := remote.db.GetAllEvents(local.runID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != len(want) {
+		t.Fatalf("archive holds %d events, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if got[i].CurrentHash != want[i].CurrentHash || got[i].Signature != want[i].Signature {
+			t.Errorf("seq %d differs between local and archive", i)
+		}
+	}
+	if _, genesis, pub, err := remote.db.GetRunInfo(local.runID); err != nil || genesis != want[0].CurrentHash || pub != local.signer.GetPublicKey() {
+		t.Errorf("archived run info = %s/%s (%v)", genesis, pub, err)
+	}
+}
+
+func TestArchiveIdempotentAndRejectsTampering(t *testing.T) {
+	pki := newTestPKI(t)
+	local := newTestLedger(t, "local")
+	remote := newTestLedger(t, "archive")
+	srv := startArchive(t, pki, remote.db)
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: pki.clientTLS}}
+
+	local.appendEvent(t, "init")
+	local.appendEvent(t, "a")
+	events, _ := local.db.GetAllEvents(local.runID)
+	run := RunInfo{ID: local.runID, AgentName: "test-agent", GenesisHash: events[0].CurrentHash, PubKey: local.signer.GetPublicKey()}
+	post := func(batch Batch) (int, Ack) {
+		body, _ := json.Marshal(batch)
+		resp, err := client.Post(srv.URL+EventsPath, "application/json", bytes.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		var ack Ack
+		_ = json.NewDecoder(resp.Body).Decode(&ack)
+		return resp.StatusCode, ack
+	}
+
+	for i := 0; i < 2; i++ {
+		if status, ack := post(Batch{Run: run, Events: events}); status != http.StatusOK || ack.Next != 2 {
+			t.Fatalf("attempt %d: status %d ack %+v", i, status, ack)
+		}
+	}
+	if got, _ := remote.db.GetAllEvents(local.runID); len(got) != 2 {
+		t.Fatalf("resent batch stored %d events, want 2", len(got))
+	}
+
+	tampered := *local.appendEvent(t, "b")
+	tampered.Params = map[string]interface{}{"n": float64(99)}
+	if status, ack := post(Batch{Run: run, Events: []models.Event{tampered}}); status != http.StatusUnprocessableEntity || ack.Next != 2 {
+		t.Errorf("tampered event: status %d ack %+v", status, ack)
+	}
+	gap := *local.appendEvent(t, "c")
+	if status, ack := post(Batch{Run: run, Events: []models.Event{gap}}); status != http.StatusConflict || ack.Next != 2 {
+		t.Errorf("gap: status %d ack %+v", status, ack)
+	}
+}
+
+func TestArchiveRequiresClientCertificate(t *testing.T) {
+	pki := newTestPKI(t)
+	srv := startArchive(t, pki, newTestLedger(t, "archive").db)
+	anonymous := pki.clientTLS.Clone()
+	anonymous.Certificates = nil
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: anonymous}}
+	resp, err := client.Get(srv.URL + CursorPath + "?run=x")
+	if err == nil {
+		resp.Body.Close()
+		t.Fatal("archive accepted a client without a certificate")
+	}
+	if _, err := NewMirror(newTestLedger(t, "local").db, Config{URL: srv.URL, TLS: anonymous}); err == nil {
+		t.Error("mirror must refuse to run without a client certificate")
+	}
+}
@@ -0,0 +1,107 @@
+// Package reports runs event queries on a schedule inside the proxy. Each report counts
+// the events in a recent window that match a saved or inline query, records the result in
+// the chain as a query_report event, and notifies channels when the count crosses a
+// threshold.
+package reports
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/slyt3/Logryph/internal/notify"
+	"github.com/slyt3/Logryph/internal/vql"
+)
+
+// EventTypeReport records one report run in the chain.
+const EventTypeReport = "query_report"
+
+const (
+	defaultEvery  = time.Hour
+	minEvery      = time.Minute
+	maxReports    = 64
+	maxNameLen    = 128
+	maxWindowDays = 366
+)
+
+// Config is one entry of the reports section of the policy file.
+type Config struct {
+	Name      string   `yaml:"name"`
+	Query     string   `yaml:"query,omitempty"`     // saved query name (logyctl query save)
+	Expr      string   `yaml:"expr,omitempty"`      // inline query, instead of query
+	Every     string   `yaml:"every,omitempty"`     // how often to run; default 1h
+	Window    string   `yaml:"window,omitempty"`    // how far back to count; default every
+	Threshold int      `yaml:"threshold,omitempty"` // 0 records every run
+	Notify    []string `yaml:"notify,omitempty"`    // channels to notify when threshold is crossed
+}
+
+// ValidateConfig checks the reports section against the configured notification channels.
+// Saved queries are resolved when a report runs, since they can change without a restart.
+func ValidateConfig(cfgs []Config, channels map[string]notify.ChannelConfig) error {
+	if len(cfgs) > maxReports {
+		return fmt.Errorf("%d reports exceed max of %d", len(cfgs), maxReports)
+	}
+	seen := make(map[string]bool, len(cfgs))
+	for i := 0; i < len(cfgs); i++ {
+		c := cfgs[i]
+		if c.Name == "" || len(c.Name) > maxNameLen {
+			return fmt.Errorf("reports[%d]: name must be 1-%d bytes", i, maxNameLen)
+		}
+		if seen[c.Name] {
+			return fmt.Errorf("duplicate report %q", c.Name)
+		}
+		seen[c.Name] = true
+		if err := c.validate(channels); err != nil {
+			return fmt.Errorf("report %s: %w", c.Name, err)
+		}
+	}
+	return nil
+}
+
+func (c Config) validate(channels map[string]notify.ChannelConfig) error {
+	if (c.Query == "") == (c.Expr == "") {
+		return errors.New("set exactly one of query or expr")
+	}
+	if c.Expr != "" {
+		if _, err := vql.CompileQuery(c.Expr); err != nil {
+			return fmt.Errorf("invalid expr: %w", err)
+		}
+	}
+	if _, err := c.every(); err != nil {
+		return err
+	}
+	if _, err := c.window(); err != nil {
+		return err
+	}
+	if c.Threshold < 0 {
+		return fmt.Errorf("invalid threshold %d: must not be negative", c.Threshold)
+	}
+	for i := 0; i < len(c.Notify); i++ {
+		if _, ok := channels[c.Notify[i]]; !ok {
+			return fmt.Errorf("unknown notification channel %q", c.Notify[i])
+		}
+	}
+	return nil
+}
+
+func (c Config) every() (time.Duration, error) {
+	if c.Every == "" {
+		return defaultEvery, nil
+	}
+	d, err := time.ParseDuration(c.Every)
+	if err != nil || d < minEvery {
+		return 0, fmt.Errorf("invalid every %q: must be a duration of at least %s", c.Every, minEvery)
+	}
+	return d, nil
+}
+
+func (c Config) window() (time.Duration, error) {
+	if c.Window == "" {
+		return c.every()
+	}
+	d, err := time.ParseDuration(c.Window)
+	if err != nil || d <= 0 || d > maxWindowDays*24*time.Hour {
+		return 0, fmt.Errorf("invalid window %q: must be a positive duration of at most %d days", c.Window, maxWindowDays)
+	}
+	return d, nil
+}
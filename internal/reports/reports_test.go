@@ -0,0 +1,152 @@
+package reports
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/slyt3/Logryph/internal/models"
+	"github.com/slyt3/Logryph/internal/notify"
+)
+
+type memLedger struct {
+	events []models.Event
+}
+
+func (l *memLedger) GetRunID() (string, error) { return "run-1", nil }
+
+func (l *memLedger) ForEachEvent(runID string, fn func(*models.Event) error) error {
+	for i := range l.events {
+		if err := fn(&l.events[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (l *memLedger) call(method string, amount float64, at time.Time) {
+	l.events = append(l.events, models.Event{
+		ID: "e", EventType: "tool_call", Method: method, Timestamp: at,
+		Params: map[string]interface{}{"amount": amount},
+	})
+}
+
+type savedQueries map[string]string
+
+func (q savedQueries) GetSavedQuery(name string) (*models.SavedQuery, error) {
+	expr, ok := q[name]
+	if !ok {
+		return nil, errors.New("not found")
+	}
+	return &models.SavedQuery{Name: name, Expr: expr}, nil
+}
+
+type recorder struct {
+	sent []notify.Notification
+}
+
+func (r *recorder) Notify(names []string, n notify.Notification) {
+	r.sent = append(r.sent, n)
+}
+
+func TestSchedulerThreshold(t *testing.T) {
+	now := time.Now()
+	ledger := &memLedger{}
+	ledger.call("stripe:charge", 5000, now.Add(-2*time.Hour)) // outside the window
+	ledger.call("stripe:charge", 2000, now.Add(-time.Minute))
+	ledger.call("stripe:charge", 10, now.Add(-time.Minute))
+
+	var submitted []*models.Event
+	notes := &recorder{}
+	s, err := NewScheduler([]Config{{
+		Name: "high-spend", Query: "high-spend", Every: "1h", Threshold: 2, Notify: []string{"ops"},
+	}}, ledger, savedQueries{"high-spend": "params.amount > 1000"}, func(e *models.Event) {
+		submitted = append(submitted, e)
+	}, notes)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := s.Run("high-spend", now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Matched != 1 || res.EventID != "" || len(submitted) != 0 {
+		t.Fatalf("below the threshold nothing should be recorded: %+v", res)
+	}
+
+	ledger.call("stripe:refund", 3000, now)
+	if res, err = s.Run("high-spend", now); err != nil {
+		t.Fatal(err)
+	}
+	if res.Matched != 2 || len(submitted) != 1 || len(notes.sent) != 1 {
+		t.Fatalf("crossing the threshold should record and notify once: %+v", res)
+	}
+	if submitted[0].EventType != EventTypeReport || submitted[0].Params["matched"] != 2 {
+		t.Errorf("unexpected report event: %+v", submitted[0])
+	}
+	if n := notes.sent[0]; n.Report != "high-spend" || n.Count != 2 || n.Threshold != 2 || n.EventID != res.EventID {
+		t.Errorf("unexpected notification: %+v", n)
+	}
+
+	if _, err = s.Run("high-spend", now); err != nil {
+		t.Fatal(err)
+	}
+	if len(submitted) != 1 || len(notes.sent) != 1 {
+		t.Error("staying above the threshold should not notify again")
+	}
+}
+
+func TestSchedulerRunDue(t *testing.T) {
+	now := time.Now()
+	ledger := &memLedger{}
+	ledger.call("aws:s3:PutObject", 1, now)
+	ledger.events = append(ledger.events, models.Event{EventType: EventTypeReport, Method: "report:calls", Timestamp: now})
+
+	var submitted []*models.Event
+	s, err := NewScheduler([]Config{{Name: "calls", Expr: `method =~ "aws:*" or type = "query_report"`, Every: "10m", Window: "1h"}},
+		ledger, nil, func(e *models.Event) { submitted = append(submitted, e) }, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.RunDue(now)
+	if len(submitted) != 0 {
+		t.Fatal("a report should not run before its first interval")
+	}
+	s.RunDue(now.Add(11 * time.Minute))
+	if len(submitted) != 1 || submitted[0].Params["matched"] != 1 {
+		t.Fatalf("a due report without threshold should record its count, excluding reports: %+v", submitted)
+	}
+	s.RunDue(now.Add(15 * time.Minute))
+	if len(submitted) != 1 {
+		t.Error("a report should not run again before its next interval")
+	}
+}
+
+func TestValidateConfig(t *testing.T) {
+	channels := map[string]notify.ChannelConfig{"ops": {Name: "ops"}}
+	valid := []Config{{Name: "a", Query: "saved"}, {Name: "b", Expr: "risk = 'high'", Every: "5m", Window: "24h", Notify: []string{"ops"}}}
+	if err := ValidateConfig(valid, channels); err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range []Config{
+		{Name: "", Query: "q"},
+		{Name: "x"},
+		{Name: "x", Query: "q", Expr: "true"},
+		{Name: "x", Expr: "mehtod = 'a'"},
+		{Name: "x", Query: "q", Every: "10s"},
+		{Name: "x", Query: "q", Window: "-1h"},
+		{Name: "x", Query: "q", Threshold: -1},
+		{Name: "x", Query: "q", Notify: []string{"pager"}},
+	} {
+		if err := ValidateConfig([]Config{c}, channels); err == nil {
+			t.Errorf("%+v should be rejected", c)
+		}
+	}
+	if err := ValidateConfig([]Config{{Name: "a", Query: "q"}, {Name: "a", Query: "q"}}, channels); err == nil {
+		t.Error("duplicate names should be rejected")
+	}
+	if _, err := NewScheduler([]Config{{Name: "a", Query: "q"}}, &memLedger{}, nil, func(*models.Event) {}, nil); err == nil {
+		t.Error("a saved-query report without a query store should be rejected")
+	}
+}
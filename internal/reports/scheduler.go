@@ -0,0 +1,231 @@
+package reports
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/slyt3/Logryph/internal/assert"
+	"github.com/slyt3/Logryph/internal/logging"
+	"github.com/slyt3/Logryph/internal/models"
+	"github.com/slyt3/Logryph/internal/notify"
+	"github.com/slyt3/Logryph/internal/pool"
+	"github.com/slyt3/Logryph/internal/vql"
+)
+
+const (
+	tickInterval = time.Minute
+	maxTicks     = 1 << 30
+)
+
+// Source is the subset of the ledger reports read.
+type Source interface {
+	GetRunID() (string, error)
+	ForEachEvent(runID string, fn func(*models.Event) error) error
+}
+
+// Queries resolves saved queries by name.
+type Queries interface {
+	GetSavedQuery(name string) (*models.SavedQuery, error)
+}
+
+// Notifier delivers threshold notifications, normally a *notify.Dispatcher.
+type Notifier interface {
+	Notify(names []string, n notify.Notification)
+}
+
+// Result is the outcome of one report run.
+type Result struct {
+	Report  string
+	Query   string
+	Matched int
+	EventID string // the query_report event, or "" when the run recorded nothing
+}
+
+type scheduled struct {
+	cfg    Config
+	every  time.Duration
+	window time.Duration
+	next   time.Time
+	above  bool // the last run reached the threshold
+}
+
+// Scheduler runs configured reports when they fall due.
+type Scheduler struct {
+	src      Source
+	queries  Queries
+	submit   func(*models.Event)
+	notifier Notifier
+
+	mu      sync.Mutex // serialises runs and guards reports
+	reports []*scheduled
+
+	stop     chan struct{}
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+// NewScheduler validates cfgs and prepares them to run. queries may be nil when no report
+// names a saved query; notifier may be nil when no report notifies. submit appends
+// query_report events to the chain, normally Worker.Submit.
+func NewScheduler(cfgs []Config, src Source, queries Queries, submit func(*models.Event), notifier Notifier) (*Scheduler, error) {
+	if err := assert.NotNil(src, "report source"); err != nil {
+		return nil, err
+	}
+	if submit == nil {
+		return nil, errors.New("report scheduler needs a submit function")
+	}
+	s := &Scheduler{
+		src: src, queries: queries, submit: submit, notifier: notifier,
+		stop: make(chan struct{}), done: make(chan struct{}),
+	}
+	now := time.Now()
+	for i := 0; i < len(cfgs) && i < maxReports; i++ {
+		c := cfgs[i]
+		if c.Query != "" && queries == nil {
+			return nil, fmt.Errorf("report %s: saved queries need the sqlite ledger", c.Name)
+		}
+		every, err := c.every()
+		if err != nil {
+			return nil, fmt.Errorf("report %s: %w", c.Name, err)
+		}
+		window, err := c.window()
+		if err != nil {
+			return nil, fmt.Errorf("report %s: %w", c.Name, err)
+		}
+		s.reports = append(s.reports, &scheduled{cfg: c, every: every, window: window, next: now.Add(every)})
+	}
+	return s, nil
+}
+
+// Start runs due reports, checking once a minute, until Stop.
+func (s *Scheduler) Start() {
+	go s.run()
+}
+
+func (s *Scheduler) run() {
+	defer close(s.done)
+	ticker := time.NewTicker(tickInterval)
+	defer ticker.Stop()
+	for i := 0; i < maxTicks; i++ {
+		select {
+		case <-s.stop:
+			return
+		case now := <-ticker.C:
+			s.RunDue(now)
+		}
+	}
+}
+
+// Stop ends scheduled runs.
+func (s *Scheduler) Stop() {
+	s.stopOnce.Do(func() { close(s.stop) })
+	<-s.done
+}
+
+// RunDue runs every report whose next run is at or before now. Failures are logged and
+// the report is retried on its next interval.
+func (s *Scheduler) RunDue(now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := 0; i < len(s.reports); i++ {
+		r := s.reports[i]
+		if now.Before(r.next) {
+			continue
+		}
+		r.next = now.Add(r.every)
+		if _, err := s.runLocked(r, now); err != nil {
+			logging.Error("report_failed", logging.Fields{Component: "reports", Method: "report:" + r.cfg.Name, Error: err.Error()})
+		}
+	}
+}
+
+// Run runs the named report immediately, whether or not it is due.
+func (s *Scheduler) Run(name string, now time.Time) (*Result, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := 0; i < len(s.reports); i++ {
+		if s.reports[i].cfg.Name == name {
+			return s.runLocked(s.reports[i], now)
+		}
+	}
+	return nil, fmt.Errorf("no report named %q", name)
+}
+
+// runLocked counts matches in r's window. With no threshold every run is recorded; with
+// one, only a run that reaches it after a run that did not is recorded and notified.
+func (s *Scheduler) runLocked(r *scheduled, now time.Time) (*Result, error) {
+	expr, err := s.resolve(r.cfg)
+	if err != nil {
+		return nil, err
+	}
+	runID, err := s.src.GetRunID()
+	if err != nil || runID == "" {
+		return nil, err
+	}
+	matched, err := count(s.src, runID, expr, now.Add(-r.window))
+	if err != nil {
+		return nil, fmt.Errorf("reading events: %w", err)
+	}
+	res := &Result{Report: r.cfg.Name, Query: expr.String(), Matched: matched}
+	above := r.cfg.Threshold > 0 && res.Matched >= r.cfg.Threshold
+	crossed := above && !r.above
+	r.above = above
+	if r.cfg.Threshold > 0 && !crossed {
+		return res, nil
+	}
+	res.EventID = s.record(r, res, runID, now)
+	if len(r.cfg.Notify) > 0 && s.notifier != nil {
+		s.notifier.Notify(r.cfg.Notify, notify.Notification{
+			EventID: res.EventID, Method: "report:" + r.cfg.Name, Report: r.cfg.Name,
+			Query: res.Query, Count: res.Matched, Threshold: r.cfg.Threshold, Timestamp: now,
+		})
+	}
+	return res, nil
+}
+
+func (s *Scheduler) resolve(c Config) (*vql.Expr, error) {
+	if c.Expr != "" {
+		return vql.CompileQuery(c.Expr)
+	}
+	q, err := s.queries.GetSavedQuery(c.Query)
+	if err != nil {
+		return nil, fmt.Errorf("saved query %s: %w", c.Query, err)
+	}
+	return vql.CompileQuery(q.Expr)
+}
+
+// count returns how many of runID's events since from match expr. Earlier report records
+// are never counted, so a report cannot feed on its own output.
+func count(src Source, runID string, expr *vql.Expr, from time.Time) (int, error) {
+	n := 0
+	err := src.ForEachEvent(runID, func(e *models.Event) error {
+		if e.EventType != EventTypeReport && !e.Timestamp.Before(from) && expr.Eval(vql.EventEnv(e)) {
+			n++
+		}
+		return nil
+	})
+	return n, err
+}
+
+func (s *Scheduler) record(r *scheduled, res *Result, runID string, now time.Time) string {
+	event := pool.GetEvent()
+	event.ID = uuid.New().String()[:8]
+	event.Timestamp = now
+	event.EventType = EventTypeReport
+	event.Method = "report:" + r.cfg.Name
+	event.Actor = "system"
+	event.Params["report"] = r.cfg.Name
+	event.Params["query"] = res.Query
+	event.Params["matched"] = res.Matched
+	event.Params["window"] = r.window.String()
+	if r.cfg.Threshold > 0 {
+		event.Params["threshold"] = r.cfg.Threshold
+	}
+	eventID := event.ID
+	s.submit(event)
+	logging.Info("report_recorded", logging.Fields{Component: "reports", RunID: runID, EventID: eventID, Method: "report:" + r.cfg.Name})
+	return eventID
+}
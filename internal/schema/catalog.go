@@ -0,0 +1,81 @@
+package schema
+
+import (
+	"sync"
+
+	"github.com/slyt3/Logryph/internal/assert"
+)
+
+const (
+	maxCatalogTools = 4096
+	maxToolNameLen  = 256
+)
+
+// Catalog holds tool input schemas discovered from MCP tools/list responses.
+// Safe for concurrent use; later listings replace earlier schemas for the same tool.
+type Catalog struct {
+	mu    sync.RWMutex
+	tools map[string]map[string]interface{}
+}
+
+// NewCatalog creates an empty catalog.
+func NewCatalog() *Catalog {
+	return &Catalog{tools: make(map[string]map[string]interface{})}
+}
+
+// Observe captures input schemas from a tools/list result ({"tools": [{"name", "inputSchema"}]}).
+// Results of any other shape are ignored. Returns the number of schemas captured.
+func (c *Catalog) Observe(result map[string]interface{}) int {
+	if err := assert.NotNil(c, "catalog"); err != nil {
+		return 0
+	}
+	tools, ok := result["tools"].([]interface{})
+	if !ok {
+		return 0
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	captured := 0
+	for i := 0; i < maxCatalogTools; i++ {
+		if i >= len(tools) {
+			break
+		}
+		tool, ok := tools[i].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		name, _ := tool["name"].(string)
+		input, ok := tool["inputSchema"].(map[string]interface{})
+		if name == "" || len(name) > maxToolNameLen || !ok {
+			continue
+		}
+		if _, exists := c.tools[name]; !exists && len(c.tools) >= maxCatalogTools {
+			continue
+		}
+		c.tools[name] = input
+		captured++
+	}
+	return captured
+}
+
+// Lookup returns the input schema captured for a tool.
+func (c *Catalog) Lookup(name string) (map[string]interface{}, bool) {
+	if err := assert.NotNil(c, "catalog"); err != nil {
+		return nil, false
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	s, ok := c.tools[name]
+	return s, ok
+}
+
+// Len returns the number of tools in the catalog.
+func (c *Catalog) Len() int {
+	if err := assert.NotNil(c, "catalog"); err != nil {
+		return 0
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return len(c.tools)
+}
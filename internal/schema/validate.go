@@ -0,0 +1,204 @@
+package schema
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+const (
+	maxSchemaDepth = 16
+	maxViolations  = 32
+	maxProperties  = 512
+	maxArrayItems  = 1024
+	maxChecks      = 1 << 16 // values checked per call, across all depths
+)
+
+// Validate checks args against the subset of JSON Schema used by MCP tool definitions:
+// type, required, properties, additionalProperties (false), enum, and array items.
+// Returns human-readable violations; nil means the arguments conform.
+// Unknown keywords are ignored so richer schemas never produce false positives.
+func Validate(inputSchema map[string]interface{}, args map[string]interface{}) []string {
+	if inputSchema == nil {
+		return nil
+	}
+	v := &validator{}
+	var value interface{} = args
+	if args == nil {
+		value = map[string]interface{}{}
+	}
+	v.pending = append(v.pending, pendingCheck{schema: inputSchema, value: value})
+	for n := 0; n < maxChecks && len(v.pending) > 0; n++ {
+		c := v.pending[len(v.pending)-1]
+		v.pending = v.pending[:len(v.pending)-1]
+		if c.unexpected {
+			v.addf("%s: unexpected property", c.path)
+			continue
+		}
+		v.check(c.path, c.schema, c.value, c.depth)
+	}
+	return v.violations
+}
+
+type validator struct {
+	violations []string
+	pending    []pendingCheck // last in, first checked
+}
+
+// pendingCheck is a value still to be checked against its schema. A property that
+// additionalProperties: false rejects is queued too, so violations keep document order.
+type pendingCheck struct {
+	path       string
+	schema     map[string]interface{}
+	value      interface{}
+	depth      int
+	unexpected bool
+}
+
+func (v *validator) addf(format string, args ...interface{}) {
+	if len(v.violations) >= maxViolations {
+		return
+	}
+	v.violations = append(v.violations, fmt.Sprintf(format, args...))
+}
+
+func (v *validator) check(path string, s map[string]interface{}, value interface{}, depth int) {
+	if depth > maxSchemaDepth || len(v.violations) >= maxViolations {
+		return
+	}
+	if want, ok := s["type"]; ok && !matchesType(want, value) {
+		v.addf("%s: expected %v, got %s", displayPath(path), want, typeName(value))
+		return
+	}
+	if enum, ok := s["enum"].([]interface{}); ok && !inEnum(enum, value) {
+		v.addf("%s: value %v not in enum", displayPath(path), value)
+	}
+
+	switch val := value.(type) {
+	case map[string]interface{}:
+		v.checkObject(path, s, val, depth)
+	case []interface{}:
+		items, ok := s["items"].(map[string]interface{})
+		if !ok {
+			return
+		}
+		// Pushed last to first, so items are checked in order.
+		for i := min(len(val), maxArrayItems) - 1; i >= 0; i-- {
+			v.pending = append(v.pending, pendingCheck{path: fmt.Sprintf("%s[%d]", path, i), schema: items, value: val[i], depth: depth + 1})
+		}
+	}
+}
+
+func (v *validator) checkObject(path string, s map[string]interface{}, obj map[string]interface{}, depth int) {
+	props, _ := s["properties"].(map[string]interface{})
+
+	if required, ok := s["required"].([]interface{}); ok {
+		for i := 0; i < maxProperties; i++ {
+			if i >= len(required) {
+				break
+			}
+			name, _ := required[i].(string)
+			if _, present := obj[name]; name != "" && !present {
+				v.addf("%s: missing required property", joinPath(path, name))
+			}
+		}
+	}
+
+	// Sorted for deterministic violation order across runs.
+	keys := make([]string, 0, len(obj))
+	for k := range obj {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	closed := s["additionalProperties"] == false
+	for i := min(len(keys), maxProperties) - 1; i >= 0; i-- {
+		propSchema, known := props[keys[i]].(map[string]interface{})
+		if !known && !closed {
+			continue
+		}
+		v.pending = append(v.pending, pendingCheck{path: joinPath(path, keys[i]), schema: propSchema, value: obj[keys[i]], depth: depth + 1, unexpected: !known})
+	}
+}
+
+// matchesType accepts a single type name or a list of alternatives.
+func matchesType(want interface{}, value interface{}) bool {
+	switch w := want.(type) {
+	case string:
+		return isType(w, value)
+	case []interface{}:
+		for i := 0; i < len(w) && i < maxProperties; i++ {
+			if name, ok := w[i].(string); ok && isType(name, value) {
+				return true
+			}
+		}
+		return false
+	}
+	return true // malformed type keyword: don't flag the agent for the server's schema
+}
+
+func isType(name string, value interface{}) bool {
+	switch name {
+	case "object":
+		_, ok := value.(map[string]interface{})
+		return ok
+	case "array":
+		_, ok := value.([]interface{})
+		return ok
+	case "string":
+		_, ok := value.(string)
+		return ok
+	case "number":
+		_, ok := value.(float64)
+		return ok
+	case "integer":
+		f, ok := value.(float64)
+		return ok && f == math.Trunc(f)
+	case "boolean":
+		_, ok := value.(bool)
+		return ok
+	case "null":
+		return value == nil
+	}
+	return true
+}
+
+func typeName(value interface{}) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	case string:
+		return "string"
+	case float64:
+		return "number"
+	case bool:
+		return "boolean"
+	}
+	return fmt.Sprintf("%T", value)
+}
+
+func inEnum(enum []interface{}, value interface{}) bool {
+	for i := 0; i < len(enum) && i < maxProperties; i++ {
+		if fmt.Sprint(enum[i]) == fmt.Sprint(value) && typeName(enum[i]) == typeName(value) {
+			return true
+		}
+	}
+	return false
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+func displayPath(path string) string {
+	if path == "" {
+		return "arguments"
+	}
+	return path
+}
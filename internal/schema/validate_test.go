@@ -0,0 +1,68 @@
+package schema
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCatalogObserve(t *testing.T) {
+	c := NewCatalog()
+	result := map[string]interface{}{
+		"tools": []interface{}{
+			map[string]interface{}{
+				"name":        "db:query",
+				"inputSchema": map[string]interface{}{"type": "object"},
+			},
+			map[string]interface{}{"name": "no-schema"},
+		},
+	}
+	if n := c.Observe(result); n != 1 {
+		t.Fatalf("expected 1 schema captured, got %d", n)
+	}
+	if _, ok := c.Lookup("db:query"); !ok {
+		t.Error("expected db:query in catalog")
+	}
+	if c.Observe(map[string]interface{}{"content": "not a listing"}) != 0 {
+		t.Error("non-listing result should be ignored")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	inputSchema := map[string]interface{}{
+		"type":                 "object",
+		"required":             []interface{}{"table", "limit"},
+		"additionalProperties": false,
+		"properties": map[string]interface{}{
+			"table": map[string]interface{}{"type": "string"},
+			"limit": map[string]interface{}{"type": "integer"},
+			"mode":  map[string]interface{}{"type": "string", "enum": []interface{}{"read", "write"}},
+			"ids":   map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "number"}},
+		},
+	}
+
+	tests := []struct {
+		name string
+		args map[string]interface{}
+		want []string
+	}{
+		{"valid", map[string]interface{}{"table": "users", "limit": float64(10), "mode": "read"}, nil},
+		{"missing required", map[string]interface{}{"table": "users"}, []string{"limit: missing required property"}},
+		{"wrong type", map[string]interface{}{"table": "users", "limit": 2.5}, []string{"limit: expected integer"}},
+		{"bad enum", map[string]interface{}{"table": "users", "limit": float64(1), "mode": "drop"}, []string{"mode: value drop not in enum"}},
+		{"hallucinated param", map[string]interface{}{"table": "users", "limit": float64(1), "force": true}, []string{"force: unexpected property"}},
+		{"array items", map[string]interface{}{"table": "users", "limit": float64(1), "ids": []interface{}{float64(1), "two"}}, []string{"ids[1]: expected number"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Validate(inputSchema, tt.args)
+			if len(got) != len(tt.want) {
+				t.Fatalf("expected %d violations, got %v", len(tt.want), got)
+			}
+			for i := range tt.want {
+				if !strings.HasPrefix(got[i], tt.want[i]) {
+					t.Errorf("violation %d: expected prefix %q, got %q", i, tt.want[i], got[i])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,200 @@
+// Package secretref resolves secret references in configuration values, so credentials
+// can live in the environment or in mounted files (Kubernetes or Docker secrets) instead
+// of the policy file. A reference is written ${env:NAME} or ${file:/path}; it may be the
+// whole value or part of one, e.g. "Bearer ${file:/run/secrets/token}".
+package secretref
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Masked stands in for a secret in a configuration shown by Mask.
+const Masked = "[secret]"
+
+const (
+	minMaskLen         = 4
+	maxSecretFileBytes = 64 * 1024
+	maxRefsPerValue    = 64
+	maxNodeDepth       = 256
+	maxNodes           = 1 << 20 // per document
+)
+
+// Has reports whether s contains a reference.
+func Has(s string) bool {
+	return strings.Contains(s, "${env:") || strings.Contains(s, "${file:")
+}
+
+// Expand returns s with every reference replaced by the secret it names. A file's
+// trailing newline is dropped. An unset variable or unreadable file is an error, so a
+// missing secret fails loading rather than leaving a credential empty. Text like ${x}
+// that is not a reference is kept as is.
+func Expand(s string) (string, error) {
+	return expand(s, nil)
+}
+
+// expand is Expand, appending each secret resolved to secrets when it is not nil.
+func expand(s string, secrets *[]string) (string, error) {
+	if !Has(s) {
+		return s, nil
+	}
+	var out strings.Builder
+	rest := s
+	for i := 0; i < maxRefsPerValue; i++ {
+		start := strings.Index(rest, "${")
+		if start < 0 {
+			out.WriteString(rest)
+			return out.String(), nil
+		}
+		end := strings.IndexByte(rest[start:], '}')
+		if end < 0 {
+			out.WriteString(rest)
+			return out.String(), nil
+		}
+		out.WriteString(rest[:start])
+		ref := rest[start+2 : start+end]
+		value, ok, err := resolve(ref)
+		if err != nil {
+			return "", err
+		}
+		if !ok {
+			value = rest[start : start+end+1]
+		} else if secrets != nil && value != "" {
+			*secrets = append(*secrets, value)
+		}
+		out.WriteString(value)
+		rest = rest[start+end+1:]
+	}
+	return "", fmt.Errorf("more than %d references in one value", maxRefsPerValue)
+}
+
+// resolve returns the secret named by ref, the text between ${ and }, and false when
+// ref is not a reference.
+func resolve(ref string) (string, bool, error) {
+	kind, name, ok := strings.Cut(ref, ":")
+	if !ok || name == "" {
+		return "", false, nil
+	}
+	switch kind {
+	case "env":
+		value, set := os.LookupEnv(name)
+		if !set {
+			return "", true, fmt.Errorf("secret reference ${env:%s}: variable is not set", name)
+		}
+		return value, true, nil
+	case "file":
+		value, err := readSecretFile(name)
+		if err != nil {
+			return "", true, fmt.Errorf("secret reference ${file:%s}: %w", name, err)
+		}
+		return value, true, nil
+	}
+	return "", false, nil
+}
+
+func readSecretFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	data, err := io.ReadAll(io.LimitReader(f, maxSecretFileBytes+1))
+	if err != nil {
+		return "", err
+	}
+	if len(data) > maxSecretFileBytes {
+		return "", fmt.Errorf("larger than %d bytes", maxSecretFileBytes)
+	}
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
+
+// Getenv returns the variable name with any references in its value expanded, so a
+// variable such as LOGRYPH_ADMIN_TOKEN can point at a mounted secret with
+// ${file:/run/secrets/admin-token}.
+func Getenv(name string) (string, error) {
+	return Expand(os.Getenv(name))
+}
+
+// ExpandYAML expands references in every scalar value of a parsed YAML document and
+// returns the secrets they resolved to, for Mask. Mapping keys are left alone. A plain
+// (unquoted) scalar is re-typed after expansion, so a reference can fill a number or
+// boolean field.
+func ExpandYAML(node *yaml.Node) ([]string, error) {
+	nodes, err := scalars(node, true)
+	if err != nil {
+		return nil, err
+	}
+	var secrets []string
+	for i := 0; i < len(nodes); i++ {
+		if !Has(nodes[i].Value) {
+			continue
+		}
+		value, err := expand(nodes[i].Value, &secrets)
+		if err != nil {
+			return secrets, err
+		}
+		nodes[i].Value = value
+		if nodes[i].Style == 0 {
+			nodes[i].Tag = ""
+		}
+	}
+	return secrets, nil
+}
+
+// Mask replaces, in the string values of a YAML document, every secret that ExpandYAML
+// resolved, so a loaded configuration can be shown without the credentials in it.
+func Mask(node *yaml.Node, secrets []string) {
+	nodes, _ := scalars(node, false)
+	for i := 0; i < len(nodes); i++ {
+		if nodes[i].ShortTag() != "!!str" {
+			continue
+		}
+		for _, secret := range secrets {
+			if secret == nodes[i].Value || (len(secret) >= minMaskLen && strings.Contains(nodes[i].Value, secret)) {
+				nodes[i].Value = strings.ReplaceAll(nodes[i].Value, secret, Masked)
+			}
+		}
+	}
+}
+
+// scalars lists the scalar nodes under root in document order, skipping mapping keys
+// when valuesOnly is set. A document nested too deeply or too large is an error; the
+// nodes listed up to that point are still returned.
+func scalars(root *yaml.Node, valuesOnly bool) ([]*yaml.Node, error) {
+	type yamlFrame struct {
+		node  *yaml.Node
+		depth int
+	}
+	var out []*yaml.Node
+	// Children are pushed last to first, so scalars come out in document order.
+	stack := []yamlFrame{{root, 0}}
+	for n := 0; n < maxNodes && len(stack) > 0; n++ {
+		f := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if f.node == nil {
+			continue
+		}
+		if f.depth > maxNodeDepth {
+			return out, errors.New("YAML nested too deeply")
+		}
+		if f.node.Kind == yaml.ScalarNode {
+			out = append(out, f.node)
+			continue
+		}
+		for i := len(f.node.Content) - 1; i >= 0; i-- {
+			if valuesOnly && f.node.Kind == yaml.MappingNode && i%2 == 0 {
+				continue
+			}
+			stack = append(stack, yamlFrame{f.node.Content[i], f.depth + 1})
+		}
+	}
+	if len(stack) > 0 {
+		return out, fmt.Errorf("YAML has more than %d nodes", maxNodes)
+	}
+	return out, nil
+}
@@ -0,0 +1,86 @@
+package secretref
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestExpand(t *testing.T) {
+	t.Setenv("LOGRYPH_TEST_SECRET", "s3cret")
+	path := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(path, []byte("from-file\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	for in, want := range map[string]string{
+		"${env:LOGRYPH_TEST_SECRET}":                      "s3cret",
+		"Bearer ${file:" + path + "}":                     "Bearer from-file",
+		"${env:LOGRYPH_TEST_SECRET}:${file:" + path + "}": "s3cret:from-file",
+		"plain ${HOME} and ${other:x}":                    "plain ${HOME} and ${other:x}",
+	} {
+		got, err := Expand(in)
+		if err != nil || got != want {
+			t.Errorf("Expand(%q) = %q, %v; want %q", in, got, err, want)
+		}
+	}
+	for _, in := range []string{"${env:LOGRYPH_TEST_UNSET}", "${file:" + path + ".missing}"} {
+		if _, err := Expand(in); err == nil {
+			t.Errorf("Expand(%q) resolved a missing secret", in)
+		}
+	}
+}
+
+func TestExpandYAMLRetypesPlainScalars(t *testing.T) {
+	t.Setenv("LOGRYPH_TEST_DAYS", "30")
+	var doc yaml.Node
+	src := "days: ${env:LOGRYPH_TEST_DAYS}\nquoted: \"${env:LOGRYPH_TEST_DAYS}\"\n${env:LOGRYPH_TEST_DAYS}: key\n"
+	if err := yaml.Unmarshal([]byte(src), &doc); err != nil {
+		t.Fatal(err)
+	}
+	secrets, err := ExpandYAML(&doc)
+	if err != nil {
+		t.Fatalf("ExpandYAML: %v", err)
+	}
+	if len(secrets) != 2 {
+		t.Errorf("secrets = %q, want the two values resolved", secrets)
+	}
+	var out struct {
+		Days   int               `yaml:"days"`
+		Quoted string            `yaml:"quoted"`
+		Rest   map[string]string `yaml:",inline"`
+	}
+	if err := doc.Decode(&out); err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	if out.Days != 30 || out.Quoted != "30" {
+		t.Errorf("decoded %+v", out)
+	}
+	for k := range out.Rest {
+		if !strings.HasPrefix(k, "${env:") {
+			t.Errorf("mapping key %q was expanded", k)
+		}
+	}
+}
+
+func TestMaskHidesResolvedSecrets(t *testing.T) {
+	t.Setenv("LOGRYPH_TEST_TOKEN", "tok-123")
+	var doc yaml.Node
+	if err := yaml.Unmarshal([]byte("auth: \"Bearer ${env:LOGRYPH_TEST_TOKEN}\"\nname: ops\n"), &doc); err != nil {
+		t.Fatal(err)
+	}
+	secrets, err := ExpandYAML(&doc)
+	if err != nil {
+		t.Fatalf("ExpandYAML: %v", err)
+	}
+	Mask(&doc, secrets)
+	out, err := yaml.Marshal(&doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(out), "tok-123") || !strings.Contains(string(out), "Bearer "+Masked) || !strings.Contains(string(out), "name: ops") {
+		t.Errorf("masked document:\n%s", out)
+	}
+}
@@ -0,0 +1,116 @@
+package server
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/slyt3/Logryph/internal/ledger"
+	"github.com/slyt3/Logryph/internal/models"
+	"github.com/slyt3/Logryph/internal/observer"
+	"github.com/slyt3/Logryph/internal/pool"
+	"github.com/slyt3/Logryph/internal/provenance"
+)
+
+// EventTypeServiceStarted is recorded once the proxy and admin listeners accept
+// connections.
+const EventTypeServiceStarted = "service_started"
+
+// readiness is the startup summary. It is printed to stdout as one JSON line once the
+// proxy is ready, and recorded as a service_started event, so orchestration tooling can
+// parse startup state instead of scraping logs. Logs go to stderr.
+type readiness struct {
+	Status          string    `json:"status"` // always "ready"
+	Time            time.Time `json:"time"`
+	EventID         string    `json:"event_id"` // the service_started event
+	Version         string    `json:"version"`
+	Commit          string    `json:"commit,omitempty"`
+	ProxyAddr       string    `json:"proxy_addr"`
+	AdminAddr       string    `json:"admin_addr"`
+	AdminSocketAuth bool      `json:"admin_socket_auth,omitempty"`
+	Target          string    `json:"target"`
+	Transparent     string    `json:"transparent,omitempty"`
+	PolicyVersion   string    `json:"policy_version,omitempty"`
+	PolicySHA256    string    `json:"policy_sha256,omitempty"`
+	PolicyLastGood  bool      `json:"policy_last_good,omitempty"` // running on the cached policy
+	EnforcementMode string    `json:"enforcement_mode"`
+	StallTimeout    string    `json:"stall_timeout,omitempty"` // in enforce mode
+	Environment     string    `json:"environment,omitempty"`
+	Backpressure    string    `json:"backpressure"`
+	ReadOnly        bool      `json:"read_only,omitempty"`
+	RunID           string    `json:"run_id,omitempty"`
+	HeadSeq         uint64    `json:"head_seq"` // chain head committed before service_started
+	HeadHash        string    `json:"head_hash,omitempty"`
+	StatsD          string    `json:"statsd,omitempty"`
+}
+
+// newReadiness fills the summary from the running components. The head is the last
+// event committed when the proxy became ready; events still queued follow it.
+func newReadiness(db ledger.EventRepository, worker *ledger.Worker, obs *observer.ObserverEngine, b provenance.Build, p provenance.Policy) *readiness {
+	r := &readiness{
+		Status:          "ready",
+		Time:            time.Now().UTC(),
+		EventID:         uuid.New().String()[:8],
+		Version:         b.Version,
+		Commit:          b.Commit,
+		PolicyVersion:   p.Version,
+		PolicySHA256:    p.SHA256,
+		PolicyLastGood:  p.LastGood,
+		EnforcementMode: observer.EnforcementObserve,
+		Environment:     obs.GetEnvironment(),
+		Backpressure:    "drop",
+		ReadOnly:        worker.ReadOnly(),
+	}
+	if obs.IsEnforcing() {
+		r.EnforcementMode = observer.EnforcementEnforce
+		r.StallTimeout = obs.GetStallTimeout().String()
+	}
+	if worker.BackpressureMode() == ledger.BackpressureBlock {
+		r.Backpressure = "block"
+	}
+	runID, err := db.GetRunID()
+	if err != nil || runID == "" {
+		log.Printf("[WARN] Reading the chain head for the readiness summary failed: %v", err)
+		return r
+	}
+	r.RunID = runID
+	if r.HeadSeq, r.HeadHash, err = db.GetLastEvent(runID); err != nil {
+		log.Printf("[WARN] Reading the chain head for the readiness summary failed: %v", err)
+	}
+	return r
+}
+
+// event records the summary in the ledger.
+func (r *readiness) event() *models.Event {
+	event := pool.GetEvent()
+	event.ID = r.EventID
+	event.Timestamp = r.Time
+	event.Actor = "system"
+	event.EventType = EventTypeServiceStarted
+	event.Method = "logryph:start"
+	event.Params["version"] = r.Version
+	event.Params["proxy_addr"] = r.ProxyAddr
+	event.Params["admin_addr"] = r.AdminAddr
+	event.Params["target"] = r.Target
+	event.Params["policy_version"] = r.PolicyVersion
+	event.Params["policy_sha256"] = r.PolicySHA256
+	event.Params["enforcement_mode"] = r.EnforcementMode
+	event.Params["backpressure"] = r.Backpressure
+	event.Params["read_only"] = r.ReadOnly
+	event.Params["head_seq"] = r.HeadSeq
+	event.Params["head_hash"] = r.HeadHash
+	if r.Transparent != "" {
+		event.Params["transparent"] = r.Transparent
+	}
+	return event
+}
+
+// announce records the service_started event and writes the summary to out.
+func (r *readiness) announce(worker *ledger.Worker, out io.Writer) {
+	worker.Submit(r.event())
+	if err := json.NewEncoder(out).Encode(r); err != nil {
+		log.Printf("[WARN] Writing the readiness summary failed: %v", err)
+	}
+}
@@ -0,0 +1,1073 @@
+// Package server runs the Logryph proxy: the intercepting reverse proxy, the admin API and
+// the background ledger components, or a replication archive with --archive-listen.
+package server
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/slyt3/Logryph/internal/api"
+	"github.com/slyt3/Logryph/internal/approval"
+	"github.com/slyt3/Logryph/internal/assert"
+	"github.com/slyt3/Logryph/internal/attachments"
+	"github.com/slyt3/Logryph/internal/backup"
+	"github.com/slyt3/Logryph/internal/canary"
+	"github.com/slyt3/Logryph/internal/capacity"
+	"github.com/slyt3/Logryph/internal/core"
+	"github.com/slyt3/Logryph/internal/cors"
+	"github.com/slyt3/Logryph/internal/digest"
+	"github.com/slyt3/Logryph/internal/headpub"
+	"github.com/slyt3/Logryph/internal/integrity"
+	"github.com/slyt3/Logryph/internal/interceptor"
+	"github.com/slyt3/Logryph/internal/ledger"
+	"github.com/slyt3/Logryph/internal/ledger/memstore"
+	"github.com/slyt3/Logryph/internal/ledger/store"
+	"github.com/slyt3/Logryph/internal/logging"
+	"github.com/slyt3/Logryph/internal/models"
+	"github.com/slyt3/Logryph/internal/notary"
+	"github.com/slyt3/Logryph/internal/notify"
+	"github.com/slyt3/Logryph/internal/observer"
+	"github.com/slyt3/Logryph/internal/pool"
+	"github.com/slyt3/Logryph/internal/provenance"
+	"github.com/slyt3/Logryph/internal/replication"
+	"github.com/slyt3/Logryph/internal/reports"
+	"github.com/slyt3/Logryph/internal/slo"
+	"github.com/slyt3/Logryph/internal/sockaddr"
+	"github.com/slyt3/Logryph/internal/transparent"
+	"github.com/slyt3/Logryph/internal/worm"
+)
+
+const (
+	defaultAdminAddr = ":9998"
+	shutdownTimeout  = 10 * time.Second
+)
+
+// config is the proxy's command line.
+type config struct {
+	configPath, policyCache string
+	target                  string
+	listenPort              int
+	listenAddr, adminListen string
+	adminSocketAuth         bool
+	sidecar                 bool
+	transparentMode         string
+	backpressure            string
+	headless                bool
+
+	prometheus     bool
+	statsdAddr     string
+	statsdFlavor   string
+	statsdInterval time.Duration
+	statsdTags     string
+	guard          api.GuardConfig
+
+	ledgerMode, ledgerFlush string
+	backupDir               string
+	backupInterval          time.Duration
+	backupKeep              int
+	canaryPath, canaryURL   string
+	canaryInterval          time.Duration
+	attachmentDir           string
+
+	mirrorURL, mirrorCert, mirrorKey, mirrorCA string
+	mirrorInterval                             time.Duration
+	archiveListen, archiveDB                   string
+	archiveCert, archiveKey, archiveClientCA   string
+
+	// Resolved by check from the listen flags.
+	proxyAddr, adminAddr string
+}
+
+// parseFlags reads the proxy flags from args, exiting on a malformed command line.
+func parseFlags(args []string) *config {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	cfg := &config{}
+	cfg.proxyFlags(fs)
+	cfg.adminFlags(fs)
+	cfg.ledgerFlags(fs)
+	cfg.replicationFlags(fs)
+	_ = fs.Parse(args)
+	return cfg
+}
+
+func (c *config) proxyFlags(fs *flag.FlagSet) {
+	fs.StringVar(&c.configPath, "config", "logryph-policy.yaml", "path to policy configuration")
+	fs.StringVar(&c.policyCache, "policy-cache", observer.DefaultLastGoodPath, "last-known-good copy of the policy, used when --config is missing or invalid at startup")
+	fs.StringVar(&c.target, "target", "http://localhost:8080", "target tool server URL")
+	fs.IntVar(&c.listenPort, "port", 9999, "port to listen on")
+	fs.StringVar(&c.listenAddr, "listen", "", "proxy listen address, host:port or unix:/path (overrides --port)")
+	fs.BoolVar(&c.sidecar, "sidecar", false, "sidecar mode: proxy and admin API accept local connections only")
+	fs.StringVar(&c.transparentMode, "transparent", "", "forward steered connections to their original destination: 'redirect' or 'tproxy' (Linux; see logyctl redirect)")
+	fs.StringVar(&c.backpressure, "backpressure", "drop", "backpressure strategy: 'drop' (fail-open) or 'block' (fail-closed)")
+	fs.BoolVar(&c.headless, "headless", false, "never read stdin: stalled calls are decided only through the admin API and logyctl")
+	fs.StringVar(&c.attachmentDir, "attachments", "attachments", "directory for payload blobs kept when capture.store_bodies is set")
+}
+
+func (c *config) adminFlags(fs *flag.FlagSet) {
+	def := api.DefaultGuardConfig()
+	fs.StringVar(&c.adminListen, "admin-listen", defaultAdminAddr, "admin API listen address, host:port or unix:/path")
+	fs.BoolVar(&c.adminSocketAuth, "admin-socket-auth", false, "with --admin-listen unix:/path, create the socket owner-only (0600) and trust its connections without tokens")
+	fs.BoolVar(&c.prometheus, "prometheus", true, "serve Prometheus metrics on the admin port at /metrics")
+	fs.StringVar(&c.statsdAddr, "statsd", "", "push metrics to a StatsD agent at host:port (disabled when empty)")
+	fs.StringVar(&c.statsdFlavor, "statsd-flavor", api.StatsdPlain, "statsd wire format: 'statsd' or 'dogstatsd'")
+	fs.DurationVar(&c.statsdInterval, "statsd-interval", 10*time.Second, "statsd flush interval")
+	fs.StringVar(&c.statsdTags, "statsd-tags", "", "comma-separated DogStatsD tags added to every metric, e.g. env:prod,team:ml")
+	fs.Float64Var(&c.guard.IPRate, "admin-rate", def.IPRate, "admin API requests per second allowed from one IP (0 disables)")
+	fs.Float64Var(&c.guard.TokenRate, "admin-token-rate", def.TokenRate, "admin API requests per second allowed per X-Admin-Token (0 disables)")
+	fs.IntVar(&c.guard.Burst, "admin-burst", def.Burst, "admin API requests allowed at once before the rates apply")
+	fs.IntVar(&c.guard.MaxFailures, "admin-max-failures", def.MaxFailures, "failed admin logins from one IP before it is locked out (0 disables)")
+	fs.DurationVar(&c.guard.Lockout, "admin-lockout", def.Lockout, "how long an IP stays locked out of the admin API")
+}
+
+func (c *config) ledgerFlags(fs *flag.FlagSet) {
+	fs.StringVar(&c.ledgerMode, "ledger", "sqlite", "ledger storage: 'sqlite' (logryph.db) or 'memory' (lost on exit unless --ledger-flush is set)")
+	fs.StringVar(&c.ledgerFlush, "ledger-flush", "", "with --ledger memory, write the ledger to this new SQLite file on exit")
+	fs.StringVar(&c.backupDir, "backup-dir", "", "write periodic ledger backups to this directory (disabled when empty; sqlite ledger only)")
+	fs.DurationVar(&c.backupInterval, "backup-interval", time.Hour, "time between ledger backups")
+	fs.IntVar(&c.backupKeep, "backup-keep", 24, "newest ledger backups kept in --backup-dir (0 keeps all)")
+	fs.StringVar(&c.canaryPath, "canary", "", "append signed chain heads to this file and check the ledger against it at startup (disabled when empty; sqlite ledger only)")
+	fs.StringVar(&c.canaryURL, "canary-url", "", "also POST each canary entry to this http(s) endpoint")
+	fs.DurationVar(&c.canaryInterval, "canary-interval", 10*time.Second, "how often the chain head is written to the canary")
+}
+
+func (c *config) replicationFlags(fs *flag.FlagSet) {
+	fs.StringVar(&c.mirrorURL, "mirror", "", "replicate committed events to the Logryph archive at this https URL (disabled when empty)")
+	fs.StringVar(&c.mirrorCert, "mirror-cert", "", "client certificate presented to the archive")
+	fs.StringVar(&c.mirrorKey, "mirror-key", "", "private key for --mirror-cert")
+	fs.StringVar(&c.mirrorCA, "mirror-ca", "", "CA bundle that signed the archive's certificate (system roots when empty)")
+	fs.DurationVar(&c.mirrorInterval, "mirror-interval", 2*time.Second, "how often to send newly committed events")
+	fs.StringVar(&c.archiveListen, "archive-listen", "", "run as a replication archive on this address instead of as a proxy")
+	fs.StringVar(&c.archiveDB, "archive-db", "logryph-archive.db", "ledger database the archive writes mirrored events to")
+	fs.StringVar(&c.archiveCert, "archive-cert", "", "archive TLS certificate")
+	fs.StringVar(&c.archiveKey, "archive-key", "", "private key for --archive-cert")
+	fs.StringVar(&c.archiveClientCA, "archive-client-ca", "", "CA bundle that mirror client certificates must chain to")
+}
+
+// check validates the proxy flags and resolves the listen addresses.
+func (c *config) check() {
+	if err := assert.Check(c.target != "", "target must not be empty"); err != nil {
+		log.Fatalf("Invalid target: %v", err)
+	}
+	if err := assert.Check(c.listenPort > 0, "listen port must be positive"); err != nil {
+		log.Fatalf("Invalid listen port: %v", err)
+	}
+	c.proxyAddr, c.adminAddr = listenAddrs(c.listenAddr, c.listenPort, c.adminListen, c.sidecar)
+	if _, ok := sockaddr.UnixPath(c.adminAddr); c.adminSocketAuth && !ok {
+		log.Fatalf("--admin-socket-auth requires --admin-listen unix:/path, got %s", c.adminAddr)
+	}
+}
+
+// services are the running proxy's components. Each optional one is nil when disabled.
+type services struct {
+	obs      *observer.ObserverEngine
+	fallback *observer.Fallback
+	db       ledgerStore
+	worker   *ledger.Worker
+	engine   *core.Engine
+	build    provenance.Build
+	policy   provenance.Policy
+
+	mirror        *replication.Mirror
+	monitor       *canary.Monitor
+	backups       *backup.Scheduler
+	committer     *worm.Committer
+	countersigner *notary.Countersigner
+	reporter      *reports.Scheduler
+	digests       *digest.Scheduler
+	prompt        *approval.Prompt
+	offline       *approval.Watcher
+	poller        *approval.Poller
+	statsd        *api.StatsdEmitter
+
+	adminServer, proxyServer *http.Server
+}
+
+// Run parses the proxy flags from args and serves until SIGINT or SIGTERM.
+func Run(args []string) {
+	cfg := parseFlags(args)
+	if cfg.archiveListen != "" {
+		runArchive(cfg.archiveListen, cfg.archiveDB, cfg.archiveCert, cfg.archiveKey, cfg.archiveClientCA)
+		return
+	}
+	cfg.check()
+	s := &services{}
+
+	// 1. Load Observer Rules
+	s.startPolicy(cfg)
+	// 2. Initialize Ledger Store & Worker
+	s.startLedger(cfg)
+	s.startReplication(cfg)
+	// 3. Initialize Core Engine
+	s.startEngine(cfg)
+	s.startApprovals(cfg)
+	// 4-6. Initialize the interceptor, the API handlers and the proxy
+	apiHandlers := s.startServers(cfg)
+	s.startStatsd(cfg, apiHandlers)
+
+	// 7. Announce readiness
+	ready := newReadiness(s.db, s.worker, s.obs, s.build, s.policy)
+	ready.ProxyAddr, ready.AdminAddr, ready.AdminSocketAuth = cfg.proxyAddr, cfg.adminAddr, cfg.adminSocketAuth
+	ready.Target, ready.Transparent, ready.StatsD = cfg.target, cfg.transparentMode, cfg.statsdAddr
+	ready.announce(s.worker, os.Stdout)
+
+	shutdownSignal := waitForShutdownSignal(syscall.SIGINT, syscall.SIGTERM)
+	log.Printf("Shutdown signal received: %v", shutdownSignal)
+	s.shutdown()
+}
+
+// startPolicy loads the policy, falling back to the cached copy, and starts watching it.
+func (s *services) startPolicy(cfg *config) {
+	var err error
+	s.obs, s.fallback, err = observer.NewObserverEngineWithFallback(cfg.configPath, cfg.policyCache)
+	if err != nil {
+		log.Fatalf("Failed to load observer rules: %v", err)
+	}
+	if fb := s.fallback; fb != nil {
+		log.Printf("[WARN] Policy %s could not be loaded (%s); running on the last-known-good policy cached %s (version %q, sha256 %s)",
+			cfg.configPath, fb.Err, fb.SavedAt.Format(time.RFC3339), fb.Version, fb.SHA256)
+	}
+	s.obs.Watch()
+	if err := logging.ConfigureSinks(s.obs.GetConfig().Logging.Sinks); err != nil {
+		log.Fatalf("Failed to configure log sinks: %v", err)
+	}
+}
+
+// startLedger opens the ledger, runs the startup checks against it and starts the worker.
+func (s *services) startLedger(cfg *config) {
+	s.db = openLedger(cfg.ledgerMode, cfg.ledgerFlush)
+	var tamperAlarm *models.Event
+	if cfg.canaryPath != "" {
+		tamperAlarm = checkCanary(s.db, cfg.canaryPath)
+	}
+	integrityCfg := s.obs.GetConfig().Integrity
+	var integrityResult *integrity.Result
+	if integrityCfg.Enabled() {
+		integrityResult = checkIntegrity(integrityCfg, s.db)
+	}
+	s.worker = newWorker(cfg.backpressure, s.db, s.obs)
+	s.build, s.policy = recordProvenance(s.worker, s.obs, cfg.configPath, s.fallback)
+	if integrityResult != nil && !integrityResult.OK() {
+		applyIntegrityFailure(integrityCfg.OnStartup, s.worker, integrityResult)
+	}
+	if s.fallback != nil {
+		recordPolicyFallback(s.worker, s.fallback)
+	}
+	if cfg.canaryPath != "" {
+		s.monitor = startCanary(s.db, s.worker, cfg.canaryPath, cfg.canaryURL, cfg.canaryInterval, tamperAlarm)
+	}
+}
+
+// newWorker configures and starts the ledger worker.
+func newWorker(backpressure string, db ledgerStore, obs *observer.ObserverEngine) *ledger.Worker {
+	worker, err := ledger.NewWorker(1000, db, ".logryph_key")
+	if err != nil {
+		log.Fatalf("Worker init failed: %v", err)
+	}
+	switch backpressure {
+	case "block":
+		if err := worker.SetBackpressureMode(ledger.BackpressureBlock); err != nil {
+			log.Fatalf("Failed to set backpressure mode: %v", err)
+		}
+	case "drop":
+		if err := worker.SetBackpressureMode(ledger.BackpressureDrop); err != nil {
+			log.Fatalf("Failed to set backpressure mode: %v", err)
+		}
+	default:
+		log.Fatalf("Invalid backpressure mode '%s': must be 'drop' or 'block'", backpressure)
+	}
+	if err := worker.SetEnvironment(obs.GetEnvironment()); err != nil {
+		log.Fatalf("Failed to set environment: %v", err)
+	}
+	if err := worker.SetRotation(obs.GetConfig().Rotation); err != nil {
+		log.Fatalf("Failed to configure run rotation: %v", err)
+	}
+	if err := worker.SetGenesis(obs.GetConfig().Genesis); err != nil {
+		log.Fatalf("Failed to configure genesis metadata: %v", err)
+	}
+	if err := worker.Start(); err != nil {
+		log.Fatalf("Worker start failed: %v", err)
+	}
+	return worker
+}
+
+// startReplication starts the components that copy the ledger elsewhere: the mirror,
+// backups, WORM commits and notary countersignatures.
+func (s *services) startReplication(cfg *config) {
+	if cfg.mirrorURL != "" {
+		s.mirror = startMirror(s.db, cfg.mirrorURL, cfg.mirrorCert, cfg.mirrorKey, cfg.mirrorCA, cfg.mirrorInterval)
+	}
+	if cfg.backupDir != "" {
+		s.backups = startBackups(s.db, cfg.backupDir, cfg.backupInterval, cfg.backupKeep)
+	}
+	if wc := s.obs.GetConfig().WORM; wc.Type != "" {
+		s.committer = startWORM(wc, s.db, s.worker)
+	}
+	if nc := s.obs.GetConfig().Notary; len(nc.Endpoints) > 0 {
+		s.countersigner = startNotary(nc, s.db, s.worker)
+	}
+}
+
+// startEngine builds the core engine and the schedulers that report through it.
+func (s *services) startEngine(cfg *config) {
+	policy := s.obs.GetConfig()
+	engine := core.NewEngine(s.worker, s.obs)
+	var err error
+	if engine.Attachments, err = attachments.NewStore(cfg.attachmentDir); err != nil {
+		log.Fatalf("Attachment store init failed: %v", err)
+	}
+	if engine.Notifier, err = notify.NewDispatcher(policy.Notifications.Channels); err != nil {
+		log.Fatalf("Notification channels init failed: %v", err)
+	}
+	if engine.SLO, err = slo.NewTracker(policy.SLOs, s.worker.Submit); err != nil {
+		log.Fatalf("SLO tracker init failed: %v", err)
+	}
+	if sqlDB, ok := s.db.(*store.DB); ok {
+		engine.Capacity, err = capacity.NewMonitor("logryph.db", sqlDB, capacity.Options{HorizonDays: policy.Defaults.RetentionDays})
+		if err != nil {
+			log.Fatalf("Capacity monitor init failed: %v", err)
+		}
+	}
+	if policy.HeadPublication.Enabled() {
+		engine.Heads = startHeadPublisher(policy.HeadPublication, s.db, s.worker)
+	}
+	if len(policy.Reports) > 0 {
+		s.reporter = startReports(policy.Reports, s.db, s.worker, engine.Notifier)
+	}
+	if len(policy.Digest.Notify) > 0 {
+		s.digests = startDigest(policy.Digest, s.db, s.worker, engine.Notifier)
+	}
+	s.engine = engine
+}
+
+// startApprovals starts the ways a stalled call can be decided besides the admin API.
+func (s *services) startApprovals(cfg *config) {
+	if !cfg.headless && approval.IsTerminal(os.Stdin) {
+		s.prompt = startApprovalPrompt(s.engine.Approvals)
+	}
+	if oc := s.obs.GetConfig().OfflineApprovals; oc.Dir != "" {
+		s.offline = startOfflineApprovals(s.engine.Approvals, oc)
+	}
+	if pc := s.obs.GetConfig().ApprovalPoll; pc.URL != "" {
+		s.poller = startApprovalPoller(s.engine.Approvals, pc)
+	}
+}
+
+// startServers wires the interceptor into the reverse proxy and starts the proxy and
+// admin listeners.
+func (s *services) startServers(cfg *config) *api.Handlers {
+	interceptorSvc := interceptor.NewInterceptor(s.engine)
+	apiHandlers := api.NewHandlers(s.engine)
+
+	targetURL, upstream := upstreamTarget(cfg.target)
+	reverseProxy := httputil.NewSingleHostReverseProxy(targetURL)
+	reverseProxy.ModifyResponse = interceptorSvc.InterceptResponse
+	reverseProxy.Transport = interceptorSvc.Transport(upstream)
+	reverseProxy.ErrorHandler = interceptorSvc.ProxyError
+
+	wrappedProxy := buildProxyHandler(interceptorSvc, reverseProxy)
+	if s.worker.ReadOnly() {
+		wrappedProxy = readOnlyHandler(s.worker.UnhealthyReason())
+	}
+	corsCfg := s.obs.GetConfig().CORS
+	s.adminServer = newAdminServer(cfg.adminAddr, apiHandlers, cfg.prometheus, s.obs.GetLimits(observer.ListenerAdmin), corsCfg.Admin, cfg.guard)
+	s.proxyServer = newProxyServer(cfg.proxyAddr, cors.Handler(corsCfg.Proxy, proxyCORS, wrappedProxy), s.obs.GetLimits(observer.ListenerProxy))
+
+	if cfg.adminSocketAuth {
+		startSocketAuthServer(s.adminServer, "Admin API")
+	} else {
+		startHTTPServer(s.adminServer, "Admin API")
+	}
+	if cfg.transparentMode != "" {
+		startTransparentProxy(s.proxyServer, reverseProxy, cfg.transparentMode)
+	} else {
+		startHTTPServer(s.proxyServer, "Proxy Server")
+	}
+	return apiHandlers
+}
+
+// startStatsd pushes the admin API's metrics to a StatsD agent when one is configured.
+func (s *services) startStatsd(cfg *config, apiHandlers *api.Handlers) {
+	if cfg.statsdAddr == "" {
+		return
+	}
+	var err error
+	s.statsd, err = apiHandlers.StartStatsd(api.StatsdConfig{
+		Addr:     cfg.statsdAddr,
+		Flavor:   cfg.statsdFlavor,
+		Interval: cfg.statsdInterval,
+		Tags:     splitTags(cfg.statsdTags),
+	})
+	if err != nil {
+		log.Fatalf("StatsD emitter failed: %v", err)
+	}
+}
+
+// shutdown stops the components in order: those that record to the ledger before the
+// worker drains, the ones that read what it committed after.
+func (s *services) shutdown() {
+	if s.prompt != nil {
+		s.prompt.Stop()
+	}
+	if s.offline != nil {
+		s.offline.Stop()
+	}
+	if s.poller != nil {
+		s.poller.Stop()
+	}
+	if s.committer != nil {
+		s.committer.Stop() // before the worker, which records each committed segment
+	}
+	if s.countersigner != nil {
+		s.countersigner.Stop() // before the worker, which records each countersignature
+	}
+	if s.reporter != nil {
+		s.reporter.Stop() // before the worker, which records each report run
+	}
+	if s.digests != nil {
+		s.digests.Stop() // before the worker closes the database
+	}
+	if s.backups != nil {
+		s.backups.Stop() // before the worker closes the database
+	}
+	if s.monitor != nil {
+		if err := s.monitor.Stop(); err != nil {
+			log.Printf("[WARN] final canary write failed: %v", err)
+		}
+	}
+	gracefulShutdown(s.obs, s.worker, s.adminServer, s.proxyServer, shutdownTimeout)
+	s.statsd.Stop() // after the worker drains, so the final flush has the final counts
+	if s.mirror != nil {
+		if err := s.mirror.Stop(); err != nil {
+			log.Printf("[WARN] final mirror sync failed: %v", err)
+		}
+	}
+	if err := s.engine.Notifier.Close(); err != nil {
+		log.Printf("Notification flush failed: %v", err)
+	}
+	logging.CloseSinks()
+}
+
+// ledgerStore is what the proxy needs from a ledger backend.
+type ledgerStore interface {
+	ledger.EventRepository
+	GetEventsFrom(runID string, fromSeq uint64, limit int) ([]models.Event, error)
+	GetEventsByType(eventType string) ([]models.Event, error)
+}
+
+// openLedger returns the SQLite ledger, or an in-memory one that is optionally copied to
+// a new SQLite file when the worker closes it.
+func openLedger(mode, flushPath string) ledgerStore {
+	switch mode {
+	case "sqlite":
+		if flushPath != "" {
+			log.Fatalf("--ledger-flush requires --ledger memory")
+		}
+		db, err := store.NewDB("logryph.db")
+		if err != nil {
+			log.Fatalf("Database init failed: %v", err)
+		}
+		return db
+	case "memory":
+		mem := memstore.New(0)
+		if flushPath != "" {
+			if _, err := os.Stat(flushPath); err == nil {
+				log.Fatalf("Ledger flush target %s already exists", flushPath)
+			}
+			mem.SetFlush(func(s *memstore.Store) error { return flushLedger(s, flushPath) })
+		}
+		log.Printf("Ledger: in memory (flush on exit: %q)", flushPath)
+		return mem
+	default:
+		log.Fatalf("Invalid ledger '%s': must be 'sqlite' or 'memory'", mode)
+	}
+	return nil
+}
+
+// flushLedger copies the in-memory ledger into a new SQLite database at path.
+func flushLedger(mem *memstore.Store, path string) error {
+	db, err := store.NewDB(path)
+	if err != nil {
+		return fmt.Errorf("opening flush target: %w", err)
+	}
+	if err := mem.CopyTo(db); err != nil {
+		_ = db.Close()
+		return fmt.Errorf("flushing ledger to %s: %w", path, err)
+	}
+	log.Printf("Ledger flushed to %s", path)
+	return db.Close()
+}
+
+// checkCanary compares the ledger with the last canary entry and returns the alarm event
+// to record when the ledger has regressed or been rewritten since.
+func checkCanary(db ledgerStore, path string) *models.Event {
+	if _, ok := db.(*store.DB); !ok {
+		log.Fatalf("--canary requires --ledger sqlite")
+	}
+	last, err := canary.Check(path, db)
+	if err == nil {
+		if last != nil {
+			log.Printf("Canary: ledger holds run %s seq %d recorded in %s", last.RunID, last.Seq, path)
+		}
+		return nil
+	}
+	if !errors.Is(err, canary.ErrTampered) {
+		log.Fatalf("Canary check failed: %v", err)
+	}
+	logging.Error("ledger_tamper_alarm", logging.Fields{Component: "canary", RunID: last.RunID, Error: err.Error()})
+	log.Printf("[ALARM] %v", err)
+	return &models.Event{
+		ID:        uuid.New().String(),
+		Timestamp: time.Now(),
+		EventType: canary.EventTypeTamperAlarm,
+		Method:    "canary:check",
+		Params: map[string]interface{}{
+			"canary":       path,
+			"canary_run":   last.RunID,
+			"canary_seq":   last.Seq,
+			"canary_hash":  last.Hash,
+			"canary_time":  last.Timestamp,
+			"alarm_detail": err.Error(),
+		},
+	}
+}
+
+// checkIntegrity verifies the chain since the last checkpoint. A failure exits in refuse
+// mode and is returned otherwise; a pass moves the checkpoint forward.
+func checkIntegrity(cfg integrity.Config, db ledgerStore) *integrity.Result {
+	res, err := integrity.Check(db, cfg.CheckpointPath())
+	if err != nil {
+		res = &integrity.Result{Failure: err.Error()}
+	}
+	if res.OK() {
+		if res.Head != nil {
+			log.Printf("Integrity: run %s verified from seq %d to %d (%d events checked)", res.RunID, res.FromSeq, res.Head.Seq, res.Verified)
+			if err := integrity.SaveCheckpoint(cfg.CheckpointPath(), res.Head); err != nil {
+				log.Printf("[WARN] saving integrity checkpoint failed: %v", err)
+			}
+		}
+		return res
+	}
+	logging.Error("integrity_check_failed", logging.Fields{Component: "integrity", RunID: res.RunID, Error: res.Failure})
+	log.Printf("[ALARM] Ledger integrity check failed at seq %d: %s", res.FailedAtSeq, res.Failure)
+	if cfg.OnStartup == integrity.ModeRefuse {
+		log.Fatalf("Refusing to start on a ledger that failed its integrity check (integrity.on_startup: refuse)")
+	}
+	return res
+}
+
+// applyIntegrityFailure records a failed startup check in warn mode, or stops the ledger
+// accepting events in read_only mode.
+func applyIntegrityFailure(mode string, worker *ledger.Worker, res *integrity.Result) {
+	switch mode {
+	case integrity.ModeWarn:
+		event := pool.GetEvent()
+		event.ID = uuid.New().String()[:8]
+		event.Timestamp = time.Now()
+		event.Actor = "system"
+		event.EventType = integrity.EventTypeWarning
+		event.Method = "logryph:integrity"
+		event.Params["failure"] = res.Failure
+		event.Params["failed_at_seq"] = res.FailedAtSeq
+		event.Params["from_seq"] = res.FromSeq
+		worker.Submit(event)
+		log.Printf("[WARN] Starting anyway (integrity.on_startup: warn); recorded an %s event", integrity.EventTypeWarning)
+	case integrity.ModeReadOnly:
+		worker.SetReadOnly(fmt.Sprintf("ledger integrity check failed at seq %d: %s", res.FailedAtSeq, res.Failure))
+		log.Printf("[WARN] Starting read-only (integrity.on_startup: read_only): no events are recorded and tool calls are refused")
+	}
+}
+
+// recordPolicyFallback ledgers a start on the last-known-good policy, so the chain shows
+// which calls were judged by a policy other than the file on disk.
+func recordPolicyFallback(worker *ledger.Worker, fb *observer.Fallback) {
+	event := pool.GetEvent()
+	event.ID = uuid.New().String()[:8]
+	event.Timestamp = time.Now()
+	event.Actor = "system"
+	event.EventType = observer.EventTypePolicyFallback
+	event.Method = "logryph:policy"
+	event.RiskLevel = "high"
+	for k, v := range fb.Params() {
+		event.Params[k] = v
+	}
+	worker.Submit(event)
+}
+
+// maxPolicyFileBytes bounds the policy file hashed into the provenance event.
+const maxPolicyFileBytes = 64 << 20
+
+// recordProvenance ledgers which build of Logryph started, on which policy, ahead of the
+// calls it records, so evidence taken from the ledger names the software that guarded it.
+// It returns both for the readiness summary.
+func recordProvenance(worker *ledger.Worker, obs *observer.ObserverEngine, configPath string, fb *observer.Fallback) (provenance.Build, provenance.Policy) {
+	b := provenance.Read()
+	p := provenance.Policy{Path: configPath, Version: obs.GetVersion()}
+	if fb != nil {
+		p.SHA256, p.LastGood = fb.SHA256, true
+	} else if sum, err := provenance.FileSHA256(configPath, maxPolicyFileBytes); err == nil {
+		p.SHA256 = sum
+	} else {
+		log.Printf("[WARN] Hashing policy %s for the provenance event failed: %v", configPath, err)
+	}
+	worker.Submit(provenance.Event(b, p))
+	return b, p
+}
+
+// readOnlyHandler refuses proxied calls while the ledger cannot record them.
+func readOnlyHandler(reason string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "Logryph ledger is read-only: "+reason, http.StatusServiceUnavailable)
+	})
+}
+
+// startCanary records any startup alarm in the chain, then appends the chain head to the
+// canary file every interval.
+func startCanary(db ledgerStore, worker *ledger.Worker, path, url string, interval time.Duration, alarm *models.Event) *canary.Monitor {
+	if alarm != nil {
+		worker.Submit(alarm)
+	}
+	monitor, err := canary.NewMonitor(db, worker.GetSigner(), canary.Config{Path: path, URL: url, Interval: interval})
+	if err != nil {
+		log.Fatalf("Canary init failed: %v", err)
+	}
+	monitor.Start()
+	log.Printf("Canary: writing chain heads to %s every %s", path, interval)
+	return monitor
+}
+
+// startHeadPublisher keeps the signed chain head published for external monitors.
+func startHeadPublisher(cfg headpub.Config, db ledgerStore, worker *ledger.Worker) *headpub.Publisher {
+	publisher, err := headpub.NewPublisher(db, worker.GetSigner(), cfg)
+	if err != nil {
+		log.Fatalf("Head publisher init failed: %v", err)
+	}
+	publisher.Start()
+	if cfg.WellKnown {
+		log.Printf("Head publication: serving the signed chain head at %s on the admin listener", headpub.WellKnownPath)
+	}
+	if cfg.DNS.Provider != "" {
+		log.Printf("Head publication: keeping DNS TXT record %s at the chain head", cfg.DNS.Record)
+	}
+	return publisher
+}
+
+// startBackups backs up the SQLite ledger every interval, keeping the newest keep copies.
+func startBackups(db ledgerStore, dir string, interval time.Duration, keep int) *backup.Scheduler {
+	src, ok := db.(*store.DB)
+	if !ok {
+		log.Fatalf("--backup-dir requires --ledger sqlite")
+	}
+	scheduler, err := backup.NewScheduler(src, backup.Config{Dir: dir, Interval: interval, Keep: keep})
+	if err != nil {
+		log.Fatalf("Backup scheduler init failed: %v", err)
+	}
+	scheduler.Start()
+	log.Printf("Backups: writing ledger backups to %s every %s (keeping %d)", dir, interval, keep)
+	return scheduler
+}
+
+// startReports runs the configured query reports. Reports naming a saved query need the
+// SQLite ledger, where saved queries are stored.
+func startReports(cfgs []reports.Config, db ledgerStore, worker *ledger.Worker, notifier *notify.Dispatcher) *reports.Scheduler {
+	var queries reports.Queries
+	if sqlite, ok := db.(*store.DB); ok {
+		queries = sqlite
+	}
+	scheduler, err := reports.NewScheduler(cfgs, db, queries, worker.Submit, notifier)
+	if err != nil {
+		log.Fatalf("Report scheduler init failed: %v", err)
+	}
+	scheduler.Start()
+	log.Printf("Reports: running %d scheduled queries", len(cfgs))
+	return scheduler
+}
+
+// startApprovalPrompt lets the operator at the proxy's terminal decide stalled calls.
+func startApprovalPrompt(reg *approval.Registry) *approval.Prompt {
+	prompt, err := approval.NewPrompt(reg, os.Stdin, os.Stdout)
+	if err != nil {
+		log.Fatalf("Approval prompt init failed: %v", err)
+	}
+	prompt.Start()
+	log.Printf("Approval prompt: type 'pending', 'approve <id>' or 'reject <id>' (use --headless to disable)")
+	return prompt
+}
+
+// startOfflineApprovals applies signed approval tokens dropped into cfg.Dir.
+func startOfflineApprovals(reg *approval.Registry, cfg approval.OfflineConfig) *approval.Watcher {
+	watcher, err := approval.NewWatcher(reg, cfg)
+	if err != nil {
+		log.Fatalf("Offline approvals init failed: %v", err)
+	}
+	watcher.Start()
+	log.Printf("Offline approvals: applying signed tokens dropped into %s", cfg.Dir)
+	return watcher
+}
+
+// startApprovalPoller asks a poll-only approval system for decisions on pending stalls.
+func startApprovalPoller(reg *approval.Registry, cfg approval.PollConfig) *approval.Poller {
+	poller, err := approval.NewPoller(reg, cfg)
+	if err != nil {
+		log.Fatalf("Approval poller init failed: %v", err)
+	}
+	poller.Start()
+	log.Printf("Approval poller: polling for decisions on pending stalls")
+	return poller
+}
+
+// startDigest sends a daily digest of the ledger to the configured channels.
+func startDigest(cfg digest.Config, db ledgerStore, worker *ledger.Worker, notifier *notify.Dispatcher) *digest.Scheduler {
+	dropped := func() uint64 {
+		_, n := worker.Stats()
+		return n
+	}
+	scheduler, err := digest.NewScheduler(cfg, db, worker.GetSigner().GetPublicKey(), dropped, notifier)
+	if err != nil {
+		log.Fatalf("Digest init failed: %v", err)
+	}
+	scheduler.Start()
+	at := cfg.At
+	if at == "" {
+		at = "00:00"
+	}
+	log.Printf("Digest: sending a daily digest at %s UTC to %s", at, strings.Join(cfg.Notify, ", "))
+	return scheduler
+}
+
+// startMirror begins replicating the ledger to an archive over mutual TLS.
+func startMirror(db replication.Source, archiveURL, certFile, keyFile, caFile string, interval time.Duration) *replication.Mirror {
+	tlsCfg, err := replication.ClientTLSConfig(certFile, keyFile, caFile)
+	if err != nil {
+		log.Fatalf("Mirror TLS config failed: %v", err)
+	}
+	mirror, err := replication.NewMirror(db, replication.Config{URL: archiveURL, TLS: tlsCfg, Interval: interval})
+	if err != nil {
+		log.Fatalf("Mirror init failed: %v", err)
+	}
+	mirror.Start()
+	log.Printf("Mirror: replicating ledger to %s every %s", archiveURL, interval)
+	return mirror
+}
+
+// startWORM begins committing signed chain segments to write-once storage.
+func startWORM(cfg worm.Config, db worm.Source, worker *ledger.Worker) *worm.Committer {
+	target, err := worm.NewTarget(cfg)
+	if err != nil {
+		log.Fatalf("WORM target init failed: %v", err)
+	}
+	committer, err := worm.NewCommitter(cfg, target, db, worker.GetSigner(), worker.Submit)
+	if err != nil {
+		log.Fatalf("WORM committer init failed: %v", err)
+	}
+	committer.Start()
+	log.Printf("WORM: committing chain segments to %s", target.Name())
+	return committer
+}
+
+// startNotary sends checkpoints to external notaries for countersigning.
+func startNotary(cfg notary.Config, db notary.Source, worker *ledger.Worker) *notary.Countersigner {
+	countersigner, err := notary.NewCountersigner(cfg, db, worker.GetSigner().GetPublicKey(), worker.Submit)
+	if err != nil {
+		log.Fatalf("Notary init failed: %v", err)
+	}
+	countersigner.Start()
+	names := make([]string, 0, len(cfg.Endpoints))
+	for i := 0; i < len(cfg.Endpoints); i++ {
+		names = append(names, cfg.Endpoints[i].Name)
+	}
+	log.Printf("Notary: countersigning checkpoints with %s", strings.Join(names, ", "))
+	return countersigner
+}
+
+// runArchive serves the replication endpoints until a shutdown signal. Mirrors must
+// present a client certificate issued by clientCAFile.
+func runArchive(addr, dbPath, certFile, keyFile, clientCAFile string) {
+	tlsCfg, err := replication.ServerTLSConfig(certFile, keyFile, clientCAFile)
+	if err != nil {
+		log.Fatalf("Archive TLS config failed: %v", err)
+	}
+	db, err := store.NewDB(dbPath)
+	if err != nil {
+		log.Fatalf("Archive database init failed: %v", err)
+	}
+	archive, err := replication.NewArchive(db)
+	if err != nil {
+		log.Fatalf("Archive init failed: %v", err)
+	}
+	server := &http.Server{Addr: addr, Handler: archive.Handler(), TLSConfig: tlsCfg}
+	go func() {
+		if err := server.ListenAndServeTLS("", ""); err != nil && err != http.ErrServerClosed {
+			log.Fatalf("Archive error: %v", err)
+		}
+	}()
+	log.Printf("Archive: accepting mirrored events on %s into %s", addr, dbPath)
+
+	shutdownSignal := waitForShutdownSignal(syscall.SIGINT, syscall.SIGTERM)
+	log.Printf("Shutdown signal received: %v", shutdownSignal)
+	shutdownHTTPServer(server, shutdownTimeout, "Archive")
+	if err := db.Close(); err != nil {
+		log.Printf("[WARN] archive database close failed: %v", err)
+	}
+}
+
+func splitTags(s string) []string {
+	var tags []string
+	for _, tag := range strings.Split(s, ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+func buildProxyHandler(interceptorSvc *interceptor.Interceptor, reverseProxy *httputil.ReverseProxy) http.Handler {
+	if err := assert.NotNil(interceptorSvc, "interceptor"); err != nil {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	}
+	if err := assert.NotNil(reverseProxy, "reverse proxy"); err != nil {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	}
+	return interceptorSvc.Handler(reverseProxy)
+}
+
+// proxyCORS lets browser agents send the correlation headers and read back the event and
+// request IDs.
+var proxyCORS = cors.Defaults{
+	Methods: []string{http.MethodPost, http.MethodGet, http.MethodOptions},
+	Headers: []string{"Content-Type", interceptor.TaskIDHeader, interceptor.ParentEventHeader, interceptor.RequestIDHeader, interceptor.TraceparentHeader, interceptor.EnvironmentHeader},
+	Exposed: []string{interceptor.EventIDHeader, interceptor.RequestIDHeader},
+}
+
+// adminCORS lets browser dashboards authenticate and approve calls.
+var adminCORS = cors.Defaults{
+	Methods: []string{http.MethodGet, http.MethodPost, http.MethodOptions},
+	Headers: []string{"Content-Type", "X-Admin-Token", api.ApproverHeader},
+}
+
+// newAdminServer serves the admin API. Every body is capped at limits.MaxBodyBytes on top
+// of each endpoint's own cap, and requests pass the guard's rate limits and lockouts.
+func newAdminServer(adminAddr string, apiHandlers *api.Handlers, prometheus bool, limits observer.ListenerLimits, corsPolicy cors.Policy, guard api.GuardConfig) *http.Server {
+	if err := assert.NotNil(apiHandlers, "api handlers"); err != nil {
+		return &http.Server{}
+	}
+	if err := assert.Check(adminAddr != "", "admin addr must not be empty"); err != nil {
+		return &http.Server{}
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/rekey", apiHandlers.HandleRekey)
+	mux.HandleFunc("/api/approvals", apiHandlers.HandlePendingApprovals)
+	mux.HandleFunc("/api/pending", apiHandlers.HandlePendingApprovals)
+	mux.HandleFunc("/api/approve", apiHandlers.HandleApprove)
+	mux.HandleFunc("/api/reject", apiHandlers.HandleReject)
+	mux.HandleFunc("/api/approvals/ticket", apiHandlers.HandleApprovalTicket)
+	mux.HandleFunc("/api/approve/batch", apiHandlers.HandleApproveBatch)
+	mux.HandleFunc("/api/reject/batch", apiHandlers.HandleRejectBatch)
+	mux.HandleFunc("/api/approve/task", apiHandlers.HandleApproveTask)
+	mux.HandleFunc("/api/reject/task", apiHandlers.HandleRejectTask)
+	mux.HandleFunc("/api/annotations", apiHandlers.HandleAnnotations)
+	mux.HandleFunc("/api/holds", apiHandlers.HandleHolds)
+	mux.HandleFunc("/api/events", apiHandlers.HandleEvents)
+	mux.HandleFunc("/api/seen", apiHandlers.HandleSeen)
+	mux.HandleFunc("/api/risk", apiHandlers.HandleRisk)
+	mux.HandleFunc("/api/receipt/", apiHandlers.HandleReceipt)
+	mux.HandleFunc("/api/metrics", apiHandlers.HandleStats)
+	mux.HandleFunc("/api/status", apiHandlers.HandleStatus)
+	mux.HandleFunc("/api/policies", apiHandlers.HandlePolicies)
+	if prometheus {
+		mux.HandleFunc("/metrics", apiHandlers.HandlePrometheus)
+	}
+	mux.Handle("/debug/", apiHandlers.DebugHandler())
+	mux.HandleFunc(headpub.WellKnownPath, apiHandlers.HandleHead)
+	mux.HandleFunc("/healthz", apiHandlers.HandleHealth)
+	mux.HandleFunc("/readyz", apiHandlers.HandleReady)
+
+	handler := cors.Handler(corsPolicy, adminCORS, apiHandlers.Guard(http.MaxBytesHandler(mux, limits.MaxBodyBytes), guard))
+	return &http.Server{Addr: adminAddr, Handler: handler, MaxHeaderBytes: limits.MaxHeaderBytes}
+}
+
+// newProxyServer serves the proxy. The interceptor enforces limits.MaxHeaderBytes itself so
+// that it can answer in JSON-RPC and ledger the rejection; the server only cuts off
+// headers past twice that, which it refuses with a bare 431.
+func newProxyServer(addr string, handler http.Handler, limits observer.ListenerLimits) *http.Server {
+	if err := assert.Check(addr != "", "addr must not be empty"); err != nil {
+		return &http.Server{}
+	}
+	if err := assert.NotNil(handler, "handler"); err != nil {
+		return &http.Server{}
+	}
+
+	return &http.Server{Addr: addr, Handler: handler, MaxHeaderBytes: 2 * limits.MaxHeaderBytes}
+}
+
+// listenAddrs resolves the proxy and admin listen addresses. In sidecar mode, defaults
+// move to 127.0.0.1 and anything reachable from outside the pod is refused.
+func listenAddrs(listen string, port int, admin string, sidecar bool) (proxyAddr, adminAddr string) {
+	proxyAddr, adminAddr = listen, admin
+	if proxyAddr == "" {
+		proxyAddr = fmt.Sprintf(":%d", port)
+		if sidecar {
+			proxyAddr = fmt.Sprintf("127.0.0.1:%d", port)
+		}
+	}
+	if sidecar && adminAddr == defaultAdminAddr {
+		adminAddr = "127.0.0.1" + defaultAdminAddr
+	}
+	if sidecar && (!sockaddr.IsLoopback(proxyAddr) || !sockaddr.IsLoopback(adminAddr)) {
+		log.Fatalf("--sidecar requires loopback or unix socket addresses (proxy %s, admin %s)", proxyAddr, adminAddr)
+	}
+	return proxyAddr, adminAddr
+}
+
+// upstreamTarget parses --target. A unix:/path target is reached over that socket; the
+// URL host is then only used for the Host header.
+func upstreamTarget(target string) (*url.URL, http.RoundTripper) {
+	base, ok := http.DefaultTransport.(*http.Transport)
+	if err := assert.Check(ok, "default transport must be *http.Transport"); err != nil {
+		log.Fatalf("Upstream transport: %v", err)
+	}
+	if path, ok := sockaddr.UnixPath(target); ok {
+		if path == "" {
+			log.Fatalf("Invalid target %q: unix socket path is empty", target)
+		}
+		return &url.URL{Scheme: "http", Host: "localhost"}, sockaddr.Transport(base, path)
+	}
+	targetURL, err := url.Parse(target)
+	if err != nil {
+		log.Fatalf("Invalid target URL: %v", err)
+	}
+	return targetURL, base
+}
+
+// startHTTPServer binds server.Addr before returning, so a bad address or a socket in
+// use fails startup instead of a background goroutine.
+func startHTTPServer(server *http.Server, label string) {
+	if err := assert.NotNil(server, "server"); err != nil {
+		return
+	}
+	if err := assert.Check(label != "", "label must not be empty"); err != nil {
+		return
+	}
+
+	ln, err := sockaddr.Listen(server.Addr)
+	if err != nil {
+		log.Fatalf("%s error: %v", label, err)
+	}
+	serveHTTP(server, ln, label)
+}
+
+// startSocketAuthServer serves on server.Addr, a Unix socket only its owner may connect
+// to, and trusts every connection to it in place of the admin tokens.
+func startSocketAuthServer(server *http.Server, label string) {
+	if err := assert.NotNil(server, "server"); err != nil {
+		return
+	}
+	ln, err := sockaddr.ListenMode(server.Addr, sockaddr.OwnerSocketMode)
+	if err != nil {
+		log.Fatalf("%s error: %v", label, err)
+	}
+	server.ConnContext = api.SocketAuthContext
+	serveHTTP(server, ln, label)
+}
+
+func serveHTTP(server *http.Server, ln net.Listener, label string) {
+	go func() {
+		if err := server.Serve(ln); err != nil && err != http.ErrServerClosed {
+			log.Fatalf("%s error: %v", label, err)
+		}
+	}()
+}
+
+// startTransparentProxy serves the proxy so that connections diverted by firewall rules
+// are forwarded to wherever the agent originally sent them.
+func startTransparentProxy(server *http.Server, reverseProxy *httputil.ReverseProxy, mode string) {
+	if err := transparent.ValidateMode(mode); err != nil {
+		log.Fatalf("Invalid --transparent: %v", err)
+	}
+	_, portStr, err := net.SplitHostPort(server.Addr)
+	if err != nil {
+		log.Fatalf("--transparent needs a TCP listen address, got %s", server.Addr)
+	}
+	port, _ := strconv.Atoi(portStr)
+	server.ConnContext = transparent.ConnContext(mode, port)
+	director := reverseProxy.Director
+	reverseProxy.Director = func(req *http.Request) {
+		director(req)
+		transparent.Rewrite(req)
+	}
+	ln, err := transparent.Listen(server.Addr, mode)
+	if err != nil {
+		log.Fatalf("Proxy Server error: %v", err)
+	}
+	serveHTTP(server, ln, "Proxy Server")
+}
+
+func shutdownHTTPServer(server *http.Server, timeout time.Duration, label string) {
+	if err := assert.NotNil(server, "server"); err != nil {
+		return
+	}
+	if err := assert.Check(timeout > 0, "timeout must be positive"); err != nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if err := server.Shutdown(ctx); err != nil {
+		log.Printf("[WARN] %s shutdown failed: %v", label, err)
+	}
+}
+
+func waitForShutdownSignal(signals ...os.Signal) os.Signal {
+	if err := assert.Check(len(signals) > 0, "signals must not be empty"); err != nil {
+		return nil
+	}
+	sigCh := make(chan os.Signal, 1)
+	if err := assert.NotNil(sigCh, "signal channel"); err != nil {
+		return nil
+	}
+
+	signal.Notify(sigCh, signals...)
+	return <-sigCh
+}
+
+func gracefulShutdown(obsEngine *observer.ObserverEngine, worker *ledger.Worker, adminServer *http.Server, proxyServer *http.Server, timeout time.Duration) {
+	if err := assert.NotNil(obsEngine, "observer engine"); err != nil {
+		return
+	}
+	if err := assert.NotNil(worker, "worker"); err != nil {
+		return
+	}
+	if err := assert.NotNil(adminServer, "admin server"); err != nil {
+		return
+	}
+	if err := assert.NotNil(proxyServer, "proxy server"); err != nil {
+		return
+	}
+	if err := assert.Check(timeout > 0, "timeout must be positive"); err != nil {
+		return
+	}
+
+	shutdownHTTPServer(proxyServer, timeout, "Proxy Server")
+	shutdownHTTPServer(adminServer, timeout, "Admin API")
+
+	if err := obsEngine.Stop(); err != nil {
+		log.Printf("[WARN] observer stop failed: %v", err)
+	}
+	if err := worker.Shutdown(timeout); err != nil {
+		log.Printf("[WARN] worker shutdown failed: %v", err)
+	}
+}
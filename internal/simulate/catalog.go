@@ -0,0 +1,75 @@
+package simulate
+
+import (
+	"fmt"
+	"math/rand"
+)
+
+// template builds one kind of call. Methods and params are chosen to hit the rules in
+// the sample logryph-policy.yaml: read-only lookups, SQL the classifier grades, cloud
+// calls, payments and outbound HTTP to allowed and denied hosts.
+type template struct {
+	method string
+	params func(r *rand.Rand) map[string]interface{}
+}
+
+type weighted struct {
+	tmpl   template
+	weight int
+}
+
+var (
+	searchTmpl = template{"google_search:query", func(r *rand.Rand) map[string]interface{} {
+		return map[string]interface{}{"q": pickString(r, searchTerms)}
+	}}
+	slackTmpl = template{"slack:search", func(r *rand.Rand) map[string]interface{} {
+		return map[string]interface{}{"query": pickString(r, searchTerms), "limit": 20}
+	}}
+	readTmpl = template{"fs:read", func(r *rand.Rand) map[string]interface{} {
+		return map[string]interface{}{"path": fmt.Sprintf("/srv/data/report-%03d.csv", r.Intn(1000))}
+	}}
+	selectTmpl = template{"db:query", func(r *rand.Rand) map[string]interface{} {
+		return map[string]interface{}{"sql": fmt.Sprintf("SELECT id, email FROM users WHERE id = %d", r.Intn(100000))}
+	}}
+	updateTmpl = template{"db:query", func(r *rand.Rand) map[string]interface{} {
+		return map[string]interface{}{"sql": fmt.Sprintf("UPDATE orders SET status = 'shipped' WHERE id = %d", r.Intn(100000))}
+	}}
+	dropTmpl = template{"db:query", func(r *rand.Rand) map[string]interface{} {
+		return map[string]interface{}{"sql": "DROP TABLE " + pickString(r, []string{"users", "orders", "audit_log"})}
+	}}
+	githubTmpl = template{"http:get", func(r *rand.Rand) map[string]interface{} {
+		return map[string]interface{}{"url": fmt.Sprintf("https://api.github.com/repos/acme/app/issues/%d", r.Intn(5000))}
+	}}
+	metadataTmpl = template{"http:post", func(*rand.Rand) map[string]interface{} {
+		return map[string]interface{}{"url": "http://169.254.169.254/latest/meta-data/iam/security-credentials/", "body": "{}"}
+	}}
+	awsTmpl = template{"aws:ec2:describe_instances", func(r *rand.Rand) map[string]interface{} {
+		return map[string]interface{}{"region": pickString(r, []string{"us-east-1", "eu-west-1", "ap-south-1"})}
+	}}
+	chargeTmpl = template{"stripe:charge", func(r *rand.Rand) map[string]interface{} {
+		return map[string]interface{}{"amount": 100 * (1 + r.Intn(200)), "currency": "usd", "customer": fmt.Sprintf("cus_%06d", r.Intn(1000000))}
+	}}
+)
+
+var searchTerms = []string{"quarterly revenue", "incident postmortem", "on-call schedule", "release notes", "customer churn"}
+
+// mixedWeights spreads calls across risk levels, mostly low risk as real agents are.
+var mixedWeights = []weighted{
+	{searchTmpl, 25}, {slackTmpl, 15}, {readTmpl, 15}, {selectTmpl, 15}, {githubTmpl, 10},
+	{updateTmpl, 6}, {awsTmpl, 6}, {chargeTmpl, 4}, {metadataTmpl, 2}, {dropTmpl, 2},
+}
+
+// burstyWeights is read-heavy, like an agent fanning out lookups.
+var burstyWeights = []weighted{
+	{searchTmpl, 40}, {slackTmpl, 20}, {readTmpl, 20}, {selectTmpl, 15}, {githubTmpl, 5},
+}
+
+// taskChain is the step sequence each multi-task task walks: research, read, query,
+// act, and for long tasks escalate into riskier calls.
+var taskChain = []template{
+	searchTmpl, readTmpl, selectTmpl, githubTmpl, updateTmpl, slackTmpl, awsTmpl, chargeTmpl,
+}
+
+func pickString(r *rand.Rand, options []string) string {
+	return options[r.Intn(len(options))]
+}
@@ -0,0 +1,204 @@
+// Package simulate generates synthetic MCP agent traffic for demos, capacity planning
+// and soak tests. A Generator turns a profile name and a seed into a reproducible
+// sequence of JSON-RPC calls and the gaps between them; Run sends that sequence to a
+// Logryph proxy and reports what came back. Upstream is a stand-in tool server that
+// answers each call the way the generator asked it to, so failure-heavy traffic needs
+// no real tools behind the proxy. Scenarios are scripted rogue-agent runs whose
+// expectations are checked against the ledger, as acceptance tests for a policy.
+package simulate
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"time"
+)
+
+// Profiles, selected by name.
+const (
+	ProfileBursty       = "bursty"        // short dense bursts separated by idle gaps
+	ProfileMixedRisk    = "mixed-risk"    // steady calls across every risk level
+	ProfileMultiTask    = "multi-task"    // interleaved multi-step tasks with task headers
+	ProfileFailureHeavy = "failure-heavy" // about half the calls error, 500 or run slow
+)
+
+// Outcome is how Upstream is asked to answer a call, carried in OutcomeHeader.
+type Outcome string
+
+const (
+	OutcomeOK        Outcome = "ok"
+	OutcomeError     Outcome = "error"     // JSON-RPC error response
+	OutcomeHTTP500   Outcome = "http_500"  // upstream 500
+	OutcomeSlow      Outcome = "slow"      // success after SlowDelay
+	OutcomeMalformed Outcome = "malformed" // body is truncated JSON; never reaches upstream
+	OutcomeInjection Outcome = "injection" // success whose result tells the agent to exfiltrate
+)
+
+// OutcomeHeader tells Upstream how to answer. The proxy forwards it untouched.
+const OutcomeHeader = "X-Logryph-Simulate-Outcome"
+
+const (
+	maxBurst       = 40
+	minBurst       = 10
+	minTasks       = 3
+	maxTasks       = 8
+	maxTaskSteps   = 12
+	maxGapInterval = 10 // inter-arrival gaps are capped at this many mean intervals
+)
+
+// Call is one generated request. Delay is the wait after the previous call was due.
+type Call struct {
+	Seq     int
+	Delay   time.Duration
+	TaskID  string
+	Method  string
+	Params  map[string]interface{}
+	Outcome Outcome
+}
+
+// Profiles lists the profile names.
+func Profiles() []string {
+	return []string{ProfileBursty, ProfileFailureHeavy, ProfileMixedRisk, ProfileMultiTask}
+}
+
+// Generator produces the call sequence for one profile. Two generators built with the
+// same profile, seed and rate produce identical sequences. Not safe for concurrent use.
+type Generator struct {
+	profile  string
+	seed     int64
+	interval time.Duration
+	rng      *rand.Rand
+	seq      int
+
+	burstLeft int
+	tasks     []task
+	taskSeq   int
+}
+
+// task is an in-flight multi-task chain.
+type task struct {
+	id   string
+	step int
+	len  int
+}
+
+// NewGenerator returns a generator averaging rate calls per second.
+func NewGenerator(profile string, seed int64, rate float64) (*Generator, error) {
+	if rate <= 0 || rate > 100000 {
+		return nil, fmt.Errorf("rate %v must be in (0, 100000] calls per second", rate)
+	}
+	known := false
+	for _, name := range Profiles() {
+		known = known || name == profile
+	}
+	if !known {
+		return nil, fmt.Errorf("unknown profile %q (want one of %s)", profile, strings.Join(Profiles(), ", "))
+	}
+	return &Generator{
+		profile:  profile,
+		seed:     seed,
+		interval: time.Duration(float64(time.Second) / rate),
+		rng:      rand.New(rand.NewSource(seed)),
+	}, nil
+}
+
+// Profile returns the generator's profile name.
+func (g *Generator) Profile() string { return g.profile }
+
+// Seed returns the seed the sequence was generated from.
+func (g *Generator) Seed() int64 { return g.seed }
+
+// Next returns the next call in the sequence.
+func (g *Generator) Next() Call {
+	g.seq++
+	c := Call{Seq: g.seq, Outcome: OutcomeOK}
+	switch g.profile {
+	case ProfileBursty:
+		c.Delay = g.burstDelay()
+		c.Method, c.Params = g.pick(burstyWeights)
+	case ProfileMultiTask:
+		c.Delay = g.poissonDelay()
+		c.TaskID, c.Method, c.Params = g.taskStep()
+	case ProfileFailureHeavy:
+		c.Delay = g.poissonDelay()
+		c.Method, c.Params = g.pick(mixedWeights)
+		c.Outcome = g.failure()
+	default:
+		c.Delay = g.poissonDelay()
+		c.Method, c.Params = g.pick(mixedWeights)
+	}
+	return c
+}
+
+// poissonDelay draws an exponential gap with the mean interval, capped so a single
+// unlucky draw cannot stall a short run.
+func (g *Generator) poissonDelay() time.Duration {
+	d := time.Duration(g.rng.ExpFloat64() * float64(g.interval))
+	if limit := maxGapInterval * g.interval; d > limit {
+		return limit
+	}
+	return d
+}
+
+// burstDelay sends bursts of 10-40 calls at ten times the rate, then idles for as long
+// as the burst would have taken at the mean rate, so the average rate is preserved.
+func (g *Generator) burstDelay() time.Duration {
+	if g.burstLeft > 0 {
+		g.burstLeft--
+		return g.interval / 10
+	}
+	size := minBurst + g.rng.Intn(maxBurst-minBurst+1)
+	g.burstLeft = size - 1
+	return time.Duration(size) * g.interval * 9 / 10
+}
+
+// failure picks a failure-heavy outcome: 50% ok, 20% error, 15% 500, 10% slow, 5% malformed.
+func (g *Generator) failure() Outcome {
+	switch n := g.rng.Intn(100); {
+	case n < 50:
+		return OutcomeOK
+	case n < 70:
+		return OutcomeError
+	case n < 85:
+		return OutcomeHTTP500
+	case n < 95:
+		return OutcomeSlow
+	default:
+		return OutcomeMalformed
+	}
+}
+
+// taskStep advances a random in-flight task by one step, starting new tasks as others
+// finish, so several task chains interleave on the wire.
+func (g *Generator) taskStep() (string, string, map[string]interface{}) {
+	for len(g.tasks) < minTasks+g.rng.Intn(maxTasks-minTasks+1) {
+		g.taskSeq++
+		g.tasks = append(g.tasks, task{id: fmt.Sprintf("sim-%d-task-%d", g.seed, g.taskSeq), len: 3 + g.rng.Intn(maxTaskSteps-2)})
+	}
+	i := g.rng.Intn(len(g.tasks))
+	t := &g.tasks[i]
+	tmpl := taskChain[t.step%len(taskChain)]
+	t.step++
+	id := t.id
+	if t.step >= t.len {
+		g.tasks = append(g.tasks[:i], g.tasks[i+1:]...)
+	}
+	return id, tmpl.method, tmpl.params(g.rng)
+}
+
+// pick draws a call template by weight.
+func (g *Generator) pick(weights []weighted) (string, map[string]interface{}) {
+	total := 0
+	for i := 0; i < len(weights); i++ {
+		total += weights[i].weight
+	}
+	n := g.rng.Intn(total)
+	for i := 0; i < len(weights); i++ {
+		if n < weights[i].weight {
+			return weights[i].tmpl.method, weights[i].tmpl.params(g.rng)
+		}
+		n -= weights[i].weight
+	}
+	last := weights[len(weights)-1].tmpl
+	return last.method, last.params(g.rng)
+}
@@ -0,0 +1,257 @@
+package simulate
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/slyt3/Logryph/internal/assert"
+	"github.com/slyt3/Logryph/internal/interceptor"
+)
+
+// SlowDelay is how long Upstream holds an OutcomeSlow call.
+const SlowDelay = 1500 * time.Millisecond
+
+const (
+	maxRunCalls      = 1 << 31
+	maxLatencySample = 1 << 16 // latencies kept for percentiles; later calls are reservoir-sampled
+	maxRespBytes     = 1 << 20
+	maxConcurrency   = 1024
+)
+
+// Options bound a run. At least one of Calls and Duration must be set; the run ends at
+// whichever comes first.
+type Options struct {
+	Target      string // proxy base URL
+	Client      *http.Client
+	Calls       int
+	Duration    time.Duration
+	Concurrency int // calls in flight at once
+}
+
+// Report summarises a run. Latencies are in milliseconds.
+type Report struct {
+	Profile    string         `json:"profile"`
+	Seed       int64          `json:"seed"`
+	Sent       int            `json:"sent"`
+	OK         int            `json:"ok"`
+	RPCErrors  int            `json:"rpc_errors"`       // 2xx with a JSON-RPC error: blocked, rejected or failed tools
+	HTTPErrors map[string]int `json:"http_errors"`      // non-2xx responses by status
+	Transport  int            `json:"transport_errors"` // no response: refused, reset or timed out
+	Late       int            `json:"late"`             // calls sent after their slot because every worker was busy
+	ElapsedS   float64        `json:"elapsed_s"`
+	RatePerS   float64        `json:"rate_per_s"`
+	P50MS      float64        `json:"p50_ms"`
+	P95MS      float64        `json:"p95_ms"`
+	P99MS      float64        `json:"p99_ms"`
+	MaxMS      float64        `json:"max_ms"`
+}
+
+// Run sends g's calls to opts.Target on schedule until the call or time budget is spent
+// or ctx is cancelled. Individual call failures are counted, not returned.
+func Run(ctx context.Context, g *Generator, opts Options) (*Report, error) {
+	if err := assert.NotNil(g, "generator"); err != nil {
+		return nil, err
+	}
+	if opts.Calls <= 0 && opts.Duration <= 0 {
+		return nil, errors.New("a call count or duration is required")
+	}
+	if opts.Concurrency <= 0 || opts.Concurrency > maxConcurrency {
+		return nil, fmt.Errorf("concurrency must be 1-%d", maxConcurrency)
+	}
+	if opts.Client == nil {
+		opts.Client = &http.Client{Timeout: 30 * time.Second}
+	}
+	if opts.Duration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.Duration)
+		defer cancel()
+	}
+
+	t := newTally(g)
+	slots := make(chan struct{}, opts.Concurrency)
+	var wg sync.WaitGroup
+	start := time.Now()
+	due := start
+	for i := 0; i < maxRunCalls && (opts.Calls <= 0 || i < opts.Calls); i++ {
+		call := g.Next()
+		due = due.Add(call.Delay)
+		if !sleepUntil(ctx, due) {
+			break
+		}
+		select {
+		case slots <- struct{}{}:
+		default:
+			t.late()
+			slots <- struct{}{}
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-slots }()
+			t.record(send(ctx, opts, fmt.Sprintf("sim-%d-%d", g.Seed(), call.Seq), call))
+		}()
+	}
+	wg.Wait()
+	return t.report(time.Since(start)), nil
+}
+
+func sleepUntil(ctx context.Context, due time.Time) bool {
+	timer := time.NewTimer(time.Until(due))
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+// result is what one call came back with.
+type result struct {
+	latency  time.Duration
+	status   int // 0 when no response arrived
+	rpcError bool
+}
+
+// send posts one call as JSON-RPC, tagged with its task and a request ID (derived from
+// the seed, or naming a scenario step) so runs can be found in the ledger.
+func send(ctx context.Context, opts Options, requestID string, c Call) result {
+	body, err := json.Marshal(map[string]interface{}{"jsonrpc": "2.0", "id": c.Seq, "method": c.Method, "params": c.Params})
+	if err != nil {
+		return result{}
+	}
+	if c.Outcome == OutcomeMalformed {
+		body = body[:len(body)/2]
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, opts.Target, bytes.NewReader(body))
+	if err != nil {
+		return result{}
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(interceptor.RequestIDHeader, requestID)
+	req.Header.Set(OutcomeHeader, string(c.Outcome))
+	if c.TaskID != "" {
+		req.Header.Set(interceptor.TaskIDHeader, c.TaskID)
+	}
+	began := time.Now()
+	resp, err := opts.Client.Do(req)
+	if err != nil {
+		return result{latency: time.Since(began)}
+	}
+	defer func() { _ = resp.Body.Close() }()
+	var out struct {
+		Error json.RawMessage `json:"error"`
+	}
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, maxRespBytes))
+	r := result{latency: time.Since(began), status: resp.StatusCode}
+	r.rpcError = json.Unmarshal(data, &out) == nil && len(out.Error) > 0 && string(out.Error) != "null"
+	return r
+}
+
+// tally accumulates results from concurrent calls.
+type tally struct {
+	mu        sync.Mutex
+	rep       Report
+	latencies []time.Duration
+	seen      int
+	rng       *rand.Rand // reservoir sampling only; does not affect the call sequence
+}
+
+func newTally(g *Generator) *tally {
+	return &tally{
+		rep: Report{Profile: g.Profile(), Seed: g.Seed(), HTTPErrors: map[string]int{}},
+		rng: rand.New(rand.NewSource(g.Seed())),
+	}
+}
+
+func (t *tally) late() {
+	t.mu.Lock()
+	t.rep.Late++
+	t.mu.Unlock()
+}
+
+func (t *tally) record(r result) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.rep.Sent++
+	switch {
+	case r.status == 0:
+		t.rep.Transport++
+	case r.status < 200 || r.status > 299:
+		t.rep.HTTPErrors[fmt.Sprint(r.status)]++
+	case r.rpcError:
+		t.rep.RPCErrors++
+	default:
+		t.rep.OK++
+	}
+	t.seen++
+	if len(t.latencies) < maxLatencySample {
+		t.latencies = append(t.latencies, r.latency)
+	} else if j := t.rng.Intn(t.seen); j < maxLatencySample {
+		t.latencies[j] = r.latency
+	}
+}
+
+func (t *tally) report(elapsed time.Duration) *Report {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	rep := t.rep
+	rep.ElapsedS = elapsed.Seconds()
+	if elapsed > 0 {
+		rep.RatePerS = float64(rep.Sent) / elapsed.Seconds()
+	}
+	sort.Slice(t.latencies, func(i, j int) bool { return t.latencies[i] < t.latencies[j] })
+	ms := func(q float64) float64 {
+		if len(t.latencies) == 0 {
+			return 0
+		}
+		return float64(t.latencies[int(q*float64(len(t.latencies)-1))]) / float64(time.Millisecond)
+	}
+	rep.P50MS, rep.P95MS, rep.P99MS, rep.MaxMS = ms(0.50), ms(0.95), ms(0.99), ms(1)
+	return &rep
+}
+
+// Upstream is a stand-in MCP tool server. It answers every JSON-RPC call according to
+// OutcomeHeader, defaulting to success.
+func Upstream() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			ID     json.RawMessage `json:"id"`
+			Method string          `json:"method"`
+		}
+		if err := json.NewDecoder(io.LimitReader(r.Body, maxRespBytes)).Decode(&req); err != nil {
+			http.Error(w, "invalid JSON-RPC request", http.StatusBadRequest)
+			return
+		}
+		resp := map[string]interface{}{"jsonrpc": "2.0", "id": req.ID}
+		switch Outcome(r.Header.Get(OutcomeHeader)) {
+		case OutcomeHTTP500:
+			http.Error(w, "simulated upstream failure", http.StatusInternalServerError)
+			return
+		case OutcomeError:
+			resp["error"] = map[string]interface{}{"code": -32000, "message": "simulated tool failure"}
+		case OutcomeInjection:
+			resp["result"] = map[string]interface{}{"content": []interface{}{map[string]string{"type": "text", "text": injectedResultMsg}}}
+		case OutcomeSlow:
+			select {
+			case <-time.After(SlowDelay):
+			case <-r.Context().Done():
+				return
+			}
+			resp["result"] = map[string]interface{}{"content": []interface{}{map[string]string{"type": "text", "text": req.Method + " ok (slow)"}}}
+		default:
+			resp["result"] = map[string]interface{}{"content": []interface{}{map[string]string{"type": "text", "text": req.Method + " ok"}}}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(resp)
+	})
+}
@@ -0,0 +1,131 @@
+package simulate
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/slyt3/Logryph/internal/core"
+	"github.com/slyt3/Logryph/internal/interceptor"
+	"github.com/slyt3/Logryph/internal/ledger"
+	"github.com/slyt3/Logryph/internal/ledger/store"
+	"github.com/slyt3/Logryph/internal/models"
+	"github.com/slyt3/Logryph/internal/observer"
+)
+
+func TestGeneratorIsReproducible(t *testing.T) {
+	for _, profile := range Profiles() {
+		a, err := NewGenerator(profile, 42, 50)
+		if err != nil {
+			t.Fatalf("%s: NewGenerator: %v", profile, err)
+		}
+		b, _ := NewGenerator(profile, 42, 50)
+		c, _ := NewGenerator(profile, 43, 50)
+		differs := false
+		for i := 0; i < 200; i++ {
+			x, y, z := a.Next(), b.Next(), c.Next()
+			if !reflect.DeepEqual(x, y) {
+				t.Fatalf("%s: call %d differs between runs with the same seed: %+v vs %+v", profile, i, x, y)
+			}
+			differs = differs || !reflect.DeepEqual(x, z)
+		}
+		if !differs {
+			t.Errorf("%s: seeds 42 and 43 produced the same sequence", profile)
+		}
+	}
+	if _, err := NewGenerator("steady", 1, 10); err == nil {
+		t.Error("an unknown profile must be refused")
+	}
+}
+
+func TestMultiTaskInterleavesTasks(t *testing.T) {
+	g, _ := NewGenerator(ProfileMultiTask, 7, 100)
+	tasks := map[string]int{}
+	for i := 0; i < 100; i++ {
+		c := g.Next()
+		if c.TaskID == "" {
+			t.Fatalf("call %d has no task", c.Seq)
+		}
+		tasks[c.TaskID]++
+	}
+	if len(tasks) < minTasks {
+		t.Errorf("100 calls touched only %d tasks", len(tasks))
+	}
+}
+
+func TestRunCountsOutcomes(t *testing.T) {
+	srv := httptest.NewServer(Upstream())
+	defer srv.Close()
+	g, _ := NewGenerator(ProfileFailureHeavy, 3, 2000)
+	want, _ := NewGenerator(ProfileFailureHeavy, 3, 2000)
+	counts := map[Outcome]int{}
+	for i := 0; i < 60; i++ {
+		counts[want.Next().Outcome]++
+	}
+
+	rep, err := Run(context.Background(), g, Options{Target: srv.URL, Calls: 60, Concurrency: 16})
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if rep.Sent != 60 || rep.OK != counts[OutcomeOK]+counts[OutcomeSlow] || rep.RPCErrors != counts[OutcomeError] {
+		t.Errorf("report %+v does not match planned outcomes %v", rep, counts)
+	}
+	if rep.HTTPErrors["500"] != counts[OutcomeHTTP500] || rep.HTTPErrors["400"] != counts[OutcomeMalformed] {
+		t.Errorf("http errors %v, planned %v", rep.HTTPErrors, counts)
+	}
+}
+
+// TestScenariosAgainstSamplePolicy runs every built-in scenario through a real interceptor
+// loaded with the sample logryph-policy.yaml, which they are written to pass.
+func TestScenariosAgainstSamplePolicy(t *testing.T) {
+	dir := t.TempDir()
+	db, err := store.NewDB(filepath.Join(dir, "logryph.db"))
+	if err != nil {
+		t.Fatalf("store: %v", err)
+	}
+	worker, err := ledger.NewWorker(64, db, filepath.Join(dir, "test.key"))
+	if err != nil {
+		t.Fatalf("worker: %v", err)
+	}
+	if err := worker.Start(); err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	t.Cleanup(func() { _ = worker.Shutdown(2 * time.Second) })
+	engine := &core.Engine{Worker: worker, ActiveTasks: &sync.Map{}, LastEventByTask: &sync.Map{}}
+	if engine.Observer, err = observer.NewObserverEngine("../../logryph-policy.yaml"); err != nil {
+		t.Fatalf("observer: %v", err)
+	}
+	proxy := httptest.NewServer(interceptor.NewInterceptor(engine).Handler(Upstream()))
+	defer proxy.Close()
+
+	opts := ScenarioOptions{Target: proxy.URL, Fetch: db.GetEventsByTaskID, Settle: 5 * time.Second, RunID: "test"}
+	for _, sc := range Scenarios() {
+		res, err := RunScenario(context.Background(), sc, opts)
+		if err != nil {
+			t.Fatalf("%s: %v", sc.Name, err)
+		}
+		for _, step := range res.Steps {
+			if !step.Pass {
+				t.Errorf("%s / %s: %v (tags %v, risk %q)", sc.Name, step.Name, step.Failures, step.Tags, step.Risk)
+			}
+		}
+	}
+
+	// The same scenario against a ledger that recorded nothing fails, and says why.
+	empty := opts
+	empty.Settle = time.Millisecond
+	empty.Fetch = func(string) ([]models.Event, error) { return nil, nil }
+	sc, _ := FindScenario("data-exfiltration")
+	res, err := RunScenario(context.Background(), sc, empty)
+	if err != nil || res.Pass || len(res.Steps[1].Failures) == 0 {
+		t.Errorf("an unrecorded run should fail: %+v, %v", res, err)
+	}
+	if _, err := SelectScenarios([]string{"loop-of-death", "nope"}); !errors.Is(err, ErrUnknownScenario) {
+		t.Errorf("unknown scenario name: %v", err)
+	}
+}
@@ -0,0 +1,112 @@
+// Package slo tracks per-method tool latency from correlated call/response pairs and
+// checks it against objectives declared in the policy, such as p99 < 2s for aws:*. A
+// method that starts missing its objective is recorded in the chain as an slo_violation
+// event; it is recorded again only after it has met the objective in between.
+package slo
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/slyt3/Logryph/internal/vql"
+)
+
+// EventTypeViolation records a method that started missing an objective.
+const EventTypeViolation = "slo_violation"
+
+const (
+	defaultWindow     = 5 * time.Minute
+	maxWindow         = 24 * time.Hour
+	defaultMinSamples = 20
+	maxObjectives     = 64
+	maxMethodLen      = 256
+)
+
+// Config is one entry of the slos section of the policy file.
+type Config struct {
+	Method     string `yaml:"method"`                // glob over tool methods, e.g. "aws:*" or "*"
+	Objective  string `yaml:"objective"`             // e.g. "p99 < 2s"
+	Window     string `yaml:"window,omitempty"`      // latencies considered; default 5m
+	MinSamples int    `yaml:"min_samples,omitempty"` // calls needed before judging; default 20
+}
+
+// objective is a parsed Config.
+type objective struct {
+	cfg        Config
+	match      *vql.Expr
+	quantile   float64
+	threshold  time.Duration
+	window     time.Duration
+	minSamples int
+}
+
+// ValidateConfig checks the slos section.
+func ValidateConfig(cfgs []Config) error {
+	_, err := parseConfigs(cfgs)
+	return err
+}
+
+func parseConfigs(cfgs []Config) ([]objective, error) {
+	if len(cfgs) > maxObjectives {
+		return nil, fmt.Errorf("%d objectives exceed max of %d", len(cfgs), maxObjectives)
+	}
+	objs := make([]objective, 0, len(cfgs))
+	for i := 0; i < len(cfgs); i++ {
+		o, err := parseConfig(cfgs[i])
+		if err != nil {
+			return nil, fmt.Errorf("slos[%d]: %w", i, err)
+		}
+		objs = append(objs, o)
+	}
+	return objs, nil
+}
+
+func parseConfig(c Config) (objective, error) {
+	o := objective{cfg: c, window: defaultWindow, minSamples: defaultMinSamples}
+	if c.Method == "" || len(c.Method) > maxMethodLen {
+		return o, fmt.Errorf("method must be 1-%d bytes", maxMethodLen)
+	}
+	var err error
+	if o.match, err = vql.Cached("method =~ " + strconv.Quote(c.Method)); err != nil {
+		return o, fmt.Errorf("invalid method %q: %w", c.Method, err)
+	}
+	if o.quantile, o.threshold, err = parseObjective(c.Objective); err != nil {
+		return o, err
+	}
+	if c.Window != "" {
+		o.window, err = time.ParseDuration(c.Window)
+		if err != nil || o.window <= 0 || o.window > maxWindow {
+			return o, fmt.Errorf("invalid window %q: must be a positive duration of at most %s", c.Window, maxWindow)
+		}
+	}
+	if c.MinSamples < 0 || c.MinSamples > maxSamples {
+		return o, fmt.Errorf("invalid min_samples %d: must be between 0 and %d", c.MinSamples, maxSamples)
+	}
+	if c.MinSamples > 0 {
+		o.minSamples = c.MinSamples
+	}
+	return o, nil
+}
+
+// parseObjective parses "pNN < duration", e.g. "p99 < 2s" or "p99.9 < 500ms".
+func parseObjective(s string) (float64, time.Duration, error) {
+	fields := strings.Fields(s)
+	if len(fields) != 3 || fields[1] != "<" || !strings.HasPrefix(fields[0], "p") {
+		return 0, 0, fmt.Errorf("invalid objective %q: want e.g. \"p99 < 2s\"", s)
+	}
+	pct, err := strconv.ParseFloat(fields[0][1:], 64)
+	if err != nil || pct <= 0 || pct >= 100 {
+		return 0, 0, fmt.Errorf("invalid percentile %q: must be between p0 and p100", fields[0])
+	}
+	threshold, err := time.ParseDuration(fields[2])
+	if err != nil || threshold <= 0 {
+		return 0, 0, fmt.Errorf("invalid latency %q: must be a positive duration", fields[2])
+	}
+	return pct / 100, threshold, nil
+}
+
+func (o *objective) matches(method string) bool {
+	return o.match.Eval(vql.MapEnv{"method": method})
+}
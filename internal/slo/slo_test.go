@@ -0,0 +1,87 @@
+package slo
+
+import (
+	"testing"
+	"time"
+
+	"github.com/slyt3/Logryph/internal/models"
+)
+
+func TestTrackerRecordsViolationOnce(t *testing.T) {
+	var submitted []*models.Event
+	tr, err := NewTracker([]Config{{Method: "aws:*", Objective: "p90 < 1s", MinSamples: 10}}, func(e *models.Event) {
+		submitted = append(submitted, e)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	observe := func(method string, latency time.Duration) {
+		now = now.Add(time.Second)
+		tr.Observe(Sample{Method: method, Latency: latency, At: now, CallID: "call-1"})
+	}
+	for i := 0; i < 10; i++ {
+		observe("aws:s3:GetObject", 100*time.Millisecond)
+		observe("stripe:charge", 5*time.Second) // no objective covers it
+	}
+	if len(submitted) != 0 {
+		t.Fatalf("healthy and uncovered methods should not violate: %+v", submitted)
+	}
+	for i := 0; i < 2; i++ {
+		observe("aws:s3:GetObject", 3*time.Second)
+	}
+	if len(submitted) != 1 {
+		t.Fatalf("expected one violation once p90 degrades, got %d", len(submitted))
+	}
+	v := submitted[0]
+	if v.EventType != EventTypeViolation || v.Method != "aws:s3:GetObject" || v.ParentID != "call-1" || v.Params["observed_ms"] != int64(3000) {
+		t.Errorf("unexpected violation event: %+v", v)
+	}
+	observe("aws:s3:GetObject", 3*time.Second)
+	if len(submitted) != 1 {
+		t.Error("a method still missing its objective should not be recorded again")
+	}
+
+	snap := tr.Snapshot(now)
+	if len(snap) != 2 || snap[0].Method != "aws:s3:GetObject" || snap[0].Violations != 1 || snap[1].P50 != 5*time.Second {
+		t.Errorf("unexpected snapshot: %+v", snap)
+	}
+	if tr.Snapshot(now.Add(MetricsWindow + time.Minute))[0].Count != 0 {
+		t.Error("latencies older than the metrics window should not count")
+	}
+}
+
+func TestQuantile(t *testing.T) {
+	var latencies []time.Duration
+	for i := 100; i >= 1; i-- {
+		latencies = append(latencies, time.Duration(i)*time.Millisecond)
+	}
+	if q := quantile(latencies, 0.99); q != 99*time.Millisecond {
+		t.Errorf("p99 = %s", q)
+	}
+	if q := quantile(latencies, 0.5); q != 50*time.Millisecond {
+		t.Errorf("p50 = %s", q)
+	}
+	if quantile(nil, 0.5) != 0 {
+		t.Error("an empty window has no latency")
+	}
+}
+
+func TestValidateConfig(t *testing.T) {
+	if err := ValidateConfig([]Config{{Method: "*", Objective: "p99.9 < 500ms", Window: "1h", MinSamples: 5}}); err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range []Config{
+		{Method: "", Objective: "p99 < 2s"},
+		{Method: "*", Objective: "p99 > 2s"},
+		{Method: "*", Objective: "p100 < 2s"},
+		{Method: "*", Objective: "99 < 2s"},
+		{Method: "*", Objective: "p99 < fast"},
+		{Method: "*", Objective: "p99 < 2s", Window: "0s"},
+		{Method: "*", Objective: "p99 < 2s", MinSamples: -1},
+	} {
+		if err := ValidateConfig([]Config{c}); err == nil {
+			t.Errorf("%+v should be rejected", c)
+		}
+	}
+}
@@ -0,0 +1,199 @@
+package slo
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/slyt3/Logryph/internal/logging"
+	"github.com/slyt3/Logryph/internal/models"
+	"github.com/slyt3/Logryph/internal/pool"
+)
+
+const (
+	maxSamples = 1024 // latencies kept per method; quantiles cover the newest of these
+	maxMethods = 512  // methods tracked; calls to further methods are not tracked
+	// MetricsWindow bounds the latencies behind Snapshot, and so the exported quantiles.
+	MetricsWindow = 5 * time.Minute
+)
+
+// Sample is the latency of one call, measured from forwarding it upstream to its response.
+type Sample struct {
+	Method      string
+	Latency     time.Duration
+	At          time.Time // when the response arrived
+	CallID      string    // the tool_call event
+	TaskID      string
+	Environment string
+}
+
+type point struct {
+	at      time.Time
+	latency time.Duration
+}
+
+// series is one method's ring of recent latencies.
+type series struct {
+	points     [maxSamples]point
+	next, n    int
+	missing    []bool // per objective: the method is currently missing it
+	violations uint64
+}
+
+// Tracker keeps per-method latency and checks it against the configured objectives.
+// A nil Tracker ignores observations.
+type Tracker struct {
+	objectives []objective
+	submit     func(*models.Event)
+
+	mu      sync.Mutex
+	methods map[string]*series
+}
+
+// NewTracker checks cfgs. submit appends slo_violation events to the chain, normally
+// Worker.Submit. Latency is tracked for every method, with or without objectives.
+func NewTracker(cfgs []Config, submit func(*models.Event)) (*Tracker, error) {
+	if submit == nil {
+		return nil, errors.New("slo tracker needs a submit function")
+	}
+	objs, err := parseConfigs(cfgs)
+	if err != nil {
+		return nil, err
+	}
+	return &Tracker{objectives: objs, submit: submit, methods: make(map[string]*series)}, nil
+}
+
+// Observe records s and checks the objectives covering its method.
+func (t *Tracker) Observe(s Sample) {
+	if t == nil || s.Method == "" || len(s.Method) > maxMethodLen {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	ser, ok := t.methods[s.Method]
+	if !ok {
+		if len(t.methods) >= maxMethods {
+			return
+		}
+		ser = &series{missing: make([]bool, len(t.objectives))}
+		t.methods[s.Method] = ser
+	}
+	ser.points[ser.next] = point{at: s.At, latency: s.Latency}
+	ser.next = (ser.next + 1) % maxSamples
+	if ser.n < maxSamples {
+		ser.n++
+	}
+	for i := 0; i < len(t.objectives); i++ {
+		t.check(&t.objectives[i], i, ser, s)
+	}
+}
+
+// check records a violation when the method starts missing o, and notes when it recovers.
+func (t *Tracker) check(o *objective, idx int, ser *series, s Sample) {
+	if !o.matches(s.Method) {
+		return
+	}
+	latencies := ser.since(s.At.Add(-o.window))
+	if len(latencies) < o.minSamples {
+		return
+	}
+	observed := quantile(latencies, o.quantile)
+	missing := observed >= o.threshold
+	was := ser.missing[idx]
+	ser.missing[idx] = missing
+	fields := logging.Fields{Component: "slo", Method: s.Method, EventID: s.CallID, TaskID: s.TaskID}
+	if !missing {
+		if was {
+			logging.Info("slo_recovered", fields)
+		}
+		return
+	}
+	if was {
+		return
+	}
+	ser.violations++
+	fields.Error = fmt.Sprintf("%s observed %s", o.cfg.Objective, observed)
+	logging.Warn("slo_violation", fields)
+
+	event := pool.GetEvent()
+	event.ID = uuid.New().String()[:8]
+	event.Timestamp = s.At
+	event.EventType = EventTypeViolation
+	event.Method = s.Method
+	event.Actor = "system"
+	event.ParentID = s.CallID
+	event.TaskID = s.TaskID
+	event.Environment = s.Environment
+	event.Params["objective"] = o.cfg.Objective
+	event.Params["pattern"] = o.cfg.Method
+	event.Params["observed_ms"] = observed.Milliseconds()
+	event.Params["threshold_ms"] = o.threshold.Milliseconds()
+	event.Params["samples"] = len(latencies)
+	event.Params["window"] = o.window.String()
+	t.submit(event)
+}
+
+// since returns the latencies recorded at or after from, in no particular order.
+func (ser *series) since(from time.Time) []time.Duration {
+	out := make([]time.Duration, 0, ser.n)
+	for i := 0; i < ser.n; i++ {
+		if p := ser.points[i]; !p.at.Before(from) {
+			out = append(out, p.latency)
+		}
+	}
+	return out
+}
+
+// quantile returns the nearest-rank q-quantile of latencies, sorting them in place.
+func quantile(latencies []time.Duration, q float64) time.Duration {
+	if len(latencies) == 0 {
+		return 0
+	}
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	rank := int(math.Ceil(q*float64(len(latencies)))) - 1
+	if rank < 0 {
+		rank = 0
+	}
+	if rank >= len(latencies) {
+		rank = len(latencies) - 1
+	}
+	return latencies[rank]
+}
+
+// MethodLatency summarises one method's latency over MetricsWindow.
+type MethodLatency struct {
+	Method     string
+	Count      int
+	P50        time.Duration
+	P90        time.Duration
+	P99        time.Duration
+	Violations uint64 // slo_violation events recorded for the method since startup
+}
+
+// Snapshot returns every tracked method's latency as of now, sorted by method. Methods
+// with no calls in the window are listed with a zero count so their counters stay visible.
+func (t *Tracker) Snapshot(now time.Time) []MethodLatency {
+	if t == nil {
+		return nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	out := make([]MethodLatency, 0, len(t.methods))
+	for method, ser := range t.methods {
+		latencies := ser.since(now.Add(-MetricsWindow))
+		out = append(out, MethodLatency{
+			Method:     method,
+			Count:      len(latencies),
+			P50:        quantile(latencies, 0.5),
+			P90:        quantile(latencies, 0.9),
+			P99:        quantile(latencies, 0.99),
+			Violations: ser.violations,
+		})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Method < out[j].Method })
+	return out
+}
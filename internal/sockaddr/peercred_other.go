@@ -0,0 +1,14 @@
+//go:build !(linux || darwin)
+
+package sockaddr
+
+import (
+	"errors"
+	"net"
+)
+
+// PeerUID returns the user ID of the process at the other end of c. Peer credentials
+// are only read on Linux and macOS.
+func PeerUID(*net.UnixConn) (int, error) {
+	return 0, errors.New("peer credentials are not supported on this platform")
+}
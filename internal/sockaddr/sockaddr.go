@@ -0,0 +1,136 @@
+// Package sockaddr handles the listen and dial addresses the proxy accepts: a TCP
+// host:port, or a Unix domain socket written "unix:/path" (or "unix:///path"). Sockets
+// let the proxy run as a sidecar with no network exposure at all.
+package sockaddr
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// SocketMode is the permission set on sockets the proxy listens on: owner and group,
+// so a sidecar sharing a volume and group with the agent can connect.
+const SocketMode = 0o660
+
+// OwnerSocketMode lets only the socket's owner connect, for sockets whose permissions
+// are the access control.
+const OwnerSocketMode = 0o600
+
+// UnixPath returns the socket path of a "unix:" address, and whether addr is one.
+func UnixPath(addr string) (string, bool) {
+	rest, ok := strings.CutPrefix(addr, "unix:")
+	if !ok {
+		return "", false
+	}
+	return strings.TrimPrefix(rest, "//"), true
+}
+
+// Listen opens addr. A stale socket file left by a previous run is replaced; any other
+// file at the path is an error.
+func Listen(addr string) (net.Listener, error) {
+	return ListenMode(addr, SocketMode)
+}
+
+// ListenMode is Listen with the permissions set on a Unix socket. The socket is bound
+// inside a new owner-only directory, given its permissions, and only then renamed to
+// path, so no one can connect while it still has the umask's permissions.
+func ListenMode(addr string, mode os.FileMode) (net.Listener, error) {
+	path, ok := UnixPath(addr)
+	if !ok {
+		return net.Listen("tcp", addr)
+	}
+	if path == "" {
+		return nil, fmt.Errorf("unix socket address %q has no path", addr)
+	}
+	if err := removeStaleSocket(path); err != nil {
+		return nil, err
+	}
+	dir, err := os.MkdirTemp(filepath.Dir(path), ".sock-")
+	if err != nil {
+		return nil, fmt.Errorf("creating socket directory: %w", err)
+	}
+	defer func() { _ = os.Remove(dir) }()
+	tmp := filepath.Join(dir, "s")
+	ln, err := net.Listen("unix", tmp)
+	if err != nil {
+		return nil, err
+	}
+	ul := ln.(*net.UnixListener)
+	ul.SetUnlinkOnClose(false) // it would unlink tmp, not path
+	if err := os.Chmod(tmp, mode); err != nil {
+		_ = ul.Close()
+		_ = os.Remove(tmp)
+		return nil, fmt.Errorf("setting socket permissions: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		_ = ul.Close()
+		_ = os.Remove(tmp)
+		return nil, fmt.Errorf("moving socket into place: %w", err)
+	}
+	return &unixListener{UnixListener: ul, path: path}, nil
+}
+
+// unixListener removes its socket file when closed.
+type unixListener struct {
+	*net.UnixListener
+	path string
+}
+
+func (l *unixListener) Close() error {
+	err := l.UnixListener.Close()
+	_ = os.Remove(l.path)
+	return err
+}
+
+func removeStaleSocket(path string) error {
+	info, err := os.Lstat(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("checking socket path: %w", err)
+	}
+	if info.Mode()&fs.ModeSocket == 0 {
+		return fmt.Errorf("%s exists and is not a socket", path)
+	}
+	if conn, err := net.Dial("unix", path); err == nil {
+		_ = conn.Close()
+		return fmt.Errorf("%s is in use by another process", path)
+	}
+	return os.Remove(path)
+}
+
+// Transport returns a copy of base that dials the socket at path for every request,
+// whatever the URL's host.
+func Transport(base *http.Transport, path string) *http.Transport {
+	t := base.Clone()
+	t.DialContext = func(ctx context.Context, _, _ string) (net.Conn, error) {
+		var d net.Dialer
+		return d.DialContext(ctx, "unix", path)
+	}
+	return t
+}
+
+// IsLoopback reports whether a TCP listen address only accepts local connections. An
+// empty host (":9998") listens on every interface.
+func IsLoopback(addr string) bool {
+	if _, ok := UnixPath(addr); ok {
+		return true
+	}
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
@@ -0,0 +1,116 @@
+package sockaddr
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestUnixListenAndDial(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "proxy.sock")
+	ln, err := Listen("unix://" + path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, "via socket "+r.URL.Path)
+	})}
+	go func() { _ = srv.Serve(ln) }()
+	defer srv.Close()
+
+	info, err := os.Stat(path)
+	if err != nil || info.Mode().Perm() != SocketMode {
+		t.Errorf("socket mode = %v, %v", info.Mode().Perm(), err)
+	}
+	client := &http.Client{Transport: Transport(http.DefaultTransport.(*http.Transport), path)}
+	resp, err := client.Get("http://tools.invalid/rpc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if string(body) != "via socket /rpc" {
+		t.Errorf("body = %q", body)
+	}
+
+	// A live socket must not be taken over by a second instance.
+	if _, err := Listen("unix:" + path); err == nil {
+		t.Error("listening on a socket in use should fail")
+	}
+}
+
+func TestListenReplacesOnlyStaleSockets(t *testing.T) {
+	dir := t.TempDir()
+	stale := filepath.Join(dir, "stale.sock")
+	ln, err := net.Listen("unix", stale)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ln.(*net.UnixListener).SetUnlinkOnClose(false)
+	_ = ln.Close()
+	if ln, err = Listen("unix:" + stale); err != nil {
+		t.Fatalf("stale socket not replaced: %v", err)
+	}
+	_ = ln.Close()
+
+	regular := filepath.Join(dir, "file")
+	if err := os.WriteFile(regular, []byte("keep"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Listen("unix:" + regular); err == nil {
+		t.Error("a regular file must not be replaced by a socket")
+	}
+}
+
+// The socket is created inside a private directory and moved into place, so the only
+// entry left beside it is the socket itself, and closing the listener removes it.
+func TestListenModeLeavesOnlyTheSocket(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "admin.sock")
+	ln, err := ListenMode("unix:"+path, OwnerSocketMode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil || len(entries) != 1 || entries[0].Name() != "admin.sock" {
+		t.Errorf("directory holds %v, %v; want only the socket", entries, err)
+	}
+	conn, err := net.Dial("unix", path)
+	if err != nil {
+		t.Fatalf("dialing the moved socket: %v", err)
+	}
+	server, err := ln.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	uid, err := PeerUID(server.(*net.UnixConn))
+	if err == nil && uid != os.Getuid() {
+		t.Errorf("peer uid = %d, want %d", uid, os.Getuid())
+	}
+	_ = conn.Close()
+	_ = server.Close()
+	_ = ln.Close()
+	if _, err := os.Lstat(path); !os.IsNotExist(err) {
+		t.Errorf("socket left behind after Close: %v", err)
+	}
+}
+
+func TestIsLoopback(t *testing.T) {
+	cases := map[string]bool{
+		"127.0.0.1:9998":   true,
+		"localhost:9999":   true,
+		"[::1]:9999":       true,
+		"unix:/run/l.sock": true,
+		":9998":            false,
+		"0.0.0.0:9999":     false,
+		"10.0.0.5:9999":    false,
+	}
+	for addr, want := range cases {
+		if got := IsLoopback(addr); got != want {
+			t.Errorf("IsLoopback(%q) = %v, want %v", addr, got, want)
+		}
+	}
+}
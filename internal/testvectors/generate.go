@@ -0,0 +1,503 @@
+package testvectors
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/slyt3/Logryph/internal/crypto"
+	"github.com/slyt3/Logryph/internal/ledger/audit"
+	"github.com/slyt3/Logryph/internal/models"
+)
+
+const (
+	genesisPrevHash = "0000000000000000000000000000000000000000000000000000000000000000"
+	agentName       = "testvectors"
+	largeStringLen  = 32 * 1024
+	largeObjects    = 256
+	nestingDepth    = 48
+)
+
+// epoch is the timestamp of every vector's genesis; later events follow a second apart.
+var epoch = time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// Generate builds the corpus. The keys come from fixed seeds and every ID and timestamp
+// is fixed, so the events, hashes and signatures are the same on every run.
+func Generate() ([]byte, error) {
+	vectors, err := buildVectors()
+	if err != nil {
+		return nil, err
+	}
+	out, err := json.MarshalIndent(Corpus{Format: Format, Vectors: vectors}, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(out, '\n'), nil
+}
+
+// seededSigner returns the signer for a name. The seeds are public, so these keys must
+// never sign anything but test vectors.
+func seededSigner(name string) (*crypto.Signer, error) {
+	seed := sha256.Sum256([]byte("logryph test vector key: " + name))
+	return crypto.NewSignerFromSeed(seed[:])
+}
+
+// chain appends signed events to one run.
+type chain struct {
+	signer *crypto.Signer
+	runID  string
+	events []models.Event
+}
+
+func newChain(signer *crypto.Signer, runID string) *chain {
+	return &chain{signer: signer, runID: runID}
+}
+
+// genesis starts the run. prev, when set, is the run_closed event of the run it replaces.
+func (c *chain) genesis(prev *models.Event) error {
+	params := map[string]interface{}{
+		"public_key": c.signer.GetPublicKey(),
+		"agent_name": agentName,
+		"version":    "1.0.0",
+	}
+	if prev != nil {
+		params[audit.ParamPrevRunID] = prev.RunID
+		params[audit.ParamPrevRunSeq] = prev.SeqIndex
+		params[audit.ParamPrevRunHead] = prev.CurrentHash
+	}
+	return c.add(models.Event{Actor: "system", EventType: "genesis", Method: "logryph:init", Params: params})
+}
+
+// add links, hashes and signs e as the run's next event. ID and Timestamp are filled in
+// when unset.
+func (c *chain) add(e models.Event) error {
+	e.RunID = c.runID
+	e.SeqIndex = uint64(len(c.events))
+	if e.ID == "" {
+		sum := sha256.Sum256([]byte(fmt.Sprintf("%s/%d", c.runID, e.SeqIndex)))
+		e.ID = hex.EncodeToString(sum[:4])
+	}
+	if e.Timestamp.IsZero() {
+		e.Timestamp = epoch.Add(time.Duration(e.SeqIndex) * time.Second)
+	}
+	if e.Params == nil {
+		e.Params = map[string]interface{}{}
+	}
+	e.PrevHash = genesisPrevHash
+	if len(c.events) > 0 {
+		e.PrevHash = c.events[len(c.events)-1].CurrentHash
+	}
+	hash, err := crypto.CalculateEventHash(e.PrevHash, e.HashPayload())
+	if err != nil {
+		return fmt.Errorf("%s seq %d: %w", c.runID, e.SeqIndex, err)
+	}
+	e.CurrentHash = hash
+	if e.Signature, err = c.signer.SignHash(hash); err != nil {
+		return err
+	}
+	c.events = append(c.events, e)
+	return nil
+}
+
+// call and response are a tool call and the response to it.
+func (c *chain) call(method string, params map[string]interface{}, response models.Event) error {
+	if err := c.add(models.Event{Actor: "agent", EventType: "tool_call", Method: method, Params: params}); err != nil {
+		return err
+	}
+	response.Actor, response.EventType, response.Method = "agent", "tool_response", method
+	response.ParentID = c.events[len(c.events)-1].ID
+	return c.add(response)
+}
+
+// closeRun ends the run the way rotation does.
+func (c *chain) closeRun(reason string) (*models.Event, error) {
+	last := c.events[len(c.events)-1]
+	err := c.add(models.Event{Actor: "system", EventType: audit.EventTypeRunClosed, Method: "logryph:rotate", Params: map[string]interface{}{
+		"reason":    reason,
+		"head_seq":  last.SeqIndex,
+		"head_hash": last.CurrentHash,
+	}})
+	if err != nil {
+		return nil, err
+	}
+	return &c.events[len(c.events)-1], nil
+}
+
+// runID returns a fixed, UUID-shaped run ID for a vector's nth run.
+func runID(name string, n int) string {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s/run/%d", name, n)))
+	h := hex.EncodeToString(sum[:16])
+	return h[0:8] + "-" + h[8:12] + "-" + h[12:16] + "-" + h[16:20] + "-" + h[20:32]
+}
+
+// builder is a function that fills a vector given its key and name.
+type builder func(v *Vector, signer *crypto.Signer) error
+
+func buildVectors() ([]Vector, error) {
+	specs := []struct {
+		name, description, expect string
+		build                     builder
+	}{
+		{"format-2026.1", "Events with only the fields of the 2026.1 format, whose hash covers them all even when empty.", ExpectValid, buildBaseline},
+		{"format-optional-fields", "Every field added after 2026.1, which is hashed only when set, including non-object results.", ExpectValid, buildOptionalFields},
+		{"timestamps", "Timestamps with nanoseconds, trailing zeros and a non-UTC offset, hashed as RFC 3339 text.", ExpectValid, buildTimestamps},
+		{"unicode", "Keys that sort differently by UTF-16 and UTF-8, NFC and NFD forms, controls and characters JSON encoders escape.", ExpectValid, buildUnicode},
+		{"numbers", "Numbers canonicalized as IEEE doubles: negative zero, exponents, the float64 limits and an integer past 2^53.", ExpectValid, buildNumbers},
+		{"large-payload", "A 32 KiB string, 256 objects and nesting 48 levels deep.", ExpectValid, buildLarge},
+		{"compressed-responses", "Responses that arrived gzip and deflate encoded, recorded decoded; the encoded bodies are included.", ExpectValid, buildCompressed},
+		{"rotation", "A run closed by rotation and the run whose genesis links back to its run_closed event.", ExpectValid, buildRotation},
+		{"acknowledged-gap", "A run missing seq 2, with the gap_acknowledged event that covers it.", ExpectValid, buildAcknowledgedGap},
+		{"tampered-response", "A response edited after it was signed.", ExpectHashMismatch, buildTamperedResponse},
+		{"forged-signature", "An event re-signed by a key other than the run's.", ExpectInvalidSignature, buildForgedSignature},
+		{"unacknowledged-gap", "A run missing seq 2 with nothing acknowledging it.", ExpectChainBroken, buildUnacknowledgedGap},
+		{"broken-rotation-link", "A genesis that names a run_closed event other than the one its previous run ends with.", ExpectRunLinkBroken, buildBrokenRotationLink},
+	}
+	vectors := make([]Vector, 0, len(specs))
+	for _, spec := range specs {
+		signer, err := seededSigner(spec.name)
+		if err != nil {
+			return nil, err
+		}
+		v := Vector{Name: spec.name, Description: spec.description, PubKey: signer.GetPublicKey(), Expect: spec.expect}
+		if err := spec.build(&v, signer); err != nil {
+			return nil, fmt.Errorf("vector %s: %w", spec.name, err)
+		}
+		vectors = append(vectors, v)
+	}
+	return vectors, nil
+}
+
+func buildBaseline(v *Vector, signer *crypto.Signer) error {
+	c := newChain(signer, runID(v.Name, 0))
+	if err := c.genesis(nil); err != nil {
+		return err
+	}
+	err := c.call("tools/call", map[string]interface{}{"name": "read_file", "arguments": map[string]interface{}{"path": "/etc/hostname"}},
+		models.Event{Response: map[string]interface{}{"content": []interface{}{map[string]interface{}{"type": "text", "text": "build-01\n"}}}})
+	if err != nil {
+		return err
+	}
+	err = c.add(models.Event{Actor: "agent", EventType: "tool_call", Method: "tools/call", TaskID: "task-1", TaskState: "working",
+		PolicyID: "stall-deletes", RiskLevel: "high", Params: map[string]interface{}{"name": "delete_branch", "arguments": map[string]interface{}{}}})
+	if err != nil {
+		return err
+	}
+	v.Events = c.events
+	return nil
+}
+
+func buildOptionalFields(v *Vector, signer *crypto.Signer) error {
+	c := newChain(signer, runID(v.Name, 0))
+	if err := c.genesis(nil); err != nil {
+		return err
+	}
+	call := models.Event{Actor: "agent", EventType: "tool_call", Method: "tools/call",
+		Params:        map[string]interface{}{"name": "search"},
+		Environment:   "prod",
+		Tags:          []string{"schema_violation", "pii_detected"},
+		Labels:        map[string]string{"team": "payments", models.LabelCanonicalMethod: "tools/call"},
+		CorrelationID: "req-7f3a",
+		TraceID:       "4bf92f3577b34da6a3ce929d0e0e4736",
+		SpanID:        "00f067aa0ba902b7",
+		Headers:       map[string]string{"User-Agent": "agent/1.2", "Authorization": "[redacted]"},
+		QueryParams:   map[string]string{"session": "abc", "empty": ""},
+	}
+	if err := c.add(call); err != nil {
+		return err
+	}
+	callID := c.events[len(c.events)-1].ID
+	results := []interface{}{
+		[]interface{}{"a", 1, nil, true},
+		"plain string result",
+		42.5,
+		false,
+	}
+	for _, result := range results {
+		if err := c.add(models.Event{Actor: "agent", EventType: "tool_response", Method: "tools/call", ParentID: callID, ResponseValue: result}); err != nil {
+			return err
+		}
+	}
+	v.Events = c.events
+	return nil
+}
+
+func buildTimestamps(v *Vector, signer *crypto.Signer) error {
+	c := newChain(signer, runID(v.Name, 0))
+	if err := c.genesis(nil); err != nil {
+		return err
+	}
+	stamps := []time.Time{
+		time.Date(2026, 1, 1, 0, 0, 1, 123456789, time.UTC),
+		time.Date(2026, 1, 1, 0, 0, 2, 120000000, time.UTC), // RFC3339Nano drops the trailing zeros
+		time.Date(2026, 1, 1, 5, 30, 3, 0, time.FixedZone("IST", 5*3600+30*60)),
+		time.Date(2025, 12, 31, 23, 59, 59, 999999999, time.FixedZone("", -8*3600)),
+	}
+	for i, ts := range stamps {
+		if err := c.add(models.Event{Actor: "agent", EventType: "tool_call", Method: "ping", Timestamp: ts, Params: map[string]interface{}{"n": i}}); err != nil {
+			return err
+		}
+	}
+	v.Events = c.events
+	return nil
+}
+
+func buildUnicode(v *Vector, signer *crypto.Signer) error {
+	c := newChain(signer, runID(v.Name, 0))
+	if err := c.genesis(nil); err != nil {
+		return err
+	}
+	params := map[string]interface{}{
+		// JCS sorts keys by UTF-16 code unit: the surrogate pair of U+1F600 sorts before
+		// U+FB01, the reverse of their UTF-8 byte order.
+		"\U0001F600": "grinning",
+		"\ufb01":     "ligature",
+		"\u20ac":     "euro",
+		"Z":          "upper",
+		"a":          "lower",
+		"":           "empty key",
+		"caf\u00e9":  "NFC",
+		"cafe\u0301": "NFD",
+		"controls":   "tab\tnewline\nnul\x00bell\x07del\x7f",
+		"escapes":    "quote\" backslash\\ slash/",
+		"html":       "</script><b>&amp;",
+		"separators": "line\u2028paragraph\u2029",
+		"scripts":    "\u65e5\u672c\u8a9e \u0627\u0644\u0639\u0631\u0628\u064a\u0629 \U0001F468\u200d\U0001F469\u200d\U0001F467",
+		"bom":        "\ufeffstart",
+	}
+	err := c.call("tools/call", map[string]interface{}{"name": "translate", "arguments": params},
+		models.Event{Response: map[string]interface{}{"text": "\u00c5ngstr\u00f6m \u212b \U0001D11E"}, Labels: map[string]string{"lang": "\u00e9"}})
+	if err != nil {
+		return err
+	}
+	v.Events = c.events
+	return nil
+}
+
+func buildNumbers(v *Vector, signer *crypto.Signer) error {
+	c := newChain(signer, runID(v.Name, 0))
+	if err := c.genesis(nil); err != nil {
+		return err
+	}
+	params := map[string]interface{}{
+		"zero":          0,
+		"negative_zero": math.Copysign(0, -1),
+		"one_e21":       1e21,
+		"below_e21":     1e20 + 1,
+		"one_e_minus7":  1e-7,
+		"one_e_minus6":  1e-6,
+		"max_safe":      uint64(1<<53 - 1),
+		"past_safe":     uint64(1<<53 + 1), // read back as 2^53
+		"max_uint64":    uint64(math.MaxUint64),
+		"min_int64":     int64(math.MinInt64),
+		"smallest":      5e-324,
+		"largest":       math.MaxFloat64,
+		"third":         1.0 / 3,
+		"negative":      -273.15,
+		"array":         []interface{}{1, 1.5, -1, 0.1 + 0.2},
+	}
+	if err := c.call("tools/call", map[string]interface{}{"name": "compute", "arguments": params},
+		models.Event{Response: map[string]interface{}{"result": 0.30000000000000004}}); err != nil {
+		return err
+	}
+	v.Events = c.events
+	return nil
+}
+
+func buildLarge(v *Vector, signer *crypto.Signer) error {
+	c := newChain(signer, runID(v.Name, 0))
+	if err := c.genesis(nil); err != nil {
+		return err
+	}
+	var sb strings.Builder
+	pattern := "The quick brown fox \u00e9\u00e8 \u4e2d\u6587 \U0001F98A jumps over 13 lazy dogs.\n"
+	for sb.Len() < largeStringLen {
+		sb.WriteString(pattern)
+	}
+	rows := make([]interface{}, 0, largeObjects)
+	for i := 0; i < largeObjects; i++ {
+		rows = append(rows, map[string]interface{}{"id": i, "name": fmt.Sprintf("row-%03d", i), "ratio": float64(i) / 7, "even": i%2 == 0})
+	}
+	var nested interface{} = "bottom"
+	for i := 0; i < nestingDepth; i++ {
+		nested = map[string]interface{}{fmt.Sprintf("level_%02d", nestingDepth-i): nested, "list": []interface{}{i}}
+	}
+	err := c.call("tools/call", map[string]interface{}{"name": "query", "arguments": map[string]interface{}{"text": sb.String()}},
+		models.Event{Response: map[string]interface{}{"rows": rows, "nested": nested}})
+	if err != nil {
+		return err
+	}
+	v.Events = c.events
+	return nil
+}
+
+func buildCompressed(v *Vector, signer *crypto.Signer) error {
+	c := newChain(signer, runID(v.Name, 0))
+	if err := c.genesis(nil); err != nil {
+		return err
+	}
+	responses := []struct {
+		encoding string
+		result   interface{}
+	}{
+		{EncodingGzip, map[string]interface{}{"content": []interface{}{map[string]interface{}{"type": "text", "text": strings.Repeat("compressible \u00fc ", 200)}}}},
+		{EncodingDeflate, []interface{}{"not", "an", "object", 3}},
+	}
+	for i, r := range responses {
+		resp := models.Event{Headers: map[string]string{"Content-Encoding": r.encoding, "Content-Type": "application/json"}}
+		if obj, ok := r.result.(map[string]interface{}); ok {
+			resp.Response = obj
+		} else {
+			resp.ResponseValue = r.result
+		}
+		if err := c.call("tools/call", map[string]interface{}{"name": "fetch", "arguments": map[string]interface{}{"n": i}}, resp); err != nil {
+			return err
+		}
+		body, err := json.Marshal(map[string]interface{}{"jsonrpc": "2.0", "id": i + 1, "result": r.result})
+		if err != nil {
+			return err
+		}
+		encoded, err := encode(r.encoding, body)
+		if err != nil {
+			return err
+		}
+		v.Encoded = append(v.Encoded, EncodedBody{EventID: c.events[len(c.events)-1].ID, Encoding: r.encoding, Body: encoded})
+	}
+	v.Events = c.events
+	return nil
+}
+
+// encode compresses body the way an upstream would for Content-Encoding.
+func encode(encoding string, body []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	var w interface {
+		Write([]byte) (int, error)
+		Close() error
+	}
+	switch encoding {
+	case EncodingGzip:
+		w = gzip.NewWriter(&buf)
+	case EncodingDeflate:
+		w = zlib.NewWriter(&buf)
+	default:
+		return nil, fmt.Errorf("unsupported encoding %q", encoding)
+	}
+	if _, err := w.Write(body); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// rotated builds two runs, the second started by rotating the first. link, when set,
+// replaces the run_closed event the second genesis names.
+func rotated(v *Vector, signer *crypto.Signer, link func(*models.Event)) error {
+	first := newChain(signer, runID(v.Name, 0))
+	if err := first.genesis(nil); err != nil {
+		return err
+	}
+	if err := first.call("tools/call", map[string]interface{}{"name": "list"}, models.Event{Response: map[string]interface{}{"items": []interface{}{}}}); err != nil {
+		return err
+	}
+	closing, err := first.closeRun("max_events")
+	if err != nil {
+		return err
+	}
+	prev := *closing
+	if link != nil {
+		link(&prev)
+	}
+	second := newChain(signer, runID(v.Name, 1))
+	if err := second.genesis(&prev); err != nil {
+		return err
+	}
+	if err := second.call("tools/call", map[string]interface{}{"name": "list"}, models.Event{Response: map[string]interface{}{"items": []interface{}{"x"}}}); err != nil {
+		return err
+	}
+	v.Events = append(first.events, second.events...)
+	return nil
+}
+
+func buildRotation(v *Vector, signer *crypto.Signer) error {
+	return rotated(v, signer, nil)
+}
+
+func buildBrokenRotationLink(v *Vector, signer *crypto.Signer) error {
+	if err := rotated(v, signer, func(prev *models.Event) { prev.CurrentHash = prev.PrevHash }); err != nil {
+		return err
+	}
+	v.FailedAtSeq = 0
+	return nil
+}
+
+// gapped builds a run of five calls and drops seq 2, acknowledging it when ack is set.
+func gapped(v *Vector, signer *crypto.Signer, ack bool) error {
+	c := newChain(signer, runID(v.Name, 0))
+	if err := c.genesis(nil); err != nil {
+		return err
+	}
+	for i := 1; i <= 4; i++ {
+		if err := c.add(models.Event{Actor: "agent", EventType: "tool_call", Method: "tools/call", Params: map[string]interface{}{"n": i}}); err != nil {
+			return err
+		}
+	}
+	if ack {
+		err := c.add(models.Event{Actor: "user", EventType: audit.EventTypeGapAcknowledged, Method: "logryph:gap", Params: map[string]interface{}{
+			"missing_from":  2,
+			"missing_to":    2,
+			"missing_count": 1,
+			"operator":      "oncall",
+			"reason":        "disk failure",
+		}})
+		if err != nil {
+			return err
+		}
+		v.AcknowledgedGaps = []audit.Gap{{From: 2, To: 2}}
+	}
+	v.Events = append(append([]models.Event{}, c.events[:2]...), c.events[3:]...)
+	return nil
+}
+
+func buildAcknowledgedGap(v *Vector, signer *crypto.Signer) error {
+	return gapped(v, signer, true)
+}
+
+func buildUnacknowledgedGap(v *Vector, signer *crypto.Signer) error {
+	if err := gapped(v, signer, false); err != nil {
+		return err
+	}
+	v.FailedAtSeq = 3
+	return nil
+}
+
+func buildTamperedResponse(v *Vector, signer *crypto.Signer) error {
+	if err := buildBaseline(v, signer); err != nil {
+		return err
+	}
+	v.Events[2].Response = map[string]interface{}{"content": []interface{}{map[string]interface{}{"type": "text", "text": "build-02\n"}}}
+	v.FailedAtSeq = 2
+	return nil
+}
+
+func buildForgedSignature(v *Vector, signer *crypto.Signer) error {
+	if err := buildBaseline(v, signer); err != nil {
+		return err
+	}
+	forger, err := seededSigner(v.Name + "/forger")
+	if err != nil {
+		return err
+	}
+	if v.Events[1].Signature, err = forger.SignHash(v.Events[1].CurrentHash); err != nil {
+		return err
+	}
+	v.FailedAtSeq = 1
+	return nil
+}
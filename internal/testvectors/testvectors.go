@@ -0,0 +1,302 @@
+// Package testvectors holds a corpus of signed ledger events that every release must
+// still verify, byte for byte. Event hashes are taken over RFC 8785 canonical JSON, so a
+// change to how payloads are encoded, decoded or canonicalized would silently break the
+// verification of ledgers written by earlier releases. The corpus covers the payloads
+// most likely to expose such a change: unicode, awkward numbers, large and deeply nested
+// values, responses that arrived compressed, each generation of the event format, and run
+// rotation. Tampered vectors check the verifier still rejects what it should.
+//
+// Generate rebuilds the corpus from fixed keys and payloads, so it is reproducible. The
+// copy embedded here was generated by an earlier release and is checked as it is.
+package testvectors
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+
+	"github.com/slyt3/Logryph/internal/ledger/audit"
+	"github.com/slyt3/Logryph/internal/models"
+)
+
+// Format identifies the corpus layout. A corpus of another format is refused.
+const Format = "logryph-testvectors/1"
+
+// Expected outcomes of verifying a vector.
+const (
+	ExpectValid            = "valid"
+	ExpectHashMismatch     = "hash_mismatch"
+	ExpectInvalidSignature = "invalid_signature"
+	ExpectChainBroken      = "chain_broken"
+	ExpectRunLinkBroken    = "run_link_broken"
+)
+
+// Encodings of EncodedBody.
+const (
+	EncodingGzip    = "gzip"
+	EncodingDeflate = "deflate"
+)
+
+const (
+	maxCorpusBytes   = 16 << 20
+	maxVectors       = 1024
+	maxDecodedBody   = 4 << 20
+	maxVectorRuns    = 64
+	maxVectorEncoded = 64
+)
+
+//go:embed corpus.json
+var embedded []byte
+
+// Corpus is the file Generate writes and Check reads.
+type Corpus struct {
+	Format  string   `json:"format"`
+	Vectors []Vector `json:"vectors"`
+}
+
+// Vector is one or more runs of signed events and what verifying them must report.
+// FailedAtSeq is the seq the failure is reported at, for vectors that must fail.
+type Vector struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description"`
+	PubKey      string         `json:"pub_key"`
+	Expect      string         `json:"expect"`
+	FailedAtSeq uint64         `json:"failed_at_seq,omitempty"`
+	Events      []models.Event `json:"events"`
+	// AcknowledgedGaps are the gaps verification must accept.
+	AcknowledgedGaps []audit.Gap `json:"acknowledged_gaps,omitempty"`
+	// Encoded are response bodies as the upstream sent them, compressed. The
+	// tool_response event named by each recorded the decoded result.
+	Encoded []EncodedBody `json:"encoded,omitempty"`
+}
+
+// EncodedBody is a JSON-RPC response body under a Content-Encoding.
+type EncodedBody struct {
+	EventID  string `json:"event_id"`
+	Encoding string `json:"encoding"`
+	Body     []byte `json:"body"` // base64 in the corpus file
+}
+
+// Result is the outcome of checking one vector. OK is false when verification reported
+// something other than the vector expects.
+type Result struct {
+	Name   string
+	Expect string
+	Got    string
+	Seq    uint64 // where Got was reported, for failures
+	Detail string
+	OK     bool
+}
+
+// Embedded returns the corpus shipped with this build.
+func Embedded() []byte {
+	return embedded
+}
+
+// Load parses a corpus file.
+func Load(data []byte) (*Corpus, error) {
+	if len(data) > maxCorpusBytes {
+		return nil, fmt.Errorf("corpus is larger than %d bytes", maxCorpusBytes)
+	}
+	var c Corpus
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("parsing corpus: %w", err)
+	}
+	if c.Format != Format {
+		return nil, fmt.Errorf("corpus format %q, want %q", c.Format, Format)
+	}
+	if len(c.Vectors) == 0 || len(c.Vectors) > maxVectors {
+		return nil, fmt.Errorf("corpus must hold 1 to %d vectors, has %d", maxVectors, len(c.Vectors))
+	}
+	return &c, nil
+}
+
+// Check verifies every vector of c.
+func Check(c *Corpus) []Result {
+	results := make([]Result, 0, len(c.Vectors))
+	for i := 0; i < len(c.Vectors); i++ {
+		results = append(results, checkVector(&c.Vectors[i]))
+	}
+	return results
+}
+
+// checkVector verifies each run of v from its genesis, then the links between runs, then
+// the encoded bodies, and compares the first failure with what v expects.
+func checkVector(v *Vector) Result {
+	res := Result{Name: v.Name, Expect: v.Expect, Got: ExpectValid}
+	runs, order := splitRuns(v.Events)
+	if len(order) == 0 || len(order) > maxVectorRuns {
+		res.Got, res.Detail = "error", fmt.Sprintf("vector must hold 1 to %d runs", maxVectorRuns)
+		return res
+	}
+	var gaps []audit.Gap
+	for _, runID := range order {
+		r := audit.VerifyEventsWithKey(runs[runID], nil, v.PubKey)
+		gaps = append(gaps, r.AcknowledgedGaps...)
+		if !r.Valid {
+			res.Got, res.Seq, res.Detail = classify(r.ErrorMessage), r.FailedAtSeq, r.ErrorMessage
+			break
+		}
+	}
+	if res.Got == ExpectValid {
+		if seq, detail := checkRunLinks(runs, order); detail != "" {
+			res.Got, res.Seq, res.Detail = ExpectRunLinkBroken, seq, detail
+		}
+	}
+	if res.Got == ExpectValid && !reflect.DeepEqual(gaps, v.AcknowledgedGaps) && (len(gaps) > 0 || len(v.AcknowledgedGaps) > 0) {
+		res.Got, res.Detail = "gaps_differ", fmt.Sprintf("acknowledged gaps %v, want %v", gaps, v.AcknowledgedGaps)
+	}
+	if res.Got == ExpectValid {
+		if err := checkEncoded(v); err != nil {
+			res.Got, res.Detail = "encoding_differs", err.Error()
+		}
+	}
+	res.OK = res.Got == v.Expect && (v.Expect == ExpectValid || res.Seq == v.FailedAtSeq)
+	return res
+}
+
+// splitRuns groups events by run, keeping the order runs first appear in.
+func splitRuns(events []models.Event) (map[string][]models.Event, []string) {
+	runs := make(map[string][]models.Event)
+	var order []string
+	for i := 0; i < len(events); i++ {
+		id := events[i].RunID
+		if _, ok := runs[id]; !ok {
+			order = append(order, id)
+		}
+		runs[id] = append(runs[id], events[i])
+	}
+	return runs, order
+}
+
+// classify names a verification failure by the error it reports.
+func classify(message string) string {
+	switch {
+	case strings.Contains(message, audit.ErrHashMismatch.Error()):
+		return ExpectHashMismatch
+	case strings.Contains(message, audit.ErrInvalidSignature.Error()):
+		return ExpectInvalidSignature
+	case strings.Contains(message, audit.ErrChainTampered.Error()):
+		return ExpectChainBroken
+	}
+	return "error"
+}
+
+// checkRunLinks checks that each genesis started by rotation names the run_closed event
+// its previous run, also in the vector, ends with. It returns the seq of the genesis's
+// run where a link fails, with why.
+func checkRunLinks(runs map[string][]models.Event, order []string) (uint64, string) {
+	for _, runID := range order {
+		genesis := runs[runID][0]
+		prevRun, _ := genesis.Params[audit.ParamPrevRunID].(string)
+		if prevRun == "" {
+			continue
+		}
+		prev, ok := runs[prevRun]
+		if !ok {
+			return genesis.SeqIndex, fmt.Sprintf("run %s links to run %s, which is not in the vector", runID, prevRun)
+		}
+		last := prev[len(prev)-1]
+		seq, okSeq := paramUint(genesis.Params[audit.ParamPrevRunSeq])
+		hash, _ := genesis.Params[audit.ParamPrevRunHead].(string)
+		if !okSeq || last.EventType != audit.EventTypeRunClosed || last.SeqIndex != seq || last.CurrentHash != hash {
+			return genesis.SeqIndex, fmt.Sprintf("run %s does not end with the run_closed event (seq %d) run %s's genesis references", prevRun, seq, runID)
+		}
+	}
+	return 0, ""
+}
+
+func paramUint(v interface{}) (uint64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return uint64(n), n >= 0 && n == float64(uint64(n))
+	case uint64:
+		return n, true
+	}
+	return 0, false
+}
+
+// checkEncoded inflates each encoded body and checks the JSON-RPC result in it is what
+// its tool_response recorded.
+func checkEncoded(v *Vector) error {
+	if len(v.Encoded) > maxVectorEncoded {
+		return fmt.Errorf("more than %d encoded bodies", maxVectorEncoded)
+	}
+	for i := 0; i < len(v.Encoded); i++ {
+		enc := v.Encoded[i]
+		var event *models.Event
+		for j := 0; j < len(v.Events); j++ {
+			if v.Events[j].ID == enc.EventID {
+				event = &v.Events[j]
+			}
+		}
+		if event == nil {
+			return fmt.Errorf("encoded body names event %s, which is not in the vector", enc.EventID)
+		}
+		plain, err := decode(enc.Encoding, enc.Body)
+		if err != nil {
+			return fmt.Errorf("event %s: %w", enc.EventID, err)
+		}
+		var body struct {
+			Result interface{} `json:"result"`
+		}
+		if err := json.Unmarshal(plain, &body); err != nil {
+			return fmt.Errorf("event %s: parsing decoded body: %w", enc.EventID, err)
+		}
+		var recorded interface{} = event.Response
+		if event.ResponseValue != nil {
+			recorded = event.ResponseValue
+		}
+		recorded, err = roundTrip(recorded)
+		if err != nil {
+			return err
+		}
+		if !reflect.DeepEqual(body.Result, recorded) {
+			return fmt.Errorf("event %s recorded a result that differs from its decoded %s body", enc.EventID, enc.Encoding)
+		}
+	}
+	return nil
+}
+
+func decode(encoding string, body []byte) ([]byte, error) {
+	var r io.ReadCloser
+	var err error
+	switch encoding {
+	case EncodingGzip:
+		r, err = gzip.NewReader(bytes.NewReader(body))
+	case EncodingDeflate:
+		r, err = zlib.NewReader(bytes.NewReader(body))
+	default:
+		return nil, fmt.Errorf("unsupported encoding %q", encoding)
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	plain, err := io.ReadAll(io.LimitReader(r, maxDecodedBody+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(plain) > maxDecodedBody {
+		return nil, errors.New("decoded body is too large")
+	}
+	return plain, nil
+}
+
+// roundTrip returns v as it reads back from JSON.
+func roundTrip(v interface{}) (interface{}, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var out interface{}
+	err = json.Unmarshal(data, &out)
+	return out, err
+}
@@ -0,0 +1,74 @@
+package testvectors
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/slyt3/Logryph/internal/models"
+)
+
+func TestEmbeddedCorpusVerifies(t *testing.T) {
+	c, err := Load(Embedded())
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	for _, r := range Check(c) {
+		if !r.OK {
+			t.Errorf("%s: expected %s, got %s at seq %d: %s", r.Name, r.Expect, r.Got, r.Seq, r.Detail)
+		}
+	}
+}
+
+// The embedded corpus was written by an earlier generation; regenerating must reproduce
+// every event, hash and signature. Encoded bodies are compared decoded, since compressors
+// may change their output between Go releases.
+func TestGenerateReproducesEmbeddedCorpus(t *testing.T) {
+	data, err := Generate()
+	if err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+	generated, err := Load(data)
+	if err != nil {
+		t.Fatalf("Load generated: %v", err)
+	}
+	frozen, err := Load(Embedded())
+	if err != nil {
+		t.Fatalf("Load embedded: %v", err)
+	}
+	if len(generated.Vectors) != len(frozen.Vectors) {
+		t.Fatalf("generated %d vectors, embedded corpus has %d", len(generated.Vectors), len(frozen.Vectors))
+	}
+	for i := range frozen.Vectors {
+		g, f := generated.Vectors[i], frozen.Vectors[i]
+		for _, v := range []*Vector{&g, &f} {
+			for j := range v.Encoded {
+				plain, err := decode(v.Encoded[j].Encoding, v.Encoded[j].Body)
+				if err != nil {
+					t.Fatalf("%s: %v", v.Name, err)
+				}
+				v.Encoded[j].Body = plain
+			}
+		}
+		if !reflect.DeepEqual(g, f) {
+			t.Errorf("vector %s differs from the embedded corpus", f.Name)
+		}
+	}
+}
+
+func TestCheckReportsUnexpectedOutcome(t *testing.T) {
+	c, err := Load(Embedded())
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	v := c.Vectors[0]
+	v.Events = append([]models.Event{}, v.Events...)
+	v.Events[1].Method = "tools/list"
+	r := Check(&Corpus{Vectors: []Vector{v}})[0]
+	if r.OK || r.Got != ExpectHashMismatch || r.Seq != 1 {
+		t.Errorf("tampered valid vector: %+v", r)
+	}
+
+	if _, err := Load([]byte(`{"format":"other/1","vectors":[{}]}`)); err == nil {
+		t.Error("corpus of another format was loaded")
+	}
+}
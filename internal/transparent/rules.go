@@ -0,0 +1,213 @@
+package transparent
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+const (
+	// Chain is the iptables chain, and Table the nftables table, holding the rules, so
+	// they can be removed without touching anything else.
+	Chain = "LOGRYPH"
+	Table = "logryph"
+	// tproxyMark and tproxyTable route TPROXY-marked packets to the local stack.
+	tproxyMark  = "0x1"
+	tproxyTable = "100"
+	maxPorts    = 15 // iptables multiport limit
+)
+
+// Rules describes which connections to divert to the proxy.
+type Rules struct {
+	Mode       string // ModeRedirect or ModeTProxy
+	ProxyPort  int    // the proxy's listen port
+	Ports      []int  // destination ports of the tool servers to divert
+	UID        int    // redirect: only this user's connections (-1 for any)
+	ExcludeUID int    // redirect: every user's except this one, normally the proxy's (-1 for none)
+	Nftables   bool   // nft instead of iptables/ip6tables
+}
+
+// Command is one program invocation, with an optional script on stdin.
+type Command struct {
+	Args  []string
+	Stdin string
+}
+
+func (c Command) String() string {
+	if c.Stdin == "" {
+		return strings.Join(c.Args, " ")
+	}
+	return strings.Join(c.Args, " ") + " <<'EOF'\n" + c.Stdin + "EOF"
+}
+
+// Validate rejects rule sets that could not work or would loop the proxy's own
+// upstream connections back into itself.
+func (r Rules) Validate() error {
+	if r.Mode != ModeRedirect && r.Mode != ModeTProxy {
+		return fmt.Errorf("mode must be %q or %q", ModeRedirect, ModeTProxy)
+	}
+	if r.ProxyPort < 1 || r.ProxyPort > 65535 {
+		return fmt.Errorf("proxy port %d out of range", r.ProxyPort)
+	}
+	if len(r.Ports) == 0 || len(r.Ports) > maxPorts {
+		return fmt.Errorf("between 1 and %d destination ports are required", maxPorts)
+	}
+	for _, p := range r.Ports {
+		if p < 1 || p > 65535 || p == r.ProxyPort {
+			return fmt.Errorf("destination port %d is out of range or is the proxy port", p)
+		}
+	}
+	if r.Mode == ModeTProxy && (r.UID >= 0 || r.ExcludeUID >= 0) {
+		return fmt.Errorf("tproxy diverts routed traffic, which has no owner; --uid and --exclude-uid apply to redirect only")
+	}
+	if r.Mode == ModeRedirect && r.UID < 0 && r.ExcludeUID < 0 {
+		return fmt.Errorf("redirect needs --uid (the agent's user) or --exclude-uid (the proxy's user), or the proxy's own upstream calls would be redirected back to it")
+	}
+	return nil
+}
+
+func (r Rules) portList(sep string) string {
+	ports := make([]string, 0, len(r.Ports))
+	for _, p := range r.Ports {
+		ports = append(ports, strconv.Itoa(p))
+	}
+	return strings.Join(ports, sep)
+}
+
+// Install returns the commands that add the rules.
+func (r Rules) Install() ([]Command, error) {
+	if err := r.Validate(); err != nil {
+		return nil, err
+	}
+	var cmds []Command
+	if r.Nftables {
+		cmds = append(cmds, Command{Args: []string{"nft", "-f", "-"}, Stdin: r.nftScript()})
+	} else {
+		for _, bin := range []string{"iptables", "ip6tables"} {
+			cmds = append(cmds, r.iptablesInstall(bin)...)
+		}
+	}
+	if r.Mode == ModeTProxy {
+		cmds = append(cmds,
+			Command{Args: []string{"ip", "rule", "add", "fwmark", tproxyMark, "lookup", tproxyTable}},
+			Command{Args: []string{"ip", "route", "add", "local", "0.0.0.0/0", "dev", "lo", "table", tproxyTable}},
+			Command{Args: []string{"ip", "-6", "rule", "add", "fwmark", tproxyMark, "lookup", tproxyTable}},
+			Command{Args: []string{"ip", "-6", "route", "add", "local", "::/0", "dev", "lo", "table", tproxyTable}},
+		)
+	}
+	return cmds, nil
+}
+
+// Remove returns the commands that delete everything Install added.
+func (r Rules) Remove() []Command {
+	var cmds []Command
+	if r.Nftables {
+		cmds = append(cmds, Command{Args: []string{"nft", "delete", "table", "inet", Table}})
+	} else {
+		table, hook := r.iptablesHook()
+		for _, bin := range []string{"iptables", "ip6tables"} {
+			cmds = append(cmds,
+				Command{Args: []string{bin, "-t", table, "-D", hook, "-j", Chain}},
+				Command{Args: []string{bin, "-t", table, "-F", Chain}},
+				Command{Args: []string{bin, "-t", table, "-X", Chain}},
+			)
+		}
+	}
+	if r.Mode == ModeTProxy {
+		cmds = append(cmds,
+			Command{Args: []string{"ip", "rule", "del", "fwmark", tproxyMark, "lookup", tproxyTable}},
+			Command{Args: []string{"ip", "route", "del", "local", "0.0.0.0/0", "dev", "lo", "table", tproxyTable}},
+			Command{Args: []string{"ip", "-6", "rule", "del", "fwmark", tproxyMark, "lookup", tproxyTable}},
+			Command{Args: []string{"ip", "-6", "route", "del", "local", "::/0", "dev", "lo", "table", tproxyTable}},
+		)
+	}
+	return cmds
+}
+
+func (r Rules) iptablesHook() (table, hook string) {
+	if r.Mode == ModeTProxy {
+		return "mangle", "PREROUTING"
+	}
+	return "nat", "OUTPUT"
+}
+
+func (r Rules) iptablesInstall(bin string) []Command {
+	table, hook := r.iptablesHook()
+	rule := []string{bin, "-t", table, "-A", Chain, "-p", "tcp", "-m", "multiport", "--dports", r.portList(",")}
+	switch {
+	case r.Mode == ModeTProxy:
+		rule = append(rule, "-j", "TPROXY", "--on-port", strconv.Itoa(r.ProxyPort), "--tproxy-mark", tproxyMark+"/"+tproxyMark)
+	default:
+		if r.UID >= 0 {
+			rule = append(rule, "-m", "owner", "--uid-owner", strconv.Itoa(r.UID))
+		}
+		if r.ExcludeUID >= 0 {
+			rule = append(rule, "-m", "owner", "!", "--uid-owner", strconv.Itoa(r.ExcludeUID))
+		}
+		rule = append(rule, "-j", "REDIRECT", "--to-ports", strconv.Itoa(r.ProxyPort))
+	}
+	return []Command{
+		{Args: []string{bin, "-t", table, "-N", Chain}},
+		{Args: rule},
+		{Args: []string{bin, "-t", table, "-A", hook, "-j", Chain}},
+	}
+}
+
+func (r Rules) nftScript() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "table inet %s {\n", Table)
+	match := fmt.Sprintf("meta l4proto tcp tcp dport { %s }", r.portList(", "))
+	if r.Mode == ModeTProxy {
+		b.WriteString("  chain prerouting {\n    type filter hook prerouting priority mangle; policy accept;\n")
+		fmt.Fprintf(&b, "    %s meta mark set %s tproxy to :%d accept\n", match, tproxyMark, r.ProxyPort)
+	} else {
+		b.WriteString("  chain output {\n    type nat hook output priority -100; policy accept;\n")
+		if r.UID >= 0 {
+			match += fmt.Sprintf(" meta skuid %d", r.UID)
+		}
+		if r.ExcludeUID >= 0 {
+			match += fmt.Sprintf(" meta skuid != %d", r.ExcludeUID)
+		}
+		fmt.Fprintf(&b, "    %s redirect to :%d\n", match, r.ProxyPort)
+	}
+	b.WriteString("  }\n}\n")
+	return b.String()
+}
+
+// Run executes cmds in order and stops at the first failure.
+func Run(cmds []Command) error {
+	for i := 0; i < len(cmds); i++ {
+		if err := run(cmds[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RunAll executes every command, for teardown where some rules may already be gone,
+// and returns the failures together.
+func RunAll(cmds []Command) error {
+	var errs []error
+	for i := 0; i < len(cmds); i++ {
+		if err := run(cmds[i]); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func run(c Command) error {
+	cmd := exec.Command(c.Args[0], c.Args[1:]...)
+	if c.Stdin != "" {
+		cmd.Stdin = strings.NewReader(c.Stdin)
+	}
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%s: %w: %s", strings.Join(c.Args, " "), err, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+//go:build linux
+
+package transparent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"syscall"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+const supported = true
+
+// soOriginalDst is SO_ORIGINAL_DST (netfilter_ipv4.h) and IP6T_SO_ORIGINAL_DST.
+const soOriginalDst = 80
+
+// originalDst reads the pre-REDIRECT destination of c from conntrack. It returns nil
+// when the connection was not redirected.
+func originalDst(c net.Conn) (*net.TCPAddr, error) {
+	tc, ok := c.(*net.TCPConn)
+	if !ok {
+		return nil, fmt.Errorf("not a TCP connection: %T", c)
+	}
+	raw, err := tc.SyscallConn()
+	if err != nil {
+		return nil, err
+	}
+	var dst *net.TCPAddr
+	var sockErr error
+	local, _ := c.LocalAddr().(*net.TCPAddr)
+	err = raw.Control(func(fd uintptr) {
+		if local != nil && local.IP.To4() == nil {
+			// sockaddr_in6 is larger than IPv6Mreq; IPv6MTUInfo starts with one.
+			var info *unix.IPv6MTUInfo
+			if info, sockErr = unix.GetsockoptIPv6MTUInfo(int(fd), unix.SOL_IPV6, soOriginalDst); sockErr == nil {
+				port := (*[2]byte)(unsafe.Pointer(&info.Addr.Port))
+				dst = &net.TCPAddr{IP: net.IP(info.Addr.Addr[:]), Port: int(port[0])<<8 | int(port[1])}
+			}
+			return
+		}
+		var mreq *unix.IPv6Mreq
+		if mreq, sockErr = unix.GetsockoptIPv6Mreq(int(fd), unix.SOL_IP, soOriginalDst); sockErr == nil {
+			// sockaddr_in: family(2) port(2, network order) addr(4)
+			a := mreq.Multiaddr
+			dst = &net.TCPAddr{IP: net.IPv4(a[4], a[5], a[6], a[7]), Port: int(a[2])<<8 | int(a[3])}
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	if errors.Is(sockErr, unix.ENOENT) {
+		return nil, nil // no conntrack entry: the connection was not redirected
+	}
+	if sockErr != nil {
+		return nil, fmt.Errorf("SO_ORIGINAL_DST: %w", sockErr)
+	}
+	return dst, nil
+}
+
+// listenTProxy listens with IP_TRANSPARENT so TPROXY-diverted connections, addressed to
+// other hosts, are accepted. Requires CAP_NET_ADMIN.
+func listenTProxy(addr string) (net.Listener, error) {
+	lc := net.ListenConfig{Control: func(network, _ string, c syscall.RawConn) error {
+		var sockErr error
+		err := c.Control(func(fd uintptr) {
+			if network == "tcp6" {
+				sockErr = unix.SetsockoptInt(int(fd), unix.SOL_IPV6, unix.IPV6_TRANSPARENT, 1)
+				return
+			}
+			sockErr = unix.SetsockoptInt(int(fd), unix.SOL_IP, unix.IP_TRANSPARENT, 1)
+		})
+		if err != nil {
+			return err
+		}
+		if sockErr != nil {
+			return fmt.Errorf("setting IP_TRANSPARENT (needs CAP_NET_ADMIN): %w", sockErr)
+		}
+		return nil
+	}}
+	return lc.Listen(context.Background(), "tcp", addr)
+}
@@ -0,0 +1,18 @@
+//go:build !linux
+
+package transparent
+
+import (
+	"fmt"
+	"net"
+)
+
+const supported = false
+
+func originalDst(net.Conn) (*net.TCPAddr, error) {
+	return nil, fmt.Errorf("transparent mode is only supported on Linux")
+}
+
+func listenTProxy(string) (net.Listener, error) {
+	return nil, fmt.Errorf("transparent mode is only supported on Linux")
+}
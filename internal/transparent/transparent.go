@@ -0,0 +1,90 @@
+// Package transparent lets agents be steered through the proxy without changing the
+// endpoint URLs they are configured with. Firewall rules (see Rules) divert their
+// connections to the proxy port; the proxy then recovers each connection's original
+// destination and forwards there instead of to --target.
+//
+// Two kernel mechanisms are supported, both Linux only:
+//   - redirect: nat REDIRECT in OUTPUT, for agents on the same host or pod. The original
+//     destination is read with SO_ORIGINAL_DST.
+//   - tproxy: mangle TPROXY in PREROUTING, for traffic routed through this host. The
+//     listener needs IP_TRANSPARENT and the original destination is the local address.
+//
+// Only plain HTTP can be steered this way; TLS connections would have to be terminated
+// by the proxy, which transparent mode does not do.
+package transparent
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+
+	"github.com/slyt3/Logryph/internal/logging"
+)
+
+// Modes accepted by --transparent.
+const (
+	ModeRedirect = "redirect"
+	ModeTProxy   = "tproxy"
+)
+
+type ctxKey struct{}
+
+// ValidateMode rejects unknown modes and platforms without transparent proxying.
+func ValidateMode(mode string) error {
+	if mode != ModeRedirect && mode != ModeTProxy {
+		return fmt.Errorf("transparent mode must be %q or %q, got %q", ModeRedirect, ModeTProxy, mode)
+	}
+	if !supported {
+		return fmt.Errorf("transparent mode is only supported on Linux")
+	}
+	return nil
+}
+
+// Listen opens the proxy listener; tproxy listeners must accept connections addressed
+// to other hosts.
+func Listen(addr, mode string) (net.Listener, error) {
+	if mode == ModeTProxy {
+		return listenTProxy(addr)
+	}
+	return net.Listen("tcp", addr)
+}
+
+// ConnContext returns an http.Server ConnContext hook that records each connection's
+// original destination. Connections made directly to the proxy port are left alone, so
+// they still go to --target.
+func ConnContext(mode string, proxyPort int) func(context.Context, net.Conn) context.Context {
+	return func(ctx context.Context, c net.Conn) context.Context {
+		var dst *net.TCPAddr
+		var err error
+		if mode == ModeTProxy {
+			dst, _ = c.LocalAddr().(*net.TCPAddr)
+		} else {
+			dst, err = originalDst(c)
+		}
+		if err != nil {
+			logging.Warn("original_destination_unknown", logging.Fields{Component: "transparent", Error: err.Error()})
+			return ctx
+		}
+		if dst == nil || dst.Port == proxyPort {
+			return ctx
+		}
+		return context.WithValue(ctx, ctxKey{}, net.JoinHostPort(dst.IP.String(), strconv.Itoa(dst.Port)))
+	}
+}
+
+// OriginalDst returns the host:port a steered request was originally sent to.
+func OriginalDst(ctx context.Context) (string, bool) {
+	dst, ok := ctx.Value(ctxKey{}).(string)
+	return dst, ok
+}
+
+// Rewrite points a proxied request at its original destination, if it was steered. It
+// runs after the reverse proxy's director, which has already applied --target.
+func Rewrite(req *http.Request) {
+	if dst, ok := OriginalDst(req.Context()); ok {
+		req.URL.Scheme = "http"
+		req.URL.Host = dst
+	}
+}
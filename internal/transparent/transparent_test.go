@@ -0,0 +1,104 @@
+package transparent
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestRulesValidate(t *testing.T) {
+	bad := []Rules{
+		{Mode: "dnat", ProxyPort: 9999, Ports: []int{8080}, UID: 1000, ExcludeUID: -1},
+		{Mode: ModeRedirect, ProxyPort: 9999, Ports: []int{8080}, UID: -1, ExcludeUID: -1},
+		{Mode: ModeRedirect, ProxyPort: 9999, Ports: []int{9999}, UID: 1000, ExcludeUID: -1},
+		{Mode: ModeRedirect, ProxyPort: 9999, UID: 1000, ExcludeUID: -1},
+		{Mode: ModeTProxy, ProxyPort: 9999, Ports: []int{8080}, UID: 1000, ExcludeUID: -1},
+	}
+	for i, r := range bad {
+		if r.Validate() == nil {
+			t.Errorf("case %d: %+v should be rejected", i, r)
+		}
+	}
+}
+
+func TestIptablesRedirect(t *testing.T) {
+	r := Rules{Mode: ModeRedirect, ProxyPort: 9999, Ports: []int{8080, 3000}, UID: -1, ExcludeUID: 1337}
+	cmds, err := r.Install()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cmds) != 6 {
+		t.Fatalf("got %d commands, want 3 per address family", len(cmds))
+	}
+	want := "iptables -t nat -A LOGRYPH -p tcp -m multiport --dports 8080,3000 -m owner ! --uid-owner 1337 -j REDIRECT --to-ports 9999"
+	if got := cmds[1].String(); got != want {
+		t.Errorf("rule =\n%s\nwant\n%s", got, want)
+	}
+	if got := cmds[2].String(); got != "iptables -t nat -A OUTPUT -j LOGRYPH" {
+		t.Errorf("hook = %s", got)
+	}
+	remove := r.Remove()
+	if len(remove) != 6 || remove[0].String() != "iptables -t nat -D OUTPUT -j LOGRYPH" {
+		t.Errorf("remove = %v", remove)
+	}
+}
+
+func TestNftTProxy(t *testing.T) {
+	r := Rules{Mode: ModeTProxy, ProxyPort: 9999, Ports: []int{8080}, UID: -1, ExcludeUID: -1, Nftables: true}
+	cmds, err := r.Install()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cmds[0].Args[0] != "nft" || !strings.Contains(cmds[0].Stdin, "tcp dport { 8080 } meta mark set 0x1 tproxy to :9999 accept") {
+		t.Errorf("nft script = %q", cmds[0].Stdin)
+	}
+	if got := cmds[1].String(); got != "ip rule add fwmark 0x1 lookup 100" {
+		t.Errorf("policy routing = %s", got)
+	}
+	if got := r.Remove()[0].String(); got != "nft delete table inet logryph" {
+		t.Errorf("remove = %s", got)
+	}
+}
+
+// connPair returns the server side of a loopback connection to ln.
+func connPair(t *testing.T, ln net.Listener) net.Conn {
+	t.Helper()
+	client, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = client.Close() })
+	server, err := ln.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = server.Close() })
+	return server
+}
+
+func TestConnContextRewrite(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	port := ln.Addr().(*net.TCPAddr).Port
+
+	// A connection made straight to the proxy port keeps going to --target.
+	for _, mode := range []string{ModeTProxy, ModeRedirect} {
+		ctx := ConnContext(mode, port)(context.Background(), connPair(t, ln))
+		if dst, ok := OriginalDst(ctx); ok {
+			t.Errorf("%s: direct connection rewritten to %s", mode, dst)
+		}
+	}
+
+	// Under TPROXY the local address is the original destination.
+	ctx := ConnContext(ModeTProxy, port+1)(context.Background(), connPair(t, ln))
+	req, _ := http.NewRequestWithContext(ctx, http.MethodPost, "http://target:8080/rpc", nil)
+	Rewrite(req)
+	if req.URL.Host != ln.Addr().String() || req.URL.Path != "/rpc" {
+		t.Errorf("rewritten URL = %s", req.URL)
+	}
+}
@@ -0,0 +1,212 @@
+// Package verifyserver serves verification of exported evidence over HTTP. It has no
+// ledger and no write path: callers post an export or a batch of events and get back a
+// verdict, so auditors can run it without being able to change anything.
+package verifyserver
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/slyt3/Logryph/internal/export"
+	"github.com/slyt3/Logryph/internal/ledger/audit"
+	"github.com/slyt3/Logryph/internal/logging"
+)
+
+// Endpoints served.
+const (
+	TaskPath   = "/verify/task"
+	EventsPath = "/verify/events"
+	HealthPath = "/healthz"
+)
+
+const (
+	defaultMaxBody = 32 << 20
+	maxEvents      = 1 << 20
+)
+
+// Verdict is the outcome of one verification. Valid is the answer; the rest says what
+// was checked, and where it failed when it did.
+type Verdict struct {
+	Valid            bool     `json:"valid"`
+	Kind             string   `json:"kind"` // task or events
+	RunID            string   `json:"run_id,omitempty"`
+	TaskID           string   `json:"task_id,omitempty"`
+	SignedBy         string   `json:"signed_by,omitempty"`
+	KeyPinned        bool     `json:"key_pinned"` // false: the export's own key was trusted
+	Events           int      `json:"events"`
+	Links            int      `json:"links,omitempty"`
+	FirstSeq         uint64   `json:"first_seq"`
+	LastSeq          uint64   `json:"last_seq"`
+	LastHash         string   `json:"last_hash,omitempty"`
+	FailedAtSeq      uint64   `json:"failed_at_seq,omitempty"`
+	AcknowledgedGaps []string `json:"acknowledged_gaps,omitempty"`
+	Error            string   `json:"error,omitempty"`
+}
+
+// Server verifies posted evidence. PubKey, when set, pins the ledger key every export
+// must be signed by; requests cannot override it.
+type Server struct {
+	PubKey  string
+	MaxBody int64 // largest request body accepted; zero means 32 MiB
+}
+
+// Handler returns the verification endpoints. Anything else is not found, and only the
+// methods listed are allowed.
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc(TaskPath, s.handleTask)
+	mux.HandleFunc(EventsPath, s.handleEvents)
+	mux.HandleFunc(HealthPath, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte("ok\n"))
+	})
+	return mux
+}
+
+// handleTask verifies a task export, posted as the evidence bag (ZIP) or the bundle JSON.
+func (s *Server) handleTask(w http.ResponseWriter, r *http.Request) {
+	body, key, ok := s.openRequest(w, r)
+	if !ok {
+		return
+	}
+	v := Verdict{Kind: "task", KeyPinned: key != ""}
+	// An evidence bag is a ZIP, which is read from the end, so the export is read whole.
+	data, err := io.ReadAll(body)
+	if err != nil {
+		writeReadError(w, v, fmt.Errorf("reading request: %w", err))
+		return
+	}
+	b, err := export.DecodeTaskBundle(data)
+	if err != nil {
+		v.Error = err.Error()
+		writeVerdict(w, http.StatusBadRequest, v)
+		return
+	}
+	v.RunID, v.TaskID, v.SignedBy = b.RunID, b.TaskID, b.PubKey
+	v.Events, v.Links = len(b.Events), len(b.Links)
+	if len(b.Events) > 0 {
+		v.FirstSeq, v.LastSeq = b.Events[0].SeqIndex, b.Events[len(b.Events)-1].SeqIndex
+		v.LastHash = b.Events[len(b.Events)-1].CurrentHash
+	}
+	if err := export.VerifyTask(b, key); err != nil {
+		v.Error = err.Error()
+	} else {
+		v.Valid = true
+	}
+	for gap := range audit.AcknowledgedGaps(b.Gaps) {
+		v.AcknowledgedGaps = append(v.AcknowledgedGaps, gap.String())
+	}
+	sort.Strings(v.AcknowledgedGaps)
+	logVerdict(v)
+	writeVerdict(w, http.StatusOK, v)
+}
+
+// handleEvents verifies a stretch of one run, posted as JSON Lines (a jsonl export) or a
+// JSON array. Without a pinned key the request names it with ?pubkey=; ?after_seq= and
+// ?after_hash= give the event before the stretch when it does not start the run. The
+// body is verified as it is decoded, a page at a time.
+func (s *Server) handleEvents(w http.ResponseWriter, r *http.Request) {
+	body, key, ok := s.openRequest(w, r)
+	if !ok {
+		return
+	}
+	v := Verdict{Kind: "events", KeyPinned: s.PubKey != ""}
+	if key == "" {
+		v.Error = "pubkey is required"
+		writeVerdict(w, http.StatusBadRequest, v)
+		return
+	}
+	head, err := afterCheckpoint(r)
+	if err != nil {
+		v.Error = err.Error()
+		writeVerdict(w, http.StatusBadRequest, v)
+		return
+	}
+	st, res, err := export.VerifyStream(body, head, key, maxEvents)
+	if err != nil {
+		writeReadError(w, v, err)
+		return
+	}
+	v.RunID, v.SignedBy, v.Events = st.RunID, key, st.Events
+	v.FirstSeq, v.LastSeq, v.LastHash = st.FirstSeq, st.LastSeq, st.LastHash
+	v.Valid, v.Error, v.FailedAtSeq = res.Valid, res.ErrorMessage, res.FailedAtSeq
+	for i := 0; i < len(res.AcknowledgedGaps); i++ {
+		v.AcknowledgedGaps = append(v.AcknowledgedGaps, res.AcknowledgedGaps[i].String())
+	}
+	logVerdict(v)
+	writeVerdict(w, http.StatusOK, v)
+}
+
+// openRequest checks a POST, caps its body at the size limit and resolves the key to
+// verify against: the pinned key, else ?pubkey=. A request naming a different key than
+// the pinned one is refused.
+func (s *Server) openRequest(w http.ResponseWriter, r *http.Request) (io.Reader, string, bool) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return nil, "", false
+	}
+	key := s.PubKey
+	if asked := r.URL.Query().Get("pubkey"); asked != "" {
+		if key != "" && !strings.EqualFold(asked, key) {
+			writeVerdict(w, http.StatusForbidden, Verdict{Error: "this server only verifies against its pinned key"})
+			return nil, "", false
+		}
+		key = asked
+	}
+	limit := s.MaxBody
+	if limit <= 0 {
+		limit = defaultMaxBody
+	}
+	return http.MaxBytesReader(w, r.Body, limit), key, true
+}
+
+// writeReadError refuses a body that could not be read or decoded: 413 past the size
+// limit, 400 otherwise.
+func writeReadError(w http.ResponseWriter, v Verdict, err error) {
+	status := http.StatusBadRequest
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		status = http.StatusRequestEntityTooLarge
+	}
+	v.Error = err.Error()
+	writeVerdict(w, status, v)
+}
+
+// afterCheckpoint parses ?after_seq= and ?after_hash=, which must come together.
+func afterCheckpoint(r *http.Request) (*audit.Checkpoint, error) {
+	seq, hash := r.URL.Query().Get("after_seq"), r.URL.Query().Get("after_hash")
+	if seq == "" && hash == "" {
+		return nil, nil
+	}
+	n, err := strconv.ParseUint(seq, 10, 64)
+	if err != nil || hash == "" {
+		return nil, errors.New("after_seq and after_hash must be given together")
+	}
+	return &audit.Checkpoint{Seq: n, Hash: hash}, nil
+}
+
+func logVerdict(v Verdict) {
+	fields := logging.Fields{Component: "verify-server", RunID: v.RunID, TaskID: v.TaskID}
+	if v.Valid {
+		logging.Info("verification_passed", fields)
+		return
+	}
+	fields.Error = v.Error
+	logging.Warn("verification_failed", fields)
+}
+
+func writeVerdict(w http.ResponseWriter, status int, v Verdict) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,134 @@
+package verifyserver
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/slyt3/Logryph/internal/crypto"
+	"github.com/slyt3/Logryph/internal/export"
+	"github.com/slyt3/Logryph/internal/ledger/memstore"
+	"github.com/slyt3/Logryph/internal/ledger/memstore/memstoretest"
+	"github.com/slyt3/Logryph/internal/models"
+)
+
+func newRun(t *testing.T, events int) (*memstore.Store, *crypto.Signer) {
+	t.Helper()
+	r := memstoretest.NewRun(t)
+	r.RecordCalls(events, "a", "b")
+	return r.Store, r.Signer
+}
+
+func post(t *testing.T, h http.Handler, target string, body []byte) (int, Verdict) {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, target, bytes.NewReader(body)))
+	var v Verdict
+	if err := json.Unmarshal(rec.Body.Bytes(), &v); err != nil {
+		t.Fatalf("%s: decoding verdict %q: %v", target, rec.Body.String(), err)
+	}
+	return rec.Code, v
+}
+
+func TestVerifyEventsBatch(t *testing.T) {
+	mem, signer := newRun(t, 20)
+	var jsonl bytes.Buffer
+	if _, err := export.WriteJSONL(&jsonl, mem, "run-1", signer.GetPublicKey(), nil); err != nil {
+		t.Fatal(err)
+	}
+	h := (&Server{}).Handler()
+	key := "?pubkey=" + signer.GetPublicKey()
+
+	if code, v := post(t, h, EventsPath+key, jsonl.Bytes()); code != http.StatusOK || !v.Valid || v.Events != 20 || v.LastSeq != 19 {
+		t.Fatalf("intact export: %d %+v", code, v)
+	}
+	lines := strings.SplitAfter(jsonl.String(), "\n")
+	var prev models.Event
+	if err := json.Unmarshal([]byte(lines[9]), &prev); err != nil {
+		t.Fatal(err)
+	}
+	tail := []byte(strings.Join(lines[10:], ""))
+	target := fmt.Sprintf("%s%s&after_seq=%d&after_hash=%s", EventsPath, key, prev.SeqIndex, prev.CurrentHash)
+	if code, v := post(t, h, target, tail); code != http.StatusOK || !v.Valid || v.FirstSeq != 10 {
+		t.Fatalf("tail after its head: %d %+v", code, v)
+	}
+	target = fmt.Sprintf("%s%s&after_seq=8&after_hash=%s", EventsPath, key, prev.PrevHash)
+	if _, v := post(t, h, target, tail); v.Valid {
+		t.Error("a tail that does not follow after_hash should fail")
+	}
+
+	tampered := []byte(strings.Replace(jsonl.String(), `"i":5}`, `"i":55}`, 1))
+	if code, v := post(t, h, EventsPath+key, tampered); code != http.StatusOK || v.Valid || v.FailedAtSeq != 5 {
+		t.Errorf("tampered export: %d %+v", code, v)
+	}
+	if code, _ := post(t, h, EventsPath, jsonl.Bytes()); code != http.StatusBadRequest {
+		t.Errorf("a batch without a key should be a bad request, got %d", code)
+	}
+}
+
+func TestVerifyEventsStreamsPagesWithinTheBodyCap(t *testing.T) {
+	mem, signer := newRun(t, 2500)
+	var jsonl bytes.Buffer
+	if _, err := export.WriteJSONL(&jsonl, mem, "run-1", signer.GetPublicKey(), nil); err != nil {
+		t.Fatal(err)
+	}
+	key := "?pubkey=" + signer.GetPublicKey()
+	h := (&Server{}).Handler()
+	if code, v := post(t, h, EventsPath+key, jsonl.Bytes()); code != http.StatusOK || !v.Valid || v.Events != 2500 || v.LastSeq != 2499 {
+		t.Fatalf("export spanning three pages: %d %+v", code, v)
+	}
+	events, err := mem.GetEventsFrom("run-1", 0, 2500)
+	if err != nil {
+		t.Fatal(err)
+	}
+	array, err := json.Marshal(events)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if code, v := post(t, h, EventsPath+key, array); code != http.StatusOK || !v.Valid || v.Events != 2500 {
+		t.Errorf("JSON array: %d %+v", code, v)
+	}
+
+	small := (&Server{MaxBody: int64(jsonl.Len() / 2)}).Handler()
+	if code, v := post(t, small, EventsPath+key, jsonl.Bytes()); code != http.StatusRequestEntityTooLarge || v.Valid {
+		t.Errorf("a body over the cap: %d %+v", code, v)
+	}
+}
+
+func TestVerifyTaskAndPinnedKey(t *testing.T) {
+	mem, signer := newRun(t, 10)
+	b, err := export.BuildTask(mem, "run-1", "b", signer.GetPublicKey())
+	if err != nil {
+		t.Fatal(err)
+	}
+	bundle, err := json.Marshal(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if code, v := post(t, (&Server{}).Handler(), TaskPath, bundle); code != http.StatusOK || !v.Valid || v.KeyPinned || v.TaskID != "b" {
+		t.Fatalf("task export: %d %+v", code, v)
+	}
+
+	other, err := crypto.NewSigner(filepath.Join(t.TempDir(), "other.key"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	pinned := (&Server{PubKey: other.GetPublicKey()}).Handler()
+	if code, v := post(t, pinned, TaskPath, bundle); code != http.StatusOK || v.Valid || !v.KeyPinned {
+		t.Errorf("export signed by another key: %d %+v", code, v)
+	}
+	if code, _ := post(t, pinned, TaskPath+"?pubkey="+signer.GetPublicKey(), bundle); code != http.StatusForbidden {
+		t.Errorf("overriding the pinned key should be refused, got %d", code)
+	}
+
+	rec := httptest.NewRecorder()
+	pinned.ServeHTTP(rec, httptest.NewRequest(http.MethodPut, TaskPath, bytes.NewReader(bundle)))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("PUT: status %d, want 405", rec.Code)
+	}
+}
@@ -0,0 +1,118 @@
+package vql
+
+import "github.com/slyt3/Logryph/internal/models"
+
+// Env supplies field values. Names are lower case; nested segments are resolved by
+// walking maps in the returned value.
+type Env interface {
+	Field(name string) (interface{}, bool)
+}
+
+// MapEnv is an Env over a plain map, e.g. {"method": ..., "params": ...} for policy rules.
+type MapEnv map[string]interface{}
+
+// Field implements Env.
+func (m MapEnv) Field(name string) (interface{}, bool) {
+	v, ok := m[name]
+	return v, ok
+}
+
+// HistoryEnv is an Env that also holds the earlier calls of the same task, for history().
+type HistoryEnv interface {
+	Env
+	// History returns the earlier calls, most recent first.
+	History() []Env
+}
+
+// TaskEnv is a policy rule's MapEnv together with the earlier calls of the call's task,
+// most recent first.
+type TaskEnv struct {
+	MapEnv
+	Prior []Env
+}
+
+// History implements HistoryEnv.
+func (t TaskEnv) History() []Env {
+	return t.Prior
+}
+
+// PolicyFields are the fields a policy rule's when: expression may read, also inside
+// history().
+var PolicyFields = []string{"method", "params", "environment"}
+
+// EventFields are the fields of a ledger event available to queries.
+var EventFields = []string{
+	"id", "run_id", "seq", "timestamp", "actor", "type", "event_type", "method", "params",
+	"response", "task_id", "task_state", "parent_id", "policy_id", "risk", "risk_level",
+	"environment", "tags", "labels", "headers", "query", "correlation_id", "trace_id",
+	"span_id", "blocked",
+}
+
+type eventEnv struct {
+	e *models.Event
+}
+
+// EventEnv exposes a ledger event to expressions.
+func EventEnv(e *models.Event) Env {
+	return eventEnv{e: e}
+}
+
+// Field implements Env.
+func (env eventEnv) Field(name string) (interface{}, bool) {
+	e := env.e
+	if e == nil {
+		return nil, false
+	}
+	switch name {
+	case "id":
+		return e.ID, true
+	case "run_id":
+		return e.RunID, true
+	case "seq":
+		return e.SeqIndex, true
+	case "timestamp":
+		return e.Timestamp, true
+	case "actor":
+		return e.Actor, true
+	case "type", "event_type":
+		return e.EventType, true
+	case "method":
+		return e.Method, true
+	case "params":
+		return e.Params, e.Params != nil
+	case "response":
+		if e.ResponseValue != nil {
+			return e.ResponseValue, true
+		}
+		return e.Response, e.Response != nil
+	case "task_id":
+		return e.TaskID, true
+	case "task_state":
+		return e.TaskState, true
+	case "parent_id":
+		return e.ParentID, true
+	case "policy_id":
+		return e.PolicyID, true
+	case "risk", "risk_level":
+		return e.RiskLevel, true
+	case "environment":
+		return e.Environment, true
+	case "tags":
+		return e.Tags, true
+	case "labels":
+		return e.Labels, e.Labels != nil
+	case "headers":
+		return e.Headers, e.Headers != nil
+	case "query":
+		return e.QueryParams, e.QueryParams != nil
+	case "correlation_id":
+		return e.CorrelationID, true
+	case "trace_id":
+		return e.TraceID, true
+	case "span_id":
+		return e.SpanID, true
+	case "blocked":
+		return e.WasBlocked, true
+	}
+	return nil, false
+}
@@ -0,0 +1,385 @@
+package vql
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/netip"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// evalBool reports whether env satisfies the expression. Each history() is counted
+// first, over the task's earlier calls; the expression inside holds no history() of its
+// own, so those evaluations need no counts.
+func evalBool(root *node, env Env, histories []*node) bool {
+	var counts map[*node]int
+	if len(histories) > 0 {
+		counts = make(map[*node]int, len(histories))
+		for i := 0; i < len(histories); i++ {
+			counts[histories[i]] = countHistory(histories[i], env)
+		}
+	}
+	return evalTree(root, env, counts)
+}
+
+// maxEvalSteps bounds evalTree: each node is visited at most three times, and a parsed
+// expression has at most one node per token.
+const maxEvalSteps = 3 * maxTokens
+
+// evalTree evaluates the tree with an explicit stack, short-circuiting and and or. A
+// frame's state counts the operands evaluated so far; result holds the last one.
+func evalTree(root *node, env Env, counts map[*node]int) bool {
+	type evalFrame struct {
+		n     *node
+		state int
+	}
+	stack := make([]evalFrame, 0, maxDepth)
+	stack = append(stack, evalFrame{n: root})
+	result := false
+	for step := 0; step < maxEvalSteps && len(stack) > 0; step++ {
+		top := len(stack) - 1
+		f := stack[top]
+		switch {
+		case f.n.kind != nodeAnd && f.n.kind != nodeOr && f.n.kind != nodeNot:
+			result = evalLeaf(f.n, env, counts)
+			stack = stack[:top]
+		case f.state == 0:
+			stack[top].state = 1
+			stack = append(stack, evalFrame{n: f.n.left})
+		case f.n.kind == nodeNot:
+			result = !result
+			stack = stack[:top]
+		case f.state == 1 && result == (f.n.kind == nodeAnd):
+			stack[top].state = 2
+			stack = append(stack, evalFrame{n: f.n.right})
+		default:
+			stack = stack[:top]
+		}
+	}
+	return result && len(stack) == 0
+}
+
+func evalLeaf(n *node, env Env, counts map[*node]int) bool {
+	switch n.kind {
+	case nodeCmp:
+		return compare(n.op, value(n.left, env, counts), n.right, env, counts)
+	case nodeHistory:
+		return counts[n] > 0
+	default:
+		b, ok := value(n, env, counts).(bool)
+		return ok && b
+	}
+}
+
+func value(n *node, env Env, counts map[*node]int) interface{} {
+	if n.kind == nodeLit {
+		return n.val
+	}
+	if n.kind == nodeHistory {
+		return float64(counts[n])
+	}
+	if n.kind != nodeField || env == nil {
+		return nil
+	}
+	v, ok := env.Field(strings.ToLower(n.path[0]))
+	for i := 1; ok && i < len(n.path); i++ {
+		switch m := v.(type) {
+		case map[string]interface{}:
+			v, ok = m[n.path[i]]
+		case map[string]string:
+			v, ok = m[n.path[i]]
+		default:
+			ok = false
+		}
+	}
+	if !ok {
+		return nil
+	}
+	return v
+}
+
+// countHistory is the number of the task's earlier calls that satisfy a history node's
+// expression. An env without history has none.
+func countHistory(n *node, env Env) int {
+	h, ok := env.(HistoryEnv)
+	if !ok {
+		return 0
+	}
+	prior := h.History()
+	count := 0
+	for i := 0; i < len(prior) && i < maxHistory; i++ {
+		if evalTree(n.left, prior[i], nil) {
+			count++
+		}
+	}
+	return count
+}
+
+func compare(op string, a interface{}, rn *node, env Env, counts map[*node]int) bool {
+	if op == "in" {
+		for i := 0; i < len(rn.list); i++ {
+			if equal(a, rn.list[i]) {
+				return true
+			}
+		}
+		return false
+	}
+	if op == "within" {
+		return within(a, rn.prefixes)
+	}
+	b := value(rn, env, counts)
+	switch op {
+	case "=":
+		return equal(a, b)
+	case "!=":
+		return !equal(a, b)
+	case "=~", "!~":
+		s, okA := scalarString(a)
+		pattern, okB := b.(string)
+		return okA && okB && glob(pattern, s) == (op == "=~")
+	case "contains":
+		return contains(a, b)
+	}
+	c, ok := order(a, b)
+	if !ok {
+		return false
+	}
+	switch op {
+	case "<":
+		return c < 0
+	case "<=":
+		return c <= 0
+	case ">":
+		return c > 0
+	case ">=":
+		return c >= 0
+	}
+	return false
+}
+
+func equal(a, b interface{}) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	if c, ok := orderTime(a, b); ok {
+		return c == 0
+	}
+	if isNumber(a) || isNumber(b) {
+		fa, okA := toFloat(a)
+		fb, okB := toFloat(b)
+		if okA && okB {
+			return fa == fb
+		}
+	}
+	if ba, ok := a.(bool); ok {
+		bb, ok := b.(bool)
+		return ok && ba == bb
+	}
+	sa, okA := scalarString(a)
+	sb, okB := scalarString(b)
+	return okA && okB && sa == sb
+}
+
+// order compares a and b as times, numbers (when either is a number) or strings.
+func order(a, b interface{}) (int, bool) {
+	if a == nil || b == nil {
+		return 0, false
+	}
+	if c, ok := orderTime(a, b); ok {
+		return c, true
+	}
+	if isNumber(a) || isNumber(b) {
+		fa, okA := toFloat(a)
+		fb, okB := toFloat(b)
+		if !okA || !okB || math.IsNaN(fa) || math.IsNaN(fb) {
+			return 0, false
+		}
+		switch {
+		case fa < fb:
+			return -1, true
+		case fa > fb:
+			return 1, true
+		}
+		return 0, true
+	}
+	sa, okA := a.(string)
+	sb, okB := b.(string)
+	if !okA || !okB {
+		return 0, false
+	}
+	return strings.Compare(sa, sb), true
+}
+
+// orderTime compares when either side is a time; the other must be an RFC 3339 time or
+// a YYYY-MM-DD date.
+func orderTime(a, b interface{}) (int, bool) {
+	ta, okA := toTime(a)
+	tb, okB := toTime(b)
+	_, aIsTime := a.(time.Time)
+	_, bIsTime := b.(time.Time)
+	if !(aIsTime || bIsTime) || !okA || !okB {
+		return 0, false
+	}
+	return ta.Compare(tb), true
+}
+
+func toTime(v interface{}) (time.Time, bool) {
+	switch t := v.(type) {
+	case time.Time:
+		return t, true
+	case string:
+		if parsed, err := time.Parse(time.RFC3339Nano, t); err == nil {
+			return parsed, true
+		}
+		if parsed, err := time.Parse("2006-01-02", t); err == nil {
+			return parsed, true
+		}
+	}
+	return time.Time{}, false
+}
+
+func contains(a, b interface{}) bool {
+	switch list := a.(type) {
+	case []string:
+		for i := 0; i < len(list); i++ {
+			if equal(list[i], b) {
+				return true
+			}
+		}
+	case []interface{}:
+		for i := 0; i < len(list); i++ {
+			if equal(list[i], b) {
+				return true
+			}
+		}
+	case map[string]interface{}:
+		key, ok := b.(string)
+		_, present := list[key]
+		return ok && present
+	case map[string]string:
+		key, ok := b.(string)
+		_, present := list[key]
+		return ok && present
+	case string:
+		sub, ok := scalarString(b)
+		return ok && strings.Contains(list, sub)
+	}
+	return false
+}
+
+// within reports whether a is an IP address inside one of the prefixes. IPv4-mapped IPv6
+// addresses match IPv4 ranges.
+func within(a interface{}, prefixes []netip.Prefix) bool {
+	s, ok := a.(string)
+	if !ok {
+		return false
+	}
+	addr, err := netip.ParseAddr(strings.TrimSpace(s))
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+	for i := 0; i < len(prefixes); i++ {
+		if prefixes[i].Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+func isNumber(v interface{}) bool {
+	switch v.(type) {
+	case float64, float32, int, int64, int32, uint, uint64, uint32, json.Number:
+		return true
+	}
+	return false
+}
+
+func toFloat(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case float32:
+		return float64(n), true
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case int32:
+		return float64(n), true
+	case uint:
+		return float64(n), true
+	case uint64:
+		return float64(n), true
+	case uint32:
+		return float64(n), true
+	case json.Number:
+		f, err := n.Float64()
+		return f, err == nil && finite(f)
+	case string:
+		// "NaN" and "Inf" parse, but an agent-supplied string must not order above or
+		// below every threshold.
+		f, err := strconv.ParseFloat(strings.TrimSpace(n), 64)
+		return f, err == nil && finite(f)
+	}
+	return 0, false
+}
+
+func finite(f float64) bool {
+	return !math.IsNaN(f) && !math.IsInf(f, 0)
+}
+
+// scalarString renders strings, numbers and bools; maps and lists have no string form.
+func scalarString(v interface{}) (string, bool) {
+	switch s := v.(type) {
+	case string:
+		return s, true
+	case bool:
+		return strconv.FormatBool(s), true
+	case time.Time:
+		return s.UTC().Format(time.RFC3339Nano), true
+	}
+	if f, ok := toFloat(v); ok && isNumber(v) {
+		return strconv.FormatFloat(f, 'f', -1, 64), true
+	}
+	if v == nil {
+		return "", false
+	}
+	if _, ok := v.(fmt.Stringer); ok {
+		return fmt.Sprint(v), true
+	}
+	return "", false
+}
+
+// glob matches s against pattern, where * matches any run of bytes and ? any one byte.
+func glob(pattern, s string) bool {
+	p, i := 0, 0
+	star, mark := -1, 0
+	for steps := 0; i < len(s) && steps < maxGlobSteps; steps++ {
+		switch {
+		case p < len(pattern) && (pattern[p] == '?' || pattern[p] == s[i]):
+			p++
+			i++
+		case p < len(pattern) && pattern[p] == '*':
+			star, mark = p, i
+			p++
+		case star >= 0:
+			p = star + 1
+			mark++
+			i = mark
+		default:
+			return false
+		}
+	}
+	if i < len(s) {
+		return false
+	}
+	for p < len(pattern) && pattern[p] == '*' {
+		p++
+	}
+	return p == len(pattern)
+}
+
+const maxGlobSteps = 1 << 20
@@ -0,0 +1,59 @@
+package vql
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestNonFiniteStringsAreNotNumbers(t *testing.T) {
+	expr, err := Compile(`params.amount > 1000 or params.amount < 0`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, v := range []string{"Inf", "-Infinity", "NaN", "1e999"} {
+		if expr.Eval(MapEnv{"params": map[string]interface{}{"amount": v}}) {
+			t.Errorf("amount %q compared as a number", v)
+		}
+	}
+	if got := FromConditions([]map[string]string{{"key": "amount", "operator": "gt", "value": "Inf"}}); got != "false" {
+		t.Errorf("FromConditions with an infinite threshold = %q, want false", got)
+	}
+}
+
+func FuzzEval(f *testing.F) {
+	f.Add(`method =~ "aws:*" and params.amount > 1000`, `{"amount":5000}`)
+	f.Add(`params["odd key"] in ("a", 1, null) or not params.x contains "y"`, `{"odd key":"a","x":["y"]}`)
+	f.Add(`params.n >= 1e308 and params.n < -1e308`, `{"n":"Infinity"}`)
+	f.Add(`params.n > 0`, `{"n":"NaN"}`)
+	f.Add("params[\"\xff\"] = \"\xfe\"", "{\"\xff\":\"\xfe\"}")
+	f.Add(`((((((((((((((((((((((((((((((((((true))))))))))))))))))))))))))))))))))`, `{}`)
+	f.Fuzz(func(t *testing.T, src, params string) {
+		expr, err := Compile(src)
+		if err != nil {
+			return
+		}
+		var p map[string]interface{}
+		_ = json.Unmarshal([]byte(params), &p)
+		env := MapEnv{"method": "aws:s3:list", "params": p, "environment": "prod"}
+		if expr.Eval(env) != expr.Eval(env) {
+			t.Fatalf("%q evaluated differently twice", src)
+		}
+	})
+}
+
+func FuzzFromConditions(f *testing.F) {
+	f.Add("amount", "gt", "1000")
+	f.Add("amount", "lte", "Inf")
+	f.Add("amount", "lt", "NaN")
+	f.Add("k\xff\"]", "eq", "v\x00")
+	f.Add("n", "gte", "1e999")
+	f.Fuzz(func(t *testing.T, key, op, value string) {
+		src := FromConditions([]map[string]string{{"key": key, "operator": op, "value": value}})
+		if src == "" || len(src) > maxSourceLen {
+			return
+		}
+		if _, err := Compile(src); err != nil {
+			t.Fatalf("FromConditions produced %q, which does not compile: %v", src, err)
+		}
+	})
+}
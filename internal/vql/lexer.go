@@ -0,0 +1,141 @@
+package vql
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokIdent
+	tokString
+	tokNumber
+	tokOp // = == != < <= > >= =~ !~
+	tokLParen
+	tokRParen
+	tokLBracket
+	tokRBracket
+	tokComma
+	tokDot
+)
+
+type token struct {
+	kind tokenKind
+	text string // identifier, operator, or the unquoted string
+	num  float64
+	pos  int
+}
+
+func isIdentStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isIdentPart(c byte) bool {
+	return isIdentStart(c) || (c >= '0' && c <= '9')
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+// lex splits src into tokens, ending with tokEOF.
+func lex(src string) ([]token, error) {
+	var toks []token
+	for i := 0; i < len(src); {
+		if len(toks) >= maxTokens {
+			return nil, fmt.Errorf("expression has more than %d tokens", maxTokens)
+		}
+		c := src[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '(' || c == ')' || c == '[' || c == ']' || c == ',' || c == '.':
+			toks = append(toks, token{kind: punctuation[c], text: string(c), pos: i})
+			i++
+		case c == '"' || c == '\'':
+			tok, n, err := lexString(src, i)
+			if err != nil {
+				return nil, err
+			}
+			toks = append(toks, tok)
+			i += n
+		case isDigit(c) || (c == '-' && i+1 < len(src) && isDigit(src[i+1])):
+			tok, n, err := lexNumber(src, i)
+			if err != nil {
+				return nil, err
+			}
+			toks = append(toks, tok)
+			i += n
+		case isIdentStart(c):
+			j := i + 1
+			for j < len(src) && isIdentPart(src[j]) {
+				j++
+			}
+			toks = append(toks, token{kind: tokIdent, text: src[i:j], pos: i})
+			i = j
+		default:
+			op := lexOp(src[i:])
+			if op == "" {
+				return nil, fmt.Errorf("unexpected %q at offset %d", c, i)
+			}
+			toks = append(toks, token{kind: tokOp, text: op, pos: i})
+			i += len(op)
+		}
+	}
+	return append(toks, token{kind: tokEOF, pos: len(src)}), nil
+}
+
+var punctuation = map[byte]tokenKind{
+	'(': tokLParen, ')': tokRParen, '[': tokLBracket, ']': tokRBracket, ',': tokComma, '.': tokDot,
+}
+
+// lexOp returns the comparison operator at the start of s, longest first.
+func lexOp(s string) string {
+	for _, op := range []string{"==", "!=", "<=", ">=", "=~", "!~", "=", "<", ">"} {
+		if strings.HasPrefix(s, op) {
+			return op
+		}
+	}
+	return ""
+}
+
+// lexString reads a quoted string. Double-quoted strings take Go escapes; single-quoted
+// strings are literal up to the next single quote.
+func lexString(src string, start int) (token, int, error) {
+	quote := src[start]
+	for j := start + 1; j < len(src); j++ {
+		if src[j] == '\\' && quote == '"' {
+			j++
+			continue
+		}
+		if src[j] != quote {
+			continue
+		}
+		raw := src[start : j+1]
+		text := raw[1 : len(raw)-1]
+		if quote == '"' {
+			var err error
+			if text, err = strconv.Unquote(raw); err != nil {
+				return token{}, 0, fmt.Errorf("invalid string at offset %d: %w", start, err)
+			}
+		}
+		return token{kind: tokString, text: text, pos: start}, len(raw), nil
+	}
+	return token{}, 0, fmt.Errorf("unterminated string at offset %d", start)
+}
+
+func lexNumber(src string, start int) (token, int, error) {
+	j := start + 1
+	for j < len(src) && (isDigit(src[j]) || src[j] == '.' || src[j] == 'e' || src[j] == 'E' ||
+		((src[j] == '+' || src[j] == '-') && (src[j-1] == 'e' || src[j-1] == 'E'))) {
+		j++
+	}
+	f, err := strconv.ParseFloat(src[start:j], 64)
+	if err != nil {
+		return token{}, 0, fmt.Errorf("invalid number %q at offset %d", src[start:j], start)
+	}
+	return token{kind: tokNumber, text: src[start:j], num: f, pos: start}, j - start, nil
+}
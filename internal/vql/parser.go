@@ -0,0 +1,364 @@
+package vql
+
+import (
+	"fmt"
+	"net/netip"
+	"strings"
+)
+
+type nodeKind int
+
+const (
+	nodeAnd nodeKind = iota
+	nodeOr
+	nodeNot
+	nodeCmp     // left op right
+	nodeField   // path into the environment
+	nodeLit     // string, float64, bool or nil
+	nodeList    // right-hand side of in and within
+	nodeHistory // history(left): the task's earlier calls that satisfy left
+)
+
+type node struct {
+	kind        nodeKind
+	op          string // nodeCmp: =, !=, <, <=, >, >=, =~, !~, in, contains, within
+	left, right *node
+	path        []string
+	val         interface{}
+	list        []interface{}
+	prefixes    []netip.Prefix // nodeList after within
+}
+
+type parser struct {
+	toks      []token
+	pos       int
+	depth     int
+	inHistory bool
+}
+
+func (p *parser) peek() token { return p.toks[p.pos] }
+
+func (p *parser) next() token {
+	t := p.toks[p.pos]
+	if t.kind != tokEOF {
+		p.pos++
+	}
+	return t
+}
+
+// keyword reports whether the next token is the case-insensitive identifier word.
+func (p *parser) keyword(word string) bool {
+	t := p.peek()
+	return t.kind == tokIdent && strings.EqualFold(t.text, word)
+}
+
+func (p *parser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("offset %d: %s", p.peek().pos, fmt.Sprintf(format, args...))
+}
+
+// frameKind is a construct the parser has opened and not yet closed.
+type frameKind int
+
+const (
+	frameNot          frameKind = iota // not, waiting for its operand
+	frameParen                         // (, waiting for )
+	frameHistory                       // history(, the left operand of a comparison
+	frameHistoryRight                  // history(, the right operand of cmp
+	frameAnd                           // left and, waiting for the right operand
+	frameOr                            // left or, waiting for the right operand
+)
+
+type frame struct {
+	kind frameKind
+	left *node // frameAnd, frameOr
+	cmp  *node // frameHistoryRight: the comparison waiting for its right operand
+	expr *node // frameHistoryRight: what the comparison parses to, cmp or not cmp
+}
+
+// maxParseSteps bounds the parse loop: each token opens, closes or reduces a bounded
+// number of frames.
+const maxParseSteps = 4 * maxTokens
+
+// parseExpr parses an expression with an explicit stack of open constructs: not binds
+// tightest, then and, then or, all left-associative. It stops before a token that cannot
+// continue the expression; Compile rejects anything left over.
+func (p *parser) parseExpr() (*node, error) {
+	stack := make([]frame, 0, maxDepth)
+	var cur *node // nil while an operand is expected
+	var err error
+	for step := 0; step < maxParseSteps; step++ {
+		if cur == nil {
+			if cur, stack, err = p.operand(stack); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		for len(stack) > 0 && stack[len(stack)-1].kind == frameNot {
+			cur = &node{kind: nodeNot, left: cur}
+			stack = stack[:len(stack)-1]
+			p.depth--
+		}
+		switch {
+		case p.keyword("and"), p.keyword("or"):
+			op := frameOr
+			if p.next(); strings.EqualFold(p.toks[p.pos-1].text, "and") {
+				op = frameAnd
+			}
+			cur, stack = reduce(stack, cur, op)
+			stack = append(stack, frame{kind: op, left: cur})
+			cur = nil
+		default:
+			if cur, stack = reduce(stack, cur, frameOr); len(stack) == 0 {
+				return cur, nil
+			}
+			if cur, stack, err = p.close(stack, cur); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return nil, p.errorf("expression longer than %d tokens", maxTokens)
+}
+
+// reduce folds the binary operators on top of the stack into cur: and frames before an
+// and, and both before an or, which binds loosest.
+func reduce(stack []frame, cur *node, op frameKind) (*node, []frame) {
+	for j := 0; j < maxTokens && len(stack) > 0; j++ {
+		top := stack[len(stack)-1]
+		if top.kind != frameAnd && (top.kind != frameOr || op == frameAnd) {
+			break
+		}
+		kind := nodeAnd
+		if top.kind == frameOr {
+			kind = nodeOr
+		}
+		cur = &node{kind: kind, left: top.left, right: cur}
+		stack = stack[:len(stack)-1]
+	}
+	return cur, stack
+}
+
+// operand reads the next operand: a comparison, or a lone operand tested for truth. A
+// leading not, ( or history( opens a frame instead, and the operand is nil.
+func (p *parser) operand(stack []frame) (*node, []frame, error) {
+	if p.depth+1 > maxDepth {
+		return nil, nil, p.errorf("expression nested deeper than %d", maxDepth)
+	}
+	if p.keyword("not") || p.peek().kind == tokLParen {
+		kind := frameParen
+		if p.next().kind != tokLParen {
+			kind = frameNot
+		}
+		p.depth++
+		return nil, append(stack, frame{kind: kind}), nil
+	}
+	left, err := p.parseOperand()
+	if err != nil {
+		return nil, nil, err
+	}
+	if left.kind == nodeHistory {
+		stack, err = p.openHistory(stack, frame{kind: frameHistory})
+		return nil, stack, err
+	}
+	return p.compare(left, stack)
+}
+
+// compare reads the rest of a comparison after its left operand. A history( right
+// operand opens a frame that completes the comparison at its ).
+func (p *parser) compare(left *node, stack []frame) (*node, []frame, error) {
+	expr, cmp, err := p.parseCmp(left)
+	if err != nil {
+		return nil, nil, err
+	}
+	if cmp == nil || cmp.right.kind != nodeHistory {
+		return expr, stack, nil
+	}
+	stack, err = p.openHistory(stack, frame{kind: frameHistoryRight, cmp: cmp, expr: expr})
+	return nil, stack, err
+}
+
+// openHistory consumes the ( after history. expr is evaluated against each earlier call
+// of the task, which has no history of its own, so history() does not nest.
+func (p *parser) openHistory(stack []frame, f frame) ([]frame, error) {
+	if p.inHistory {
+		return nil, p.errorf("history() cannot be nested")
+	}
+	p.next()
+	p.inHistory = true
+	p.depth++
+	return append(stack, f), nil
+}
+
+// close ends the innermost ( or history( at its ), returning the operand it forms.
+func (p *parser) close(stack []frame, cur *node) (*node, []frame, error) {
+	top := stack[len(stack)-1]
+	if p.peek().kind != tokRParen {
+		if top.kind == frameParen {
+			return nil, nil, p.errorf("expected )")
+		}
+		return nil, nil, p.errorf("expected ) after history expression")
+	}
+	p.next()
+	stack = stack[:len(stack)-1]
+	p.depth--
+	switch top.kind {
+	case frameHistory:
+		p.inHistory = false
+		return p.compare(&node{kind: nodeHistory, left: cur}, stack)
+	case frameHistoryRight:
+		p.inHistory = false
+		top.cmp.right = &node{kind: nodeHistory, left: cur}
+		return top.expr, stack, nil
+	}
+	return cur, stack, nil
+}
+
+// parseCmp reads [op operand] after the left operand. It returns the expression and the
+// comparison node, nil for a lone operand, which is tested for truth.
+func (p *parser) parseCmp(left *node) (*node, *node, error) {
+	negate := false
+	if p.keyword("not") {
+		p.next()
+		negate = true
+		if !p.keyword("in") && !p.keyword("contains") && !p.keyword("within") {
+			return nil, nil, p.errorf("expected in, contains or within after not")
+		}
+	}
+	var op string
+	switch t := p.peek(); {
+	case t.kind == tokOp:
+		op = t.text
+		if op == "==" {
+			op = "="
+		}
+	case p.keyword("in"), p.keyword("contains"), p.keyword("within"):
+		op = strings.ToLower(t.text)
+	default:
+		return left, nil, nil
+	}
+	p.next()
+	var right *node
+	var err error
+	switch op {
+	case "in":
+		right, err = p.parseList()
+	case "within":
+		right, err = p.parsePrefixes()
+	default:
+		right, err = p.parseOperand()
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	cmp := &node{kind: nodeCmp, op: op, left: left, right: right}
+	if negate {
+		return &node{kind: nodeNot, left: cmp}, cmp, nil
+	}
+	return cmp, cmp, nil
+}
+
+func (p *parser) parseOperand() (*node, error) {
+	t := p.next()
+	switch t.kind {
+	case tokString:
+		return &node{kind: nodeLit, val: t.text}, nil
+	case tokNumber:
+		return &node{kind: nodeLit, val: t.num}, nil
+	case tokIdent:
+		switch strings.ToLower(t.text) {
+		case "true":
+			return &node{kind: nodeLit, val: true}, nil
+		case "false":
+			return &node{kind: nodeLit, val: false}, nil
+		case "null":
+			return &node{kind: nodeLit, val: nil}, nil
+		case "and", "or", "not", "in", "contains", "within":
+			return nil, fmt.Errorf("offset %d: unexpected keyword %s", t.pos, t.text)
+		case "history":
+			// The caller opens history( and parses the expression inside.
+			if p.peek().kind == tokLParen {
+				return &node{kind: nodeHistory}, nil
+			}
+		}
+		return p.parsePath(t.text)
+	}
+	return nil, fmt.Errorf("offset %d: expected a field or value", t.pos)
+}
+
+// parsePath reads the rest of a field path: .name or ["any key"] segments.
+func (p *parser) parsePath(first string) (*node, error) {
+	path := []string{first}
+	for i := 0; i < maxPathLen; i++ {
+		switch p.peek().kind {
+		case tokDot:
+			p.next()
+			t := p.next()
+			if t.kind != tokIdent {
+				return nil, fmt.Errorf("offset %d: expected a name after .", t.pos)
+			}
+			path = append(path, t.text)
+		case tokLBracket:
+			p.next()
+			t := p.next()
+			if t.kind != tokString || p.next().kind != tokRBracket {
+				return nil, fmt.Errorf("offset %d: expected [\"key\"]", t.pos)
+			}
+			path = append(path, t.text)
+		default:
+			return &node{kind: nodeField, path: path}, nil
+		}
+	}
+	return nil, p.errorf("field path longer than %d segments", maxPathLen)
+}
+
+// parseList reads ("a", 1, ...) after in.
+func (p *parser) parseList() (*node, error) {
+	if p.next().kind != tokLParen {
+		return nil, p.errorf("expected ( after in")
+	}
+	list := &node{kind: nodeList}
+	for i := 0; i < maxListLen; i++ {
+		item, err := p.parseOperand()
+		if err != nil {
+			return nil, err
+		}
+		if item.kind != nodeLit {
+			return nil, p.errorf("in lists hold values, not fields")
+		}
+		list.list = append(list.list, item.val)
+		switch p.next().kind {
+		case tokComma:
+			continue
+		case tokRParen:
+			return list, nil
+		default:
+			return nil, p.errorf("expected , or ) in list")
+		}
+	}
+	return nil, p.errorf("list longer than %d values", maxListLen)
+}
+
+// parsePrefixes reads the CIDR ranges after within: one string, or a list of them.
+func (p *parser) parsePrefixes() (*node, error) {
+	list := &node{kind: nodeList}
+	if p.peek().kind == tokString {
+		list.list = []interface{}{p.next().text}
+	} else {
+		parsed, err := p.parseList()
+		if err != nil {
+			return nil, err
+		}
+		list.list = parsed.list
+	}
+	for i := 0; i < len(list.list); i++ {
+		s, ok := list.list[i].(string)
+		if !ok {
+			return nil, p.errorf("within takes CIDR ranges, got %v", list.list[i])
+		}
+		prefix, err := netip.ParsePrefix(strings.TrimSpace(s))
+		if err != nil {
+			return nil, p.errorf("invalid CIDR range %q", s)
+		}
+		list.prefixes = append(list.prefixes, prefix.Masked())
+	}
+	return list, nil
+}
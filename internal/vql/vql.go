@@ -0,0 +1,274 @@
+// Package vql implements the Logryph event query language, a small boolean expression
+// language shared by policy rules (when:), logyctl events --where and /api/events:
+//
+//	method =~ "aws:*" and params.amount > 1000 and risk in ("high", "critical")
+//
+// Operands are fields (method, params.amount, params["odd key"]) or literals (strings,
+// numbers, true, false, null). Operators are = (or ==), !=, <, <=, >, >=, =~ and !~ (glob
+// with * and ?), in (...), contains and within (CIDR ranges, for IP addresses), combined
+// with and, or, not and parentheses. A missing field is null: it equals only null and
+// fails every ordering.
+//
+// In policy rules, history(expr) counts the earlier calls of the same task that satisfy
+// expr: alone it is true when there is at least one, and it compares as a number, e.g.
+//
+//	method = "database:delete" and history(method = "fs:read" and params.path = "/etc/passwd")
+//	history(method =~ "fs:*") >= 20
+package vql
+
+import (
+	"fmt"
+	"math"
+	"net/netip"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/slyt3/Logryph/internal/models"
+)
+
+const (
+	maxSourceLen = 4096
+	maxTokens    = 1024
+	maxDepth     = 32
+	maxPathLen   = 16
+	maxListLen   = 256
+	maxCached    = 1024
+	maxHistory   = 1024
+)
+
+// Expr is a compiled expression. It is immutable and safe for concurrent use.
+type Expr struct {
+	src       string
+	root      *node
+	histories []*node // the history() nodes, counted before each evaluation
+}
+
+// Compile parses src.
+func Compile(src string) (*Expr, error) {
+	if len(src) > maxSourceLen {
+		return nil, fmt.Errorf("expression longer than %d bytes", maxSourceLen)
+	}
+	if strings.TrimSpace(src) == "" {
+		return nil, fmt.Errorf("expression is empty")
+	}
+	toks, err := lex(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{toks: toks}
+	root, err := p.parseExpr()
+	if err != nil {
+		return nil, err
+	}
+	if p.peek().kind != tokEOF {
+		return nil, p.errorf("unexpected %q", p.peek().text)
+	}
+	e := &Expr{src: src, root: root}
+	all := nodes(root)
+	for i := 0; i < len(all); i++ {
+		if all[i].kind == nodeHistory {
+			e.histories = append(e.histories, all[i])
+		}
+	}
+	return e, nil
+}
+
+var (
+	cache     sync.Map // source -> *Expr
+	cacheSize atomic.Int64
+)
+
+// Cached compiles src once and reuses the result, for expressions evaluated per request.
+// Past a fixed number of distinct sources, further ones are compiled without caching.
+func Cached(src string) (*Expr, error) {
+	if e, ok := cache.Load(src); ok {
+		return e.(*Expr), nil
+	}
+	e, err := Compile(src)
+	if err != nil {
+		return nil, err
+	}
+	if cacheSize.Add(1) <= maxCached {
+		cache.Store(src, e)
+	}
+	return e, nil
+}
+
+// CompileQuery compiles an event query and rejects fields events do not have.
+func CompileQuery(src string) (*Expr, error) {
+	expr, err := Cached(src)
+	if err != nil {
+		return nil, err
+	}
+	if err := expr.CheckFields(EventFields); err != nil {
+		return nil, err
+	}
+	if expr.UsesHistory() {
+		return nil, fmt.Errorf("history() is only available in policy rules")
+	}
+	return expr, nil
+}
+
+// Filter returns the last limit events that satisfy the expression, in their original
+// order. A limit of zero or less keeps every match.
+func (e *Expr) Filter(events []models.Event, limit int) []models.Event {
+	var matched []models.Event
+	for i := 0; i < len(events); i++ {
+		if e.Eval(EventEnv(&events[i])) {
+			matched = append(matched, events[i])
+		}
+	}
+	if limit > 0 && len(matched) > limit {
+		matched = matched[len(matched)-limit:]
+	}
+	return matched
+}
+
+// String returns the source the expression was compiled from.
+func (e *Expr) String() string {
+	return e.src
+}
+
+// Eval reports whether env satisfies the expression.
+func (e *Expr) Eval(env Env) bool {
+	return evalBool(e.root, env, e.histories)
+}
+
+// Fields returns the distinct top-level field names the expression reads, sorted.
+func (e *Expr) Fields() []string {
+	seen := make(map[string]bool)
+	all := nodes(e.root)
+	for i := 0; i < len(all); i++ {
+		if all[i].kind == nodeField {
+			seen[strings.ToLower(all[i].path[0])] = true
+		}
+	}
+	names := make([]string, 0, len(seen))
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// UsesHistory reports whether the expression reads the task's earlier calls.
+func (e *Expr) UsesHistory() bool {
+	return len(e.histories) > 0
+}
+
+// CheckFields returns an error naming the first field not in allowed.
+func (e *Expr) CheckFields(allowed []string) error {
+	fields := e.Fields()
+	for i := 0; i < len(fields); i++ {
+		if !containsFold(allowed, fields[i]) {
+			return fmt.Errorf("unknown field %q (known: %s)", fields[i], strings.Join(allowed, ", "))
+		}
+	}
+	return nil
+}
+
+// nodes lists the expression's nodes, parents before their operands. A parsed expression
+// has at most one node per token, and the walk stops there.
+func nodes(root *node) []*node {
+	var out []*node
+	stack := []*node{root}
+	for i := 0; i < 2*maxTokens && len(stack) > 0; i++ {
+		n := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if n == nil {
+			continue
+		}
+		out = append(out, n)
+		stack = append(stack, n.right, n.left)
+	}
+	return out
+}
+
+func containsFold(list []string, s string) bool {
+	for i := 0; i < len(list); i++ {
+		if strings.EqualFold(list[i], s) {
+			return true
+		}
+	}
+	return false
+}
+
+// FromConditions translates the legacy conditions list ({key, operator, value} maps with
+// eq, gt, lt, gte, lte, in, not_in and cidr) into an expression over params. in, not_in
+// and cidr take a comma-separated value, optionally in brackets: "[us-east-1, eu-west-1]"
+// or "10.0.0.0/8, 192.168.0.0/16". not_in needs the key present. Unknown operators are
+// skipped, as before; an ordering against a non-numeric value or an invalid range never
+// matches. It returns "" when nothing is left to check.
+func FromConditions(conditions []map[string]string) string {
+	ops := map[string]string{"eq": "=", "gt": ">", "lt": "<", "gte": ">=", "lte": "<=", "in": "in", "not_in": "not in", "cidr": "within"}
+	var parts []string
+	for i := 0; i < len(conditions) && i < maxListLen; i++ {
+		c := conditions[i]
+		op, ok := ops[c["operator"]]
+		if !ok {
+			continue
+		}
+		field := "params[" + strconv.Quote(c["key"]) + "]"
+		switch op {
+		case "=":
+			parts = append(parts, field+" = "+strconv.Quote(c["value"]))
+			continue
+		case "in", "not in", "within":
+			parts = append(parts, listCondition(field, op, c["value"]))
+			continue
+		}
+		f, err := strconv.ParseFloat(c["value"], 64)
+		if err != nil || math.IsNaN(f) || math.IsInf(f, 0) {
+			parts = append(parts, "false")
+			continue
+		}
+		parts = append(parts, field+" "+op+" "+strconv.FormatFloat(f, 'g', -1, 64))
+	}
+	return strings.Join(parts, " and ")
+}
+
+// listCondition renders field op (values...) for a comma-separated condition value. An
+// empty list, or a cidr list with an invalid range, renders as false.
+func listCondition(field, op, value string) string {
+	value = strings.TrimSpace(value)
+	value = strings.TrimSuffix(strings.TrimPrefix(value, "["), "]")
+	var quoted []string
+	items := strings.Split(value, ",")
+	for i := 0; i < len(items) && i < maxListLen; i++ {
+		item := strings.Trim(strings.TrimSpace(items[i]), `"'`)
+		if item == "" {
+			continue
+		}
+		if op == "within" {
+			if _, err := netip.ParsePrefix(item); err != nil {
+				return "false"
+			}
+		}
+		quoted = append(quoted, strconv.Quote(item))
+	}
+	if len(quoted) == 0 {
+		return "false"
+	}
+	src := field + " " + op + " (" + strings.Join(quoted, ", ") + ")"
+	if op == "not in" {
+		return "(" + field + " != null and " + src + ")"
+	}
+	return src
+}
+
+// And joins non-empty expression sources with and.
+func And(srcs ...string) string {
+	var parts []string
+	for i := 0; i < len(srcs); i++ {
+		if strings.TrimSpace(srcs[i]) != "" {
+			parts = append(parts, "("+srcs[i]+")")
+		}
+	}
+	if len(parts) == 1 {
+		return strings.TrimSuffix(strings.TrimPrefix(parts[0], "("), ")")
+	}
+	return strings.Join(parts, " and ")
+}
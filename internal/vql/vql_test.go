@@ -0,0 +1,210 @@
+package vql
+
+import (
+	"testing"
+	"time"
+
+	"github.com/slyt3/Logryph/internal/models"
+)
+
+func TestEvalEvent(t *testing.T) {
+	e := &models.Event{
+		ID: "abc12345", EventType: "tool_call", Method: "aws:s3:PutObject", RiskLevel: "high",
+		Timestamp: time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC),
+		Params: map[string]interface{}{
+			"amount": 1500.0, "currency": "usd", "limit": "250",
+			"target": map[string]interface{}{"bucket": "prod-logs"}, "odd.key": true,
+			"target_ip": "10.4.2.1", "peer": "::ffff:192.168.1.9",
+		},
+		Tags:   []string{"schema_violation"},
+		Labels: map[string]string{"team": "payments"},
+	}
+	tests := []struct {
+		expr string
+		want bool
+	}{
+		{`method =~ "aws:*" and params.amount > 1000 and risk in ("high", "critical")`, true},
+		{`method =~ "stripe:*" or params.amount < 1000`, false},
+		{`method !~ "aws:?3:*"`, false},
+		{`params.amount == 1500 and params.amount >= 1500.0 and params.amount != 1`, true},
+		{`params.limit < 300`, true}, // numeric string against a number
+		{`params.target.bucket = "prod-logs"`, true},
+		{`params["odd.key"]`, true},
+		{`params.missing = null and not (params.missing > 0) and params.missing != "x"`, true},
+		{`tags contains "schema_violation" and labels.team = 'payments'`, true},
+		{`tags not contains "secret_detected" and risk not in ("low")`, true},
+		{`params contains "currency" and params.currency contains "us"`, true},
+		{`timestamp > "2026-02-28" and timestamp < "2026-03-01T12:00:01Z"`, true},
+		{`NOT type = "tool_response" AND (risk = "low" OR id = "abc12345")`, true},
+		{`blocked or seq > 0`, false},
+		{`risk = "high" or risk = "low" and blocked`, true}, // and binds tighter than or
+		{`not not (risk = "high") and not blocked`, true},
+		{`params.target_ip within "10.0.0.0/8" and params.peer within ("172.16.0.0/12", "192.168.0.0/16")`, true},
+		{`params.target_ip not within ("10.4.0.0/16") or params.amount within "10.0.0.0/8"`, false},
+	}
+	for _, tt := range tests {
+		expr, err := Compile(tt.expr)
+		if err != nil {
+			t.Errorf("%s: %v", tt.expr, err)
+			continue
+		}
+		if got := expr.Eval(EventEnv(e)); got != tt.want {
+			t.Errorf("%s = %v, want %v", tt.expr, got, tt.want)
+		}
+		if err := expr.CheckFields(EventFields); err != nil {
+			t.Errorf("%s: %v", tt.expr, err)
+		}
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	for _, src := range []string{
+		``, `method =`, `method = "unterminated`, `(method = "a"`, `risk in "high"`,
+		`risk in (method)`, `a = 1 b = 2`, `method & 1`, `params.`, `params[1]`, `not`,
+		`a not = 1`, `and = 1`, `ip within "10.0.0.0/33"`, `ip within (1)`, `ip within x`,
+	} {
+		if _, err := Compile(src); err == nil {
+			t.Errorf("%q should not compile", src)
+		}
+	}
+	deep := ""
+	for i := 0; i < maxDepth+1; i++ {
+		deep += "("
+	}
+	if _, err := Compile(deep + "a"); err == nil {
+		t.Error("nesting past the depth limit should not compile")
+	}
+}
+
+func TestCheckFields(t *testing.T) {
+	expr, err := Compile(`mehtod = "x" or params.a = 1`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := expr.CheckFields(PolicyFields); err == nil {
+		t.Error("a misspelt field should be reported")
+	}
+}
+
+func TestHistory(t *testing.T) {
+	env := TaskEnv{
+		MapEnv: MapEnv{"method": "database:delete", "params": map[string]interface{}{"table": "users"}},
+		Prior: []Env{
+			MapEnv{"method": "fs:read", "params": map[string]interface{}{"path": "/etc/passwd"}},
+			MapEnv{"method": "fs:read", "params": map[string]interface{}{"path": "/tmp/a"}},
+			MapEnv{"method": "http:get", "params": map[string]interface{}{}},
+		},
+	}
+	tests := []struct {
+		expr string
+		want bool
+	}{
+		{`method = "database:delete" and history(method = "fs:read" and params.path = "/etc/passwd")`, true},
+		{`history(method = "fs:write")`, false},
+		{`history(method =~ "fs:*") = 2 and history(method =~ "fs:*") >= 2`, true},
+		{`not history(params.path contains "shadow")`, true},
+		{`history(method = "fs:read") > 2`, false},
+		{`2 = history(method = "fs:read") and (history(method = "http:get"))`, true},
+	}
+	for _, tt := range tests {
+		expr, err := Compile(tt.expr)
+		if err != nil {
+			t.Errorf("%s: %v", tt.expr, err)
+			continue
+		}
+		if got := expr.Eval(env); got != tt.want {
+			t.Errorf("%s = %v, want %v", tt.expr, got, tt.want)
+		}
+		if !expr.UsesHistory() {
+			t.Errorf("%s should report reading history", tt.expr)
+		}
+	}
+	// Outside a task, or in event queries, there is no history to read.
+	if expr, _ := Compile(`history(method = "fs:read")`); expr.Eval(env.MapEnv) {
+		t.Error("an env without history has no earlier calls")
+	}
+	if _, err := CompileQuery(`history(method = "fs:read")`); err == nil {
+		t.Error("event queries should refuse history()")
+	}
+	if _, err := Compile(`history(history(method = "a"))`); err == nil {
+		t.Error("history() should not nest")
+	}
+	if expr, err := Compile(`params.history = 1`); err != nil || expr.UsesHistory() {
+		t.Errorf("a field named history is still a field: %v", err)
+	}
+}
+
+func TestFromConditions(t *testing.T) {
+	src := FromConditions([]map[string]string{
+		{"key": "amount", "operator": "gt", "value": "100"},
+		{"key": "mode \"x\"", "operator": "eq", "value": "live"},
+		{"key": "ignored", "operator": "regex", "value": ".*"},
+	})
+	if src != `params["amount"] > 100 and params["mode \"x\""] = "live"` {
+		t.Fatalf("unexpected translation: %s", src)
+	}
+	expr, err := Compile(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	env := MapEnv{"params": map[string]interface{}{"amount": 200, "mode \"x\"": "live"}}
+	if !expr.Eval(env) {
+		t.Error("translated conditions should match")
+	}
+	if FromConditions([]map[string]string{{"key": "a", "operator": "gt", "value": "many"}}) != "false" {
+		t.Error("an ordering against a non-number must never match")
+	}
+	lists := FromConditions([]map[string]string{
+		{"key": "region", "operator": "not_in", "value": "[us-east-1, eu-west-1]"},
+		{"key": "tier", "operator": "in", "value": `"gold", silver`},
+		{"key": "target_ip", "operator": "cidr", "value": "10.0.0.0/8,192.168.0.0/16"},
+	})
+	expr, err = Compile(lists)
+	if err != nil {
+		t.Fatalf("%s: %v", lists, err)
+	}
+	for _, tt := range []struct {
+		params map[string]interface{}
+		want   bool
+	}{
+		{map[string]interface{}{"region": "ap-south-1", "tier": "silver", "target_ip": "192.168.3.4"}, true},
+		{map[string]interface{}{"region": "eu-west-1", "tier": "silver", "target_ip": "192.168.3.4"}, false},
+		{map[string]interface{}{"tier": "gold", "target_ip": "10.1.1.1"}, false}, // not_in needs the key
+		{map[string]interface{}{"region": "ap-south-1", "tier": "bronze", "target_ip": "10.1.1.1"}, false},
+		{map[string]interface{}{"region": "ap-south-1", "tier": "gold", "target_ip": "8.8.8.8"}, false},
+	} {
+		if got := expr.Eval(MapEnv{"params": tt.params}); got != tt.want {
+			t.Errorf("%s with %v = %v, want %v", lists, tt.params, got, tt.want)
+		}
+	}
+	for _, bad := range []map[string]string{
+		{"key": "ip", "operator": "cidr", "value": "10.0.0.0/8, not-a-range"},
+		{"key": "region", "operator": "in", "value": "[]"},
+	} {
+		if FromConditions([]map[string]string{bad}) != "false" {
+			t.Errorf("%v should never match", bad)
+		}
+	}
+	if And("", "a = 1", " ") != "a = 1" || And("a = 1", "b or c") != "(a = 1) and (b or c)" {
+		t.Error("And should join only non-empty sources")
+	}
+}
+
+func TestGlob(t *testing.T) {
+	tests := []struct {
+		pattern, s string
+		want       bool
+	}{
+		{"aws:*", "aws:s3", true},
+		{"*:delete*", "db:deleteRows", true},
+		{"a?c", "abc", true},
+		{"a?c", "ac", false},
+		{"*", "", true},
+		{"exact", "exactly", false},
+	}
+	for _, tt := range tests {
+		if got := glob(tt.pattern, tt.s); got != tt.want {
+			t.Errorf("glob(%q, %q) = %v", tt.pattern, tt.s, got)
+		}
+	}
+}
@@ -0,0 +1,284 @@
+package worm
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/slyt3/Logryph/internal/assert"
+	"github.com/slyt3/Logryph/internal/ledger/audit"
+)
+
+const maxArchiveObjects = 1000000
+
+// Object is one stored version of a key in an archive.
+type Object struct {
+	Key       string
+	VersionID string
+}
+
+// Archive is a Target whose objects can be listed, so its segments can be verified
+// without the ledger that recorded them.
+type Archive interface {
+	Target
+	// List returns every version of every key under prefix, oldest version first
+	// within a key.
+	List(prefix string) ([]Object, error)
+}
+
+// OpenArchive opens an archive from a URI: s3://bucket/prefix, or dir:/path (or a plain
+// path) for a directory. It returns the key prefix to list. For S3, c supplies the region,
+// endpoint and credentials; credentials fall back to the standard AWS variables.
+func OpenArchive(uri string, c Config) (Archive, string, error) {
+	if rest, ok := strings.CutPrefix(uri, "s3://"); ok {
+		bucket, prefix, _ := strings.Cut(rest, "/")
+		if bucket == "" {
+			return nil, "", fmt.Errorf("archive %q names no bucket", uri)
+		}
+		if prefix != "" && !validKey(prefix) {
+			return nil, "", fmt.Errorf("invalid archive prefix %q", prefix)
+		}
+		if c.Region == "" {
+			return nil, "", errors.New("s3 archive needs a region")
+		}
+		c.Type, c.Bucket = TargetS3, bucket
+		target, err := NewS3Target(c, Credentials{
+			AccessKeyID:     orEnv(c.AccessKeyID, "AWS_ACCESS_KEY_ID"),
+			SecretAccessKey: orEnv(c.SecretAccessKey, "AWS_SECRET_ACCESS_KEY"),
+			SessionToken:    orEnv(c.SessionToken, "AWS_SESSION_TOKEN"),
+		})
+		if err != nil {
+			return nil, "", err
+		}
+		return target, prefix, nil
+	}
+	root := strings.TrimPrefix(uri, "dir:")
+	if root == "" {
+		return nil, "", errors.New("archive path is empty")
+	}
+	target, err := NewDirTarget(root)
+	if err != nil {
+		return nil, "", err
+	}
+	return target, "", nil
+}
+
+// ArchiveReport is the outcome of VerifyArchive. The archive is intact when Valid.
+type ArchiveReport struct {
+	Runs     []*RunArchive
+	Problems []string // objects that could not be attributed to a run
+}
+
+// RunArchive is what the archive holds of one run. Head is the last event of the last
+// segment; events stored after it were never committed and are not covered.
+type RunArchive struct {
+	Report
+	RunID            string
+	PubKey           string
+	Pinned           bool // PubKey was given, not taken from the first segment
+	FirstSeq         uint64
+	Head             audit.Checkpoint
+	Duplicates       int // identical re-uploads of a segment
+	AcknowledgedGaps []audit.Gap
+}
+
+// Valid reports whether every run verified without problems.
+func (r *ArchiveReport) Valid() bool {
+	if len(r.Problems) > 0 {
+		return false
+	}
+	for _, run := range r.Runs {
+		if len(run.Problems) > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// archiveSegment is a segment object found by listing, with every stored version.
+type archiveSegment struct {
+	key      string
+	dir      string
+	fromSeq  uint64
+	toSeq    uint64
+	versions []string
+}
+
+// VerifyArchive verifies every run under prefix from its segments alone. Each segment's
+// signature, event hashes and event signatures are checked, and consecutive segments must
+// link into one chain from seq 0. A missing stretch is a problem unless a
+// gap_acknowledged event in the archive covers it. pubKey, when set, pins the key every
+// segment must be signed with; otherwise each run's first segment names it.
+func VerifyArchive(archive Archive, prefix, pubKey string) (*ArchiveReport, error) {
+	if err := assert.NotNil(archive, "archive"); err != nil {
+		return nil, err
+	}
+	objects, err := archive.List(prefix)
+	if err != nil {
+		return nil, fmt.Errorf("listing %s: %w", archive.Name(), err)
+	}
+	report := &ArchiveReport{}
+	runs := make(map[string][]*archiveSegment)
+	byKey := make(map[string]*archiveSegment)
+	for i := 0; i < len(objects); i++ {
+		obj := objects[i]
+		if seg, ok := byKey[obj.Key]; ok {
+			seg.versions = append(seg.versions, obj.VersionID)
+			continue
+		}
+		seg, ok := parseSegmentKey(obj.Key)
+		if !ok {
+			if len(report.Problems) < maxReportProblems {
+				report.Problems = append(report.Problems, fmt.Sprintf("%s is not a segment object", obj.Key))
+			}
+			continue
+		}
+		seg.versions = []string{obj.VersionID}
+		byKey[obj.Key] = seg
+		runs[seg.dir] = append(runs[seg.dir], seg)
+	}
+	dirs := make([]string, 0, len(runs))
+	for dir := range runs {
+		dirs = append(dirs, dir)
+	}
+	sort.Strings(dirs)
+	for _, dir := range dirs {
+		report.Runs = append(report.Runs, verifyArchivedRun(archive, runs[dir], pubKey))
+	}
+	return report, nil
+}
+
+// parseSegmentKey reads the run directory and sequence range from a key written by the
+// committer: <prefix><run-id>/segment-<from>-<to>-<unix>.json.
+func parseSegmentKey(key string) (*archiveSegment, bool) {
+	dir, name := path.Split(key)
+	var from, to, unix uint64
+	if n, err := fmt.Sscanf(name, "segment-%d-%d-%d.json", &from, &to, &unix); err != nil || n != 3 || from > to {
+		return nil, false
+	}
+	if fmt.Sprintf("segment-%010d-%010d-%d.json", from, to, unix) != name {
+		return nil, false
+	}
+	return &archiveSegment{key: key, dir: strings.TrimSuffix(dir, "/"), fromSeq: from, toSeq: to}, true
+}
+
+// verifyArchivedRun walks one run's segments in sequence order.
+func verifyArchivedRun(archive Archive, segs []*archiveSegment, pubKey string) *RunArchive {
+	sort.Slice(segs, func(i, j int) bool {
+		if segs[i].fromSeq != segs[j].fromSeq {
+			return segs[i].fromSeq < segs[j].fromSeq
+		}
+		return segs[i].toSeq < segs[j].toSeq
+	})
+	run := &RunArchive{RunID: path.Base(segs[0].dir), PubKey: pubKey, Pinned: pubKey != "", FirstSeq: segs[0].fromSeq}
+	acked := make(map[audit.Gap]bool)
+	var missing []archiveGap
+	// head is the last segment reached. Its Hash is empty when that segment failed, so
+	// the next one is verified on its own rather than reported twice.
+	var head *audit.Checkpoint
+	for _, obj := range segs {
+		seg, err := loadArchivedSegment(archive, obj, run)
+		if err != nil {
+			run.problem("segment %s: %v", obj.key, err)
+			head = &audit.Checkpoint{RunID: run.RunID, Seq: obj.toSeq}
+			continue
+		}
+		if run.PubKey == "" {
+			run.PubKey = seg.SignerPubKey
+		}
+		if seg.SignerPubKey != run.PubKey {
+			run.problem("segment %s is signed by %.16s..., not the run key %.16s...", obj.key, seg.SignerPubKey, run.PubKey)
+			head = &audit.Checkpoint{RunID: run.RunID, Seq: seg.ToSeq}
+			continue
+		}
+		for gap := range audit.AcknowledgedGaps(seg.Events) {
+			acked[gap] = true
+		}
+		verifier := audit.NewStreamVerifier(run.PubKey, nil)
+		switch {
+		case head == nil:
+			if seg.FromSeq > 0 {
+				missing = append(missing, archiveGap{gap: audit.Gap{From: 0, To: seg.FromSeq - 1}, linked: true})
+			}
+		case seg.FromSeq <= head.Seq:
+			if seg.ToSeq == head.Seq && head.Hash != "" && seg.HeadHash == head.Hash {
+				run.Duplicates++
+			} else {
+				run.problem("segment %s overlaps seq %d-%d", obj.key, seg.FromSeq, min(seg.ToSeq, head.Seq))
+			}
+			continue
+		case seg.FromSeq == head.Seq+1:
+			if head.Hash != "" {
+				verifier.Resume(*head)
+			}
+		default:
+			// After an acknowledged gap the chain links to the last event before it.
+			gap := audit.Gap{From: head.Seq + 1, To: seg.FromSeq - 1}
+			missing = append(missing, archiveGap{gap: gap, linked: head.Hash != "" && seg.Events[0].PrevHash == head.Hash})
+		}
+		head = &audit.Checkpoint{RunID: run.RunID, Seq: seg.ToSeq}
+		if !verifier.Verify(seg.Events) {
+			v := verifier.Result()
+			run.problem("segment %s: seq %d: %s", obj.key, v.FailedAtSeq, v.ErrorMessage)
+			continue
+		}
+		head.Hash = seg.HeadHash
+		run.Segments++
+		run.Events += len(seg.Events)
+		run.Head = *head
+	}
+	// An acknowledgement is written after the gap it covers, so gaps are judged last.
+	for _, m := range missing {
+		switch {
+		case !acked[m.gap]:
+			run.problem("%s is not in the archive", m.gap)
+		case !m.linked:
+			run.problem("%s is acknowledged, but the chain does not link across it", m.gap)
+		default:
+			run.AcknowledgedGaps = append(run.AcknowledgedGaps, m.gap)
+		}
+	}
+	return run
+}
+
+// archiveGap is a stretch of a run no segment holds, and whether the segment after it
+// links to the one before.
+type archiveGap struct {
+	gap    audit.Gap
+	linked bool
+}
+
+// loadArchivedSegment fetches the first version of a segment object and checks it is
+// intact and belongs where its key puts it. Later versions must hold the same bytes.
+func loadArchivedSegment(archive Archive, obj *archiveSegment, run *RunArchive) (*Segment, error) {
+	data, err := archive.Get(obj.key, obj.versions[0])
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(data)
+	for i := 1; i < len(obj.versions); i++ {
+		other, err := archive.Get(obj.key, obj.versions[i])
+		if err != nil {
+			run.problem("segment %s version %s: %v", obj.key, obj.versions[i], err)
+			continue
+		}
+		if sha256.Sum256(other) != sum {
+			run.problem("segment %s version %s differs from the first version %s", obj.key, obj.versions[i], obj.versions[0])
+		}
+	}
+	var seg Segment
+	if err := json.Unmarshal(data, &seg); err != nil {
+		return nil, fmt.Errorf("parsing: %w", err)
+	}
+	if seg.RunID != run.RunID || seg.FromSeq != obj.fromSeq || seg.ToSeq != obj.toSeq {
+		return nil, fmt.Errorf("holds run %s seq %d-%d, not what its key names", seg.RunID, seg.FromSeq, seg.ToSeq)
+	}
+	if err := seg.Check(); err != nil {
+		return nil, err
+	}
+	return &seg, nil
+}
@@ -0,0 +1,204 @@
+package worm
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/slyt3/Logryph/internal/assert"
+	"github.com/slyt3/Logryph/internal/crypto"
+	"github.com/slyt3/Logryph/internal/logging"
+	"github.com/slyt3/Logryph/internal/models"
+	"github.com/slyt3/Logryph/internal/pool"
+)
+
+// EventTypeSegment records a committed segment in the chain.
+const EventTypeSegment = "worm_segment"
+
+const (
+	maxSegmentEvents    = 5000
+	maxSegmentsPerCycle = 100
+	maxCommitTicks      = 1 << 30
+)
+
+// Source is the subset of the ledger the committer and verifier read.
+type Source interface {
+	GetRunID() (string, error)
+	GetEventsFrom(runID string, fromSeq uint64, limit int) ([]models.Event, error)
+	GetEventsByType(eventType string) ([]models.Event, error)
+}
+
+// Committer periodically writes the events committed since the last segment to a Target
+// and submits a worm_segment event recording where they went.
+type Committer struct {
+	target    Target
+	src       Source
+	signer    *crypto.Signer
+	submit    func(*models.Event)
+	interval  time.Duration
+	retention time.Duration
+	prefix    string
+
+	mu     sync.Mutex // serialises Commit and guards the cursor
+	runID  string
+	next   uint64
+	loaded bool
+
+	stop     chan struct{}
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+// NewCommitter commits to target on the schedule in c. submit appends the worm_segment
+// event to the chain, normally Worker.Submit.
+func NewCommitter(c Config, target Target, src Source, signer *crypto.Signer, submit func(*models.Event)) (*Committer, error) {
+	if err := assert.NotNil(target, "worm target"); err != nil {
+		return nil, err
+	}
+	if err := assert.NotNil(src, "worm source"); err != nil {
+		return nil, err
+	}
+	if err := assert.NotNil(signer, "signer"); err != nil {
+		return nil, err
+	}
+	if submit == nil {
+		return nil, errors.New("worm committer needs a submit function")
+	}
+	interval, err := c.interval()
+	if err != nil {
+		return nil, err
+	}
+	return &Committer{
+		target: target, src: src, signer: signer, submit: submit,
+		interval: interval, retention: c.retention(), prefix: c.Prefix,
+		stop: make(chan struct{}), done: make(chan struct{}),
+	}, nil
+}
+
+// Start commits every interval until Stop.
+func (c *Committer) Start() {
+	go c.run()
+}
+
+func (c *Committer) run() {
+	defer close(c.done)
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+	for i := 0; i < maxCommitTicks; i++ {
+		select {
+		case <-c.stop:
+			return
+		case <-ticker.C:
+			if err := c.Commit(); err != nil {
+				logging.Error("worm_commit_failed", logging.Fields{Component: "worm", Error: err.Error()})
+			}
+		}
+	}
+}
+
+// Stop ends periodic commits. Events after the last segment are committed by the next
+// process to run against this ledger.
+func (c *Committer) Stop() {
+	c.stopOnce.Do(func() { close(c.stop) })
+	<-c.done
+}
+
+// Commit writes segments for every stored event after the last committed one. A stretch
+// holding only worm_segment records is left for the next segment, so an idle ledger does
+// not commit a segment per interval.
+func (c *Committer) Commit() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	runID, err := c.src.GetRunID()
+	if err != nil || runID == "" {
+		return err
+	}
+	if runID != c.runID || !c.loaded {
+		if c.next, err = lastCommitted(c.src, runID); err != nil {
+			return err
+		}
+		c.runID, c.loaded = runID, true
+	}
+	for i := 0; i < maxSegmentsPerCycle; i++ {
+		events, err := c.src.GetEventsFrom(runID, c.next, maxSegmentEvents)
+		if err != nil {
+			return err
+		}
+		if onlyRecords(events) {
+			return nil
+		}
+		if err := c.commitSegment(events); err != nil {
+			return err
+		}
+		c.next = events[len(events)-1].SeqIndex + 1
+	}
+	return nil
+}
+
+func (c *Committer) commitSegment(events []models.Event) error {
+	seg, err := newSegment(events, c.signer)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(seg)
+	if err != nil {
+		return fmt.Errorf("encoding segment: %w", err)
+	}
+	key := fmt.Sprintf("%s%s/segment-%010d-%010d-%d.json", c.prefix, seg.RunID, seg.FromSeq, seg.ToSeq, seg.Timestamp.Unix())
+	retainUntil := seg.Timestamp.Add(c.retention)
+	version, err := c.target.Put(key, data, retainUntil)
+	if err != nil {
+		return err
+	}
+	sum := sha256.Sum256(data)
+
+	record := pool.GetEvent()
+	record.ID = uuid.New().String()[:8]
+	record.Timestamp = time.Now()
+	record.EventType = EventTypeSegment
+	record.Method = "logryph:worm_segment"
+	record.Actor = "system"
+	record.Params["target"] = c.target.Name()
+	record.Params["key"] = key
+	record.Params["version_id"] = version
+	record.Params["sha256"] = hex.EncodeToString(sum[:])
+	record.Params["from_seq"] = seg.FromSeq
+	record.Params["to_seq"] = seg.ToSeq
+	record.Params["head_hash"] = seg.HeadHash
+	if c.retention > 0 {
+		record.Params["retain_until"] = retainUntil.Format(time.RFC3339)
+	}
+	eventID := record.ID
+	c.submit(record)
+	logging.Info("worm_segment_committed", logging.Fields{Component: "worm", RunID: seg.RunID, EventID: eventID})
+	return nil
+}
+
+// lastCommitted returns the seq after the last segment recorded in runID's chain.
+func lastCommitted(src Source, runID string) (uint64, error) {
+	records, err := runRecords(src, runID)
+	if err != nil {
+		return 0, err
+	}
+	next := uint64(0)
+	for i := 0; i < len(records); i++ {
+		if to := records[i].toSeq + 1; to > next {
+			next = to
+		}
+	}
+	return next, nil
+}
+
+func onlyRecords(events []models.Event) bool {
+	for i := 0; i < len(events); i++ {
+		if events[i].EventType != EventTypeSegment {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,308 @@
+package worm
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	s3Timeout      = 60 * time.Second
+	maxObjectBytes = 256 << 20
+	maxErrorBytes  = 4 << 10
+	maxListBytes   = 16 << 20
+	maxListPages   = maxArchiveObjects / 1000
+	amzDateFormat  = "20060102T150405Z"
+	emptySHA256    = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+)
+
+// Credentials sign S3 requests.
+type Credentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+}
+
+// S3Target stores segments in a bucket with Object Lock enabled. Every object is written
+// in compliance mode, so no account, including root, can delete or overwrite that version
+// before its retain-until date.
+type S3Target struct {
+	bucket    string
+	region    string
+	endpoint  string // scheme://host of a path-style endpoint; empty for AWS
+	creds     Credentials
+	retention time.Duration
+	client    *http.Client
+	now       func() time.Time
+}
+
+// NewS3Target builds a target for c.Bucket. The bucket must have versioning and Object
+// Lock enabled; Put fails when S3 returns no version ID.
+func NewS3Target(c Config, creds Credentials) (*S3Target, error) {
+	if creds.AccessKeyID == "" || creds.SecretAccessKey == "" {
+		return nil, errors.New("s3 target needs AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY")
+	}
+	endpoint := ""
+	if c.Endpoint != "" {
+		u, err := url.Parse(c.Endpoint)
+		if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+			return nil, fmt.Errorf("invalid s3 endpoint %q", c.Endpoint)
+		}
+		endpoint = u.Scheme + "://" + u.Host
+	}
+	return &S3Target{
+		bucket:    c.Bucket,
+		region:    c.Region,
+		endpoint:  endpoint,
+		creds:     creds,
+		retention: c.retention(),
+		client:    &http.Client{Timeout: s3Timeout},
+		now:       time.Now,
+	}, nil
+}
+
+// Name describes the target for worm_segment events.
+func (s *S3Target) Name() string { return "s3://" + s.bucket }
+
+// objectURL uses virtual-hosted addressing on AWS and path-style on custom endpoints.
+func (s *S3Target) objectURL(key string) string {
+	if s.endpoint == "" {
+		return fmt.Sprintf("https://%s.s3.%s.amazonaws.com/%s", s.bucket, s.region, key)
+	}
+	return fmt.Sprintf("%s/%s/%s", s.endpoint, s.bucket, key)
+}
+
+// Put uploads data under compliance-mode Object Lock until retainUntil.
+func (s *S3Target) Put(key string, data []byte, retainUntil time.Time) (string, error) {
+	if !validKey(key) {
+		return "", fmt.Errorf("invalid key %q", key)
+	}
+	req, err := http.NewRequest(http.MethodPut, s.objectURL(key), bytes.NewReader(data))
+	if err != nil {
+		return "", fmt.Errorf("s3 put: %w", err)
+	}
+	md5sum := md5.Sum(data)
+	sum := sha256.Sum256(data)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Content-MD5", base64.StdEncoding.EncodeToString(md5sum[:]))
+	req.Header.Set("X-Amz-Object-Lock-Mode", "COMPLIANCE")
+	req.Header.Set("X-Amz-Object-Lock-Retain-Until-Date", retainUntil.UTC().Format(time.RFC3339))
+	resp, err := s.do(req, hex.EncodeToString(sum[:]))
+	if err != nil {
+		return "", fmt.Errorf("s3 put %s: %w", key, err)
+	}
+	_ = resp.Body.Close()
+	version := resp.Header.Get("X-Amz-Version-Id")
+	if version == "" || version == "null" {
+		return "", fmt.Errorf("s3 put %s: no version ID returned; enable versioning and Object Lock on %s", key, s.bucket)
+	}
+	return version, nil
+}
+
+// Get downloads the given version of key.
+func (s *S3Target) Get(key, versionID string) ([]byte, error) {
+	if !validKey(key) {
+		return nil, fmt.Errorf("invalid key %q", key)
+	}
+	req, err := http.NewRequest(http.MethodGet, s.objectURL(key)+"?versionId="+awsEscape(versionID), nil)
+	if err != nil {
+		return nil, fmt.Errorf("s3 get: %w", err)
+	}
+	resp, err := s.do(req, emptySHA256)
+	if err != nil {
+		return nil, fmt.Errorf("s3 get %s: %w", key, err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxObjectBytes))
+	if err != nil {
+		return nil, fmt.Errorf("s3 get %s: %w", key, err)
+	}
+	return data, nil
+}
+
+// listVersionsResult is the part of a ListObjectVersions response List reads. Delete
+// markers are not listed; an Object Lock bucket keeps the versions behind them.
+type listVersionsResult struct {
+	IsTruncated         bool   `xml:"IsTruncated"`
+	NextKeyMarker       string `xml:"NextKeyMarker"`
+	NextVersionIDMarker string `xml:"NextVersionIdMarker"`
+	Versions            []struct {
+		Key       string `xml:"Key"`
+		VersionID string `xml:"VersionId"`
+	} `xml:"Version"`
+}
+
+// List returns every version of every key under prefix with ListObjectVersions, oldest
+// version first within a key.
+func (s *S3Target) List(prefix string) ([]Object, error) {
+	var objects []Object
+	keyMarker, versionMarker := "", ""
+	for page := 0; page < maxListPages; page++ {
+		query := "?versions=&prefix=" + awsEscape(prefix)
+		if keyMarker != "" {
+			query += "&key-marker=" + awsEscape(keyMarker) + "&version-id-marker=" + awsEscape(versionMarker)
+		}
+		req, err := http.NewRequest(http.MethodGet, s.objectURL("")+query, nil)
+		if err != nil {
+			return nil, fmt.Errorf("s3 list: %w", err)
+		}
+		resp, err := s.do(req, emptySHA256)
+		if err != nil {
+			return nil, fmt.Errorf("s3 list %s: %w", prefix, err)
+		}
+		var result listVersionsResult
+		err = xml.NewDecoder(io.LimitReader(resp.Body, maxListBytes)).Decode(&result)
+		_ = resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("s3 list %s: parsing: %w", prefix, err)
+		}
+		for i := 0; i < len(result.Versions); i++ {
+			objects = append(objects, Object{Key: result.Versions[i].Key, VersionID: result.Versions[i].VersionID})
+		}
+		if len(objects) > maxArchiveObjects {
+			return nil, fmt.Errorf("s3 list %s: more than %d objects", prefix, maxArchiveObjects)
+		}
+		if !result.IsTruncated {
+			oldestFirst(objects)
+			return objects, nil
+		}
+		if result.NextKeyMarker == "" {
+			return nil, fmt.Errorf("s3 list %s: truncated listing without a next marker", prefix)
+		}
+		keyMarker, versionMarker = result.NextKeyMarker, result.NextVersionIDMarker
+	}
+	return nil, fmt.Errorf("s3 list %s: more than %d pages", prefix, maxListPages)
+}
+
+// oldestFirst reverses each key's run of versions, which S3 lists newest first.
+func oldestFirst(objects []Object) {
+	for start := 0; start < len(objects); {
+		end := start + 1
+		for end < len(objects) && objects[end].Key == objects[start].Key {
+			end++
+		}
+		for i, j := start, end-1; i < j; i, j = i+1, j-1 {
+			objects[i], objects[j] = objects[j], objects[i]
+		}
+		start = end
+	}
+}
+
+// do signs and sends req, turning non-2xx responses into errors.
+func (s *S3Target) do(req *http.Request, payloadHash string) (*http.Response, error) {
+	s.sign(req, payloadHash, s.now())
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBytes))
+		_ = resp.Body.Close()
+		return nil, fmt.Errorf("status %d: %s", resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+	return resp, nil
+}
+
+// sign adds AWS Signature Version 4 headers. Host, Content-MD5, Content-Type, Range and
+// every x-amz-* header are signed.
+func (s *S3Target) sign(req *http.Request, payloadHash string, now time.Time) {
+	amzDate := now.UTC().Format(amzDateFormat)
+	day := amzDate[:8]
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	if s.creds.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", s.creds.SessionToken)
+	}
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		lower := strings.ToLower(name)
+		if strings.HasPrefix(lower, "x-amz-") || lower == "content-md5" || lower == "content-type" || lower == "range" {
+			headers[lower] = strings.TrimSpace(strings.Join(values, ","))
+		}
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonical := strings.Join([]string{
+		req.Method, canonicalPath(req.URL.Path), canonicalQuery(req.URL.Query()),
+		canonicalHeaders.String(), signedHeaders, payloadHash,
+	}, "\n")
+	scope := day + "/" + s.region + "/s3/aws4_request"
+	hashed := sha256.Sum256([]byte(canonical))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(hashed[:])
+
+	key := hmacSHA256([]byte("AWS4"+s.creds.SecretAccessKey), day)
+	key = hmacSHA256(key, s.region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.creds.AccessKeyID, scope, signedHeaders, signature))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+func canonicalPath(path string) string {
+	if path == "" {
+		return "/"
+	}
+	segments := strings.Split(path, "/")
+	for i := range segments {
+		segments[i] = awsEscape(segments[i])
+	}
+	return strings.Join(segments, "/")
+}
+
+func canonicalQuery(q url.Values) string {
+	keys := make([]string, 0, len(q))
+	for k := range q {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	parts := make([]string, 0, len(keys))
+	for _, k := range keys {
+		for _, v := range q[k] {
+			parts = append(parts, awsEscape(k)+"="+awsEscape(v))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// awsEscape percent-encodes everything except RFC 3986 unreserved characters.
+func awsEscape(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || c == '-' || c == '_' || c == '.' || c == '~' {
+			b.WriteByte(c)
+			continue
+		}
+		fmt.Fprintf(&b, "%%%02X", c)
+	}
+	return b.String()
+}
@@ -0,0 +1,107 @@
+package worm
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/slyt3/Logryph/internal/assert"
+	"github.com/slyt3/Logryph/internal/crypto"
+	"github.com/slyt3/Logryph/internal/models"
+)
+
+// SegmentVersion identifies the segment layout.
+const SegmentVersion = "1"
+
+// Segment is one committed stretch of a run's chain. Signature is the ledger key's
+// signature over Digest of every other field.
+type Segment struct {
+	Version      string         `json:"version"`
+	RunID        string         `json:"run_id"`
+	FromSeq      uint64         `json:"from_seq"`
+	ToSeq        uint64         `json:"to_seq"`
+	HeadHash     string         `json:"head_hash"` // current_hash of ToSeq
+	SignerPubKey string         `json:"signer_pubkey"`
+	Timestamp    time.Time      `json:"timestamp"`
+	Events       []models.Event `json:"events"`
+	Signature    string         `json:"signature"`
+}
+
+// newSegment signs consecutive events of one run.
+func newSegment(events []models.Event, signer *crypto.Signer) (*Segment, error) {
+	if err := assert.NotNil(signer, "signer"); err != nil {
+		return nil, err
+	}
+	if len(events) == 0 {
+		return nil, errors.New("segment needs at least one event")
+	}
+	last := events[len(events)-1]
+	s := &Segment{
+		Version:      SegmentVersion,
+		RunID:        events[0].RunID,
+		FromSeq:      events[0].SeqIndex,
+		ToSeq:        last.SeqIndex,
+		HeadHash:     last.CurrentHash,
+		SignerPubKey: signer.GetPublicKey(),
+		Timestamp:    time.Now().UTC(),
+		Events:       events,
+	}
+	digest, err := s.Digest()
+	if err != nil {
+		return nil, err
+	}
+	if s.Signature, err = signer.SignHash(digest); err != nil {
+		return nil, fmt.Errorf("signing segment: %w", err)
+	}
+	return s, nil
+}
+
+// Digest returns the hex SHA-256 of the segment with Signature cleared.
+func (s *Segment) Digest() (string, error) {
+	unsigned := *s
+	unsigned.Signature = ""
+	data, err := json.Marshal(unsigned)
+	if err != nil {
+		return "", fmt.Errorf("encoding segment: %w", err)
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// Check verifies the segment signature, that the events are consecutive and linked, and
+// that each event's hash matches its contents.
+func (s *Segment) Check() error {
+	digest, err := s.Digest()
+	if err != nil {
+		return err
+	}
+	if !crypto.VerifyWithPublicKey(s.SignerPubKey, digest, s.Signature) {
+		return errors.New("segment signature is invalid")
+	}
+	if len(s.Events) == 0 || uint64(len(s.Events)) != s.ToSeq-s.FromSeq+1 {
+		return fmt.Errorf("segment holds %d events for seq %d-%d", len(s.Events), s.FromSeq, s.ToSeq)
+	}
+	for i := 0; i < len(s.Events); i++ {
+		e := &s.Events[i]
+		if e.RunID != s.RunID || e.SeqIndex != s.FromSeq+uint64(i) {
+			return fmt.Errorf("segment event %d is out of place", i)
+		}
+		if i > 0 && e.PrevHash != s.Events[i-1].CurrentHash {
+			return fmt.Errorf("segment seq %d does not link to seq %d", e.SeqIndex, e.SeqIndex-1)
+		}
+		hash, err := crypto.CalculateEventHash(e.PrevHash, e.HashPayload())
+		if err != nil {
+			return fmt.Errorf("hashing segment seq %d: %w", e.SeqIndex, err)
+		}
+		if hash != e.CurrentHash {
+			return fmt.Errorf("segment seq %d: hash does not match its contents", e.SeqIndex)
+		}
+	}
+	if s.Events[len(s.Events)-1].CurrentHash != s.HeadHash {
+		return errors.New("segment head hash does not match its last event")
+	}
+	return nil
+}
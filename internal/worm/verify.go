@@ -0,0 +1,151 @@
+package worm
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/slyt3/Logryph/internal/assert"
+	"github.com/slyt3/Logryph/internal/crypto"
+	"github.com/slyt3/Logryph/internal/models"
+)
+
+const maxReportProblems = 100
+
+// record is a worm_segment event's params.
+type record struct {
+	eventID   string
+	key       string
+	versionID string
+	sha256    string
+	fromSeq   uint64
+	toSeq     uint64
+}
+
+// runRecords returns the worm_segment records of runID in chain order.
+func runRecords(src Source, runID string) ([]record, error) {
+	events, err := src.GetEventsByType(EventTypeSegment)
+	if err != nil {
+		return nil, fmt.Errorf("loading worm_segment events: %w", err)
+	}
+	sort.Slice(events, func(i, j int) bool { return events[i].SeqIndex < events[j].SeqIndex })
+	var records []record
+	for i := 0; i < len(events); i++ {
+		e := &events[i]
+		if e.RunID != runID {
+			continue
+		}
+		key, _ := e.Params["key"].(string)
+		version, _ := e.Params["version_id"].(string)
+		sum, _ := e.Params["sha256"].(string)
+		from, okFrom := e.Params["from_seq"].(float64) // JSON numbers are float64
+		to, okTo := e.Params["to_seq"].(float64)
+		if key == "" || version == "" || !okFrom || !okTo {
+			return nil, fmt.Errorf("worm_segment event %s is incomplete", e.ID)
+		}
+		records = append(records, record{eventID: e.ID, key: key, versionID: version, sha256: sum, fromSeq: uint64(from), toSeq: uint64(to)})
+	}
+	return records, nil
+}
+
+// Report is the outcome of VerifySegments. The ledger matches its immutable copies when
+// Problems is empty.
+type Report struct {
+	Segments int
+	Events   int
+	Problems []string
+}
+
+func (r *Report) problem(format string, args ...interface{}) {
+	if len(r.Problems) < maxReportProblems {
+		r.Problems = append(r.Problems, fmt.Sprintf(format, args...))
+	}
+}
+
+// VerifySegments fetches every segment recorded in runID's chain from target, checks each
+// is intact and signed, and compares its events with the ledger. It reports segments
+// that are missing or altered, ledger events that were deleted or changed since they
+// were committed, and gaps between segments.
+func VerifySegments(src Source, target Target, runID string) (*Report, error) {
+	if err := assert.NotNil(target, "worm target"); err != nil {
+		return nil, err
+	}
+	records, err := runRecords(src, runID)
+	if err != nil {
+		return nil, err
+	}
+	report := &Report{}
+	next := uint64(0)
+	for i := 0; i < len(records); i++ {
+		rec := records[i]
+		if rec.fromSeq > next {
+			report.problem("seq %d-%d is not covered by any segment", next, rec.fromSeq-1)
+		}
+		if rec.toSeq+1 > next {
+			next = rec.toSeq + 1
+		}
+		seg, err := fetchSegment(target, rec)
+		if err != nil {
+			report.problem("segment %s: %v", rec.key, err)
+			continue
+		}
+		report.Segments++
+		report.Events += len(seg.Events)
+		if err := compareLedger(src, seg, report); err != nil {
+			return nil, err
+		}
+	}
+	return report, nil
+}
+
+// fetchSegment downloads the recorded version and checks it against the record.
+func fetchSegment(target Target, rec record) (*Segment, error) {
+	data, err := target.Get(rec.key, rec.versionID)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(data)
+	if rec.sha256 != "" && hex.EncodeToString(sum[:]) != rec.sha256 {
+		return nil, fmt.Errorf("content differs from the digest recorded in event %s", rec.eventID)
+	}
+	var seg Segment
+	if err := json.Unmarshal(data, &seg); err != nil {
+		return nil, fmt.Errorf("parsing: %w", err)
+	}
+	if seg.FromSeq != rec.fromSeq || seg.ToSeq != rec.toSeq {
+		return nil, fmt.Errorf("holds seq %d-%d, recorded as %d-%d", seg.FromSeq, seg.ToSeq, rec.fromSeq, rec.toSeq)
+	}
+	if err := seg.Check(); err != nil {
+		return nil, err
+	}
+	return &seg, nil
+}
+
+// compareLedger checks each committed event is still in the ledger unchanged.
+func compareLedger(src Source, seg *Segment, report *Report) error {
+	local, err := src.GetEventsFrom(seg.RunID, seg.FromSeq, len(seg.Events))
+	if err != nil {
+		return fmt.Errorf("loading ledger seq %d-%d: %w", seg.FromSeq, seg.ToSeq, err)
+	}
+	bySeq := make(map[uint64]*models.Event, len(local))
+	for i := 0; i < len(local); i++ {
+		bySeq[local[i].SeqIndex] = &local[i]
+	}
+	for i := 0; i < len(seg.Events); i++ {
+		want := &seg.Events[i]
+		got, ok := bySeq[want.SeqIndex]
+		switch {
+		case !ok:
+			report.problem("seq %d (event %s) is missing from the ledger", want.SeqIndex, want.ID)
+		case got.ID != want.ID || got.CurrentHash != want.CurrentHash || got.PrevHash != want.PrevHash || got.Signature != want.Signature:
+			report.problem("seq %d differs from its immutable copy (ledger event %s, committed %s)", want.SeqIndex, got.ID, want.ID)
+		default:
+			if hash, err := crypto.CalculateEventHash(got.PrevHash, got.HashPayload()); err != nil || hash != got.CurrentHash {
+				report.problem("seq %d: ledger contents no longer match the committed hash", want.SeqIndex)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,251 @@
+// Package worm commits signed segments of the ledger chain to write-once storage (S3 with
+// Object Lock in compliance mode, or a WORM filesystem mount) and cross-checks the ledger
+// against those copies. Each committed segment is recorded in the chain itself by a
+// worm_segment event carrying the object's key and version ID, so the record of what
+// was committed is covered by the same hashes and signatures as the events it protects.
+package worm
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Target types.
+const (
+	TargetS3  = "s3"
+	TargetDir = "dir"
+)
+
+const (
+	defaultInterval = time.Hour
+	minInterval     = time.Minute
+	maxRetention    = 100 * 365
+	maxKeyLen       = 512
+)
+
+// Config is the worm section of the policy file. An empty Type disables WORM commits.
+type Config struct {
+	Type          string `yaml:"type,omitempty"`           // s3 or dir
+	Interval      string `yaml:"interval,omitempty"`       // how often to commit a segment; default 1h
+	RetentionDays int    `yaml:"retention_days,omitempty"` // s3: Object Lock retain-until, days from commit
+	Bucket        string `yaml:"bucket,omitempty"`         // s3
+	Region        string `yaml:"region,omitempty"`         // s3
+	Endpoint      string `yaml:"endpoint,omitempty"`       // s3-compatible endpoint; AWS when empty
+	Prefix        string `yaml:"prefix,omitempty"`         // key prefix for segment objects
+	Path          string `yaml:"path,omitempty"`           // dir: WORM mount, e.g. a SnapLock volume
+	// S3 credentials, normally secret references such as ${file:/run/secrets/aws-key}.
+	// Each falls back to the standard AWS variable when empty.
+	AccessKeyID     string `yaml:"access_key_id,omitempty"`
+	SecretAccessKey string `yaml:"secret_access_key,omitempty"`
+	SessionToken    string `yaml:"session_token,omitempty"`
+}
+
+// Target is write-once storage for segments. Put returns an ID that names exactly the
+// bytes written: the S3 version ID, or the SHA-256 for a directory.
+type Target interface {
+	Name() string
+	Put(key string, data []byte, retainUntil time.Time) (versionID string, err error)
+	Get(key, versionID string) ([]byte, error)
+}
+
+// ValidateConfig checks the worm section. A disabled section is always valid.
+func ValidateConfig(c Config) error {
+	if c.Type == "" {
+		return nil
+	}
+	if _, err := c.interval(); err != nil {
+		return err
+	}
+	if c.Prefix != "" && !validKey(c.Prefix) {
+		return fmt.Errorf("invalid prefix %q: use letters, digits, '/', '.', '_' and '-'", c.Prefix)
+	}
+	switch c.Type {
+	case TargetS3:
+		if c.Bucket == "" || c.Region == "" {
+			return errors.New("s3 target needs bucket and region")
+		}
+		if c.RetentionDays <= 0 || c.RetentionDays > maxRetention {
+			return fmt.Errorf("s3 target needs retention_days between 1 and %d", maxRetention)
+		}
+	case TargetDir:
+		if c.Path == "" {
+			return errors.New("dir target needs path")
+		}
+	default:
+		return fmt.Errorf("unknown type %q (want s3 or dir)", c.Type)
+	}
+	return nil
+}
+
+func (c Config) interval() (time.Duration, error) {
+	if c.Interval == "" {
+		return defaultInterval, nil
+	}
+	d, err := time.ParseDuration(c.Interval)
+	if err != nil || d < minInterval {
+		return 0, fmt.Errorf("invalid interval %q: must be a duration of at least %s", c.Interval, minInterval)
+	}
+	return d, nil
+}
+
+func (c Config) retention() time.Duration {
+	return time.Duration(c.RetentionDays) * 24 * time.Hour
+}
+
+// NewTarget builds the target described by c. S3 credentials come from c, or else from
+// the standard AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and AWS_SESSION_TOKEN variables.
+func NewTarget(c Config) (Target, error) {
+	if err := ValidateConfig(c); err != nil {
+		return nil, err
+	}
+	switch c.Type {
+	case TargetS3:
+		return NewS3Target(c, Credentials{
+			AccessKeyID:     orEnv(c.AccessKeyID, "AWS_ACCESS_KEY_ID"),
+			SecretAccessKey: orEnv(c.SecretAccessKey, "AWS_SECRET_ACCESS_KEY"),
+			SessionToken:    orEnv(c.SessionToken, "AWS_SESSION_TOKEN"),
+		})
+	case TargetDir:
+		return NewDirTarget(c.Path)
+	}
+	return nil, errors.New("worm is not configured")
+}
+
+func orEnv(value, name string) string {
+	if value != "" {
+		return value
+	}
+	return os.Getenv(name)
+}
+
+func validKey(key string) bool {
+	if key == "" || len(key) > maxKeyLen || strings.Contains(key, "..") || strings.HasPrefix(key, "/") {
+		return false
+	}
+	for _, r := range key {
+		ok := r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || strings.ContainsRune("/._-", r)
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// DirTarget writes each segment once, read-only, under a directory. On a WORM mount the
+// filesystem enforces retention; elsewhere it only guards against accidental overwrite.
+type DirTarget struct {
+	root string
+}
+
+// NewDirTarget uses root, which must exist.
+func NewDirTarget(root string) (*DirTarget, error) {
+	info, err := os.Stat(root)
+	if err != nil {
+		return nil, fmt.Errorf("worm dir: %w", err)
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("worm dir: %s is not a directory", root)
+	}
+	return &DirTarget{root: root}, nil
+}
+
+// Name describes the target for worm_segment events.
+func (d *DirTarget) Name() string { return "dir:" + d.root }
+
+// Put creates key exclusively and returns the SHA-256 of data as its version ID.
+func (d *DirTarget) Put(key string, data []byte, _ time.Time) (string, error) {
+	if !validKey(key) {
+		return "", fmt.Errorf("invalid key %q", key)
+	}
+	path := filepath.Join(d.root, filepath.FromSlash(key))
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return "", fmt.Errorf("worm dir: %w", err)
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o444)
+	if err != nil {
+		return "", fmt.Errorf("worm dir: %w", err)
+	}
+	if _, err := f.Write(data); err != nil {
+		_ = f.Close()
+		return "", fmt.Errorf("worm dir: writing %s: %w", key, err)
+	}
+	if err := f.Sync(); err != nil {
+		_ = f.Close()
+		return "", fmt.Errorf("worm dir: syncing %s: %w", key, err)
+	}
+	if err := f.Close(); err != nil {
+		return "", fmt.Errorf("worm dir: closing %s: %w", key, err)
+	}
+	return versionOf(data), nil
+}
+
+// Get reads key and checks it still hashes to versionID.
+func (d *DirTarget) Get(key, versionID string) ([]byte, error) {
+	if !validKey(key) {
+		return nil, fmt.Errorf("invalid key %q", key)
+	}
+	data, err := os.ReadFile(filepath.Join(d.root, filepath.FromSlash(key)))
+	if err != nil {
+		return nil, fmt.Errorf("worm dir: %w", err)
+	}
+	if versionOf(data) != versionID {
+		return nil, fmt.Errorf("worm dir: %s no longer matches version %s", key, versionID)
+	}
+	return data, nil
+}
+
+// List returns every file under prefix with its SHA-256 as the version ID.
+func (d *DirTarget) List(prefix string) ([]Object, error) {
+	if prefix != "" && !validKey(prefix) {
+		return nil, fmt.Errorf("invalid prefix %q", prefix)
+	}
+	// Walk only the directory the prefix is in.
+	start := filepath.Join(d.root, filepath.FromSlash(path.Dir("./"+prefix)))
+	var objects []Object
+	err := filepath.WalkDir(start, func(p string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) && p == start {
+				return fs.SkipAll
+			}
+			return err
+		}
+		if entry.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(d.root, p)
+		if err != nil {
+			return err
+		}
+		key := filepath.ToSlash(rel)
+		if !strings.HasPrefix(key, prefix) {
+			return nil
+		}
+		if len(objects) >= maxArchiveObjects {
+			return fmt.Errorf("more than %d objects", maxArchiveObjects)
+		}
+		data, err := os.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		objects = append(objects, Object{Key: key, VersionID: versionOf(data)})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("worm dir: %w", err)
+	}
+	return objects, nil
+}
+
+// versionOf is the version ID of data in a directory: its SHA-256.
+func versionOf(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
@@ -14,25 +14,16 @@ import (
 	"strings"
 	"testing"
 	"time"
+
+	"github.com/slyt3/Logryph/internal/ledger/store"
 )
 
 func TestIntegration(t *testing.T) {
-	// 1. Build binaries
+	// 1. Build the binary; logyctl serve is the proxy
 	wd, _ := os.Getwd()
-	logryphPath := filepath.Join(wd, "logryph")
 	cliPath := filepath.Join(wd, "logyctl")
 
-	cmd := exec.Command("go", "build", "-o", logryphPath, "../main.go")
-	if err := cmd.Run(); err != nil {
-		t.Fatalf("Failed to build logryph: %v", err)
-	}
-	t.Cleanup(func() {
-		if err := os.Remove(logryphPath); err != nil && !os.IsNotExist(err) {
-			t.Errorf("Failed to remove logryph binary: %v", err)
-		}
-	})
-
-	cmd = exec.Command("go", "build", "-o", cliPath, "../cmd/logyctl/main.go")
+	cmd := exec.Command("go", "build", "-o", cliPath, "../cmd/logyctl")
 	if err := cmd.Run(); err != nil {
 		t.Fatalf("Failed to build logyctl: %v", err)
 	}
@@ -91,7 +82,7 @@ policies:
 	})
 
 	// 4. Start Logryph Proxy
-	logryphCmd := exec.Command(logryphPath)
+	logryphCmd := exec.Command(cliPath, "serve")
 	logryphCmd.Dir = tmpDir
 	// Create a pipe for stderr (standard log output goes here)
 	stderr, _ := logryphCmd.StderrPipe()
@@ -112,7 +103,7 @@ policies:
 			}
 			line := scanner.Text()
 			fmt.Printf("[%s] %s\n", name, line)
-			if strings.Contains(line, "Admin API:") {
+			if strings.Contains(line, `"status":"ready"`) {
 				select {
 				case ready <- true:
 				default:
@@ -125,8 +116,7 @@ policies:
 
 	select {
 	case <-ready:
-		// Proxy is ready, give it a moment to fully bind
-		time.Sleep(500 * time.Millisecond)
+		// The readiness line is written once both listeners are bound
 	case <-time.After(45 * time.Second): // Build and startup might take time
 		t.Fatal("Timeout waiting for proxy readiness")
 	}
@@ -163,8 +153,31 @@ policies:
 		t.Errorf("Expected result in response 2 (passive mode should not block)")
 	}
 
+	// 6b. Correlation: the request ID is echoed and the trace context is recorded
+	const traceparent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	corrReq, _ := http.NewRequest(http.MethodPost, "http://localhost:9999", strings.NewReader(`{"jsonrpc":"2.0","id":3,"method":"mcp:list_tools","params":{}}`))
+	corrReq.Header.Set("X-Logryph-Request-ID", "itest-req-1")
+	corrReq.Header.Set("traceparent", traceparent)
+	corrResp, err := http.DefaultClient.Do(corrReq)
+	if err != nil {
+		t.Fatalf("Correlated request failed: %v", err)
+	}
+	_ = corrResp.Body.Close()
+	if got := corrResp.Header.Get("X-Logryph-Request-ID"); got != "itest-req-1" {
+		t.Errorf("Expected request ID to be echoed, got %q", got)
+	}
+	plainResp, err := http.Post("http://localhost:9999", "application/json", strings.NewReader(`{"jsonrpc":"2.0","id":4,"method":"mcp:list_tools","params":{}}`))
+	if err != nil {
+		t.Fatalf("Uncorrelated request failed: %v", err)
+	}
+	_ = plainResp.Body.Close()
+	if plainResp.Header.Get("X-Logryph-Request-ID") == "" {
+		t.Errorf("Expected a generated request ID on the response")
+	}
+
 	// Wait for async ledger write
 	time.Sleep(1 * time.Second)
+	checkCorrelatedEvents(t, filepath.Join(tmpDir, "logryph.db"), "itest-req-1", "4bf92f3577b34da6a3ce929d0e0e4736")
 
 	// 7. Verify recording and tagging via CLI
 	cliRiskCmd := exec.Command(cliPath, "risk")
@@ -188,6 +201,33 @@ policies:
 	}
 }
 
+// checkCorrelatedEvents asserts that both the call and its response carry the correlation.
+func checkCorrelatedEvents(t *testing.T, dbPath, requestID, traceID string) {
+	t.Helper()
+	db, err := store.NewDB(dbPath)
+	if err != nil {
+		t.Fatalf("Failed to open ledger: %v", err)
+	}
+	defer db.Close()
+	runID, err := db.GetRunID()
+	if err != nil {
+		t.Fatalf("Failed to get run: %v", err)
+	}
+	events, err := db.GetRecentEvents(runID, 100)
+	if err != nil {
+		t.Fatalf("Failed to read events: %v", err)
+	}
+	seen := map[string]bool{}
+	for _, e := range events {
+		if e.CorrelationID == requestID && e.TraceID == traceID && e.SpanID == "00f067aa0ba902b7" {
+			seen[e.EventType] = true
+		}
+	}
+	if !seen["tool_call"] || !seen["tool_response"] {
+		t.Errorf("Expected correlated tool_call and tool_response events, got %v", seen)
+	}
+}
+
 func sendRequest(url string, reqBody interface{}) (map[string]interface{}, error) {
 	b, _ := json.Marshal(reqBody)
 	resp, err := http.Post(url, "application/json", bytes.NewBuffer(b))
@@ -9,7 +9,6 @@ import (
 	"time"
 
 	"github.com/google/uuid"
-	_ "github.com/mattn/go-sqlite3"
 	"github.com/slyt3/Logryph/internal/ledger"
 	"github.com/slyt3/Logryph/internal/ledger/audit"
 	"github.com/slyt3/Logryph/internal/ledger/store"
@@ -71,7 +70,7 @@ func TestTamperDetection(t *testing.T) {
 	}
 
 	// Helper to get raw SQL connection for tampering
-	rawDB, err := sql.Open("sqlite3", dbPath)
+	rawDB, err := sql.Open(store.DriverName, dbPath)
 	if err != nil {
 		t.Fatalf("failed to open raw db: %v", err)
 	}